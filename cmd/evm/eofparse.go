@@ -0,0 +1,93 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	hexFlag = &cli.StringFlag{
+		Name:  "hex",
+		Usage: "single container data parse and validation",
+	}
+	initcodeFlag = &cli.BoolFlag{
+		Name:  "initcode",
+		Usage: "validate the container as initcode",
+	}
+)
+
+var eofParseCommand = &cli.Command{
+	Action: eofParseAction,
+	Name:   "eofparse",
+	Usage:  "Parses and validates EOF containers",
+	Description: `The eofparse command validates the given hex encoded EOF container,
+or each container read line by line from stdin if no --hex value is given.`,
+	Flags: []cli.Flag{hexFlag, initcodeFlag},
+}
+
+func eofParseAction(ctx *cli.Context) error {
+	var (
+		jt       = vm.NewEOFInstructionSetForTesting()
+		initcode = ctx.Bool(initcodeFlag.Name)
+	)
+	if ctx.IsSet(hexFlag.Name) {
+		c, err := parseAndValidate(ctx.String(hexFlag.Name), &jt, initcode)
+		if err != nil {
+			return err
+		}
+		fmt.Println(c)
+		return nil
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 1024*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if c, err := parseAndValidate(line, &jt, initcode); err != nil {
+			fmt.Printf("err: %v\n", err)
+		} else {
+			fmt.Printf("OK %d\n", len(c.MarshalBinary()))
+		}
+	}
+	return scanner.Err()
+}
+
+// parseAndValidate decodes the hex encoded container and validates its code.
+func parseAndValidate(s string, jt *vm.JumpTable, initcode bool) (*vm.Container, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode data: %w", err)
+	}
+	var c vm.Container
+	if err := c.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+	if err := c.ValidateCode(jt, initcode); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
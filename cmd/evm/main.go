@@ -229,6 +229,7 @@ func init() {
 	app.Commands = []*cli.Command{
 		compileCommand,
 		disasmCommand,
+		eofParseCommand,
 		runCommand,
 		blockTestCommand,
 		stateTestCommand,
@@ -246,7 +246,7 @@ func makeFullNode(ctx *cli.Context) *node.Node {
 		blsyncer := blsync.NewClient(ctx)
 		blsyncer.SetEngineRPC(rpc.DialInProc(srv))
 		stack.RegisterLifecycle(blsyncer)
-	} else {
+	} else if !ctx.IsSet(utils.ReplicaFlag.Name) { // CHANGE(taiko): the replica doesn't follow a consensus client.
 		// Launch the engine API for interacting with external consensus client.
 		err := catalyst.Register(stack, eth)
 		if err != nil {
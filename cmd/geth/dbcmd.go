@@ -18,6 +18,7 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
@@ -31,6 +32,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/console/prompt"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state/snapshot"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -54,6 +56,11 @@ var (
 		Name:  "remove.chain",
 		Usage: "If set, selects the state data for removal",
 	}
+	// CHANGE(taiko): dbVerifyRepairFlag rewinds the chain to the last consistent block.
+	dbVerifyRepairFlag = &cli.BoolFlag{
+		Name:  "repair",
+		Usage: "If set, rewinds the chain to the last consistent block for resyncing",
+	}
 
 	removedbCommand = &cli.Command{
 		Action:    removeDB,
@@ -78,11 +85,14 @@ Remove blockchain and state databases`,
 			dbPutCmd,
 			dbGetSlotsCmd,
 			dbDumpFreezerIndex,
+			dbRecompressFreezerCmd, // CHANGE(taiko): migrate the freezer table codec offline
+			dbVerifyCmd,            // CHANGE(taiko): verify the database consistency offline
 			dbImportCmd,
 			dbExportCmd,
 			dbMetadataCmd,
 			dbCheckStateContentCmd,
 			dbInspectHistoryCmd,
+			dbTrimHistoryCmd, // CHANGE(taiko): prune the state histories offline
 		},
 	}
 	dbInspectCmd = &cli.Command{
@@ -178,6 +188,45 @@ WARNING: This is a low-level operation which may cause database corruption!`,
 		}, utils.NetworkFlags, utils.DatabaseFlags),
 		Description: "This command displays information about the freezer index.",
 	}
+	// CHANGE(taiko): dbRecompressFreezerCmd migrates a freezer table to another
+	// compression codec.
+	dbRecompressFreezerCmd = &cli.Command{
+		Action:    freezerRecompress,
+		Name:      "freezer-recompress",
+		Usage:     "Recompress a specific freezer table with another codec",
+		ArgsUsage: "<freezer-type> <table-type> <codec (snappy|zstd|zstd-dict)>",
+		Flags: flags.Merge([]cli.Flag{
+			utils.SyncModeFlag,
+		}, utils.NetworkFlags, utils.DatabaseFlags),
+		Description: `This command migrates all the items of the specified compressed freezer
+table to another compression codec offline, e.g. recompressing the receipts with
+zstd and a dictionary trained on the table. The node must be stopped. If the
+migration is interrupted, the table stays readable with the original codec and
+the command can simply be rerun.`,
+	}
+	// CHANGE(taiko): dbVerifyCmd verifies the consistency of the ancient store
+	// and the key-value store.
+	dbVerifyCmd = &cli.Command{
+		Action:    verifyDatabase,
+		Name:      "verify",
+		Usage:     "Verify the consistency of the ancient store and the key-value store",
+		ArgsUsage: "[<start (int)> [<end (int)>]]",
+		Flags: flags.Merge([]cli.Flag{
+			utils.SyncModeFlag,
+			dbVerifyRepairFlag,
+		}, utils.NetworkFlags, utils.DatabaseFlags),
+		Description: `This command walks all the ancient tables, checking the index entries, the
+data file boundaries and the item counts across the tables. Then the canonical
+chain within the optional range is checked for the hashes, headers, bodies and
+receipts agreeing between the ancient store and the key-value store.
+
+The database is verified in read-only mode, leaving every inconsistency in
+place. If --repair is set, the database is reopened afterwards to truncate the
+corrupted ancient tables, and the chain is rewound to the block before the first
+inconsistent one, so that the node resyncs the rest of the chain on startup.
+
+A running node can verify its chain in the background with --db.verify.`,
+	}
 	dbImportCmd = &cli.Command{
 		Action:    importLDBdata,
 		Name:      "import",
@@ -229,6 +278,25 @@ WARNING: This is a low-level operation which may cause database corruption!`,
 		}, utils.NetworkFlags, utils.DatabaseFlags),
 		Description: "This command queries the history of the account or storage slot within the specified block range",
 	}
+	// CHANGE(taiko): dbTrimHistoryCmd is the database level entry point of the
+	// offline state history pruning, sharing it with 'geth snapshot prune-history'.
+	dbTrimHistoryCmd = &cli.Command{
+		Action: pruneHistory,
+		Name:   "trim-history",
+		Usage:  "Trim the state histories in path scheme to the most recent ones",
+		Flags: flags.Merge([]cli.Flag{
+			utils.StateHistoryFlag,
+		}, utils.NetworkFlags, utils.DatabaseFlags),
+		Description: `This command truncates the state history freezer tables to the most recent
+state histories, specified by --history.state, deleting the state lookups and
+index entries of the trimmed ones. The key-value store is compacted afterwards
+and the reclaimed disk space is reported. The node must be stopped.
+
+If the trimming is interrupted, it will be resumed in the next start.
+
+It's equivalent to 'geth snapshot prune-history' and only supported in path
+mode(--state.scheme=path).`,
+	}
 )
 
 func removeDB(ctx *cli.Context) error {
@@ -627,6 +695,145 @@ func freezerInspect(ctx *cli.Context) error {
 	return rawdb.InspectFreezerTable(ancient, freezer, table, start, end)
 }
 
+// CHANGE(taiko): freezerRecompress migrates a freezer table to another codec.
+func freezerRecompress(ctx *cli.Context) error {
+	if ctx.NArg() < 3 {
+		return fmt.Errorf("required arguments: %v", ctx.Command.ArgsUsage)
+	}
+	var (
+		freezer = ctx.Args().Get(0)
+		table   = ctx.Args().Get(1)
+	)
+	codec, err := rawdb.ParseFreezerCodec(ctx.Args().Get(2))
+	if err != nil {
+		return err
+	}
+	// The data directory is locked by the node, ensuring the freezer is not
+	// in use during the migration.
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	ancient := stack.ResolveAncient("chaindata", ctx.String(utils.AncientFlag.Name))
+	size, nsize, err := rawdb.RecompressFreezerTable(ancient, freezer, table, codec)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Recompressed %s/%s with %v: %v -> %v\n", freezer, table, codec, common.StorageSize(size), common.StorageSize(nsize))
+	return nil
+}
+
+// CHANGE(taiko): verifyDatabase checks the ancient stores and the canonical chain
+// for inconsistencies, optionally rewinding the chain before the first one.
+func verifyDatabase(ctx *cli.Context) error {
+	if ctx.NArg() > 2 {
+		return fmt.Errorf("max 2 arguments: %v", ctx.Command.ArgsUsage)
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	var (
+		issues  int
+		maxLogs = 100
+	)
+	report := func(err error) {
+		if issues < maxLogs {
+			log.Error("Database inconsistency", "err", err)
+		} else if issues == maxLogs {
+			log.Error("Too many inconsistencies, omitting the rest")
+		}
+		issues++
+	}
+	// Verify the structure of the ancient stores on the raw files, before the
+	// chain database is opened at all.
+	ancient := stack.ResolveAncient("chaindata", ctx.String(utils.AncientFlag.Name))
+	for _, name := range []string{rawdb.ChainFreezerName, rawdb.MerkleStateFreezerName} {
+		if name != rawdb.ChainFreezerName && !common.FileExist(filepath.Join(ancient, name)) {
+			continue
+		}
+		errs, err := rawdb.VerifyFreezer(ancient, name)
+		if err != nil {
+			report(fmt.Errorf("%s freezer: %w", name, err))
+			continue
+		}
+		for _, err := range errs {
+			report(fmt.Errorf("%s freezer: %w", name, err))
+		}
+	}
+	// Verify the canonical chain with the database opened in read-only mode,
+	// which reports the inconsistencies instead of silently repairing them. The
+	// read-only freezer refuses to open with a corrupted table though, the chain
+	// is only verified after the repair in that case.
+	var (
+		corrupted = issues > 0
+		repair    = ctx.Bool(dbVerifyRepairFlag.Name)
+		first     uint64
+		failed    bool
+	)
+	if !corrupted {
+		db := utils.MakeChainDatabase(ctx, stack, true)
+		var err error
+		first, failed, err = verifyChainRange(ctx, db, report)
+		db.Close()
+		if err != nil {
+			return err
+		}
+	}
+	if issues == 0 {
+		log.Info("Database is consistent")
+		return nil
+	}
+	log.Error("Database is inconsistent", "issues", issues)
+	if !repair {
+		return fmt.Errorf("database is inconsistent, rerun with --%s to repair it", dbVerifyRepairFlag.Name)
+	}
+	// Reopen the database for repairing. Opening it in read-write mode truncates
+	// the corrupted ancient tables to their last consistent item, after which the
+	// chain can be verified.
+	db := utils.MakeChainDatabase(ctx, stack, false)
+	defer db.Close()
+
+	if corrupted {
+		var err error
+		if first, failed, err = verifyChainRange(ctx, db, report); err != nil {
+			return err
+		}
+	}
+	if !failed {
+		log.Info("Repaired ancient store")
+		return nil
+	}
+	return core.RewindChain(db, first)
+}
+
+// verifyChainRange verifies the canonical chain within the optional range given
+// as the command arguments, defaulting to the whole chain.
+func verifyChainRange(ctx *cli.Context, db ethdb.Database, report func(error)) (uint64, bool, error) {
+	head := rawdb.ReadHeadHeader(db)
+	if head == nil {
+		return 0, false, errors.New("missing head header")
+	}
+	var (
+		start uint64
+		end   = head.Number.Uint64()
+		err   error
+	)
+	if ctx.NArg() > 0 {
+		if start, err = strconv.ParseUint(ctx.Args().Get(0), 10, 64); err != nil {
+			return 0, false, fmt.Errorf("invalid start: %v", err)
+		}
+	}
+	if ctx.NArg() > 1 {
+		if end, err = strconv.ParseUint(ctx.Args().Get(1), 10, 64); err != nil {
+			return 0, false, fmt.Errorf("invalid end: %v", err)
+		}
+	}
+	log.Info("Verifying chain", "start", start, "end", end)
+	first, failed := core.VerifyChain(db, start, end, func(number uint64, err error) {
+		report(fmt.Errorf("block %d: %w", number, err))
+	})
+	return first, failed, nil
+}
+
 func importLDBdata(ctx *cli.Context) error {
 	start := 0
 	switch ctx.NArg() {
@@ -22,6 +22,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/ethereum/go-ethereum/cmd/utils"
@@ -36,6 +37,8 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/triedb"
+	"github.com/ethereum/go-ethereum/triedb/pathdb"
 	"github.com/urfave/cli/v2"
 )
 
@@ -63,6 +66,25 @@ two version states are available: genesis and the specific one.
 The default pruning target is the HEAD-127 state.
 
 WARNING: it's only supported in hash mode(--state.scheme=hash)".
+`,
+			},
+			// CHANGE(taiko): offline state history pruning in path scheme.
+			{
+				Name:   "prune-history",
+				Usage:  "Prune the stale state histories in path scheme",
+				Action: pruneHistory,
+				Flags: flags.Merge([]cli.Flag{
+					utils.StateHistoryFlag,
+				}, utils.NetworkFlags, utils.DatabaseFlags),
+				Description: `
+geth snapshot prune-history [--history.state <number>]
+will delete all the state histories except the most recent ones, specified by
+--history.state, along with their state lookups and index. The key-value store
+is compacted afterwards and the reclaimed disk space is reported.
+
+If the pruning is interrupted, it will be resumed in the next start.
+
+WARNING: it's only supported in path mode(--state.scheme=path).
 `,
 			},
 			{
@@ -159,6 +181,38 @@ block is used.
 				Description: `
 The export-preimages command exports hash preimages to a flat file, in exactly
 the expected order for the overlay tree migration.
+`,
+			},
+			// CHANGE(taiko): state archive export and import.
+			{
+				Action:    snapshotExport,
+				Name:      "export",
+				Usage:     "Export the state into a chunked archive with range proofs",
+				ArgsUsage: "<archive> [<root>]",
+				Flags:     flags.Merge(utils.NetworkFlags, utils.DatabaseFlags),
+				Description: `
+geth snapshot export <archive> [<root>]
+will export the state of the given root, the HEAD state by default, into a
+compact binary archive by iterating the snapshot. The trie entries are grouped
+into chunks, each carrying the Merkle proof of its range, so that the archive
+is verifiable against the state root. If the file name ends with .gz, the
+archive is gzipped.
+`,
+			},
+			{
+				Action:    snapshotImport,
+				Name:      "import",
+				Usage:     "Import the state from a chunked archive into an empty database",
+				ArgsUsage: "<archive>",
+				Flags:     flags.Merge(utils.NetworkFlags, utils.DatabaseFlags),
+				Description: `
+geth snapshot import <archive>
+will verify the state archive against its state root and write the snapshot,
+the trie nodes in the configured state scheme and the contract codes into the
+database. The database must not hold a snapshot yet.
+
+The chain itself is not part of the archive, the imported state is usable once
+the block with the state root is available in the database.
 `,
 			},
 		},
@@ -205,6 +259,101 @@ func pruneState(ctx *cli.Context) error {
 	return nil
 }
 
+// CHANGE(taiko): pruneHistory truncates the state histories in path scheme to
+// the configured retention and reports the reclaimed disk space.
+func pruneHistory(ctx *cli.Context) error {
+	stack, config := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chaindb := utils.MakeChainDatabase(ctx, stack, false)
+	defer chaindb.Close()
+
+	if rawdb.ReadStateScheme(chaindb) != rawdb.PathScheme {
+		log.Error("State history pruning is only supported in path scheme")
+		return errors.New("unsupported state scheme")
+	}
+	keep := ctx.Uint64(utils.StateHistoryFlag.Name)
+	if keep == 0 {
+		log.Error("The entire state history is configured to be retained")
+		return errors.New("nothing to prune")
+	}
+	// Resolve the folders holding the key-value store and state histories
+	// for measuring the reclaimed disk space.
+	var (
+		start      = time.Now()
+		rootDir    = stack.ResolvePath("chaindata")
+		ancientDir = config.Eth.DatabaseFreezer
+	)
+	switch {
+	case ancientDir == "":
+		ancientDir = filepath.Join(rootDir, "ancient")
+	case !filepath.IsAbs(ancientDir):
+		ancientDir = config.Node.ResolvePath(ancientDir)
+	}
+	dirs := []string{rootDir, filepath.Join(ancientDir, rawdb.MerkleStateFreezerName)}
+	before := folderSize(dirs)
+
+	// Keep the state history index aligned if it's maintained, otherwise the
+	// index will be invalidated by opening the database without indexing.
+	pathconfig := *pathdb.Defaults
+	pathconfig.EnableStateIndexing = rawdb.ReadStateHistoryIndexHead(chaindb) != nil
+
+	db := triedb.NewDatabase(chaindb, &triedb.Config{PathDB: &pathconfig})
+	pruned, err := db.PruneHistory(keep)
+	db.Close()
+	if err != nil {
+		log.Error("Failed to prune state histories", "err", err)
+		return err
+	}
+	// Start compactions, will remove the deleted state lookups and index
+	// entries from the disk immediately.
+	if pruned > 0 {
+		cstart := time.Now()
+		for b := 0x00; b <= 0xf0; b += 0x10 {
+			var (
+				start = []byte{byte(b)}
+				end   = []byte{byte(b + 0x10)}
+			)
+			if b == 0xf0 {
+				end = nil
+			}
+			log.Info("Compacting database", "range", fmt.Sprintf("%#x-%#x", start, end), "elapsed", common.PrettyDuration(time.Since(cstart)))
+			if err := chaindb.Compact(start, end); err != nil {
+				log.Error("Database compaction failed", "error", err)
+				return err
+			}
+		}
+		log.Info("Database compaction finished", "elapsed", common.PrettyDuration(time.Since(cstart)))
+	}
+	var reclaimed common.StorageSize
+	if after := folderSize(dirs); after < before {
+		reclaimed = before - after
+	}
+	log.Info("State history pruning successful", "pruned", pruned, "reclaimed", reclaimed, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// CHANGE(taiko): folderSize returns the total size of the files inside the given
+// directories (but not files in subfolders).
+func folderSize(dirs []string) common.StorageSize {
+	var size common.StorageSize
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			if info, err := entry.Info(); err == nil {
+				size += common.StorageSize(info.Size())
+			}
+		}
+	}
+	return size
+}
+
 func verifyState(ctx *cli.Context) error {
 	stack, _ := makeConfigNode(ctx)
 	defer stack.Close()
@@ -661,6 +810,66 @@ func snapshotExportPreimages(ctx *cli.Context) error {
 	return utils.ExportSnapshotPreimages(chaindb, snaptree, ctx.Args().First(), root)
 }
 
+// CHANGE(taiko): snapshotExport exports the state into the chunked archive.
+func snapshotExport(ctx *cli.Context) error {
+	if ctx.NArg() < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chaindb := utils.MakeChainDatabase(ctx, stack, true)
+	defer chaindb.Close()
+
+	triedb := utils.MakeTrieDatabase(ctx, chaindb, false, true, false)
+	defer triedb.Close()
+
+	var root common.Hash
+	if ctx.NArg() > 1 {
+		rootBytes := common.FromHex(ctx.Args().Get(1))
+		if len(rootBytes) != common.HashLength {
+			return fmt.Errorf("invalid hash: %s", ctx.Args().Get(1))
+		}
+		root = common.BytesToHash(rootBytes)
+	} else {
+		headBlock := rawdb.ReadHeadBlock(chaindb)
+		if headBlock == nil {
+			log.Error("Failed to load head block")
+			return errors.New("no head block")
+		}
+		root = headBlock.Root()
+	}
+	snapConfig := snapshot.Config{
+		CacheSize:  256,
+		Recovery:   false,
+		NoBuild:    true,
+		AsyncBuild: false,
+	}
+	snaptree, err := snapshot.New(snapConfig, chaindb, triedb, root)
+	if err != nil {
+		return err
+	}
+	return utils.ExportSnapshot(chaindb, snaptree, triedb, ctx.Args().First(), root)
+}
+
+// CHANGE(taiko): snapshotImport imports the state from the chunked archive.
+func snapshotImport(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chaindb := utils.MakeChainDatabase(ctx, stack, false)
+	defer chaindb.Close()
+
+	scheme, err := rawdb.ParseStateScheme(ctx.String(utils.StateSchemeFlag.Name), chaindb)
+	if err != nil {
+		return err
+	}
+	return utils.ImportSnapshot(chaindb, ctx.Args().First(), scheme)
+}
+
 // checkAccount iterates the snap data layers, and looks up the given account
 // across all layers.
 func checkAccount(ctx *cli.Context) error {
@@ -47,6 +47,7 @@ import (
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/triedb"
 	"github.com/urfave/cli/v2"
 )
 
@@ -660,6 +661,51 @@ func ExportSnapshotPreimages(chaindb ethdb.Database, snaptree *snapshot.Tree, fn
 	return nil
 }
 
+// CHANGE(taiko): ExportSnapshot exports the state of the given root into the
+// chunked state archive, enumerating the snapshot.
+func ExportSnapshot(chaindb ethdb.Database, snaptree *snapshot.Tree, triedb *triedb.Database, fn string, root common.Hash) error {
+	log.Info("Exporting state", "file", fn, "root", root)
+
+	fh, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	// Enable gzip compressing if file name has gz suffix.
+	var writer io.Writer = fh
+	if strings.HasSuffix(fn, ".gz") {
+		gz := gzip.NewWriter(writer)
+		defer gz.Close()
+		writer = gz
+	}
+	buf := bufio.NewWriter(writer)
+	defer buf.Flush()
+
+	return snapshot.ExportArchive(buf, snaptree, chaindb, triedb, root, snapshot.DefaultArchiveChunkSize)
+}
+
+// CHANGE(taiko): ImportSnapshot imports the state from the chunked state archive,
+// writing the trie nodes in the given state scheme.
+func ImportSnapshot(db ethdb.Database, fn string, scheme string) error {
+	log.Info("Importing state", "file", fn, "scheme", scheme)
+
+	fh, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var reader io.Reader = bufio.NewReader(fh)
+	if strings.HasSuffix(fn, ".gz") {
+		if reader, err = gzip.NewReader(reader); err != nil {
+			return err
+		}
+	}
+	_, err = snapshot.ImportArchive(reader, db, scheme)
+	return err
+}
+
 // exportHeader is used in the export/import flow. When we do an export,
 // the first element we output is the exportHeader.
 // Whenever a backwards-incompatible change is made, the Version header
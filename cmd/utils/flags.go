@@ -31,6 +31,7 @@ import (
 	"os"
 	"path/filepath"
 	godebug "runtime/debug"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -54,6 +55,8 @@ import (
 	"github.com/ethereum/go-ethereum/eth/filters"
 	"github.com/ethereum/go-ethereum/eth/gasprice"
 	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/eth/tracers/live"
+	"github.com/ethereum/go-ethereum/eth/tracers/parity"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/ethdb/remotedb"
 	"github.com/ethereum/go-ethereum/ethstats"
@@ -100,9 +103,10 @@ var (
 		Usage:    "URL for remote database",
 		Category: flags.LoggingCategory,
 	}
+	// CHANGE(taiko): list the engines from the storage engine registry.
 	DBEngineFlag = &cli.StringFlag{
 		Name:     "db.engine",
-		Usage:    "Backing database implementation to use ('pebble' or 'leveldb')",
+		Usage:    "Backing database implementation to use (" + strings.Join(rawdb.DatabaseEngines(), ", ") + ")",
 		Value:    node.DefaultConfig.DBEngine,
 		Category: flags.EthCategory,
 	}
@@ -1380,7 +1384,8 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 		cfg.NetRestrict = list
 	}
 
-	if ctx.Bool(DeveloperFlag.Name) {
+	// CHANGE(taiko): the replica follows the primary's database without networking.
+	if ctx.Bool(DeveloperFlag.Name) || ctx.IsSet(ReplicaFlag.Name) {
 		// --dev mode can't use p2p networking.
 		cfg.MaxPeers = 0
 		cfg.ListenAddr = ""
@@ -1433,8 +1438,9 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	}
 	if ctx.IsSet(DBEngineFlag.Name) {
 		dbEngine := ctx.String(DBEngineFlag.Name)
-		if dbEngine != "leveldb" && dbEngine != "pebble" {
-			Fatalf("Invalid choice for db.engine '%s', allowed 'leveldb' or 'pebble'", dbEngine)
+		// CHANGE(taiko): accept any engine from the storage engine registry.
+		if !slices.Contains(rawdb.DatabaseEngines(), dbEngine) {
+			Fatalf("Invalid choice for db.engine '%s', allowed %s", dbEngine, strings.Join(rawdb.DatabaseEngines(), ", "))
 		}
 		log.Info(fmt.Sprintf("Using %s as db engine", dbEngine))
 		cfg.DBEngine = dbEngine
@@ -1656,6 +1662,12 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	setMiner(ctx, &cfg.Miner)
 	setRequiredBlocks(ctx, cfg)
 	setLes(ctx, cfg)
+	// CHANGE(taiko): set the preconfirmation block gossip options.
+	setPreconf(ctx, &cfg.Preconf)
+	// CHANGE(taiko): set the transaction pool policy options.
+	setTxPolicy(ctx, &cfg.TxPolicy)
+	// CHANGE(taiko): set the transaction pool snapshot options.
+	setTxPoolSnapshot(ctx, &cfg.TxPool)
 
 	// Cap the cache allowance and tune the garbage collector
 	mem, err := gopsutil.VirtualMemory()
@@ -1711,6 +1723,42 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.IsSet(StateHistoryFlag.Name) {
 		cfg.StateHistory = ctx.Uint64(StateHistoryFlag.Name)
 	}
+	// CHANGE(taiko): index the state histories to serve historical state.
+	if ctx.IsSet(StateHistoryIndexFlag.Name) {
+		cfg.StateHistoryIndex = ctx.Bool(StateHistoryIndexFlag.Name)
+	}
+	// CHANGE(taiko): follow the database of the primary node in replica mode,
+	// only the hash scheme is supported (see core.NewReplicaBlockChain).
+	if ctx.IsSet(ReplicaFlag.Name) {
+		if ctx.IsSet(StateSchemeFlag.Name) && ctx.String(StateSchemeFlag.Name) != rawdb.HashScheme {
+			Fatalf("--%s only supports the %s state scheme", ReplicaFlag.Name, rawdb.HashScheme)
+		}
+		cfg.Replica = ctx.String(ReplicaFlag.Name)
+	}
+	// CHANGE(taiko): persist the clean caches across restarts.
+	if ctx.IsSet(CacheJournalFlag.Name) {
+		cfg.CacheJournal = stack.ResolvePath(ctx.String(CacheJournalFlag.Name))
+	}
+	// CHANGE(taiko): execute basic blocks in the EVM.
+	if ctx.IsSet(VMBlockExecutionFlag.Name) {
+		cfg.VMBlockExecution = ctx.Bool(VMBlockExecutionFlag.Name)
+	}
+	// CHANGE(taiko): execute the transactions of a block in parallel.
+	if ctx.IsSet(VMParallelFlag.Name) {
+		cfg.VMParallel = ctx.Bool(VMParallelFlag.Name)
+	}
+	// CHANGE(taiko): index the flat call traces of the canonical chain.
+	if ctx.IsSet(TraceIndexFlag.Name) {
+		cfg.TraceIndex = ctx.Bool(TraceIndexFlag.Name)
+	}
+	// CHANGE(taiko): index the logs of the canonical chain.
+	if ctx.IsSet(LogIndexFlag.Name) {
+		cfg.LogIndex = ctx.Bool(LogIndexFlag.Name)
+	}
+	// CHANGE(taiko): verify the canonical chain in the background.
+	if ctx.IsSet(DBVerifyFlag.Name) {
+		cfg.VerifyDatabase = ctx.Bool(DBVerifyFlag.Name)
+	}
 	if ctx.IsSet(StateSchemeFlag.Name) {
 		cfg.StateScheme = ctx.String(StateSchemeFlag.Name)
 	}
@@ -1940,6 +1988,21 @@ func RegisterEthService(stack *node.Node, cfg *ethconfig.Config) (*eth.EthAPIBac
 		Fatalf("Failed to register the Ethereum service: %v", err)
 	}
 	stack.RegisterAPIs(tracers.APIs(backend.APIBackend))
+
+	// CHANGE(taiko): register the Parity compatible trace namespace, backed by
+	// the trace index if enabled.
+	var indexer *parity.Indexer
+	if cfg.TraceIndex {
+		indexer = parity.NewIndexer(backend.APIBackend)
+		stack.RegisterLifecycle(indexer)
+	}
+	stack.RegisterAPIs(parity.APIs(backend.APIBackend, indexer))
+
+	// CHANGE(taiko): serve the records of the live trace sink, if it is the
+	// configured live tracer.
+	if cfg.VMTrace == "sink" {
+		stack.RegisterAPIs(live.APIs())
+	}
 	return backend.APIBackend, backend
 }
 
@@ -2171,6 +2234,8 @@ func MakeChain(ctx *cli.Context, stack *node.Node, readonly bool) (*core.BlockCh
 		Preimages:           ctx.Bool(CachePreimagesFlag.Name),
 		StateScheme:         scheme,
 		StateHistory:        ctx.Uint64(StateHistoryFlag.Name),
+		StateHistoryIndex:   ctx.Bool(StateHistoryIndexFlag.Name), // CHANGE(taiko): index the state histories.
+		ParallelExecution:   ctx.Bool(VMParallelFlag.Name),        // CHANGE(taiko): execute transactions in parallel.
 	}
 	if cache.TrieDirtyDisabled && !cache.Preimages {
 		cache.Preimages = true
@@ -2192,6 +2257,7 @@ func MakeChain(ctx *cli.Context, stack *node.Node, readonly bool) (*core.BlockCh
 	}
 	vmcfg := vm.Config{
 		EnablePreimageRecording: ctx.Bool(VMEnableDebugFlag.Name),
+		EnableBlockExecution:    ctx.Bool(VMBlockExecutionFlag.Name), // CHANGE(taiko): execute basic blocks.
 	}
 	if ctx.IsSet(VMTraceFlag.Name) {
 		if name := ctx.String(VMTraceFlag.Name); name != "" {
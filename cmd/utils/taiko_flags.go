@@ -2,9 +2,15 @@ package utils
 
 import (
 	"os"
+	"strings"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/txpool"
+	"github.com/ethereum/go-ethereum/core/txpool/legacypool"
 	"github.com/ethereum/go-ethereum/eth"
 	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/eth/protocols/preconf"
+	"github.com/ethereum/go-ethereum/internal/flags"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -16,8 +22,195 @@ var (
 		Name:  "taiko",
 		Usage: "Taiko network",
 	}
+	PreconfGossipFlag = cli.BoolFlag{
+		Name:     "preconf.gossip",
+		Usage:    "Enable gossiping preconfirmation blocks over the `preconf` p2p protocol",
+		Category: flags.NetworkingCategory,
+	}
+	PreconfWhitelistFlag = cli.StringFlag{
+		Name:     "preconf.whitelist",
+		Usage:    "Comma separated list of sequencer addresses allowed to sign gossiped preconfirmation blocks",
+		Category: flags.NetworkingCategory,
+	}
+	PreconfRateLimitFlag = cli.Float64Flag{
+		Name:     "preconf.ratelimit",
+		Usage:    "Maximum number of preconfirmation blocks accepted per second from a single peer",
+		Value:    ethconfig.Defaults.Preconf.RateLimit,
+		Category: flags.NetworkingCategory,
+	}
+	PreconfRateBurstFlag = cli.IntFlag{
+		Name:     "preconf.rateburst",
+		Usage:    "Maximum burst of preconfirmation blocks accepted from a single peer",
+		Value:    ethconfig.Defaults.Preconf.RateBurst,
+		Category: flags.NetworkingCategory,
+	}
+	StateHistoryIndexFlag = cli.BoolFlag{
+		Name:     "history.state.index",
+		Usage:    "Index the state histories to serve historical state within the retained state history window (path scheme only)",
+		Category: flags.StateCategory,
+	}
+	ReplicaFlag = flags.DirectoryFlag{
+		Name:     "replica",
+		Usage:    "Serve the read-only RPC by following the pebble chain database of a primary node at the given path (the primary must use the hash state scheme)",
+		Category: flags.StateCategory,
+	}
+	CacheJournalFlag = flags.DirectoryFlag{
+		Name:     "cache.journal",
+		Usage:    "Directory to persist the most accessed entries of the clean trie and snapshot caches on shutdown and preload them on startup (relative to the datadir)",
+		Category: flags.PerfCategory,
+	}
+	VMBlockExecutionFlag = cli.BoolFlag{
+		Name:     "vm.blockexec",
+		Usage:    "Execute runs of instructions with constant gas costs as basic blocks (disabled when tracing every instruction)",
+		Category: flags.VMCategory,
+	}
+	VMParallelFlag = cli.BoolFlag{
+		Name:     "vm.parallel",
+		Usage:    "Execute the transactions of a block speculatively in parallel, re-executing conflicting ones sequentially (disabled when tracing)",
+		Category: flags.VMCategory,
+	}
+	TraceIndexFlag = cli.BoolFlag{
+		Name:     "trace.index",
+		Usage:    "Index the flat call traces of the canonical chain in the background to serve trace_filter by address",
+		Category: flags.APICategory,
+	}
+	LogIndexFlag = cli.BoolFlag{
+		Name:     "logindex",
+		Usage:    "Index the logs of the canonical chain by address and topic in the background to serve eth_getLogs over large ranges",
+		Category: flags.APICategory,
+	}
+	DBVerifyFlag = cli.BoolFlag{
+		Name:     "db.verify",
+		Usage:    "Verify the consistency of the canonical chain in the background on startup (repair offline with 'geth db verify --repair')",
+		Category: flags.EthCategory,
+	}
+	TxPoolOrderingFlag = cli.StringFlag{
+		Name:     "txpool.ordering",
+		Usage:    "Ordering of pending transactions for block building (price, fcfs, feeperbyte)",
+		Value:    txpool.OrderingPrice,
+		Category: flags.TxPoolCategory,
+	}
+	TxPoolRateLimitFlag = cli.Float64Flag{
+		Name:     "txpool.ratelimit",
+		Usage:    "Maximum number of transactions accepted per second from a single remote sender (0 = unlimited)",
+		Category: flags.TxPoolCategory,
+	}
+	TxPoolRateBurstFlag = cli.IntFlag{
+		Name:     "txpool.rateburst",
+		Usage:    "Maximum burst of transactions accepted from a single remote sender",
+		Value:    16,
+		Category: flags.TxPoolCategory,
+	}
+	TxPoolAllowFlag = cli.StringFlag{
+		Name:     "txpool.allow",
+		Usage:    "Comma separated list of recipients transactions are restricted to",
+		Category: flags.TxPoolCategory,
+	}
+	TxPoolDenyFlag = cli.StringFlag{
+		Name:     "txpool.deny",
+		Usage:    "Comma separated list of recipients whose transactions are rejected",
+		Category: flags.TxPoolCategory,
+	}
+	TxPoolSnapshotFlag = cli.StringFlag{
+		Name:     "txpool.snapshot",
+		Usage:    "Disk snapshot of all pooled transactions to restore on startup, written periodically and on shutdown (relative to the datadir)",
+		Category: flags.TxPoolCategory,
+	}
+	TxPoolResnapshotFlag = cli.DurationFlag{
+		Name:     "txpool.resnapshot",
+		Usage:    "Time interval to regenerate the transaction pool snapshot",
+		Value:    ethconfig.Defaults.TxPool.Resnapshot,
+		Category: flags.TxPoolCategory,
+	}
+
+	// TaikoFlags is the list of all the Taiko specific flags.
+	TaikoFlags = []cli.Flag{
+		&TaikoFlag,
+		&PreconfGossipFlag,
+		&PreconfWhitelistFlag,
+		&PreconfRateLimitFlag,
+		&PreconfRateBurstFlag,
+		&StateHistoryIndexFlag,
+		&ReplicaFlag,
+		&CacheJournalFlag,
+		&VMBlockExecutionFlag,
+		&VMParallelFlag,
+		&TraceIndexFlag,
+		&LogIndexFlag,
+		&DBVerifyFlag,
+		&TxPoolOrderingFlag,
+		&TxPoolRateLimitFlag,
+		&TxPoolRateBurstFlag,
+		&TxPoolAllowFlag,
+		&TxPoolDenyFlag,
+		&TxPoolSnapshotFlag,
+		&TxPoolResnapshotFlag,
+	}
 )
 
+// setPreconf configures the preconfirmation block gossip from the command line flags.
+func setPreconf(ctx *cli.Context, cfg *preconf.Config) {
+	if ctx.IsSet(PreconfGossipFlag.Name) {
+		cfg.Enabled = ctx.Bool(PreconfGossipFlag.Name)
+	}
+	if ctx.IsSet(PreconfWhitelistFlag.Name) {
+		cfg.Whitelist = cfg.Whitelist[:0]
+		for _, account := range strings.Split(ctx.String(PreconfWhitelistFlag.Name), ",") {
+			if trimmed := strings.TrimSpace(account); !common.IsHexAddress(trimmed) {
+				Fatalf("Invalid preconfirmation sequencer address in --%s: %s", PreconfWhitelistFlag.Name, trimmed)
+			} else {
+				cfg.Whitelist = append(cfg.Whitelist, common.HexToAddress(trimmed))
+			}
+		}
+	}
+	if ctx.IsSet(PreconfRateLimitFlag.Name) {
+		cfg.RateLimit = ctx.Float64(PreconfRateLimitFlag.Name)
+	}
+	if ctx.IsSet(PreconfRateBurstFlag.Name) {
+		cfg.RateBurst = ctx.Int(PreconfRateBurstFlag.Name)
+	}
+}
+
+// setTxPolicy configures the transaction pool policy from the command line flags.
+func setTxPolicy(ctx *cli.Context, cfg *txpool.PolicyConfig) {
+	if ctx.IsSet(TxPoolOrderingFlag.Name) {
+		cfg.Ordering = ctx.String(TxPoolOrderingFlag.Name)
+	}
+	if ctx.IsSet(TxPoolRateLimitFlag.Name) {
+		cfg.RateLimit = ctx.Float64(TxPoolRateLimitFlag.Name)
+		cfg.RateBurst = ctx.Int(TxPoolRateBurstFlag.Name)
+	}
+	if ctx.IsSet(TxPoolAllowFlag.Name) {
+		cfg.Allow = parseTxPolicyAddresses(TxPoolAllowFlag.Name, ctx.String(TxPoolAllowFlag.Name))
+	}
+	if ctx.IsSet(TxPoolDenyFlag.Name) {
+		cfg.Deny = parseTxPolicyAddresses(TxPoolDenyFlag.Name, ctx.String(TxPoolDenyFlag.Name))
+	}
+}
+
+// setTxPoolSnapshot configures the transaction pool snapshot from the command line flags.
+func setTxPoolSnapshot(ctx *cli.Context, cfg *legacypool.Config) {
+	if ctx.IsSet(TxPoolSnapshotFlag.Name) {
+		cfg.Snapshot = ctx.String(TxPoolSnapshotFlag.Name)
+	}
+	if ctx.IsSet(TxPoolResnapshotFlag.Name) {
+		cfg.Resnapshot = ctx.Duration(TxPoolResnapshotFlag.Name)
+	}
+}
+
+// parseTxPolicyAddresses parses a comma separated list of addresses of a flag.
+func parseTxPolicyAddresses(flag string, list string) []common.Address {
+	var addrs []common.Address
+	for _, account := range strings.Split(list, ",") {
+		if trimmed := strings.TrimSpace(account); !common.IsHexAddress(trimmed) {
+			Fatalf("Invalid address in --%s: %s", flag, trimmed)
+		} else {
+			addrs = append(addrs, common.HexToAddress(trimmed))
+		}
+	}
+	return addrs
+}
+
 // RegisterTaikoAPIs initializes and registers the Taiko RPC APIs.
 func RegisterTaikoAPIs(stack *node.Node, cfg *ethconfig.Config, backend *eth.Ethereum) {
 	if os.Getenv("TAIKO_TEST") != "" {
@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"io"
 	"math/big"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
@@ -103,6 +104,10 @@ const (
 	receiptsCacheLimit = 32
 	txLookupCacheLimit = 1024
 
+	// CHANGE(taiko): the state diffs of the recent blocks are retained for
+	// announcing the reverts on reorg.
+	stateDiffCacheLimit = 128
+
 	// BlockChainVersion ensures that an incompatible database forces a resync from scratch.
 	//
 	// Changelog:
@@ -142,6 +147,15 @@ type CacheConfig struct {
 	StateHistory        uint64        // Number of blocks from head whose state histories are reserved.
 	StateScheme         string        // Scheme used to store ethereum states and merkle tree nodes on top
 
+	// CHANGE(taiko): serve the historical state from the indexed state histories.
+	StateHistoryIndex bool // Whether to index the state histories in path scheme
+
+	// CHANGE(taiko): persist the clean trie and snapshot caches across restarts.
+	CacheJournal string // Directory to persist the clean caches, disabled if empty
+
+	// CHANGE(taiko): execute the transactions of a block in parallel.
+	ParallelExecution bool // Whether to execute transactions speculatively in parallel
+
 	SnapshotNoBuild bool // Whether the background generation is allowed
 	SnapshotWait    bool // Wait for snapshot construction on startup. TODO(karalabe): This is a dirty hack for testing, nuke it
 }
@@ -156,12 +170,19 @@ func (c *CacheConfig) triedbConfig(isVerkle bool) *triedb.Config {
 		config.HashDB = &hashdb.Config{
 			CleanCacheSize: c.TrieCleanLimit * 1024 * 1024,
 		}
+		if c.CacheJournal != "" {
+			config.HashDB.CleanCacheJournal = filepath.Join(c.CacheJournal, "triecache") // CHANGE(taiko)
+		}
 	}
 	if c.StateScheme == rawdb.PathScheme {
 		config.PathDB = &pathdb.Config{
-			StateHistory:   c.StateHistory,
-			CleanCacheSize: c.TrieCleanLimit * 1024 * 1024,
-			DirtyCacheSize: c.TrieDirtyLimit * 1024 * 1024,
+			StateHistory:        c.StateHistory,
+			EnableStateIndexing: c.StateHistoryIndex, // CHANGE(taiko): index the state histories.
+			CleanCacheSize:      c.TrieCleanLimit * 1024 * 1024,
+			DirtyCacheSize:      c.TrieDirtyLimit * 1024 * 1024,
+		}
+		if c.CacheJournal != "" {
+			config.PathDB.CleanCacheJournal = filepath.Join(c.CacheJournal, "triecache") // CHANGE(taiko)
 		}
 	}
 	return config
@@ -228,6 +249,8 @@ type BlockChain struct {
 	chainHeadFeed event.Feed
 	logsFeed      event.Feed
 	blockProcFeed event.Feed
+	stateDiffFeed event.Feed   // CHANGE(taiko): state diffs of the canonical blocks
+	stateDiffSubs atomic.Int32 // CHANGE(taiko): number of the state diff subscribers
 	scope         event.SubscriptionScope
 	genesisBlock  *types.Block
 
@@ -248,6 +271,8 @@ type BlockChain struct {
 	txLookupLock  sync.RWMutex
 	txLookupCache *lru.Cache[common.Hash, txLookup]
 
+	stateDiffCache *lru.Cache[common.Hash, *state.StateDiff] // CHANGE(taiko): state diffs of the recently imported blocks
+
 	wg            sync.WaitGroup
 	quit          chan struct{} // shutdown signal, closed in Stop.
 	stopping      atomic.Bool   // false if chain is running, true when stopped
@@ -259,6 +284,8 @@ type BlockChain struct {
 	processor  Processor // Block transaction processor interface
 	vmConfig   vm.Config
 	logger     *tracing.Hooks
+
+	replica bool // CHANGE(taiko): read-only chain following the database of a primary node
 }
 
 // NewBlockChain returns a fully initialised block chain using information
@@ -302,6 +329,8 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, genesis *Genesis
 		engine:        engine,
 		vmConfig:      vmConfig,
 		logger:        vmConfig.Tracer,
+
+		stateDiffCache: lru.NewCache[common.Hash, *state.StateDiff](stateDiffCacheLimit),
 	}
 	var err error
 	bc.hc, err = NewHeaderChain(db, chainConfig, engine, bc.insertStopped)
@@ -312,7 +341,10 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, genesis *Genesis
 	bc.statedb = state.NewDatabase(bc.triedb, nil)
 	bc.validator = NewBlockValidator(chainConfig, bc)
 	bc.prefetcher = newStatePrefetcher(chainConfig, bc.hc)
-	bc.processor = NewStateProcessor(chainConfig, bc.hc)
+	// CHANGE(taiko): execute the transactions in parallel if enabled.
+	processor := NewStateProcessor(chainConfig, bc.hc)
+	processor.parallel = cacheConfig.ParallelExecution
+	bc.processor = processor
 
 	bc.genesisBlock = bc.GetBlockByNumber(0)
 	if bc.genesisBlock == nil {
@@ -446,6 +478,9 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, genesis *Genesis
 			NoBuild:    bc.cacheConfig.SnapshotNoBuild,
 			AsyncBuild: !bc.cacheConfig.SnapshotWait,
 		}
+		if bc.cacheConfig.CacheJournal != "" {
+			snapconfig.CacheJournal = filepath.Join(bc.cacheConfig.CacheJournal, "snapcache") // CHANGE(taiko)
+		}
 		bc.snaps, _ = snapshot.New(snapconfig, bc.db, bc.triedb, head.Root)
 
 		// Re-initialize the state database with snapshot
@@ -566,6 +601,10 @@ func (bc *BlockChain) loadLastState() error {
 // was snap synced or full synced and in which state, the method will try to
 // delete minimal data from disk whilst retaining chain consistency.
 func (bc *BlockChain) SetHead(head uint64) error {
+	// CHANGE(taiko): the replica chain only follows the primary.
+	if bc.replica {
+		return errReplicaChain
+	}
 	if _, err := bc.setHeadBeyondRoot(head, 0, common.Hash{}, false); err != nil {
 		return err
 	}
@@ -588,6 +627,10 @@ func (bc *BlockChain) SetHead(head uint64) error {
 // synced and in which state, the method will try to delete minimal data from
 // disk whilst retaining chain consistency.
 func (bc *BlockChain) SetHeadWithTimestamp(timestamp uint64) error {
+	// CHANGE(taiko): the replica chain only follows the primary.
+	if bc.replica {
+		return errReplicaChain
+	}
 	if _, err := bc.setHeadBeyondRoot(0, timestamp, common.Hash{}, false); err != nil {
 		return err
 	}
@@ -607,6 +650,10 @@ func (bc *BlockChain) SetHeadWithTimestamp(timestamp uint64) error {
 
 // SetFinalized sets the finalized block.
 func (bc *BlockChain) SetFinalized(header *types.Header) {
+	// CHANGE(taiko): the replica chain only follows the primary.
+	if bc.replica {
+		return
+	}
 	bc.currentFinalBlock.Store(header)
 	if header != nil {
 		rawdb.WriteFinalizedBlockHash(bc.db, header.Hash())
@@ -1190,6 +1237,10 @@ const (
 // InsertReceiptChain attempts to complete an already existing header chain with
 // transaction and receipt data.
 func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain []types.Receipts, ancientLimit uint64) (int, error) {
+	// CHANGE(taiko): the replica chain only follows the primary.
+	if bc.replica {
+		return 0, errReplicaChain
+	}
 	// We don't require the chainMu here since we want to maximize the
 	// concurrency of header insertion and receipt insertion.
 	bc.wg.Add(1)
@@ -1470,9 +1521,22 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 		log.Crit("Failed to write block into disk", "err", err)
 	}
 	// Commit all cached state changes into underlying memory database.
-	root, err := statedb.Commit(block.NumberU64(), bc.chainConfig.IsEIP158(block.Number()))
-	if err != nil {
-		return err
+	// CHANGE(taiko): retain the state diff for announcing it once the block
+	// becomes canonical, only assembled if anyone is subscribed.
+	var (
+		root common.Hash
+		err  error
+	)
+	if bc.stateDiffSubs.Load() > 0 {
+		var diff *state.StateDiff
+		if root, diff, err = statedb.CommitWithDiff(block.NumberU64(), bc.chainConfig.IsEIP158(block.Number())); err != nil {
+			return err
+		}
+		bc.stateDiffCache.Add(block.Hash(), diff)
+	} else {
+		if root, err = statedb.Commit(block.NumberU64(), bc.chainConfig.IsEIP158(block.Number())); err != nil {
+			return err
+		}
 	}
 	// If node is running in path mode, skip explicit gc operation
 	// which is unnecessary in this mode.
@@ -1556,6 +1620,8 @@ func (bc *BlockChain) writeBlockAndSetHead(block *types.Block, receipts []*types
 	if len(logs) > 0 {
 		bc.logsFeed.Send(logs)
 	}
+	bc.sendStateDiff(block, false) // CHANGE(taiko): announce the state diff.
+
 	// In theory, we should fire a ChainHeadEvent when we inject
 	// a canonical block, but sometimes we can insert a batch of
 	// canonical blocks. Avoid firing too many ChainHeadEvents,
@@ -1572,6 +1638,10 @@ func (bc *BlockChain) writeBlockAndSetHead(block *types.Block, receipts []*types
 // the index number of the failing block as well an error describing what went
 // wrong. After insertion is done, all accumulated events will be fired.
 func (bc *BlockChain) InsertChain(chain types.Blocks) (int, error) {
+	// CHANGE(taiko): the replica chain only follows the primary.
+	if bc.replica {
+		return 0, errReplicaChain
+	}
 	// Sanity check that we have something meaningful to import
 	if len(chain) == 0 {
 		return 0, nil
@@ -2181,6 +2251,55 @@ func (bc *BlockChain) collectLogs(b *types.Block, removed bool) []*types.Log {
 	return logs
 }
 
+// CHANGE(taiko): sendStateDiff announces the state diff of the given block if
+// it's retained, either applied or reverted.
+func (bc *BlockChain) sendStateDiff(block *types.Block, reverted bool) {
+	diff, ok := bc.stateDiffCache.Get(block.Hash())
+	if !ok {
+		log.Debug("State diff is not available", "number", block.Number(), "hash", block.Hash(), "reverted", reverted)
+		return
+	}
+	bc.stateDiffFeed.Send(StateDiffEvent{Block: block, Diff: diff, Reverted: reverted})
+}
+
+// CHANGE(taiko): sendReorgStateDiffs announces the reverts of the state diffs
+// of the old chain from its head backwards, followed by the new chain except its
+// head. If any of the diffs is not retained, e.g. the reorg is deeper than the
+// diff cache, the subscribers are asked to resync their state at the highest
+// block whose diffs can't be delivered, followed by the diffs above it.
+func (bc *BlockChain) sendReorgStateDiffs(ancestor *types.Block, oldChain, newChain types.Blocks) {
+	if bc.stateDiffSubs.Load() == 0 {
+		return
+	}
+	var (
+		resync *types.Block
+		apply  = len(newChain) // new chain blocks to apply, [1, apply)
+	)
+	for _, block := range oldChain {
+		if !bc.stateDiffCache.Contains(block.Hash()) {
+			resync = ancestor
+			break
+		}
+	}
+	for i := 1; i < len(newChain); i++ {
+		if !bc.stateDiffCache.Contains(newChain[i].Hash()) {
+			resync, apply = newChain[i], i
+			break
+		}
+	}
+	if resync == nil {
+		for _, block := range oldChain {
+			bc.sendStateDiff(block, true)
+		}
+	} else {
+		log.Warn("State diffs unavailable for reorg, requesting resync", "number", resync.Number(), "hash", resync.Hash(), "drop", len(oldChain), "add", len(newChain))
+		bc.stateDiffFeed.Send(StateDiffEvent{Block: resync, Resync: true})
+	}
+	for i := apply - 1; i >= 1; i-- {
+		bc.sendStateDiff(newChain[i], false)
+	}
+}
+
 // reorg takes two blocks, an old chain and a new chain and will reconstruct the
 // blocks and inserts them to be part of the new canonical chain and accumulates
 // potential missing transactions and post an event about them.
@@ -2343,6 +2462,9 @@ func (bc *BlockChain) reorg(oldHead *types.Header, newHead *types.Block) error {
 	if len(deletedLogs) > 0 {
 		bc.rmLogsFeed.Send(RemovedLogsEvent{deletedLogs})
 	}
+	// CHANGE(taiko): revert the state diffs of the old canon chain, from the
+	// old head backwards, and apply the new ones except the new head.
+	bc.sendReorgStateDiffs(commonBlock, oldChain, newChain)
 
 	// New logs:
 	var rebirthLogs []*types.Log
@@ -2367,6 +2489,10 @@ func (bc *BlockChain) reorg(oldHead *types.Header, newHead *types.Block) error {
 // updating. It relies on the additional SetCanonical call to finalize the entire
 // procedure.
 func (bc *BlockChain) InsertBlockWithoutSetHead(block *types.Block, makeWitness bool) (*stateless.Witness, error) {
+	// CHANGE(taiko): the replica chain only follows the primary.
+	if bc.replica {
+		return nil, errReplicaChain
+	}
 	if !bc.chainmu.TryLock() {
 		return nil, errChainStopped
 	}
@@ -2380,6 +2506,10 @@ func (bc *BlockChain) InsertBlockWithoutSetHead(block *types.Block, makeWitness
 // block. It's possible that the state of the new head is missing, and it will
 // be recovered in this function as well.
 func (bc *BlockChain) SetCanonical(head *types.Block) (common.Hash, error) {
+	// CHANGE(taiko): the replica chain only follows the primary.
+	if bc.replica {
+		return common.Hash{}, errReplicaChain
+	}
 	if !bc.chainmu.TryLock() {
 		return common.Hash{}, errChainStopped
 	}
@@ -2407,6 +2537,7 @@ func (bc *BlockChain) SetCanonical(head *types.Block) (common.Hash, error) {
 	if len(logs) > 0 {
 		bc.logsFeed.Send(logs)
 	}
+	bc.sendStateDiff(head, false) // CHANGE(taiko): announce the state diff.
 	bc.chainHeadFeed.Send(ChainHeadEvent{Block: head})
 
 	context := []interface{}{
@@ -2502,6 +2633,10 @@ Receipts: %v
 // chain, possibly creating a reorg. If an error is returned, it will return the
 // index number of the failing header as well an error describing what went wrong.
 func (bc *BlockChain) InsertHeaderChain(chain []*types.Header) (int, error) {
+	// CHANGE(taiko): the replica chain only follows the primary.
+	if bc.replica {
+		return 0, errReplicaChain
+	}
 	if len(chain) == 0 {
 		return 0, nil
 	}
@@ -19,6 +19,7 @@ package core
 import (
 	"errors"
 	"math/big"
+	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus"
@@ -356,6 +357,13 @@ func (bc *BlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
 	return state.New(root, bc.statedb)
 }
 
+// CHANGE(taiko): HistoricState returns a read-only state database for a historical
+// point, reconstructed from the indexed state histories of the path-based scheme.
+// The returned state can be executed upon, but not be hashed nor committed.
+func (bc *BlockChain) HistoricState(root common.Hash) (*state.StateDB, error) {
+	return state.New(root, state.NewHistoricDatabase(bc.statedb))
+}
+
 // Config retrieves the chain's fork configuration.
 func (bc *BlockChain) Config() *params.ChainConfig { return bc.chainConfig }
 
@@ -440,6 +448,31 @@ func (bc *BlockChain) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscript
 	return bc.scope.Track(bc.logsFeed.Subscribe(ch))
 }
 
+// CHANGE(taiko): SubscribeStateDiffEvent registers a subscription of StateDiffEvent.
+// The state diffs are only assembled while there are subscribers.
+func (bc *BlockChain) SubscribeStateDiffEvent(ch chan<- StateDiffEvent) event.Subscription {
+	bc.stateDiffSubs.Add(1)
+	sub := &stateDiffSubscription{
+		Subscription: bc.stateDiffFeed.Subscribe(ch),
+		release:      func() { bc.stateDiffSubs.Add(-1) },
+	}
+	return bc.scope.Track(sub)
+}
+
+// CHANGE(taiko): stateDiffSubscription is a state diff subscription releasing
+// its slot in the subscriber count once unsubscribed.
+type stateDiffSubscription struct {
+	event.Subscription
+	release func()
+	once    sync.Once
+}
+
+// Unsubscribe implements event.Subscription.
+func (s *stateDiffSubscription) Unsubscribe() {
+	s.once.Do(s.release)
+	s.Subscription.Unsubscribe()
+}
+
 // SubscribeBlockProcessingEvent registers a subscription of bool where true means
 // block processing has started while false means it has stopped.
 func (bc *BlockChain) SubscribeBlockProcessingEvent(ch chan<- bool) event.Subscription {
@@ -0,0 +1,262 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/common/prque"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/internal/syncx"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/triedb"
+)
+
+const (
+	// replicaFollowInterval is the time interval between the checks of the head
+	// markers written by the primary.
+	replicaFollowInterval = 500 * time.Millisecond
+
+	// replicaEventLimit is the maximum number of blocks announced one by one when
+	// the head moves. The larger gaps, e.g. the primary is syncing, are announced
+	// with the head event only.
+	replicaEventLimit = 1024
+)
+
+// errReplicaChain is returned if a write operation is attempted on the replica
+// chain.
+var errReplicaChain = errors.New("replica chain is read-only")
+
+// NewReplicaBlockChain returns a read-only blockchain on top of the database of
+// another node, the primary. The chain neither imports nor modifies anything, it
+// follows the head blocks written by the primary and announces them to the
+// subscribers.
+//
+// Only the hash scheme is supported, the states are served from the trie nodes
+// persisted by the primary. Running the primary in archive mode is recommended
+// to have the states of the recent blocks available. The path scheme can't be
+// followed, the primary keeps the states of the recent blocks as diff layers in
+// its memory, only flushing them to the disk layer in aggregated form.
+func NewReplicaBlockChain(db ethdb.Database, cacheConfig *CacheConfig, engine consensus.Engine, vmConfig vm.Config) (*BlockChain, error) {
+	if cacheConfig == nil {
+		cacheConfig = defaultCacheConfig
+	}
+	if cacheConfig.StateScheme == rawdb.PathScheme {
+		return nil, errors.New("replica chain doesn't support the path state scheme")
+	}
+	// Disable everything maintaining the data in the database.
+	config := *cacheConfig
+	config.StateScheme = rawdb.HashScheme
+	config.TrieDirtyDisabled = true
+	config.SnapshotLimit = 0
+	config.Preimages = false
+
+	chainConfig := rawdb.ReadChainConfig(db, rawdb.ReadCanonicalHash(db, 0))
+	if chainConfig == nil {
+		return nil, errors.New("chain config is not found in the primary database")
+	}
+	if rawdb.ReadHeadBlockHash(db) == (common.Hash{}) {
+		return nil, errors.New("head block is not found in the primary database")
+	}
+	triedb := triedb.NewDatabase(db, config.triedbConfig(false))
+
+	bc := &BlockChain{
+		chainConfig:   chainConfig,
+		cacheConfig:   &config,
+		db:            db,
+		triedb:        triedb,
+		triegc:        prque.New[int64, common.Hash](nil),
+		quit:          make(chan struct{}),
+		chainmu:       syncx.NewClosableMutex(),
+		bodyCache:     lru.NewCache[common.Hash, *types.Body](bodyCacheLimit),
+		bodyRLPCache:  lru.NewCache[common.Hash, rlp.RawValue](bodyCacheLimit),
+		receiptsCache: lru.NewCache[common.Hash, []*types.Receipt](receiptsCacheLimit),
+		blockCache:    lru.NewCache[common.Hash, *types.Block](blockCacheLimit),
+		txLookupCache: lru.NewCache[common.Hash, txLookup](txLookupCacheLimit),
+		engine:        engine,
+		vmConfig:      vmConfig,
+		logger:        vmConfig.Tracer,
+		replica:       true,
+	}
+	var err error
+	bc.hc, err = NewHeaderChain(db, chainConfig, engine, bc.insertStopped)
+	if err != nil {
+		return nil, err
+	}
+	bc.statedb = state.NewDatabase(bc.triedb, nil)
+	bc.validator = NewBlockValidator(chainConfig, bc)
+	bc.prefetcher = newStatePrefetcher(chainConfig, bc.hc)
+	bc.processor = NewStateProcessor(chainConfig, bc.hc)
+
+	bc.genesisBlock = bc.GetBlockByNumber(0)
+	if bc.genesisBlock == nil {
+		return nil, ErrNoGenesis
+	}
+	bc.currentBlock.Store(nil)
+	bc.currentSnapBlock.Store(nil)
+	bc.currentFinalBlock.Store(nil)
+	bc.currentSafeBlock.Store(nil)
+
+	// The head block is checked to avoid resetting the chain while loading.
+	if bc.GetBlockByHash(rawdb.ReadHeadBlockHash(db)) == nil {
+		return nil, errors.New("head block is missing in the primary database")
+	}
+	if err := bc.loadLastState(); err != nil {
+		return nil, err
+	}
+	chainInfoGauge.Update(metrics.GaugeInfoValue{"chain_id": bc.chainConfig.ChainID.String()})
+
+	bc.wg.Add(1)
+	go bc.followPrimary()
+	return bc, nil
+}
+
+// followPrimary tracks the head markers written by the primary until the chain
+// is stopped.
+func (bc *BlockChain) followPrimary() {
+	defer bc.wg.Done()
+
+	ticker := time.NewTicker(replicaFollowInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			bc.updateReplicaHeads()
+		case <-bc.quit:
+			return
+		}
+	}
+}
+
+// updateReplicaHeads loads the head markers written by the primary. The markers
+// pointing to the data not visible yet are retried in the next round.
+func (bc *BlockChain) updateReplicaHeads() {
+	if hash := rawdb.ReadHeadHeaderHash(bc.db); hash != bc.hc.CurrentHeader().Hash() {
+		if header := bc.GetHeaderByHash(hash); header != nil {
+			bc.hc.SetCurrentHeader(header)
+		}
+	}
+	if hash := rawdb.ReadHeadFastBlockHash(bc.db); hash != bc.CurrentSnapBlock().Hash() {
+		if header := bc.GetHeaderByHash(hash); header != nil {
+			bc.currentSnapBlock.Store(header)
+			headFastBlockGauge.Update(header.Number.Int64())
+		}
+	}
+	if hash := rawdb.ReadFinalizedBlockHash(bc.db); hash != (common.Hash{}) {
+		if final := bc.CurrentFinalBlock(); final == nil || final.Hash() != hash {
+			if header := bc.GetHeaderByHash(hash); header != nil {
+				bc.currentFinalBlock.Store(header)
+				headFinalizedBlockGauge.Update(header.Number.Int64())
+				bc.currentSafeBlock.Store(header)
+				headSafeBlockGauge.Update(header.Number.Int64())
+			}
+		}
+	}
+	current := bc.CurrentBlock()
+	hash := rawdb.ReadHeadBlockHash(bc.db)
+	if hash == current.Hash() {
+		return
+	}
+	head := bc.GetBlockByHash(hash)
+	if head == nil {
+		return
+	}
+	bc.setReplicaHead(current, head)
+}
+
+// setReplicaHead moves the head block of the replica chain, announcing the blocks
+// between the old and new heads along with the reorged ones.
+func (bc *BlockChain) setReplicaHead(oldHead *types.Header, head *types.Block) {
+	oldChain, newChain, ok := bc.replicaReorgChains(oldHead, head)
+
+	bc.currentBlock.Store(head.Header())
+	headBlockGauge.Update(int64(head.NumberU64()))
+
+	if len(oldChain) > 0 {
+		// The transaction lookups of the reorged blocks are stale.
+		bc.txLookupLock.Lock()
+		bc.txLookupCache.Purge()
+		bc.txLookupLock.Unlock()
+	}
+	if ok {
+		for i := len(oldChain) - 1; i >= 0; i-- {
+			bc.chainSideFeed.Send(ChainSideEvent{Block: oldChain[i]})
+			if logs := bc.collectLogs(oldChain[i], true); len(logs) > 0 {
+				bc.rmLogsFeed.Send(RemovedLogsEvent{logs})
+			}
+		}
+		for i := len(newChain) - 1; i >= 0; i-- {
+			block := newChain[i]
+			logs := bc.collectLogs(block, false)
+			bc.chainFeed.Send(ChainEvent{Block: block, Hash: block.Hash(), Logs: logs})
+			if len(logs) > 0 {
+				bc.logsFeed.Send(logs)
+			}
+		}
+	}
+	bc.chainHeadFeed.Send(ChainHeadEvent{Block: head})
+
+	if len(oldChain) > 0 {
+		log.Info("Replica chain reorged", "number", head.Number(), "hash", head.Hash(), "drop", len(oldChain), "add", len(newChain))
+	} else {
+		log.Debug("Replica chain advanced", "number", head.Number(), "hash", head.Hash(), "blocks", len(newChain))
+	}
+}
+
+// replicaReorgChains collects the blocks of the old chain and new chain since the
+// common ancestor, in the descending order. False is returned if the chains are
+// too long to be announced or the blocks are missing.
+func (bc *BlockChain) replicaReorgChains(oldHead *types.Header, newHead *types.Block) (types.Blocks, types.Blocks, bool) {
+	var (
+		oldChain types.Blocks
+		newChain types.Blocks
+		oldBlock = bc.GetBlock(oldHead.Hash(), oldHead.Number.Uint64())
+		newBlock = newHead
+	)
+	if oldBlock == nil {
+		return nil, nil, false
+	}
+	next := func(chain types.Blocks, block *types.Block) (types.Blocks, *types.Block) {
+		return append(chain, block), bc.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	}
+	for oldBlock != nil && newBlock != nil && oldBlock.Hash() != newBlock.Hash() {
+		if len(oldChain)+len(newChain) > replicaEventLimit {
+			return oldChain, newChain, false
+		}
+		switch {
+		case oldBlock.NumberU64() > newBlock.NumberU64():
+			oldChain, oldBlock = next(oldChain, oldBlock)
+		case newBlock.NumberU64() > oldBlock.NumberU64():
+			newChain, newBlock = next(newChain, newBlock)
+		default:
+			oldChain, oldBlock = next(oldChain, oldBlock)
+			newChain, newBlock = next(newChain, newBlock)
+		}
+	}
+	return oldChain, newChain, oldBlock != nil && newBlock != nil
+}
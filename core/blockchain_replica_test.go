@@ -0,0 +1,127 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestReplicaBlockChain(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{
+			Config:  params.TestChainConfig,
+			Alloc:   types.GenesisAlloc{address: {Balance: big.NewInt(params.Ether)}},
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		signer = types.LatestSigner(gspec.Config)
+		engine = ethash.NewFaker()
+	)
+	genDb, blocks, _ := GenerateChainWithGenesis(gspec, engine, 10, func(i int, gen *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(address), common.Address{0xde, 0xad}, big.NewInt(1), params.TxGas, gen.header.BaseFee, nil), signer, key)
+		gen.AddTx(tx)
+	})
+	// The fork diverges after the block 3 and overtakes the canonical chain.
+	forks, _ := GenerateChain(gspec.Config, blocks[2], engine, genDb, 8, func(i int, gen *BlockGen) {
+		gen.SetCoinbase(common.Address{0x01})
+	})
+	db := rawdb.NewMemoryDatabase()
+	primary, err := NewBlockChain(db, DefaultCacheConfigWithScheme(rawdb.HashScheme), gspec, nil, engine, vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create primary: %v", err)
+	}
+	defer primary.Stop()
+	if _, err := primary.InsertChain(blocks[:5]); err != nil {
+		t.Fatalf("Failed to insert chain: %v", err)
+	}
+	replica, err := NewReplicaBlockChain(db, DefaultCacheConfigWithScheme(rawdb.HashScheme), engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create replica: %v", err)
+	}
+	defer replica.Stop()
+
+	if head := replica.CurrentBlock(); head.Hash() != blocks[4].Hash() {
+		t.Fatalf("Unexpected replica head %d", head.Number)
+	}
+	if _, err := replica.InsertChain(blocks[5:]); !errors.Is(err, errReplicaChain) {
+		t.Fatalf("Unexpected insertion error: %v", err)
+	}
+	var (
+		chainCh = make(chan ChainEvent, 32)
+		sideCh  = make(chan ChainSideEvent, 32)
+		headCh  = make(chan ChainHeadEvent, 32)
+	)
+	defer replica.SubscribeChainEvent(chainCh).Unsubscribe()
+	defer replica.SubscribeChainSideEvent(sideCh).Unsubscribe()
+	defer replica.SubscribeChainHeadEvent(headCh).Unsubscribe()
+
+	waitHead := func(want *types.Block) {
+		t.Helper()
+		for {
+			select {
+			case ev := <-headCh:
+				if ev.Block.Hash() == want.Hash() {
+					return
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Replica head is not updated to %d", want.NumberU64())
+			}
+		}
+	}
+	// The blocks imported by the primary are announced one by one.
+	if _, err := primary.InsertChain(blocks[5:]); err != nil {
+		t.Fatalf("Failed to insert chain: %v", err)
+	}
+	waitHead(blocks[9])
+	if n := len(chainCh); n != 5 {
+		t.Fatalf("Unexpected chain events, want %d, got %d", 5, n)
+	}
+	for i := 5; i < 10; i++ {
+		if ev := <-chainCh; ev.Block.Hash() != blocks[i].Hash() || len(ev.Logs) != 0 {
+			t.Fatalf("Unexpected chain event %d", ev.Block.NumberU64())
+		}
+	}
+	if _, tx, err := replica.GetTransactionLookup(blocks[9].Transactions()[0].Hash()); tx == nil || err != nil {
+		t.Fatal("Transaction of the new block is not found")
+	}
+	// The reorg done by the primary announces the dropped blocks as side blocks.
+	if _, err := primary.InsertChain(forks); err != nil {
+		t.Fatalf("Failed to insert fork: %v", err)
+	}
+	waitHead(forks[len(forks)-1])
+	if n := len(sideCh); n != 7 {
+		t.Fatalf("Unexpected side events, want %d, got %d", 7, n)
+	}
+	if n := len(chainCh); n != len(forks) {
+		t.Fatalf("Unexpected chain events, want %d, got %d", len(forks), n)
+	}
+	if block := replica.GetBlockByNumber(5); block.Hash() != forks[1].Hash() {
+		t.Fatalf("Unexpected canonical block %x", block.Hash())
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestStateDiffEvents(t *testing.T) {
+	var (
+		key, _    = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address   = crypto.PubkeyToAddress(key.PublicKey)
+		recipient = common.Address{0xde, 0xad}
+		gspec     = &Genesis{
+			Config:  params.TestChainConfig,
+			Alloc:   types.GenesisAlloc{address: {Balance: big.NewInt(params.Ether)}},
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		signer = types.LatestSigner(gspec.Config)
+		engine = ethash.NewFaker()
+	)
+	genDb, blocks, _ := GenerateChainWithGenesis(gspec, engine, 4, func(i int, gen *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(address), recipient, big.NewInt(1), params.TxGas, gen.header.BaseFee, nil), signer, key)
+		gen.AddTx(tx)
+	})
+	// The fork diverges after the block 2 and overtakes the canonical chain.
+	forks, _ := GenerateChain(gspec.Config, blocks[1], engine, genDb, 4, func(i int, gen *BlockGen) {
+		gen.SetCoinbase(common.Address{0x01})
+	})
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), DefaultCacheConfigWithScheme(rawdb.HashScheme), gspec, nil, engine, vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	diffCh := make(chan StateDiffEvent, 32)
+	defer chain.SubscribeStateDiffEvent(diffCh).Unsubscribe()
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("Failed to insert chain: %v", err)
+	}
+	if n := len(diffCh); n != len(blocks) {
+		t.Fatalf("Unexpected state diff events, want %d, got %d", len(blocks), n)
+	}
+	for i, block := range blocks {
+		ev := <-diffCh
+		if ev.Block.Hash() != block.Hash() || ev.Reverted || ev.Diff.Root != block.Root() {
+			t.Fatalf("Unexpected state diff event of block %d", ev.Block.NumberU64())
+		}
+		acc := ev.Diff.Accounts[recipient]
+		if acc == nil || acc.Account.Balance.Uint64() != uint64(i+1) {
+			t.Fatalf("Unexpected recipient diff in block %d", ev.Block.NumberU64())
+		}
+		if acc := ev.Diff.Accounts[address]; acc == nil || acc.Account.Nonce != uint64(i+1) {
+			t.Fatalf("Unexpected sender diff in block %d", ev.Block.NumberU64())
+		}
+	}
+	// The reorg reverts the dropped blocks from the old head backwards.
+	if _, err := chain.InsertChain(forks); err != nil {
+		t.Fatalf("Failed to insert fork: %v", err)
+	}
+	for i := len(blocks) - 1; i >= 2; i-- {
+		ev := <-diffCh
+		if ev.Block.Hash() != blocks[i].Hash() || !ev.Reverted {
+			t.Fatalf("Unexpected revert event of block %d", ev.Block.NumberU64())
+		}
+	}
+	for _, block := range forks {
+		ev := <-diffCh
+		if ev.Block.Hash() != block.Hash() || ev.Reverted {
+			t.Fatalf("Unexpected state diff event of block %d", ev.Block.NumberU64())
+		}
+		if _, ok := ev.Diff.Accounts[common.Address{0x01}]; !ok {
+			t.Fatalf("Missing coinbase diff in block %d", ev.Block.NumberU64())
+		}
+	}
+	if n := len(diffCh); n != 0 {
+		t.Fatalf("Unexpected extra state diff events: %d", n)
+	}
+}
+
+// Tests that the state diffs are only assembled while subscribed, and that a
+// reorg whose reverts are unavailable requests a resync at the common ancestor.
+func TestStateDiffResync(t *testing.T) {
+	var (
+		gspec = &Genesis{
+			Config:  params.TestChainConfig,
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		engine = ethash.NewFaker()
+	)
+	genDb, blocks, _ := GenerateChainWithGenesis(gspec, engine, 4, func(i int, gen *BlockGen) {})
+	forks, _ := GenerateChain(gspec.Config, blocks[1], engine, genDb, 4, func(i int, gen *BlockGen) {
+		gen.SetCoinbase(common.Address{0x01})
+	})
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), DefaultCacheConfigWithScheme(rawdb.HashScheme), gspec, nil, engine, vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("Failed to insert chain: %v", err)
+	}
+	if n := chain.stateDiffCache.Len(); n != 0 {
+		t.Fatalf("State diffs assembled without subscribers: %d", n)
+	}
+	diffCh := make(chan StateDiffEvent, 32)
+	sub := chain.SubscribeStateDiffEvent(diffCh)
+
+	if _, err := chain.InsertChain(forks); err != nil {
+		t.Fatalf("Failed to insert fork: %v", err)
+	}
+	if ev := <-diffCh; !ev.Resync || ev.Diff != nil || ev.Block.Hash() != blocks[1].Hash() {
+		t.Fatalf("Unexpected resync event at block %d (resync %v)", ev.Block.NumberU64(), ev.Resync)
+	}
+	for _, block := range forks {
+		if ev := <-diffCh; ev.Block.Hash() != block.Hash() || ev.Reverted || ev.Resync {
+			t.Fatalf("Unexpected state diff event of block %d", ev.Block.NumberU64())
+		}
+	}
+	if n := len(diffCh); n != 0 {
+		t.Fatalf("Unexpected extra state diff events: %d", n)
+	}
+	sub.Unsubscribe()
+	if n := chain.stateDiffSubs.Load(); n != 0 {
+		t.Fatalf("Subscriber not released: %d", n)
+	}
+}
@@ -17,6 +17,7 @@
 package core
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"math/big"
@@ -4228,6 +4229,110 @@ func TestEIP3651(t *testing.T) {
 	}
 }
 
+// TestEIP7702 deploys two delegation designations and calls them. It writes one
+// value to storage which is verified after.
+func TestEIP7702(t *testing.T) {
+	var (
+		engine  = beacon.NewFaker()
+		key1, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		key2, _ = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+		addr1   = crypto.PubkeyToAddress(key1.PublicKey)
+		addr2   = crypto.PubkeyToAddress(key2.PublicKey)
+		aa      = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+		bb      = common.HexToAddress("0x000000000000000000000000000000000000bbbb")
+		funds   = new(big.Int).Mul(common.Big1, big.NewInt(params.Ether))
+		config  = *params.MergedTestChainConfig
+	)
+	config.EIP7702Block = common.Big0
+
+	gspec := &Genesis{
+		Config: &config,
+		Alloc: types.GenesisAlloc{
+			addr1: {Balance: funds},
+			addr2: {Balance: funds},
+			// The address 0xAAAA calls into addr2
+			aa: {
+				Code: append(append([]byte{
+					byte(vm.PUSH1), 0, // out size
+					byte(vm.DUP1),     // out offset
+					byte(vm.DUP1),     // in size
+					byte(vm.DUP1),     // in offset
+					byte(vm.PUSH1), 1, // value
+					byte(vm.PUSH20),
+				}, addr2.Bytes()...), byte(vm.GAS), byte(vm.CALL)),
+				Nonce:   0,
+				Balance: big.NewInt(0),
+			},
+			// The address 0xBBBB sstores 42 into slot 42
+			bb: {
+				Code: []byte{
+					byte(vm.PUSH1), 0x42,
+					byte(vm.DUP1),
+					byte(vm.SSTORE),
+				},
+				Nonce:   0,
+				Balance: big.NewInt(0),
+			},
+		},
+	}
+
+	// Sign authorization tuples. The way the auths are combined, it becomes
+	//  1. tx -> addr1 which is delegated to 0xaaaa
+	//  2. addr1:0xaaaa calls into addr2:0xbbbb
+	//  3. addr2:0xbbbb writes to storage
+	auth1, _ := types.SignSetCode(key1, types.SetCodeAuthorization{
+		ChainID: *uint256.MustFromBig(gspec.Config.ChainID),
+		Address: aa,
+		Nonce:   1,
+	})
+	auth2, _ := types.SignSetCode(key2, types.SetCodeAuthorization{
+		Address: bb,
+		Nonce:   0,
+	})
+	signer := types.LatestSigner(gspec.Config)
+
+	_, blocks, _ := GenerateChainWithGenesis(gspec, engine, 1, func(i int, b *BlockGen) {
+		b.SetCoinbase(aa)
+		txdata := &types.SetCodeTx{
+			ChainID:   uint256.MustFromBig(gspec.Config.ChainID),
+			Nonce:     0,
+			To:        addr1,
+			Gas:       500000,
+			GasFeeCap: uint256.MustFromBig(newGwei(5)),
+			GasTipCap: uint256.NewInt(2),
+			AuthList:  []types.SetCodeAuthorization{auth1, auth2},
+		}
+		b.AddTx(types.MustSignNewTx(key1, signer, txdata))
+	})
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, gspec, nil, engine, vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+
+	// Verify delegation designations were deployed.
+	state, _ := chain.State()
+	code, want := state.GetCode(addr1), types.AddressToDelegation(auth1.Address)
+	if !bytes.Equal(code, want) {
+		t.Fatalf("addr1 code incorrect: got %x, want %x", code, want)
+	}
+	code, want = state.GetCode(addr2), types.AddressToDelegation(auth2.Address)
+	if !bytes.Equal(code, want) {
+		t.Fatalf("addr2 code incorrect: got %x, want %x", code, want)
+	}
+	// Verify delegation executed the correct code.
+	var (
+		fortyTwo = common.BytesToHash([]byte{0x42})
+		actual   = state.GetState(addr2, fortyTwo)
+	)
+	if actual != fortyTwo {
+		t.Fatalf("addr2 storage wrong: expected %x, got %x", fortyTwo, actual)
+	}
+}
+
 func TestEIP6110(t *testing.T) {
 	var (
 		engine = beacon.NewFaker()
@@ -4314,3 +4419,59 @@ func TestEIP6110(t *testing.T) {
 		}
 	}
 }
+
+// CHANGE(taiko): TestHistoricState tests the historical states out of the
+// in-memory layers can be served from the indexed state histories.
+func TestHistoricState(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		dest    = common.Address{0xde, 0xad}
+		gspec   = &Genesis{
+			Config:  params.TestChainConfig,
+			Alloc:   types.GenesisAlloc{address: {Balance: big.NewInt(params.Ether)}},
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		signer = types.LatestSigner(gspec.Config)
+		engine = ethash.NewFaker()
+		blocks = state.TriesInMemory + 10
+	)
+	_, chain, _ := GenerateChainWithGenesis(gspec, engine, blocks, func(i int, gen *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(address), dest, big.NewInt(1), params.TxGas, gen.header.BaseFee, nil), signer, key)
+		gen.AddTx(tx)
+	})
+	db, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), t.TempDir(), "", false)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	config := DefaultCacheConfigWithScheme(rawdb.PathScheme)
+	config.StateHistoryIndex = true
+	bc, err := NewBlockChain(db, config, gspec, nil, engine, vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create blockchain: %v", err)
+	}
+	defer bc.Stop()
+
+	if n, err := bc.InsertChain(chain); err != nil {
+		t.Fatalf("Failed to insert block %d: %v", n, err)
+	}
+	// The states below the disk layer are only available as historical state
+	for i := 0; i < blocks-state.TriesInMemory; i++ {
+		root := chain[i].Root()
+		if _, err := bc.StateAt(root); err == nil && i < blocks-state.TriesInMemory-1 {
+			t.Fatalf("Block %d: unexpected live state", i+1)
+		}
+		statedb, err := bc.HistoricState(root)
+		if err != nil {
+			t.Fatalf("Block %d: failed to open historical state: %v", i+1, err)
+		}
+		if balance := statedb.GetBalance(dest); balance.Uint64() != uint64(i+1) {
+			t.Fatalf("Block %d: balance mismatch, want %d, got %d", i+1, i+1, balance)
+		}
+		if nonce := statedb.GetNonce(address); nonce != uint64(i+1) {
+			t.Fatalf("Block %d: nonce mismatch, want %d, got %d", i+1, i+1, nonce)
+		}
+	}
+}
@@ -52,6 +52,15 @@ type ChainIndexerBackend interface {
 	Prune(threshold uint64) error
 }
 
+// CHANGE(taiko): ChainIndexerRollback is implemented by the backends storing
+// their index data outside of the index database, deleting the data of the
+// sections invalidated by a reorg.
+type ChainIndexerRollback interface {
+	// Rollback deletes the index data of all the sections from the given one
+	// onwards.
+	Rollback(section uint64) error
+}
+
 // ChainIndexerChain interface is used for connecting the indexer to a blockchain
 type ChainIndexerChain interface {
 	// CurrentHeader retrieves the latest locally known header.
@@ -483,6 +492,13 @@ func (c *ChainIndexer) setValidSections(sections uint64) {
 	binary.BigEndian.PutUint64(data[:], sections)
 	c.indexDb.Put([]byte("count"), data[:])
 
+	// CHANGE(taiko): delete the index data of the reorged sections stored by
+	// the backend itself.
+	if rollback, ok := c.backend.(ChainIndexerRollback); ok && sections < c.storedSections {
+		if err := rollback.Rollback(sections); err != nil {
+			c.log.Error("Failed to roll back reorged sections", "sections", sections, "err", err)
+		}
+	}
 	// Remove any reorged sections, caching the valids in the mean time
 	for c.storedSections > sections {
 		c.storedSections--
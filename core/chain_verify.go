@@ -0,0 +1,282 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+const (
+	// verifyHeadMargin is the distance from the chain head the background
+	// verification stops at, to not race against the blocks being imported
+	// and reorged meanwhile.
+	verifyHeadMargin = 128
+
+	// verifyReportLimit is the maximum number of inconsistencies the background
+	// verification logs individually.
+	verifyReportLimit = 16
+)
+
+var (
+	errVerifyStopped = errors.New("chain verification stopped")
+
+	verifyInconsistencyMeter = metrics.NewRegisteredMeter("chain/verify/inconsistencies", nil)
+)
+
+// VerifyChain checks that the canonical chain segment [start, end] is complete
+// and self-consistent across the ancient store and the key-value store. Every
+// block must have a canonical hash and the matching header linked to its parent.
+// The blocks up to the head of the synced chain must additionally have the body
+// and receipts matching the roots in the header.
+//
+// Each inconsistency found is passed to the report callback. The number of the
+// first inconsistent block is returned, along with a flag whether there is any.
+func VerifyChain(db ethdb.Database, start, end uint64, report func(number uint64, err error)) (uint64, bool) {
+	first, failed, _ := verifyChain(db, start, end, report, nil)
+	return first, failed
+}
+
+// verifyChain is the interruptible version of VerifyChain, returning
+// errVerifyStopped if the quit channel is closed before the end is reached.
+func verifyChain(db ethdb.Database, start, end uint64, report func(number uint64, err error), quit <-chan struct{}) (uint64, bool, error) {
+	// The blocks below the tail of the ancient store are pruned.
+	if tail, err := db.Tail(); err == nil && start < tail {
+		start = tail
+	}
+	var (
+		first  uint64
+		failed bool
+		prev   common.Hash
+		synced = syncedChainHead(db)
+
+		started = time.Now()
+		logged  = time.Now()
+	)
+	fail := func(number uint64, err error) {
+		report(number, err)
+		if !failed {
+			first, failed = number, true
+		}
+	}
+	for number := start; number <= end; number++ {
+		if number%1024 == 0 {
+			select {
+			case <-quit:
+				return first, failed, errVerifyStopped
+			default:
+			}
+		}
+		hash := rawdb.ReadCanonicalHash(db, number)
+		if hash == (common.Hash{}) {
+			fail(number, errors.New("missing canonical hash"))
+			prev = common.Hash{}
+			continue
+		}
+		header := rawdb.ReadHeader(db, hash, number)
+		if header == nil {
+			fail(number, fmt.Errorf("missing header %x", hash))
+			prev = hash
+			continue
+		}
+		if prev != (common.Hash{}) && header.ParentHash != prev {
+			fail(number, fmt.Errorf("parent hash %x mismatches the canonical %x", header.ParentHash, prev))
+		}
+		prev = hash
+
+		if number <= synced {
+			if err := verifyBlockData(db, header); err != nil {
+				fail(number, err)
+			}
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Verifying chain", "number", number, "end", end, "elapsed", common.PrettyDuration(time.Since(started)))
+			logged = time.Now()
+		}
+	}
+	return first, failed, nil
+}
+
+// CHANGE(taiko): StartVerification verifies the canonical chain of the running
+// node in the background, up to a safe distance from the current head. The
+// inconsistencies are only reported, since repairing requires rewinding the
+// chain under the node; they have to be fixed offline by `geth db verify`.
+func (bc *BlockChain) StartVerification() {
+	head := bc.CurrentBlock().Number.Uint64()
+	if head <= verifyHeadMargin {
+		return
+	}
+	end := head - verifyHeadMargin
+
+	bc.wg.Add(1)
+	go func() {
+		defer bc.wg.Done()
+
+		var (
+			issues  int
+			started = time.Now()
+		)
+		log.Info("Verifying chain in background", "end", end)
+		first, failed, err := verifyChain(bc.db, 0, end, func(number uint64, err error) {
+			verifyInconsistencyMeter.Mark(1)
+			if issues < verifyReportLimit {
+				log.Error("Database inconsistency", "number", number, "err", err)
+			}
+			issues++
+		}, bc.quit)
+		switch {
+		case err != nil:
+			log.Info("Chain verification stopped", "elapsed", common.PrettyDuration(time.Since(started)))
+		case failed:
+			log.Error("Chain verification failed", "first", first, "issues", issues, "elapsed", common.PrettyDuration(time.Since(started)), "hint", "stop the node and run `geth db verify --repair`")
+		default:
+			log.Info("Chain verification finished", "end", end, "elapsed", common.PrettyDuration(time.Since(started)))
+		}
+	}()
+}
+
+// syncedChainHead returns the number of the highest block whose body and
+// receipts are expected to be present in the database.
+func syncedChainHead(db ethdb.Database) uint64 {
+	var head uint64
+	for _, hash := range []common.Hash{rawdb.ReadHeadBlockHash(db), rawdb.ReadHeadFastBlockHash(db)} {
+		if number := rawdb.ReadHeaderNumber(db, hash); number != nil && *number > head {
+			head = *number
+		}
+	}
+	return head
+}
+
+// verifyBlockData checks that the body and receipts of the block are present
+// and match the roots committed by the header.
+func verifyBlockData(db ethdb.Database, header *types.Header) error {
+	var (
+		hash   = header.Hash()
+		number = header.Number.Uint64()
+	)
+	body := rawdb.ReadBody(db, hash, number)
+	if body == nil {
+		return errors.New("missing body")
+	}
+	if root := types.DeriveSha(types.Transactions(body.Transactions), trie.NewStackTrie(nil)); root != header.TxHash {
+		return fmt.Errorf("transaction root %x mismatches the header %x", root, header.TxHash)
+	}
+	if root := types.CalcUncleHash(body.Uncles); root != header.UncleHash {
+		return fmt.Errorf("uncle hash %x mismatches the header %x", root, header.UncleHash)
+	}
+	if header.WithdrawalsHash != nil {
+		if body.Withdrawals == nil {
+			return errors.New("missing withdrawals")
+		}
+		if root := types.DeriveSha(types.Withdrawals(body.Withdrawals), trie.NewStackTrie(nil)); root != *header.WithdrawalsHash {
+			return fmt.Errorf("withdrawal root %x mismatches the header %x", root, *header.WithdrawalsHash)
+		}
+	}
+	receipts := rawdb.ReadRawReceipts(db, hash, number)
+	if receipts == nil {
+		return errors.New("missing receipts")
+	}
+	if len(receipts) != len(body.Transactions) {
+		return fmt.Errorf("receipt count %d mismatches the transaction count %d", len(receipts), len(body.Transactions))
+	}
+	// The type and bloom are not persisted, derive them for the consensus encoding.
+	for i, receipt := range receipts {
+		receipt.Type = body.Transactions[i].Type()
+		receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+	}
+	if root := types.DeriveSha(receipts, trie.NewStackTrie(nil)); root != header.ReceiptHash {
+		return fmt.Errorf("receipt root %x mismatches the header %x", root, header.ReceiptHash)
+	}
+	return nil
+}
+
+// RewindChain discards the canonical chain from the given block onwards, so that
+// the missing or corrupted blocks can be synced again. The ancient items from the
+// block are truncated, the per-block data left in the key-value store is deleted,
+// and the head markers are moved back to the parent block.
+func RewindChain(db ethdb.Database, number uint64) error {
+	if number == 0 {
+		return errors.New("cannot rewind the genesis block")
+	}
+	parent := rawdb.ReadCanonicalHash(db, number-1)
+	if parent == (common.Hash{}) || rawdb.ReadHeader(db, parent, number-1) == nil {
+		return fmt.Errorf("missing parent block %d", number-1)
+	}
+	// Collect the blocks and transactions to discard before truncating the
+	// ancient store, the canonical hashes of the frozen blocks live there.
+	var (
+		hashes []common.Hash
+		txs    []common.Hash
+	)
+	if head := rawdb.ReadHeadHeader(db); head != nil {
+		for n := number; n <= head.Number.Uint64(); n++ {
+			hash := rawdb.ReadCanonicalHash(db, n)
+			if hash != (common.Hash{}) {
+				if body := rawdb.ReadBody(db, hash, n); body != nil {
+					for _, tx := range body.Transactions {
+						txs = append(txs, tx.Hash())
+					}
+				}
+			}
+			hashes = append(hashes, hash)
+		}
+	}
+	if frozen, err := db.Ancients(); err == nil && number < frozen {
+		if _, err := db.TruncateHead(number); err != nil {
+			return err
+		}
+	}
+	batch := db.NewBatch()
+	for i, hash := range hashes {
+		n := number + uint64(i)
+		rawdb.DeleteCanonicalHash(batch, n)
+		if hash != (common.Hash{}) {
+			rawdb.DeleteBlock(batch, hash, n)
+		}
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	rawdb.DeleteTxLookupEntries(batch, txs)
+	rawdb.WriteHeadHeaderHash(batch, parent)
+
+	// The head blocks are only moved backward, the block state is re-verified
+	// by the chain on the startup.
+	if n := rawdb.ReadHeaderNumber(db, rawdb.ReadHeadBlockHash(db)); n == nil || *n >= number {
+		rawdb.WriteHeadBlockHash(batch, parent)
+	}
+	if n := rawdb.ReadHeaderNumber(db, rawdb.ReadHeadFastBlockHash(db)); n == nil || *n >= number {
+		rawdb.WriteHeadFastBlockHash(batch, parent)
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Info("Rewound chain", "number", number-1, "hash", parent, "blocks", len(hashes))
+	return nil
+}
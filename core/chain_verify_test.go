@@ -0,0 +1,131 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestVerifyChain(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{
+			Config:  params.TestChainConfig,
+			Alloc:   types.GenesisAlloc{address: {Balance: big.NewInt(params.Ether)}},
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		signer = types.LatestSigner(gspec.Config)
+	)
+	_, blocks, receipts := GenerateChainWithGenesis(gspec, ethash.NewFaker(), 32, func(i int, gen *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(address), common.Address{0xde, 0xad}, big.NewInt(1), params.TxGas, gen.header.BaseFee, nil), signer, key)
+		gen.AddTx(tx)
+	})
+	db, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), t.TempDir(), "", false)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	chain, err := NewBlockChain(db, DefaultCacheConfigWithScheme(rawdb.HashScheme), gspec, nil, ethash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create blockchain: %v", err)
+	}
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	if n, err := chain.InsertHeaderChain(headers); err != nil {
+		t.Fatalf("Failed to insert header %d: %v", n, err)
+	}
+	// Freeze the first half of the chain.
+	if n, err := chain.InsertReceiptChain(blocks, receipts, uint64(len(blocks)/2)); err != nil {
+		t.Fatalf("Failed to insert receipt %d: %v", n, err)
+	}
+	chain.Stop()
+
+	verify := func(end uint64) (uint64, bool, []uint64) {
+		var numbers []uint64
+		first, failed := VerifyChain(db, 0, end, func(number uint64, err error) {
+			numbers = append(numbers, number)
+		})
+		return first, failed, numbers
+	}
+	if _, failed, numbers := verify(uint64(len(blocks))); failed {
+		t.Fatalf("Unexpected inconsistencies at %v", numbers)
+	}
+	// Corrupt the blocks in the key-value store.
+	block := blocks[20]
+	rawdb.DeleteReceipts(db, block.Hash(), block.NumberU64())
+	rawdb.WriteBody(db, blocks[24].Hash(), blocks[24].NumberU64(), blocks[25].Body())
+
+	first, failed, numbers := verify(uint64(len(blocks)))
+	if !failed || first != block.NumberU64() {
+		t.Fatalf("Unexpected first inconsistency, want %d, got %d (%v)", block.NumberU64(), first, failed)
+	}
+	if len(numbers) != 2 || numbers[1] != blocks[24].NumberU64() {
+		t.Fatalf("Unexpected inconsistencies %v", numbers)
+	}
+	// Rewind the chain to the last consistent block, along with the frozen
+	// blocks above it.
+	if err := RewindChain(db, 10); err != nil {
+		t.Fatalf("Failed to rewind chain: %v", err)
+	}
+	if frozen, _ := db.Ancients(); frozen != 10 {
+		t.Fatalf("Unexpected frozen items, want %d, got %d", 10, frozen)
+	}
+	if head := rawdb.ReadHeadHeader(db); head == nil || head.Number.Uint64() != 9 {
+		t.Fatalf("Unexpected head header %v", head)
+	}
+	if hash := rawdb.ReadHeadFastBlockHash(db); hash != blocks[8].Hash() {
+		t.Fatalf("Unexpected head fast block %x", hash)
+	}
+	if hash := rawdb.ReadCanonicalHash(db, 20); hash != (common.Hash{}) {
+		t.Fatalf("Unexpected canonical hash %x above the head", hash)
+	}
+	// The per-block data of the rewound blocks must be gone, both of the frozen
+	// and the live ones.
+	for _, block := range []*types.Block{blocks[12], blocks[20]} {
+		if rawdb.ReadHeaderNumber(db, block.Hash()) != nil || rawdb.ReadHeader(db, block.Hash(), block.NumberU64()) != nil {
+			t.Fatalf("Unexpected header of rewound block %d", block.NumberU64())
+		}
+		if td := rawdb.ReadTd(db, block.Hash(), block.NumberU64()); td != nil {
+			t.Fatalf("Unexpected total difficulty of rewound block %d", block.NumberU64())
+		}
+		if rawdb.HasBody(db, block.Hash(), block.NumberU64()) {
+			t.Fatalf("Unexpected body of rewound block %d", block.NumberU64())
+		}
+		if lookup := rawdb.ReadTxLookupEntry(db, block.Transactions()[0].Hash()); lookup != nil {
+			t.Fatalf("Unexpected transaction lookup of rewound block %d", block.NumberU64())
+		}
+	}
+	if _, failed, numbers := verify(uint64(len(blocks))); !failed || numbers[0] != 10 {
+		t.Fatalf("Unexpected inconsistencies %v after rewinding", numbers)
+	}
+	if _, failed, numbers := verify(9); failed {
+		t.Fatalf("Unexpected inconsistencies %v below the head", numbers)
+	}
+}
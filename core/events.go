@@ -18,6 +18,7 @@ package core
 
 import (
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 )
 
@@ -41,3 +42,14 @@ type ChainSideEvent struct {
 }
 
 type ChainHeadEvent struct{ Block *types.Block }
+
+// CHANGE(taiko): StateDiffEvent is posted when the state changes of a block are
+// applied to the canonical chain, or reverted when the block is reorged out. If
+// the changes of a reorg are not available anymore, a Resync event without the
+// diff is posted instead, the state has to be reloaded at the given block.
+type StateDiffEvent struct {
+	Block    *types.Block
+	Diff     *state.StateDiff
+	Reverted bool
+	Resync   bool
+}
@@ -0,0 +1,311 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// LogIndexSectionSize is the number of blocks in a log index section.
+	LogIndexSectionSize = 4096
+
+	// LogIndexPositionBits is the number of low bits of a log position holding
+	// the index of the log within its block, the high bits holding the offset of
+	// the block within its section.
+	LogIndexPositionBits = 20
+
+	// logIndexThrottling is the time to wait between processing two consecutive
+	// index sections. It's useful during chain upgrades to prevent disk overload.
+	logIndexThrottling = 100 * time.Millisecond
+)
+
+var (
+	errCorruptLogPositions = errors.New("corrupt log positions")
+	errCorruptLogSection   = errors.New("corrupt log index section")
+)
+
+// logIndexKey is an address or a positional topic indexed by the log index.
+type logIndexKey struct {
+	kind  byte
+	value common.Hash // Left padded for addresses
+}
+
+// logIndexKeySize is the size of a key in the directory of a log index section.
+const logIndexKeySize = 1 + common.HashLength
+
+// LogIndexer implements a core.ChainIndexer, building up an index of the log
+// positions emitted by each address or carrying each topic at each position,
+// permitting fast log filtering over sparse criteria.
+//
+// The postings of each section are compacted into the log index freezer, one
+// item per key in the sorted order of the keys. A small directory per section
+// maps the keys onto the freezer items.
+type LogIndexer struct {
+	size     uint64                   // section size to generate the log index for
+	db       ethdb.Database           // database instance to write index directories into
+	freezer  ethdb.AncientStore       // freezer to append the postings of the sections into
+	section  uint64                   // Section is the section number being processed currently
+	first    uint64                   // First freezer item of the section being processed
+	head     common.Hash              // Head is the hash of the last header processed
+	postings map[logIndexKey][]uint32 // Positions of the logs matching each key in the section
+}
+
+// NewLogIndexer returns a chain indexer that generates the log index for the
+// canonical chain, storing the postings into the given freezer.
+func NewLogIndexer(db ethdb.Database, freezer ethdb.AncientStore, size, confirms uint64) *ChainIndexer {
+	backend := &LogIndexer{
+		db:      db,
+		freezer: freezer,
+		size:    size,
+	}
+	table := rawdb.NewTable(db, string(rawdb.LogIndexTablePrefix))
+	indexer := NewChainIndexer(db, table, backend, size, confirms, logIndexThrottling, "logindex")
+
+	// Drop the sections whose postings were lost from the freezer, e.g. by an
+	// unclean shutdown, they are regenerated by the chain indexer
+	items, err := freezer.Ancients()
+	if err != nil {
+		log.Error("Failed to read log index freezer", "err", err)
+		items = 0
+	}
+	indexer.lock.Lock()
+	valid := indexer.storedSections
+	for valid > 0 {
+		if end, err := logSectionEnd(rawdb.ReadLogIndexSection(db, valid-1)); err == nil && end <= items {
+			break
+		}
+		valid--
+	}
+	if valid < indexer.storedSections {
+		log.Warn("Log index postings missing, reindexing", "stored", indexer.storedSections, "valid", valid)
+		indexer.setValidSections(valid)
+	}
+	indexer.lock.Unlock()
+	return indexer
+}
+
+// Reset implements core.ChainIndexerBackend, starting a new log index section.
+// Any leftover of the section or the ones above, either reorged out or partially
+// written before a crash, is dropped.
+func (l *LogIndexer) Reset(ctx context.Context, section uint64, lastSectionHead common.Hash) error {
+	if err := l.Rollback(section); err != nil {
+		return err
+	}
+	first, err := l.sectionEnd(section)
+	if err != nil {
+		return err
+	}
+	l.section, l.first, l.head, l.postings = section, first, common.Hash{}, make(map[logIndexKey][]uint32)
+	return nil
+}
+
+// Rollback implements core.ChainIndexerRollback, deleting the directories and
+// the postings of all the sections from the given one onwards.
+func (l *LogIndexer) Rollback(section uint64) error {
+	end, err := l.sectionEnd(section)
+	if err != nil {
+		return err
+	}
+	rawdb.DeleteLogIndexSections(l.db, section)
+	if items, err := l.freezer.Ancients(); err != nil {
+		return err
+	} else if items > end {
+		if _, err := l.freezer.TruncateHead(end); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sectionEnd returns the first freezer item following the postings of all the
+// sections below the given one.
+func (l *LogIndexer) sectionEnd(section uint64) (uint64, error) {
+	if section == 0 {
+		return 0, nil
+	}
+	return logSectionEnd(rawdb.ReadLogIndexSection(l.db, section-1))
+}
+
+// Process implements core.ChainIndexerBackend, adding the logs of a new header
+// into the index.
+func (l *LogIndexer) Process(ctx context.Context, header *types.Header) error {
+	number, hash := header.Number.Uint64(), header.Hash()
+
+	logs := rawdb.ReadLogs(l.db, hash, number)
+	if logs == nil && header.ReceiptHash != types.EmptyReceiptsHash {
+		return fmt.Errorf("missing receipts of block #%d [%x]", number, hash)
+	}
+	var index uint32
+	for _, txLogs := range logs {
+		for _, log := range txLogs {
+			if index >= 1<<LogIndexPositionBits {
+				return fmt.Errorf("too many logs in block #%d [%x]", number, hash)
+			}
+			pos := uint32(number-l.section*l.size)<<LogIndexPositionBits | index
+			index++
+
+			l.add(logIndexKey{kind: rawdb.LogIndexAddress, value: common.BytesToHash(log.Address.Bytes())}, pos)
+			for i, topic := range log.Topics {
+				l.add(logIndexKey{kind: rawdb.LogIndexTopic + byte(i), value: topic}, pos)
+			}
+		}
+	}
+	l.head = hash
+	return nil
+}
+
+// add appends a log position to the postings of a key.
+func (l *LogIndexer) add(key logIndexKey, pos uint32) {
+	if postings := l.postings[key]; len(postings) == 0 || postings[len(postings)-1] != pos {
+		l.postings[key] = append(postings, pos)
+	}
+}
+
+// Commit implements core.ChainIndexerBackend, finalizing the log index section
+// and writing it out into the freezer and the database.
+func (l *LogIndexer) Commit() error {
+	keys := make([]logIndexKey, 0, len(l.postings))
+	for key := range l.postings {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b logIndexKey) int {
+		if a.kind != b.kind {
+			return int(a.kind) - int(b.kind)
+		}
+		return bytes.Compare(a.value[:], b.value[:])
+	})
+	var (
+		directory = make([]byte, 0, common.HashLength+8+len(keys)*logIndexKeySize)
+		postings  = make([][]byte, 0, len(keys))
+	)
+	directory = append(directory, l.head.Bytes()...)
+	directory = binary.BigEndian.AppendUint64(directory, l.first)
+	for _, key := range keys {
+		directory = append(append(directory, key.kind), key.value[:]...)
+		postings = append(postings, encodeLogPositions(l.postings[key]))
+	}
+	// Persist the postings before the directory referencing them
+	if err := rawdb.WriteLogIndexPostings(l.freezer, l.first, postings); err != nil {
+		return err
+	}
+	if err := l.freezer.Sync(); err != nil {
+		return err
+	}
+	rawdb.WriteLogIndexSection(l.db, l.section, directory)
+	return nil
+}
+
+// Prune returns an empty error since we don't support pruning here.
+func (l *LogIndexer) Prune(threshold uint64) error {
+	return nil
+}
+
+// ReadLogPositions retrieves the positions of the logs emitted by the address,
+// or carrying the topic at the given position if the address is nil, within the
+// log index section with the given head.
+func ReadLogPositions(db ethdb.KeyValueReader, freezer ethdb.AncientReaderOp, address *common.Address, topic common.Hash, position int, section uint64, head common.Hash) ([]uint32, error) {
+	directory := rawdb.ReadLogIndexSection(db, section)
+	if len(directory) < common.HashLength+8 || (len(directory)-common.HashLength-8)%logIndexKeySize != 0 {
+		if directory == nil {
+			return nil, nil
+		}
+		return nil, errCorruptLogSection
+	}
+	// Sections are only readable by their own head
+	if common.BytesToHash(directory[:common.HashLength]) != head {
+		return nil, nil
+	}
+	var want [logIndexKeySize]byte
+	if address != nil {
+		want[0] = rawdb.LogIndexAddress
+		copy(want[1+common.HashLength-common.AddressLength:], address.Bytes())
+	} else {
+		want[0] = rawdb.LogIndexTopic + byte(position)
+		copy(want[1:], topic.Bytes())
+	}
+	var (
+		first = binary.BigEndian.Uint64(directory[common.HashLength:])
+		keys  = directory[common.HashLength+8:]
+		count = len(keys) / logIndexKeySize
+	)
+	i := sort.Search(count, func(i int) bool {
+		return bytes.Compare(keys[i*logIndexKeySize:(i+1)*logIndexKeySize], want[:]) >= 0
+	})
+	if i == count || !bytes.Equal(keys[i*logIndexKeySize:(i+1)*logIndexKeySize], want[:]) {
+		return nil, nil
+	}
+	blob := rawdb.ReadLogIndexPostings(freezer, first+uint64(i))
+	if blob == nil {
+		return nil, fmt.Errorf("missing log index postings #%d of section %d", first+uint64(i), section)
+	}
+	return decodeLogPositions(blob)
+}
+
+// logSectionEnd returns the first freezer item following the postings of the
+// log index section with the given directory.
+func logSectionEnd(directory []byte) (uint64, error) {
+	if len(directory) < common.HashLength+8 || (len(directory)-common.HashLength-8)%logIndexKeySize != 0 {
+		return 0, errCorruptLogSection
+	}
+	keys := uint64(len(directory)-common.HashLength-8) / logIndexKeySize
+	return binary.BigEndian.Uint64(directory[common.HashLength:]) + keys, nil
+}
+
+// encodeLogPositions encodes the ascending log positions as varint deltas.
+func encodeLogPositions(positions []uint32) []byte {
+	var (
+		blob = make([]byte, 0, len(positions)*2)
+		prev uint32
+	)
+	for _, pos := range positions {
+		blob = binary.AppendUvarint(blob, uint64(pos-prev))
+		prev = pos
+	}
+	return blob
+}
+
+// decodeLogPositions decodes the varint deltas of ascending log positions.
+func decodeLogPositions(blob []byte) ([]uint32, error) {
+	var (
+		positions []uint32
+		prev      uint64
+	)
+	for len(blob) > 0 {
+		delta, n := binary.Uvarint(blob)
+		if n <= 0 || prev+delta > 1<<32-1 {
+			return nil, errCorruptLogPositions
+		}
+		prev += delta
+		positions = append(positions, uint32(prev))
+		blob = blob[n:]
+	}
+	return positions, nil
+}
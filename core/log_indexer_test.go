@@ -0,0 +1,138 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Tests that the log indexer records the positions of the logs of a section by
+// address and positional topic.
+func TestLogIndexer(t *testing.T) {
+	var (
+		db       = rawdb.NewMemoryDatabase()
+		freezer  = rawdb.NewMemoryFreezer(false, map[string]bool{"postings": true})
+		indexer  = &LogIndexer{db: db, freezer: freezer, size: 4}
+		addr1    = common.Address{0x01}
+		addr2    = common.Address{0x02}
+		topic1   = common.Hash{0x01}
+		topic2   = common.Hash{0x02}
+		receipts = map[uint64]types.Receipts{
+			5: {{Logs: []*types.Log{{Address: addr1, Topics: []common.Hash{topic1}}}}},
+			6: {
+				{Logs: []*types.Log{{Address: addr2, Topics: []common.Hash{topic1, topic2}}}},
+				{Logs: []*types.Log{{Address: addr1}, {Address: addr1, Topics: []common.Hash{topic2}}}},
+			},
+		}
+		head common.Hash
+	)
+	// Index an empty first section to anchor the postings of the second one
+	if err := indexer.Reset(context.Background(), 0, common.Hash{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := indexer.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := indexer.Reset(context.Background(), 1, common.Hash{}); err != nil {
+		t.Fatal(err)
+	}
+	for number := uint64(4); number < 8; number++ {
+		header := &types.Header{Number: new(big.Int).SetUint64(number), ReceiptHash: types.EmptyReceiptsHash}
+		if receipts[number] != nil {
+			header.ReceiptHash = common.Hash{0xff}
+		}
+		rawdb.WriteReceipts(db, header.Hash(), number, receipts[number])
+
+		if err := indexer.Process(context.Background(), header); err != nil {
+			t.Fatalf("block %d: %v", number, err)
+		}
+		head = header.Hash()
+	}
+	if err := indexer.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	pos := func(offset, index uint32) uint32 { return offset<<LogIndexPositionBits | index }
+
+	for i, tt := range []struct {
+		address  *common.Address
+		topic    common.Hash
+		position int
+		want     []uint32
+	}{
+		{address: &addr1, want: []uint32{pos(1, 0), pos(2, 1), pos(2, 2)}},
+		{address: &addr2, want: []uint32{pos(2, 0)}},
+		{topic: topic1, want: []uint32{pos(1, 0), pos(2, 0)}},
+		{topic: topic2, want: []uint32{pos(2, 2)}},
+		{topic: topic2, position: 1, want: []uint32{pos(2, 0)}},
+		{topic: topic1, position: 1},
+	} {
+		have, err := ReadLogPositions(db, freezer, tt.address, tt.topic, tt.position, 1, head)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("test %d: positions mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+	// Sections are only readable by their own head
+	if have, _ := ReadLogPositions(db, freezer, &addr1, common.Hash{}, 0, 1, common.Hash{}); len(have) != 0 {
+		t.Errorf("positions of unknown section head: have %v, want none", have)
+	}
+	// Postings are compacted into one freezer item per key
+	if items, _ := freezer.Ancients(); items != 5 {
+		t.Errorf("postings items mismatch: have %d, want 5", items)
+	}
+	// Reorged sections are deleted along with their postings
+	if err := indexer.Rollback(1); err != nil {
+		t.Fatal(err)
+	}
+	if rawdb.ReadLogIndexSection(db, 1) != nil {
+		t.Error("reorged section directory left")
+	}
+	if items, _ := freezer.Ancients(); items != 0 {
+		t.Errorf("reorged postings left: %d items", items)
+	}
+	if have, _ := ReadLogPositions(db, freezer, &addr1, common.Hash{}, 0, 1, head); len(have) != 0 {
+		t.Errorf("positions of reorged section: have %v, want none", have)
+	}
+	// Blocks with receipts unavailable are rejected
+	header := &types.Header{Number: big.NewInt(8), ReceiptHash: common.Hash{0x01}}
+	if err := indexer.Process(context.Background(), header); err == nil {
+		t.Error("missing receipts accepted")
+	}
+}
+
+func TestLogPositionsEncoding(t *testing.T) {
+	positions := []uint32{0, 1, 127, 128, 1 << 20, 1<<32 - 1}
+	have, err := decodeLogPositions(encodeLogPositions(positions))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(have, positions) {
+		t.Fatalf("positions mismatch: have %v, want %v", have, positions)
+	}
+	if _, err := decodeLogPositions([]byte{0x80}); err != errCorruptLogPositions {
+		t.Fatalf("truncated positions: have %v, want %v", err, errCorruptLogPositions)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// Parallel transaction execution
+//
+// The transactions of a block are executed speculatively and concurrently, each
+// on a private copy of the state at the start of the block. Every speculative
+// execution records the state it read and the modifications it made. The
+// results are then committed in block order: if the reads of a transaction
+// still match the state left by its predecessors, its modifications are
+// replayed on the state; otherwise the transaction conflicts with a preceding
+// one and is executed again, sequentially. Either way, the resulting state and
+// receipts are the same as with sequential execution.
+
+var (
+	parallelCommittedMeter = metrics.NewRegisteredMeter("chain/parallel/committed", nil)
+	parallelConflictMeter  = metrics.NewRegisteredMeter("chain/parallel/conflicts", nil)
+)
+
+// speculation is the result of executing a transaction speculatively.
+type speculation struct {
+	state  *recordingState
+	result *ExecutionResult
+	err    error
+}
+
+// parallelizable reports whether the transactions of a block can be executed in
+// parallel.
+func (p *StateProcessor) parallelizable(block *types.Block, statedb *state.StateDB, cfg vm.Config) bool {
+	if !p.parallel || len(block.Transactions()) < 2 || cfg.Tracer != nil || cfg.Profiler != nil {
+		return false
+	}
+	// Pre-Byzantium receipts commit to the intermediate state root, and the
+	// witnesses of stateless execution to the order the state is accessed in.
+	if !p.config.IsByzantium(block.Number()) || statedb.Witness() != nil || statedb.GetTrie().IsVerkle() {
+		return false
+	}
+	return true
+}
+
+// applyParallel applies the transactions of a block, executing them
+// speculatively in parallel.
+func (p *StateProcessor) applyParallel(block *types.Block, statedb *state.StateDB, cfg vm.Config, vmenv *vm.EVM, gp *GasPool, usedGas *uint64) (types.Receipts, error) {
+	var (
+		header = block.Header()
+		txs    = block.Transactions()
+		signer = types.MakeSigner(p.config, header.Number, header.Time)
+		msgs   = make([]*Message, len(txs))
+	)
+	for i, tx := range txs {
+		// Taiko marks the first transaction as anchor transaction.
+		if i == 0 && p.config.Taiko {
+			if err := tx.MarkAsAnchor(); err != nil {
+				return nil, err
+			}
+		}
+		msg, err := TransactionToMessage(tx, signer, header.BaseFee)
+		if err != nil {
+			return nil, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
+		}
+		if p.config.IsOntake(block.Number()) {
+			msg.BasefeeSharingPctg = DecodeOntakeExtraData(header.Extra)
+		}
+		msgs[i] = msg
+	}
+	// Execute the transactions speculatively in the background
+	var (
+		results = make([]chan *speculation, len(txs))
+		next    atomic.Int64
+		done    = make(chan struct{})
+	)
+	defer close(done)
+
+	for i := range results {
+		results[i] = make(chan *speculation, 1)
+	}
+	for n := min(runtime.NumCPU(), len(txs)); n > 0; n-- {
+		go func(db *state.StateDB) {
+			// The block hash cache of the context is not thread safe
+			evm := vm.NewEVM(NewEVMBlockContext(header, p.chain, nil), vm.TxContext{}, db, p.config, cfg)
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= len(txs) {
+					return
+				}
+				select {
+				case <-done:
+					return
+				default:
+				}
+				results[i] <- speculate(evm, db, msgs[i], header.GasLimit)
+			}
+		}(statedb.Copy())
+	}
+	// Commit the results in order, re-executing conflicting transactions
+	receipts := make(types.Receipts, 0, len(txs))
+	for i, tx := range txs {
+		var (
+			spec = <-results[i]
+			msg  = msgs[i]
+		)
+		statedb.SetTxContext(tx.Hash(), i)
+
+		if spec.err != nil || gp.Gas() < msg.GasLimit || !spec.state.valid(statedb) {
+			parallelConflictMeter.Mark(1)
+
+			receipt, err := ApplyTransactionWithEVM(msg, p.config, gp, statedb, block.Number(), block.Hash(), tx, usedGas, vmenv)
+			if err != nil {
+				return nil, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
+			}
+			receipts = append(receipts, receipt)
+			continue
+		}
+		parallelCommittedMeter.Mark(1)
+
+		spec.state.apply(statedb)
+		statedb.Finalise(true)
+
+		gp.SubGas(msg.GasLimit)
+		gp.AddGas(msg.GasLimit - spec.result.UsedGas)
+		*usedGas += spec.result.UsedGas
+
+		vmenv.Reset(NewEVMTxContext(msg), statedb)
+		receipts = append(receipts, MakeReceipt(vmenv, spec.result, statedb, block.Number(), block.Hash(), tx, *usedGas, nil))
+	}
+	return receipts, nil
+}
+
+// speculate executes a message on the given state, recording the state it reads
+// and the modifications it makes. The state is left unchanged.
+func speculate(evm *vm.EVM, db *state.StateDB, msg *Message, gasLimit uint64) *speculation {
+	var (
+		snapshot = db.Snapshot()
+		recorder = newRecordingState(db)
+	)
+	defer db.RevertToSnapshot(snapshot)
+
+	evm.Reset(NewEVMTxContext(msg), recorder)
+	result, err := ApplyMessage(evm, msg, new(GasPool).AddGas(gasLimit))
+	return &speculation{state: recorder, result: result, err: err}
+}
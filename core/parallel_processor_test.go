@@ -0,0 +1,122 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	// parallelCounter increments the value of its first storage slot.
+	parallelCounter = common.Address{0xc0}
+	// parallelCoinbase stores the balance of the coinbase.
+	parallelCoinbase = common.Address{0xc1}
+	// parallelLogger emits a log with the caller.
+	parallelLogger = common.Address{0xc2}
+	// parallelCreator creates an empty contract.
+	parallelCreator = common.Address{0xc3}
+)
+
+func parallelGenesis(keys []*ecdsa.PrivateKey) *Genesis {
+	gspec := &Genesis{
+		Config: params.TestChainConfig,
+		Alloc: types.GenesisAlloc{
+			parallelCounter:  {Code: []byte{byte(vm.PUSH1), 0x00, byte(vm.SLOAD), byte(vm.PUSH1), 0x01, byte(vm.ADD), byte(vm.PUSH1), 0x00, byte(vm.SSTORE), byte(vm.STOP)}},
+			parallelCoinbase: {Code: []byte{byte(vm.COINBASE), byte(vm.BALANCE), byte(vm.PUSH1), 0x00, byte(vm.SSTORE), byte(vm.STOP)}},
+			parallelLogger:   {Code: []byte{byte(vm.CALLER), byte(vm.PUSH1), 0x00, byte(vm.MSTORE), byte(vm.PUSH1), 0x20, byte(vm.PUSH1), 0x00, byte(vm.LOG0), byte(vm.STOP)}},
+			parallelCreator:  {Code: []byte{byte(vm.PUSH1), 0x00, byte(vm.PUSH1), 0x00, byte(vm.PUSH1), 0x00, byte(vm.CREATE), byte(vm.POP), byte(vm.STOP)}},
+		},
+	}
+	for _, key := range keys {
+		gspec.Alloc[crypto.PubkeyToAddress(key.PublicKey)] = types.Account{Balance: big.NewInt(params.Ether)}
+	}
+	return gspec
+}
+
+// Tests that executing transactions in parallel results in the same state and
+// receipts as executing them sequentially.
+func TestParallelProcessing(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 8)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	var (
+		gspec   = parallelGenesis(keys)
+		targets = []common.Address{parallelCounter, parallelCoinbase, parallelLogger, parallelCreator}
+	)
+	_, blocks, receipts := GenerateChainWithGenesis(gspec, ethash.NewFaker(), 8, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{0xcb})
+		for j, key := range keys {
+			// Mix independent transfers, calls to shared contracts and
+			// several transactions from the same sender.
+			for k := 0; k <= j%3; k++ {
+				var (
+					from = crypto.PubkeyToAddress(key.PublicKey)
+					to   = common.Address{byte(i), byte(j), byte(k)}
+				)
+				if (i+j+k)%2 == 0 {
+					to = targets[(i+j+k)%len(targets)]
+				}
+				tx := types.MustSignNewTx(key, b.Signer(), &types.LegacyTx{
+					Nonce:    b.TxNonce(from),
+					To:       &to,
+					Value:    big.NewInt(1000),
+					Gas:      100_000,
+					GasPrice: b.BaseFee(),
+				})
+				b.AddTx(tx)
+			}
+		}
+	})
+	for _, parallel := range []bool{false, true} {
+		cacheConfig := DefaultCacheConfigWithScheme(rawdb.HashScheme)
+		cacheConfig.ParallelExecution = parallel
+
+		chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), cacheConfig, gspec, nil, ethash.NewFaker(), vm.Config{}, nil)
+		if err != nil {
+			t.Fatalf("failed to create chain: %v", err)
+		}
+		// The blocks were generated with sequential execution, so the state
+		// root and receipt root are checked on import.
+		if n, err := chain.InsertChain(blocks); err != nil {
+			t.Fatalf("parallel %t: failed to insert block %d: %v", parallel, n, err)
+		}
+		for i, block := range blocks {
+			have := chain.GetReceiptsByHash(block.Hash())
+			if len(have) != len(receipts[i]) {
+				t.Fatalf("parallel %t: block %d receipt count mismatch: have %d, want %d", parallel, i, len(have), len(receipts[i]))
+			}
+			for j, receipt := range have {
+				want := receipts[i][j]
+				if receipt.GasUsed != want.GasUsed || receipt.Status != want.Status || receipt.ContractAddress != want.ContractAddress || len(receipt.Logs) != len(want.Logs) {
+					t.Fatalf("parallel %t: block %d receipt %d mismatch", parallel, i, j)
+				}
+			}
+		}
+		chain.Stop()
+	}
+}
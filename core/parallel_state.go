@@ -0,0 +1,412 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/stateless"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/trie/utils"
+	"github.com/holiman/uint256"
+)
+
+// readKind is the kind of a piece of state read by a transaction.
+type readKind byte
+
+const (
+	readExist       readKind = iota // Account existence
+	readBalance                     // Account balance
+	readNonce                       // Account nonce
+	readCode                        // Account code, identified by its hash
+	readStorage                     // Storage slot
+	readCommitted                   // Storage slot at the start of the transaction
+	readStorageRoot                 // Storage root of the account
+)
+
+// readKey identifies a piece of state read by a transaction.
+type readKey struct {
+	kind readKind
+	addr common.Address
+	slot common.Hash
+}
+
+// readValue returns the current value of the given piece of state.
+func readValue(db vm.StateDB, key readKey) any {
+	switch key.kind {
+	case readExist:
+		return db.Exist(key.addr)
+	case readBalance:
+		return *db.GetBalance(key.addr)
+	case readNonce:
+		return db.GetNonce(key.addr)
+	case readCode:
+		return db.GetCodeHash(key.addr)
+	case readStorage:
+		return db.GetState(key.addr, key.slot)
+	case readCommitted:
+		return db.GetCommittedState(key.addr, key.slot)
+	case readStorageRoot:
+		return db.GetStorageRoot(key.addr)
+	}
+	panic("unknown read kind")
+}
+
+// stateWrite is a state modification made by a transaction, which is replayed
+// on the canonical state once the transaction is committed.
+type stateWrite struct {
+	op     func(db vm.StateDB) // Applies the modification
+	addr   common.Address      // Account modified, if any
+	slot   *common.Hash        // Storage slot modified, if any
+	effect writeEffect         // Effect on the account
+	add    *uint256.Int        // Balance increase, if any
+	sub    *uint256.Int        // Balance decrease, if any
+}
+
+// writeEffect describes which parts of an account are determined by a write.
+type writeEffect byte
+
+const (
+	writeTouch   writeEffect = 1 << iota // The account exists afterwards
+	writeBalance                         // The balance is set to a known value
+	writeNonce                           // The nonce is set to a known value
+	writeCode                            // The code is set to a known value
+	writeReset                           // The account is recreated from scratch
+)
+
+// accountWrites aggregates the writes of a transaction to a single account.
+type accountWrites struct {
+	effect   writeEffect
+	add, sub uint256.Int // Balance changes since the balance was last set
+	slots    map[common.Hash]struct{}
+}
+
+// recordingState is a view on the state used to execute a transaction
+// speculatively. It records the values read from the state the transaction was
+// executed on, along with the modifications made by the transaction.
+//
+// A read is only recorded if its value is not fully determined by preceding
+// writes of the same transaction. As long as all recorded reads match the state
+// a transaction is committed on, executing it on that state results in exactly
+// the recorded writes.
+type recordingState struct {
+	db *state.StateDB
+
+	reads     map[readKey]any
+	writes    []stateWrite
+	accounts  map[common.Address]*accountWrites
+	snapshots []recordingSnapshot
+}
+
+// recordingSnapshot maps a snapshot of the underlying state to the number of
+// writes made when it was taken.
+type recordingSnapshot struct {
+	id     int
+	writes int
+}
+
+func newRecordingState(db *state.StateDB) *recordingState {
+	return &recordingState{
+		db:       db,
+		reads:    make(map[readKey]any),
+		accounts: make(map[common.Address]*accountWrites),
+	}
+}
+
+// record stores the value of a read, unless the piece of state was read before.
+func (s *recordingState) record(key readKey, value any) {
+	if _, ok := s.reads[key]; !ok {
+		s.reads[key] = value
+	}
+}
+
+// write appends a modification and accounts for its effects.
+func (s *recordingState) write(w stateWrite) {
+	s.writes = append(s.writes, w)
+	s.account(&w)
+}
+
+// account updates the aggregated account writes with the given modification.
+func (s *recordingState) account(w *stateWrite) {
+	if w.effect == 0 && w.slot == nil {
+		return
+	}
+	acc := s.accounts[w.addr]
+	if acc == nil {
+		acc = &accountWrites{slots: make(map[common.Hash]struct{})}
+		s.accounts[w.addr] = acc
+	}
+	if w.effect&writeReset != 0 {
+		*acc = accountWrites{slots: make(map[common.Hash]struct{})}
+	}
+	if w.effect&writeBalance != 0 {
+		acc.add.Clear()
+		acc.sub.Clear()
+	}
+	acc.effect |= w.effect
+	if w.add != nil {
+		acc.add.Add(&acc.add, w.add)
+	}
+	if w.sub != nil {
+		acc.sub.Add(&acc.sub, w.sub)
+	}
+	if w.slot != nil {
+		acc.slots[*w.slot] = struct{}{}
+	}
+}
+
+// written returns the aggregated writes to the given account.
+func (s *recordingState) written(addr common.Address) *accountWrites {
+	if acc := s.accounts[addr]; acc != nil {
+		return acc
+	}
+	return &accountWrites{}
+}
+
+func (s *recordingState) CreateAccount(addr common.Address) {
+	s.write(stateWrite{op: func(db vm.StateDB) { db.CreateAccount(addr) }, addr: addr, effect: writeTouch | writeReset | writeBalance | writeNonce | writeCode})
+	s.db.CreateAccount(addr)
+}
+
+func (s *recordingState) CreateContract(addr common.Address) {
+	s.write(stateWrite{op: func(db vm.StateDB) { db.CreateContract(addr) }})
+	s.db.CreateContract(addr)
+}
+
+func (s *recordingState) SubBalance(addr common.Address, amount *uint256.Int, reason tracing.BalanceChangeReason) {
+	amount = amount.Clone()
+	s.write(stateWrite{op: func(db vm.StateDB) { db.SubBalance(addr, amount, reason) }, addr: addr, effect: writeTouch, sub: amount})
+	s.db.SubBalance(addr, amount, reason)
+}
+
+func (s *recordingState) AddBalance(addr common.Address, amount *uint256.Int, reason tracing.BalanceChangeReason) {
+	amount = amount.Clone()
+	s.write(stateWrite{op: func(db vm.StateDB) { db.AddBalance(addr, amount, reason) }, addr: addr, effect: writeTouch, add: amount})
+	s.db.AddBalance(addr, amount, reason)
+}
+
+func (s *recordingState) GetBalance(addr common.Address) *uint256.Int {
+	balance := s.db.GetBalance(addr)
+	s.recordBalance(addr, balance)
+	return balance
+}
+
+// recordBalance records a balance read, deducting the balance changes of the
+// transaction itself unless they determine the balance.
+func (s *recordingState) recordBalance(addr common.Address, balance *uint256.Int) {
+	acc := s.written(addr)
+	if acc.effect&writeBalance != 0 {
+		return
+	}
+	base := new(uint256.Int).Sub(balance, &acc.add)
+	s.record(readKey{kind: readBalance, addr: addr}, *base.Add(base, &acc.sub))
+}
+
+func (s *recordingState) GetNonce(addr common.Address) uint64 {
+	nonce := s.db.GetNonce(addr)
+	if s.written(addr).effect&writeNonce == 0 {
+		s.record(readKey{kind: readNonce, addr: addr}, nonce)
+	}
+	return nonce
+}
+
+func (s *recordingState) SetNonce(addr common.Address, nonce uint64) {
+	s.write(stateWrite{op: func(db vm.StateDB) { db.SetNonce(addr, nonce) }, addr: addr, effect: writeTouch | writeNonce})
+	s.db.SetNonce(addr, nonce)
+}
+
+// recordCode records a read of the account code, unless it was set by the
+// transaction itself.
+func (s *recordingState) recordCode(addr common.Address) {
+	if s.written(addr).effect&writeCode == 0 {
+		s.record(readKey{kind: readCode, addr: addr}, s.db.GetCodeHash(addr))
+	}
+}
+
+func (s *recordingState) GetCodeHash(addr common.Address) common.Hash {
+	s.recordCode(addr)
+	return s.db.GetCodeHash(addr)
+}
+
+func (s *recordingState) GetCode(addr common.Address) []byte {
+	s.recordCode(addr)
+	return s.db.GetCode(addr)
+}
+
+func (s *recordingState) SetCode(addr common.Address, code []byte) {
+	s.write(stateWrite{op: func(db vm.StateDB) { db.SetCode(addr, code) }, addr: addr, effect: writeTouch | writeCode})
+	s.db.SetCode(addr, code)
+}
+
+func (s *recordingState) GetCodeSize(addr common.Address) int {
+	s.recordCode(addr)
+	return s.db.GetCodeSize(addr)
+}
+
+func (s *recordingState) AddRefund(gas uint64)          { s.db.AddRefund(gas) }
+func (s *recordingState) SubRefund(gas uint64)          { s.db.SubRefund(gas) }
+func (s *recordingState) GetRefund() uint64             { return s.db.GetRefund() }
+func (s *recordingState) PointCache() *utils.PointCache { return s.db.PointCache() }
+func (s *recordingState) Witness() *stateless.Witness   { return s.db.Witness() }
+
+func (s *recordingState) GetCommittedState(addr common.Address, slot common.Hash) common.Hash {
+	value := s.db.GetCommittedState(addr, slot)
+	if s.written(addr).effect&writeReset == 0 {
+		s.record(readKey{kind: readCommitted, addr: addr, slot: slot}, value)
+	}
+	return value
+}
+
+func (s *recordingState) GetState(addr common.Address, slot common.Hash) common.Hash {
+	value := s.db.GetState(addr, slot)
+	acc := s.written(addr)
+	if _, ok := acc.slots[slot]; !ok && acc.effect&writeReset == 0 {
+		s.record(readKey{kind: readStorage, addr: addr, slot: slot}, value)
+	}
+	return value
+}
+
+func (s *recordingState) SetState(addr common.Address, slot, value common.Hash) {
+	s.write(stateWrite{op: func(db vm.StateDB) { db.SetState(addr, slot, value) }, addr: addr, slot: &slot, effect: writeTouch})
+	s.db.SetState(addr, slot, value)
+}
+
+func (s *recordingState) GetStorageRoot(addr common.Address) common.Hash {
+	root := s.db.GetStorageRoot(addr)
+	if s.written(addr).effect&writeReset == 0 {
+		s.record(readKey{kind: readStorageRoot, addr: addr}, root)
+	}
+	return root
+}
+
+func (s *recordingState) GetTransientState(addr common.Address, key common.Hash) common.Hash {
+	return s.db.GetTransientState(addr, key)
+}
+
+func (s *recordingState) SetTransientState(addr common.Address, key, value common.Hash) {
+	s.db.SetTransientState(addr, key, value)
+}
+
+func (s *recordingState) SelfDestruct(addr common.Address) {
+	s.write(stateWrite{op: func(db vm.StateDB) { db.SelfDestruct(addr) }, addr: addr, effect: writeBalance})
+	s.db.SelfDestruct(addr)
+}
+
+func (s *recordingState) HasSelfDestructed(addr common.Address) bool {
+	return s.db.HasSelfDestructed(addr)
+}
+
+func (s *recordingState) Selfdestruct6780(addr common.Address) {
+	// The account is only destructed if it was created by the transaction,
+	// in which case its balance is determined by the transaction as well.
+	s.write(stateWrite{op: func(db vm.StateDB) { db.Selfdestruct6780(addr) }})
+	s.db.Selfdestruct6780(addr)
+}
+
+func (s *recordingState) Exist(addr common.Address) bool {
+	exist := s.db.Exist(addr)
+	if s.written(addr).effect&writeTouch == 0 {
+		s.record(readKey{kind: readExist, addr: addr}, exist)
+	}
+	return exist
+}
+
+func (s *recordingState) Empty(addr common.Address) bool {
+	// Emptiness is derived from the balance, nonce and code of the account,
+	// which are recorded individually.
+	s.recordBalance(addr, s.db.GetBalance(addr))
+	if s.written(addr).effect&writeNonce == 0 {
+		s.record(readKey{kind: readNonce, addr: addr}, s.db.GetNonce(addr))
+	}
+	s.recordCode(addr)
+	return s.db.Empty(addr)
+}
+
+func (s *recordingState) AddressInAccessList(addr common.Address) bool {
+	return s.db.AddressInAccessList(addr)
+}
+
+func (s *recordingState) SlotInAccessList(addr common.Address, slot common.Hash) (bool, bool) {
+	return s.db.SlotInAccessList(addr, slot)
+}
+
+func (s *recordingState) AddAddressToAccessList(addr common.Address) {
+	s.db.AddAddressToAccessList(addr)
+}
+
+func (s *recordingState) AddSlotToAccessList(addr common.Address, slot common.Hash) {
+	s.db.AddSlotToAccessList(addr, slot)
+}
+
+func (s *recordingState) Prepare(rules params.Rules, sender, coinbase common.Address, dest *common.Address, precompiles []common.Address, txAccesses types.AccessList) {
+	s.db.Prepare(rules, sender, coinbase, dest, precompiles, txAccesses)
+}
+
+func (s *recordingState) Snapshot() int {
+	id := s.db.Snapshot()
+	s.snapshots = append(s.snapshots, recordingSnapshot{id: id, writes: len(s.writes)})
+	return id
+}
+
+func (s *recordingState) RevertToSnapshot(id int) {
+	s.db.RevertToSnapshot(id)
+
+	// Drop the writes made since the snapshot, but keep the reads as they
+	// may have affected the course of the execution.
+	for i := len(s.snapshots) - 1; i >= 0; i-- {
+		if s.snapshots[i].id != id {
+			continue
+		}
+		s.writes = s.writes[:s.snapshots[i].writes]
+		s.snapshots = s.snapshots[:i]
+		break
+	}
+	clear(s.accounts)
+	for i := range s.writes {
+		s.account(&s.writes[i])
+	}
+}
+
+func (s *recordingState) AddLog(log *types.Log) {
+	s.write(stateWrite{op: func(db vm.StateDB) { db.AddLog(log) }})
+	s.db.AddLog(log)
+}
+
+func (s *recordingState) AddPreimage(hash common.Hash, preimage []byte) {
+	s.write(stateWrite{op: func(db vm.StateDB) { db.AddPreimage(hash, preimage) }})
+	s.db.AddPreimage(hash, preimage)
+}
+
+// valid reports whether the recorded reads match the given state.
+func (s *recordingState) valid(db vm.StateDB) bool {
+	for key, value := range s.reads {
+		if readValue(db, key) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// apply replays the recorded modifications on the given state.
+func (s *recordingState) apply(db vm.StateDB) {
+	for _, w := range s.writes {
+		w.op(db)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Kinds of the keys of the log index. Topics are indexed by their position, so
+// the kind of the topic at position i is LogIndexTopic+i.
+const (
+	LogIndexAddress byte = 0
+	LogIndexTopic   byte = 1
+)
+
+// ReadLogIndexSection retrieves the directory of the log index section with the
+// given number, holding the section head, the first postings item of the section
+// and the sorted keys indexed within it.
+func ReadLogIndexSection(db ethdb.KeyValueReader, section uint64) []byte {
+	data, _ := db.Get(logIndexSectionKey(section))
+	return data
+}
+
+// WriteLogIndexSection stores the directory of the log index section with the
+// given number.
+func WriteLogIndexSection(db ethdb.KeyValueWriter, section uint64, directory []byte) {
+	if err := db.Put(logIndexSectionKey(section), directory); err != nil {
+		log.Crit("Failed to store log index section", "err", err)
+	}
+}
+
+// DeleteLogIndexSections removes the directories of all the log index sections
+// from the given number onwards.
+func DeleteLogIndexSections(db ethdb.KeyValueStore, from uint64) {
+	it := db.NewIterator(logIndexSectionPrefix, encodeBlockNumber(from))
+	defer it.Release()
+
+	batch := db.NewBatch()
+	for it.Next() {
+		if key := it.Key(); len(key) == len(logIndexSectionPrefix)+8 {
+			if err := batch.Delete(key); err != nil {
+				log.Crit("Failed to delete log index section", "err", err)
+			}
+		}
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to delete log index sections", "err", err)
+	}
+}
+
+// ReadLogIndexPostings retrieves the encoded positions of the logs emitted by an
+// address or carrying a topic stored as the given item of the log index freezer.
+func ReadLogIndexPostings(db ethdb.AncientReaderOp, item uint64) []byte {
+	blob, err := db.Ancient(logIndexPostings, item)
+	if err != nil {
+		return nil
+	}
+	return blob
+}
+
+// WriteLogIndexPostings appends the encoded positions of the logs of a section
+// into the log index freezer, numbering the items from the given first one.
+func WriteLogIndexPostings(db ethdb.AncientWriter, first uint64, postings [][]byte) error {
+	_, err := db.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+		for i, blob := range postings {
+			if err := op.AppendRaw(logIndexPostings, first+uint64(i), blob); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return err
+}
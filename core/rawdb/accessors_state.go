@@ -264,3 +264,119 @@ func WriteStateHistory(db ethdb.AncientWriter, id uint64, meta []byte, accountIn
 		return nil
 	})
 }
+
+// ReadStateHistoryIndexHead retrieves the id of the latest state history which
+// has been indexed. Nil is returned if the index has never been constructed.
+func ReadStateHistoryIndexHead(db ethdb.KeyValueReader) *uint64 {
+	data, _ := db.Get(stateHistoryIndexHeadKey)
+	if len(data) != 8 {
+		return nil
+	}
+	number := binary.BigEndian.Uint64(data)
+	return &number
+}
+
+// WriteStateHistoryIndexHead stores the id of the latest indexed state history
+// into database.
+func WriteStateHistoryIndexHead(db ethdb.KeyValueWriter, id uint64) {
+	if err := db.Put(stateHistoryIndexHeadKey, encodeBlockNumber(id)); err != nil {
+		log.Crit("Failed to store the state history index head", "err", err)
+	}
+}
+
+// DeleteStateHistoryIndexHead removes the id of the latest indexed state history
+// from the database.
+func DeleteStateHistoryIndexHead(db ethdb.KeyValueWriter) {
+	if err := db.Delete(stateHistoryIndexHeadKey); err != nil {
+		log.Crit("Failed to delete the state history index head", "err", err)
+	}
+}
+
+// ReadStateHistoryPruneTarget retrieves the tail target of the interrupted state
+// history pruning. Nil is returned if there is no pruning in progress.
+func ReadStateHistoryPruneTarget(db ethdb.KeyValueReader) *uint64 {
+	data, _ := db.Get(stateHistoryPruneTargetKey)
+	if len(data) != 8 {
+		return nil
+	}
+	number := binary.BigEndian.Uint64(data)
+	return &number
+}
+
+// WriteStateHistoryPruneTarget stores the tail target of the state history
+// pruning into database.
+func WriteStateHistoryPruneTarget(db ethdb.KeyValueWriter, tail uint64) {
+	if err := db.Put(stateHistoryPruneTargetKey, encodeBlockNumber(tail)); err != nil {
+		log.Crit("Failed to store the state history prune target", "err", err)
+	}
+}
+
+// DeleteStateHistoryPruneTarget removes the tail target of the state history
+// pruning from the database.
+func DeleteStateHistoryPruneTarget(db ethdb.KeyValueWriter) {
+	if err := db.Delete(stateHistoryPruneTargetKey); err != nil {
+		log.Crit("Failed to delete the state history prune target", "err", err)
+	}
+}
+
+// WriteAccountHistoryIndex marks the account as modified in the state history
+// with the provided id.
+func WriteAccountHistoryIndex(db ethdb.KeyValueWriter, address common.Address, id uint64) {
+	if err := db.Put(accountHistoryIndexKey(address, id), nil); err != nil {
+		log.Crit("Failed to store account history index", "err", err)
+	}
+}
+
+// DeleteAccountHistoryIndex removes the account modification marker of the
+// state history with the provided id.
+func DeleteAccountHistoryIndex(db ethdb.KeyValueWriter, address common.Address, id uint64) {
+	if err := db.Delete(accountHistoryIndexKey(address, id)); err != nil {
+		log.Crit("Failed to delete account history index", "err", err)
+	}
+}
+
+// WriteStorageHistoryIndex marks the storage slot as modified in the state
+// history with the provided id. Note, slot refers to the hash of the raw slot key.
+func WriteStorageHistoryIndex(db ethdb.KeyValueWriter, address common.Address, slot common.Hash, id uint64) {
+	if err := db.Put(storageHistoryIndexKey(address, slot, id), nil); err != nil {
+		log.Crit("Failed to store storage history index", "err", err)
+	}
+}
+
+// DeleteStorageHistoryIndex removes the storage slot modification marker of
+// the state history with the provided id.
+func DeleteStorageHistoryIndex(db ethdb.KeyValueWriter, address common.Address, slot common.Hash, id uint64) {
+	if err := db.Delete(storageHistoryIndexKey(address, slot, id)); err != nil {
+		log.Crit("Failed to delete storage history index", "err", err)
+	}
+}
+
+// ReadAccountHistoryIndexFrom returns the id of the first state history not
+// below start in which the account was modified. False is returned if no such
+// state history is indexed.
+func ReadAccountHistoryIndexFrom(db ethdb.Iteratee, address common.Address, start uint64) (uint64, bool) {
+	prefix := accountHistoryIndexKey(address, 0)
+	return readHistoryIndexFrom(db, prefix[:len(prefix)-8], start)
+}
+
+// ReadStorageHistoryIndexFrom returns the id of the first state history not
+// below start in which the storage slot was modified. False is returned if no
+// such state history is indexed.
+func ReadStorageHistoryIndexFrom(db ethdb.Iteratee, address common.Address, slot common.Hash, start uint64) (uint64, bool) {
+	prefix := storageHistoryIndexKey(address, slot, 0)
+	return readHistoryIndexFrom(db, prefix[:len(prefix)-8], start)
+}
+
+// readHistoryIndexFrom seeks the first state history id not below start in the
+// index entries sharing the given prefix.
+func readHistoryIndexFrom(db ethdb.Iteratee, prefix []byte, start uint64) (uint64, bool) {
+	it := db.NewIterator(prefix, encodeBlockNumber(start))
+	defer it.Release()
+
+	for it.Next() {
+		if key := it.Key(); len(key) == len(prefix)+8 {
+			return binary.BigEndian.Uint64(key[len(prefix):]), true
+		}
+	}
+	return 0, false
+}
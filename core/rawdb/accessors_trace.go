@@ -0,0 +1,135 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// ReadTraceIndexRange retrieves the first and last block numbers whose traces
+// are indexed. False is returned if the index has never been constructed.
+func ReadTraceIndexRange(db ethdb.KeyValueReader) (uint64, uint64, bool) {
+	tail, _ := db.Get(traceIndexTailKey)
+	head, _ := db.Get(traceIndexHeadKey)
+	if len(tail) != 8 || len(head) != 8 {
+		return 0, 0, false
+	}
+	return binary.BigEndian.Uint64(tail), binary.BigEndian.Uint64(head), true
+}
+
+// WriteTraceIndexRange stores the first and last block numbers whose traces
+// are indexed.
+func WriteTraceIndexRange(db ethdb.KeyValueWriter, tail, head uint64) {
+	if err := db.Put(traceIndexTailKey, encodeBlockNumber(tail)); err != nil {
+		log.Crit("Failed to store the trace index tail", "err", err)
+	}
+	if err := db.Put(traceIndexHeadKey, encodeBlockNumber(head)); err != nil {
+		log.Crit("Failed to store the trace index head", "err", err)
+	}
+}
+
+// DeleteTraceIndexRange removes the trace index range, marking the index as
+// never constructed.
+func DeleteTraceIndexRange(db ethdb.KeyValueWriter) {
+	if err := db.Delete(traceIndexTailKey); err != nil {
+		log.Crit("Failed to delete the trace index tail", "err", err)
+	}
+	if err := db.Delete(traceIndexHeadKey); err != nil {
+		log.Crit("Failed to delete the trace index head", "err", err)
+	}
+}
+
+// ReadBlockTraces retrieves the encoded flat call traces of a block.
+func ReadBlockTraces(db ethdb.KeyValueReader, number uint64, hash common.Hash) []byte {
+	data, _ := db.Get(blockTracesKey(number, hash))
+	return data
+}
+
+// WriteBlockTraces stores the encoded flat call traces of a block.
+func WriteBlockTraces(db ethdb.KeyValueWriter, number uint64, hash common.Hash, traces []byte) {
+	if err := db.Put(blockTracesKey(number, hash), traces); err != nil {
+		log.Crit("Failed to store block traces", "err", err)
+	}
+}
+
+// ReadBlockTracesHashes retrieves the hashes of all the blocks with the given
+// number having traces stored, both canonical and reorged forks included.
+func ReadBlockTracesHashes(db ethdb.Iteratee, number uint64) []common.Hash {
+	prefix := append(blockTracesPrefix, encodeBlockNumber(number)...)
+
+	it := db.NewIterator(prefix, nil)
+	defer it.Release()
+
+	var hashes []common.Hash
+	for it.Next() {
+		if key := it.Key(); len(key) == len(prefix)+common.HashLength {
+			hashes = append(hashes, common.BytesToHash(key[len(prefix):]))
+		}
+	}
+	return hashes
+}
+
+// DeleteBlockTraces removes the flat call traces of a block.
+func DeleteBlockTraces(db ethdb.KeyValueWriter, number uint64, hash common.Hash) {
+	if err := db.Delete(blockTracesKey(number, hash)); err != nil {
+		log.Crit("Failed to delete block traces", "err", err)
+	}
+}
+
+// WriteTraceAddressIndex marks the address as sender or recipient of a call in
+// the traces of the block with the provided number.
+func WriteTraceAddressIndex(db ethdb.KeyValueWriter, address common.Address, number uint64) {
+	if err := db.Put(traceAddressIndexKey(address, number), nil); err != nil {
+		log.Crit("Failed to store trace address index", "err", err)
+	}
+}
+
+// DeleteTraceAddressIndex removes the mark of the address in the traces of the
+// block with the provided number.
+func DeleteTraceAddressIndex(db ethdb.KeyValueWriter, address common.Address, number uint64) {
+	if err := db.Delete(traceAddressIndexKey(address, number)); err != nil {
+		log.Crit("Failed to delete trace address index", "err", err)
+	}
+}
+
+// ReadTraceAddressIndex returns the numbers of the blocks within [from, to]
+// whose traces contain a call from or to the address.
+func ReadTraceAddressIndex(db ethdb.Iteratee, address common.Address, from, to uint64) []uint64 {
+	prefix := traceAddressIndexKey(address, 0)
+	prefix = prefix[:len(prefix)-8]
+
+	it := db.NewIterator(prefix, encodeBlockNumber(from))
+	defer it.Release()
+
+	var numbers []uint64
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(prefix)+8 {
+			continue
+		}
+		number := binary.BigEndian.Uint64(key[len(prefix):])
+		if number > to {
+			break
+		}
+		numbers = append(numbers, number)
+	}
+	return numbers
+}
@@ -70,15 +70,26 @@ var stateFreezerNoSnappy = map[string]bool{
 	stateHistoryStorageData:  false,
 }
 
+// CHANGE(taiko): the log index freezer stores the postings of the log index
+// sections, one item per indexed address or topic of each section.
+const logIndexPostings = "postings"
+
+// logIndexFreezerNoSnappy configures whether compression is disabled for the
+// log index tables. Postings are already delta encoded.
+var logIndexFreezerNoSnappy = map[string]bool{
+	logIndexPostings: true,
+}
+
 // The list of identifiers of ancient stores.
 var (
 	ChainFreezerName       = "chain"        // the folder name of chain segment ancient store.
 	MerkleStateFreezerName = "state"        // the folder name of state history ancient store.
 	VerkleStateFreezerName = "state_verkle" // the folder name of state history ancient store.
+	LogIndexFreezerName    = "logindex"     // CHANGE(taiko): the folder name of log index ancient store.
 )
 
 // freezers the collections of all builtin freezers.
-var freezers = []string{ChainFreezerName, MerkleStateFreezerName, VerkleStateFreezerName}
+var freezers = []string{ChainFreezerName, MerkleStateFreezerName, VerkleStateFreezerName, LogIndexFreezerName}
 
 // NewStateFreezer initializes the ancient store for state history.
 //
@@ -98,3 +109,12 @@ func NewStateFreezer(ancientDir string, verkle bool, readOnly bool) (ethdb.Reset
 	}
 	return newResettableFreezer(name, "eth/db/state", readOnly, stateHistoryTableSize, stateFreezerNoSnappy)
 }
+
+// CHANGE(taiko): NewLogIndexFreezer initializes the ancient store for the log
+// index postings, purely in-memory if the empty directory is given.
+func NewLogIndexFreezer(ancientDir string, readOnly bool) (ethdb.ResettableAncientStore, error) {
+	if ancientDir == "" {
+		return NewMemoryFreezer(readOnly, logIndexFreezerNoSnappy), nil
+	}
+	return newResettableFreezer(filepath.Join(ancientDir, LogIndexFreezerName), "eth/db/logindex", readOnly, freezerTableSize, logIndexFreezerNoSnappy)
+}
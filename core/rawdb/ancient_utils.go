@@ -18,7 +18,6 @@ package rawdb
 
 import (
 	"fmt"
-	"path/filepath"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethdb"
@@ -105,6 +104,23 @@ func inspectFreezers(db ethdb.Database) ([]freezerInfo, error) {
 			}
 			infos = append(infos, info)
 
+		case LogIndexFreezerName:
+			datadir, err := db.AncientDatadir()
+			if err != nil {
+				return nil, err
+			}
+			f, err := NewLogIndexFreezer(datadir, true)
+			if err != nil {
+				continue // might be possible the log index freezer is not existent
+			}
+			defer f.Close()
+
+			info, err := inspect(freezer, logIndexFreezerNoSnappy, f)
+			if err != nil {
+				return nil, err
+			}
+			infos = append(infos, info)
+
 		default:
 			return nil, fmt.Errorf("unknown freezer, supported ones: %v", freezers)
 		}
@@ -117,25 +133,9 @@ func inspectFreezers(db ethdb.Database) ([]freezerInfo, error) {
 // be opened. Start and end specify the range for dumping out indexes.
 // Note this function can only be used for debugging purposes.
 func InspectFreezerTable(ancient string, freezerName string, tableName string, start, end int64) error {
-	var (
-		path   string
-		tables map[string]bool
-	)
-	switch freezerName {
-	case ChainFreezerName:
-		path, tables = resolveChainFreezerDir(ancient), chainFreezerNoSnappy
-	case MerkleStateFreezerName, VerkleStateFreezerName:
-		path, tables = filepath.Join(ancient, freezerName), stateFreezerNoSnappy
-	default:
-		return fmt.Errorf("unknown freezer, supported ones: %v", freezers)
-	}
-	noSnappy, exist := tables[tableName]
-	if !exist {
-		var names []string
-		for name := range tables {
-			names = append(names, name)
-		}
-		return fmt.Errorf("unknown table, supported ones: %v", names)
+	path, noSnappy, err := resolveFreezerTable(ancient, freezerName, tableName)
+	if err != nil {
+		return err
 	}
 	table, err := newFreezerTable(path, tableName, noSnappy, true)
 	if err != nil {
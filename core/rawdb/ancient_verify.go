@@ -0,0 +1,194 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// verifyIndexBatch is the number of index entries loaded at once for verification.
+const verifyIndexBatch = 64 * 1024
+
+// VerifyFreezer checks the structural integrity of all the tables in the specified
+// freezer, and their alignment with each other. The passed ancient indicates the
+// path of root ancient directory, the freezer is opened in read-only mode. The
+// issues found are returned, while the error is only returned if the verification
+// can't be performed at all.
+func VerifyFreezer(ancient string, freezerName string) ([]error, error) {
+	var (
+		path   string
+		tables map[string]bool
+	)
+	switch freezerName {
+	case ChainFreezerName:
+		path, tables = resolveChainFreezerDir(ancient), chainFreezerNoSnappy
+	case MerkleStateFreezerName, VerkleStateFreezerName:
+		path, tables = filepath.Join(ancient, freezerName), stateFreezerNoSnappy
+	case LogIndexFreezerName:
+		path, tables = filepath.Join(ancient, freezerName), logIndexFreezerNoSnappy
+	default:
+		return nil, fmt.Errorf("unknown freezer, supported ones: %v", freezers)
+	}
+	f, err := NewFreezer(path, "", true, freezerTableSize, tables)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return verifyFreezer(f), nil
+}
+
+// verifyFreezer checks the integrity of all the tables in the freezer, along
+// with their alignment with each other.
+func verifyFreezer(f *Freezer) []error {
+	var (
+		issues []error
+		names  []string
+		frozen = f.frozen.Load()
+		tail   = f.tail.Load()
+	)
+	for name := range f.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		table := f.tables[name]
+		if err := table.verify(); err != nil {
+			issues = append(issues, fmt.Errorf("table %s: %w", name, err))
+		}
+		if items := table.items.Load(); items != frozen {
+			issues = append(issues, fmt.Errorf("table %s: item count %d mismatches the freezer %d", name, items, frozen))
+		}
+		if hidden := table.itemHidden.Load(); hidden != tail {
+			issues = append(issues, fmt.Errorf("table %s: tail %d mismatches the freezer %d", name, hidden, tail))
+		}
+	}
+	return issues
+}
+
+// verify checks the structural integrity of the table, ensuring the index entries
+// are well-ordered, refer to the existent data files and match their boundaries.
+func (t *freezerTable) verify() error {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if t.index == nil || t.head == nil || t.meta == nil {
+		return errClosed
+	}
+	stat, err := t.index.Stat()
+	if err != nil {
+		return err
+	}
+	if stat.Size()%indexEntrySize != 0 {
+		return fmt.Errorf("index size %d is not a multiple of %d", stat.Size(), indexEntrySize)
+	}
+	var (
+		entries = uint64(stat.Size() / indexEntrySize)
+		offset  = t.itemOffset.Load()
+		sizes   = make(map[uint32]int64)
+	)
+	if entries == 0 {
+		return errors.New("empty index")
+	}
+	if items := t.items.Load(); entries-1 != items-offset {
+		return fmt.Errorf("index has %d entries, want %d", entries-1, items-offset)
+	}
+	fileSize := func(num uint32) (int64, error) {
+		if size, ok := sizes[num]; ok {
+			return size, nil
+		}
+		f, ok := t.files[num]
+		if !ok {
+			return 0, fmt.Errorf("data file %d is missing", num)
+		}
+		stat, err := f.Stat()
+		if err != nil {
+			return 0, err
+		}
+		sizes[num] = stat.Size()
+		return stat.Size(), nil
+	}
+	var (
+		prev   indexEntry
+		buffer = make([]byte, min(entries, verifyIndexBatch)*indexEntrySize)
+	)
+	for read := uint64(0); read < entries; {
+		n := min(entries-read, verifyIndexBatch)
+		if _, err := t.index.ReadAt(buffer[:n*indexEntrySize], int64(read*indexEntrySize)); err != nil {
+			return err
+		}
+		for i := uint64(0); i < n; i++ {
+			var (
+				entry  indexEntry
+				number = read + i
+				item   = offset + number - 1 // the item ending at the entry
+			)
+			entry.unmarshalBinary(buffer[i*indexEntrySize:])
+
+			// The first entry carries the tail information instead of the
+			// offset within the data file.
+			if number == 0 {
+				if entry.filenum != t.tailId || uint64(entry.offset) != offset {
+					return fmt.Errorf("tail entry (%d, %d) mismatches the table (%d, %d)", entry.filenum, entry.offset, t.tailId, offset)
+				}
+				prev = indexEntry{filenum: entry.filenum}
+				continue
+			}
+			switch {
+			case entry.filenum == prev.filenum:
+				if entry.offset < prev.offset {
+					return fmt.Errorf("item %d: offset %d is below the previous %d", item, entry.offset, prev.offset)
+				}
+			case entry.filenum == prev.filenum+1 && number > 1:
+				// The previous data file must end exactly at the last item in it.
+				size, err := fileSize(prev.filenum)
+				if err != nil {
+					return err
+				}
+				if size != int64(prev.offset) {
+					return fmt.Errorf("data file %d: size %d mismatches the indexed %d", prev.filenum, size, prev.offset)
+				}
+			case entry.filenum > prev.filenum && number == 1:
+				// Legacy tables might start the first item in the next file.
+			default:
+				return fmt.Errorf("item %d: data file %d follows %d", item, entry.filenum, prev.filenum)
+			}
+			size, err := fileSize(entry.filenum)
+			if err != nil {
+				return err
+			}
+			if int64(entry.offset) > size {
+				return fmt.Errorf("item %d: offset %d exceeds the size %d of data file %d", item, entry.offset, size, entry.filenum)
+			}
+			prev = entry
+		}
+		read += n
+	}
+	if prev.filenum != t.headId {
+		return fmt.Errorf("last item is in data file %d, want head %d", prev.filenum, t.headId)
+	}
+	if size, err := fileSize(t.headId); err != nil {
+		return err
+	} else if size != int64(prev.offset) {
+		return fmt.Errorf("head data file %d: size %d mismatches the indexed %d", t.headId, size, prev.offset)
+	}
+	return nil
+}
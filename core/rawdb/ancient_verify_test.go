@@ -0,0 +1,95 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+func TestFreezerTableVerify(t *testing.T) {
+	var (
+		path = t.TempDir()
+		name = "verify"
+	)
+	f, err := newTable(path, name, metrics.NilMeter{}, metrics.NilMeter{}, metrics.NilGauge{}, 50, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Write 20 items spanning across several data files.
+	writeChunks(t, f, 20, 15)
+	if err := f.verify(); err != nil {
+		t.Fatalf("Unexpected verification failure: %v", err)
+	}
+	// The tail deletion should be tolerated.
+	if err := f.truncateTail(7); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.verify(); err != nil {
+		t.Fatalf("Unexpected verification failure after tail deletion: %v", err)
+	}
+	f.Close()
+
+	// Shorten a data file in the middle, the boundary mismatch should be detected.
+	if err := os.Truncate(filepath.Join(path, fmt.Sprintf("%s.0003.rdat", name)), 10); err != nil {
+		t.Fatal(err)
+	}
+	f, err = newTable(path, name, metrics.NilMeter{}, metrics.NilMeter{}, metrics.NilGauge{}, 50, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if err := f.verify(); err == nil {
+		t.Fatal("Corrupted data file is not detected")
+	}
+}
+
+func TestFreezerVerify(t *testing.T) {
+	f, _ := newFreezerForTesting(t, map[string]bool{"a": true, "b": false})
+	defer f.Close()
+
+	_, err := f.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+		for i := uint64(0); i < 10; i++ {
+			if err := op.AppendRaw("a", i, getChunk(100, int(i))); err != nil {
+				return err
+			}
+			if err := op.AppendRaw("b", i, getChunk(100, int(i))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issues := verifyFreezer(f); len(issues) != 0 {
+		t.Fatalf("Unexpected issues: %v", issues)
+	}
+	// Misalign the tables, it should be reported.
+	if err := f.tables["a"].truncateHead(5); err != nil {
+		t.Fatal(err)
+	}
+	if issues := verifyFreezer(f); len(issues) != 1 {
+		t.Fatalf("Unexpected issues, want 1, got %v", issues)
+	}
+}
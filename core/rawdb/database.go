@@ -327,31 +327,10 @@ func NewPebbleDBDatabase(file string, cache int, handles int, namespace string,
 	return NewDatabase(db), nil
 }
 
-const (
-	dbPebble  = "pebble"
-	dbLeveldb = "leveldb"
-)
-
-// PreexistingDatabase checks the given data directory whether a database is already
-// instantiated at that location, and if so, returns the type of database (or the
-// empty string).
-func PreexistingDatabase(path string) string {
-	if _, err := os.Stat(filepath.Join(path, "CURRENT")); err != nil {
-		return "" // No pre-existing db
-	}
-	if matches, err := filepath.Glob(filepath.Join(path, "OPTIONS*")); len(matches) > 0 || err != nil {
-		if err != nil {
-			panic(err) // only possible if the pattern is malformed
-		}
-		return dbPebble
-	}
-	return dbLeveldb
-}
-
 // OpenOptions contains the options to apply when opening a database.
 // OBS: If AncientsDirectory is empty, it indicates that no freezer is to be used.
 type OpenOptions struct {
-	Type              string // "leveldb" | "pebble"
+	Type              string // any registered engine, e.g. "pebble" | "leveldb" | "btree"
 	Directory         string // the datadir
 	AncientsDirectory string // the ancients-dir
 	Namespace         string // the namespace for database relevant metrics
@@ -361,9 +340,13 @@ type OpenOptions struct {
 	// Ephemeral means that filesystem sync operations should be avoided: data integrity in the face of
 	// a crash is not important. This option should typically be used in tests.
 	Ephemeral bool
+	// Replica means that the database maintained by another process is opened in read-only mode,
+	// following the writes of that process. Only pebble is supported.
+	Replica bool
 }
 
-// openKeyValueDatabase opens a disk-based key-value database, e.g. leveldb or pebble.
+// openKeyValueDatabase opens a disk-based key-value database using one of the
+// registered storage engines, e.g. leveldb or pebble.
 //
 //	                      type == null          type != null
 //	                   +----------------------------------------
@@ -371,8 +354,10 @@ type OpenOptions struct {
 //	db is existent     |  from db         |  specified type (if compatible)
 func openKeyValueDatabase(o OpenOptions) (ethdb.Database, error) {
 	// Reject any unsupported database type
-	if len(o.Type) != 0 && o.Type != dbLeveldb && o.Type != dbPebble {
-		return nil, fmt.Errorf("unknown db.engine %v", o.Type)
+	if len(o.Type) != 0 {
+		if _, ok := lookupEngine(o.Type); !ok {
+			return nil, fmt.Errorf("unknown db.engine %v", o.Type)
+		}
 	}
 	// Retrieve any pre-existing database's type and use that or the requested one
 	// as long as there's no conflict between the two types
@@ -380,17 +365,23 @@ func openKeyValueDatabase(o OpenOptions) (ethdb.Database, error) {
 	if len(existingDb) != 0 && len(o.Type) != 0 && o.Type != existingDb {
 		return nil, fmt.Errorf("db.engine choice was %v but found pre-existing %v database in specified data directory", o.Type, existingDb)
 	}
-	if o.Type == dbPebble || existingDb == dbPebble {
-		log.Info("Using pebble as the backing database")
-		return NewPebbleDBDatabase(o.Directory, o.Cache, o.Handles, o.Namespace, o.ReadOnly, o.Ephemeral)
+	name := o.Type
+	if len(existingDb) != 0 {
+		name = existingDb
+	}
+	if len(name) == 0 {
+		// No pre-existing database, no user-requested one either. Use the default.
+		name = dbDefault
+		log.Info(fmt.Sprintf("Defaulting to %s as the backing database", name))
+	} else {
+		log.Info(fmt.Sprintf("Using %s as the backing database", name))
 	}
-	if o.Type == dbLeveldb || existingDb == dbLeveldb {
-		log.Info("Using leveldb as the backing database")
-		return NewLevelDBDatabase(o.Directory, o.Cache, o.Handles, o.Namespace, o.ReadOnly)
+	engine, _ := lookupEngine(name)
+	db, err := engine.Open(o)
+	if err != nil {
+		return nil, err
 	}
-	// No pre-existing database, no user-requested one either. Default to Pebble.
-	log.Info("Defaulting to pebble as the backing database")
-	return NewPebbleDBDatabase(o.Directory, o.Cache, o.Handles, o.Namespace, o.ReadOnly, o.Ephemeral)
+	return NewDatabase(db), nil
 }
 
 // Open opens both a disk-based key-value database such as leveldb or pebble, but also
@@ -399,6 +390,9 @@ func openKeyValueDatabase(o OpenOptions) (ethdb.Database, error) {
 // The passed o.AncientDir indicates the path of root ancient directory where
 // the chain freezer can be opened.
 func Open(o OpenOptions) (ethdb.Database, error) {
+	if o.Replica {
+		return openReplicaDatabase(o)
+	}
 	kvdb, err := openKeyValueDatabase(o)
 	if err != nil {
 		return nil, err
@@ -464,6 +458,9 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		hashNumPairings stat
 		legacyTries     stat
 		stateLookups    stat
+		stateIndexes    stat
+		traceIndexes    stat
+		logIndexes      stat
 		accountTries    stat
 		storageTries    stat
 		codes           stat
@@ -514,6 +511,18 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 			legacyTries.Add(size)
 		case bytes.HasPrefix(key, stateIDPrefix) && len(key) == len(stateIDPrefix)+common.HashLength:
 			stateLookups.Add(size)
+		case bytes.HasPrefix(key, StateHistoryAccountIndexPrefix) && len(key) == len(StateHistoryAccountIndexPrefix)+common.AddressLength+8:
+			stateIndexes.Add(size)
+		case bytes.HasPrefix(key, StateHistoryStorageIndexPrefix) && len(key) == len(StateHistoryStorageIndexPrefix)+common.AddressLength+common.HashLength+8:
+			stateIndexes.Add(size)
+		case bytes.HasPrefix(key, blockTracesPrefix) && len(key) == len(blockTracesPrefix)+8+common.HashLength:
+			traceIndexes.Add(size)
+		case bytes.HasPrefix(key, TraceAddressIndexPrefix) && len(key) == len(TraceAddressIndexPrefix)+common.AddressLength+8:
+			traceIndexes.Add(size)
+		case bytes.HasPrefix(key, logIndexSectionPrefix) && len(key) == len(logIndexSectionPrefix)+8:
+			logIndexes.Add(size)
+		case bytes.HasPrefix(key, LogIndexTablePrefix):
+			logIndexes.Add(size)
 		case IsAccountTrieNode(key):
 			accountTries.Add(size)
 		case IsStorageTrieNode(key):
@@ -574,6 +583,7 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 				snapshotGeneratorKey, snapshotRecoveryKey, txIndexTailKey, fastTxLookupLimitKey,
 				uncleanShutdownKey, badBlockKey, transitionStatusKey, skeletonSyncStatusKey,
 				persistentStateIDKey, trieJournalKey, snapshotSyncStatusKey, snapSyncStatusFlagKey,
+				stateHistoryIndexHeadKey, stateHistoryPruneTargetKey, traceIndexHeadKey, traceIndexTailKey,
 			} {
 				if bytes.Equal(key, meta) {
 					metadata.Add(size)
@@ -604,6 +614,9 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		{"Key-Value store", "Contract codes", codes.Size(), codes.Count()},
 		{"Key-Value store", "Hash trie nodes", legacyTries.Size(), legacyTries.Count()},
 		{"Key-Value store", "Path trie state lookups", stateLookups.Size(), stateLookups.Count()},
+		{"Key-Value store", "Path state history index", stateIndexes.Size(), stateIndexes.Count()},
+		{"Key-Value store", "Flat call trace index", traceIndexes.Size(), traceIndexes.Count()},
+		{"Key-Value store", "Log index", logIndexes.Size(), logIndexes.Count()},
 		{"Key-Value store", "Path trie account nodes", accountTries.Size(), accountTries.Count()},
 		{"Key-Value store", "Path trie storage nodes", storageTries.Size(), storageTries.Count()},
 		{"Key-Value store", "Verkle trie nodes", verkleTries.Size(), verkleTries.Count()},
@@ -0,0 +1,160 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/btreedb"
+	"github.com/ethereum/go-ethereum/ethdb/leveldb"
+	"github.com/ethereum/go-ethereum/ethdb/pebble"
+)
+
+const (
+	dbPebble  = "pebble"
+	dbLeveldb = "leveldb"
+	dbBTree   = "btree"
+
+	// dbDefault is the engine used for new databases if none is requested.
+	dbDefault = dbPebble
+)
+
+// Engine describes a key-value storage backend selectable via the db.engine
+// option. Additional backends are made available by registering them with
+// RegisterEngine, typically from an init function of the implementing package.
+// Every backend is expected to pass the ethdb/dbtest conformance suite.
+type Engine struct {
+	// Name is the identifier of the engine, as used in the db.engine option.
+	Name string
+
+	// Detect reports whether the given directory holds a database created by
+	// the engine. Detection must be unambiguous across all registered engines.
+	Detect func(dir string) bool
+
+	// Open opens the database in the directory given by the options, creating
+	// it if it doesn't exist yet (unless opened read-only).
+	Open func(o OpenOptions) (ethdb.KeyValueStore, error)
+}
+
+var (
+	enginesLock sync.RWMutex
+	engines     []Engine
+)
+
+func init() {
+	RegisterEngine(Engine{
+		Name: dbPebble,
+		Detect: func(dir string) bool {
+			if _, err := os.Stat(filepath.Join(dir, "CURRENT")); err != nil {
+				return false
+			}
+			matches, err := filepath.Glob(filepath.Join(dir, "OPTIONS*"))
+			if err != nil {
+				panic(err) // only possible if the pattern is malformed
+			}
+			return len(matches) > 0
+		},
+		Open: func(o OpenOptions) (ethdb.KeyValueStore, error) {
+			return pebble.New(o.Directory, o.Cache, o.Handles, o.Namespace, o.ReadOnly, o.Ephemeral)
+		},
+	})
+	RegisterEngine(Engine{
+		Name: dbLeveldb,
+		Detect: func(dir string) bool {
+			if _, err := os.Stat(filepath.Join(dir, "CURRENT")); err != nil {
+				return false
+			}
+			matches, err := filepath.Glob(filepath.Join(dir, "OPTIONS*"))
+			if err != nil {
+				panic(err) // only possible if the pattern is malformed
+			}
+			return len(matches) == 0
+		},
+		Open: func(o OpenOptions) (ethdb.KeyValueStore, error) {
+			return leveldb.New(o.Directory, o.Cache, o.Handles, o.Namespace, o.ReadOnly)
+		},
+	})
+	RegisterEngine(Engine{
+		Name:   dbBTree,
+		Detect: btreedb.Exists,
+		Open: func(o OpenOptions) (ethdb.KeyValueStore, error) {
+			return btreedb.New(o.Directory, o.ReadOnly, o.Ephemeral)
+		},
+	})
+}
+
+// RegisterEngine makes a storage engine available under its name. It panics if
+// an engine with the same name is already registered.
+func RegisterEngine(engine Engine) {
+	enginesLock.Lock()
+	defer enginesLock.Unlock()
+
+	if engine.Name == "" || engine.Detect == nil || engine.Open == nil {
+		panic("incomplete database engine definition")
+	}
+	for _, e := range engines {
+		if e.Name == engine.Name {
+			panic(fmt.Sprintf("database engine %q registered twice", engine.Name))
+		}
+	}
+	engines = append(engines, engine)
+}
+
+// DatabaseEngines returns the names of all registered storage engines in the
+// order of their registration.
+func DatabaseEngines() []string {
+	enginesLock.RLock()
+	defer enginesLock.RUnlock()
+
+	names := make([]string, len(engines))
+	for i, e := range engines {
+		names[i] = e.Name
+	}
+	return names
+}
+
+// lookupEngine retrieves a registered storage engine by name.
+func lookupEngine(name string) (Engine, bool) {
+	enginesLock.RLock()
+	defer enginesLock.RUnlock()
+
+	for _, e := range engines {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return Engine{}, false
+}
+
+// PreexistingDatabase checks the given data directory whether a database is already
+// instantiated at that location, and if so, returns the type of database (or the
+// empty string).
+func PreexistingDatabase(path string) string {
+	enginesLock.RLock()
+	defer enginesLock.RUnlock()
+
+	for _, e := range engines {
+		if e.Detect(path) {
+			return e.Name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// Tests that every registered engine can create a database, is detected when
+// reopening it, and that a conflicting engine choice is rejected.
+func TestDatabaseEngines(t *testing.T) {
+	for _, name := range DatabaseEngines() {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			if have := PreexistingDatabase(dir); have != "" {
+				t.Fatalf("detected engine %q in empty directory", have)
+			}
+			db, err := Open(OpenOptions{Type: name, Directory: dir, Ephemeral: true})
+			if err != nil {
+				t.Fatalf("failed to create database: %v", err)
+			}
+			if err := db.Put([]byte("key"), []byte("value")); err != nil {
+				t.Fatalf("failed to write: %v", err)
+			}
+			db.Close()
+
+			if have := PreexistingDatabase(dir); have != name {
+				t.Fatalf("detected wrong engine: have %q, want %q", have, name)
+			}
+			for _, other := range DatabaseEngines() {
+				if other == name {
+					continue
+				}
+				if db, err := Open(OpenOptions{Type: other, Directory: dir}); err == nil {
+					db.Close()
+					t.Fatalf("opened %s database as %s", name, other)
+				}
+			}
+			db, err = Open(OpenOptions{Directory: dir})
+			if err != nil {
+				t.Fatalf("failed to reopen database: %v", err)
+			}
+			defer db.Close()
+
+			if val, err := db.Get([]byte("key")); err != nil || !bytes.Equal(val, []byte("value")) {
+				t.Fatalf("unexpected value after reopen: %q, %v", val, err)
+			}
+		})
+	}
+}
+
+func TestRegisterEngineDuplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("duplicate engine registration did not panic")
+		}
+	}()
+	RegisterEngine(Engine{
+		Name:   dbPebble,
+		Detect: func(string) bool { return false },
+		Open:   func(OpenOptions) (ethdb.KeyValueStore, error) { return nil, nil },
+	})
+}
@@ -0,0 +1,155 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/pebble"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// replicaRefreshInterval is the time interval between the catch-ups of the
+// replica database with the primary.
+const replicaRefreshInterval = time.Second
+
+// ReplicaStore is a read-only key-value store following the writes of a database
+// maintained by another process, the primary.
+type ReplicaStore interface {
+	ethdb.KeyValueStore
+
+	// CatchUp refreshes the view on the primary database to follow the writes
+	// since the last catch-up.
+	CatchUp() error
+}
+
+// replicadb is a read-only database following the key-value store and the chain
+// freezer of a live primary, catching up with the primary periodically.
+type replicadb struct {
+	*freezerdb
+	store   ReplicaStore
+	freezer *Freezer
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewReplicaDatabase creates a read-only high level database on top of the given
+// replica key-value store and the chain freezer of the primary. The passed ancient
+// indicates the path of root ancient directory of the primary.
+func NewReplicaDatabase(db ReplicaStore, ancient string, namespace string) (ethdb.Database, error) {
+	dir := resolveChainFreezerDir(ancient)
+	if !common.FileExist(dir) {
+		return nil, fmt.Errorf("chain freezer is not found in %s", ancient)
+	}
+	freezer, err := newReplicaFreezer(dir, namespace, freezerTableSize, chainFreezerNoSnappy)
+	if err != nil {
+		return nil, err
+	}
+	rdb := &replicadb{
+		freezerdb: &freezerdb{
+			KeyValueStore: db,
+			chainFreezer: &chainFreezer{
+				AncientStore: freezer,
+				quit:         make(chan struct{}),
+				trigger:      make(chan chan struct{}),
+			},
+			readOnly:    true,
+			ancientRoot: ancient,
+		},
+		store:   db,
+		freezer: freezer,
+		quit:    make(chan struct{}),
+	}
+	rdb.wg.Add(1)
+	go rdb.follow()
+	return rdb, nil
+}
+
+// openReplicaDatabase opens the pebble database and the chain freezer maintained
+// by another process as a replica.
+func openReplicaDatabase(o OpenOptions) (ethdb.Database, error) {
+	if PreexistingDatabase(o.Directory) != dbPebble {
+		return nil, fmt.Errorf("no pebble database is found in %s, replica requires an existing pebble database", o.Directory)
+	}
+	if len(o.AncientsDirectory) == 0 {
+		return nil, errors.New("replica requires the ancient directory")
+	}
+	kvdb, err := pebble.NewReplica(o.Directory, o.Cache, o.Handles)
+	if err != nil {
+		return nil, err
+	}
+	db, err := NewReplicaDatabase(kvdb, o.AncientsDirectory, o.Namespace)
+	if err != nil {
+		kvdb.Close()
+		return nil, err
+	}
+	log.Info("Opened replica database", "database", o.Directory, "ancient", o.AncientsDirectory)
+	return db, nil
+}
+
+// Close stops following the primary and releases the replica database.
+func (db *replicadb) Close() error {
+	select {
+	case <-db.quit:
+	default:
+		close(db.quit)
+	}
+	db.wg.Wait()
+	return db.freezerdb.Close()
+}
+
+// follow catches up with the primary periodically until the database is closed.
+func (db *replicadb) follow() {
+	defer db.wg.Done()
+
+	var (
+		ticker = time.NewTicker(replicaRefreshInterval)
+		warned time.Time
+	)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// The failures are mostly transient, e.g. the files are deleted by
+			// the primary during the catch-up. Warn about the persistent ones.
+			if err := db.refresh(); err != nil && time.Since(warned) > time.Minute {
+				log.Warn("Failed to catch up with the primary database", "err", err)
+				warned = time.Now()
+			}
+		case <-db.quit:
+			return
+		}
+	}
+}
+
+// refresh catches up with the primary database. The primary moves the chain
+// segments into the freezer before deleting them from the key-value store, the
+// freezer is refreshed after the key-value store to ensure the deleted items are
+// visible in the freezer.
+func (db *replicadb) refresh() error {
+	if err := db.store.CatchUp(); err != nil {
+		return err
+	}
+	return db.freezer.refresh()
+}
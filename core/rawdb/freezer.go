@@ -67,6 +67,7 @@ type Freezer struct {
 	writeBatch *freezerBatch
 
 	readonly     bool
+	replica      bool                     // Whether the freezer follows the one maintained by another process
 	tables       map[string]*freezerTable // Data tables for storing everything
 	instanceLock *flock.Flock             // File-system lock to prevent double opens
 	closeOnce    sync.Once
@@ -78,6 +79,12 @@ type Freezer struct {
 // The 'tables' argument defines the data tables. If the value of a map
 // entry is true, snappy compression is disabled for the table.
 func NewFreezer(datadir string, namespace string, readonly bool, maxTableSize uint32, tables map[string]bool) (*Freezer, error) {
+	return newFreezer(datadir, namespace, readonly, false, maxTableSize, tables)
+}
+
+// newFreezer creates a freezer instance, which is opened as a replica of the
+// freezer maintained by another process if the replica flag is set.
+func newFreezer(datadir string, namespace string, readonly bool, replica bool, maxTableSize uint32, tables map[string]bool) (*Freezer, error) {
 	// Create the initial freezer object
 	var (
 		readMeter  = metrics.NewRegisteredMeter(namespace+"ancient/read", nil)
@@ -97,26 +104,34 @@ func NewFreezer(datadir string, namespace string, readonly bool, maxTableSize ui
 	}
 	// Leveldb uses LOCK as the filelock filename. To prevent the
 	// name collision, we use FLOCK as the lock name.
+	//
+	// The replica doesn't acquire the lock held by the primary, the files are
+	// only read.
 	lock := flock.New(flockFile)
-	tryLock := lock.TryLock
-	if readonly {
-		tryLock = lock.TryRLock
-	}
-	if locked, err := tryLock(); err != nil {
-		return nil, err
-	} else if !locked {
-		return nil, errors.New("locking failed")
+	if replica {
+		readonly = true
+	} else {
+		tryLock := lock.TryLock
+		if readonly {
+			tryLock = lock.TryRLock
+		}
+		if locked, err := tryLock(); err != nil {
+			return nil, err
+		} else if !locked {
+			return nil, errors.New("locking failed")
+		}
 	}
 	// Open all the supported data tables
 	freezer := &Freezer{
 		readonly:     readonly,
+		replica:      replica,
 		tables:       make(map[string]*freezerTable),
 		instanceLock: lock,
 	}
 
 	// Create the tables.
 	for name, disableSnappy := range tables {
-		table, err := newTable(datadir, name, readMeter, writeMeter, sizeGauge, maxTableSize, disableSnappy, readonly)
+		table, err := openTable(datadir, name, readMeter, writeMeter, sizeGauge, maxTableSize, disableSnappy, readonly, replica)
 		if err != nil {
 			for _, table := range freezer.tables {
 				table.Close()
@@ -127,7 +142,11 @@ func NewFreezer(datadir string, namespace string, readonly bool, maxTableSize ui
 		freezer.tables[name] = table
 	}
 	var err error
-	if freezer.readonly {
+	if freezer.replica {
+		// The primary might be appending the items, align the tables with
+		// the items available in all of them.
+		err = freezer.refresh()
+	} else if freezer.readonly {
 		// In readonly mode only validate, don't truncate.
 		// validate also sets `freezer.frozen`.
 		err = freezer.validate()
@@ -146,7 +165,7 @@ func NewFreezer(datadir string, namespace string, readonly bool, maxTableSize ui
 	// Create the write batch.
 	freezer.writeBatch = newFreezerBatch(freezer)
 
-	log.Info("Opened ancient database", "database", datadir, "readonly", readonly)
+	log.Info("Opened ancient database", "database", datadir, "readonly", readonly, "replica", replica)
 	return freezer, nil
 }
 
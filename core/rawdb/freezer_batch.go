@@ -21,7 +21,6 @@ import (
 
 	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/rlp"
-	"github.com/golang/snappy"
 )
 
 // This is the maximum amount of data that will be buffered in memory
@@ -84,7 +83,7 @@ func (batch *freezerBatch) commit() (item uint64, writeSize int64, err error) {
 type freezerTableBatch struct {
 	t *freezerTable
 
-	sb          *snappyBuffer
+	cb          *compressBuffer
 	encBuffer   writeBuffer
 	dataBuffer  []byte
 	indexBuffer []byte
@@ -95,8 +94,8 @@ type freezerTableBatch struct {
 // newBatch creates a new batch for the freezer table.
 func (t *freezerTable) newBatch() *freezerTableBatch {
 	batch := &freezerTableBatch{t: t}
-	if !t.noCompression {
-		batch.sb = new(snappyBuffer)
+	if t.codec != nil {
+		batch.cb = &compressBuffer{codec: t.codec}
 	}
 	batch.reset()
 	return batch
@@ -124,8 +123,8 @@ func (batch *freezerTableBatch) Append(item uint64, data interface{}) error {
 		return err
 	}
 	encItem := batch.encBuffer.data
-	if batch.sb != nil {
-		encItem = batch.sb.compress(encItem)
+	if batch.cb != nil {
+		encItem = batch.cb.compress(encItem)
 	}
 	return batch.appendItem(encItem)
 }
@@ -139,8 +138,8 @@ func (batch *freezerTableBatch) AppendRaw(item uint64, blob []byte) error {
 	}
 
 	encItem := blob
-	if batch.sb != nil {
-		encItem = batch.sb.compress(blob)
+	if batch.cb != nil {
+		encItem = batch.cb.compress(blob)
 	}
 	return batch.appendItem(encItem)
 }
@@ -216,28 +215,18 @@ func (batch *freezerTableBatch) commit() error {
 	return nil
 }
 
-// snappyBuffer writes snappy in block format, and can be reused. It is
-// reset when WriteTo is called.
-type snappyBuffer struct {
-	dst []byte
+// compressBuffer compresses the items with the codec of the table, and can be
+// reused across the items.
+type compressBuffer struct {
+	codec itemCodec
+	dst   []byte
 }
 
-// compress snappy-compresses the data.
-func (s *snappyBuffer) compress(data []byte) []byte {
-	// The snappy library does not care what the capacity of the buffer is,
-	// but only checks the length. If the length is too small, it will
-	// allocate a brand new buffer.
-	// To avoid that, we check the required size here, and grow the size of the
-	// buffer to utilize the full capacity.
-	if n := snappy.MaxEncodedLen(len(data)); len(s.dst) < n {
-		if cap(s.dst) < n {
-			s.dst = make([]byte, n)
-		}
-		s.dst = s.dst[:n]
-	}
-
-	s.dst = snappy.Encode(s.dst, data)
-	return s.dst
+// compress compresses the data, the returned slice is only valid until the
+// next invocation.
+func (c *compressBuffer) compress(data []byte) []byte {
+	c.dst = c.codec.encode(c.dst, data)
+	return c.dst
 }
 
 // writeBuffer implements io.Writer for a byte slice.
@@ -0,0 +1,321 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// FreezerCodec is the compression algorithm applied to the items of a compressed
+// freezer table. The codec of each table is recorded in its metadata, the tables
+// created before the codecs were introduced are all snappy compressed.
+type FreezerCodec uint8
+
+const (
+	FreezerCodecSnappy   FreezerCodec = iota // Snappy block compression, the default
+	FreezerCodecZstd                         // Zstandard compression
+	FreezerCodecZstdDict                     // Zstandard compression with a dictionary trained on the table
+)
+
+// freezerDictID is the identifier of the dictionary referenced by the zstd frames.
+const freezerDictID = 1
+
+const (
+	dictSegmentSize = 128 // length of the segments the dictionary is assembled from
+	dictDmerSize    = 8   // length of the substrings scoring the segments
+	dictPasses      = 4   // number of times the epochs are expected to be visited
+)
+
+// String implements fmt.Stringer.
+func (c FreezerCodec) String() string {
+	switch c {
+	case FreezerCodecSnappy:
+		return "snappy"
+	case FreezerCodecZstd:
+		return "zstd"
+	case FreezerCodecZstdDict:
+		return "zstd-dict"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(c))
+	}
+}
+
+// ParseFreezerCodec parses the codec from its name.
+func ParseFreezerCodec(name string) (FreezerCodec, error) {
+	for _, codec := range []FreezerCodec{FreezerCodecSnappy, FreezerCodecZstd, FreezerCodecZstdDict} {
+		if codec.String() == name {
+			return codec, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown freezer codec %q", name)
+}
+
+// fileKind returns the letter marking the data and index files of the tables
+// compressed by the codec. The files of different codecs are distinguished so
+// that they can coexist while a table is being migrated.
+func (c FreezerCodec) fileKind() string {
+	switch c {
+	case FreezerCodecZstd:
+		return "z"
+	case FreezerCodecZstdDict:
+		return "d"
+	default:
+		return "c"
+	}
+}
+
+// itemCodec compresses and decompresses the items of a freezer table.
+type itemCodec interface {
+	// encode compresses the item, the provided buffer may be reused for the output.
+	encode(dst, src []byte) []byte
+
+	// decode decompresses the item.
+	decode(src []byte) ([]byte, error)
+
+	// decodedLen returns the length of the decompressed item.
+	decodedLen(src []byte) (int, error)
+
+	// close releases the resources held by the codec.
+	close() error
+}
+
+// newItemCodec constructs the item codec of the specified algorithm, along with
+// the dictionary if it's required.
+func newItemCodec(codec FreezerCodec, dict []byte) (itemCodec, error) {
+	switch codec {
+	case FreezerCodecSnappy:
+		return snappyCodec{}, nil
+	case FreezerCodecZstd:
+		return newZstdCodec(nil)
+	case FreezerCodecZstdDict:
+		if len(dict) == 0 {
+			return nil, fmt.Errorf("missing dictionary for codec %v", codec)
+		}
+		return newZstdCodec(dict)
+	default:
+		return nil, fmt.Errorf("unknown freezer codec %d", uint8(codec))
+	}
+}
+
+// snappyCodec compresses the items in snappy block format.
+type snappyCodec struct{}
+
+func (snappyCodec) encode(dst, src []byte) []byte {
+	// The snappy library does not care what the capacity of the buffer is,
+	// but only checks the length. If the length is too small, it will
+	// allocate a brand new buffer.
+	// To avoid that, we check the required size here, and grow the size of the
+	// buffer to utilize the full capacity.
+	if n := snappy.MaxEncodedLen(len(src)); len(dst) < n {
+		if cap(dst) < n {
+			dst = make([]byte, n)
+		}
+		dst = dst[:n]
+	}
+	return snappy.Encode(dst, src)
+}
+
+func (snappyCodec) decode(src []byte) ([]byte, error) {
+	return snappy.Decode(nil, src)
+}
+
+func (snappyCodec) decodedLen(src []byte) (int, error) {
+	return snappy.DecodedLen(src)
+}
+
+func (snappyCodec) close() error {
+	return nil
+}
+
+// zstdCodec compresses the items as zstd frames, optionally with a raw content
+// dictionary. Both the encoder and decoder are safe for concurrent use, and
+// must be closed along with the table.
+type zstdCodec struct {
+	enc *zstd.Encoder
+	dec *zstd.Decoder
+}
+
+// newZstdCodec constructs the zstd codec with the optional dictionary.
+func newZstdCodec(dict []byte) (*zstdCodec, error) {
+	var (
+		eopts = []zstd.EOption{zstd.WithEncoderLevel(zstd.SpeedBetterCompression), zstd.WithEncoderConcurrency(1)}
+		dopts = []zstd.DOption{zstd.WithDecoderConcurrency(1)}
+	)
+	if len(dict) != 0 {
+		eopts = append(eopts, zstd.WithEncoderDictRaw(freezerDictID, dict))
+		dopts = append(dopts, zstd.WithDecoderDictRaw(freezerDictID, dict))
+	}
+	enc, err := zstd.NewWriter(nil, eopts...)
+	if err != nil {
+		return nil, err
+	}
+	dec, err := zstd.NewReader(nil, dopts...)
+	if err != nil {
+		enc.Close()
+		return nil, err
+	}
+	return &zstdCodec{enc: enc, dec: dec}, nil
+}
+
+func (c *zstdCodec) encode(dst, src []byte) []byte {
+	return c.enc.EncodeAll(src, dst[:0])
+}
+
+func (c *zstdCodec) decode(src []byte) ([]byte, error) {
+	return c.dec.DecodeAll(src, nil)
+}
+
+func (c *zstdCodec) decodedLen(src []byte) (int, error) {
+	var header zstd.Header
+	if err := header.Decode(src); err != nil {
+		return 0, err
+	}
+	if header.HasFCS {
+		return int(header.FrameContentSize), nil
+	}
+	// The content size is not recorded in the frame, fall back to decoding.
+	data, err := c.decode(src)
+	if err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// close releases the background goroutines of the encoder and decoder.
+func (c *zstdCodec) close() error {
+	c.dec.Close()
+	return c.enc.Close()
+}
+
+// trainDictionary trains a raw content dictionary of the given size limit on the
+// samples, following the COVER algorithm of the zstd dictionary builder. The
+// samples are split into epochs, and from each epoch the segment is picked whose
+// d-mers (substrings of a fixed length) occur in the most samples. The d-mers of
+// a picked segment are not scored again, so the dictionary covers as much of the
+// content shared between the items as possible, e.g. the RLP framing and the
+// frequently used addresses and topics, which the zstd matcher can reference
+// instead of repeating it in every compressed item.
+//
+// The segments are placed from the end of the dictionary backwards in the order
+// they are picked, as the content closer to the end is cheaper to reference.
+func trainDictionary(samples [][]byte, size int) []byte {
+	// Concatenate the samples and count the number of samples each d-mer occurs
+	// in. The d-mers spanning the sample boundaries are left out.
+	var (
+		data  []byte
+		valid []bool
+		freqs = make(map[uint64]uint32)
+		seen  = make(map[uint64]struct{})
+	)
+	for _, sample := range samples {
+		clear(seen)
+		for i := range sample {
+			ok := i+dictDmerSize <= len(sample)
+			if ok {
+				dmer := binary.LittleEndian.Uint64(sample[i:])
+				if _, dup := seen[dmer]; !dup {
+					seen[dmer] = struct{}{}
+					freqs[dmer]++
+				}
+			}
+			valid = append(valid, ok)
+		}
+		data = append(data, sample...)
+	}
+	// Split the content into epochs, each of them is visited in turn until the
+	// dictionary is full or none of them has anything left to contribute.
+	epochs := max(size/dictSegmentSize/dictPasses, 1)
+	if len(data)/epochs < dictSegmentSize {
+		epochs = max(len(data)/dictSegmentSize, 1)
+	}
+	var (
+		epochSize = len(data) / epochs
+		dict      = make([]byte, size)
+		tail      = size
+	)
+	for epoch, idle := 0, 0; tail > 0 && idle < epochs; epoch = (epoch + 1) % epochs {
+		begin, end, score := bestSegment(data, valid, freqs, epoch*epochSize, (epoch+1)*epochSize)
+		if score == 0 {
+			idle++
+			continue
+		}
+		idle = 0
+
+		// Exclude the d-mers of the segment from the further scoring.
+		for i := begin; i < end; i++ {
+			if valid[i] {
+				delete(freqs, binary.LittleEndian.Uint64(data[i:]))
+			}
+		}
+		segment := data[begin : end-1+dictDmerSize]
+		if len(segment) > tail {
+			segment = segment[len(segment)-tail:]
+		}
+		tail -= copy(dict[tail-len(segment):], segment)
+	}
+	return dict[tail:]
+}
+
+// bestSegment returns the range of the d-mers within [begin, end) starting the
+// segment with the highest score, the sum of the frequencies of the distinct
+// d-mers in it. The d-mers without any frequency are trimmed from both sides.
+func bestSegment(data []byte, valid []bool, freqs map[uint64]uint32, begin, end int) (int, int, uint64) {
+	var (
+		window = dictSegmentSize - dictDmerSize + 1 // number of d-mers in a segment
+		active = make(map[uint64]int)               // occurrences of the d-mers in the window
+		score  uint64
+		best   uint64
+
+		bestBegin, bestEnd int
+	)
+	for i := begin; i < end; i++ {
+		if valid[i] {
+			dmer := binary.LittleEndian.Uint64(data[i:])
+			if active[dmer] == 0 {
+				score += uint64(freqs[dmer])
+			}
+			active[dmer]++
+		}
+		if j := i - window; j >= begin && valid[j] {
+			dmer := binary.LittleEndian.Uint64(data[j:])
+			if active[dmer]--; active[dmer] == 0 {
+				delete(active, dmer)
+				score -= uint64(freqs[dmer])
+			}
+		}
+		if score > best {
+			best, bestBegin, bestEnd = score, max(i-window+1, begin), i+1
+		}
+	}
+	if best == 0 {
+		return 0, 0, 0
+	}
+	scored := func(i int) bool {
+		return valid[i] && freqs[binary.LittleEndian.Uint64(data[i:])] > 0
+	}
+	for !scored(bestBegin) {
+		bestBegin++
+	}
+	for !scored(bestEnd - 1) {
+		bestEnd--
+	}
+	return bestBegin, bestEnd, best
+}
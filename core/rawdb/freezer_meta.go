@@ -17,6 +17,7 @@
 package rawdb
 
 import (
+	"fmt"
 	"io"
 	"os"
 
@@ -24,7 +25,12 @@ import (
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
-const freezerVersion = 1 // The initial version tag of freezer table metadata
+const (
+	freezerVersionInitial = 1 // The initial version tag of freezer table metadata
+	freezerVersionCodec   = 2 // The version tag of the metadata recording the compression codec
+
+	freezerVersion = freezerVersionCodec // The version tag of the newly written metadata
+)
 
 // freezerTableMeta wraps all the metadata of the freezer table.
 type freezerTableMeta struct {
@@ -36,6 +42,15 @@ type freezerTableMeta struct {
 	// plus the number of items hidden in the table, so it should never
 	// be lower than the "actual tail".
 	VirtualTail uint64
+
+	// Codec is the compression algorithm applied to the items, only meaningful
+	// for compressed tables. It's omitted for the default snappy compression,
+	// keeping the metadata compatible with the legacy tables.
+	Codec FreezerCodec `rlp:"optional"`
+
+	// Dictionary is the raw compression dictionary trained on the table items,
+	// only available for the codecs relying on it.
+	Dictionary []byte `rlp:"optional"`
 }
 
 // newMetadata initializes the metadata object with the given virtual tail.
@@ -57,6 +72,9 @@ func readMetadata(file *os.File) (*freezerTableMeta, error) {
 	if err := rlp.Decode(file, &meta); err != nil {
 		return nil, err
 	}
+	if meta.Version > freezerVersion {
+		return nil, fmt.Errorf("unsupported freezer table metadata version %d", meta.Version)
+	}
 	return &meta, nil
 }
 
@@ -107,3 +125,21 @@ func loadMetadata(file *os.File, tail uint64) (*freezerTableMeta, error) {
 	}
 	return m, nil
 }
+
+// readCodec reads the compression codec of the freezer table from the given
+// metadata file. The default snappy codec is returned if the metadata is not
+// yet initialized.
+func readCodec(file *os.File) (FreezerCodec, []byte, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return 0, nil, err
+	}
+	if stat.Size() == 0 {
+		return FreezerCodecSnappy, nil, nil
+	}
+	m, err := readMetadata(file)
+	if err != nil {
+		return 0, nil, err
+	}
+	return m.Codec, m.Dictionary, nil
+}
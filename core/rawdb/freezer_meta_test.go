@@ -19,6 +19,8 @@ package rawdb
 import (
 	"os"
 	"testing"
+
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 func TestReadWriteFreezerTableMeta(t *testing.T) {
@@ -60,3 +62,30 @@ func TestInitializeFreezerTableMeta(t *testing.T) {
 		t.Fatalf("Unexpected virtual tail field")
 	}
 }
+
+func TestFreezerTableMetaVersion(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "*")
+	if err != nil {
+		t.Fatalf("Failed to create file %v", err)
+	}
+	defer f.Close()
+
+	// Metadata written before the codecs were introduced is still readable
+	if err := rlp.Encode(f, []interface{}{uint16(freezerVersionInitial), uint64(100)}); err != nil {
+		t.Fatalf("Failed to write metadata %v", err)
+	}
+	meta, err := readMetadata(f)
+	if err != nil {
+		t.Fatalf("Failed to read legacy metadata %v", err)
+	}
+	if meta.Version != freezerVersionInitial || meta.VirtualTail != 100 || meta.Codec != FreezerCodecSnappy {
+		t.Fatalf("Unexpected legacy metadata %+v", meta)
+	}
+	// Metadata of a future version is rejected
+	if err := writeMetadata(f, &freezerTableMeta{Version: freezerVersion + 1}); err != nil {
+		t.Fatalf("Failed to write metadata %v", err)
+	}
+	if _, err := readMetadata(f); err == nil {
+		t.Fatal("Future metadata version accepted")
+	}
+}
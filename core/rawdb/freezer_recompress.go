@@ -0,0 +1,286 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+const (
+	// dictionarySize is the size limit of the dictionary trained on the table.
+	dictionarySize = 112 * 1024
+
+	// dictionarySamples is the number of items sampled for training the dictionary.
+	dictionarySamples = 1024
+
+	// dictionaryTrainingSize is the size limit of the samples the dictionary is
+	// trained on, the items are truncated to their share of it.
+	dictionaryTrainingSize = 4 * 1024 * 1024
+
+	// recompressBatchSize is the size limit of the items migrated at once.
+	recompressBatchSize = 16 * 1024 * 1024
+)
+
+// RecompressFreezerTable migrates the items of the specified compressed freezer
+// table to the given codec, returning the table size before and after. The passed
+// ancient indicates the path of root ancient directory, the freezer must not be
+// opened by anyone else during the migration.
+//
+// The migrated items are written into the files dedicated to the new codec, next
+// to the existing ones, and the switch is committed by atomically replacing the
+// metadata of the table. The files of the replaced codec are deleted afterwards.
+// The table remains readable if the migration is interrupted at any point, with
+// the leftover files cleaned up in the next migration.
+func RecompressFreezerTable(ancient string, freezerName string, tableName string, codec FreezerCodec) (uint64, uint64, error) {
+	path, noSnappy, err := resolveFreezerTable(ancient, freezerName, tableName)
+	if err != nil {
+		return 0, 0, err
+	}
+	if noSnappy {
+		return 0, 0, fmt.Errorf("table %s is not compressed", tableName)
+	}
+	if codec > FreezerCodecZstdDict {
+		return 0, 0, fmt.Errorf("unknown freezer codec %d", uint8(codec))
+	}
+	src, err := newFreezerTable(path, tableName, false, true)
+	if err != nil {
+		return 0, 0, err
+	}
+	size, err := src.size()
+	if err != nil {
+		src.Close()
+		return 0, 0, err
+	}
+	// Clean up the leftovers of the interrupted migrations.
+	if err := removeCodecLeftovers(path, tableName, src.codecType); err != nil {
+		src.Close()
+		return 0, 0, err
+	}
+	if src.codecType == codec {
+		src.Close()
+		log.Info("Freezer table is already compressed by the codec", "table", tableName, "codec", codec)
+		return size, size, nil
+	}
+	// Migrate the items into a temporary table aside.
+	var (
+		start  = time.Now()
+		tmpdir = filepath.Join(path, tableName+".recompress")
+	)
+	nsize, err := migrateTable(src, tmpdir, codec)
+	if cerr := src.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	// Move the migrated data and index files next to the existing ones, and
+	// commit the switch by replacing the metadata.
+	entries, err := os.ReadDir(tmpdir)
+	if err != nil {
+		return 0, 0, err
+	}
+	metaName := fmt.Sprintf("%s.meta", tableName)
+	for _, entry := range entries {
+		if entry.Name() == metaName {
+			continue
+		}
+		if err := os.Rename(filepath.Join(tmpdir, entry.Name()), filepath.Join(path, entry.Name())); err != nil {
+			return 0, 0, err
+		}
+	}
+	if err := os.Rename(filepath.Join(tmpdir, metaName), filepath.Join(path, metaName)); err != nil {
+		return 0, 0, err
+	}
+	if err := removeCodecLeftovers(path, tableName, codec); err != nil {
+		return 0, 0, err
+	}
+	log.Info("Recompressed freezer table", "table", tableName, "codec", codec, "size", common.StorageSize(size), "new", common.StorageSize(nsize), "elapsed", common.PrettyDuration(time.Since(start)))
+	return size, nsize, nil
+}
+
+// migrateTable copies all the items of the source table into a new table in the
+// given directory compressed by the specified codec, returning the new size.
+func migrateTable(src *freezerTable, path string, codec FreezerCodec) (uint64, error) {
+	var (
+		tail = src.itemHidden.Load()
+		head = src.items.Load()
+		dict []byte
+		err  error
+	)
+	if codec == FreezerCodecZstdDict {
+		if dict, err = sampleDictionary(src, tail, head); err != nil {
+			return 0, err
+		}
+	}
+	dst, err := newTableAt(path, src.name, tail, codec, dict)
+	if err != nil {
+		return 0, err
+	}
+	defer dst.Close()
+
+	var (
+		start  = time.Now()
+		logged = time.Now()
+	)
+	for number := tail; number < head; {
+		items, err := src.RetrieveItems(number, head-number, recompressBatchSize)
+		if err != nil {
+			return 0, err
+		}
+		batch := dst.newBatch()
+		for _, item := range items {
+			if err := batch.AppendRaw(number, item); err != nil {
+				return 0, err
+			}
+			number++
+		}
+		if err := batch.commit(); err != nil {
+			return 0, err
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Recompressing freezer table", "table", src.name, "migrated", number-tail, "total", head-tail, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := dst.Sync(); err != nil {
+		return 0, err
+	}
+	return dst.size()
+}
+
+// resolveFreezerTable resolves the directory of the specified freezer table
+// along with the flag whether the table is compressed.
+func resolveFreezerTable(ancient string, freezerName string, tableName string) (string, bool, error) {
+	var (
+		path   string
+		tables map[string]bool
+	)
+	switch freezerName {
+	case ChainFreezerName:
+		path, tables = resolveChainFreezerDir(ancient), chainFreezerNoSnappy
+	case MerkleStateFreezerName, VerkleStateFreezerName:
+		path, tables = filepath.Join(ancient, freezerName), stateFreezerNoSnappy
+	case LogIndexFreezerName:
+		path, tables = filepath.Join(ancient, freezerName), logIndexFreezerNoSnappy
+	default:
+		return "", false, fmt.Errorf("unknown freezer, supported ones: %v", freezers)
+	}
+	noSnappy, exist := tables[tableName]
+	if !exist {
+		var names []string
+		for name := range tables {
+			names = append(names, name)
+		}
+		return "", false, fmt.Errorf("unknown table, supported ones: %v", names)
+	}
+	return path, noSnappy, nil
+}
+
+// newTableAt creates an empty compressed freezer table in the given directory,
+// in which the first item is numbered by tail.
+func newTableAt(path string, name string, tail uint64, codec FreezerCodec, dict []byte) (*freezerTable, error) {
+	if tail > uint64(^uint32(0)) {
+		return nil, fmt.Errorf("tail %d is out of range", tail)
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+	// The first index entry carries the number of the deleted items.
+	entry := indexEntry{filenum: 0, offset: uint32(tail)}
+	if err := os.WriteFile(filepath.Join(path, fmt.Sprintf("%s.%sidx", name, codec.fileKind())), entry.append(nil), 0644); err != nil {
+		return nil, err
+	}
+	meta, err := openFreezerFileForAppend(filepath.Join(path, fmt.Sprintf("%s.meta", name)))
+	if err != nil {
+		return nil, err
+	}
+	m := newMetadata(tail)
+	m.Codec, m.Dictionary = codec, dict
+	err = writeMetadata(meta, m)
+	if cerr := meta.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newTable(path, name, metrics.NilMeter{}, metrics.NilMeter{}, metrics.NilGauge{}, freezerTableSize, false, false)
+}
+
+// sampleDictionary trains the dictionary on the items evenly sampled from the
+// table.
+func sampleDictionary(t *freezerTable, tail, head uint64) ([]byte, error) {
+	if head == tail {
+		return nil, errors.New("no items to sample the dictionary from")
+	}
+	var (
+		step    = max((head-tail)/dictionarySamples, 1)
+		limit   = dictionaryTrainingSize / int(min(head-tail, dictionarySamples))
+		samples [][]byte
+	)
+	for number := tail + (head-tail-1)%step; number < head; number += step {
+		item, err := t.Retrieve(number)
+		if err != nil {
+			return nil, err
+		}
+		if len(item) > limit {
+			item = item[:limit]
+		}
+		samples = append(samples, item)
+	}
+	dict := trainDictionary(samples, dictionarySize)
+	if len(dict) == 0 {
+		return nil, errors.New("no shared content to train the dictionary on")
+	}
+	return dict, nil
+}
+
+// removeCodecLeftovers deletes the data and index files of the table which
+// belong to any codec other than the given one, along with the temporary
+// directory of the migration.
+func removeCodecLeftovers(path string, name string, keep FreezerCodec) error {
+	if err := os.RemoveAll(filepath.Join(path, name+".recompress")); err != nil {
+		return err
+	}
+	for _, codec := range []FreezerCodec{FreezerCodecSnappy, FreezerCodecZstd, FreezerCodecZstdDict} {
+		kind := codec.fileKind()
+		if kind == keep.fileKind() {
+			continue
+		}
+		files, err := filepath.Glob(filepath.Join(path, fmt.Sprintf("%s.*.%sdat", name, kind)))
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.Join(path, fmt.Sprintf("%s.%sidx", name, kind)))
+		for _, file := range files {
+			if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFreezerTableCodecs(t *testing.T) {
+	for _, codec := range []FreezerCodec{FreezerCodecSnappy, FreezerCodecZstd, FreezerCodecZstdDict} {
+		t.Run(codec.String(), func(t *testing.T) {
+			var (
+				path = t.TempDir()
+				name = "table"
+				dict []byte
+			)
+			if codec == FreezerCodecZstdDict {
+				dict = getChunk(1024, 0x01)
+			}
+			f, err := newTableAt(path, name, 0, codec, dict)
+			if err != nil {
+				t.Fatal(err)
+			}
+			writeChunks(t, f, 100, 512)
+			f.Close()
+
+			// Reopen the table, the codec should be resolved from the metadata.
+			f, err = newFreezerTable(path, name, false, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.codecType != codec {
+				t.Fatalf("Unexpected codec, want %v, got %v", codec, f.codecType)
+			}
+			if err := f.truncateTail(10); err != nil {
+				t.Fatal(err)
+			}
+			f.Close()
+
+			// The codec should survive the metadata updates.
+			f, err = newFreezerTable(path, name, false, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			if f.codecType != codec {
+				t.Fatalf("Unexpected codec after truncation, want %v, got %v", codec, f.codecType)
+			}
+			checkRetrieveError(t, f, map[uint64]error{9: errOutOfBounds})
+			for i := uint64(10); i < 100; i++ {
+				checkRetrieve(t, f, map[uint64][]byte{i: getChunk(512, int(i))})
+			}
+		})
+	}
+}
+
+func TestRecompressFreezerTable(t *testing.T) {
+	var (
+		ancient = t.TempDir()
+		path    = filepath.Join(ancient, MerkleStateFreezerName)
+		name    = stateHistoryAccountData
+	)
+	f, err := newFreezerTable(path, name, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeChunks(t, f, 100, 512)
+	if err := f.truncateTail(10); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	for _, codec := range []FreezerCodec{FreezerCodecZstdDict, FreezerCodecZstd, FreezerCodecSnappy} {
+		if _, _, err := RecompressFreezerTable(ancient, MerkleStateFreezerName, name, codec); err != nil {
+			t.Fatalf("Failed to recompress table with %v: %v", codec, err)
+		}
+		f, err := newFreezerTable(path, name, false, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.codecType != codec {
+			t.Fatalf("Unexpected codec, want %v, got %v", codec, f.codecType)
+		}
+		if f.items.Load() != 100 || f.itemHidden.Load() != 10 {
+			t.Fatalf("Unexpected table range, items %d, hidden %d", f.items.Load(), f.itemHidden.Load())
+		}
+		checkRetrieveError(t, f, map[uint64]error{9: errOutOfBounds})
+		for i := uint64(10); i < 100; i++ {
+			checkRetrieve(t, f, map[uint64][]byte{i: getChunk(512, int(i))})
+		}
+		f.Close()
+
+		// Only the files of the current codec should be retained.
+		for _, other := range []FreezerCodec{FreezerCodecSnappy, FreezerCodecZstd, FreezerCodecZstdDict} {
+			if other == codec {
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(path, fmt.Sprintf("%s.%sidx", name, other.fileKind()))); !os.IsNotExist(err) {
+				t.Fatalf("Unexpected leftover index of codec %v", other)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(path, name+".recompress")); !os.IsNotExist(err) {
+			t.Fatal("Unexpected leftover temporary directory")
+		}
+	}
+	// The uncompressed tables can't be migrated.
+	if _, _, err := RecompressFreezerTable(ancient, MerkleStateFreezerName, stateHistoryMeta, FreezerCodecZstd); err == nil {
+		t.Fatal("Unexpected migration of uncompressed table")
+	}
+}
+
+func TestTrainDictionary(t *testing.T) {
+	// Assemble the samples from a few shared fragments interleaved with unique
+	// content, which only the fragments are worth to be trained on.
+	var (
+		rng       = rand.New(rand.NewSource(1))
+		fragments = make([][]byte, 8)
+		samples   [][]byte
+	)
+	for i := range fragments {
+		fragments[i] = make([]byte, 96)
+		rng.Read(fragments[i])
+	}
+	for i := 0; i < 256; i++ {
+		var sample []byte
+		for j := 0; j < 4; j++ {
+			noise := make([]byte, 32)
+			rng.Read(noise)
+			sample = append(sample, noise...)
+			sample = append(sample, fragments[rng.Intn(len(fragments))]...)
+		}
+		samples = append(samples, sample)
+	}
+	dict := trainDictionary(samples, 4096)
+	if len(dict) == 0 || len(dict) > 4096 {
+		t.Fatalf("Unexpected dictionary size %d", len(dict))
+	}
+	for i, fragment := range fragments {
+		if !bytes.Contains(dict, fragment) {
+			t.Fatalf("Fragment %d missing from the dictionary", i)
+		}
+	}
+	// The dictionary should improve the compression of the similar items.
+	plain, err := newZstdCodec(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plain.close()
+	trained, err := newZstdCodec(dict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer trained.close()
+
+	var plainSize, trainedSize int
+	for _, sample := range samples {
+		plainSize += len(plain.encode(nil, sample))
+		trainedSize += len(trained.encode(nil, sample))
+	}
+	if trainedSize >= plainSize*3/4 {
+		t.Fatalf("Dictionary ineffective, compressed size %d, without dictionary %d", trainedSize, plainSize)
+	}
+}
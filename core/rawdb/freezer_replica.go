@@ -0,0 +1,155 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// newReplicaFreezer opens the freezer maintained by another process, the primary,
+// in read-only mode. The freezer keeps the view on the files at the moment, the
+// items appended or truncated by the primary are followed by refresh.
+func newReplicaFreezer(datadir string, namespace string, maxTableSize uint32, tables map[string]bool) (*Freezer, error) {
+	return newFreezer(datadir, namespace, true, true, maxTableSize, tables)
+}
+
+// refresh reloads the tables of the replica freezer, aligning the freezer with
+// the items available in all the tables.
+func (f *Freezer) refresh() error {
+	if !f.replica {
+		return errors.New("not a replica freezer")
+	}
+	f.writeLock.Lock()
+	defer f.writeLock.Unlock()
+
+	var (
+		head = uint64(math.MaxUint64)
+		tail = uint64(0)
+	)
+	if len(f.tables) == 0 {
+		head = 0
+	}
+	for _, table := range f.tables {
+		if err := table.refresh(); err != nil {
+			return err
+		}
+		head = min(head, table.items.Load())
+		tail = max(tail, table.itemHidden.Load())
+	}
+	f.frozen.Store(head)
+	f.tail.Store(tail)
+	return nil
+}
+
+// refresh reloads the boundaries of the replica table from the files. The data
+// file being appended by the primary might be ahead of the index, the items only
+// become visible once they are indexed.
+func (t *freezerTable) refresh() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// The index and metadata files are replaced by the primary when truncating
+	// the tail, reopen them for following.
+	idxName := fmt.Sprintf("%s.ridx", t.name)
+	if !t.noCompression {
+		idxName = fmt.Sprintf("%s.%sidx", t.name, t.codecType.fileKind())
+	}
+	index, err := openFreezerFileForReadOnly(filepath.Join(t.path, idxName))
+	if err != nil {
+		return err
+	}
+	meta, err := openFreezerFileForReadOnly(filepath.Join(t.path, fmt.Sprintf("%s.meta", t.name)))
+	if err != nil {
+		index.Close()
+		return err
+	}
+	if err := t.reload(index, meta); err != nil {
+		index.Close()
+		meta.Close()
+		return err
+	}
+	return nil
+}
+
+// reload loads the boundaries of the table from the given index and metadata
+// files, replacing the ones in use.
+func (t *freezerTable) reload(index *os.File, meta *os.File) error {
+	stat, err := index.Stat()
+	if err != nil {
+		return err
+	}
+	// The trailing entry might be partially written.
+	entries := stat.Size() / indexEntrySize
+	if entries == 0 {
+		return fmt.Errorf("index file(path: %s, name: %s) is empty", t.path, t.name)
+	}
+	var (
+		first  indexEntry
+		last   indexEntry
+		buffer = make([]byte, indexEntrySize)
+	)
+	if _, err := index.ReadAt(buffer, 0); err != nil {
+		return err
+	}
+	first.unmarshalBinary(buffer)
+
+	last = indexEntry{filenum: first.filenum}
+	if entries > 1 {
+		if _, err := index.ReadAt(buffer, (entries-1)*indexEntrySize); err != nil {
+			return err
+		}
+		last.unmarshalBinary(buffer)
+	}
+	hidden := uint64(first.offset)
+	if stat, err := meta.Stat(); err != nil {
+		return err
+	} else if stat.Size() > 0 {
+		m, err := readMetadata(meta)
+		if err != nil {
+			return err
+		}
+		hidden = max(hidden, m.VirtualTail)
+	}
+	// Open the newly referenced data files and release the deleted ones.
+	for num := first.filenum; num <= last.filenum; num++ {
+		if _, err := t.openFile(num, openFreezerFileForReadOnly); err != nil {
+			return err
+		}
+	}
+	t.releaseFilesBefore(first.filenum, false)
+	t.releaseFilesAfter(last.filenum, false)
+
+	if t.index != nil {
+		t.index.Close()
+	}
+	if t.meta != nil {
+		t.meta.Close()
+	}
+	t.index, t.meta = index, meta
+	t.head = t.files[last.filenum]
+	t.tailId, t.headId = first.filenum, last.filenum
+	t.headBytes = int64(last.offset)
+
+	t.itemOffset.Store(uint64(first.offset))
+	t.itemHidden.Store(hidden)
+	t.items.Store(uint64(first.offset) + uint64(entries-1))
+	return nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+func TestFreezerReplicaRefresh(t *testing.T) {
+	tables := map[string]bool{"raw": true, "snappy": false}
+	primary, dir := newFreezerForTesting(t, tables)
+	defer primary.Close()
+
+	item := func(i uint64) []byte {
+		return bytes.Repeat([]byte{byte(i)}, 200)
+	}
+	write := func(from, to uint64) {
+		t.Helper()
+		_, err := primary.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+			for i := from; i < to; i++ {
+				if err := op.AppendRaw("raw", i, item(i)); err != nil {
+					return err
+				}
+				if err := op.AppendRaw("snappy", i, item(i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal("ModifyAncients failed:", err)
+		}
+	}
+	write(0, 20)
+
+	// The replica opens the freezer without being blocked by the primary.
+	replica, err := newReplicaFreezer(dir, "", 2049, tables)
+	if err != nil {
+		t.Fatal("can't open replica freezer", err)
+	}
+	defer replica.Close()
+
+	checkAncientCount(t, replica, "raw", 20)
+	if _, err := replica.ModifyAncients(func(op ethdb.AncientWriteOp) error { return nil }); err == nil {
+		t.Fatal("Write into replica freezer is not rejected")
+	}
+	// The appended items become visible after refreshing, across data files.
+	write(20, 50)
+	checkAncientCount(t, replica, "raw", 20)
+	if err := replica.refresh(); err != nil {
+		t.Fatal("can't refresh replica freezer", err)
+	}
+	checkAncientCount(t, replica, "snappy", 50)
+	for i := uint64(0); i < 50; i++ {
+		for _, kind := range []string{"raw", "snappy"} {
+			blob, err := replica.Ancient(kind, i)
+			if err != nil || !bytes.Equal(blob, item(i)) {
+				t.Fatalf("Unexpected item %d in table %s: %x, %v", i, kind, blob, err)
+			}
+		}
+	}
+	// The items deleted by the primary disappear after refreshing.
+	if _, err := primary.TruncateTail(30); err != nil {
+		t.Fatal("can't truncate tail", err)
+	}
+	if err := replica.refresh(); err != nil {
+		t.Fatal("can't refresh replica freezer", err)
+	}
+	if tail, _ := replica.Tail(); tail != 30 {
+		t.Fatalf("Unexpected tail, want %d, got %d", 30, tail)
+	}
+	if _, err := replica.Ancient("raw", 10); err == nil {
+		t.Fatal("Deleted item is still retrievable")
+	}
+	if blob, err := replica.Ancient("raw", 40); err != nil || !bytes.Equal(blob, item(40)) {
+		t.Fatalf("Unexpected item: %x, %v", blob, err)
+	}
+	if _, err := primary.TruncateHead(45); err != nil {
+		t.Fatal("can't truncate head", err)
+	}
+	if err := replica.refresh(); err != nil {
+		t.Fatal("can't refresh replica freezer", err)
+	}
+	checkAncientCount(t, replica, "raw", 45)
+}
@@ -30,7 +30,6 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
-	"github.com/golang/snappy"
 )
 
 var (
@@ -98,8 +97,12 @@ type freezerTable struct {
 	// should never be lower than itemOffset.
 	itemHidden atomic.Uint64
 
-	noCompression bool // if true, disables snappy compression. Note: does not work retroactively
+	noCompression bool         // if true, disables compression. Note: does not work retroactively
+	codecType     FreezerCodec // compression algorithm of the items, only for compressed table
+	codec         itemCodec    // codec compressing the items, nil if compression is disabled
+	dict          []byte       // dictionary of the codec, retained in the metadata
 	readonly      bool
+	replica       bool   // Whether the table follows the one maintained by another process
 	maxFileSize   uint32 // Max file size for data-files
 	name          string
 	path          string
@@ -129,16 +132,17 @@ func newFreezerTable(path, name string, disableSnappy, readonly bool) (*freezerT
 // non-existent. Both files are truncated to the shortest common length to ensure
 // they don't go out of sync.
 func newTable(path string, name string, readMeter metrics.Meter, writeMeter metrics.Meter, sizeGauge metrics.Gauge, maxFilesize uint32, noCompression, readonly bool) (*freezerTable, error) {
+	return openTable(path, name, readMeter, writeMeter, sizeGauge, maxFilesize, noCompression, readonly, false)
+}
+
+// openTable opens a freezer table. If the replica flag is set, the table is
+// opened in read-only mode following the table maintained by another process,
+// without repairing the files.
+func openTable(path string, name string, readMeter metrics.Meter, writeMeter metrics.Meter, sizeGauge metrics.Gauge, maxFilesize uint32, noCompression, readonly, replica bool) (*freezerTable, error) {
 	// Ensure the containing directory exists and open the indexEntry file
 	if err := os.MkdirAll(path, 0755); err != nil {
 		return nil, err
 	}
-	var idxName string
-	if noCompression {
-		idxName = fmt.Sprintf("%s.ridx", name) // raw index file
-	} else {
-		idxName = fmt.Sprintf("%s.cidx", name) // compressed index file
-	}
 	var (
 		err   error
 		index *os.File
@@ -146,23 +150,46 @@ func newTable(path string, name string, readMeter metrics.Meter, writeMeter metr
 	)
 	if readonly {
 		// Will fail if table index file or meta file is not existent
-		index, err = openFreezerFileForReadOnly(filepath.Join(path, idxName))
-		if err != nil {
-			return nil, err
-		}
 		meta, err = openFreezerFileForReadOnly(filepath.Join(path, fmt.Sprintf("%s.meta", name)))
 		if err != nil {
 			return nil, err
 		}
 	} else {
-		index, err = openFreezerFileForAppend(filepath.Join(path, idxName))
+		meta, err = openFreezerFileForAppend(filepath.Join(path, fmt.Sprintf("%s.meta", name)))
 		if err != nil {
 			return nil, err
 		}
-		meta, err = openFreezerFileForAppend(filepath.Join(path, fmt.Sprintf("%s.meta", name)))
+	}
+	// Resolve the compression codec of the table, which determines the files
+	// holding the items.
+	var (
+		codecType FreezerCodec
+		codec     itemCodec
+		dict      []byte
+		idxName   = fmt.Sprintf("%s.ridx", name) // raw index file
+	)
+	if !noCompression {
+		codecType, dict, err = readCodec(meta)
+		if err == nil {
+			codec, err = newItemCodec(codecType, dict)
+		}
 		if err != nil {
+			meta.Close()
 			return nil, err
 		}
+		idxName = fmt.Sprintf("%s.%sidx", name, codecType.fileKind()) // compressed index file
+	}
+	if readonly {
+		index, err = openFreezerFileForReadOnly(filepath.Join(path, idxName))
+	} else {
+		index, err = openFreezerFileForAppend(filepath.Join(path, idxName))
+	}
+	if err != nil {
+		meta.Close()
+		if codec != nil {
+			codec.close()
+		}
+		return nil, err
 	}
 	// Create the table and repair any past inconsistency
 	tab := &freezerTable{
@@ -176,10 +203,18 @@ func newTable(path string, name string, readMeter metrics.Meter, writeMeter metr
 		path:          path,
 		logger:        log.New("database", path, "table", name),
 		noCompression: noCompression,
+		codecType:     codecType,
+		codec:         codec,
+		dict:          dict,
 		readonly:      readonly,
+		replica:       replica,
 		maxFileSize:   maxFilesize,
 	}
-	if err := tab.repair(); err != nil {
+	load := tab.repair
+	if replica {
+		load = tab.refresh
+	}
+	if err := load(); err != nil {
 		tab.Close()
 		return nil, err
 	}
@@ -517,7 +552,7 @@ func (t *freezerTable) truncateTail(items uint64) error {
 	}
 	// Update the virtual tail marker and hidden these entries in table.
 	t.itemHidden.Store(items)
-	if err := writeMetadata(t.meta, newMetadata(items)); err != nil {
+	if err := writeMetadata(t.meta, t.metadata(items)); err != nil {
 		return err
 	}
 	// Hidden items still fall in the current tail file, no data file
@@ -621,6 +656,12 @@ func (t *freezerTable) Close() error {
 	for _, f := range t.files {
 		doClose(f, false, true) // close but do not sync
 	}
+	// CHANGE(taiko): release the compression codec.
+	if t.codec != nil {
+		if err := t.codec.close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
 	t.index = nil
 	t.meta = nil
 	t.head = nil
@@ -639,7 +680,7 @@ func (t *freezerTable) openFile(num uint32, opener func(string) (*os.File, error
 		if t.noCompression {
 			name = fmt.Sprintf("%s.%04d.rdat", t.name, num)
 		} else {
-			name = fmt.Sprintf("%s.%04d.cdat", t.name, num)
+			name = fmt.Sprintf("%s.%04d.%sdat", t.name, num, t.codecType.fileKind())
 		}
 		f, err = opener(filepath.Join(t.path, name))
 		if err != nil {
@@ -650,6 +691,15 @@ func (t *freezerTable) openFile(num uint32, opener func(string) (*os.File, error
 	return f, err
 }
 
+// metadata returns the metadata of the table with the given virtual tail.
+func (t *freezerTable) metadata(tail uint64) *freezerTableMeta {
+	m := newMetadata(tail)
+	if !t.noCompression {
+		m.Codec, m.Dictionary = t.codecType, t.dict
+	}
+	return m
+}
+
 // releaseFile closes a file, and removes it from the open file cache.
 // Assumes that the caller holds the write lock
 func (t *freezerTable) releaseFile(num uint32) {
@@ -752,14 +802,14 @@ func (t *freezerTable) RetrieveItems(start, count, maxBytes uint64) ([][]byte, e
 		item := diskData[offset : offset+diskSize]
 		offset += diskSize
 		decompressedSize := diskSize
-		if !t.noCompression {
-			decompressedSize, _ = snappy.DecodedLen(item)
+		if t.codec != nil {
+			decompressedSize, _ = t.codec.decodedLen(item)
 		}
 		if i > 0 && maxBytes != 0 && uint64(outputSize+decompressedSize) > maxBytes {
 			break
 		}
-		if !t.noCompression {
-			data, err := snappy.Decode(nil, item)
+		if t.codec != nil {
+			data, err := t.codec.decode(item)
 			if err != nil {
 				return nil, err
 			}
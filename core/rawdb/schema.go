@@ -96,6 +96,18 @@ var (
 	// snapSyncStatusFlagKey flags that status of snap sync.
 	snapSyncStatusFlagKey = []byte("SnapSyncStatus")
 
+	// stateHistoryIndexHeadKey tracks the id of the latest indexed state history.
+	stateHistoryIndexHeadKey = []byte("LastStateHistoryIndex")
+
+	// stateHistoryPruneTargetKey tracks the tail target of the ongoing state
+	// history pruning, used to resume the pruning if it's interrupted.
+	stateHistoryPruneTargetKey = []byte("StateHistoryPruneTarget")
+
+	// traceIndexHeadKey and traceIndexTailKey track the range of blocks whose
+	// flat call traces are indexed.
+	traceIndexHeadKey = []byte("LastTraceIndex")
+	traceIndexTailKey = []byte("TraceIndexTail")
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`, used for indexes).
 	headerPrefix       = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
 	headerTDSuffix     = []byte("t") // headerPrefix + num (uint64 big endian) + hash + headerTDSuffix -> td
@@ -117,6 +129,18 @@ var (
 	TrieNodeStoragePrefix = []byte("O") // TrieNodeStoragePrefix + accountHash + hexPath -> trie node
 	stateIDPrefix         = []byte("L") // stateIDPrefix + state root -> state id
 
+	// State history indexes of path-based storage scheme.
+	StateHistoryAccountIndexPrefix = []byte("ma") // StateHistoryAccountIndexPrefix + address + state id (uint64 big endian) -> nil
+	StateHistoryStorageIndexPrefix = []byte("ms") // StateHistoryStorageIndexPrefix + address + slot hash + state id (uint64 big endian) -> nil
+
+	// Flat call trace indexes.
+	blockTracesPrefix       = []byte("tb") // blockTracesPrefix + num (uint64 big endian) + hash -> flat call traces
+	TraceAddressIndexPrefix = []byte("ta") // TraceAddressIndexPrefix + address + num (uint64 big endian) -> nil
+
+	// Log index of the canonical chain, built in sections by a chain indexer. The
+	// postings themselves are stored in the log index freezer.
+	logIndexSectionPrefix = []byte("gl") // logIndexSectionPrefix + section (uint64 big endian) -> section head + first postings item + sorted keys
+
 	// VerklePrefix is the database prefix for Verkle trie data, which includes:
 	// (a) Trie nodes
 	// (b) In-memory trie node journal
@@ -131,6 +155,9 @@ var (
 	// BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
 	BloomBitsIndexPrefix = []byte("iB")
 
+	// LogIndexTablePrefix is the data table of the log index chain indexer to track its progress
+	LogIndexTablePrefix = []byte("iL")
+
 	ChtPrefix           = []byte("chtRootV2-") // ChtPrefix + chtNum (uint64 big endian) -> trie root hash
 	ChtTablePrefix      = []byte("cht-")
 	ChtIndexTablePrefix = []byte("chtIndexV2-")
@@ -272,6 +299,44 @@ func stateIDKey(root common.Hash) []byte {
 	return append(stateIDPrefix, root.Bytes()...)
 }
 
+// accountHistoryIndexKey = StateHistoryAccountIndexPrefix + address + id (uint64 big endian)
+func accountHistoryIndexKey(address common.Address, id uint64) []byte {
+	buf := make([]byte, len(StateHistoryAccountIndexPrefix)+common.AddressLength+8)
+	n := copy(buf, StateHistoryAccountIndexPrefix)
+	n += copy(buf[n:], address.Bytes())
+	binary.BigEndian.PutUint64(buf[n:], id)
+	return buf
+}
+
+// storageHistoryIndexKey = StateHistoryStorageIndexPrefix + address + slotHash + id (uint64 big endian)
+func storageHistoryIndexKey(address common.Address, slot common.Hash, id uint64) []byte {
+	buf := make([]byte, len(StateHistoryStorageIndexPrefix)+common.AddressLength+common.HashLength+8)
+	n := copy(buf, StateHistoryStorageIndexPrefix)
+	n += copy(buf[n:], address.Bytes())
+	n += copy(buf[n:], slot.Bytes())
+	binary.BigEndian.PutUint64(buf[n:], id)
+	return buf
+}
+
+// blockTracesKey = blockTracesPrefix + num (uint64 big endian) + hash
+func blockTracesKey(number uint64, hash common.Hash) []byte {
+	return append(append(blockTracesPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
+// traceAddressIndexKey = TraceAddressIndexPrefix + address + num (uint64 big endian)
+func traceAddressIndexKey(address common.Address, number uint64) []byte {
+	buf := make([]byte, len(TraceAddressIndexPrefix)+common.AddressLength+8)
+	n := copy(buf, TraceAddressIndexPrefix)
+	n += copy(buf[n:], address.Bytes())
+	binary.BigEndian.PutUint64(buf[n:], number)
+	return buf
+}
+
+// logIndexSectionKey = logIndexSectionPrefix + section (uint64 big endian)
+func logIndexSectionKey(section uint64) []byte {
+	return append(logIndexSectionPrefix, encodeBlockNumber(section)...)
+}
+
 // accountTrieNodeKey = TrieNodeAccountPrefix + nodePath.
 func accountTrieNodeKey(path []byte) []byte {
 	return append(TrieNodeAccountPrefix, path...)
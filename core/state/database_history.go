@@ -0,0 +1,212 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/trie/trienode"
+	"github.com/ethereum/go-ethereum/triedb/pathdb"
+)
+
+// errHistoricStateImmutable is returned if a historical state is attempted to
+// be mutated in the underlying trie.
+var errHistoricStateImmutable = errors.New("historical state is immutable")
+
+// HistoricDB is an implementation of Database interface for accessing the
+// historical states of the path-based trie database, which are reconstructed
+// from the indexed state histories instead of the tries. The historical state
+// can be executed upon, but the mutations can't be hashed nor committed.
+type HistoricDB struct {
+	*CachingDB
+}
+
+// NewHistoricDatabase creates a historical state database on top of the given
+// caching database, sharing its code caches.
+func NewHistoricDatabase(db *CachingDB) *HistoricDB {
+	return &HistoricDB{CachingDB: db}
+}
+
+// Reader returns a state reader associated with the specified historical state.
+func (db *HistoricDB) Reader(stateRoot common.Hash) (Reader, error) {
+	r, err := db.triedb.HistoricReader(stateRoot)
+	if err != nil {
+		return nil, err
+	}
+	return newHistoricReader(r), nil
+}
+
+// OpenTrie opens a read-only trie of the specified historical state.
+func (db *HistoricDB) OpenTrie(root common.Hash) (Trie, error) {
+	r, err := db.triedb.HistoricReader(root)
+	if err != nil {
+		return nil, err
+	}
+	return &historicTrie{root: root, reader: newHistoricReader(r)}, nil
+}
+
+// OpenStorageTrie returns the trie of the historical state itself, which serves
+// the storage slots of all the accounts.
+func (db *HistoricDB) OpenStorageTrie(stateRoot common.Hash, address common.Address, root common.Hash, self Trie) (Trie, error) {
+	return self, nil
+}
+
+// historicReader implements the Reader interface, providing the functions to
+// access the historical state from the indexed state histories.
+type historicReader struct {
+	reader *pathdb.HistoricalStateReader
+	buff   crypto.KeccakState
+}
+
+// newHistoricReader constructs a reader for the historical state.
+func newHistoricReader(r *pathdb.HistoricalStateReader) *historicReader {
+	return &historicReader{reader: r, buff: crypto.NewKeccakState()}
+}
+
+// Account implements Reader, retrieving the account specified by the address.
+//
+// The returned account might be nil if it's not existent.
+func (r *historicReader) Account(addr common.Address) (*types.StateAccount, error) {
+	blob, err := r.reader.Account(addr)
+	if err != nil {
+		return nil, err
+	}
+	if len(blob) == 0 {
+		return nil, nil
+	}
+	return types.FullAccount(blob)
+}
+
+// Storage implements Reader, retrieving the storage slot specified by the
+// address and slot key.
+//
+// The returned storage slot might be empty if it's not existent.
+func (r *historicReader) Storage(addr common.Address, key common.Hash) (common.Hash, error) {
+	blob, err := r.reader.Storage(addr, crypto.HashData(r.buff, key.Bytes()))
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if len(blob) == 0 {
+		return common.Hash{}, nil
+	}
+	// Perform the rlp-decode as the slot value is RLP-encoded in the state
+	// history.
+	_, content, _, err := rlp.Split(blob)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	var value common.Hash
+	value.SetBytes(content)
+	return value, nil
+}
+
+// Copy implements Reader, returning a deep-copied historical reader.
+func (r *historicReader) Copy() Reader {
+	return newHistoricReader(r.reader)
+}
+
+// historicTrie implements the Trie interface on top of a historical state. It
+// only supports the account and storage reads, everything else is rejected.
+type historicTrie struct {
+	root   common.Hash
+	reader *historicReader
+}
+
+// GetKey returns nil as the preimages are not tracked.
+func (t *historicTrie) GetKey([]byte) []byte {
+	return nil
+}
+
+// GetAccount returns the account in the historical state.
+func (t *historicTrie) GetAccount(address common.Address) (*types.StateAccount, error) {
+	return t.reader.Account(address)
+}
+
+// GetStorage returns the storage slot in the historical state.
+func (t *historicTrie) GetStorage(addr common.Address, key []byte) ([]byte, error) {
+	value, err := t.reader.Storage(addr, common.BytesToHash(key))
+	if err != nil {
+		return nil, err
+	}
+	return common.TrimLeftZeroes(value[:]), nil
+}
+
+// UpdateAccount rejects the mutation of the historical state.
+func (t *historicTrie) UpdateAccount(address common.Address, account *types.StateAccount, codeLen int) error {
+	return errHistoricStateImmutable
+}
+
+// UpdateStorage rejects the mutation of the historical state.
+func (t *historicTrie) UpdateStorage(addr common.Address, key, value []byte) error {
+	return errHistoricStateImmutable
+}
+
+// DeleteAccount rejects the mutation of the historical state.
+func (t *historicTrie) DeleteAccount(address common.Address) error {
+	return errHistoricStateImmutable
+}
+
+// DeleteStorage rejects the mutation of the historical state.
+func (t *historicTrie) DeleteStorage(addr common.Address, key []byte) error {
+	return errHistoricStateImmutable
+}
+
+// UpdateContractCode rejects the mutation of the historical state.
+func (t *historicTrie) UpdateContractCode(address common.Address, codeHash common.Hash, code []byte) error {
+	return errHistoricStateImmutable
+}
+
+// Hash returns the root hash of the historical state.
+func (t *historicTrie) Hash() common.Hash {
+	return t.root
+}
+
+// Commit returns the root hash of the historical state, with nothing to commit.
+func (t *historicTrie) Commit(collectLeaf bool) (common.Hash, *trienode.NodeSet) {
+	return t.root, nil
+}
+
+// Witness returns nil as no trie node is accessed.
+func (t *historicTrie) Witness() map[string]struct{} {
+	return nil
+}
+
+// NodeIterator rejects the iteration as no trie node is available.
+func (t *historicTrie) NodeIterator(startKey []byte) (trie.NodeIterator, error) {
+	return nil, errors.New("node iteration is not supported by historical state")
+}
+
+// Prove rejects the proving as no trie node is available.
+func (t *historicTrie) Prove(key []byte, proofDb ethdb.KeyValueWriter) error {
+	return errors.New("proving is not supported by historical state")
+}
+
+// IsVerkle returns false as the historical state is only served for merkle tries.
+func (t *historicTrie) IsVerkle() bool {
+	return false
+}
+
+// copy returns a copy of the trie with an independent reader.
+func (t *historicTrie) copy() *historicTrie {
+	return &historicTrie{root: t.root, reader: newHistoricReader(t.reader.reader)}
+}
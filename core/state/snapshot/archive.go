@@ -0,0 +1,480 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/trie/trienode"
+	"github.com/ethereum/go-ethereum/triedb"
+	"github.com/holiman/uint256"
+)
+
+// The state archive is a stream of RLP items: an archiveHeader followed by a
+// sequence of archiveChunks. The entries are grouped by tries, each account
+// chunk is followed by the storage chunks of its accounts, in the order of the
+// accounts, and the codes referenced by them.
+//
+// Every account or storage chunk carries a contiguous range of trie entries in
+// columnar layout, along with the Merkle proof of the range boundaries. A chunk
+// starts right after the last entry of the previous chunk of the same trie, so
+// the archive as a whole proves the complete state against the state root. The
+// proof is omitted if a chunk covers an entire trie.
+const (
+	archiveMagic   = "gethstate"
+	archiveVersion = 0
+
+	// DefaultArchiveChunkSize is the default number of entries in a chunk.
+	DefaultArchiveChunkSize = 4096
+)
+
+// Chunk kinds of the state archive.
+const (
+	archiveAccountChunk = iota // Entries of the account trie
+	archiveStorageChunk        // Entries of a storage trie
+	archiveCodeChunk           // Contract codes
+)
+
+// archiveHeader is the first item of the state archive.
+type archiveHeader struct {
+	Magic    string      // Always set to 'gethstate' for disambiguation
+	Version  uint64      // Format version, bumped on backwards-incompatible changes
+	Root     common.Hash // State root of the archived state
+	UnixTime uint64      // Creation time of the archive
+}
+
+// archiveChunk is a batch of archived entries.
+type archiveChunk struct {
+	Kind   uint8
+	Owner  common.Hash   // Account hash of the storage chunk, empty otherwise
+	Keys   []common.Hash // Hashed trie keys, empty for the code chunk
+	Values [][]byte      // Trie values in consensus form, or contract codes
+	Proof  [][]byte      // Range proof, empty if the chunk covers the whole trie
+}
+
+// incHash returns the next hash in lexicographical order.
+func incHash(h common.Hash) common.Hash {
+	var a uint256.Int
+	a.SetBytes32(h[:])
+	a.AddUint64(&a, 1)
+	return common.Hash(a.Bytes32())
+}
+
+// archiveExporter writes the state of the given root into the archive.
+type archiveExporter struct {
+	w      io.Writer
+	tree   *Tree
+	triedb *triedb.Database
+	codedb ethdb.KeyValueReader
+	root   common.Hash
+	size   int
+
+	codes    map[common.Hash]struct{} // Hashes of the exported codes
+	accounts uint64
+	slots    uint64
+	start    time.Time
+	logged   time.Time
+}
+
+// ExportArchive writes the state of the given root into w in the chunked archive
+// format, iterating the snapshot. The codes are read from the given database and
+// the range proofs are generated from the tries in the trie database.
+func ExportArchive(w io.Writer, tree *Tree, db ethdb.KeyValueReader, triedb *triedb.Database, root common.Hash, size int) error {
+	if size <= 0 {
+		size = DefaultArchiveChunkSize
+	}
+	e := &archiveExporter{
+		w:      w,
+		tree:   tree,
+		triedb: triedb,
+		codedb: db,
+		root:   root,
+		size:   size,
+		codes:  make(map[common.Hash]struct{}),
+		start:  time.Now(),
+		logged: time.Now(),
+	}
+	if err := rlp.Encode(w, &archiveHeader{
+		Magic:    archiveMagic,
+		Version:  archiveVersion,
+		Root:     root,
+		UnixTime: uint64(time.Now().Unix()),
+	}); err != nil {
+		return err
+	}
+	if err := e.exportAccounts(); err != nil {
+		return err
+	}
+	log.Info("Exported state archive", "root", root, "accounts", e.accounts, "slots", e.slots,
+		"codes", len(e.codes), "elapsed", common.PrettyDuration(time.Since(e.start)))
+	return nil
+}
+
+// exportAccounts exports the account trie chunk by chunk, each followed by the
+// storages and codes of the included accounts.
+func (e *archiveExporter) exportAccounts() error {
+	tr, err := trie.New(trie.StateTrieID(e.root), e.triedb)
+	if err != nil {
+		return err
+	}
+	it, err := e.tree.AccountIterator(e.root, common.Hash{})
+	if err != nil {
+		return err
+	}
+	defer it.Release()
+
+	var (
+		origin   common.Hash
+		accounts []*types.StateAccount
+		chunk    = &archiveChunk{Kind: archiveAccountChunk}
+	)
+	// The iterator is advanced ahead of the chunk to find out whether the chunk
+	// is the last one.
+	next := it.Next()
+	for {
+		if next {
+			account, err := types.FullAccount(it.Account())
+			if err != nil {
+				return err
+			}
+			blob, err := rlp.EncodeToBytes(account)
+			if err != nil {
+				return err
+			}
+			chunk.Keys = append(chunk.Keys, it.Hash())
+			chunk.Values = append(chunk.Values, blob)
+			accounts = append(accounts, account)
+			next = it.Next()
+		}
+		if len(chunk.Keys) < e.size && next {
+			continue
+		}
+		if err := it.Error(); err != nil {
+			return err
+		}
+		exhausted := !next
+		if err := e.writeChunk(tr, chunk, origin, exhausted); err != nil {
+			return err
+		}
+		if err := e.exportAccountData(chunk.Keys, accounts); err != nil {
+			return err
+		}
+		e.accounts += uint64(len(chunk.Keys))
+		if exhausted {
+			return nil
+		}
+		if time.Since(e.logged) > 8*time.Second {
+			log.Info("Exporting state archive", "at", chunk.Keys[len(chunk.Keys)-1], "accounts", e.accounts,
+				"slots", e.slots, "elapsed", common.PrettyDuration(time.Since(e.start)))
+			e.logged = time.Now()
+		}
+		origin = incHash(chunk.Keys[len(chunk.Keys)-1])
+		accounts, chunk = accounts[:0], &archiveChunk{Kind: archiveAccountChunk}
+	}
+}
+
+// exportAccountData exports the storages and the codes not exported yet of the
+// given accounts.
+func (e *archiveExporter) exportAccountData(hashes []common.Hash, accounts []*types.StateAccount) error {
+	for i, account := range accounts {
+		if account.Root == types.EmptyRootHash {
+			continue
+		}
+		if err := e.exportStorage(hashes[i], account.Root); err != nil {
+			return err
+		}
+	}
+	chunk := &archiveChunk{Kind: archiveCodeChunk}
+	for _, account := range accounts {
+		hash := common.BytesToHash(account.CodeHash)
+		if hash == types.EmptyCodeHash {
+			continue
+		}
+		if _, ok := e.codes[hash]; ok {
+			continue
+		}
+		code := rawdb.ReadCode(e.codedb, hash)
+		if len(code) == 0 {
+			return fmt.Errorf("missing code %x", hash)
+		}
+		e.codes[hash] = struct{}{}
+		chunk.Values = append(chunk.Values, code)
+	}
+	if len(chunk.Values) == 0 {
+		return nil
+	}
+	return rlp.Encode(e.w, chunk)
+}
+
+// exportStorage exports the storage trie of the given account chunk by chunk.
+func (e *archiveExporter) exportStorage(account common.Hash, root common.Hash) error {
+	tr, err := trie.New(trie.StorageTrieID(e.root, account, root), e.triedb)
+	if err != nil {
+		return err
+	}
+	it, err := e.tree.StorageIterator(e.root, account, common.Hash{})
+	if err != nil {
+		return err
+	}
+	defer it.Release()
+
+	var (
+		origin common.Hash
+		chunk  = &archiveChunk{Kind: archiveStorageChunk, Owner: account}
+	)
+	next := it.Next()
+	for {
+		if next {
+			chunk.Keys = append(chunk.Keys, it.Hash())
+			chunk.Values = append(chunk.Values, common.CopyBytes(it.Slot()))
+			next = it.Next()
+		}
+		if len(chunk.Keys) < e.size && next {
+			continue
+		}
+		if err := it.Error(); err != nil {
+			return err
+		}
+		exhausted := !next
+		if err := e.writeChunk(tr, chunk, origin, exhausted); err != nil {
+			return err
+		}
+		e.slots += uint64(len(chunk.Keys))
+		if exhausted {
+			return nil
+		}
+		origin = incHash(chunk.Keys[len(chunk.Keys)-1])
+		chunk = &archiveChunk{Kind: archiveStorageChunk, Owner: account}
+	}
+}
+
+// writeChunk attaches the range proof to the chunk starting at the given origin
+// and writes it out.
+func (e *archiveExporter) writeChunk(tr *trie.Trie, chunk *archiveChunk, origin common.Hash, last bool) error {
+	if origin != (common.Hash{}) || !last {
+		proof := trienode.NewProofSet()
+		if err := tr.Prove(origin[:], proof); err != nil {
+			return err
+		}
+		if len(chunk.Keys) > 0 {
+			if err := tr.Prove(chunk.Keys[len(chunk.Keys)-1][:], proof); err != nil {
+				return err
+			}
+		}
+		chunk.Proof = proof.List()
+	}
+	return rlp.Encode(e.w, chunk)
+}
+
+// archiveStorage is the storage trie being imported.
+type archiveStorage struct {
+	account common.Hash
+	root    common.Hash
+	origin  common.Hash
+	trie    *trie.StackTrie
+}
+
+// ImportArchive imports the state archive from r into the database, writing the
+// snapshot, the trie nodes in the given state scheme and the contract codes. All
+// the chunks are verified against the state root. The database is expected to
+// have no state yet, the root of the imported state is returned.
+func ImportArchive(r io.Reader, db ethdb.KeyValueStore, scheme string) (common.Hash, error) {
+	if rawdb.ReadSnapshotRoot(db) != (common.Hash{}) {
+		return common.Hash{}, errors.New("snapshot already exists")
+	}
+	stream := rlp.NewStream(r, 0)
+
+	var header archiveHeader
+	if err := stream.Decode(&header); err != nil {
+		return common.Hash{}, fmt.Errorf("could not decode header: %v", err)
+	}
+	if header.Magic != archiveMagic {
+		return common.Hash{}, errors.New("incompatible data, wrong magic")
+	}
+	if header.Version != archiveVersion {
+		return common.Hash{}, fmt.Errorf("incompatible version %d, (support only %d)", header.Version, archiveVersion)
+	}
+	log.Info("Importing state archive", "root", header.Root, "age",
+		common.PrettyDuration(time.Since(time.Unix(int64(header.UnixTime), 0))))
+
+	var (
+		batch   = db.NewBatch()
+		accTrie = trie.NewStackTrie(func(path []byte, hash common.Hash, blob []byte) {
+			rawdb.WriteTrieNode(batch, common.Hash{}, path, hash, blob, scheme)
+		})
+		origin   common.Hash
+		done     bool
+		storages []*archiveStorage            // Storage tries pending for import
+		codes    = make(map[common.Hash]bool) // Referenced codes, flagged if imported
+		missing  int                          // Number of referenced codes not imported yet
+
+		accounts, slots int
+		start           = time.Now()
+		logged          = time.Now()
+	)
+	for {
+		var chunk archiveChunk
+		if err := stream.Decode(&chunk); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return common.Hash{}, err
+		}
+		switch chunk.Kind {
+		case archiveAccountChunk:
+			if done {
+				return common.Hash{}, errors.New("unexpected account chunk")
+			}
+			if len(storages) > 0 {
+				return common.Hash{}, fmt.Errorf("missing storage of account %x", storages[0].account)
+			}
+			more, err := verifyArchiveChunk(header.Root, origin, &chunk)
+			if err != nil {
+				return common.Hash{}, fmt.Errorf("invalid account chunk: %v", err)
+			}
+			for i, key := range chunk.Keys {
+				var account types.StateAccount
+				if err := rlp.DecodeBytes(chunk.Values[i], &account); err != nil {
+					return common.Hash{}, fmt.Errorf("invalid account %x: %v", key, err)
+				}
+				accTrie.Update(key[:], chunk.Values[i])
+				rawdb.WriteAccountSnapshot(batch, key, types.SlimAccountRLP(account))
+
+				if account.Root != types.EmptyRootHash {
+					storages = append(storages, &archiveStorage{account: key, root: account.Root})
+				}
+				if hash := common.BytesToHash(account.CodeHash); hash != types.EmptyCodeHash {
+					if _, ok := codes[hash]; !ok {
+						codes[hash] = false
+						missing++
+					}
+				}
+			}
+			accounts += len(chunk.Keys)
+			if len(chunk.Keys) > 0 {
+				origin = incHash(chunk.Keys[len(chunk.Keys)-1])
+			}
+			done = !more
+
+		case archiveStorageChunk:
+			if len(storages) == 0 || storages[0].account != chunk.Owner {
+				return common.Hash{}, fmt.Errorf("unexpected storage chunk of account %x", chunk.Owner)
+			}
+			storage := storages[0]
+			if storage.trie == nil {
+				storage.trie = trie.NewStackTrie(func(path []byte, hash common.Hash, blob []byte) {
+					rawdb.WriteTrieNode(batch, storage.account, path, hash, blob, scheme)
+				})
+			}
+			more, err := verifyArchiveChunk(storage.root, storage.origin, &chunk)
+			if err != nil {
+				return common.Hash{}, fmt.Errorf("invalid storage chunk of account %x: %v", chunk.Owner, err)
+			}
+			for i, key := range chunk.Keys {
+				storage.trie.Update(key[:], chunk.Values[i])
+				rawdb.WriteStorageSnapshot(batch, storage.account, key, chunk.Values[i])
+			}
+			slots += len(chunk.Keys)
+			if len(chunk.Keys) > 0 {
+				storage.origin = incHash(chunk.Keys[len(chunk.Keys)-1])
+			}
+			if !more {
+				if hash := storage.trie.Hash(); hash != storage.root {
+					return common.Hash{}, fmt.Errorf("storage root mismatch of account %x, want %x, got %x", storage.account, storage.root, hash)
+				}
+				storages = storages[1:]
+			}
+
+		case archiveCodeChunk:
+			for _, code := range chunk.Values {
+				hash := crypto.Keccak256Hash(code)
+				if imported, ok := codes[hash]; !ok || imported {
+					return common.Hash{}, fmt.Errorf("unexpected code %x", hash)
+				}
+				rawdb.WriteCode(batch, hash, code)
+				codes[hash] = true
+				missing--
+			}
+
+		default:
+			return common.Hash{}, fmt.Errorf("unknown chunk kind %d", chunk.Kind)
+		}
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return common.Hash{}, err
+			}
+			batch.Reset()
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Importing state archive", "at", origin, "accounts", accounts, "slots", slots,
+				"elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if !done || len(storages) > 0 {
+		return common.Hash{}, errors.New("truncated state archive")
+	}
+	if missing > 0 {
+		return common.Hash{}, fmt.Errorf("missing %d codes", missing)
+	}
+	if hash := accTrie.Hash(); hash != header.Root {
+		return common.Hash{}, fmt.Errorf("state root mismatch, want %x, got %x", header.Root, hash)
+	}
+	// Mark the snapshot as complete, it's usable right away.
+	rawdb.WriteSnapshotRoot(batch, header.Root)
+	journalProgress(batch, nil, nil)
+	if err := batch.Write(); err != nil {
+		return common.Hash{}, err
+	}
+	log.Info("Imported state archive", "root", header.Root, "accounts", accounts, "slots", slots,
+		"elapsed", common.PrettyDuration(time.Since(start)))
+	return header.Root, nil
+}
+
+// verifyArchiveChunk verifies the trie entries of the chunk starting at the
+// given origin against the trie root, returning whether more entries follow.
+func verifyArchiveChunk(root common.Hash, origin common.Hash, chunk *archiveChunk) (bool, error) {
+	if len(chunk.Keys) != len(chunk.Values) {
+		return false, fmt.Errorf("inconsistent chunk, keys: %d, values: %d", len(chunk.Keys), len(chunk.Values))
+	}
+	keys := make([][]byte, len(chunk.Keys))
+	for i := range chunk.Keys {
+		keys[i] = chunk.Keys[i][:]
+	}
+	var proof ethdb.KeyValueReader
+	if len(chunk.Proof) > 0 {
+		set := trienode.NewProofSet()
+		for _, node := range chunk.Proof {
+			set.Put(crypto.Keccak256(node), node)
+		}
+		proof = set
+	} else if origin != (common.Hash{}) {
+		return false, errors.New("missing range proof")
+	}
+	return trie.VerifyRangeProof(root, origin[:], keys, chunk.Values, proof)
+}
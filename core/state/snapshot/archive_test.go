@@ -0,0 +1,140 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/triedb"
+	"github.com/ethereum/go-ethereum/triedb/hashdb"
+	"github.com/ethereum/go-ethereum/triedb/pathdb"
+	"github.com/holiman/uint256"
+)
+
+func TestArchiveExportImport(t *testing.T) {
+	testArchiveExportImport(t, rawdb.HashScheme)
+	testArchiveExportImport(t, rawdb.PathScheme)
+}
+
+func testArchiveExportImport(t *testing.T, scheme string) {
+	var (
+		helper = newHelper(scheme)
+		code   = []byte{0x60, 0x00}
+	)
+	rawdb.WriteCode(helper.diskdb, crypto.Keccak256Hash(code), code)
+
+	// Create a state with 32 accounts, every third of them having a contract
+	// code and the storage of a size spanning up to several chunks.
+	for i := 0; i < 32; i++ {
+		var (
+			name = fmt.Sprintf("acc-%d", i)
+			acc  = &types.StateAccount{Balance: uint256.NewInt(uint64(i)), Root: types.EmptyRootHash, CodeHash: types.EmptyCodeHash.Bytes()}
+		)
+		if i%3 == 0 {
+			var keys, vals []string
+			for j := 0; j < i; j++ {
+				keys = append(keys, fmt.Sprintf("key-%d", j))
+				vals = append(vals, fmt.Sprintf("val-%d", j))
+			}
+			acc.Root = helper.makeStorageTrie(hashData([]byte(name)), keys, vals, true)
+			acc.CodeHash = crypto.Keccak256(code)
+		}
+		helper.addTrieAccount(name, acc)
+	}
+	root := helper.Commit()
+	tree, err := New(Config{CacheSize: 16}, helper.diskdb, helper.triedb, root)
+	if err != nil {
+		t.Fatalf("Failed to create snapshot: %v", err)
+	}
+	var archive bytes.Buffer
+	if err := ExportArchive(&archive, tree, helper.diskdb, helper.triedb, root, 5); err != nil {
+		t.Fatalf("Failed to export archive: %v", err)
+	}
+	// Import the archive into an empty database and verify the result.
+	db := rawdb.NewMemoryDatabase()
+	imported, err := ImportArchive(bytes.NewReader(archive.Bytes()), db, scheme)
+	if err != nil {
+		t.Fatalf("Failed to import archive: %v", err)
+	}
+	if imported != root {
+		t.Fatalf("Unexpected imported root, want %x, got %x", root, imported)
+	}
+	config := &triedb.Config{HashDB: &hashdb.Config{}}
+	if scheme == rawdb.PathScheme {
+		config = &triedb.Config{PathDB: &pathdb.Config{}}
+	}
+	tdb := triedb.NewDatabase(db, config)
+	snaps, err := New(Config{CacheSize: 16, NoBuild: true}, db, tdb, root)
+	if err != nil {
+		t.Fatalf("Failed to load imported snapshot: %v", err)
+	}
+	if err := snaps.Verify(root); err != nil {
+		t.Fatalf("Failed to verify imported snapshot: %v", err)
+	}
+	// All the trie nodes are imported as well.
+	accTrie, err := trie.New(trie.StateTrieID(root), tdb)
+	if err != nil {
+		t.Fatalf("Failed to open imported trie: %v", err)
+	}
+	var accounts, slots int
+	accIt := trie.NewIterator(accTrie.MustNodeIterator(nil))
+	for accIt.Next() {
+		var acc types.StateAccount
+		if err := rlp.DecodeBytes(accIt.Value, &acc); err != nil {
+			t.Fatalf("Failed to decode account: %v", err)
+		}
+		accounts++
+		if acc.Root != types.EmptyRootHash {
+			stTrie, err := trie.New(trie.StorageTrieID(root, common.BytesToHash(accIt.Key), acc.Root), tdb)
+			if err != nil {
+				t.Fatalf("Failed to open imported storage trie: %v", err)
+			}
+			stIt := trie.NewIterator(stTrie.MustNodeIterator(nil))
+			for stIt.Next() {
+				slots++
+			}
+			if stIt.Err != nil {
+				t.Fatalf("Failed to iterate imported storage trie: %v", stIt.Err)
+			}
+		}
+		if !bytes.Equal(acc.CodeHash, types.EmptyCodeHash.Bytes()) && !bytes.Equal(rawdb.ReadCode(db, common.BytesToHash(acc.CodeHash)), code) {
+			t.Fatal("Missing imported code")
+		}
+	}
+	if accIt.Err != nil {
+		t.Fatalf("Failed to iterate imported trie: %v", accIt.Err)
+	}
+	if accounts != 32 || slots != 3+6+9+12+15+18+21+24+27+30 {
+		t.Fatalf("Unexpected imported state, accounts %d, slots %d", accounts, slots)
+	}
+	// The truncated or tampered archives are rejected.
+	if _, err := ImportArchive(bytes.NewReader(archive.Bytes()[:archive.Len()/2]), rawdb.NewMemoryDatabase(), scheme); err == nil {
+		t.Fatal("Truncated archive is imported")
+	}
+	tampered := bytes.Replace(archive.Bytes(), []byte("val-7"), []byte("val-8"), 1)
+	if _, err := ImportArchive(bytes.NewReader(tampered), rawdb.NewMemoryDatabase(), scheme); err == nil {
+		t.Fatal("Tampered archive is imported")
+	}
+}
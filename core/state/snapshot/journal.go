@@ -28,6 +28,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/internal/cachejournal"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/triedb"
@@ -120,7 +121,7 @@ func loadAndParseJournal(db ethdb.KeyValueStore, base *diskLayer) (snapshot, jou
 }
 
 // loadSnapshot loads a pre-existing state snapshot backed by a key-value store.
-func loadSnapshot(diskdb ethdb.KeyValueStore, triedb *triedb.Database, root common.Hash, cache int, recovery bool, noBuild bool) (snapshot, bool, error) {
+func loadSnapshot(diskdb ethdb.KeyValueStore, triedb *triedb.Database, root common.Hash, cache int, cacheJournal string, recovery bool, noBuild bool) (snapshot, bool, error) {
 	// If snapshotting is disabled (initial sync in progress), don't do anything,
 	// wait for the chain to permit us to do something meaningful
 	if rawdb.ReadSnapshotDisabled(diskdb) {
@@ -132,11 +133,25 @@ func loadSnapshot(diskdb ethdb.KeyValueStore, triedb *triedb.Database, root comm
 	if baseRoot == (common.Hash{}) {
 		return nil, false, errors.New("missing or corrupted snapshot")
 	}
+	// CHANGE(taiko): warm up the read cache with the persisted one if it's
+	// saved for the same disk layer.
+	var (
+		cleans  *fastcache.Cache
+		tracker *cachejournal.Tracker
+	)
+	if cacheJournal != "" && cache > 0 {
+		cleans = cachejournal.Load(cacheJournal, cache*1024*1024, baseRoot)
+		tracker = cachejournal.NewTracker(cache * 1024 * 1024)
+	}
+	if cleans == nil {
+		cleans = fastcache.New(cache * 1024 * 1024)
+	}
 	base := &diskLayer{
-		diskdb: diskdb,
-		triedb: triedb,
-		cache:  fastcache.New(cache * 1024 * 1024),
-		root:   baseRoot,
+		diskdb:  diskdb,
+		triedb:  triedb,
+		cache:   cleans,
+		tracker: tracker,
+		root:    baseRoot,
 	}
 	snapshot, generator, err := loadAndParseJournal(diskdb, base)
 	if err != nil {
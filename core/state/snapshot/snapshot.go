@@ -27,6 +27,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/internal/cachejournal"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/rlp"
@@ -154,6 +155,9 @@ type Config struct {
 	Recovery   bool // Indicator that the snapshots is in the recovery mode
 	NoBuild    bool // Indicator that the snapshots generation is disallowed
 	AsyncBuild bool // The snapshot generation is allowed to be constructed asynchronously
+
+	// CHANGE(taiko): persist the read cache across restarts.
+	CacheJournal string // Directory to persist the read cache on release, disabled if empty
 }
 
 // Tree is an Ethereum state snapshot tree. It consists of one persistent base
@@ -201,7 +205,7 @@ func New(config Config, diskdb ethdb.KeyValueStore, triedb *triedb.Database, roo
 		layers: make(map[common.Hash]snapshot),
 	}
 	// Attempt to load a previously persisted snapshot and rebuild one if failed
-	head, disabled, err := loadSnapshot(diskdb, triedb, root, config.CacheSize, config.Recovery, config.NoBuild)
+	head, disabled, err := loadSnapshot(diskdb, triedb, root, config.CacheSize, config.CacheJournal, config.Recovery, config.NoBuild)
 	if disabled {
 		log.Warn("Snapshot maintenance disabled (syncing)")
 		return snap, nil
@@ -633,6 +637,7 @@ func diffToDisk(bottom *diffLayer) *diskLayer {
 	res := &diskLayer{
 		root:       bottom.root,
 		cache:      base.cache,
+		tracker:    base.tracker, // CHANGE(taiko)
 		diskdb:     base.diskdb,
 		triedb:     base.triedb,
 		genMarker:  base.genMarker,
@@ -657,6 +662,12 @@ func (t *Tree) Release() {
 	defer t.lock.RUnlock()
 
 	if dl := t.disklayer(); dl != nil {
+		// CHANGE(taiko): persist the read cache for the next startup.
+		if t.config.CacheJournal != "" && dl.cache != nil {
+			if err := cachejournal.Save(dl.cache, dl.tracker, t.config.CacheJournal, dl.root); err != nil {
+				log.Warn("Failed to persist snapshot cache", "err", err)
+			}
+		}
 		dl.Release()
 	}
 }
@@ -736,8 +747,15 @@ func (t *Tree) Rebuild(root common.Hash) {
 	// Start generating a new snapshot from scratch on a background thread. The
 	// generator will run a wiper first if there's not one running right now.
 	log.Info("Rebuilding state snapshot")
+	base := generateSnapshot(t.diskdb, t.triedb, t.config.CacheSize, root)
+
+	// CHANGE(taiko): track the accesses of the read cache to persist the hottest
+	// entries on shutdown.
+	if t.config.CacheJournal != "" && t.config.CacheSize > 0 {
+		base.tracker = cachejournal.NewTracker(t.config.CacheSize * 1024 * 1024)
+	}
 	t.layers = map[common.Hash]snapshot{
-		root: generateSnapshot(t.diskdb, t.triedb, t.config.CacheSize, root),
+		root: base,
 	}
 }
 
@@ -404,6 +404,10 @@ func (s *stateObject) commitStorage(op *accountUpdate) {
 			op.storagesOrigin = make(map[common.Hash][]byte)
 		}
 		op.storagesOrigin[hash] = encode(s.originStorage[key])
+		if op.storageKeys == nil {
+			op.storageKeys = make(map[common.Hash]common.Hash)
+		}
+		op.storageKeys[hash] = key
 
 		// Overwrite the clean value of storage slots
 		s.originStorage[key] = val
@@ -1310,6 +1310,20 @@ func (s *StateDB) Commit(block uint64, deleteEmptyObjects bool) (common.Hash, er
 	return ret.root, nil
 }
 
+// CommitWithDiff writes the state mutations into the configured data stores just
+// like Commit, additionally returning the account and storage changes.
+func (s *StateDB) CommitWithDiff(block uint64, deleteEmptyObjects bool) (common.Hash, *StateDiff, error) {
+	ret, err := s.commitAndFlush(block, deleteEmptyObjects)
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+	diff, err := ret.stateDiff()
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+	return ret.root, diff, nil
+}
+
 // Prepare handles the preparatory steps for executing a state transition with.
 // This method must be invoked before state transition.
 //
@@ -0,0 +1,139 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// StateDiff is the set of account and storage changes caused by a committed
+// state transition.
+type StateDiff struct {
+	OriginRoot common.Hash                     // Hash of the state before the transition
+	Root       common.Hash                     // Hash of the state after the transition
+	Accounts   map[common.Address]*AccountDiff // Changed accounts
+}
+
+// AccountDiff is the change of a single account.
+type AccountDiff struct {
+	Origin  *types.StateAccount // Original account, nil if it didn't exist
+	Account *types.StateAccount // Updated account, nil if it was deleted
+	Code    []byte              // Updated contract code, nil if not changed
+
+	// Destructed is set if the original account was destructed, wiping all its
+	// storage slots, and possibly resurrected afterwards.
+	Destructed bool
+
+	// Storage is the changed storage slots, keyed by the raw slot keys.
+	Storage map[common.Hash]StorageDiff
+
+	// WipedStorage is the storage slots wiped by the destruction and not written
+	// afterwards, keyed by the hashes of the slot keys as the raw keys are not
+	// available. The updated values of these slots are always zero.
+	WipedStorage map[common.Hash]StorageDiff
+}
+
+// StorageDiff is the change of a single storage slot.
+type StorageDiff struct {
+	Origin common.Hash // Original value of the slot
+	Value  common.Hash // Updated value of the slot
+}
+
+// stateDiff assembles the account and storage changes from the state update.
+func (sc *stateUpdate) stateDiff() (*StateDiff, error) {
+	diff := &StateDiff{
+		OriginRoot: sc.originRoot,
+		Root:       sc.root,
+		Accounts:   make(map[common.Address]*AccountDiff),
+	}
+	for addr, origin := range sc.accountsOrigin {
+		var (
+			addrHash = crypto.Keccak256Hash(addr.Bytes())
+			data     = sc.accounts[addrHash]
+			account  = &AccountDiff{}
+			err      error
+		)
+		_, account.Destructed = sc.destructs[addrHash]
+
+		if len(origin) > 0 {
+			if account.Origin, err = types.FullAccount(origin); err != nil {
+				return nil, fmt.Errorf("invalid origin of account %x: %v", addr, err)
+			}
+		}
+		if len(data) > 0 {
+			if account.Account, err = types.FullAccount(data); err != nil {
+				return nil, fmt.Errorf("invalid account %x: %v", addr, err)
+			}
+		}
+		if code, ok := sc.codes[addr]; ok {
+			account.Code = code.blob
+		}
+		var (
+			keys    = sc.storageKeys[addr]
+			updates = sc.storages[addrHash]
+		)
+		for hash, blob := range sc.storagesOrigin[addr] {
+			prev, err := decodeStorageValue(blob)
+			if err != nil {
+				return nil, fmt.Errorf("invalid origin of slot %x of account %x: %v", hash, addr, err)
+			}
+			next, err := decodeStorageValue(updates[hash])
+			if err != nil {
+				return nil, fmt.Errorf("invalid slot %x of account %x: %v", hash, addr, err)
+			}
+			if prev == next {
+				continue
+			}
+			if key, ok := keys[hash]; ok {
+				if account.Storage == nil {
+					account.Storage = make(map[common.Hash]StorageDiff)
+				}
+				account.Storage[key] = StorageDiff{Origin: prev, Value: next}
+			} else {
+				if account.WipedStorage == nil {
+					account.WipedStorage = make(map[common.Hash]StorageDiff)
+				}
+				account.WipedStorage[hash] = StorageDiff{Origin: prev, Value: next}
+			}
+		}
+		// Skip the accounts touched without any change.
+		if !account.Destructed && account.Code == nil && len(account.Storage) == 0 && bytes.Equal(origin, data) {
+			continue
+		}
+		diff.Accounts[addr] = account
+	}
+	return diff, nil
+}
+
+// decodeStorageValue decodes the storage slot value in prefix-zero-trimmed RLP
+// format, the empty value is interpreted as zero.
+func decodeStorageValue(blob []byte) (common.Hash, error) {
+	if len(blob) == 0 {
+		return common.Hash{}, nil
+	}
+	_, content, _, err := rlp.Split(blob)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(content), nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/holiman/uint256"
+)
+
+func TestStateDiff(t *testing.T) {
+	var (
+		db       = NewDatabaseForTesting()
+		sender   = common.Address{0x01}
+		contract = common.Address{0x02}
+		created  = common.Address{0x03}
+		destruct = common.Address{0x04}
+		touched  = common.Address{0x05}
+	)
+	state, _ := New(types.EmptyRootHash, db)
+	state.SetBalance(sender, uint256.NewInt(100), tracing.BalanceChangeUnspecified)
+	state.SetCode(contract, []byte{0x01})
+	state.SetState(contract, common.Hash{0x01}, common.Hash{0x11})
+	state.SetState(contract, common.Hash{0x02}, common.Hash{0x22})
+	state.SetBalance(destruct, uint256.NewInt(1), tracing.BalanceChangeUnspecified)
+	state.SetState(destruct, common.Hash{0x01}, common.Hash{0x33})
+	state.SetBalance(touched, uint256.NewInt(1), tracing.BalanceChangeUnspecified)
+
+	root, diff, err := state.CommitWithDiff(0, false)
+	if err != nil {
+		t.Fatalf("Failed to commit state: %v", err)
+	}
+	if diff.OriginRoot != types.EmptyRootHash || diff.Root != root || len(diff.Accounts) != 4 {
+		t.Fatalf("Unexpected genesis diff: %d accounts", len(diff.Accounts))
+	}
+	if acc := diff.Accounts[contract]; acc.Origin != nil || !bytes.Equal(acc.Code, []byte{0x01}) || len(acc.Storage) != 2 {
+		t.Fatal("Unexpected diff of the created contract")
+	}
+	// Mutate the state in the following transition.
+	state, _ = New(root, db)
+	state.SubBalance(sender, uint256.NewInt(10), tracing.BalanceChangeUnspecified)
+	state.SetNonce(sender, 1)
+	state.SetState(contract, common.Hash{0x01}, common.Hash{0x12})
+	state.SetState(contract, common.Hash{0x02}, common.Hash{0x22})
+	state.SetState(contract, common.Hash{0x03}, common.Hash{0x33})
+	state.SetCode(created, []byte{0x02})
+	state.SelfDestruct(destruct)
+	state.AddBalance(touched, uint256.NewInt(0), tracing.BalanceChangeUnspecified)
+
+	next, diff, err := state.CommitWithDiff(1, false)
+	if err != nil {
+		t.Fatalf("Failed to commit state: %v", err)
+	}
+	if diff.OriginRoot != root || diff.Root != next {
+		t.Fatal("Unexpected state roots")
+	}
+	if len(diff.Accounts) != 4 {
+		t.Fatalf("Unexpected changed accounts, want %d, got %d", 4, len(diff.Accounts))
+	}
+	if _, ok := diff.Accounts[touched]; ok {
+		t.Fatal("Unchanged account is reported")
+	}
+	acc := diff.Accounts[sender]
+	if acc.Origin.Balance.Uint64() != 100 || acc.Account.Balance.Uint64() != 90 || acc.Origin.Nonce != 0 || acc.Account.Nonce != 1 {
+		t.Fatal("Unexpected diff of the sender")
+	}
+	acc = diff.Accounts[contract]
+	if acc.Code != nil || len(acc.Storage) != 2 || len(acc.WipedStorage) != 0 {
+		t.Fatalf("Unexpected diff of the contract: %v", acc.Storage)
+	}
+	if slot := acc.Storage[common.Hash{0x01}]; slot.Origin != (common.Hash{0x11}) || slot.Value != (common.Hash{0x12}) {
+		t.Fatal("Unexpected diff of the updated slot")
+	}
+	if slot := acc.Storage[common.Hash{0x03}]; slot.Origin != (common.Hash{}) || slot.Value != (common.Hash{0x33}) {
+		t.Fatal("Unexpected diff of the created slot")
+	}
+	acc = diff.Accounts[created]
+	if acc.Origin != nil || acc.Account == nil || !bytes.Equal(acc.Account.CodeHash, crypto.Keccak256([]byte{0x02})) {
+		t.Fatal("Unexpected diff of the created account")
+	}
+	acc = diff.Accounts[destruct]
+	if !acc.Destructed || acc.Origin == nil || acc.Account != nil || len(acc.Storage) != 0 {
+		t.Fatal("Unexpected diff of the destructed account")
+	}
+	if slot, ok := acc.WipedStorage[crypto.Keccak256Hash(common.Hash{0x01}.Bytes())]; !ok || slot.Origin != (common.Hash{0x33}) || slot.Value != (common.Hash{}) {
+		t.Fatal("Unexpected diff of the wiped slot")
+	}
+}
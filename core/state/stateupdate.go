@@ -37,12 +37,13 @@ type accountDelete struct {
 
 // accountUpdate represents an operation for updating an Ethereum account.
 type accountUpdate struct {
-	address        common.Address         // address is the unique account identifier
-	data           []byte                 // data is the slim-RLP encoded account data.
-	origin         []byte                 // origin is the original value of account data in slim-RLP encoding.
-	code           *contractCode          // code represents mutated contract code; nil means it's not modified.
-	storages       map[common.Hash][]byte // storages stores mutated slots in prefix-zero-trimmed RLP format.
-	storagesOrigin map[common.Hash][]byte // storagesOrigin stores the original values of mutated slots in prefix-zero-trimmed RLP format.
+	address        common.Address              // address is the unique account identifier
+	data           []byte                      // data is the slim-RLP encoded account data.
+	origin         []byte                      // origin is the original value of account data in slim-RLP encoding.
+	code           *contractCode               // code represents mutated contract code; nil means it's not modified.
+	storages       map[common.Hash][]byte      // storages stores mutated slots in prefix-zero-trimmed RLP format.
+	storagesOrigin map[common.Hash][]byte      // storagesOrigin stores the original values of mutated slots in prefix-zero-trimmed RLP format.
+	storageKeys    map[common.Hash]common.Hash // storageKeys maps the hashes of mutated slots to the raw slot keys.
 }
 
 // stateUpdate represents the difference between two states resulting from state
@@ -58,6 +59,8 @@ type stateUpdate struct {
 	storagesOrigin map[common.Address]map[common.Hash][]byte // storagesOrigin stores the original values of mutated slots in 'prefix-zero-trimmed' RLP format
 	codes          map[common.Address]contractCode           // codes contains the set of dirty codes
 	nodes          *trienode.MergedNodeSet                   // Aggregated dirty nodes caused by state changes
+
+	storageKeys map[common.Address]map[common.Hash]common.Hash // storageKeys maps the hashes of mutated slots to the raw slot keys
 }
 
 // empty returns a flag indicating the state transition is empty or not.
@@ -76,6 +79,7 @@ func newStateUpdate(originRoot common.Hash, root common.Hash, deletes map[common
 		storages       = make(map[common.Hash]map[common.Hash][]byte)
 		storagesOrigin = make(map[common.Address]map[common.Hash][]byte)
 		codes          = make(map[common.Address]contractCode)
+		storageKeys    = make(map[common.Address]map[common.Hash]common.Hash)
 	)
 	// Due to the fact that some accounts could be destructed and resurrected
 	// within the same block, the deletions must be aggregated first.
@@ -105,6 +109,9 @@ func newStateUpdate(originRoot common.Hash, root common.Hash, deletes map[common
 		if len(op.storages) > 0 {
 			storages[addrHash] = op.storages
 		}
+		if len(op.storageKeys) > 0 {
+			storageKeys[addr] = op.storageKeys
+		}
 		if len(op.storagesOrigin) > 0 {
 			origin := storagesOrigin[addr]
 			if origin == nil {
@@ -129,5 +136,6 @@ func newStateUpdate(originRoot common.Hash, root common.Hash, deletes map[common
 		storagesOrigin: storagesOrigin,
 		codes:          codes,
 		nodes:          nodes,
+		storageKeys:    storageKeys,
 	}
 }
@@ -0,0 +1,74 @@
+package state
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// CheckKnownAccounts returns an error if the storage of the given accounts does
+// not match the current state, including the mutations of all the finalised
+// transactions.
+func (s *StateDB) CheckKnownAccounts(accounts types.KnownAccounts) error {
+	for addr, account := range accounts {
+		if account.StorageRoot != nil {
+			root := types.EmptyRootHash
+			if obj := s.getStateObject(addr); obj != nil {
+				var err error
+				if root, err = s.pendingStorageRoot(obj); err != nil {
+					return fmt.Errorf("%w: storage root of %v unavailable: %v", types.ErrConditionalFailed, addr, err)
+				}
+			}
+			if root != *account.StorageRoot {
+				return fmt.Errorf("%w: storage root of %v is %v, expected %v", types.ErrConditionalFailed, addr, root, *account.StorageRoot)
+			}
+		}
+		for slot, value := range account.StorageSlots {
+			if have := s.GetState(addr, slot); have != value {
+				return fmt.Errorf("%w: storage slot %v of %v is %v, expected %v", types.ErrConditionalFailed, slot, addr, have, value)
+			}
+		}
+	}
+	return nil
+}
+
+// pendingStorageRoot computes the storage root of the given state object with
+// its uncommitted mutations applied. Storage roots are only updated when hashing
+// the state, so the mutations are applied on a copy of the storage trie, leaving
+// the object itself and the prefetcher untouched.
+func (s *StateDB) pendingStorageRoot(obj *stateObject) (common.Hash, error) {
+	if len(obj.uncommittedStorage) == 0 {
+		return obj.Root(), nil
+	}
+	var tr Trie
+	if obj.trie != nil {
+		tr = mustCopyTrie(obj.trie)
+	} else {
+		var err error
+		if tr, err = s.db.OpenStorageTrie(s.originalRoot, obj.address, obj.data.Root, s.trie); err != nil {
+			return common.Hash{}, err
+		}
+	}
+	var deletions []common.Hash
+	for key, origin := range obj.uncommittedStorage {
+		value, exist := obj.pendingStorage[key]
+		if !exist || value == origin {
+			continue
+		}
+		if value != (common.Hash{}) {
+			if err := tr.UpdateStorage(obj.address, key[:], common.TrimLeftZeroes(value[:])); err != nil {
+				return common.Hash{}, err
+			}
+		} else {
+			deletions = append(deletions, key)
+		}
+	}
+	// Deletions go last, mirroring stateObject.updateTrie
+	for _, key := range deletions {
+		if err := tr.DeleteStorage(obj.address, key[:]); err != nil {
+			return common.Hash{}, err
+		}
+	}
+	return tr.Hash(), nil
+}
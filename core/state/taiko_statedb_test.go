@@ -0,0 +1,58 @@
+package state
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/holiman/uint256"
+)
+
+func TestCheckKnownAccounts(t *testing.T) {
+	var (
+		addr  = common.BytesToAddress([]byte{0x01})
+		slot  = common.BytesToHash([]byte{0x01})
+		other = common.BytesToAddress([]byte{0x02})
+	)
+	db := NewDatabaseForTesting()
+	state, _ := New(types.EmptyRootHash, db)
+	state.SetBalance(addr, uint256.NewInt(1), tracing.BalanceChangeUnspecified)
+	state.SetState(addr, slot, common.Hash{0x01})
+	root, _ := state.Commit(0, true)
+
+	state, _ = New(root, db)
+	committed := state.GetStorageRoot(addr)
+
+	// Mutate the storage as a finalised transaction would
+	state.SetState(addr, slot, common.Hash{0x02})
+	state.Finalise(true)
+
+	shadow := state.Copy()
+	shadow.IntermediateRoot(true)
+	updated := shadow.GetStorageRoot(addr)
+
+	for i, test := range []struct {
+		accounts types.KnownAccounts
+		ok       bool
+	}{
+		{types.KnownAccounts{addr: {StorageRoot: &updated}}, true},
+		{types.KnownAccounts{addr: {StorageRoot: &committed}}, false},
+		{types.KnownAccounts{addr: {StorageSlots: map[common.Hash]common.Hash{slot: {0x02}}}}, true},
+		{types.KnownAccounts{addr: {StorageSlots: map[common.Hash]common.Hash{slot: {0x01}}}}, false},
+		{types.KnownAccounts{other: {StorageRoot: &types.EmptyRootHash}}, true},
+	} {
+		err := state.CheckKnownAccounts(test.accounts)
+		if test.ok && err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+		}
+		if !test.ok && !errors.Is(err, types.ErrConditionalFailed) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, types.ErrConditionalFailed)
+		}
+	}
+	// The check must not hash the storage of the state itself
+	if have := state.GetStorageRoot(addr); have != committed {
+		t.Errorf("storage root of the state changed: have %v, want %v", have, committed)
+	}
+}
@@ -36,6 +36,8 @@ import (
 type StateProcessor struct {
 	config *params.ChainConfig // Chain configuration options
 	chain  *HeaderChain        // Canonical header chain
+
+	parallel bool // CHANGE(taiko): whether to execute transactions in parallel
 }
 
 // NewStateProcessor initialises a new StateProcessor.
@@ -81,8 +83,19 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 	if p.config.IsPrague(block.Number(), block.Time()) {
 		ProcessParentBlockHash(block.ParentHash(), vmenv, statedb)
 	}
+	// CHANGE(taiko): execute the transactions speculatively in parallel if enabled.
+	txs := block.Transactions()
+	if p.parallelizable(block, statedb, cfg) {
+		if receipts, err = p.applyParallel(block, statedb, cfg, vmenv, gp, usedGas); err != nil {
+			return nil, err
+		}
+		for _, receipt := range receipts {
+			allLogs = append(allLogs, receipt.Logs...)
+		}
+		txs = nil
+	}
 	// Iterate over and process the individual transactions
-	for i, tx := range block.Transactions() {
+	for i, tx := range txs {
 		// CHANGE(taiko): mark the first transaction as anchor transaction.
 		if i == 0 && p.config.Taiko {
 			if err := tx.MarkAsAnchor(); err != nil {
@@ -1501,6 +1501,7 @@ func (p *BlobPool) Pending(filter txpool.PendingFilter) map[common.Address][]*tx
 				GasTipCap: tx.execTipCap,
 				Gas:       tx.execGas,
 				BlobGas:   tx.blobGas,
+				Size:      uint64(tx.size), // CHANGE(taiko)
 			})
 		}
 		if len(lazies) > 0 {
@@ -60,4 +60,12 @@ var (
 	// input transaction of non-blob type when a blob transaction from this sender
 	// remains pending (and vice-versa).
 	ErrAlreadyReserved = errors.New("address already reserved")
+
+	// ErrPolicyRejected is returned if a transaction is rejected by the admission
+	// policy configured for the pool, e.g. due to its recipient.
+	ErrPolicyRejected = errors.New("rejected by pool policy")
+
+	// ErrSenderRateLimited is returned if a remote sender exceeds the rate of
+	// transactions admitted by the pool policy.
+	ErrSenderRateLimited = errors.New("sender rate limited")
 )
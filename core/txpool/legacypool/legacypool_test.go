@@ -49,6 +49,9 @@ var (
 
 	// eip1559Config is a chain config with EIP-1559 enabled at block 0.
 	eip1559Config *params.ChainConfig
+
+	// eip7702Config is a chain config with EIP-7702 enabled at block 0.
+	eip7702Config *params.ChainConfig
 )
 
 func init() {
@@ -59,6 +62,10 @@ func init() {
 	eip1559Config = &cpy
 	eip1559Config.BerlinBlock = common.Big0
 	eip1559Config.LondonBlock = common.Big0
+
+	cpy7702 := *eip1559Config
+	eip7702Config = &cpy7702
+	eip7702Config.EIP7702Block = common.Big0
 }
 
 type testBlockChain struct {
@@ -129,6 +136,24 @@ func dynamicFeeTx(nonce uint64, gaslimit uint64, gasFee *big.Int, tip *big.Int,
 	return tx
 }
 
+func setCodeTx(nonce uint64, key *ecdsa.PrivateKey, auths []types.SetCodeAuthorization) *types.Transaction {
+	return pricedSetCodeTx(nonce, 250000, uint256.NewInt(1000), uint256.NewInt(1), key, auths)
+}
+
+func pricedSetCodeTx(nonce uint64, gaslimit uint64, gasFee, tip *uint256.Int, key *ecdsa.PrivateKey, auths []types.SetCodeAuthorization) *types.Transaction {
+	tx, _ := types.SignNewTx(key, types.LatestSignerForChainID(params.TestChainConfig.ChainID), &types.SetCodeTx{
+		ChainID:   uint256.MustFromBig(params.TestChainConfig.ChainID),
+		Nonce:     nonce,
+		GasTipCap: tip,
+		GasFeeCap: gasFee,
+		Gas:       gaslimit,
+		To:        common.Address{},
+		Value:     uint256.NewInt(100),
+		AuthList:  auths,
+	})
+	return tx
+}
+
 func makeAddressReserver() txpool.AddressReserver {
 	var (
 		reserved = make(map[common.Address]struct{})
@@ -2670,3 +2695,72 @@ func BenchmarkMultiAccountBatchInsert(b *testing.B) {
 		pool.addRemotesSync([]*types.Transaction{tx})
 	}
 }
+
+// Tests that the pool enforces the in-flight transaction limits of delegated
+// accounts and rejects authorizations signed by accounts with pooled
+// transactions.
+func TestSetCodeTransactions(t *testing.T) {
+	t.Parallel()
+
+	pool, keyA := setupPoolWithConfig(eip7702Config)
+	defer pool.Close()
+
+	var (
+		keyB, _ = crypto.GenerateKey()
+		keyC, _ = crypto.GenerateKey()
+		keyD, _ = crypto.GenerateKey()
+		addrA   = crypto.PubkeyToAddress(keyA.PublicKey)
+		addrB   = crypto.PubkeyToAddress(keyB.PublicKey)
+		addrC   = crypto.PubkeyToAddress(keyC.PublicKey)
+		addrD   = crypto.PubkeyToAddress(keyD.PublicKey)
+	)
+	for _, addr := range []common.Address{addrA, addrB, addrC, addrD} {
+		testAddBalance(pool, addr, big.NewInt(params.Ether))
+	}
+	authorize := func(key *ecdsa.PrivateKey, nonce uint64) types.SetCodeAuthorization {
+		auth, _ := types.SignSetCode(key, types.SetCodeAuthorization{
+			ChainID: *uint256.MustFromBig(params.TestChainConfig.ChainID),
+			Address: common.Address{0xaa},
+			Nonce:   nonce,
+		})
+		return auth
+	}
+	// Set code transactions need at least one authorization
+	if err := pool.addRemoteSync(setCodeTx(0, keyB, nil)); !errors.Is(err, core.ErrEmptyAuthList) {
+		t.Fatalf("empty authorization list: have %v, want %v", err, core.ErrEmptyAuthList)
+	}
+	// Authorities with multiple pooled transactions are reserved
+	if err := pool.addRemotesSync([]*types.Transaction{transaction(0, 100000, keyA), transaction(1, 100000, keyA)})[1]; err != nil {
+		t.Fatalf("failed to add transactions: %v", err)
+	}
+	if err := pool.addRemoteSync(setCodeTx(0, keyB, []types.SetCodeAuthorization{authorize(keyA, 2)})); !errors.Is(err, ErrAuthorityReserved) {
+		t.Fatalf("reserved authority: have %v, want %v", err, ErrAuthorityReserved)
+	}
+	// Accounts with a pending authorization may have a single in-flight transaction
+	if err := pool.addRemoteSync(setCodeTx(0, keyB, []types.SetCodeAuthorization{authorize(keyD, 0)})); err != nil {
+		t.Fatalf("failed to add set code transaction: %v", err)
+	}
+	if err := pool.addRemoteSync(transaction(0, 100000, keyD)); err != nil {
+		t.Fatalf("failed to add first transaction of authority: %v", err)
+	}
+	if err := pool.addRemoteSync(transaction(1, 100000, keyD)); !errors.Is(err, ErrInflightTxLimitReached) {
+		t.Fatalf("second transaction of authority: have %v, want %v", err, ErrInflightTxLimitReached)
+	}
+	// Delegated accounts may have a single in-flight transaction, which can be replaced
+	pool.mu.Lock()
+	pool.currentState.SetCode(addrC, types.AddressToDelegation(common.Address{0xaa}))
+	pool.mu.Unlock()
+
+	if err := pool.addRemoteSync(pricedTransaction(0, 100000, big.NewInt(1), keyC)); err != nil {
+		t.Fatalf("failed to add first transaction of delegated account: %v", err)
+	}
+	if err := pool.addRemoteSync(pricedTransaction(1, 100000, big.NewInt(1), keyC)); !errors.Is(err, ErrInflightTxLimitReached) {
+		t.Fatalf("second transaction of delegated account: have %v, want %v", err, ErrInflightTxLimitReached)
+	}
+	if err := pool.addRemoteSync(pricedTransaction(0, 100000, big.NewInt(2), keyC)); err != nil {
+		t.Fatalf("failed to replace transaction of delegated account: %v", err)
+	}
+	if err := validatePoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package legacypool
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// conditionalExpiredMeter counts the conditional transactions dropped because
+// their block number or timestamp range passed.
+var conditionalExpiredMeter = metrics.NewRegisteredMeter("txpool/conditional/expired", nil)
+
+// dropExpiredConditionals removes the conditional transactions which cannot be
+// included in any block built on top of the given head anymore.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *LegacyPool) dropExpiredConditionals(head *types.Header) {
+	var expired []common.Hash
+	pool.all.Range(func(hash common.Hash, tx *types.Transaction, local bool) bool {
+		if cond := tx.Conditional(); cond != nil && cond.Expired(head) {
+			expired = append(expired, hash)
+		}
+		return true
+	}, true, true)
+
+	for _, hash := range expired {
+		log.Trace("Dropping expired conditional transaction", "hash", hash)
+		pool.removeTx(hash, true, true)
+	}
+	conditionalExpiredMeter.Mark(int64(len(expired)))
+}
+
+// withoutConditionals filters out the conditional transactions from the given
+// set. Their preconditions only live in memory, so they are never persisted
+// into the journal or the snapshot, otherwise they would be reloaded as plain
+// transactions after a restart, includable even if the preconditions fail.
+func withoutConditionals(all map[common.Address]types.Transactions) map[common.Address]types.Transactions {
+	for addr, txs := range all {
+		kept := txs[:0]
+		for _, tx := range txs {
+			if tx.Conditional() == nil {
+				kept = append(kept, tx)
+			}
+		}
+		if len(kept) == 0 {
+			delete(all, addr)
+		} else {
+			all[addr] = kept
+		}
+	}
+	return all
+}
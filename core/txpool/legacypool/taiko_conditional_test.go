@@ -0,0 +1,80 @@
+package legacypool
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Tests that conditional transactions which cannot be included anymore are
+// dropped on pool resets, along with the promotions they gate.
+func TestExpiredConditionalDropping(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupPool()
+	defer pool.Close()
+
+	testAddBalance(pool, crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+	// Add a conditional transaction expiring at the head, and a conditional one
+	// valid for future blocks
+	head := pool.chain.CurrentBlock()
+
+	expiring := pricedTransaction(0, 100000, big.NewInt(1), key)
+	expiring.SetConditional(&types.TransactionConditional{BlockNumberMax: (*hexutil.Big)(head.Number)})
+
+	future := pricedTransaction(1, 100000, big.NewInt(1), key)
+	future.SetConditional(&types.TransactionConditional{BlockNumberMax: (*hexutil.Big)(new(big.Int).Add(head.Number, big.NewInt(10)))})
+
+	for i, err := range pool.addRemotesSync([]*types.Transaction{expiring, future}) {
+		if err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+	if pending, _ := pool.Stats(); pending != 2 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 2)
+	}
+	<-pool.requestReset(nil, nil)
+
+	if pool.Has(expiring.Hash()) {
+		t.Fatal("expired conditional transaction not dropped")
+	}
+	if pending, queued := pool.Stats(); pending != 0 || queued != 1 {
+		t.Fatalf("pool stats mismatch: have %d pending and %d queued, want 0 and 1", pending, queued)
+	}
+	if err := validatePoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that conditional transactions are never persisted into the snapshot, as
+// they would be restored without their preconditions.
+func TestConditionalNotSnapshotted(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupPool()
+	defer pool.Close()
+
+	testAddBalance(pool, crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+	plain := pricedTransaction(0, 100000, big.NewInt(1), key)
+	conditional := pricedTransaction(1, 100000, big.NewInt(1), key)
+	conditional.SetConditional(&types.TransactionConditional{})
+
+	for i, err := range pool.addRemotesSync([]*types.Transaction{plain, conditional}) {
+		if err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+	pool.mu.Lock()
+	snapshotted := pool.snapshotted()
+	pool.mu.Unlock()
+
+	txs := snapshotted[crypto.PubkeyToAddress(key.PublicKey)]
+	if len(txs) != 1 || txs[0].Hash() != plain.Hash() {
+		t.Fatalf("snapshotted transactions mismatch: have %d, want only the unconditional one", len(txs))
+	}
+}
@@ -0,0 +1,128 @@
+package legacypool
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// snapshot is a periodically regenerated dump of all the transactions in the
+// pool, local and remote alike, allowing them to survive node restarts. Blob
+// transactions need no snapshot, the blob pool persists them in its own store.
+type snapshot struct {
+	path string // Filesystem path to store the transactions at
+}
+
+// newTxSnapshot creates a new transaction pool snapshot at the given path.
+func newTxSnapshot(path string) *snapshot {
+	return &snapshot{path: path}
+}
+
+// load parses a transaction pool snapshot from disk, adding its contents into
+// the pool. The transactions are validated against the current state and the
+// ones no longer valid are dropped.
+func (snap *snapshot) load(add func([]*types.Transaction) []error) error {
+	input, err := os.Open(snap.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	var (
+		stream         = rlp.NewStream(input, 0)
+		total, dropped int
+		batch          types.Transactions
+		failure        error
+	)
+	loadBatch := func(txs types.Transactions) {
+		for _, err := range add(txs) {
+			if err != nil {
+				log.Debug("Failed to add snapshotted transaction", "err", err)
+				dropped++
+			}
+		}
+	}
+	for {
+		tx := new(types.Transaction)
+		if err = stream.Decode(tx); err != nil {
+			if err != io.EOF {
+				failure = err
+			}
+			if batch.Len() > 0 {
+				loadBatch(batch)
+			}
+			break
+		}
+		total++
+
+		if batch = append(batch, tx); batch.Len() > 1024 {
+			loadBatch(batch)
+			batch = batch[:0]
+		}
+	}
+	log.Info("Loaded transaction pool snapshot", "transactions", total, "dropped", dropped)
+	return failure
+}
+
+// write regenerates the snapshot with the given transactions, replacing the
+// previous one atomically.
+func (snap *snapshot) write(all map[common.Address]types.Transactions) error {
+	replacement, err := os.OpenFile(snap.path+".new", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	count := 0
+	for _, txs := range all {
+		for _, tx := range txs {
+			if err = rlp.Encode(replacement, tx); err != nil {
+				replacement.Close()
+				return err
+			}
+		}
+		count += len(txs)
+	}
+	if err = replacement.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(snap.path+".new", snap.path); err != nil {
+		return err
+	}
+	log.Debug("Regenerated transaction pool snapshot", "transactions", count, "accounts", len(all))
+	return nil
+}
+
+// snapshotted retrieves all the pending and queued transactions of the pool,
+// grouped by origin account and sorted by nonce. Conditional transactions are
+// left out, see withoutConditionals.
+func (pool *LegacyPool) snapshotted() map[common.Address]types.Transactions {
+	txs := make(map[common.Address]types.Transactions, len(pool.pending)+len(pool.queue))
+	for addr, list := range pool.pending {
+		txs[addr] = append(txs[addr], list.Flatten()...)
+	}
+	for addr, list := range pool.queue {
+		txs[addr] = append(txs[addr], list.Flatten()...)
+	}
+	return withoutConditionals(txs)
+}
+
+// writeSnapshot regenerates the transaction pool snapshot, if enabled.
+func (pool *LegacyPool) writeSnapshot() {
+	if pool.snapshot == nil {
+		return
+	}
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if err := pool.snapshot.write(pool.snapshotted()); err != nil {
+		log.Warn("Failed to write transaction pool snapshot", "err", err)
+	}
+}
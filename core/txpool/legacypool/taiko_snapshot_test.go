@@ -0,0 +1,72 @@
+package legacypool
+
+import (
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// Tests that remote transactions survive a restart of the pool if snapshotting
+// is enabled, and that the ones invalidated meanwhile are dropped.
+func TestSnapshotting(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	blockchain := newTestBlockChain(params.TestChainConfig, 1000000, statedb, new(event.Feed))
+
+	config := testTxPoolConfig
+	config.Snapshot = filepath.Join(t.TempDir(), "snapshot.rlp")
+
+	pool := New(config, blockchain)
+	pool.Init(config.PriceLimit, blockchain.CurrentBlock(), makeAddressReserver())
+
+	first, _ := crypto.GenerateKey()
+	second, _ := crypto.GenerateKey()
+	testAddBalance(pool, crypto.PubkeyToAddress(first.PublicKey), big.NewInt(1000000000))
+	testAddBalance(pool, crypto.PubkeyToAddress(second.PublicKey), big.NewInt(1000000000))
+
+	// Add two pending and a queued transaction from the first account, and a
+	// pending one from the second
+	txs := []*types.Transaction{
+		pricedTransaction(0, 100000, big.NewInt(1), first),
+		pricedTransaction(1, 100000, big.NewInt(1), first),
+		pricedTransaction(3, 100000, big.NewInt(1), first),
+		pricedTransaction(0, 100000, big.NewInt(1), second),
+	}
+	for i, err := range pool.addRemotesSync(txs) {
+		if err != nil {
+			t.Fatalf("failed to add remote transaction %d: %v", i, err)
+		}
+	}
+	if pending, queued := pool.Stats(); pending != 3 || queued != 1 {
+		t.Fatalf("pool stats mismatch: have %d pending and %d queued, want 3 and 1", pending, queued)
+	}
+	// Restart the pool after the first transaction of the first account got
+	// included, and the second account got drained
+	pool.Close()
+	statedb.SetNonce(crypto.PubkeyToAddress(first.PublicKey), 1)
+	statedb.SetBalance(crypto.PubkeyToAddress(second.PublicKey), new(uint256.Int), tracing.BalanceChangeUnspecified)
+	blockchain = newTestBlockChain(params.TestChainConfig, 1000000, statedb, new(event.Feed))
+
+	pool = New(config, blockchain)
+	pool.Init(config.PriceLimit, blockchain.CurrentBlock(), makeAddressReserver())
+	defer pool.Close()
+
+	if pending, queued := pool.Stats(); pending != 1 || queued != 1 {
+		t.Fatalf("restored pool stats mismatch: have %d pending and %d queued, want 1 and 1", pending, queued)
+	}
+	if !pool.Has(txs[1].Hash()) || !pool.Has(txs[2].Hash()) {
+		t.Fatal("valid transactions not restored")
+	}
+	if err := validatePoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
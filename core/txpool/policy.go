@@ -0,0 +1,208 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package txpool
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/holiman/uint256"
+	"golang.org/x/time/rate"
+)
+
+// Policy is a node local set of rules on top of the consensus rules, deciding
+// which transactions are admitted into the pool and in which order pending
+// transactions are included into blocks.
+type Policy interface {
+	// Admit returns an error if the transaction sent by the given account
+	// should not be admitted into the pool. Otherwise the returned callback must
+	// be invoked once the pool decided whether to accept the transaction, as
+	// only the accepted ones are charged against the rate limit of the sender.
+	Admit(tx *types.Transaction, from common.Address, local bool) (func(accepted bool), error)
+
+	// Less reports whether transaction a should be included before b, given
+	// the effective miner tip per gas of both. The ordering is only applied to
+	// block building, the subpools keep evicting their cheapest transactions by
+	// price when full, regardless of the policy.
+	Less(a *LazyTransaction, aTip *uint256.Int, b *LazyTransaction, bTip *uint256.Int) bool
+}
+
+// Transaction orderings supported by the policy configuration.
+const (
+	OrderingPrice      = "price"      // Highest miner tip first
+	OrderingFCFS       = "fcfs"       // First seen first
+	OrderingFeePerByte = "feeperbyte" // Highest miner tip ceiling per byte of transaction data first
+)
+
+// policyRateLimitSenders is the number of senders rate limits are tracked for.
+const policyRateLimitSenders = 4096
+
+// PolicyConfig are the configuration parameters of the transaction pool policy.
+type PolicyConfig struct {
+	Ordering  string           // Ordering of pending transactions, by price if empty
+	RateLimit float64          // Maximum transactions admitted per second from a remote sender, unlimited if zero
+	RateBurst int              // Maximum burst of transactions admitted from a remote sender
+	Allow     []common.Address // Recipients the transactions are restricted to, unrestricted if empty
+	Deny      []common.Address // Recipients whose transactions are rejected
+}
+
+// DefaultPolicy admits all transactions and orders them by miner tip.
+var DefaultPolicy Policy = &policy{less: lessByPrice}
+
+// policy is the Policy assembled from a PolicyConfig.
+type policy struct {
+	less  func(a *LazyTransaction, aTip *uint256.Int, b *LazyTransaction, bTip *uint256.Int) bool
+	allow map[common.Address]struct{}
+	deny  map[common.Address]struct{}
+
+	rate     rate.Limit
+	burst    int
+	limiters lru.BasicLRU[common.Address, *senderLimiter]
+	lock     sync.Mutex // Protects the limiters
+}
+
+// senderLimiter is the rate limiter of a remote sender, along with the number of
+// its admitted transactions the pool has not decided about yet.
+type senderLimiter struct {
+	limiter  *rate.Limiter
+	inflight int
+}
+
+// NewPolicy creates a transaction pool policy from the given configuration.
+func NewPolicy(config PolicyConfig) (Policy, error) {
+	p := &policy{
+		rate:  rate.Limit(config.RateLimit),
+		burst: config.RateBurst,
+	}
+	switch config.Ordering {
+	case "", OrderingPrice:
+		p.less = lessByPrice
+	case OrderingFCFS:
+		p.less = lessByTime
+	case OrderingFeePerByte:
+		p.less = lessByFeePerByte
+	default:
+		return nil, fmt.Errorf("unknown transaction ordering %q", config.Ordering)
+	}
+	if config.RateLimit < 0 || (config.RateLimit > 0 && config.RateBurst < 1) {
+		return nil, fmt.Errorf("invalid rate limit %v with burst %d", config.RateLimit, config.RateBurst)
+	}
+	if config.RateLimit > 0 {
+		p.limiters = lru.NewBasicLRU[common.Address, *senderLimiter](policyRateLimitSenders)
+	}
+	if len(config.Allow) > 0 {
+		p.allow = make(map[common.Address]struct{})
+		for _, addr := range config.Allow {
+			p.allow[addr] = struct{}{}
+		}
+	}
+	p.deny = make(map[common.Address]struct{})
+	for _, addr := range config.Deny {
+		p.deny[addr] = struct{}{}
+	}
+	return p, nil
+}
+
+// Admit implements Policy, checking the recipient lists and rate limiting the
+// transactions of remote senders. The tokens of the admitted transactions are
+// held back until the pool accepts them, and only consumed then.
+func (p *policy) Admit(tx *types.Transaction, from common.Address, local bool) (func(accepted bool), error) {
+	if to := tx.To(); to == nil {
+		if p.allow != nil {
+			return nil, fmt.Errorf("%w: contract creation not allowed", ErrPolicyRejected)
+		}
+	} else {
+		if _, ok := p.deny[*to]; ok {
+			return nil, fmt.Errorf("%w: recipient %v denied", ErrPolicyRejected, *to)
+		}
+		if _, ok := p.allow[*to]; p.allow != nil && !ok {
+			return nil, fmt.Errorf("%w: recipient %v not allowed", ErrPolicyRejected, *to)
+		}
+	}
+	if p.rate == 0 || local {
+		return func(bool) {}, nil
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	sender, ok := p.limiters.Get(from)
+	if !ok {
+		sender = &senderLimiter{limiter: rate.NewLimiter(p.rate, p.burst)}
+		p.limiters.Add(from, sender)
+	}
+	if sender.limiter.Tokens() < float64(sender.inflight+1) {
+		return nil, fmt.Errorf("%w: sender %v", ErrSenderRateLimited, from)
+	}
+	sender.inflight++
+
+	return func(accepted bool) {
+		p.lock.Lock()
+		defer p.lock.Unlock()
+
+		sender.inflight--
+		if accepted {
+			sender.limiter.Allow()
+		}
+	}, nil
+}
+
+// Less implements Policy.
+func (p *policy) Less(a *LazyTransaction, aTip *uint256.Int, b *LazyTransaction, bTip *uint256.Int) bool {
+	return p.less(a, aTip, b, bTip)
+}
+
+// lessByPrice orders transactions by miner tip, and by the time they were first
+// seen if equal.
+func lessByPrice(a *LazyTransaction, aTip *uint256.Int, b *LazyTransaction, bTip *uint256.Int) bool {
+	if cmp := aTip.Cmp(bTip); cmp != 0 {
+		return cmp > 0
+	}
+	return a.Time.Before(b.Time)
+}
+
+// lessByTime orders transactions by the time they were first seen, and by
+// miner tip if equal.
+func lessByTime(a *LazyTransaction, aTip *uint256.Int, b *LazyTransaction, bTip *uint256.Int) bool {
+	if !a.Time.Equal(b.Time) {
+		return a.Time.Before(b.Time)
+	}
+	return aTip.Gt(bTip)
+}
+
+// lessByFeePerByte orders transactions by the bid ceiling paid per byte of the
+// encoded transaction, and by the time they were first seen if equal. The bid
+// ceiling is the effective miner tip times the gas limit, i.e. the most a
+// transaction may pay the miner, as the gas used is unknown before execution.
+func lessByFeePerByte(a *LazyTransaction, aTip *uint256.Int, b *LazyTransaction, bTip *uint256.Int) bool {
+	// Compare a.tip*a.gas/a.size with b.tip*b.gas/b.size by cross multiplying
+	// the sizes, the products may exceed 256 bits.
+	var (
+		aFee = new(big.Int).Mul(aTip.ToBig(), new(big.Int).SetUint64(a.Gas))
+		bFee = new(big.Int).Mul(bTip.ToBig(), new(big.Int).SetUint64(b.Gas))
+	)
+	aFee.Mul(aFee, new(big.Int).SetUint64(max(b.Size, 1)))
+	bFee.Mul(bFee, new(big.Int).SetUint64(max(a.Size, 1)))
+
+	if cmp := aFee.Cmp(bFee); cmp != 0 {
+		return cmp > 0
+	}
+	return a.Time.Before(b.Time)
+}
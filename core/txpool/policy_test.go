@@ -0,0 +1,136 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package txpool
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/holiman/uint256"
+)
+
+func TestPolicyAdmission(t *testing.T) {
+	var (
+		allowed = common.Address{0x01}
+		denied  = common.Address{0x02}
+		other   = common.Address{0x03}
+		sender  = common.Address{0xaa}
+	)
+	call := func(to *common.Address) *types.Transaction {
+		return types.NewTx(&types.LegacyTx{To: to})
+	}
+	policy, err := NewPolicy(PolicyConfig{Allow: []common.Address{allowed, denied}, Deny: []common.Address{denied}, RateLimit: 1, RateBurst: 2})
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	for i, test := range []struct {
+		tx    *types.Transaction
+		local bool
+		err   error
+	}{
+		{tx: call(&allowed)},
+		{tx: call(&denied), err: ErrPolicyRejected},
+		{tx: call(&other), err: ErrPolicyRejected},
+		{tx: call(nil), err: ErrPolicyRejected},
+		{tx: call(&allowed)},
+		{tx: call(&allowed), err: ErrSenderRateLimited},
+		{tx: call(&allowed), local: true},
+	} {
+		settle, err := policy.Admit(test.tx, sender, test.local)
+		if !errors.Is(err, test.err) {
+			t.Errorf("test %d: got error \"%v\", want \"%v\"", i, err, test.err)
+		}
+		if err == nil {
+			settle(true)
+		}
+	}
+	// Other senders are rate limited independently.
+	settle, err := policy.Admit(call(&allowed), other, false)
+	if err != nil {
+		t.Fatalf("failed to admit transaction from other sender: %v", err)
+	}
+	// The transactions rejected by the pool are not charged, but the pending
+	// admissions hold their tokens back.
+	if _, err := policy.Admit(call(&allowed), other, false); err != nil {
+		t.Fatalf("failed to admit second transaction from other sender: %v", err)
+	}
+	if _, err := policy.Admit(call(&allowed), other, false); !errors.Is(err, ErrSenderRateLimited) {
+		t.Errorf("admitted transaction beyond the pending ones: %v", err)
+	}
+	settle(false)
+	if _, err := policy.Admit(call(&allowed), other, false); err != nil {
+		t.Errorf("rejected transaction charged: %v", err)
+	}
+	if _, err := NewPolicy(PolicyConfig{Ordering: "random"}); err == nil {
+		t.Error("created policy with unknown ordering")
+	}
+}
+
+// Tests that the fee per byte ordering compares the bid ceilings exactly, even
+// if the tip times the gas limit exceeds 256 bits.
+func TestPolicyFeePerByteOrdering(t *testing.T) {
+	var (
+		huge  = new(uint256.Int).Lsh(uint256.NewInt(1), 255)
+		early = time.Unix(1, 0)
+		late  = time.Unix(2, 0)
+	)
+	policy, err := NewPolicy(PolicyConfig{Ordering: OrderingFeePerByte})
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	for i, test := range []struct {
+		a, b       *LazyTransaction
+		aTip, bTip *uint256.Int
+		want       bool
+	}{
+		// Smaller transactions win at the same bid ceiling
+		{
+			a: &LazyTransaction{Gas: 21000, Size: 100, Time: late}, aTip: uint256.NewInt(1),
+			b: &LazyTransaction{Gas: 21000, Size: 200, Time: early}, bTip: uint256.NewInt(1),
+			want: true,
+		},
+		// Bid ceilings beyond 256 bits are not saturated
+		{
+			a: &LazyTransaction{Gas: 3, Size: 1, Time: late}, aTip: huge,
+			b: &LazyTransaction{Gas: 2, Size: 1, Time: early}, bTip: huge,
+			want: true,
+		},
+		// Large gas limits at the maximum tip
+		{
+			a: &LazyTransaction{Gas: math.MaxUint64, Size: 3, Time: early}, aTip: new(uint256.Int).SetAllOne(),
+			b: &LazyTransaction{Gas: math.MaxUint64 - 1, Size: 2, Time: late}, bTip: new(uint256.Int).SetAllOne(),
+			want: false,
+		},
+		// Equal fees per byte are ordered by time
+		{
+			a: &LazyTransaction{Gas: math.MaxUint64, Size: 2, Time: late}, aTip: huge,
+			b: &LazyTransaction{Gas: math.MaxUint64, Size: 2, Time: early}, bTip: huge,
+			want: false,
+		},
+	} {
+		if have := policy.Less(test.a, test.aTip, test.b, test.bTip); have != test.want {
+			t.Errorf("test %d: have %v, want %v", i, have, test.want)
+		}
+		if have := policy.Less(test.b, test.bTip, test.a, test.aTip); have == test.want {
+			t.Errorf("test %d: reversed comparison not antisymmetric", i)
+		}
+	}
+}
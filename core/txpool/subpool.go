@@ -41,6 +41,8 @@ type LazyTransaction struct {
 
 	Gas     uint64 // Amount of gas required by the transaction
 	BlobGas uint64 // Amount of blob gas required by the transaction
+
+	Size uint64 // CHANGE(taiko): encoded size of the transaction, used for ordering by fee per byte
 }
 
 // Resolve retrieves the full transaction belonging to a lazy handle if it is still
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"math/big"
 	"sync"
+	"sync/atomic"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
@@ -28,6 +29,7 @@ import (
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/params"
 )
 
 // TxStatus is the current status of a transaction as seen by the pool.
@@ -57,6 +59,10 @@ type BlockChain interface {
 
 	// SubscribeChainHeadEvent subscribes to new blocks being added to the chain.
 	SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription
+
+	// CHANGE(taiko): Config retrieves the chain's fork configuration, needed to
+	// recover the senders for the pool policy.
+	Config() *params.ChainConfig
 }
 
 // TxPool is an aggregator for various transaction specific pools, collectively
@@ -75,6 +81,9 @@ type TxPool struct {
 	term chan struct{}           // Termination channel to detect a closed pool
 
 	sync chan chan error // Testing / simulator channel to block until internal reset is done
+
+	policy atomic.Pointer[Policy] // CHANGE(taiko): admission and ordering policy of the pool
+	signer types.Signer           // CHANGE(taiko): signer of the subpools, sharing the cached senders
 }
 
 // New creates a new transaction pool to gather, sort and filter inbound
@@ -91,6 +100,7 @@ func New(gasTip uint64, chain BlockChain, subpools []SubPool) (*TxPool, error) {
 		quit:         make(chan chan error),
 		term:         make(chan struct{}),
 		sync:         make(chan chan error),
+		signer:       types.LatestSigner(chain.Config()), // CHANGE(taiko)
 	}
 	for i, subpool := range subpools {
 		if err := subpool.Init(gasTip, head, pool.reserver(i, subpool)); err != nil {
@@ -317,11 +327,18 @@ func (p *TxPool) Add(txs []*types.Transaction, local bool, sync bool) []error {
 	// so we can piece back the returned errors into the original order.
 	txsets := make([][]*types.Transaction, len(p.subpools))
 	splits := make([]int, len(txs))
+	errs := make([]error, len(txs))
+	settles := make([]func(bool), len(txs)) // CHANGE(taiko)
 
 	for i, tx := range txs {
 		// Mark this transaction belonging to no-subpool
 		splits[i] = -1
 
+		// CHANGE(taiko): reject the transactions disallowed by the pool policy.
+		if settles[i], errs[i] = p.admit(tx, local); errs[i] != nil {
+			continue
+		}
+
 		// Try to find a subpool that accepts the transaction
 		for j, subpool := range p.subpools {
 			if subpool.Filter(tx) {
@@ -337,8 +354,11 @@ func (p *TxPool) Add(txs []*types.Transaction, local bool, sync bool) []error {
 	for i := 0; i < len(p.subpools); i++ {
 		errsets[i] = p.subpools[i].Add(txsets[i], local, sync)
 	}
-	errs := make([]error, len(txs))
 	for i, split := range splits {
+		// CHANGE(taiko): skip the transactions rejected by the pool policy.
+		if errs[i] != nil {
+			continue
+		}
 		// If the transaction was rejected by all subpools, mark it unsupported
 		if split == -1 {
 			errs[i] = core.ErrTxTypeNotSupported
@@ -348,9 +368,41 @@ func (p *TxPool) Add(txs []*types.Transaction, local bool, sync bool) []error {
 		errs[i] = errsets[split][0]
 		errsets[split] = errsets[split][1:]
 	}
+	// CHANGE(taiko): settle the admissions with the decisions of the subpools,
+	// charging only the accepted transactions against the rate limits.
+	for i, settle := range settles {
+		if settle != nil {
+			settle(errs[i] == nil)
+		}
+	}
 	return errs
 }
 
+// CHANGE(taiko): SetPolicy sets the admission and ordering policy of the pool.
+func (p *TxPool) SetPolicy(policy Policy) {
+	p.policy.Store(&policy)
+}
+
+// CHANGE(taiko): Policy returns the admission and ordering policy of the pool.
+func (p *TxPool) Policy() Policy {
+	if policy := p.policy.Load(); policy != nil {
+		return *policy
+	}
+	return DefaultPolicy
+}
+
+// CHANGE(taiko): admit checks whether a transaction is admitted by the pool
+// policy, returning the callback to settle the admission with. The sender is
+// recovered with the signer of the subpools, which find it cached afterwards.
+// Transactions with invalid signatures are left to the subpools to reject.
+func (p *TxPool) admit(tx *types.Transaction, local bool) (func(bool), error) {
+	from, err := types.Sender(p.signer, tx)
+	if err != nil {
+		return nil, nil
+	}
+	return p.Policy().Admit(tx, from, local)
+}
+
 // Pending retrieves all currently processable transactions, grouped by origin
 // account and sorted by nonce.
 //
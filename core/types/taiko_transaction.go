@@ -40,3 +40,11 @@ func (tx *AccessListTx) markAsAnchor() error {
 func (tx *BlobTx) markAsAnchor() error {
 	return ErrInvalidTxType
 }
+
+func (tx *SetCodeTx) isAnchor() bool {
+	return false
+}
+
+func (tx *SetCodeTx) markAsAnchor() error {
+	return ErrInvalidTxType
+}
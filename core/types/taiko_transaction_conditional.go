@@ -0,0 +1,133 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// MaxTransactionConditionalCost is the maximum number of storage roots and
+// slots a transaction conditional may check.
+const MaxTransactionConditionalCost = 1000
+
+var (
+	// ErrConditionalInvalid is returned if a transaction conditional is malformed.
+	ErrConditionalInvalid = errors.New("invalid transaction conditional")
+
+	// ErrConditionalFailed is returned if a transaction conditional does not hold.
+	ErrConditionalFailed = errors.New("transaction conditional failed")
+)
+
+// KnownAccount is the expected storage of an account, either its storage root
+// or the values of individual storage slots.
+type KnownAccount struct {
+	StorageRoot  *common.Hash
+	StorageSlots map[common.Hash]common.Hash
+}
+
+// MarshalJSON encodes the storage root as a single hash, or the storage slots
+// as an object.
+func (ka KnownAccount) MarshalJSON() ([]byte, error) {
+	if ka.StorageRoot != nil {
+		return json.Marshal(ka.StorageRoot)
+	}
+	return json.Marshal(ka.StorageSlots)
+}
+
+// UnmarshalJSON decodes either a storage root hash, or an object of storage
+// slots and their values.
+func (ka *KnownAccount) UnmarshalJSON(input []byte) error {
+	var root common.Hash
+	if err := json.Unmarshal(input, &root); err == nil {
+		ka.StorageRoot, ka.StorageSlots = &root, nil
+		return nil
+	}
+	var slots map[common.Hash]common.Hash
+	if err := json.Unmarshal(input, &slots); err != nil {
+		return fmt.Errorf("known account is neither a storage root nor storage slots: %w", err)
+	}
+	ka.StorageRoot, ka.StorageSlots = nil, slots
+	return nil
+}
+
+// KnownAccounts is the expected storage of a set of accounts.
+type KnownAccounts map[common.Address]KnownAccount
+
+// TransactionConditional is a set of preconditions a transaction is only to
+// be included under, as submitted with eth_sendRawTransactionConditional.
+type TransactionConditional struct {
+	KnownAccounts  KnownAccounts   `json:"knownAccounts"`
+	BlockNumberMin *hexutil.Big    `json:"blockNumberMin,omitempty"`
+	BlockNumberMax *hexutil.Big    `json:"blockNumberMax,omitempty"`
+	TimestampMin   *hexutil.Uint64 `json:"timestampMin,omitempty"`
+	TimestampMax   *hexutil.Uint64 `json:"timestampMax,omitempty"`
+}
+
+// Cost returns the number of storage roots and slots checked by the conditional.
+func (c *TransactionConditional) Cost() int {
+	cost := 0
+	for _, account := range c.KnownAccounts {
+		if account.StorageRoot != nil {
+			cost++
+		}
+		cost += len(account.StorageSlots)
+	}
+	return cost
+}
+
+// Validate checks that the conditional is well formed and within the cost limit.
+func (c *TransactionConditional) Validate() error {
+	if c.BlockNumberMin != nil && c.BlockNumberMax != nil && c.BlockNumberMin.ToInt().Cmp(c.BlockNumberMax.ToInt()) > 0 {
+		return fmt.Errorf("%w: block number range [%v, %v] is empty", ErrConditionalInvalid, c.BlockNumberMin, c.BlockNumberMax)
+	}
+	if c.TimestampMin != nil && c.TimestampMax != nil && *c.TimestampMin > *c.TimestampMax {
+		return fmt.Errorf("%w: timestamp range [%d, %d] is empty", ErrConditionalInvalid, *c.TimestampMin, *c.TimestampMax)
+	}
+	if cost := c.Cost(); cost > MaxTransactionConditionalCost {
+		return fmt.Errorf("%w: cost %d exceeds limit %d", ErrConditionalInvalid, cost, MaxTransactionConditionalCost)
+	}
+	return nil
+}
+
+// CheckBlock returns an error if a block with the given number and timestamp
+// falls outside the block number or timestamp range of the conditional.
+func (c *TransactionConditional) CheckBlock(number *big.Int, time uint64) error {
+	if c.BlockNumberMin != nil && number.Cmp(c.BlockNumberMin.ToInt()) < 0 {
+		return fmt.Errorf("%w: block number %v below minimum %v", ErrConditionalFailed, number, c.BlockNumberMin)
+	}
+	if c.BlockNumberMax != nil && number.Cmp(c.BlockNumberMax.ToInt()) > 0 {
+		return fmt.Errorf("%w: block number %v above maximum %v", ErrConditionalFailed, number, c.BlockNumberMax)
+	}
+	if c.TimestampMin != nil && time < uint64(*c.TimestampMin) {
+		return fmt.Errorf("%w: timestamp %d below minimum %d", ErrConditionalFailed, time, *c.TimestampMin)
+	}
+	if c.TimestampMax != nil && time > uint64(*c.TimestampMax) {
+		return fmt.Errorf("%w: timestamp %d above maximum %d", ErrConditionalFailed, time, *c.TimestampMax)
+	}
+	return nil
+}
+
+// Expired reports whether no block built on top of the given head can satisfy
+// the block number or timestamp range of the conditional anymore.
+func (c *TransactionConditional) Expired(head *Header) bool {
+	if c.BlockNumberMax != nil && head.Number.Cmp(c.BlockNumberMax.ToInt()) >= 0 {
+		return true
+	}
+	return c.TimestampMax != nil && head.Time >= uint64(*c.TimestampMax)
+}
+
+// Conditional returns the preconditions the transaction was submitted with, or
+// nil if it is unconditional.
+func (tx *Transaction) Conditional() *TransactionConditional {
+	return tx.conditional.Load()
+}
+
+// SetConditional attaches the preconditions the transaction was submitted with.
+// The conditional is node local metadata, it is not part of the encoding.
+func (tx *Transaction) SetConditional(cond *TransactionConditional) {
+	tx.conditional.Store(cond)
+}
@@ -0,0 +1,86 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestTransactionConditionalJSON(t *testing.T) {
+	input := `{
+		"knownAccounts": {
+			"0x000000000000000000000000000000000000000a": "0x00000000000000000000000000000000000000000000000000000000000000ff",
+			"0x000000000000000000000000000000000000000b": {"0x0000000000000000000000000000000000000000000000000000000000000001": "0x0000000000000000000000000000000000000000000000000000000000000002"}
+		},
+		"blockNumberMin": "0x10",
+		"timestampMax": "0x64"
+	}`
+	var cond TransactionConditional
+	if err := json.Unmarshal([]byte(input), &cond); err != nil {
+		t.Fatalf("failed to decode conditional: %v", err)
+	}
+	root := cond.KnownAccounts[common.BytesToAddress([]byte{0x0a})]
+	if root.StorageRoot == nil || *root.StorageRoot != common.BytesToHash([]byte{0xff}) {
+		t.Fatalf("storage root mismatch: have %v", root.StorageRoot)
+	}
+	slots := cond.KnownAccounts[common.BytesToAddress([]byte{0x0b})]
+	if slots.StorageSlots[common.BytesToHash([]byte{0x01})] != common.BytesToHash([]byte{0x02}) {
+		t.Fatalf("storage slots mismatch: have %v", slots.StorageSlots)
+	}
+	if cost := cond.Cost(); cost != 2 {
+		t.Fatalf("cost mismatch: have %d, want 2", cost)
+	}
+	blob, err := json.Marshal(&cond)
+	if err != nil {
+		t.Fatalf("failed to encode conditional: %v", err)
+	}
+	var dec TransactionConditional
+	if err := json.Unmarshal(blob, &dec); err != nil {
+		t.Fatalf("failed to decode encoded conditional: %v", err)
+	}
+	if dec.Cost() != 2 || dec.BlockNumberMin.ToInt().Uint64() != 16 || uint64(*dec.TimestampMax) != 100 {
+		t.Fatalf("conditional mismatch after roundtrip: %s", blob)
+	}
+}
+
+func TestTransactionConditionalRanges(t *testing.T) {
+	var cond TransactionConditional
+	if err := json.Unmarshal([]byte(`{"blockNumberMin": "0xa", "blockNumberMax": "0x14", "timestampMin": "0x64", "timestampMax": "0xc8"}`), &cond); err != nil {
+		t.Fatalf("failed to decode conditional: %v", err)
+	}
+	if err := cond.Validate(); err != nil {
+		t.Fatalf("failed to validate conditional: %v", err)
+	}
+	for i, test := range []struct {
+		number, time uint64
+		ok           bool
+	}{
+		{10, 100, true},
+		{20, 200, true},
+		{9, 150, false},
+		{21, 150, false},
+		{15, 99, false},
+		{15, 201, false},
+	} {
+		err := cond.CheckBlock(new(big.Int).SetUint64(test.number), test.time)
+		if test.ok && err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+		}
+		if !test.ok && !errors.Is(err, ErrConditionalFailed) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, ErrConditionalFailed)
+		}
+	}
+	if cond.Expired(&Header{Number: big.NewInt(19), Time: 199}) {
+		t.Error("conditional expired before its last block")
+	}
+	if !cond.Expired(&Header{Number: big.NewInt(20), Time: 150}) || !cond.Expired(&Header{Number: big.NewInt(15), Time: 200}) {
+		t.Error("conditional not expired after its last block")
+	}
+	cond.BlockNumberMin, cond.BlockNumberMax = cond.BlockNumberMax, cond.BlockNumberMin
+	if err := cond.Validate(); !errors.Is(err, ErrConditionalInvalid) {
+		t.Errorf("empty block range error mismatch: have %v, want %v", err, ErrConditionalInvalid)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/holiman/uint256"
+)
+
+// TestParseDelegation tests a few possible delegation designator values and
+// ensures they are parsed correctly.
+func TestParseDelegation(t *testing.T) {
+	addr := common.Address{0x42}
+	for _, tt := range []struct {
+		val  []byte
+		want *common.Address
+	}{
+		{ // simple correct delegation
+			val:  append(DelegationPrefix, addr.Bytes()...),
+			want: &addr,
+		},
+		{ // wrong address size
+			val: append(DelegationPrefix, addr.Bytes()[0:19]...),
+		},
+		{ // short address
+			val: append(DelegationPrefix, 0x42),
+		},
+		{ // long address
+			val: append(append(DelegationPrefix, addr.Bytes()...), 0x42),
+		},
+		{ // wrong prefix size
+			val: append([]byte{0xef, 0x01}, addr.Bytes()...),
+		},
+		{ // wrong prefix
+			val: append([]byte{0xef, 0x01, 0x01}, addr.Bytes()...),
+		},
+		{ // wrong prefix
+			val: append([]byte{0xef, 0x00, 0x00}, addr.Bytes()...),
+		},
+		{ // no prefix
+			val: addr.Bytes(),
+		},
+		{ // no address
+			val: DelegationPrefix,
+		},
+	} {
+		got, ok := ParseDelegation(tt.val)
+		if ok && tt.want == nil {
+			t.Fatalf("expected fail, got %s", got.Hex())
+		}
+		if !ok && tt.want != nil {
+			t.Fatalf("failed to parse, want %s", tt.want.Hex())
+		}
+	}
+}
+
+// TestSetCodeTxEncoding signs a set code transaction and ensures it survives
+// binary and JSON round-trips with its sender and authorities intact.
+func TestSetCodeTxEncoding(t *testing.T) {
+	var (
+		key, _  = crypto.GenerateKey()
+		auth, _ = crypto.GenerateKey()
+		chainID = big.NewInt(167)
+		signer  = NewPragueSigner(chainID)
+	)
+	authorization, err := SignSetCode(auth, SetCodeAuthorization{
+		ChainID: *uint256.MustFromBig(chainID),
+		Address: common.Address{0xaa},
+		Nonce:   7,
+	})
+	if err != nil {
+		t.Fatalf("failed to sign authorization: %v", err)
+	}
+	tx := MustSignNewTx(key, signer, &SetCodeTx{
+		ChainID:   uint256.MustFromBig(chainID),
+		Nonce:     1,
+		To:        common.Address{0xbb},
+		Gas:       100000,
+		GasFeeCap: uint256.NewInt(10),
+		GasTipCap: uint256.NewInt(1),
+		Value:     uint256.NewInt(0),
+		AuthList:  []SetCodeAuthorization{authorization},
+	})
+	if tx.Type() != SetCodeTxType {
+		t.Fatalf("wrong tx type: have %d, want %d", tx.Type(), SetCodeTxType)
+	}
+	blob, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to encode tx: %v", err)
+	}
+	var dec Transaction
+	if err := dec.UnmarshalBinary(blob); err != nil {
+		t.Fatalf("failed to decode tx: %v", err)
+	}
+	if dec.Hash() != tx.Hash() {
+		t.Fatalf("hash mismatch after binary round-trip: have %x, want %x", dec.Hash(), tx.Hash())
+	}
+	js, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatalf("failed to marshal tx: %v", err)
+	}
+	var decJSON Transaction
+	if err := json.Unmarshal(js, &decJSON); err != nil {
+		t.Fatalf("failed to unmarshal tx: %v", err)
+	}
+	if decJSON.Hash() != tx.Hash() {
+		t.Fatalf("hash mismatch after json round-trip: have %x, want %x", decJSON.Hash(), tx.Hash())
+	}
+	if !reflect.DeepEqual(decJSON.SetCodeAuthorizations(), tx.SetCodeAuthorizations()) {
+		t.Fatalf("authorization mismatch after json round-trip")
+	}
+	if from, err := Sender(signer, &dec); err != nil || from != crypto.PubkeyToAddress(key.PublicKey) {
+		t.Fatalf("wrong sender: have %x (%v), want %x", from, err, crypto.PubkeyToAddress(key.PublicKey))
+	}
+	want := []common.Address{crypto.PubkeyToAddress(auth.PublicKey)}
+	if have := dec.SetCodeAuthorities(); !reflect.DeepEqual(have, want) {
+		t.Fatalf("wrong authorities: have %x, want %x", have, want)
+	}
+}
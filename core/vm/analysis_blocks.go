@@ -0,0 +1,185 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// Basic block execution
+//
+// A basic block is a straight run of legacy instructions which only have a
+// constant gas cost and don't touch memory. Such a run can be executed with a
+// single gas and stack check on entry instead of one check per instruction:
+// if the gas available covers the whole block, it covers every prefix of it,
+// and the stack bounds of each instruction are folded into a single range for
+// the stack height on entry. Whenever the check on entry fails, the block is
+// executed instruction by instruction, so that errors are reported exactly as
+// the regular interpreter loop would.
+//
+// The instructions in a block can only fail by consuming all gas, so charging
+// the block upfront is indistinguishable from charging each instruction. The
+// one exception is an execution aborted through EVM.Cancel, which leaves the
+// gas of the unexecuted instructions charged; aborted results are discarded.
+
+// minBlockSteps is the minimum number of instructions in a block for it to be
+// executed as a whole.
+const minBlockSteps = 2
+
+// blockStep is a single step of a basic block.
+type blockStep struct {
+	execute executionFunc // Instruction implementation, nil for fused jumps
+
+	// Fused PUSH+JUMP and PUSH+JUMPI steps, with the pushed destination
+	op    OpCode // JUMP or JUMPI
+	dest  uint64 // Jump destination
+	valid bool   // Whether the destination is a valid JUMPDEST
+	next  uint64 // Position of the instruction following the jump
+}
+
+// basicBlock is a run of instructions executed with a single gas and stack
+// check.
+type basicBlock struct {
+	gas      uint64 // Constant gas of all instructions in the block
+	minStack int    // Minimum stack height on entry
+	maxStack int    // Maximum stack height on entry
+	steps    []blockStep
+}
+
+// fits reports whether a block can be executed as a whole with the given
+// stack height and gas.
+func (b *basicBlock) fits(stackLen int, gas uint64) bool {
+	return b.steps != nil && stackLen >= b.minStack && stackLen <= b.maxStack && gas >= b.gas
+}
+
+// codeBlocks holds the basic blocks of a piece of code, indexed by the program
+// counter they start at. Blocks are analysed lazily as execution reaches them.
+type codeBlocks struct {
+	blocks map[uint64]*basicBlock
+}
+
+func newCodeBlocks() *codeBlocks {
+	return &codeBlocks{blocks: make(map[uint64]*basicBlock)}
+}
+
+// at returns the basic block of the contract's code starting at pc.
+func (cb *codeBlocks) at(contract *Contract, pc uint64, table *JumpTable) *basicBlock {
+	block, ok := cb.blocks[pc]
+	if !ok {
+		block = analyseBlock(contract, pc, table)
+		cb.blocks[pc] = block
+	}
+	return block
+}
+
+// analyseBlock determines the basic block of the contract's code starting at
+// pc. The returned block has no steps if it is too short to be worthwhile.
+func analyseBlock(contract *Contract, pc uint64, table *JumpTable) *basicBlock {
+	var (
+		code   = contract.Code
+		block  = &basicBlock{maxStack: int(params.StackLimit)}
+		height int // stack height relative to the block entry
+		steps  []blockStep
+	)
+	// account adds the gas and stack effect of an instruction to the block.
+	account := func(operation *operation) {
+		block.gas += operation.constantGas
+		block.minStack = max(block.minStack, operation.minStack-height)
+		block.maxStack = min(block.maxStack, operation.maxStack-height)
+		height += int(params.StackLimit) - operation.maxStack
+	}
+	for pos := pc; pos < uint64(len(code)); {
+		op := OpCode(code[pos])
+		operation := table[op]
+		if !blockable(op, operation) {
+			break
+		}
+		account(operation)
+
+		// Fuse a push of a jump destination with the jump itself
+		if op >= PUSH1 && op <= PUSH4 {
+			size := uint64(op - PUSH0)
+			if next := pos + size + 1; next < uint64(len(code)) {
+				if jump := OpCode(code[next]); jump == JUMP || jump == JUMPI {
+					account(table[jump])
+
+					dest := new(uint256.Int).SetBytes(code[pos+1 : next])
+					steps = append(steps, blockStep{
+						op:    jump,
+						dest:  dest.Uint64(),
+						valid: contract.validJumpdest(dest),
+						next:  next + 1,
+					})
+					break
+				}
+			}
+		}
+		steps = append(steps, blockStep{execute: operation.execute})
+		if op == JUMP || op == JUMPI || op == STOP {
+			break
+		}
+		pos++
+		if op >= PUSH1 && op <= PUSH32 {
+			pos += uint64(op - PUSH0)
+		}
+	}
+	if len(steps) >= minBlockSteps || (len(steps) == 1 && steps[0].execute == nil) {
+		block.steps = steps
+	}
+	return block
+}
+
+// blockable reports whether an instruction can be part of a basic block.
+func blockable(op OpCode, operation *operation) bool {
+	if operation.undefined || operation.dynamicGas != nil || operation.memorySize != nil {
+		return false
+	}
+	// GAS observes the gas left, which must not include the gas charged for
+	// the remainder of the block.
+	return op != GAS
+}
+
+// runBlock executes the steps of a basic block whose gas has been charged
+// and stack bounds checked. On return, pc points at the next instruction to
+// be executed.
+func (in *EVMInterpreter) runBlock(block *basicBlock, pc *uint64, scope *ScopeContext) (res []byte, err error) {
+	for i := range block.steps {
+		step := &block.steps[i]
+		if step.execute != nil {
+			if res, err = step.execute(pc, in, scope); err != nil {
+				return res, err
+			}
+			*pc++
+			continue
+		}
+		if in.evm.abort.Load() {
+			return nil, errStopToken
+		}
+		if step.op == JUMPI {
+			if cond := scope.Stack.pop(); cond.IsZero() {
+				*pc = step.next
+				continue
+			}
+		}
+		if !step.valid {
+			return nil, ErrInvalidJump
+		}
+		*pc = step.dest
+	}
+	return res, nil
+}
@@ -0,0 +1,166 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// executeCode runs the given code, returning the outcome of the call.
+func executeCode(code []byte, gas uint64, static bool, config Config) (string, error) {
+	var (
+		address = common.Address{0xc0, 0xde}
+		sender  = common.Address{0x01}
+	)
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	statedb.SetCode(address, code)
+	vmctx := BlockContext{
+		CanTransfer: func(StateDB, common.Address, *uint256.Int) bool { return true },
+		Transfer:    func(StateDB, common.Address, common.Address, *uint256.Int) {},
+		BlockNumber: big.NewInt(0),
+		Random:      &common.Hash{},
+	}
+	evm := NewEVM(vmctx, TxContext{}, statedb, params.MergedTestChainConfig, config)
+
+	var (
+		ret []byte
+		err error
+	)
+	if static {
+		ret, gas, err = evm.StaticCall(AccountRef(sender), address, nil, gas)
+	} else {
+		ret, gas, err = evm.Call(AccountRef(sender), address, nil, gas, new(uint256.Int))
+	}
+	return fmt.Sprintf("ret %x gas %d err %v slot %x", ret, gas, err, statedb.GetState(address, common.Hash{})), err
+}
+
+func TestBlockExecution(t *testing.T) {
+	for i, test := range []struct {
+		code   []byte
+		gas    uint64
+		static bool
+		fails  bool
+	}{
+		{ // Counting loop using a fused PUSH+JUMPI
+			code: []byte{
+				byte(PUSH1), 0x10, byte(JUMPDEST), byte(PUSH1), 0x01, byte(SWAP1), byte(SUB),
+				byte(DUP1), byte(PUSH1), 0x02, byte(JUMPI), byte(PUSH0), byte(SSTORE), byte(GAS),
+				byte(PUSH0), byte(MSTORE), byte(PUSH1), 0x20, byte(PUSH0), byte(RETURN),
+			},
+			gas: 100000,
+		},
+		{ // Fused PUSH+JUMP into a JUMPDEST
+			code: []byte{byte(PUSH1), 0x04, byte(JUMP), byte(INVALID), byte(JUMPDEST), byte(PC), byte(PUSH0), byte(SSTORE)},
+			gas:  100000,
+		},
+		{ // Fused PUSH+JUMP to an invalid destination
+			code:  []byte{byte(PUSH1), 0x03, byte(JUMP), byte(INVALID), byte(JUMPDEST)},
+			gas:   100000,
+			fails: true,
+		},
+		{ // Jump into push data
+			code:  []byte{byte(PUSH1), 0x03, byte(JUMP), byte(PUSH1), byte(JUMPDEST)},
+			gas:   100000,
+			fails: true,
+		},
+		{ // Out of gas in the middle of a block
+			code:  []byte{byte(PUSH1), 0x01, byte(PUSH1), 0x02, byte(ADD), byte(PUSH1), 0x03, byte(MUL), byte(POP), byte(STOP)},
+			gas:   10,
+			fails: true,
+		},
+		{ // Stack underflow in the middle of a block
+			code:  []byte{byte(PUSH1), 0x01, byte(ADD), byte(STOP)},
+			gas:   100000,
+			fails: true,
+		},
+		{ // Write protection in a static context
+			code:   []byte{byte(PUSH1), 0x01, byte(PUSH0), byte(TSTORE), byte(STOP)},
+			gas:    100000,
+			static: true,
+			fails:  true,
+		},
+		{ // Stack overflow
+			code:  append(bytes.Repeat([]byte{byte(PUSH0)}, 1025), byte(STOP)),
+			gas:   100000,
+			fails: true,
+		},
+	} {
+		want, wantErr := executeCode(test.code, test.gas, test.static, Config{})
+		have, _ := executeCode(test.code, test.gas, test.static, Config{EnableBlockExecution: true})
+		if have != want {
+			t.Errorf("test %d: block execution mismatch:\nhave %s\nwant %s", i, have, want)
+		}
+		if (wantErr != nil) != test.fails {
+			t.Errorf("test %d: unexpected outcome %s", i, want)
+		}
+	}
+}
+
+// TestBlockExecutionRandom checks that executing random code as basic blocks
+// has the same outcome as executing it instruction by instruction.
+func TestBlockExecutionRandom(t *testing.T) {
+	// Favour instructions which can be part of basic blocks, along with some
+	// jumps to small destinations.
+	ops := []OpCode{
+		PUSH0, PUSH1, PUSH2, DUP1, DUP2, SWAP1, SWAP2, POP, ADD, SUB, MUL, LT, ISZERO,
+		JUMP, JUMPI, JUMPDEST, PC, GAS, CALLVALUE, MSTORE, MLOAD, SSTORE, TLOAD, STOP,
+	}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		var code []byte
+		for j := rng.Intn(64); j > 0; j-- {
+			op := ops[rng.Intn(len(ops))]
+			code = append(code, byte(op))
+			if op >= PUSH1 && op <= PUSH2 {
+				for k := 0; k < int(op-PUSH0); k++ {
+					code = append(code, byte(rng.Intn(64)))
+				}
+			}
+		}
+		gas := uint64(rng.Intn(20000))
+		want, _ := executeCode(code, gas, false, Config{})
+		have, _ := executeCode(code, gas, false, Config{EnableBlockExecution: true})
+		if have != want {
+			t.Fatalf("code %x: block execution mismatch:\nhave %s\nwant %s", code, have, want)
+		}
+	}
+}
+
+func BenchmarkBlockExecution(b *testing.B) {
+	// Arithmetic loop running until out of gas
+	code := []byte{
+		byte(JUMPDEST), byte(PUSH1), 0x01, byte(PUSH1), 0x02, byte(ADD), byte(DUP1), byte(MUL),
+		byte(PUSH1), 0x03, byte(SWAP1), byte(SUB), byte(POP), byte(PUSH0), byte(JUMP),
+	}
+	for _, enabled := range []bool{false, true} {
+		b.Run(fmt.Sprintf("enabled=%t", enabled), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				executeCode(code, 10_000_000, false, Config{EnableBlockExecution: enabled})
+			}
+		})
+	}
+}
@@ -52,6 +52,13 @@ type Contract struct {
 	jumpdests map[common.Hash]bitvec // Aggregated result of JUMPDEST analysis.
 	analysis  bitvec                 // Locally cached result of JUMPDEST analysis
 
+	// CHANGE(taiko): basic block analysis, cached like the JUMPDEST analysis.
+	blockCache map[common.Hash]*codeBlocks // Aggregated result of basic block analysis
+	blocks     *codeBlocks                 // Locally cached result of basic block analysis
+
+	// CHANGE(taiko): parsed EOF containers, cached like the JUMPDEST analysis.
+	containers map[common.Hash]*Container
+
 	Code     []byte
 	CodeHash common.Hash
 	CodeAddr *common.Address
@@ -60,6 +67,11 @@ type Contract struct {
 	// is the execution frame represented by this object a contract deployment
 	IsDeployment bool
 
+	// EOF execution context, set if the code is an EOF container
+	container   *Container
+	codeSection int
+	returnStack []returnFrame
+
 	Gas   uint64
 	value *uint256.Int
 }
@@ -71,8 +83,12 @@ func NewContract(caller ContractRef, object ContractRef, value *uint256.Int, gas
 	if parent, ok := caller.(*Contract); ok {
 		// Reuse JUMPDEST analysis from parent context if available.
 		c.jumpdests = parent.jumpdests
+		c.blockCache = parent.blockCache // CHANGE(taiko)
+		c.containers = parent.containers // CHANGE(taiko)
 	} else {
 		c.jumpdests = make(map[common.Hash]bitvec)
+		c.blockCache = make(map[common.Hash]*codeBlocks) // CHANGE(taiko)
+		c.containers = make(map[common.Hash]*Container)  // CHANGE(taiko)
 	}
 
 	// Gas should be a pointer so it can safely be reduced through the run
@@ -84,6 +100,26 @@ func NewContract(caller ContractRef, object ContractRef, value *uint256.Int, gas
 	return c
 }
 
+// returnFrame is the caller context saved by CALLF and restored by RETF.
+type returnFrame struct {
+	section int
+	pc      uint64
+}
+
+// setContainer switches the contract to executing the given EOF container,
+// starting at its first code section.
+func (c *Contract) setContainer(container *Container) {
+	c.container = container
+	c.setCodeSection(0)
+}
+
+// setCodeSection switches the executed code to the given code section of the
+// contract's EOF container.
+func (c *Contract) setCodeSection(section int) {
+	c.codeSection = section
+	c.Code = c.container.codeSections[section]
+}
+
 func (c *Contract) validJumpdest(dest *uint256.Int) bool {
 	udest, overflow := dest.Uint64WithOverflow()
 	// PC cannot go beyond len(code) and certainly can't be bigger than 63bits.
@@ -131,6 +167,43 @@ func (c *Contract) isCode(udest uint64) bool {
 	return c.analysis.codeSegment(udest)
 }
 
+// CHANGE(taiko): basicBlocks returns the basic block analysis of the contract's
+// code. Like the JUMPDEST analysis, it is shared with the parent context unless
+// the code has no hash, which is the case for initcode.
+func (c *Contract) basicBlocks() *codeBlocks {
+	if c.blocks != nil {
+		return c.blocks
+	}
+	if c.CodeHash != (common.Hash{}) {
+		blocks, exist := c.blockCache[c.CodeHash]
+		if !exist {
+			blocks = newCodeBlocks()
+			c.blockCache[c.CodeHash] = blocks
+		}
+		c.blocks = blocks
+		return blocks
+	}
+	c.blocks = newCodeBlocks()
+	return c.blocks
+}
+
+// CHANGE(taiko): parseContainer parses the contract's code as an EOF container.
+// Like the JUMPDEST analysis, the result is shared with the parent context unless
+// the code has no hash, which is the case for initcode.
+func (c *Contract) parseContainer() (*Container, error) {
+	if container, ok := c.containers[c.CodeHash]; ok && c.CodeHash != (common.Hash{}) {
+		return container, nil
+	}
+	container := new(Container)
+	if err := container.UnmarshalBinary(c.Code); err != nil {
+		return nil, err
+	}
+	if c.CodeHash != (common.Hash{}) {
+		c.containers[c.CodeHash] = container
+	}
+	return container, nil
+}
+
 // AsDelegate sets the contract to be a delegate call and returns the current
 // contract (for chaining calls)
 func (c *Contract) AsDelegate() *Contract {
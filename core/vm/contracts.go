@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"maps"
 	"math/big"
+	"slices"
 
 	"github.com/consensys/gnark-crypto/ecc"
 	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
@@ -35,6 +36,7 @@ import (
 	"github.com/ethereum/go-ethereum/crypto/blake2b"
 	"github.com/ethereum/go-ethereum/crypto/bn256"
 	"github.com/ethereum/go-ethereum/crypto/kzg4844"
+	"github.com/ethereum/go-ethereum/crypto/secp256r1"
 	"github.com/ethereum/go-ethereum/params"
 	"golang.org/x/crypto/ripemd160"
 )
@@ -143,6 +145,20 @@ var PrecompiledContractsBLS = PrecompiledContractsPrague
 
 var PrecompiledContractsVerkle = PrecompiledContractsPrague
 
+// CHANGE(taiko): PrecompiledContractsRIP7212 contains the RIP-7212 secp256r1
+// signature verification precompile. It is activated through its own fork
+// block and extends the precompile set of whichever Ethereum fork is active.
+var PrecompiledContractsRIP7212 = PrecompiledContracts{
+	common.BytesToAddress([]byte{0x01, 0x00}): &p256Verify{},
+}
+
+// rip7212Precompiles holds the precompile set of each fork extended with the
+// contracts of PrecompiledContractsRIP7212, along with their addresses.
+var rip7212Precompiles = make(map[*PrecompiledContracts]struct {
+	contracts PrecompiledContracts
+	addresses []common.Address
+})
+
 var (
 	PrecompiledAddressesPrague    []common.Address
 	PrecompiledAddressesCancun    []common.Address
@@ -171,25 +187,57 @@ func init() {
 	for k := range PrecompiledContractsPrague {
 		PrecompiledAddressesPrague = append(PrecompiledAddressesPrague, k)
 	}
+	// CHANGE(taiko): pre-compute the RIP-7212 extended precompile sets.
+	for _, set := range []*PrecompiledContracts{
+		&PrecompiledContractsHomestead,
+		&PrecompiledContractsByzantium,
+		&PrecompiledContractsIstanbul,
+		&PrecompiledContractsBerlin,
+		&PrecompiledContractsCancun,
+		&PrecompiledContractsPrague,
+		&PrecompiledContractsVerkle,
+	} {
+		entry := rip7212Precompiles[set]
+		entry.contracts = maps.Clone(*set)
+		maps.Copy(entry.contracts, PrecompiledContractsRIP7212)
+		for k := range entry.contracts {
+			entry.addresses = append(entry.addresses, k)
+		}
+		rip7212Precompiles[set] = entry
+	}
 }
 
-func activePrecompiledContracts(rules params.Rules) PrecompiledContracts {
+// forkPrecompiledContracts returns the precompile set of the active Ethereum fork.
+func forkPrecompiledContracts(rules params.Rules) *PrecompiledContracts {
 	switch {
 	case rules.IsVerkle:
-		return PrecompiledContractsVerkle
+		return &PrecompiledContractsVerkle
 	case rules.IsPrague:
-		return PrecompiledContractsPrague
+		return &PrecompiledContractsPrague
 	case rules.IsCancun:
-		return PrecompiledContractsCancun
+		return &PrecompiledContractsCancun
 	case rules.IsBerlin:
-		return PrecompiledContractsBerlin
+		return &PrecompiledContractsBerlin
 	case rules.IsIstanbul:
-		return PrecompiledContractsIstanbul
+		return &PrecompiledContractsIstanbul
 	case rules.IsByzantium:
-		return PrecompiledContractsByzantium
+		return &PrecompiledContractsByzantium
 	default:
-		return PrecompiledContractsHomestead
+		return &PrecompiledContractsHomestead
+	}
+}
+
+func activePrecompiledContracts(rules params.Rules) PrecompiledContracts {
+	contracts := *forkPrecompiledContracts(rules)
+	// CHANGE(taiko): RIP-7212 extends the precompile set of the active fork.
+	if rules.IsRIP7212 {
+		contracts = rip7212Precompiles[forkPrecompiledContracts(rules)].contracts
+	}
+	// CHANGE(taiko): so do the custom precompiles of the chain config.
+	if len(rules.Precompiles) > 0 {
+		contracts = withCustomPrecompiles(contracts, rules.Precompiles)
 	}
+	return contracts
 }
 
 // ActivePrecompiledContracts returns a copy of precompiled contracts enabled with the current configuration.
@@ -199,6 +247,24 @@ func ActivePrecompiledContracts(rules params.Rules) PrecompiledContracts {
 
 // ActivePrecompiles returns the precompile addresses enabled with the current configuration.
 func ActivePrecompiles(rules params.Rules) []common.Address {
+	// CHANGE(taiko): custom precompiles extend the precompile set of the active fork.
+	if len(rules.Precompiles) > 0 {
+		addresses := slices.Clone(activePrecompiles(rules))
+		for addr := range rules.Precompiles {
+			addresses = append(addresses, addr)
+		}
+		return addresses
+	}
+	return activePrecompiles(rules)
+}
+
+// activePrecompiles returns the addresses of the precompiles of the active
+// fork, including RIP-7212 if enabled.
+func activePrecompiles(rules params.Rules) []common.Address {
+	// CHANGE(taiko): RIP-7212 extends the precompile set of the active fork.
+	if rules.IsRIP7212 {
+		return rip7212Precompiles[forkPrecompiledContracts(rules)].addresses
+	}
 	switch {
 	case rules.IsPrague:
 		return PrecompiledAddressesPrague
@@ -1251,3 +1317,33 @@ func kZGToVersionedHash(kzg kzg4844.Commitment) common.Hash {
 
 	return h
 }
+
+// p256Verify implements the RIP-7212 secp256r1 signature verification precompile.
+type p256Verify struct{}
+
+// p256VerifyInputLength is the exact input length of the p256Verify precompile:
+// hash, r, s, x and y, 32 bytes each.
+const p256VerifyInputLength = 160
+
+// RequiredGas returns the gas required to execute the precompiled contract.
+func (c *p256Verify) RequiredGas(input []byte) uint64 {
+	return params.P256VerifyGas
+}
+
+// Run executes the precompiled contract with the given 160 bytes of input,
+// returning 32 bytes holding 1 if the signature is valid, or no data otherwise.
+func (c *p256Verify) Run(input []byte) ([]byte, error) {
+	if len(input) != p256VerifyInputLength {
+		return nil, nil
+	}
+	// Extract the hash, r, s, x and y from the input
+	hash := input[0:32]
+	r, s := new(big.Int).SetBytes(input[32:64]), new(big.Int).SetBytes(input[64:96])
+	x, y := new(big.Int).SetBytes(input[96:128]), new(big.Int).SetBytes(input[128:160])
+
+	// Verify the signature
+	if secp256r1.Verify(hash, r, s, x, y) {
+		return true32Byte, nil
+	}
+	return nil, nil
+}
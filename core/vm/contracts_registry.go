@@ -0,0 +1,211 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// StatefulPrecompiledContract is a precompiled contract with access to the
+// state and call context of the EVM executing it. Its RunStateful method is
+// invoked in place of Run.
+type StatefulPrecompiledContract interface {
+	PrecompiledContract
+
+	// RunStateful runs the contract within the given call environment. State
+	// modifications are reverted if an error is returned, and all remaining gas
+	// is consumed unless the error is ErrExecutionReverted.
+	RunStateful(env *PrecompileEnvironment, input []byte) ([]byte, error)
+}
+
+// PrecompileEnvironment is the call context a stateful precompile runs in.
+type PrecompileEnvironment struct {
+	evm *EVM
+
+	CallType OpCode         // CALL, CALLCODE, DELEGATECALL or STATICCALL
+	Caller   common.Address // Sender of the message, as seen by the precompile
+	Address  common.Address // Account whose storage and balance are in scope
+	Value    *uint256.Int   // Value transferred along with the message
+
+	// ReadOnly is set if the call happens in a static context, in which case
+	// the precompile must not modify the state and should fail with
+	// ErrWriteProtection instead.
+	ReadOnly bool
+}
+
+// StateDB returns the state the precompile operates on. Modifications are
+// journaled and reported to the tracer like those done by EVM code.
+func (env *PrecompileEnvironment) StateDB() StateDB { return env.evm.StateDB }
+
+// BlockContext returns the context of the block being executed.
+func (env *PrecompileEnvironment) BlockContext() BlockContext { return env.evm.Context }
+
+// TxContext returns the context of the transaction being executed.
+func (env *PrecompileEnvironment) TxContext() TxContext { return env.evm.TxContext }
+
+// ChainConfig returns the configuration of the chain being executed.
+func (env *PrecompileEnvironment) ChainConfig() *params.ChainConfig { return env.evm.chainConfig }
+
+// Rules returns the chain rules of the block being executed.
+func (env *PrecompileEnvironment) Rules() params.Rules { return env.evm.chainRules }
+
+// Depth returns the call depth the precompile is executed at.
+func (env *PrecompileEnvironment) Depth() int { return env.evm.depth }
+
+// Tracer returns the hooks of the active tracer, or nil if execution is not
+// traced.
+func (env *PrecompileEnvironment) Tracer() *tracing.Hooks { return env.evm.Config.Tracer }
+
+var (
+	precompilesLock sync.RWMutex
+	precompiles     = make(map[string]PrecompiledContract)
+)
+
+// RegisterPrecompile makes a custom precompiled contract available under the
+// given name. The contract is activated through the Precompiles section of the
+// chain config, which assigns it an address and an activation block. Contracts
+// implementing StatefulPrecompiledContract are given access to the state and
+// call context. It panics if a contract is already registered under the name.
+func RegisterPrecompile(name string, contract PrecompiledContract) {
+	precompilesLock.Lock()
+	defer precompilesLock.Unlock()
+
+	if name == "" || contract == nil {
+		panic("vm: invalid precompile registration")
+	}
+	if _, ok := precompiles[name]; ok {
+		panic(fmt.Sprintf("vm: precompile %q already registered", name))
+	}
+	precompiles[name] = contract
+}
+
+// RegisteredPrecompiles returns the names of all registered custom precompiles.
+func RegisteredPrecompiles() []string {
+	precompilesLock.RLock()
+	defer precompilesLock.RUnlock()
+
+	names := make([]string, 0, len(precompiles))
+	for name := range precompiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// registeredPrecompile returns the custom precompile registered under name.
+func registeredPrecompile(name string) (PrecompiledContract, bool) {
+	precompilesLock.RLock()
+	defer precompilesLock.RUnlock()
+
+	p, ok := precompiles[name]
+	return p, ok
+}
+
+// CheckPrecompiles verifies that the custom precompiles of the chain config
+// are registered and don't shadow any of the built-in precompiles.
+func CheckPrecompiles(config *params.ChainConfig) error {
+	for addr, p := range config.Precompiles {
+		if p == nil || p.Block == nil {
+			return fmt.Errorf("precompile at %v: missing activation block", addr)
+		}
+		if _, ok := registeredPrecompile(p.Name); !ok {
+			return fmt.Errorf("precompile at %v: %q is not registered", addr, p.Name)
+		}
+		if _, ok := PrecompiledContractsPrague[addr]; ok {
+			return fmt.Errorf("precompile at %v: address of a built-in precompile", addr)
+		}
+		if _, ok := PrecompiledContractsRIP7212[addr]; ok {
+			return fmt.Errorf("precompile at %v: address of a built-in precompile", addr)
+		}
+	}
+	return nil
+}
+
+// withCustomPrecompiles returns a copy of the given precompile set extended with
+// the active custom precompiles.
+func withCustomPrecompiles(contracts PrecompiledContracts, active map[common.Address]string) PrecompiledContracts {
+	contracts = maps.Clone(contracts)
+	for addr, name := range active {
+		p, ok := registeredPrecompile(name)
+		if !ok {
+			// The chain config is checked when the node starts up, so this
+			// can only happen if the EVM is used directly.
+			panic(fmt.Sprintf("vm: precompile %q at %v is not registered", name, addr))
+		}
+		contracts[addr] = p
+	}
+	return contracts
+}
+
+// runPrecompile runs the precompile p located at addr, providing stateful
+// precompiles with the context of the call and accounting its cost if
+// profiling.
+func (evm *EVM) runPrecompile(p PrecompiledContract, typ OpCode, caller ContractRef, addr common.Address, input []byte, gas uint64, value *uint256.Int) (ret []byte, remainingGas uint64, err error) {
+	if profiler := evm.Config.Profiler; profiler != nil {
+		start := time.Now()
+		defer func() { profiler.precompile(addr, gas-remainingGas, time.Since(start)) }()
+	}
+	sp, ok := p.(StatefulPrecompiledContract)
+	if !ok {
+		return RunPrecompiledContract(p, input, gas, evm.Config.Tracer)
+	}
+	env := &PrecompileEnvironment{
+		evm:      evm,
+		CallType: typ,
+		Caller:   caller.Address(),
+		Address:  addr,
+		Value:    value,
+		ReadOnly: typ == STATICCALL || evm.interpreter.readOnly,
+	}
+	switch typ {
+	case CALLCODE:
+		env.Address = caller.Address()
+	case DELEGATECALL:
+		env.Address = caller.Address()
+		if parent, ok := caller.(*Contract); ok {
+			env.Caller, env.Value = parent.CallerAddress, parent.value
+		}
+	}
+	if env.Value == nil {
+		env.Value = new(uint256.Int)
+	}
+	return runStatefulPrecompiledContract(sp, env, input, gas)
+}
+
+// runStatefulPrecompiledContract runs and evaluates the output of a stateful
+// precompiled contract, mirroring RunPrecompiledContract.
+func runStatefulPrecompiledContract(p StatefulPrecompiledContract, env *PrecompileEnvironment, input []byte, suppliedGas uint64) ([]byte, uint64, error) {
+	gasCost := p.RequiredGas(input)
+	if suppliedGas < gasCost {
+		return nil, 0, ErrOutOfGas
+	}
+	if logger := env.Tracer(); logger != nil && logger.OnGasChange != nil {
+		logger.OnGasChange(suppliedGas, suppliedGas-gasCost, tracing.GasChangeCallPrecompiledContract)
+	}
+	suppliedGas -= gasCost
+	output, err := p.RunStateful(env, input)
+	return output, suppliedGas, err
+}
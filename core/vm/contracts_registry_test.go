@@ -0,0 +1,140 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"errors"
+	"math/big"
+	"slices"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// counterPrecompile counts its invocations in the storage of the account it
+// runs in the context of, returning the sender of the message.
+type counterPrecompile struct{}
+
+func (c *counterPrecompile) RequiredGas(input []byte) uint64 { return 100 }
+
+func (c *counterPrecompile) Run(input []byte) ([]byte, error) {
+	panic("stateful precompile run without context")
+}
+
+func (c *counterPrecompile) RunStateful(env *PrecompileEnvironment, input []byte) ([]byte, error) {
+	if env.ReadOnly {
+		return nil, ErrWriteProtection
+	}
+	db := env.StateDB()
+	count := db.GetState(env.Address, common.Hash{}).Big()
+	db.SetState(env.Address, common.Hash{}, common.BigToHash(count.Add(count, common.Big1)))
+	return common.LeftPadBytes(env.Caller.Bytes(), 32), nil
+}
+
+func init() {
+	RegisterPrecompile("counter", &counterPrecompile{})
+}
+
+func TestStatefulPrecompile(t *testing.T) {
+	var (
+		counter = common.BytesToAddress([]byte{0x10, 0x00})
+		sender  = common.Address{0x01}
+		proxy   = common.Address{0x02}
+		config  = *params.MergedTestChainConfig
+	)
+	config.Precompiles = map[common.Address]*params.PrecompileConfig{
+		counter: {Name: "counter", Block: big.NewInt(1)},
+	}
+	if err := CheckPrecompiles(&config); err != nil {
+		t.Fatalf("failed to check precompiles: %v", err)
+	}
+	if rules := config.Rules(big.NewInt(0), true, 0); slices.Contains(ActivePrecompiles(rules), counter) {
+		t.Fatal("precompile active before its activation block")
+	}
+	if rules := config.Rules(big.NewInt(1), true, 0); !slices.Contains(ActivePrecompiles(rules), counter) {
+		t.Fatal("precompile inactive after its activation block")
+	}
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	vmctx := BlockContext{
+		CanTransfer: func(StateDB, common.Address, *uint256.Int) bool { return true },
+		Transfer:    func(StateDB, common.Address, common.Address, *uint256.Int) {},
+		BlockNumber: big.NewInt(1),
+		Random:      &common.Hash{},
+	}
+	evm := NewEVM(vmctx, TxContext{}, statedb, &config, Config{})
+
+	// Calls run in the context of the precompile itself.
+	ret, gas, err := evm.Call(AccountRef(sender), counter, nil, 1000, new(uint256.Int))
+	if err != nil {
+		t.Fatalf("failed to call precompile: %v", err)
+	}
+	if gas != 900 {
+		t.Errorf("remaining gas mismatch: have %d, want 900", gas)
+	}
+	if have := common.BytesToAddress(ret); have != sender {
+		t.Errorf("caller mismatch: have %v, want %v", have, sender)
+	}
+	if have := statedb.GetState(counter, common.Hash{}); have != common.BigToHash(common.Big1) {
+		t.Errorf("counter mismatch: have %v, want 1", have)
+	}
+	// Delegate calls run in the context of the calling contract.
+	statedb.SetCode(proxy, []byte{
+		byte(PUSH1), 0x20, byte(PUSH0), byte(PUSH0), byte(PUSH0), byte(PUSH2), 0x10, 0x00, byte(GAS), byte(DELEGATECALL),
+		byte(PUSH1), 0x20, byte(PUSH0), byte(RETURN),
+	})
+	ret, _, err = evm.Call(AccountRef(sender), proxy, nil, 100000, new(uint256.Int))
+	if err != nil {
+		t.Fatalf("failed to call proxy: %v", err)
+	}
+	if have := common.BytesToAddress(ret); have != sender {
+		t.Errorf("delegated caller mismatch: have %v, want %v", have, sender)
+	}
+	if have := statedb.GetState(proxy, common.Hash{}); have != common.BigToHash(common.Big1) {
+		t.Errorf("delegated counter mismatch: have %v, want 1", have)
+	}
+	// Static calls may not modify the state.
+	_, gas, err = evm.StaticCall(AccountRef(sender), counter, nil, 1000)
+	if !errors.Is(err, ErrWriteProtection) {
+		t.Errorf("static call error mismatch: have %v, want %v", err, ErrWriteProtection)
+	}
+	if gas != 0 {
+		t.Errorf("static call left %d gas", gas)
+	}
+}
+
+func TestCheckPrecompiles(t *testing.T) {
+	for i, test := range []struct {
+		addr common.Address
+		cfg  *params.PrecompileConfig
+		ok   bool
+	}{
+		{addr: common.Address{0x10, 0x00}, cfg: &params.PrecompileConfig{Name: "counter", Block: common.Big0}, ok: true},
+		{addr: common.Address{0x10, 0x00}, cfg: &params.PrecompileConfig{Name: "unknown", Block: common.Big0}},
+		{addr: common.Address{0x10, 0x00}, cfg: &params.PrecompileConfig{Name: "counter"}},
+		{addr: common.BytesToAddress([]byte{0x01}), cfg: &params.PrecompileConfig{Name: "counter", Block: common.Big0}},
+		{addr: common.BytesToAddress([]byte{0x01, 0x00}), cfg: &params.PrecompileConfig{Name: "counter", Block: common.Big0}},
+	} {
+		config := &params.ChainConfig{Precompiles: map[common.Address]*params.PrecompileConfig{test.addr: test.cfg}}
+		if err := CheckPrecompiles(config); (err == nil) != test.ok {
+			t.Errorf("test %d: error mismatch: have %v, want ok %t", i, err, test.ok)
+		}
+	}
+}
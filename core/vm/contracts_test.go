@@ -20,11 +20,14 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"os"
+	"slices"
 	"testing"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
 )
 
 // precompiledTest defines the input/output pairs for precompiled contract tests.
@@ -67,6 +70,8 @@ var allPrecompiles = map[common.Address]PrecompiledContract{
 	common.BytesToAddress([]byte{0x0f, 0x10}): &bls12381Pairing{},
 	common.BytesToAddress([]byte{0x0f, 0x11}): &bls12381MapG1{},
 	common.BytesToAddress([]byte{0x0f, 0x12}): &bls12381MapG2{},
+
+	common.BytesToAddress([]byte{0x01, 0x00}): &p256Verify{},
 }
 
 // EIP-152 test vectors
@@ -318,6 +323,10 @@ func TestPrecompiledPointEvaluation(t *testing.T) { testJson("pointEvaluation",
 
 func BenchmarkPrecompiledPointEvaluation(b *testing.B) { benchJson("pointEvaluation", "0a", b) }
 
+func TestPrecompiledP256Verify(t *testing.T) { testJson("p256Verify", "100", t) }
+
+func BenchmarkPrecompiledP256Verify(b *testing.B) { benchJson("p256Verify", "100", b) }
+
 func BenchmarkPrecompiledBLS12381G1Add(b *testing.B)      { benchJson("blsG1Add", "f0a", b) }
 func BenchmarkPrecompiledBLS12381G1Mul(b *testing.B)      { benchJson("blsG1Mul", "f0b", b) }
 func BenchmarkPrecompiledBLS12381G1MultiExp(b *testing.B) { benchJson("blsG1MultiExp", "f0c", b) }
@@ -397,3 +406,31 @@ func BenchmarkPrecompiledBLS12381G2MultiExpWorstCase(b *testing.B) {
 	}
 	benchmarkPrecompiled("f0f", testcase, b)
 }
+
+// Tests that the RIP-7212 precompile is only active once its fork is reached,
+// extending the precompile set of the active Ethereum fork.
+func TestRIP7212Activation(t *testing.T) {
+	var (
+		addr   = common.BytesToAddress([]byte{0x01, 0x00})
+		config = *params.MergedTestChainConfig
+	)
+	config.RIP7212Block = big.NewInt(10)
+
+	before := config.Rules(big.NewInt(9), true, 0)
+	if _, ok := activePrecompiledContracts(before)[addr]; ok {
+		t.Fatal("p256Verify active before its fork")
+	}
+	if slices.Contains(ActivePrecompiles(before), addr) {
+		t.Fatal("p256Verify address active before its fork")
+	}
+	after := config.Rules(big.NewInt(10), true, 0)
+	if _, ok := activePrecompiledContracts(after)[addr]; !ok {
+		t.Fatal("p256Verify inactive after its fork")
+	}
+	if have, want := len(ActivePrecompiles(after)), len(PrecompiledAddressesCancun)+1; have != want {
+		t.Fatalf("wrong number of precompiles: have %d, want %d", have, want)
+	}
+	if _, ok := PrecompiledContractsCancun[addr]; ok {
+		t.Fatal("fork precompile set modified")
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"math"
 	"sort"
+	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/tracing"
@@ -40,6 +41,7 @@ var activators = map[int]func(*JumpTable){
 	1344: enable1344,
 	1153: enable1153,
 	4762: enable4762,
+	7702: enable7702,
 }
 
 // EnableEIP enables the given EIP on the config.
@@ -533,3 +535,212 @@ func enable4762(jt *JumpTable) {
 		}
 	}
 }
+
+// enable7702 the EIP-7702 changes to support delegation designators.
+func enable7702(jt *JumpTable) {
+	jt[CALL].dynamicGas = gasCallEIP7702
+	jt[CALLCODE].dynamicGas = gasCallCodeEIP7702
+	jt[STATICCALL].dynamicGas = gasStaticCallEIP7702
+	jt[DELEGATECALL].dynamicGas = gasDelegateCallEIP7702
+}
+
+// eip7702JumpTables caches the EIP-7702 enabled variants of the instruction
+// sets, keyed by the instruction set they are derived from.
+var eip7702JumpTables sync.Map
+
+// CHANGE(taiko): eip7702JumpTable returns the given instruction set extended
+// with EIP-7702, which can be activated independently of any hard fork.
+func eip7702JumpTable(table *JumpTable) *JumpTable {
+	if cached, ok := eip7702JumpTables.Load(table); ok {
+		return cached.(*JumpTable)
+	}
+	extended := copyJumpTable(table)
+	enable7702(extended)
+	cached, _ := eip7702JumpTables.LoadOrStore(table, extended)
+	return cached.(*JumpTable)
+}
+
+// enableEOFLegacy changes the legacy instruction set to hide the contents of
+// EOF contracts from legacy code introspection.
+func enableEOFLegacy(jt *JumpTable) {
+	jt[EXTCODESIZE].execute = opExtCodeSizeEOF
+	jt[EXTCODECOPY].execute = opExtCodeCopyEOF
+	jt[EXTCODEHASH].execute = opExtCodeHashEOF
+}
+
+// enableEOF turns a legacy instruction set into the EOF v1 instruction set,
+// removing the instructions deprecated by EIP-3540 and adding the new ones.
+func enableEOF(jt *JumpTable) {
+	for _, op := range []OpCode{
+		CALLCODE, SELFDESTRUCT, JUMP, JUMPI, PC, CREATE, CREATE2, CODESIZE, CODECOPY,
+		EXTCODESIZE, EXTCODECOPY, EXTCODEHASH, GAS, CALL, DELEGATECALL, STATICCALL,
+	} {
+		jt[op] = &operation{execute: opUndefined, maxStack: maxStack(0, 0), undefined: true}
+	}
+	// INVALID is a designated terminating instruction in EOF code.
+	jt[INVALID] = &operation{
+		execute:  opUndefined,
+		minStack: minStack(0, 0),
+		maxStack: maxStack(0, 0),
+	}
+	jt[RJUMP] = &operation{
+		execute:     opRjump,
+		constantGas: GasQuickStep,
+		minStack:    minStack(0, 0),
+		maxStack:    maxStack(0, 0),
+	}
+	jt[RJUMPI] = &operation{
+		execute:     opRjumpi,
+		constantGas: GasFastishStep,
+		minStack:    minStack(1, 0),
+		maxStack:    maxStack(1, 0),
+	}
+	jt[RJUMPV] = &operation{
+		execute:     opRjumpv,
+		constantGas: GasFastishStep,
+		minStack:    minStack(1, 0),
+		maxStack:    maxStack(1, 0),
+	}
+	jt[CALLF] = &operation{
+		execute:     opCallf,
+		constantGas: GasFastStep,
+		minStack:    minStack(0, 0),
+		maxStack:    maxStack(0, 0),
+	}
+	jt[RETF] = &operation{
+		execute:     opRetf,
+		constantGas: GasFastestStep,
+		minStack:    minStack(0, 0),
+		maxStack:    maxStack(0, 0),
+	}
+	jt[JUMPF] = &operation{
+		execute:     opJumpf,
+		constantGas: GasFastStep,
+		minStack:    minStack(0, 0),
+		maxStack:    maxStack(0, 0),
+	}
+	jt[DUPN] = &operation{
+		execute:     opDupN,
+		constantGas: GasFastestStep,
+		minStack:    minStack(0, 1),
+		maxStack:    maxStack(0, 1),
+	}
+	jt[SWAPN] = &operation{
+		execute:     opSwapN,
+		constantGas: GasFastestStep,
+		minStack:    minStack(0, 0),
+		maxStack:    maxStack(0, 0),
+	}
+	jt[EXCHANGE] = &operation{
+		execute:     opExchange,
+		constantGas: GasFastestStep,
+		minStack:    minStack(0, 0),
+		maxStack:    maxStack(0, 0),
+	}
+	jt[DATALOAD] = &operation{
+		execute:     opDataLoad,
+		constantGas: GasFastishStep,
+		minStack:    minStack(1, 1),
+		maxStack:    maxStack(1, 1),
+	}
+	jt[DATALOADN] = &operation{
+		execute:     opDataLoadN,
+		constantGas: GasFastestStep,
+		minStack:    minStack(0, 1),
+		maxStack:    maxStack(0, 1),
+	}
+	jt[DATASIZE] = &operation{
+		execute:     opDataSize,
+		constantGas: GasQuickStep,
+		minStack:    minStack(0, 1),
+		maxStack:    maxStack(0, 1),
+	}
+	jt[DATACOPY] = &operation{
+		execute:     opDataCopy,
+		constantGas: GasFastestStep,
+		dynamicGas:  gasDataCopy,
+		minStack:    minStack(3, 0),
+		maxStack:    maxStack(3, 0),
+		memorySize:  memoryDataCopy,
+	}
+	jt[RETURNDATALOAD] = &operation{
+		execute:     opReturnDataLoad,
+		constantGas: GasFastestStep,
+		minStack:    minStack(1, 1),
+		maxStack:    maxStack(1, 1),
+	}
+	jt[EOFCREATE] = &operation{
+		execute:     opEOFCreate,
+		constantGas: params.Create2Gas,
+		dynamicGas:  gasEOFCreate,
+		minStack:    minStack(4, 1),
+		maxStack:    maxStack(4, 1),
+		memorySize:  memoryEOFCreate,
+	}
+	jt[RETURNCODE] = &operation{
+		execute:    opReturnCode,
+		dynamicGas: gasReturnCode,
+		minStack:   minStack(2, 0),
+		maxStack:   maxStack(2, 0),
+		memorySize: memoryReturnCode,
+	}
+	jt[EXTCALL] = &operation{
+		execute:     opExtCall,
+		constantGas: params.WarmStorageReadCostEIP2929,
+		dynamicGas:  gasExtCall,
+		minStack:    minStack(4, 1),
+		maxStack:    maxStack(4, 1),
+		memorySize:  memoryExtCall,
+	}
+	jt[EXTDELEGATECALL] = &operation{
+		execute:     opExtDelegateCall,
+		constantGas: params.WarmStorageReadCostEIP2929,
+		dynamicGas:  gasExtDelegateCall,
+		minStack:    minStack(3, 1),
+		maxStack:    maxStack(3, 1),
+		memorySize:  memoryExtCall,
+	}
+	jt[EXTSTATICCALL] = &operation{
+		execute:     opExtStaticCall,
+		constantGas: params.WarmStorageReadCostEIP2929,
+		dynamicGas:  gasExtStaticCall,
+		minStack:    minStack(3, 1),
+		maxStack:    maxStack(3, 1),
+		memorySize:  memoryExtCall,
+	}
+}
+
+// eofJumpTables caches the legacy and EOF instruction sets used once EOF is
+// active, keyed by the instruction set they are derived from.
+var eofJumpTables sync.Map
+
+type eofJumpTablePair struct {
+	legacy, eof *JumpTable
+}
+
+// CHANGE(taiko): eofJumpTable returns the legacy and EOF instruction sets
+// derived from the given instruction set, as EOF is activated independently
+// of any hard fork.
+func eofJumpTable(table *JumpTable) (*JumpTable, *JumpTable) {
+	if cached, ok := eofJumpTables.Load(table); ok {
+		pair := cached.(*eofJumpTablePair)
+		return pair.legacy, pair.eof
+	}
+	legacy := copyJumpTable(table)
+	enableEOFLegacy(legacy)
+
+	eof := copyJumpTable(table)
+	enableEOF(eof)
+	validate(*eof)
+
+	cached, _ := eofJumpTables.LoadOrStore(table, &eofJumpTablePair{legacy: legacy, eof: eof})
+	pair := cached.(*eofJumpTablePair)
+	return pair.legacy, pair.eof
+}
+
+// NewEOFInstructionSetForTesting returns the instruction set used to validate
+// and execute EOF containers on top of Cancun.
+func NewEOFInstructionSetForTesting() JumpTable {
+	_, eof := eofJumpTable(&cancunInstructionSet)
+	return *eof
+}
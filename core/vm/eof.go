@@ -0,0 +1,387 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/params"
+)
+
+const (
+	offsetVersion   = 2
+	offsetTypesKind = 3
+	offsetCodeKind  = 6
+
+	kindTypes     = 1
+	kindCode      = 2
+	kindContainer = 3
+	kindData      = 0xff
+
+	eof1Version = 1
+
+	maxInputItems        = 127
+	maxOutputItems       = 127
+	maxStackHeight       = 1023
+	maxContainerSections = 256
+	maxCodeSections      = 1024
+
+	// nonReturningFunction is the output count marking a code section that
+	// never returns to its caller.
+	nonReturningFunction = 0x80
+
+	// returnStackLimit is the maximum number of nested CALLF frames.
+	returnStackLimit = 1024
+)
+
+var (
+	eofMagic = []byte{0xef, 0x00}
+
+	errInvalidMagic                  = errors.New("invalid magic")
+	errInvalidVersion                = errors.New("invalid version")
+	errMissingTypeHeader             = errors.New("missing type header")
+	errInvalidTypeSize               = errors.New("invalid type section size")
+	errMissingCodeHeader             = errors.New("missing code header")
+	errInvalidCodeSize               = errors.New("invalid code size")
+	errInvalidContainerSectionSize   = errors.New("invalid container section size")
+	errMissingDataHeader             = errors.New("missing data header")
+	errMissingTerminator             = errors.New("missing header terminator")
+	errTooManyInputs                 = errors.New("invalid type content, too many inputs")
+	errTooManyOutputs                = errors.New("invalid type content, too many outputs")
+	errInvalidSection0Type           = errors.New("invalid section 0 type, input should be zero and output non-returning (0x80)")
+	errTooLargeMaxStackHeight        = errors.New("invalid type content, max stack height exceeds limit")
+	errInvalidContainerSize          = errors.New("invalid container size")
+	errTruncatedTopLevelContainer    = errors.New("truncated top level container")
+	errEOFCreateWithTruncatedSection = errors.New("eofcreate with truncated section")
+)
+
+// functionMetadata is the type section entry of a single code section.
+type functionMetadata struct {
+	inputs           uint8
+	outputs          uint8
+	maxStackIncrease uint16
+}
+
+// Container is an EOF container object, as defined by EIP-3540.
+type Container struct {
+	types             []*functionMetadata
+	codeSections      [][]byte
+	subContainers     []*Container
+	subContainerCodes [][]byte
+	data              []byte
+	dataSize          int // might be more than len(data) in subcontainers
+	dataSizeOffset    int // offset of the data size field in the header
+}
+
+// hasEOFMagic returns true if code starts with the EOF magic prefix.
+func hasEOFMagic(code []byte) bool {
+	return len(eofMagic) <= len(code) && bytes.Equal(eofMagic, code[0:len(eofMagic)])
+}
+
+// isEOFVersion1 returns true if the code's version byte equals eof1Version.
+// It does not verify the EOF magic.
+func isEOFVersion1(code []byte) bool {
+	return offsetVersion < len(code) && code[offsetVersion] == byte(eof1Version)
+}
+
+// MarshalBinary encodes an EOF container into binary format.
+func (c *Container) MarshalBinary() []byte {
+	b := make([]byte, 0, 64)
+	b = append(b, eofMagic...)
+	b = append(b, eof1Version)
+
+	// Write section headers.
+	b = append(b, kindTypes)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(c.types)*4))
+	b = append(b, kindCode)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(c.codeSections)))
+	for _, code := range c.codeSections {
+		b = binary.BigEndian.AppendUint16(b, uint16(len(code)))
+	}
+	encoded := make([][]byte, len(c.subContainers))
+	if len(c.subContainers) != 0 {
+		b = append(b, kindContainer)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(c.subContainers)))
+		for i, sub := range c.subContainers {
+			encoded[i] = sub.MarshalBinary()
+			b = binary.BigEndian.AppendUint32(b, uint32(len(encoded[i])))
+		}
+	}
+	b = append(b, kindData)
+	b = binary.BigEndian.AppendUint16(b, uint16(c.dataSize))
+	b = append(b, 0) // terminator
+
+	// Write section contents.
+	for _, ty := range c.types {
+		b = append(b, ty.inputs, ty.outputs)
+		b = binary.BigEndian.AppendUint16(b, ty.maxStackIncrease)
+	}
+	for _, code := range c.codeSections {
+		b = append(b, code...)
+	}
+	for _, sub := range encoded {
+		b = append(b, sub...)
+	}
+	return append(b, c.data...)
+}
+
+// UnmarshalBinary decodes an EOF container. The data section must be complete
+// and no trailing bytes are allowed.
+func (c *Container) UnmarshalBinary(b []byte) error {
+	size, err := c.unmarshal(b, true)
+	if err != nil {
+		return err
+	}
+	if size != len(b) {
+		return fmt.Errorf("%w: have %d, want %d", errInvalidContainerSize, len(b), size)
+	}
+	return nil
+}
+
+// parseInitcontainer decodes the EOF initcontainer at the start of a creation
+// transaction's data, returning it together with its encoding and the
+// trailing calldata.
+func parseInitcontainer(b []byte) (*Container, []byte, []byte, error) {
+	c := new(Container)
+	size, err := c.unmarshal(b, true)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return c, b[:size], b[size:], nil
+}
+
+// unmarshal decodes the container at the start of b and returns the number of
+// bytes it occupies. Only subcontainers, which may be deployed by RETURNCODE
+// with additional aux data, are allowed to have a truncated data section.
+func (c *Container) unmarshal(b []byte, topLevel bool) (int, error) {
+	if !hasEOFMagic(b) {
+		return 0, fmt.Errorf("%w: want %x", errInvalidMagic, eofMagic)
+	}
+	if len(b) < 14 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if len(b) > params.MaxInitCodeSize {
+		return 0, ErrMaxInitCodeSizeExceeded
+	}
+	if !isEOFVersion1(b) {
+		return 0, fmt.Errorf("%w: have %d, want %d", errInvalidVersion, b[offsetVersion], eof1Version)
+	}
+	// Parse the type section header.
+	kind, typesSize, err := parseSection(b, offsetTypesKind)
+	if err != nil {
+		return 0, err
+	}
+	if kind != kindTypes {
+		return 0, fmt.Errorf("%w: found section kind %x instead", errMissingTypeHeader, kind)
+	}
+	if typesSize < 4 || typesSize%4 != 0 {
+		return 0, fmt.Errorf("%w: type section size must be divisible by 4, have %d", errInvalidTypeSize, typesSize)
+	}
+	if typesSize/4 > maxCodeSections {
+		return 0, fmt.Errorf("%w: type section must not exceed %d code sections, have %d", errInvalidTypeSize, maxCodeSections, typesSize/4)
+	}
+	// Parse the code section header.
+	kind, codeSizes, err := parseSectionList(b, offsetCodeKind, 2)
+	if err != nil {
+		return 0, err
+	}
+	if kind != kindCode {
+		return 0, fmt.Errorf("%w: found section kind %x instead", errMissingCodeHeader, kind)
+	}
+	if len(codeSizes) != typesSize/4 {
+		return 0, fmt.Errorf("%w: mismatch of code sections and type signatures, types %d, code %d", errInvalidCodeSize, typesSize/4, len(codeSizes))
+	}
+	// Parse the optional container section header.
+	var (
+		offset         = offsetCodeKind + 3 + 2*len(codeSizes)
+		containerSizes []int
+	)
+	if offset < len(b) && b[offset] == kindContainer {
+		_, containerSizes, err = parseSectionList(b, offset, 4)
+		if err != nil {
+			return 0, err
+		}
+		if len(containerSizes) == 0 {
+			return 0, fmt.Errorf("%w: total container count must not be zero", errInvalidContainerSectionSize)
+		}
+		if len(containerSizes) > maxContainerSections {
+			return 0, fmt.Errorf("%w: total container count exceeds limit, have %d", errInvalidContainerSectionSize, len(containerSizes))
+		}
+		offset += 3 + 4*len(containerSizes)
+	}
+	// Parse the data section header.
+	kind, dataSize, err := parseSection(b, offset)
+	if err != nil {
+		return 0, err
+	}
+	if kind != kindData {
+		return 0, fmt.Errorf("%w: found section %x instead", errMissingDataHeader, kind)
+	}
+	c.dataSize = dataSize
+	c.dataSizeOffset = offset + 1
+
+	// Check for the header terminator.
+	offset += 3
+	if len(b) <= offset {
+		return 0, fmt.Errorf("%w: missing terminator", io.ErrUnexpectedEOF)
+	}
+	if b[offset] != 0 {
+		return 0, fmt.Errorf("%w: have %x", errMissingTerminator, b[offset])
+	}
+	offset++
+
+	// Verify the container holds all sections, except maybe the data.
+	bodySize := typesSize + sum(codeSizes) + sum(containerSizes)
+	if len(b) < offset+bodySize {
+		return 0, fmt.Errorf("%w: have %d, want %d", errInvalidContainerSize, len(b), offset+bodySize+dataSize)
+	}
+	// Parse the types section.
+	c.types = make([]*functionMetadata, 0, typesSize/4)
+	for i := 0; i < typesSize/4; i++ {
+		sig := &functionMetadata{
+			inputs:           b[offset+i*4],
+			outputs:          b[offset+i*4+1],
+			maxStackIncrease: binary.BigEndian.Uint16(b[offset+i*4+2:]),
+		}
+		if sig.inputs > maxInputItems {
+			return 0, fmt.Errorf("%w for section %d: have %d", errTooManyInputs, i, sig.inputs)
+		}
+		if sig.outputs > maxOutputItems && sig.outputs != nonReturningFunction {
+			return 0, fmt.Errorf("%w for section %d: have %d", errTooManyOutputs, i, sig.outputs)
+		}
+		if int(sig.inputs)+int(sig.maxStackIncrease) > maxStackHeight {
+			return 0, fmt.Errorf("%w for section %d: have %d", errTooLargeMaxStackHeight, i, int(sig.inputs)+int(sig.maxStackIncrease))
+		}
+		c.types = append(c.types, sig)
+	}
+	if c.types[0].inputs != 0 || c.types[0].outputs != nonReturningFunction {
+		return 0, fmt.Errorf("%w: have %d, %d", errInvalidSection0Type, c.types[0].inputs, c.types[0].outputs)
+	}
+	offset += typesSize
+
+	// Parse the code sections.
+	c.codeSections = make([][]byte, len(codeSizes))
+	for i, size := range codeSizes {
+		if size == 0 {
+			return 0, fmt.Errorf("%w for section %d: size must not be 0", errInvalidCodeSize, i)
+		}
+		c.codeSections[i] = b[offset : offset+size]
+		offset += size
+	}
+	// Parse the subcontainers.
+	for i, size := range containerSizes {
+		if size == 0 {
+			return 0, fmt.Errorf("%w for subcontainer %d: size must not be 0", errInvalidContainerSectionSize, i)
+		}
+		code := b[offset : offset+size]
+		sub := new(Container)
+		n, err := sub.unmarshal(code, false)
+		if err != nil {
+			return 0, fmt.Errorf("subcontainer %d: %w", i, err)
+		}
+		if n != size {
+			return 0, fmt.Errorf("%w for subcontainer %d: have %d, want %d", errInvalidContainerSize, i, size, n)
+		}
+		c.subContainers = append(c.subContainers, sub)
+		c.subContainerCodes = append(c.subContainerCodes, code)
+		offset += size
+	}
+	// Parse the data section.
+	end := min(offset+dataSize, len(b))
+	if topLevel && end-offset != dataSize {
+		return 0, fmt.Errorf("%w: have %d, want %d", errTruncatedTopLevelContainer, end-offset, dataSize)
+	}
+	c.data = b[offset:end]
+	return end, nil
+}
+
+// parseSection decodes a (kind, size) pair from an EOF header.
+func parseSection(b []byte, idx int) (kind, size int, err error) {
+	if idx+3 > len(b) {
+		return 0, 0, io.ErrUnexpectedEOF
+	}
+	return int(b[idx]), int(binary.BigEndian.Uint16(b[idx+1:])), nil
+}
+
+// parseSectionList decodes a (kind, count, []size) section list from an EOF
+// header, where each size is sizeBytes wide.
+func parseSectionList(b []byte, idx int, sizeBytes int) (kind int, list []int, err error) {
+	if idx+3 > len(b) {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+	kind = int(b[idx])
+	count := int(binary.BigEndian.Uint16(b[idx+1:]))
+	if count == 0 && kind == kindCode {
+		return 0, nil, fmt.Errorf("%w: total code sections must not be zero", errInvalidCodeSize)
+	}
+	if idx+3+count*sizeBytes > len(b) {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+	list = make([]int, count)
+	for i := range list {
+		pos := idx + 3 + sizeBytes*i
+		if sizeBytes == 2 {
+			list[i] = int(binary.BigEndian.Uint16(b[pos:]))
+		} else {
+			list[i] = int(binary.BigEndian.Uint32(b[pos:]))
+		}
+	}
+	return kind, list, nil
+}
+
+// sum computes the sum of a slice.
+func sum(list []int) (s int) {
+	for _, n := range list {
+		s += n
+	}
+	return s
+}
+
+func (c *Container) String() string {
+	var output = []string{
+		"Header",
+		fmt.Sprintf("  - EOFMagic: %02x", eofMagic),
+		fmt.Sprintf("  - EOFVersion: %02x", eof1Version),
+		fmt.Sprintf("  - TypesSize: %04x", len(c.types)*4),
+		fmt.Sprintf("  - Number of code sections: %d", len(c.codeSections)),
+	}
+	for i, code := range c.codeSections {
+		output = append(output, fmt.Sprintf("    - Code section %d length: %04x", i, len(code)))
+	}
+	output = append(output, fmt.Sprintf("  - Number of subcontainers: %d", len(c.subContainers)))
+	for i, code := range c.subContainerCodes {
+		output = append(output, fmt.Sprintf("    - Subcontainer %d length: %04x", i, len(code)))
+	}
+	output = append(output, fmt.Sprintf("  - DataSize: %04x", c.dataSize))
+	output = append(output, "Body")
+	for i, typ := range c.types {
+		output = append(output, fmt.Sprintf("  - Type %d: inputs %d, outputs %#x, max stack increase %d", i, typ.inputs, typ.outputs, typ.maxStackIncrease))
+	}
+	for i, code := range c.codeSections {
+		output = append(output, fmt.Sprintf("  - Code section %d: %#x", i, code))
+	}
+	for i, code := range c.subContainerCodes {
+		output = append(output, fmt.Sprintf("  - Subcontainer %d: %#x", i, code))
+	}
+	output = append(output, fmt.Sprintf("  - Data: %#x", c.data))
+	return strings.Join(output, "\n")
+}
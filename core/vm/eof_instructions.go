@@ -0,0 +1,414 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// eofMagicHash is the code hash legacy code observes for EOF contracts.
+var eofMagicHash = crypto.Keccak256Hash(eofMagic)
+
+// parseInt16 decodes the big endian signed 16 bit immediate at the start of b.
+func parseInt16(b []byte) int64 {
+	return int64(int16(binary.BigEndian.Uint16(b)))
+}
+
+// opRjump implements the RJUMP opcode.
+func opRjump(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	offset := parseInt16(scope.Contract.Code[*pc+1:])
+	// Move past the opcode and immediate, then apply the relative offset. The
+	// interpreter loop increments pc afterwards, hence the -1.
+	*pc = uint64(int64(*pc+3) + offset - 1)
+	return nil, nil
+}
+
+// opRjumpi implements the RJUMPI opcode.
+func opRjumpi(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	condition := scope.Stack.pop()
+	if condition.IsZero() {
+		*pc += 2
+		return nil, nil
+	}
+	return opRjump(pc, interpreter, scope)
+}
+
+// opRjumpv implements the RJUMPV opcode.
+func opRjumpv(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	var (
+		code  = scope.Contract.Code
+		count = uint64(code[*pc+1]) + 1
+		index = scope.Stack.pop()
+	)
+	idx, overflow := index.Uint64WithOverflow()
+	if overflow || idx >= count {
+		// Out of bounds, fall through to the next instruction.
+		*pc += 1 + count*2
+		return nil, nil
+	}
+	offset := parseInt16(code[*pc+2+idx*2:])
+	*pc = uint64(int64(*pc+2+count*2) + offset - 1)
+	return nil, nil
+}
+
+// opCallf implements the CALLF opcode.
+func opCallf(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	var (
+		contract = scope.Contract
+		section  = int(binary.BigEndian.Uint16(contract.Code[*pc+1:]))
+		typ      = contract.container.types[section]
+	)
+	if limit := int(params.StackLimit); scope.Stack.len()+int(typ.maxStackIncrease) > limit {
+		return nil, &ErrStackOverflow{stackLen: scope.Stack.len() + int(typ.maxStackIncrease), limit: limit}
+	}
+	if len(contract.returnStack) >= returnStackLimit {
+		return nil, ErrReturnStackExceeded
+	}
+	contract.returnStack = append(contract.returnStack, returnFrame{
+		section: contract.codeSection,
+		pc:      *pc + 3,
+	})
+	contract.setCodeSection(section)
+	*pc = 0
+	*pc-- // account for the interpreter loop increment
+	return nil, nil
+}
+
+// opRetf implements the RETF opcode.
+func opRetf(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	var (
+		contract = scope.Contract
+		frame    = contract.returnStack[len(contract.returnStack)-1]
+	)
+	contract.returnStack = contract.returnStack[:len(contract.returnStack)-1]
+	contract.setCodeSection(frame.section)
+	*pc = frame.pc - 1
+	return nil, nil
+}
+
+// opJumpf implements the JUMPF opcode.
+func opJumpf(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	var (
+		contract = scope.Contract
+		section  = int(binary.BigEndian.Uint16(contract.Code[*pc+1:]))
+		typ      = contract.container.types[section]
+	)
+	if limit := int(params.StackLimit); scope.Stack.len()+int(typ.maxStackIncrease) > limit {
+		return nil, &ErrStackOverflow{stackLen: scope.Stack.len() + int(typ.maxStackIncrease), limit: limit}
+	}
+	contract.setCodeSection(section)
+	*pc = 0
+	*pc-- // account for the interpreter loop increment
+	return nil, nil
+}
+
+// opDupN implements the DUPN opcode.
+func opDupN(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	n := int(scope.Contract.Code[*pc+1]) + 1
+	scope.Stack.dup(n)
+	*pc += 1
+	return nil, nil
+}
+
+// opSwapN implements the SWAPN opcode.
+func opSwapN(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	n := int(scope.Contract.Code[*pc+1]) + 1
+	scope.Stack.swap(n)
+	*pc += 1
+	return nil, nil
+}
+
+// opExchange implements the EXCHANGE opcode.
+func opExchange(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	var (
+		imm = scope.Contract.Code[*pc+1]
+		n   = int(imm>>4) + 1
+		m   = int(imm&0x0f) + 1
+		a   = scope.Stack.Back(n)
+		b   = scope.Stack.Back(n + m)
+	)
+	*a, *b = *b, *a
+	*pc += 1
+	return nil, nil
+}
+
+// opDataLoad implements the DATALOAD opcode.
+func opDataLoad(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	x := scope.Stack.peek()
+	if offset, overflow := x.Uint64WithOverflow(); !overflow {
+		x.SetBytes(getData(scope.Contract.container.data, offset, 32))
+	} else {
+		x.Clear()
+	}
+	return nil, nil
+}
+
+// opDataLoadN implements the DATALOADN opcode.
+func opDataLoadN(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	offset := uint64(binary.BigEndian.Uint16(scope.Contract.Code[*pc+1:]))
+	val := new(uint256.Int).SetBytes(getData(scope.Contract.container.data, offset, 32))
+	scope.Stack.push(val)
+	*pc += 2
+	return nil, nil
+}
+
+// opDataSize implements the DATASIZE opcode.
+func opDataSize(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	scope.Stack.push(new(uint256.Int).SetUint64(uint64(len(scope.Contract.container.data))))
+	return nil, nil
+}
+
+// opDataCopy implements the DATACOPY opcode.
+func opDataCopy(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	var (
+		memOffset  = scope.Stack.pop()
+		dataOffset = scope.Stack.pop()
+		length     = scope.Stack.pop()
+	)
+	dataOffset64, overflow := dataOffset.Uint64WithOverflow()
+	if overflow {
+		dataOffset64 = math.MaxUint64
+	}
+	// These values are checked for overflow during gas cost calculation
+	memOffset64 := memOffset.Uint64()
+	length64 := length.Uint64()
+	scope.Memory.Set(memOffset64, length64, getData(scope.Contract.container.data, dataOffset64, length64))
+	return nil, nil
+}
+
+// opReturnDataLoad implements the RETURNDATALOAD opcode.
+func opReturnDataLoad(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	x := scope.Stack.peek()
+	if offset, overflow := x.Uint64WithOverflow(); !overflow {
+		x.SetBytes(getData(interpreter.returnData, offset, 32))
+	} else {
+		x.Clear()
+	}
+	return nil, nil
+}
+
+// opEOFCreate implements the EOFCREATE opcode.
+func opEOFCreate(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	if interpreter.readOnly {
+		return nil, ErrWriteProtection
+	}
+	var (
+		idx          = scope.Contract.Code[*pc+1]
+		container    = scope.Contract.container.subContainers[idx]
+		initcode     = scope.Contract.container.subContainerCodes[idx]
+		value        = scope.Stack.pop()
+		salt         = scope.Stack.pop()
+		offset, size = scope.Stack.pop(), scope.Stack.pop()
+		input        = scope.Memory.GetCopy(offset.Uint64(), size.Uint64())
+	)
+	*pc += 1
+
+	// Charge for hashing the initcontainer, which determines the address.
+	hashingCost := params.Keccak256WordGas * toWordSize(uint64(len(initcode)))
+	if !scope.Contract.UseGas(hashingCost, interpreter.evm.Config.Tracer, tracing.GasChangeIgnored) {
+		return nil, ErrOutOfGas
+	}
+	// Apply EIP150
+	gas := scope.Contract.Gas
+	gas -= gas / 64
+	scope.Contract.UseGas(gas, interpreter.evm.Config.Tracer, tracing.GasChangeCallContractCreation2)
+
+	res, addr, returnGas, suberr := interpreter.evm.EOFCreate(scope.Contract, container, initcode, input, gas, &value, &salt)
+	// reuse size int for stackvalue
+	stackvalue := size
+	if suberr != nil {
+		stackvalue.Clear()
+	} else {
+		stackvalue.SetBytes(addr.Bytes())
+	}
+	scope.Stack.push(&stackvalue)
+	scope.Contract.RefundGas(returnGas, interpreter.evm.Config.Tracer, tracing.GasChangeCallLeftOverRefunded)
+
+	if suberr == ErrExecutionReverted {
+		interpreter.returnData = res // set REVERT data to return data buffer
+		return res, nil
+	}
+	interpreter.returnData = nil // clear dirty return data buffer
+	return nil, nil
+}
+
+// opReturnCode implements the RETURNCODE opcode, returning the referenced
+// subcontainer with the aux data appended to its data section as the code
+// to deploy.
+func opReturnCode(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	var (
+		idx          = scope.Contract.Code[*pc+1]
+		container    = scope.Contract.container.subContainers[idx]
+		code         = scope.Contract.container.subContainerCodes[idx]
+		offset, size = scope.Stack.pop(), scope.Stack.pop()
+		aux          = scope.Memory.GetPtr(offset.Uint64(), size.Uint64())
+	)
+	dataSize := len(container.data) + len(aux)
+	if dataSize < container.dataSize || dataSize > math.MaxUint16 {
+		return nil, ErrInvalidAuxDataSize
+	}
+	ret := make([]byte, 0, len(code)+len(aux))
+	ret = append(ret, code...)
+	ret = append(ret, aux...)
+	binary.BigEndian.PutUint16(ret[container.dataSizeOffset:], uint16(dataSize))
+	return ret, errStopToken
+}
+
+// opExtCall implements the EXTCALL opcode.
+func opExtCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	var (
+		stack                         = scope.Stack
+		addr, inOffset, inSize, value = stack.pop(), stack.pop(), stack.pop(), stack.pop()
+	)
+	if interpreter.readOnly && !value.IsZero() {
+		return nil, ErrWriteProtection
+	}
+	args := scope.Memory.GetPtr(inOffset.Uint64(), inSize.Uint64())
+	return extCall(EXTCALL, interpreter, scope, &addr, args, &value)
+}
+
+// opExtDelegateCall implements the EXTDELEGATECALL opcode.
+func opExtDelegateCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	var (
+		stack                  = scope.Stack
+		addr, inOffset, inSize = stack.pop(), stack.pop(), stack.pop()
+	)
+	args := scope.Memory.GetPtr(inOffset.Uint64(), inSize.Uint64())
+	return extCall(EXTDELEGATECALL, interpreter, scope, &addr, args, nil)
+}
+
+// opExtStaticCall implements the EXTSTATICCALL opcode.
+func opExtStaticCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	var (
+		stack                  = scope.Stack
+		addr, inOffset, inSize = stack.pop(), stack.pop(), stack.pop()
+	)
+	args := scope.Memory.GetPtr(inOffset.Uint64(), inSize.Uint64())
+	return extCall(EXTSTATICCALL, interpreter, scope, &addr, args, nil)
+}
+
+// extCall performs an EXT*CALL and pushes its status onto the stack: 0 on
+// success, 1 on revert or if the call could not be made, 2 on failure.
+func extCall(typ OpCode, interpreter *EVMInterpreter, scope *ScopeContext, addr *uint256.Int, args []byte, value *uint256.Int) ([]byte, error) {
+	if addr.BitLen() > 8*common.AddressLength {
+		return nil, ErrInvalidAddress
+	}
+	var (
+		evm       = interpreter.evm
+		toAddr    = common.Address(addr.Bytes20())
+		available = scope.Contract.Gas
+		retained  = max(available/64, params.ExtCallMinRetainedGas)
+		status    = addr // reuse the address slot for the result
+	)
+	interpreter.returnData = nil
+
+	// Light failures leave the caller's gas untouched.
+	if available < retained+params.ExtCallMinCalleeGas {
+		scope.Stack.push(status.SetOne())
+		return nil, nil
+	}
+	if typ == EXTDELEGATECALL && !hasEOFMagic(evm.StateDB.GetCode(toAddr)) {
+		scope.Stack.push(status.SetOne())
+		return nil, nil
+	}
+	gas := available - retained
+	scope.Contract.UseGas(gas, evm.Config.Tracer, tracing.GasChangeIgnored)
+
+	var (
+		ret       []byte
+		returnGas uint64
+		err       error
+	)
+	switch typ {
+	case EXTCALL:
+		ret, returnGas, err = evm.Call(scope.Contract, toAddr, args, gas, value)
+	case EXTDELEGATECALL:
+		ret, returnGas, err = evm.DelegateCall(scope.Contract, toAddr, args, gas)
+	case EXTSTATICCALL:
+		ret, returnGas, err = evm.StaticCall(scope.Contract, toAddr, args, gas)
+	}
+	switch err {
+	case nil:
+		status.Clear()
+	case ErrExecutionReverted, ErrDepth, ErrInsufficientBalance:
+		status.SetOne()
+	default:
+		status.SetUint64(2)
+	}
+	scope.Stack.push(status)
+	scope.Contract.RefundGas(returnGas, evm.Config.Tracer, tracing.GasChangeCallLeftOverRefunded)
+
+	interpreter.returnData = ret
+	return ret, nil
+}
+
+// opExtCodeSizeEOF implements EXTCODESIZE for legacy code once EOF is active,
+// reporting the size of the EOF magic for EOF contracts.
+func opExtCodeSizeEOF(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	slot := scope.Stack.peek()
+	code := interpreter.evm.StateDB.GetCode(slot.Bytes20())
+	if hasEOFMagic(code) {
+		code = eofMagic
+	}
+	slot.SetUint64(uint64(len(code)))
+	return nil, nil
+}
+
+// opExtCodeCopyEOF implements EXTCODECOPY for legacy code once EOF is active,
+// copying only the EOF magic of EOF contracts.
+func opExtCodeCopyEOF(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	var (
+		stack      = scope.Stack
+		a          = stack.pop()
+		memOffset  = stack.pop()
+		codeOffset = stack.pop()
+		length     = stack.pop()
+	)
+	uint64CodeOffset, overflow := codeOffset.Uint64WithOverflow()
+	if overflow {
+		uint64CodeOffset = math.MaxUint64
+	}
+	code := interpreter.evm.StateDB.GetCode(a.Bytes20())
+	if hasEOFMagic(code) {
+		code = eofMagic
+	}
+	scope.Memory.Set(memOffset.Uint64(), length.Uint64(), getData(code, uint64CodeOffset, length.Uint64()))
+	return nil, nil
+}
+
+// opExtCodeHashEOF implements EXTCODEHASH for legacy code once EOF is active,
+// reporting the hash of the EOF magic for EOF contracts.
+func opExtCodeHashEOF(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	slot := scope.Stack.peek()
+	address := common.Address(slot.Bytes20())
+	switch {
+	case interpreter.evm.StateDB.Empty(address):
+		slot.Clear()
+	case hasEOFMagic(interpreter.evm.StateDB.GetCode(address)):
+		slot.SetBytes(eofMagicHash.Bytes())
+	default:
+		slot.SetBytes(interpreter.evm.StateDB.GetCodeHash(address).Bytes())
+	}
+	return nil, nil
+}
@@ -0,0 +1,259 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+func TestEOFMarshaling(t *testing.T) {
+	for i, want := range []Container{
+		{
+			types:        []*functionMetadata{{inputs: 0, outputs: 0x80, maxStackIncrease: 1}},
+			codeSections: [][]byte{common.Hex2Bytes("604200")},
+			data:         []byte{0x01, 0x02, 0x03},
+			dataSize:     3,
+		},
+		{
+			types: []*functionMetadata{
+				{inputs: 0, outputs: 0x80, maxStackIncrease: 1},
+				{inputs: 2, outputs: 3, maxStackIncrease: 4},
+				{inputs: 1, outputs: 1, maxStackIncrease: 1},
+			},
+			codeSections: [][]byte{
+				common.Hex2Bytes("604200"),
+				common.Hex2Bytes("6042604200"),
+				common.Hex2Bytes("00"),
+			},
+			data: []byte{},
+		},
+		{
+			types:        []*functionMetadata{{inputs: 0, outputs: 0x80, maxStackIncrease: 2}},
+			codeSections: [][]byte{common.Hex2Bytes("5f5fee00")},
+			subContainers: []*Container{{
+				types:        []*functionMetadata{{inputs: 0, outputs: 0x80}},
+				codeSections: [][]byte{{byte(STOP)}},
+				dataSize:     4, // truncated, completed by the aux data
+			}},
+			data: []byte{},
+		},
+	} {
+		var (
+			b   = want.MarshalBinary()
+			got Container
+		)
+		if err := got.UnmarshalBinary(b); err != nil {
+			t.Fatalf("test %d: failed to unmarshal container: %v", i, err)
+		}
+		if have := got.MarshalBinary(); !bytes.Equal(have, b) {
+			t.Fatalf("test %d: round trip mismatch: have %x, want %x", i, have, b)
+		}
+		if len(got.subContainers) != len(want.subContainers) {
+			t.Fatalf("test %d: subcontainer count mismatch: have %d, want %d", i, len(got.subContainers), len(want.subContainers))
+		}
+	}
+}
+
+func TestEOFParseErrors(t *testing.T) {
+	for i, test := range []struct {
+		code string
+		err  error
+	}{
+		{"ef0001 010004 0200010001 ff0000 00 00800000 fe", nil},
+		{"ef0001", io.ErrUnexpectedEOF},
+		{"ef0002 010004 0200010001 ff0000 00 00800000 fe", errInvalidVersion},
+		{"ef0101 010004 0200010001 ff0000 00 00800000 fe", errInvalidMagic},
+		{"ef0001 010003 0200010001 ff0000 00 00800000 fe", errInvalidTypeSize},
+		{"ef0001 020004 0200010001 ff0000 00 00800000 fe", errMissingTypeHeader},
+		{"ef0001 010004 020000 ff0000 00 00800000", errInvalidCodeSize},
+		{"ef0001 010004 0200010001 fe0000 00 00800000 fe", errMissingDataHeader},
+		{"ef0001 010004 0200010001 ff0000 01 00800000 fe", errMissingTerminator},
+		{"ef0001 010004 0200010001 ff0000 00 00810000 fe", errTooManyOutputs},
+		{"ef0001 010004 0200010001 ff0000 00 01800000 fe", errInvalidSection0Type},
+		{"ef0001 010004 0200010001 ff0000 00 00800000 fe 00", errInvalidContainerSize},
+		{"ef0001 010004 0200010001 ff0001 00 00800000 fe", errTruncatedTopLevelContainer},
+	} {
+		var c Container
+		err := c.UnmarshalBinary(common.FromHex(strings.ReplaceAll(test.code, " ", "")))
+		if !errors.Is(err, test.err) {
+			t.Errorf("test %d: got error \"%v\", want \"%v\"", i, err, test.err)
+		}
+	}
+}
+
+func TestParseInitcontainer(t *testing.T) {
+	want := Container{
+		types:        []*functionMetadata{{inputs: 0, outputs: 0x80, maxStackIncrease: 0}},
+		codeSections: [][]byte{{byte(INVALID)}},
+		data:         []byte{0xaa},
+		dataSize:     1,
+	}
+	code := want.MarshalBinary()
+	container, initcode, calldata, err := parseInitcontainer(append(bytes.Clone(code), 0x01, 0x02))
+	if err != nil {
+		t.Fatalf("failed to parse initcontainer: %v", err)
+	}
+	if !bytes.Equal(initcode, code) {
+		t.Errorf("initcode mismatch: have %x, want %x", initcode, code)
+	}
+	if !bytes.Equal(calldata, []byte{0x01, 0x02}) {
+		t.Errorf("calldata mismatch: have %x, want 0102", calldata)
+	}
+	if !bytes.Equal(container.data, want.data) {
+		t.Errorf("data mismatch: have %x, want %x", container.data, want.data)
+	}
+}
+
+func TestEOFExecution(t *testing.T) {
+	t.Run("cancun", func(t *testing.T) {
+		testEOFExecution(t, *params.MergedTestChainConfig)
+	})
+	// Taiko chains activate EOF on top of Shanghai.
+	t.Run("shanghai", func(t *testing.T) {
+		config := *params.MergedTestChainConfig
+		config.CancunTime, config.PragueTime, config.VerkleTime = nil, nil, nil
+		testEOFExecution(t, config)
+	})
+}
+
+func testEOFExecution(t *testing.T, config params.ChainConfig) {
+	var (
+		sender = common.Address{0x01}
+		aux    = bytes.Repeat([]byte{0xab}, 32)
+
+		// runtime returns the result of a function call along with the aux
+		// data appended to its data section on deployment.
+		runtime = &Container{
+			types: []*functionMetadata{
+				{inputs: 0, outputs: 0x80, maxStackIncrease: 2},
+				{inputs: 0, outputs: 1, maxStackIncrease: 1},
+			},
+			codeSections: [][]byte{
+				{
+					byte(DATALOADN), 0x00, 0x00, byte(PUSH1), 0x20, byte(MSTORE),
+					byte(CALLF), 0x00, 0x01, byte(PUSH0), byte(MSTORE),
+					byte(PUSH1), 0x40, byte(PUSH0), byte(RETURN),
+				},
+				{byte(PUSH1), 0x07, byte(RETF)},
+			},
+			dataSize: 32,
+		}
+		// initcode deploys the runtime with its calldata as aux data.
+		initcode = &Container{
+			types: []*functionMetadata{{inputs: 0, outputs: 0x80, maxStackIncrease: 2}},
+			codeSections: [][]byte{{
+				byte(PUSH0), byte(CALLDATALOAD), byte(PUSH0), byte(MSTORE),
+				byte(PUSH1), 0x20, byte(PUSH0), byte(RETURNCODE), 0x00,
+			}},
+			subContainers: []*Container{runtime},
+		}
+		// factory creates a contract from the initcode.
+		factory = &Container{
+			types: []*functionMetadata{{inputs: 0, outputs: 0x80, maxStackIncrease: 4}},
+			codeSections: [][]byte{{
+				byte(PUSH0), byte(PUSH0), byte(PUSH0), byte(PUSH0), byte(EOFCREATE), 0x00,
+				byte(PUSH0), byte(MSTORE), byte(PUSH1), 0x20, byte(PUSH0), byte(RETURN),
+			}},
+			subContainers: []*Container{initcode},
+		}
+	)
+	config.EOFBlock = big.NewInt(0)
+
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	statedb.CreateAccount(sender)
+	vmctx := BlockContext{
+		CanTransfer: func(StateDB, common.Address, *uint256.Int) bool { return true },
+		Transfer:    func(StateDB, common.Address, common.Address, *uint256.Int) {},
+		BlockNumber: big.NewInt(0),
+		Random:      &common.Hash{},
+	}
+	evm := NewEVM(vmctx, TxContext{}, statedb, &config, Config{})
+
+	// Deploy the runtime through a creation transaction.
+	data := append(initcode.MarshalBinary(), aux...)
+	_, addr, _, err := evm.Create(AccountRef(sender), data, 1_000_000, new(uint256.Int))
+	if err != nil {
+		t.Fatalf("failed to create contract: %v", err)
+	}
+	var deployed Container
+	if err := deployed.UnmarshalBinary(statedb.GetCode(addr)); err != nil {
+		t.Fatalf("failed to parse deployed code: %v", err)
+	}
+	if !bytes.Equal(deployed.data, aux) {
+		t.Fatalf("deployed data mismatch: have %x, want %x", deployed.data, aux)
+	}
+	ret, _, err := evm.Call(AccountRef(sender), addr, nil, 1_000_000, new(uint256.Int))
+	if err != nil {
+		t.Fatalf("failed to call contract: %v", err)
+	}
+	if want := append(common.LeftPadBytes([]byte{0x07}, 32), aux...); !bytes.Equal(ret, want) {
+		t.Fatalf("return data mismatch: have %x, want %x", ret, want)
+	}
+	// Legacy code only sees the EOF magic.
+	legacy := common.Address{0x02}
+	statedb.SetCode(legacy, append(append([]byte{byte(PUSH20)}, addr.Bytes()...),
+		byte(EXTCODESIZE), byte(PUSH0), byte(MSTORE), byte(PUSH1), 0x20, byte(PUSH0), byte(RETURN)))
+	ret, _, err = evm.Call(AccountRef(sender), legacy, nil, 1_000_000, new(uint256.Int))
+	if err != nil {
+		t.Fatalf("failed to call legacy contract: %v", err)
+	}
+	if size := new(uint256.Int).SetBytes(ret); size.Uint64() != 2 {
+		t.Fatalf("extcodesize mismatch: have %d, want 2", size)
+	}
+	// Deploy the runtime through EOFCREATE.
+	factoryAddr := common.Address{0x03}
+	statedb.SetCode(factoryAddr, factory.MarshalBinary())
+	ret, _, err = evm.Call(AccountRef(sender), factoryAddr, nil, 1_000_000, new(uint256.Int))
+	if err != nil {
+		t.Fatalf("failed to call factory: %v", err)
+	}
+	want := crypto.CreateAddress2(factoryAddr, common.Hash{}, crypto.Keccak256(initcode.MarshalBinary()))
+	if have := common.BytesToAddress(ret); have != want {
+		t.Fatalf("created address mismatch: have %x, want %x", have, want)
+	}
+	if code := statedb.GetCode(want); !hasEOFMagic(code) {
+		t.Fatalf("created code is not an EOF container: %x", code)
+	}
+	// Invalid initcontainers consume all gas, but still bump the nonce.
+	invalid := &Container{
+		types:        []*functionMetadata{{inputs: 0, outputs: 0x80, maxStackIncrease: 1}},
+		codeSections: [][]byte{{byte(PC), byte(INVALID)}},
+	}
+	nonce := statedb.GetNonce(sender)
+	_, _, gas, err := evm.Create(AccountRef(sender), invalid.MarshalBinary(), 1_000_000, new(uint256.Int))
+	if !errors.Is(err, ErrInvalidEOFInitcode) {
+		t.Fatalf("invalid initcode error mismatch: have %v, want %v", err, ErrInvalidEOFInitcode)
+	}
+	if gas != 0 {
+		t.Fatalf("invalid initcode left %d gas", gas)
+	}
+	if have := statedb.GetNonce(sender); have != nonce+1 {
+		t.Fatalf("nonce mismatch: have %d, want %d", have, nonce+1)
+	}
+}
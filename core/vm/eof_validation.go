@@ -0,0 +1,378 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	errUndefinedInstruction     = errors.New("undefined instruction")
+	errTruncatedImmediate       = errors.New("truncated immediate")
+	errInvalidSectionArgument   = errors.New("invalid section argument")
+	errInvalidContainerArgument = errors.New("invalid container argument")
+	errInvalidCallArgument      = errors.New("callf into non-returning section")
+	errInvalidDataloadNArgument = errors.New("invalid dataloadN argument")
+	errInvalidJumpDest          = errors.New("invalid jump destination")
+	errInvalidBackwardJump      = errors.New("invalid backward jump")
+	errInvalidOutputs           = errors.New("invalid number of outputs")
+	errInvalidMaxStackHeight    = errors.New("invalid max stack height")
+	errInvalidCodeTermination   = errors.New("invalid code termination")
+	errUnreachableCode          = errors.New("unreachable code")
+	errInvalidNonReturning      = errors.New("invalid non-returning flag")
+	errEOFStackUnderflow        = errors.New("stack underflow")
+	errEOFStackOverflow         = errors.New("stack overflow")
+	errOrphanedSubcontainer     = errors.New("subcontainer not referenced at all")
+	errAmbiguousContainer       = errors.New("subcontainer referenced by both EOFCREATE and RETURNCODE")
+	errStopInInitcode           = errors.New("initcode contains a RETURN or STOP opcode")
+	errReturnCodeInRuntime      = errors.New("runtime code contains a RETURNCODE opcode")
+)
+
+const (
+	refByEOFCreate  = 1 // subcontainer is an initcontainer
+	refByReturnCode = 2 // subcontainer is deployed runtime code
+)
+
+// validationResult collects the cross-section references of a code section.
+type validationResult struct {
+	visitedCode          map[int]struct{}
+	visitedSubContainers map[int]int
+}
+
+// ValidateCode validates the container against the EOF v1 rules, either as
+// initcode or as runtime code, recursing into its subcontainers. The jump
+// table is the EOF instruction set used to look up valid opcodes and their
+// stack effects.
+func (c *Container) ValidateCode(jt *JumpTable, isInitcode bool) error {
+	visited := make(map[int]struct{})
+	subContainerRefs := make(map[int]int)
+	toVisit := []int{0}
+	for len(toVisit) > 0 {
+		index := toVisit[0]
+		toVisit = toVisit[1:]
+		if _, ok := visited[index]; ok {
+			continue
+		}
+		res, err := validateCode(c.codeSections[index], index, c, jt, isInitcode)
+		if err != nil {
+			return fmt.Errorf("code section %d: %w", index, err)
+		}
+		visited[index] = struct{}{}
+		for idx := range res.visitedCode {
+			if _, ok := visited[idx]; !ok {
+				toVisit = append(toVisit, idx)
+			}
+		}
+		for idx, ref := range res.visitedSubContainers {
+			if prev, ok := subContainerRefs[idx]; ok && prev != ref {
+				return fmt.Errorf("%w: subcontainer %d", errAmbiguousContainer, idx)
+			}
+			subContainerRefs[idx] = ref
+		}
+	}
+	// Every code section must be reachable from the first one.
+	if len(visited) != len(c.codeSections) {
+		return fmt.Errorf("%w: %d of %d code sections reachable", errUnreachableCode, len(visited), len(c.codeSections))
+	}
+	// Every subcontainer must be referenced and is validated according to
+	// the way it is referenced.
+	for idx, sub := range c.subContainers {
+		ref, ok := subContainerRefs[idx]
+		if !ok {
+			return fmt.Errorf("%w: subcontainer %d", errOrphanedSubcontainer, idx)
+		}
+		if ref == refByEOFCreate && len(sub.data) < sub.dataSize {
+			return fmt.Errorf("%w: subcontainer %d", errEOFCreateWithTruncatedSection, idx)
+		}
+		if err := sub.ValidateCode(jt, ref == refByEOFCreate); err != nil {
+			return fmt.Errorf("subcontainer %d: %w", idx, err)
+		}
+	}
+	return nil
+}
+
+// immediateSize returns the size of the immediate argument of the instruction
+// at pos, not counting the opcode itself.
+func immediateSize(code []byte, pos int) int {
+	switch op := OpCode(code[pos]); {
+	case op.IsPush():
+		return int(op - PUSH0)
+	case op == RJUMP || op == RJUMPI || op == CALLF || op == JUMPF || op == DATALOADN:
+		return 2
+	case op == DUPN || op == SWAPN || op == EXCHANGE || op == EOFCREATE || op == RETURNCODE:
+		return 1
+	case op == RJUMPV:
+		if pos+1 >= len(code) {
+			return 1
+		}
+		return 1 + (int(code[pos+1])+1)*2
+	}
+	return 0
+}
+
+// isTerminal reports whether the instruction ends the execution of the
+// current code section.
+func isTerminal(op OpCode) bool {
+	switch op {
+	case STOP, RETURN, REVERT, INVALID, RETF, JUMPF, RETURNCODE:
+		return true
+	}
+	return false
+}
+
+// relativeJumpTargets returns the destinations of the relative jump at pos.
+func relativeJumpTargets(code []byte, pos int) []int {
+	switch OpCode(code[pos]) {
+	case RJUMP, RJUMPI:
+		return []int{pos + 3 + int(int16(binary.BigEndian.Uint16(code[pos+1:])))}
+	case RJUMPV:
+		var (
+			count   = int(code[pos+1]) + 1
+			next    = pos + 2 + count*2
+			targets = make([]int, count)
+		)
+		for i := range targets {
+			targets[i] = next + int(int16(binary.BigEndian.Uint16(code[pos+2+i*2:])))
+		}
+		return targets
+	}
+	return nil
+}
+
+// validateCode validates a single code section of the container: its
+// instructions and immediates, jump destinations and stack heights.
+func validateCode(code []byte, section int, container *Container, jt *JumpTable, isInitcode bool) (*validationResult, error) {
+	var (
+		res = &validationResult{
+			visitedCode:          make(map[int]struct{}),
+			visitedSubContainers: make(map[int]int),
+		}
+		meta      = container.types[section]
+		starts    = make([]bool, len(code))
+		returning bool
+	)
+	// Check the instructions and their immediate arguments.
+	for pos := 0; pos < len(code); {
+		op := OpCode(code[pos])
+		if jt[op].undefined {
+			return nil, fmt.Errorf("%w: op %s, pos %d", errUndefinedInstruction, op, pos)
+		}
+		starts[pos] = true
+
+		size := immediateSize(code, pos)
+		if pos+size >= len(code) && size > 0 {
+			return nil, fmt.Errorf("%w: op %s, pos %d", errTruncatedImmediate, op, pos)
+		}
+		var arg int
+		if size == 1 {
+			arg = int(code[pos+1])
+		} else if size == 2 {
+			arg = int(binary.BigEndian.Uint16(code[pos+1:]))
+		}
+		switch op {
+		case CALLF:
+			if arg >= len(container.types) {
+				return nil, fmt.Errorf("%w: arg %d, last %d, pos %d", errInvalidSectionArgument, arg, len(container.types)-1, pos)
+			}
+			if container.types[arg].outputs == nonReturningFunction {
+				return nil, fmt.Errorf("%w: section %d, pos %d", errInvalidCallArgument, arg, pos)
+			}
+			res.visitedCode[arg] = struct{}{}
+		case JUMPF:
+			if arg >= len(container.types) {
+				return nil, fmt.Errorf("%w: arg %d, last %d, pos %d", errInvalidSectionArgument, arg, len(container.types)-1, pos)
+			}
+			if container.types[arg].outputs != nonReturningFunction {
+				if meta.outputs == nonReturningFunction {
+					return nil, fmt.Errorf("%w: jumpf into returning section %d, pos %d", errInvalidNonReturning, arg, pos)
+				}
+				returning = true
+			}
+			res.visitedCode[arg] = struct{}{}
+		case RETF:
+			if meta.outputs == nonReturningFunction {
+				return nil, fmt.Errorf("%w: retf in non-returning section, pos %d", errInvalidNonReturning, pos)
+			}
+			returning = true
+		case DATALOADN:
+			if arg+32 > container.dataSize {
+				return nil, fmt.Errorf("%w: arg %d, data size %d, pos %d", errInvalidDataloadNArgument, arg, container.dataSize, pos)
+			}
+		case EOFCREATE, RETURNCODE:
+			if arg >= len(container.subContainers) {
+				return nil, fmt.Errorf("%w: arg %d, pos %d", errInvalidContainerArgument, arg, pos)
+			}
+			ref := refByEOFCreate
+			if op == RETURNCODE {
+				if !isInitcode {
+					return nil, fmt.Errorf("%w: pos %d", errReturnCodeInRuntime, pos)
+				}
+				ref = refByReturnCode
+			}
+			if prev, ok := res.visitedSubContainers[arg]; ok && prev != ref {
+				return nil, fmt.Errorf("%w: subcontainer %d, pos %d", errAmbiguousContainer, arg, pos)
+			}
+			res.visitedSubContainers[arg] = ref
+		case STOP, RETURN:
+			if isInitcode {
+				return nil, fmt.Errorf("%w: op %s, pos %d", errStopInInitcode, op, pos)
+			}
+		}
+		pos += size + 1
+	}
+	if meta.outputs != nonReturningFunction && !returning {
+		return nil, fmt.Errorf("%w: returning section without RETF", errInvalidNonReturning)
+	}
+	// Check that all relative jumps land on an instruction.
+	for pos := 0; pos < len(code); pos += immediateSize(code, pos) + 1 {
+		for _, target := range relativeJumpTargets(code, pos) {
+			if target < 0 || target >= len(code) || !starts[target] {
+				return nil, fmt.Errorf("%w: target %d, pos %d", errInvalidJumpDest, target, pos)
+			}
+		}
+	}
+	// Validate the stack heights. Since jumps are either forward or backward
+	// to already visited instructions, a single pass in code order suffices.
+	if err := validateStack(code, section, container, jt); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// validateStack computes the stack height range of every instruction in the
+// code section as defined by EIP-5450, rejecting unreachable instructions,
+// stack underflows and mismatching stack heights on backward jumps.
+func validateStack(code []byte, section int, container *Container, jt *JumpTable) error {
+	var (
+		meta      = container.types[section]
+		minHeight = make([]int, len(code))
+		maxHeight = make([]int, len(code))
+		highest   = int(meta.inputs)
+	)
+	for i := range minHeight {
+		minHeight[i], maxHeight[i] = -1, -1
+	}
+	minHeight[0], maxHeight[0] = int(meta.inputs), int(meta.inputs)
+
+	// visit propagates the stack height range of the instruction at pos to
+	// one of its successors.
+	visit := func(pos, target, lo, hi int) error {
+		if target >= len(code) {
+			return fmt.Errorf("%w: pos %d", errInvalidCodeTermination, pos)
+		}
+		if target > pos {
+			if minHeight[target] < 0 {
+				minHeight[target], maxHeight[target] = lo, hi
+			} else {
+				minHeight[target] = min(minHeight[target], lo)
+				maxHeight[target] = max(maxHeight[target], hi)
+			}
+			return nil
+		}
+		if minHeight[target] != lo || maxHeight[target] != hi {
+			return fmt.Errorf("%w: target %d, pos %d, have [%d, %d], want [%d, %d]", errInvalidBackwardJump, target, pos, lo, hi, minHeight[target], maxHeight[target])
+		}
+		return nil
+	}
+	for pos := 0; pos < len(code); {
+		var (
+			op   = OpCode(code[pos])
+			next = pos + immediateSize(code, pos) + 1
+			lo   = minHeight[pos]
+			hi   = maxHeight[pos]
+		)
+		if lo < 0 {
+			return fmt.Errorf("%w: pos %d", errUnreachableCode, pos)
+		}
+		var pops, pushes int
+		switch op {
+		case CALLF:
+			callee := container.types[binary.BigEndian.Uint16(code[pos+1:])]
+			if hi+int(callee.maxStackIncrease) > int(params.StackLimit) {
+				return fmt.Errorf("%w: pos %d", errEOFStackOverflow, pos)
+			}
+			pops, pushes = int(callee.inputs), int(callee.outputs)
+		case JUMPF:
+			callee := container.types[binary.BigEndian.Uint16(code[pos+1:])]
+			if hi+int(callee.maxStackIncrease) > int(params.StackLimit) {
+				return fmt.Errorf("%w: pos %d", errEOFStackOverflow, pos)
+			}
+			if callee.outputs == nonReturningFunction {
+				pops = int(callee.inputs)
+			} else {
+				if callee.outputs > meta.outputs {
+					return fmt.Errorf("%w: jumpf to section with more outputs, pos %d", errInvalidOutputs, pos)
+				}
+				want := int(meta.outputs) + int(callee.inputs) - int(callee.outputs)
+				if lo != hi || lo != want {
+					return fmt.Errorf("%w: have [%d, %d], want %d, pos %d", errInvalidOutputs, lo, hi, want, pos)
+				}
+			}
+		case RETF:
+			if lo != hi || lo != int(meta.outputs) {
+				return fmt.Errorf("%w: have [%d, %d], want %d, pos %d", errInvalidOutputs, lo, hi, meta.outputs, pos)
+			}
+		case DUPN:
+			pops, pushes = int(code[pos+1])+1, int(code[pos+1])+2
+		case SWAPN:
+			pops, pushes = int(code[pos+1])+2, int(code[pos+1])+2
+		case EXCHANGE:
+			n, m := int(code[pos+1]>>4)+1, int(code[pos+1]&0x0f)+1
+			pops, pushes = n+m+1, n+m+1
+		default:
+			pops = jt[op].minStack
+			pushes = int(params.StackLimit) + pops - jt[op].maxStack
+		}
+		if lo < pops {
+			return fmt.Errorf("%w: op %s, have %d, want %d, pos %d", errEOFStackUnderflow, op, lo, pops, pos)
+		}
+		lo, hi = lo-pops+pushes, hi-pops+pushes
+		highest = max(highest, hi)
+
+		switch {
+		case op == RJUMP:
+			if err := visit(pos, relativeJumpTargets(code, pos)[0], lo, hi); err != nil {
+				return err
+			}
+		case op == RJUMPI || op == RJUMPV:
+			if err := visit(pos, next, lo, hi); err != nil {
+				return err
+			}
+			for _, target := range relativeJumpTargets(code, pos) {
+				if err := visit(pos, target, lo, hi); err != nil {
+					return err
+				}
+			}
+		case !isTerminal(op):
+			if err := visit(pos, next, lo, hi); err != nil {
+				return err
+			}
+		}
+		pos = next
+	}
+	if highest > maxStackHeight {
+		return fmt.Errorf("%w: have %d, limit %d", errInvalidMaxStackHeight, highest, maxStackHeight)
+	}
+	if increase := highest - int(meta.inputs); increase != int(meta.maxStackIncrease) {
+		return fmt.Errorf("%w: have %d, declared %d", errInvalidMaxStackHeight, increase, meta.maxStackIncrease)
+	}
+	return nil
+}
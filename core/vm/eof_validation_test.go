@@ -0,0 +1,254 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateCode(t *testing.T) {
+	_, jt := eofJumpTable(&cancunInstructionSet)
+
+	for i, test := range []struct {
+		code     []byte
+		maxStack uint16
+		data     []byte
+		err      error
+	}{
+		{
+			code:     []byte{byte(CALLER), byte(POP), byte(STOP)},
+			maxStack: 1,
+		},
+		{
+			code:     []byte{byte(PUSH0), byte(RJUMPI), 0x00, 0x01, byte(STOP), byte(STOP)},
+			maxStack: 1,
+		},
+		{
+			code:     []byte{byte(PUSH0), byte(RJUMPV), 0x00, 0x00, 0x00, byte(STOP)},
+			maxStack: 1,
+		},
+		{
+			code:     []byte{byte(RJUMP), 0x00, 0x00, byte(RJUMP), 0xff, 0xfd},
+			maxStack: 0,
+		},
+		{
+			code:     []byte{byte(DATALOADN), 0x00, 0x00, byte(POP), byte(STOP)},
+			maxStack: 1,
+			data:     make([]byte, 32),
+		},
+		{
+			code:     []byte{byte(PUSH0), byte(PUSH0), byte(PUSH0), byte(EXCHANGE), 0x00, byte(DUPN), 0x01, byte(SWAPN), 0x00, byte(STOP)},
+			maxStack: 4,
+		},
+		{
+			code: []byte{byte(INVALID)},
+		},
+		{
+			code:     []byte{byte(PC), byte(STOP)},
+			maxStack: 1,
+			err:      errUndefinedInstruction,
+		},
+		{
+			code: []byte{byte(JUMPDEST), byte(PUSH1)},
+			err:  errTruncatedImmediate,
+		},
+		{
+			code: []byte{byte(RJUMP), 0x00},
+			err:  errTruncatedImmediate,
+		},
+		{
+			code:     []byte{byte(RJUMP), 0x00, 0x01, byte(PUSH1), 0x01, byte(STOP)},
+			maxStack: 1,
+			err:      errInvalidJumpDest,
+		},
+		{
+			code: []byte{byte(RJUMP), 0xff, 0xf0},
+			err:  errInvalidJumpDest,
+		},
+		{
+			code: []byte{byte(STOP), byte(STOP)},
+			err:  errUnreachableCode,
+		},
+		{
+			code:     []byte{byte(PUSH0), byte(POP)},
+			maxStack: 1,
+			err:      errInvalidCodeTermination,
+		},
+		{
+			code: []byte{byte(POP), byte(STOP)},
+			err:  errEOFStackUnderflow,
+		},
+		{
+			code:     []byte{byte(PUSH0), byte(POP), byte(STOP)},
+			maxStack: 2,
+			err:      errInvalidMaxStackHeight,
+		},
+		{
+			code:     []byte{byte(PUSH0), byte(RJUMP), 0xff, 0xfc},
+			maxStack: 1,
+			err:      errInvalidBackwardJump,
+		},
+		{
+			code: []byte{byte(RETF)},
+			err:  errInvalidNonReturning,
+		},
+		{
+			code:     []byte{byte(DATALOADN), 0x00, 0x00, byte(POP), byte(STOP)},
+			maxStack: 1,
+			data:     make([]byte, 31),
+			err:      errInvalidDataloadNArgument,
+		},
+		{
+			code: []byte{byte(CALLF), 0x00, 0x01, byte(STOP)},
+			err:  errInvalidSectionArgument,
+		},
+		{
+			code: []byte{byte(PUSH0), byte(PUSH0), byte(RETURNCODE), 0x00},
+			err:  errInvalidContainerArgument,
+		},
+	} {
+		container := &Container{
+			types:        []*functionMetadata{{inputs: 0, outputs: 0x80, maxStackIncrease: test.maxStack}},
+			codeSections: [][]byte{test.code},
+			data:         test.data,
+			dataSize:     len(test.data),
+		}
+		_, err := validateCode(test.code, 0, container, jt, false)
+		if !errors.Is(err, test.err) {
+			t.Errorf("test %d (%x): got error \"%v\", want \"%v\"", i, test.code, err, test.err)
+		}
+	}
+}
+
+func TestValidateContainer(t *testing.T) {
+	_, jt := eofJumpTable(&cancunInstructionSet)
+
+	var (
+		stop    = &Container{types: []*functionMetadata{{inputs: 0, outputs: 0x80}}, codeSections: [][]byte{{byte(STOP)}}}
+		revert  = &Container{types: []*functionMetadata{{inputs: 0, outputs: 0x80, maxStackIncrease: 2}}, codeSections: [][]byte{{byte(PUSH0), byte(PUSH0), byte(REVERT)}}}
+		deploys = &Container{
+			types:         []*functionMetadata{{inputs: 0, outputs: 0x80, maxStackIncrease: 2}},
+			codeSections:  [][]byte{{byte(PUSH0), byte(PUSH0), byte(RETURNCODE), 0x00}},
+			subContainers: []*Container{stop},
+		}
+	)
+	for i, test := range []struct {
+		container  *Container
+		isInitcode bool
+		err        error
+	}{
+		{
+			// Function calls and returns.
+			container: &Container{
+				types: []*functionMetadata{
+					{inputs: 0, outputs: 0x80, maxStackIncrease: 1},
+					{inputs: 0, outputs: 1, maxStackIncrease: 1},
+					{inputs: 1, outputs: 0x80, maxStackIncrease: 0},
+				},
+				codeSections: [][]byte{
+					{byte(CALLF), 0x00, 0x01, byte(JUMPF), 0x00, 0x02},
+					{byte(PUSH0), byte(RETF)},
+					{byte(POP), byte(STOP)},
+				},
+			},
+		},
+		{
+			// Runtime code creating a contract.
+			container: &Container{
+				types:         []*functionMetadata{{inputs: 0, outputs: 0x80, maxStackIncrease: 4}},
+				codeSections:  [][]byte{{byte(PUSH0), byte(PUSH0), byte(PUSH0), byte(PUSH0), byte(EOFCREATE), 0x00, byte(POP), byte(STOP)}},
+				subContainers: []*Container{deploys},
+			},
+		},
+		{
+			container:  deploys,
+			isInitcode: true,
+		},
+		{
+			container: deploys,
+			err:       errReturnCodeInRuntime,
+		},
+		{
+			container:  stop,
+			isInitcode: true,
+			err:        errStopInInitcode,
+		},
+		{
+			// Sections that are never called.
+			container: &Container{
+				types:        []*functionMetadata{{inputs: 0, outputs: 0x80}, {inputs: 0, outputs: 0x80}},
+				codeSections: [][]byte{{byte(STOP)}, {byte(STOP)}},
+			},
+			err: errUnreachableCode,
+		},
+		{
+			// Calls into a section that never returns.
+			container: &Container{
+				types:        []*functionMetadata{{inputs: 0, outputs: 0x80}, {inputs: 0, outputs: 0x80}},
+				codeSections: [][]byte{{byte(CALLF), 0x00, 0x01, byte(STOP)}, {byte(STOP)}},
+			},
+			err: errInvalidCallArgument,
+		},
+		{
+			// Returning section without RETF.
+			container: &Container{
+				types:        []*functionMetadata{{inputs: 0, outputs: 0x80}, {inputs: 0, outputs: 0}},
+				codeSections: [][]byte{{byte(CALLF), 0x00, 0x01, byte(STOP)}, {byte(STOP)}},
+			},
+			err: errInvalidNonReturning,
+		},
+		{
+			// Subcontainer that is never referenced.
+			container: &Container{
+				types:         []*functionMetadata{{inputs: 0, outputs: 0x80}},
+				codeSections:  [][]byte{{byte(STOP)}},
+				subContainers: []*Container{stop},
+			},
+			err: errOrphanedSubcontainer,
+		},
+		{
+			// Subcontainer used both as initcode and as runtime code.
+			container: &Container{
+				types:         []*functionMetadata{{inputs: 0, outputs: 0x80, maxStackIncrease: 4}},
+				codeSections:  [][]byte{{byte(PUSH0), byte(PUSH0), byte(PUSH0), byte(PUSH0), byte(EOFCREATE), 0x00, byte(PUSH0), byte(RETURNCODE), 0x00}},
+				subContainers: []*Container{revert},
+			},
+			isInitcode: true,
+			err:        errAmbiguousContainer,
+		},
+		{
+			// Creating a contract from initcode with a stop instruction.
+			container: &Container{
+				types:         []*functionMetadata{{inputs: 0, outputs: 0x80, maxStackIncrease: 4}},
+				codeSections:  [][]byte{{byte(PUSH0), byte(PUSH0), byte(PUSH0), byte(PUSH0), byte(EOFCREATE), 0x00, byte(POP), byte(STOP)}},
+				subContainers: []*Container{stop},
+			},
+			err: errStopInInitcode,
+		},
+	} {
+		// Round trip the container to fill in the derived fields.
+		var container Container
+		if err := container.UnmarshalBinary(test.container.MarshalBinary()); err != nil {
+			t.Fatalf("test %d: failed to unmarshal container: %v", i, err)
+		}
+		err := container.ValidateCode(jt, test.isInitcode)
+		if !errors.Is(err, test.err) {
+			t.Errorf("test %d: got error \"%v\", want \"%v\"", i, err, test.err)
+		}
+	}
+}
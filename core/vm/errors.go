@@ -39,6 +39,13 @@ var (
 	ErrInvalidCode              = errors.New("invalid code: must not begin with 0xef")
 	ErrNonceUintOverflow        = errors.New("nonce uint64 overflow")
 
+	// EVM Object Format errors.
+	ErrInvalidEOFInitcode  = errors.New("invalid eof initcode")
+	ErrInvalidAuxDataSize  = errors.New("invalid eof aux data size")
+	ErrReturnStackExceeded = errors.New("return stack limit reached")
+	ErrInvalidAddress      = errors.New("address has non-zero high bytes")
+	ErrLegacyCallToEOF     = errors.New("legacy delegatecall or callcode to eof contract")
+
 	// errStopToken is an internal token indicating interpreter loop termination,
 	// never returned to outside callers.
 	errStopToken = errors.New("stop token")
@@ -285,6 +285,10 @@ var (
 	gasMStore8 = pureMemoryGascost
 	gasMStore  = pureMemoryGascost
 	gasCreate  = pureMemoryGascost
+
+	gasEOFCreate  = pureMemoryGascost
+	gasReturnCode = pureMemoryGascost
+	gasDataCopy   = memoryCopierGas(2)
 )
 
 func gasCreate2(evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySize uint64) (uint64, error) {
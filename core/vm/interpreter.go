@@ -35,6 +35,14 @@ type Config struct {
 	ExtraEips               []int // Additional EIPS that are to be enabled
 
 	StatelessSelfValidation bool // Generate execution witnesses and self-check against them (testing purpose)
+
+	// CHANGE(taiko): execute runs of instructions with constant gas costs as
+	// basic blocks, with a single gas and stack check per block.
+	EnableBlockExecution bool
+
+	// CHANGE(taiko): account the execution cost of every opcode, precompile
+	// and contract. Disables basic block execution.
+	Profiler *Profiler
 }
 
 // ScopeContext contains the things that are per-call, such as stack and memory,
@@ -91,8 +99,9 @@ func (ctx *ScopeContext) ContractCode() []byte {
 
 // EVMInterpreter represents an EVM interpreter
 type EVMInterpreter struct {
-	evm   *EVM
-	table *JumpTable
+	evm      *EVM
+	table    *JumpTable
+	tableEOF *JumpTable // CHANGE(taiko): instruction set of EOF contracts, if active
 
 	hasher    crypto.KeccakState // Keccak256 hasher instance shared across opcodes
 	hasherBuf common.Hash        // Keccak256 hasher result array shared across opcodes
@@ -134,6 +143,15 @@ func NewEVMInterpreter(evm *EVM) *EVMInterpreter {
 	default:
 		table = &frontierInstructionSet
 	}
+	// CHANGE(taiko): EIP-7702 can be activated independently of Prague.
+	if evm.chainRules.IsEIP7702 {
+		table = eip7702JumpTable(table)
+	}
+	// CHANGE(taiko): EOF can be activated independently of any hard fork.
+	var tableEOF *JumpTable
+	if evm.chainRules.IsEOF {
+		table, tableEOF = eofJumpTable(table)
+	}
 	var extraEips []int
 	if len(evm.Config.ExtraEips) > 0 {
 		// Deep-copy jumptable to prevent modification of opcodes in other tables
@@ -148,7 +166,7 @@ func NewEVMInterpreter(evm *EVM) *EVMInterpreter {
 		}
 	}
 	evm.Config.ExtraEips = extraEips
-	return &EVMInterpreter{evm: evm, table: table}
+	return &EVMInterpreter{evm: evm, table: table, tableEOF: tableEOF}
 }
 
 // Run loops and evaluates the contract's code with the given input data and returns
@@ -177,6 +195,22 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 	if len(contract.Code) == 0 {
 		return nil, nil
 	}
+	// CHANGE(taiko): EOF contracts are executed with the EOF instruction set.
+	// Deployed EOF code was validated on creation, so it only needs parsing,
+	// which is cached per code hash.
+	table := in.table
+	if in.tableEOF != nil {
+		if contract.container == nil && !contract.IsDeployment && hasEOFMagic(contract.Code) {
+			container, err := contract.parseContainer()
+			if err != nil {
+				return nil, err
+			}
+			contract.setContainer(container)
+		}
+		if contract.container != nil {
+			table = in.tableEOF
+		}
+	}
 
 	var (
 		op          OpCode        // current opcode
@@ -198,6 +232,7 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 		logged  bool   // deferred EVMLogger should ignore already logged steps
 		res     []byte // result of the opcode execution function
 		debug   = in.evm.Config.Tracer != nil
+		blocks  *codeBlocks // CHANGE(taiko): basic blocks, if executed as such
 	)
 	// Don't move this deferred function, it's placed before the OnOpcode-deferred method,
 	// so that it gets executed _after_: the OnOpcode needs the stacks before
@@ -208,6 +243,19 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 	}()
 	contract.Input = input
 
+	// CHANGE(taiko): account the cost of every instruction if profiling.
+	profiler := in.evm.Config.Profiler
+	if profiler != nil {
+		profiler.enter(contract)
+		defer func() { profiler.exit(contract.Gas, err) }()
+	}
+	// CHANGE(taiko): basic blocks are only executed for legacy code and if no
+	// tracer or profiler needs to observe every single instruction.
+	if in.evm.Config.EnableBlockExecution && profiler == nil && contract.container == nil && !in.evm.chainRules.IsEIP4762 {
+		if hooks := in.evm.Config.Tracer; hooks == nil || (hooks.OnOpcode == nil && hooks.OnFault == nil && hooks.OnGasChange == nil) {
+			blocks = contract.basicBlocks()
+		}
+	}
 	if debug {
 		defer func() { // this deferred method handles exit-with-error
 			if err == nil {
@@ -226,10 +274,24 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 	// the execution of one of the operations or until the done flag is set by the
 	// parent context.
 	for {
+		// CHANGE(taiko): execute the basic block starting at pc in one go if
+		// its gas and stack requirements are met.
+		if blocks != nil {
+			if block := blocks.at(contract, pc, table); block.fits(stack.len(), contract.Gas) {
+				contract.Gas -= block.gas
+				if res, err = in.runBlock(block, &pc, callContext); err != nil {
+					break
+				}
+				continue
+			}
+		}
 		if debug {
 			// Capture pre-execution values for tracing.
 			logged, pcCopy, gasCopy = false, pc, contract.Gas
 		}
+		if profiler != nil {
+			profiler.step(contract.GetOp(pc), contract.Gas) // CHANGE(taiko)
+		}
 
 		if in.evm.chainRules.IsEIP4762 && !contract.IsDeployment {
 			// if the PC ends up in a new "chunk" of verkleized code, charge the
@@ -241,7 +303,7 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 		// Get the operation from the jump table and validate the stack to ensure there are
 		// enough stack items available to perform the operation.
 		op = contract.GetOp(pc)
-		operation := in.table[op]
+		operation := table[op]
 		cost = operation.constantGas // For tracing
 		// Validate stack
 		if sLen := stack.len(); sLen < operation.minStack {
@@ -0,0 +1,251 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/google/pprof/profile"
+)
+
+// ProfileStats is the accumulated execution cost of an opcode, a precompile or
+// a contract.
+type ProfileStats struct {
+	Count uint64        `json:"count"` // Number of executions, or calls for contracts
+	Gas   uint64        `json:"gas"`   // Gas consumed
+	Time  time.Duration `json:"time"`  // Wall time spent executing
+}
+
+func (s *ProfileStats) add(o *ProfileStats) {
+	s.Count += o.Count
+	s.Gas += o.Gas
+	s.Time += o.Time
+}
+
+// profileKey identifies the executions accounted together: the instructions
+// with the same opcode or the calls to the same precompile, made by the code
+// with the same hash.
+type profileKey struct {
+	code       common.Hash    // Hash of the executing code
+	op         OpCode         // Opcode executed, unless a precompile was called
+	precompile common.Address // Precompile called, if any
+	called     bool           // Whether a precompile was called
+}
+
+// profileFrame tracks the execution of a single call frame.
+type profileFrame struct {
+	code    common.Hash
+	gas     uint64    // Gas available on entry
+	entered time.Time // Time of entry
+
+	// Instruction being executed
+	active bool
+	op     OpCode
+	opGas  uint64    // Gas available before the instruction
+	opTime time.Time // Start of the instruction
+
+	// Cost of the nested calls made by the instruction
+	nestedGas  uint64
+	nestedTime time.Duration
+}
+
+// Profiler accounts the wall time, gas and number of executions of every
+// opcode, precompile and contract executed by the EVM. The cost of nested
+// calls is excluded from the instructions making them, so that every unit of
+// gas and time is accounted exactly once, to the code actually spending it.
+//
+// A Profiler may be shared by subsequent executions, but it is not safe for
+// concurrent use.
+type Profiler struct {
+	samples map[profileKey]*ProfileStats
+	calls   map[common.Hash]uint64
+	frames  []*profileFrame
+}
+
+// NewProfiler creates an empty profiler.
+func NewProfiler() *Profiler {
+	return &Profiler{
+		samples: make(map[profileKey]*ProfileStats),
+		calls:   make(map[common.Hash]uint64),
+	}
+}
+
+// record accounts an execution to the given key.
+func (p *Profiler) record(key profileKey, gas uint64, elapsed time.Duration) {
+	stats := p.samples[key]
+	if stats == nil {
+		stats = new(ProfileStats)
+		p.samples[key] = stats
+	}
+	stats.add(&ProfileStats{Count: 1, Gas: gas, Time: elapsed})
+}
+
+// enter starts accounting a call frame executing the given contract.
+func (p *Profiler) enter(contract *Contract) {
+	p.frames = append(p.frames, &profileFrame{
+		code:    contract.CodeHash,
+		gas:     contract.Gas,
+		entered: time.Now(),
+	})
+	p.calls[contract.CodeHash]++
+}
+
+// step accounts the previous instruction of the current frame and starts
+// timing the next one.
+func (p *Profiler) step(op OpCode, gas uint64) {
+	frame := p.frames[len(p.frames)-1]
+	p.flush(frame, gas)
+
+	frame.active, frame.op, frame.opGas, frame.opTime = true, op, gas, time.Now()
+}
+
+// flush accounts the instruction being executed by a frame, given the gas left
+// after it.
+func (p *Profiler) flush(frame *profileFrame, gas uint64) {
+	if !frame.active {
+		return
+	}
+	var (
+		used    = saturatingSub(saturatingSub(frame.opGas, gas), frame.nestedGas)
+		elapsed = max(time.Since(frame.opTime)-frame.nestedTime, 0)
+	)
+	p.record(profileKey{code: frame.code, op: frame.op}, used, elapsed)
+	frame.active, frame.nestedGas, frame.nestedTime = false, 0, 0
+}
+
+// exit stops accounting the current frame, given the gas left and the error
+// it returned with. The cost of the frame is excluded from the instruction of
+// the calling frame.
+func (p *Profiler) exit(gas uint64, err error) {
+	// All gas is consumed unless reverted, which the failing instruction
+	// is accountable for.
+	if err != nil && !errors.Is(err, ErrExecutionReverted) {
+		gas = 0
+	}
+	frame := p.frames[len(p.frames)-1]
+	p.flush(frame, gas)
+
+	p.frames = p.frames[:len(p.frames)-1]
+	if len(p.frames) > 0 {
+		parent := p.frames[len(p.frames)-1]
+		parent.nestedGas += saturatingSub(frame.gas, gas)
+		parent.nestedTime += time.Since(frame.entered)
+	}
+}
+
+// precompile accounts a call to a precompiled contract.
+func (p *Profiler) precompile(addr common.Address, gas uint64, elapsed time.Duration) {
+	key := profileKey{precompile: addr, called: true}
+	if len(p.frames) > 0 {
+		parent := p.frames[len(p.frames)-1]
+		parent.nestedGas += gas
+		parent.nestedTime += elapsed
+		key.code = parent.code
+	}
+	p.record(key, gas, elapsed)
+}
+
+// Opcodes returns the accumulated cost of every opcode executed.
+func (p *Profiler) Opcodes() map[OpCode]*ProfileStats {
+	res := make(map[OpCode]*ProfileStats)
+	for key, stats := range p.samples {
+		if !key.called {
+			accumulate(res, key.op, stats)
+		}
+	}
+	return res
+}
+
+// Precompiles returns the accumulated cost of every precompile called.
+func (p *Profiler) Precompiles() map[common.Address]*ProfileStats {
+	res := make(map[common.Address]*ProfileStats)
+	for key, stats := range p.samples {
+		if key.called {
+			accumulate(res, key.precompile, stats)
+		}
+	}
+	return res
+}
+
+// Contracts returns the accumulated cost of the code executed, keyed by code
+// hash. The cost includes the precompiles called by the code, but excludes
+// any other nested calls.
+func (p *Profiler) Contracts() map[common.Hash]*ProfileStats {
+	res := make(map[common.Hash]*ProfileStats)
+	for key, stats := range p.samples {
+		accumulate(res, key.code, &ProfileStats{Gas: stats.Gas, Time: stats.Time})
+	}
+	for code, calls := range p.calls {
+		accumulate(res, code, &ProfileStats{Count: calls})
+	}
+	return res
+}
+
+func accumulate[K comparable](res map[K]*ProfileStats, key K, stats *ProfileStats) {
+	acc := res[key]
+	if acc == nil {
+		acc = new(ProfileStats)
+		res[key] = acc
+	}
+	acc.add(stats)
+}
+
+// Profile returns the accumulated costs as a pprof profile. Every sample is
+// located in an opcode or precompile, called by the code with a given hash.
+func (p *Profiler) Profile() *profile.Profile {
+	prof := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{Type: "count", Unit: "count"},
+			{Type: "gas", Unit: "gas"},
+			{Type: "time", Unit: "nanoseconds"},
+		},
+		DefaultSampleType: "gas",
+	}
+	locations := make(map[string]*profile.Location)
+	location := func(name string) *profile.Location {
+		if loc, ok := locations[name]; ok {
+			return loc
+		}
+		id := uint64(len(prof.Function) + 1)
+		fn := &profile.Function{ID: id, Name: name, SystemName: name}
+		loc := &profile.Location{ID: id, Line: []profile.Line{{Function: fn}}}
+		prof.Function = append(prof.Function, fn)
+		prof.Location = append(prof.Location, loc)
+		locations[name] = loc
+		return loc
+	}
+	for key, stats := range p.samples {
+		leaf := key.op.String()
+		if key.called {
+			leaf = "precompile " + key.precompile.Hex()
+		}
+		prof.Sample = append(prof.Sample, &profile.Sample{
+			Location: []*profile.Location{location(leaf), location(key.code.Hex())},
+			Value:    []int64{int64(stats.Count), int64(stats.Gas), int64(stats.Time)},
+		})
+	}
+	return prof
+}
+
+func saturatingSub(a, b uint64) uint64 {
+	if a < b {
+		return 0
+	}
+	return a - b
+}
@@ -0,0 +1,91 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+func TestProfiler(t *testing.T) {
+	var (
+		caller = common.BytesToAddress([]byte{0xaa})
+		callee = common.BytesToAddress([]byte{0xbb})
+		sha256 = common.BytesToAddress([]byte{0x02})
+
+		callerCode = []byte{
+			// Call the callee with all gas
+			byte(PUSH1), 0x00, byte(PUSH1), 0x00, byte(PUSH1), 0x00, byte(PUSH1), 0x00, byte(PUSH1), 0x00,
+			byte(PUSH1), 0xbb, byte(GAS), byte(CALL), byte(POP),
+			// Hash nothing with the sha256 precompile
+			byte(PUSH1), 0x20, byte(PUSH1), 0x00, byte(PUSH1), 0x00, byte(PUSH1), 0x00, byte(PUSH1), 0x00,
+			byte(PUSH1), 0x02, byte(GAS), byte(CALL), byte(POP),
+			byte(STOP),
+		}
+		calleeCode = []byte{byte(PUSH1), 0x01, byte(PUSH1), 0x02, byte(ADD), byte(POP), byte(STOP)}
+	)
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	statedb.SetCode(caller, callerCode)
+	statedb.SetCode(callee, calleeCode)
+
+	vmctx := BlockContext{
+		CanTransfer: func(StateDB, common.Address, *uint256.Int) bool { return true },
+		Transfer:    func(StateDB, common.Address, common.Address, *uint256.Int) {},
+		BlockNumber: big.NewInt(0),
+	}
+	profiler := NewProfiler()
+	evm := NewEVM(vmctx, TxContext{}, statedb, params.TestChainConfig, Config{Profiler: profiler, EnableBlockExecution: true})
+
+	const gas = 1_000_000
+	_, left, err := evm.Call(AccountRef(common.Address{}), caller, nil, gas, new(uint256.Int))
+	if err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	opcodes := profiler.Opcodes()
+	if have := opcodes[CALL].Count; have != 2 {
+		t.Errorf("CALL count mismatch: have %d, want 2", have)
+	}
+	if have := opcodes[ADD]; have.Count != 1 || have.Gas != GasFastestStep {
+		t.Errorf("ADD stats mismatch: have %d executions using %d gas, want 1 using %d", have.Count, have.Gas, GasFastestStep)
+	}
+	precompiles := profiler.Precompiles()
+	if have, want := precompiles[sha256].Gas, params.Sha256BaseGas; have != want {
+		t.Errorf("precompile gas mismatch: have %d, want %d", have, want)
+	}
+	contracts := profiler.Contracts()
+	if have := contracts[crypto.Keccak256Hash(calleeCode)]; have.Count != 1 || have.Gas != 11 {
+		t.Errorf("callee stats mismatch: have %d calls using %d gas, want 1 using 11", have.Count, have.Gas)
+	}
+	// Every unit of gas is accounted exactly once.
+	var total uint64
+	for _, stats := range contracts {
+		total += stats.Gas
+	}
+	if total != gas-left {
+		t.Errorf("accounted gas mismatch: have %d, want %d", total, gas-left)
+	}
+	if err := profiler.Profile().CheckValid(); err != nil {
+		t.Errorf("invalid pprof profile: %v", err)
+	}
+}
@@ -203,13 +203,26 @@ func (b *EthAPIBackend) StateAndHeaderByNumber(ctx context.Context, number rpc.B
 	if header == nil {
 		return nil, nil, errors.New("header not found")
 	}
-	stateDb, err := b.eth.BlockChain().StateAt(header.Root)
+	stateDb, err := b.stateAt(header.Root)
 	if err != nil {
 		return nil, nil, err
 	}
 	return stateDb, header, nil
 }
 
+// CHANGE(taiko): stateAt returns the live state of the given root, falling back
+// to the historical state reconstructed from the indexed state histories.
+func (b *EthAPIBackend) stateAt(root common.Hash) (*state.StateDB, error) {
+	statedb, err := b.eth.BlockChain().StateAt(root)
+	if err == nil {
+		return statedb, nil
+	}
+	if historic, herr := b.eth.BlockChain().HistoricState(root); herr == nil {
+		return historic, nil
+	}
+	return nil, err
+}
+
 func (b *EthAPIBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error) {
 	if blockNr, ok := blockNrOrHash.Number(); ok {
 		return b.StateAndHeaderByNumber(ctx, blockNr)
@@ -225,7 +238,7 @@ func (b *EthAPIBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockN
 		if blockNrOrHash.RequireCanonical && b.eth.blockchain.GetCanonicalHash(header.Number.Uint64()) != hash {
 			return nil, nil, errors.New("hash is not currently canonical")
 		}
-		stateDb, err := b.eth.BlockChain().StateAt(header.Root)
+		stateDb, err := b.stateAt(header.Root)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -284,6 +297,10 @@ func (b *EthAPIBackend) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscri
 }
 
 func (b *EthAPIBackend) SendTx(ctx context.Context, signedTx *types.Transaction) error {
+	// CHANGE(taiko): the replica has no peers to propagate the transactions to.
+	if b.eth.config.Replica != "" {
+		return errors.New("transaction submission is not supported in replica mode")
+	}
 	return b.eth.txPool.Add([]*types.Transaction{signedTx}, true, false)[0]
 }
 
@@ -410,6 +427,22 @@ func (b *EthAPIBackend) BloomStatus() (uint64, uint64) {
 	return params.BloomBitsBlocks, sections
 }
 
+// CHANGE(taiko): LogIndexStatus returns the section size of the log index and
+// the number of sections indexed, none if the log index is disabled.
+func (b *EthAPIBackend) LogIndexStatus() (uint64, uint64) {
+	if b.eth.logIndexer == nil {
+		return core.LogIndexSectionSize, 0
+	}
+	sections, _, _ := b.eth.logIndexer.Sections()
+	return core.LogIndexSectionSize, sections
+}
+
+// CHANGE(taiko): LogIndexPositions returns the positions of the logs matching
+// an address or a positional topic within an indexed section.
+func (b *EthAPIBackend) LogIndexPositions(section uint64, address *common.Address, topic common.Hash, position int) ([]uint32, error) {
+	return core.ReadLogPositions(b.eth.chainDb, b.eth.logIndexPostings, address, topic, position, section, b.eth.logIndexer.SectionHead(section))
+}
+
 func (b *EthAPIBackend) ServiceFilter(ctx context.Context, session *bloombits.MatcherSession) {
 	for i := 0; i < bloomFilterThreads; i++ {
 		go session.Multiplex(bloomRetrievalBatch, bloomRetrievalWait, b.eth.bloomRequests)
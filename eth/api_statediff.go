@@ -0,0 +1,163 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"bytes"
+	"context"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// StateDiffResult is the state changes of a canonical block delivered by the
+// stateDiffs subscription. If Reverted is set, the block was reorged out and the
+// changes are rolled back, i.e. the values transit from To back to From. If
+// Resync is set, the changes of a reorg are not available and the subscriber has
+// to reload the state of the block, on top of which the next changes apply.
+type StateDiffResult struct {
+	BlockHash   common.Hash         `json:"blockHash"`
+	BlockNumber hexutil.Uint64      `json:"blockNumber"`
+	ParentHash  common.Hash         `json:"parentHash"`
+	StateRoot   common.Hash         `json:"stateRoot"`
+	Reverted    bool                `json:"reverted"`
+	Resync      bool                `json:"resync,omitempty"`
+	Accounts    []AccountDiffResult `json:"accounts"`
+}
+
+// AccountDiffResult is the change of a single account. The absent From or To
+// means the account didn't exist before or after the block.
+type AccountDiffResult struct {
+	Address      common.Address      `json:"address"`
+	From         *AccountStateResult `json:"from"`
+	To           *AccountStateResult `json:"to"`
+	Code         hexutil.Bytes       `json:"code,omitempty"`
+	Destructed   bool                `json:"destructed,omitempty"`
+	Storage      []SlotDiffResult    `json:"storage,omitempty"`
+	WipedStorage []SlotDiffResult    `json:"wipedStorage,omitempty"`
+}
+
+// AccountStateResult is the account fields tracked by the state diffs.
+type AccountStateResult struct {
+	Balance  *hexutil.Big   `json:"balance"`
+	Nonce    hexutil.Uint64 `json:"nonce"`
+	CodeHash common.Hash    `json:"codeHash"`
+}
+
+// SlotDiffResult is the change of a single storage slot. The key is the raw slot
+// key, except for the wiped slots keyed by the hashes of the slot keys.
+type SlotDiffResult struct {
+	Key  common.Hash `json:"key"`
+	From common.Hash `json:"from"`
+	To   common.Hash `json:"to"`
+}
+
+// StateDiffs creates a subscription that fires the state changes of each block
+// added to the canonical chain, and the reverts of the blocks reorged out.
+func (api *DebugAPI) StateDiffs(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		diffs := make(chan core.StateDiffEvent, 128)
+		diffsSub := api.eth.BlockChain().SubscribeStateDiffEvent(diffs)
+		defer diffsSub.Unsubscribe()
+
+		for {
+			select {
+			case ev := <-diffs:
+				notifier.Notify(rpcSub.ID, newStateDiffResult(ev))
+			case <-rpcSub.Err():
+				return
+			case <-diffsSub.Err():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+// newStateDiffResult converts the state diff event to its RPC representation,
+// with the accounts and slots sorted by their keys.
+func newStateDiffResult(ev core.StateDiffEvent) *StateDiffResult {
+	if ev.Resync {
+		return &StateDiffResult{
+			BlockHash:   ev.Block.Hash(),
+			BlockNumber: hexutil.Uint64(ev.Block.NumberU64()),
+			ParentHash:  ev.Block.ParentHash(),
+			StateRoot:   ev.Block.Root(),
+			Resync:      true,
+			Accounts:    []AccountDiffResult{},
+		}
+	}
+	result := &StateDiffResult{
+		BlockHash:   ev.Block.Hash(),
+		BlockNumber: hexutil.Uint64(ev.Block.NumberU64()),
+		ParentHash:  ev.Block.ParentHash(),
+		StateRoot:   ev.Diff.Root,
+		Reverted:    ev.Reverted,
+		Accounts:    make([]AccountDiffResult, 0, len(ev.Diff.Accounts)),
+	}
+	for addr, diff := range ev.Diff.Accounts {
+		result.Accounts = append(result.Accounts, AccountDiffResult{
+			Address:      addr,
+			From:         newAccountStateResult(diff.Origin),
+			To:           newAccountStateResult(diff.Account),
+			Code:         diff.Code,
+			Destructed:   diff.Destructed,
+			Storage:      newSlotDiffResults(diff.Storage),
+			WipedStorage: newSlotDiffResults(diff.WipedStorage),
+		})
+	}
+	slices.SortFunc(result.Accounts, func(a, b AccountDiffResult) int {
+		return bytes.Compare(a.Address[:], b.Address[:])
+	})
+	return result
+}
+
+func newAccountStateResult(account *types.StateAccount) *AccountStateResult {
+	if account == nil {
+		return nil
+	}
+	return &AccountStateResult{
+		Balance:  (*hexutil.Big)(account.Balance.ToBig()),
+		Nonce:    hexutil.Uint64(account.Nonce),
+		CodeHash: common.BytesToHash(account.CodeHash),
+	}
+}
+
+func newSlotDiffResults(slots map[common.Hash]state.StorageDiff) []SlotDiffResult {
+	if len(slots) == 0 {
+		return nil
+	}
+	results := make([]SlotDiffResult, 0, len(slots))
+	for key, slot := range slots {
+		results = append(results, SlotDiffResult{Key: key, From: slot.Origin, To: slot.Value})
+	}
+	slices.SortFunc(results, func(a, b SlotDiffResult) int {
+		return bytes.Compare(a.Key[:], b.Key[:])
+	})
+	return results
+}
@@ -41,6 +41,7 @@ import (
 	"github.com/ethereum/go-ethereum/eth/ethconfig"
 	"github.com/ethereum/go-ethereum/eth/gasprice"
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/eth/protocols/preconf"
 	"github.com/ethereum/go-ethereum/eth/protocols/snap"
 	"github.com/ethereum/go-ethereum/eth/tracers"
 	"github.com/ethereum/go-ethereum/ethdb"
@@ -72,6 +73,9 @@ type Ethereum struct {
 	handler *handler
 	discmix *enode.FairMix
 
+	// CHANGE(taiko): preconfirmation block gossip, nil if disabled.
+	preconf *preconf.Gossip
+
 	// DB interfaces
 	chainDb ethdb.Database // Block chain database
 
@@ -83,6 +87,9 @@ type Ethereum struct {
 	bloomIndexer      *core.ChainIndexer             // Bloom indexer operating during block imports
 	closeBloomHandler chan struct{}
 
+	logIndexer       *core.ChainIndexer           // CHANGE(taiko): log indexer operating during block imports, if enabled
+	logIndexPostings ethdb.ResettableAncientStore // CHANGE(taiko): freezer holding the postings of the log index
+
 	APIBackend *EthAPIBackend
 
 	miner    *miner.Miner
@@ -121,16 +128,32 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	log.Info("Allocated trie memory caches", "clean", common.StorageSize(config.TrieCleanCache)*1024*1024, "dirty", common.StorageSize(config.TrieDirtyCache)*1024*1024)
 
 	// Assemble the Ethereum object
-	chainDb, err := stack.OpenDatabaseWithFreezer("chaindata", config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, "eth/db/chaindata/", false)
+	// CHANGE(taiko): follow the database of the primary node in replica mode.
+	var (
+		chainDb ethdb.Database
+		err     error
+	)
+	if config.Replica != "" {
+		chainDb, err = stack.OpenReplicaDatabase(config.Replica, config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, "eth/db/chaindata/")
+	} else {
+		chainDb, err = stack.OpenDatabaseWithFreezer("chaindata", config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, "eth/db/chaindata/", false)
+	}
 	if err != nil {
 		return nil, err
 	}
+	// CHANGE(taiko): the replica can only follow primaries using the hash scheme,
+	// the path scheme keeps the recent states in the memory of the primary.
+	if config.Replica != "" {
+		if stored := rawdb.ReadStateScheme(chainDb); stored != rawdb.HashScheme {
+			return nil, fmt.Errorf("replica mode requires the primary to use the %s state scheme, primary database scheme: %q", rawdb.HashScheme, stored)
+		}
+	}
 	scheme, err := rawdb.ParseStateScheme(config.StateScheme, chainDb)
 	if err != nil {
 		return nil, err
 	}
 	// Try to recover offline state pruning only in hash-based.
-	if scheme == rawdb.HashScheme {
+	if scheme == rawdb.HashScheme && config.Replica == "" { // CHANGE(taiko): the replica can't recover pruning.
 		if err := pruner.RecoverPruning(stack.ResolvePath(""), chainDb); err != nil {
 			log.Error("Failed to recover state", "error", err)
 		}
@@ -173,7 +196,7 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	if !config.SkipBcVersionCheck {
 		if bcVersion != nil && *bcVersion > core.BlockChainVersion {
 			return nil, fmt.Errorf("database version is v%d, Geth %s only supports v%d", *bcVersion, params.VersionWithMeta, core.BlockChainVersion)
-		} else if bcVersion == nil || *bcVersion < core.BlockChainVersion {
+		} else if (bcVersion == nil || *bcVersion < core.BlockChainVersion) && config.Replica == "" { // CHANGE(taiko): the replica can't upgrade the database.
 			if bcVersion != nil { // only print warning on upgrade, not on init
 				log.Warn("Upgrade blockchain database version", "from", dbVer, "to", core.BlockChainVersion)
 			}
@@ -183,6 +206,7 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	var (
 		vmConfig = vm.Config{
 			EnablePreimageRecording: config.EnablePreimageRecording,
+			EnableBlockExecution:    config.VMBlockExecution, // CHANGE(taiko): execute basic blocks.
 		}
 		cacheConfig = &core.CacheConfig{
 			TrieCleanLimit:      config.TrieCleanCache,
@@ -194,6 +218,9 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 			Preimages:           config.Preimages,
 			StateHistory:        config.StateHistory,
 			StateScheme:         scheme,
+			StateHistoryIndex:   config.StateHistoryIndex, // CHANGE(taiko): index the state histories.
+			CacheJournal:        config.CacheJournal,      // CHANGE(taiko): persist the clean caches.
+			ParallelExecution:   config.VMParallel,        // CHANGE(taiko): execute transactions in parallel.
 		}
 	)
 	if config.VMTrace != "" {
@@ -215,11 +242,35 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	if config.OverrideVerkle != nil {
 		overrides.OverrideVerkle = config.OverrideVerkle
 	}
-	eth.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, config.Genesis, &overrides, eth.engine, vmConfig, &config.TransactionHistory)
+	// CHANGE(taiko): the replica chain follows the primary without importing
+	// or indexing anything.
+	if config.Replica != "" {
+		eth.blockchain, err = core.NewReplicaBlockChain(chainDb, cacheConfig, eth.engine, vmConfig)
+	} else {
+		eth.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, config.Genesis, &overrides, eth.engine, vmConfig, &config.TransactionHistory)
+	}
 	if err != nil {
 		return nil, err
 	}
-	eth.bloomIndexer.Start(eth.blockchain)
+	if config.Replica == "" {
+		eth.bloomIndexer.Start(eth.blockchain)
+	}
+	// CHANGE(taiko): index the logs of the canonical chain.
+	if config.LogIndex && config.Replica == "" {
+		ancient, err := chainDb.AncientDatadir()
+		if err != nil {
+			ancient = "" // keep the postings in memory without an ancient store
+		}
+		if eth.logIndexPostings, err = rawdb.NewLogIndexFreezer(ancient, false); err != nil {
+			return nil, err
+		}
+		eth.logIndexer = core.NewLogIndexer(chainDb, eth.logIndexPostings, core.LogIndexSectionSize, params.BloomConfirms)
+		eth.logIndexer.Start(eth.blockchain)
+	}
+	// CHANGE(taiko): verify the canonical chain in the background.
+	if config.VerifyDatabase && config.Replica == "" {
+		eth.blockchain.StartVerification()
+	}
 
 	if config.BlobPool.Datadir != "" {
 		config.BlobPool.Datadir = stack.ResolvePath(config.BlobPool.Datadir)
@@ -229,12 +280,22 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = stack.ResolvePath(config.TxPool.Journal)
 	}
+	// CHANGE(taiko): resolve the transaction pool snapshot path.
+	if config.TxPool.Snapshot != "" {
+		config.TxPool.Snapshot = stack.ResolvePath(config.TxPool.Snapshot)
+	}
 	legacyPool := legacypool.New(config.TxPool, eth.blockchain)
 
 	eth.txPool, err = txpool.New(config.TxPool.PriceLimit, eth.blockchain, []txpool.SubPool{legacyPool, blobPool})
 	if err != nil {
 		return nil, err
 	}
+	// CHANGE(taiko): apply the admission and ordering policy of the node.
+	policy, err := txpool.NewPolicy(config.TxPolicy)
+	if err != nil {
+		return nil, err
+	}
+	eth.txPool.SetPolicy(policy)
 	// Permit the downloader to use the trie cache allowance during fast sync
 	cacheLimit := cacheConfig.TrieCleanLimit + cacheConfig.TrieDirtyLimit + cacheConfig.SnapshotLimit
 	if eth.handler, err = newHandler(&handlerConfig{
@@ -251,6 +312,11 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 		return nil, err
 	}
 
+	// CHANGE(taiko): gossip preconfirmation blocks over the `preconf` protocol.
+	if config.Preconf.Enabled && config.Replica == "" {
+		eth.preconf = preconf.NewGossip(eth.blockchain, chainDb, config.Preconf)
+	}
+
 	eth.miner = miner.New(eth, config.Miner, eth.engine)
 	eth.miner.SetExtra(makeExtraData(config.Miner.ExtraData))
 
@@ -269,7 +335,9 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	stack.RegisterLifecycle(eth)
 
 	// Successful startup; push a marker and check previous unclean shutdowns.
-	eth.shutdownTracker.MarkStartup()
+	if config.Replica == "" { // CHANGE(taiko): the replica doesn't track the shutdowns.
+		eth.shutdownTracker.MarkStartup()
+	}
 
 	return eth, nil
 }
@@ -342,16 +410,29 @@ func (s *Ethereum) BloomIndexer() *core.ChainIndexer   { return s.bloomIndexer }
 // Protocols returns all the currently configured
 // network protocols to start.
 func (s *Ethereum) Protocols() []p2p.Protocol {
+	// CHANGE(taiko): the replica doesn't join the network.
+	if s.config.Replica != "" {
+		return nil
+	}
 	protos := eth.MakeProtocols((*ethHandler)(s.handler), s.networkID, s.discmix)
 	if s.config.SnapshotCache > 0 {
 		protos = append(protos, snap.MakeProtocols((*snapHandler)(s.handler))...)
 	}
+	// CHANGE(taiko): serve the `preconf` protocol if gossip is enabled.
+	if s.preconf != nil {
+		protos = append(protos, s.preconf.Protocols()...)
+	}
 	return protos
 }
 
 // Start implements node.Lifecycle, starting all internal goroutines needed by the
 // Ethereum protocol implementation.
 func (s *Ethereum) Start() error {
+	// CHANGE(taiko): the replica only serves the RPC, following the primary.
+	if s.config.Replica != "" {
+		s.startBloomHandlers(params.BloomBitsBlocks)
+		return nil
+	}
 	s.setupDiscovery()
 
 	// Start the bloom bits servicing goroutines
@@ -402,17 +483,25 @@ func (s *Ethereum) setupDiscovery() error {
 func (s *Ethereum) Stop() error {
 	// Stop all the peer-related stuff first.
 	s.discmix.Close()
-	s.handler.Stop()
+	if s.config.Replica == "" { // CHANGE(taiko): the handler isn't started in replica mode.
+		s.handler.Stop()
+	}
 
 	// Then stop everything else.
 	s.bloomIndexer.Close()
+	if s.logIndexer != nil { // CHANGE(taiko): the log indexer is optional.
+		s.logIndexer.Close()
+		s.logIndexPostings.Close()
+	}
 	close(s.closeBloomHandler)
 	s.txPool.Close()
 	s.blockchain.Stop()
 	s.engine.Close()
 
 	// Clean shutdown marker as the last thing before closing db
-	s.shutdownTracker.Stop()
+	if s.config.Replica == "" { // CHANGE(taiko): the replica doesn't track the shutdowns.
+		s.shutdownTracker.Stop()
+	}
 
 	s.chainDb.Close()
 	s.eventMux.Stop()
@@ -28,10 +28,12 @@ import (
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/consensus/taiko"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/txpool"
 	"github.com/ethereum/go-ethereum/core/txpool/blobpool"
 	"github.com/ethereum/go-ethereum/core/txpool/legacypool"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/gasprice"
+	"github.com/ethereum/go-ethereum/eth/protocols/preconf"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/miner"
 	"github.com/ethereum/go-ethereum/params"
@@ -67,6 +69,7 @@ var Defaults = Config{
 	RPCEVMTimeout:      5 * time.Second,
 	GPO:                FullNodeGPO,
 	RPCTxFeeCap:        1, // 1 ether
+	Preconf:            preconf.DefaultConfig,
 }
 
 //go:generate go run github.com/fjl/gencodec -type Config -formats toml -out gen_config.go
@@ -157,6 +160,44 @@ type Config struct {
 
 	// OverrideVerkle (TODO: remove after the fork)
 	OverrideVerkle *uint64 `toml:",omitempty"`
+
+	// CHANGE(taiko): preconfirmation block gossip options.
+	Preconf preconf.Config
+
+	// CHANGE(taiko): admission and ordering policy of the transaction pool.
+	TxPolicy txpool.PolicyConfig
+
+	// CHANGE(taiko): index the state histories to serve historical state in
+	// path scheme, within the retained state history window.
+	StateHistoryIndex bool `toml:",omitempty"`
+
+	// CHANGE(taiko): path of the chain database of a primary node, serving the
+	// read-only RPC in replica mode by following the primary's database.
+	Replica string `toml:",omitempty"`
+
+	// CHANGE(taiko): directory to persist the clean trie and snapshot caches
+	// across restarts, disabled if empty.
+	CacheJournal string `toml:",omitempty"`
+
+	// CHANGE(taiko): execute runs of instructions with constant gas costs as
+	// basic blocks in the EVM.
+	VMBlockExecution bool `toml:",omitempty"`
+
+	// CHANGE(taiko): execute the transactions of a block speculatively in
+	// parallel, falling back to sequential execution on conflicts.
+	VMParallel bool `toml:",omitempty"`
+
+	// CHANGE(taiko): index the flat call traces of the canonical chain in the
+	// background to serve the trace namespace without re-executing blocks.
+	TraceIndex bool `toml:",omitempty"`
+
+	// CHANGE(taiko): index the logs of the canonical chain by address and topic
+	// in the background to serve log queries over large ranges.
+	LogIndex bool `toml:",omitempty"`
+
+	// CHANGE(taiko): verify the consistency of the canonical chain in the
+	// background on startup.
+	VerifyDatabase bool `toml:",omitempty"`
 }
 
 // CreateConsensusEngine creates a consensus engine for the given chain config.
@@ -7,10 +7,12 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/txpool"
 	"github.com/ethereum/go-ethereum/core/txpool/blobpool"
 	"github.com/ethereum/go-ethereum/core/txpool/legacypool"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/gasprice"
+	"github.com/ethereum/go-ethereum/eth/protocols/preconf"
 	"github.com/ethereum/go-ethereum/miner"
 )
 
@@ -52,6 +54,16 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		RPCTxFeeCap             float64
 		OverrideCancun          *uint64 `toml:",omitempty"`
 		OverrideVerkle          *uint64 `toml:",omitempty"`
+		Preconf                 preconf.Config
+		TxPolicy                txpool.PolicyConfig
+		StateHistoryIndex       bool   `toml:",omitempty"`
+		Replica                 string `toml:",omitempty"`
+		CacheJournal            string `toml:",omitempty"`
+		VMBlockExecution        bool   `toml:",omitempty"`
+		VMParallel              bool   `toml:",omitempty"`
+		TraceIndex              bool   `toml:",omitempty"`
+		LogIndex                bool   `toml:",omitempty"`
+		VerifyDatabase          bool   `toml:",omitempty"`
 	}
 	var enc Config
 	enc.Genesis = c.Genesis
@@ -89,6 +101,16 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.RPCTxFeeCap = c.RPCTxFeeCap
 	enc.OverrideCancun = c.OverrideCancun
 	enc.OverrideVerkle = c.OverrideVerkle
+	enc.Preconf = c.Preconf
+	enc.TxPolicy = c.TxPolicy
+	enc.StateHistoryIndex = c.StateHistoryIndex
+	enc.Replica = c.Replica
+	enc.CacheJournal = c.CacheJournal
+	enc.VMBlockExecution = c.VMBlockExecution
+	enc.VMParallel = c.VMParallel
+	enc.TraceIndex = c.TraceIndex
+	enc.LogIndex = c.LogIndex
+	enc.VerifyDatabase = c.VerifyDatabase
 	return &enc, nil
 }
 
@@ -130,6 +152,16 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		RPCTxFeeCap             *float64
 		OverrideCancun          *uint64 `toml:",omitempty"`
 		OverrideVerkle          *uint64 `toml:",omitempty"`
+		Preconf                 *preconf.Config
+		TxPolicy                *txpool.PolicyConfig
+		StateHistoryIndex       *bool   `toml:",omitempty"`
+		Replica                 *string `toml:",omitempty"`
+		CacheJournal            *string `toml:",omitempty"`
+		VMBlockExecution        *bool   `toml:",omitempty"`
+		VMParallel              *bool   `toml:",omitempty"`
+		TraceIndex              *bool   `toml:",omitempty"`
+		LogIndex                *bool   `toml:",omitempty"`
+		VerifyDatabase          *bool   `toml:",omitempty"`
 	}
 	var dec Config
 	if err := unmarshal(&dec); err != nil {
@@ -240,5 +272,35 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.OverrideVerkle != nil {
 		c.OverrideVerkle = dec.OverrideVerkle
 	}
+	if dec.Preconf != nil {
+		c.Preconf = *dec.Preconf
+	}
+	if dec.TxPolicy != nil {
+		c.TxPolicy = *dec.TxPolicy
+	}
+	if dec.StateHistoryIndex != nil {
+		c.StateHistoryIndex = *dec.StateHistoryIndex
+	}
+	if dec.Replica != nil {
+		c.Replica = *dec.Replica
+	}
+	if dec.CacheJournal != nil {
+		c.CacheJournal = *dec.CacheJournal
+	}
+	if dec.VMBlockExecution != nil {
+		c.VMBlockExecution = *dec.VMBlockExecution
+	}
+	if dec.VMParallel != nil {
+		c.VMParallel = *dec.VMParallel
+	}
+	if dec.TraceIndex != nil {
+		c.TraceIndex = *dec.TraceIndex
+	}
+	if dec.LogIndex != nil {
+		c.LogIndex = *dec.LogIndex
+	}
+	if dec.VerifyDatabase != nil {
+		c.VerifyDatabase = *dec.VerifyDatabase
+	}
 	return nil
 }
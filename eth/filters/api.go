@@ -39,6 +39,7 @@ var (
 	errInvalidBlockRange      = errors.New("invalid block range params")
 	errPendingLogsUnsupported = errors.New("pending logs are not supported")
 	errExceedMaxTopics        = errors.New("exceed max topics")
+	errInvalidPageSize        = errors.New("invalid page size") // CHANGE(taiko)
 )
 
 // The maximum number of topic criteria allowed, vm.LOG4 - vm.LOG0
@@ -47,6 +48,9 @@ const maxTopics = 4
 // The maximum number of allowed topics within a topic criteria
 const maxSubTopics = 1000
 
+// CHANGE(taiko): the maximum number of logs returned in a page
+const maxLogsPageSize = 10000
+
 // filter is a helper struct that holds meta information over the filter type
 // and associated subscription in the event system.
 type filter struct {
@@ -334,6 +338,39 @@ func (api *FilterAPI) NewFilter(crit FilterCriteria) (rpc.ID, error) {
 
 // GetLogs returns logs matching the given argument that are stored within the state.
 func (api *FilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([]*types.Log, error) {
+	// CHANGE(taiko): the filter construction is shared with GetLogsPage.
+	filter, err := api.criteriaFilter(crit)
+	if err != nil {
+		return nil, err
+	}
+	// Run the filter and return all the logs
+	logs, err := filter.Logs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return returnLogs(logs), err
+}
+
+// CHANGE(taiko): GetLogsPage returns at most limit logs matching the given
+// argument at or after the cursor, along with the cursor of the next page if
+// more logs match, permitting queries over large ranges to be paginated.
+func (api *FilterAPI) GetLogsPage(ctx context.Context, crit FilterCriteria, cursor *LogCursor, limit hexutil.Uint) (*LogsPage, error) {
+	if limit == 0 || limit > maxLogsPageSize {
+		return nil, errInvalidPageSize
+	}
+	filter, err := api.criteriaFilter(crit)
+	if err != nil {
+		return nil, err
+	}
+	logs, next, err := filter.Page(ctx, cursor, int(limit))
+	if err != nil {
+		return nil, err
+	}
+	return &LogsPage{Logs: returnLogs(logs), Next: next}, nil
+}
+
+// criteriaFilter creates a block or range filter from the given criteria.
+func (api *FilterAPI) criteriaFilter(crit FilterCriteria) (*Filter, error) {
 	if len(crit.Topics) > maxTopics {
 		return nil, errExceedMaxTopics
 	}
@@ -357,12 +394,7 @@ func (api *FilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([]*type
 		// Construct the range filter
 		filter = api.sys.NewRangeFilter(begin, end, crit.Addresses, crit.Topics)
 	}
-	// Run the filter and return all the logs
-	logs, err := filter.Logs(ctx)
-	if err != nil {
-		return nil, err
-	}
-	return returnLogs(logs), err
+	return filter, nil
 }
 
 // UninstallFilter removes the filter with the given filter id.
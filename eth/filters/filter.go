@@ -108,9 +108,28 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 		return f.blockLogs(ctx, header)
 	}
 
+	// CHANGE(taiko): the range resolution is shared with the paginated queries.
+	if err := f.resolveRange(ctx); err != nil {
+		return nil, err
+	}
+	logChan, errChan := f.rangeLogsAsync(ctx)
+	var logs []*types.Log
+	for {
+		select {
+		case log := <-logChan:
+			logs = append(logs, log)
+		case err := <-errChan:
+			return logs, err
+		}
+	}
+}
+
+// resolveRange disallows pending logs and resolves the special begin and end
+// block numbers of a range filter.
+func (f *Filter) resolveRange(ctx context.Context) error {
 	// Disallow pending logs.
 	if f.begin == rpc.PendingBlockNumber.Int64() || f.end == rpc.PendingBlockNumber.Int64() {
-		return nil, errPendingLogsUnsupported
+		return errPendingLogsUnsupported
 	}
 
 	resolveSpecial := func(number int64) (int64, error) {
@@ -142,22 +161,12 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	var err error
 	// range query need to resolve the special begin/end block number
 	if f.begin, err = resolveSpecial(f.begin); err != nil {
-		return nil, err
+		return err
 	}
 	if f.end, err = resolveSpecial(f.end); err != nil {
-		return nil, err
-	}
-
-	logChan, errChan := f.rangeLogsAsync(ctx)
-	var logs []*types.Log
-	for {
-		select {
-		case log := <-logChan:
-			logs = append(logs, log)
-		case err := <-errChan:
-			return logs, err
-		}
+		return err
 	}
+	return nil
 }
 
 // rangeLogsAsync retrieves block-range logs that match the filter criteria asynchronously,
@@ -180,7 +189,12 @@ func (f *Filter) rangeLogsAsync(ctx context.Context) (chan *types.Log, chan erro
 			size, sections = f.sys.backend.BloomStatus()
 			err            error
 		)
-		if indexed := sections * size; indexed > uint64(f.begin) {
+		// CHANGE(taiko): serve the sections covered by the log index first.
+		if err = f.logIndexLogs(ctx, end, logChan); err != nil {
+			errChan <- err
+			return
+		}
+		if indexed := sections * size; indexed > uint64(f.begin) && uint64(f.begin) <= end {
 			if indexed > end {
 				indexed = end + 1
 			}
@@ -0,0 +1,267 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// LogIndexBackend is implemented by the backends maintaining a log index of the
+// canonical chain, which is used in place of the bloom bits for the sections it
+// covers.
+type LogIndexBackend interface {
+	// LogIndexStatus returns the section size of the log index and the number of
+	// sections indexed.
+	LogIndexStatus() (uint64, uint64)
+
+	// LogIndexPositions returns the ascending positions of the logs emitted by the
+	// address, or carrying the topic at the given position if the address is nil,
+	// within an indexed section.
+	LogIndexPositions(section uint64, address *common.Address, topic common.Hash, position int) ([]uint32, error)
+}
+
+// LogCursor is the position of a log in the canonical chain, used to resume a
+// paginated log query.
+type LogCursor struct {
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	LogIndex    hexutil.Uint   `json:"logIndex"`
+}
+
+// LogsPage is a page of the logs matching a filter, along with the cursor of the
+// next page, if any.
+type LogsPage struct {
+	Logs []*types.Log `json:"logs"`
+	Next *LogCursor   `json:"next"`
+}
+
+// Page searches the blockchain for at most limit matching log entries at or after
+// the cursor, returning them along with the cursor of the next matching log if
+// the limit is exceeded.
+func (f *Filter) Page(ctx context.Context, cursor *LogCursor, limit int) ([]*types.Log, *LogCursor, error) {
+	skip := func(log *types.Log) bool {
+		return cursor != nil && (log.BlockNumber < uint64(cursor.BlockNumber) ||
+			log.BlockNumber == uint64(cursor.BlockNumber) && log.Index < uint(cursor.LogIndex))
+	}
+	// Single blocks are small enough to be filtered in one go
+	if f.block != nil {
+		logs, err := f.Logs(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		var page []*types.Log
+		for _, log := range logs {
+			if skip(log) {
+				continue
+			}
+			if len(page) == limit {
+				return page, &LogCursor{BlockNumber: hexutil.Uint64(log.BlockNumber), LogIndex: hexutil.Uint(log.Index)}, nil
+			}
+			page = append(page, log)
+		}
+		return page, nil, nil
+	}
+	if err := f.resolveRange(ctx); err != nil {
+		return nil, nil, err
+	}
+	if cursor != nil && int64(cursor.BlockNumber) > f.begin {
+		f.begin = int64(cursor.BlockNumber)
+	}
+	// Stream the logs until one more than the limit is found, signalling the
+	// start of the next page
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	logChan, errChan := f.rangeLogsAsync(ctx)
+	var page []*types.Log
+	for {
+		select {
+		case log := <-logChan:
+			if skip(log) {
+				continue
+			}
+			if len(page) < limit {
+				page = append(page, log)
+				continue
+			}
+			// Abort the search, draining the pending deliveries
+			cancel()
+			for done := false; !done; {
+				select {
+				case <-logChan:
+				case <-errChan:
+					done = true
+				}
+			}
+			return page, &LogCursor{BlockNumber: hexutil.Uint64(log.BlockNumber), LogIndex: hexutil.Uint(log.Index)}, nil
+
+		case err := <-errChan:
+			return page, nil, err
+		}
+	}
+}
+
+// logIndexLogs returns the logs matching the filter criteria within the sections
+// covered by the log index, advancing the start of the filter past them. Filters
+// without any address or topic constraints are left to the bloom bits.
+func (f *Filter) logIndexLogs(ctx context.Context, end uint64, logChan chan *types.Log) error {
+	backend, ok := f.sys.backend.(LogIndexBackend)
+	if !ok || f.begin < 0 || !f.constrained() {
+		return nil
+	}
+	size, sections := backend.LogIndexStatus()
+	for section := uint64(f.begin) / size; section < sections && uint64(f.begin) <= end; section++ {
+		numbers, err := f.logIndexBlocks(backend, section, size)
+		if err != nil {
+			return err
+		}
+		for _, number := range numbers {
+			if number < uint64(f.begin) {
+				continue
+			}
+			if number > end {
+				break
+			}
+			header, err := f.sys.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+			if err != nil {
+				return err
+			}
+			if header == nil {
+				return fmt.Errorf("indexed block #%d not found", number)
+			}
+			found, err := f.checkMatches(ctx, header)
+			if err != nil {
+				return err
+			}
+			for _, log := range found {
+				select {
+				case logChan <- log:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+		f.begin = int64(min((section+1)*size, end+1))
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logIndexBlocks returns the ascending numbers of the blocks of an indexed section
+// containing logs matching the filter criteria. The positions within each clause
+// are merged, and the clauses intersected.
+func (f *Filter) logIndexBlocks(backend LogIndexBackend, section, size uint64) ([]uint64, error) {
+	var (
+		matches []uint32
+		first   = true
+	)
+	intersect := func(positions []uint32) {
+		if first {
+			matches, first = positions, false
+		} else {
+			matches = intersectPositions(matches, positions)
+		}
+	}
+	if len(f.addresses) > 0 {
+		var positions []uint32
+		for _, address := range f.addresses {
+			found, err := backend.LogIndexPositions(section, &address, common.Hash{}, 0)
+			if err != nil {
+				return nil, err
+			}
+			positions = unionPositions(positions, found)
+		}
+		intersect(positions)
+	}
+	for i, topics := range f.topics {
+		if len(topics) == 0 {
+			continue // empty rule set == wildcard
+		}
+		var positions []uint32
+		for _, topic := range topics {
+			found, err := backend.LogIndexPositions(section, nil, topic, i)
+			if err != nil {
+				return nil, err
+			}
+			positions = unionPositions(positions, found)
+		}
+		intersect(positions)
+	}
+	var numbers []uint64
+	for _, pos := range matches {
+		number := section*size + uint64(pos>>core.LogIndexPositionBits)
+		if len(numbers) == 0 || numbers[len(numbers)-1] != number {
+			numbers = append(numbers, number)
+		}
+	}
+	return numbers, nil
+}
+
+// constrained returns whether the filter has any address or topic constraints.
+func (f *Filter) constrained() bool {
+	if len(f.addresses) > 0 {
+		return true
+	}
+	for _, topics := range f.topics {
+		if len(topics) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// unionPositions merges two ascending lists of log positions.
+func unionPositions(a, b []uint32) []uint32 {
+	merged := make([]uint32, 0, len(a)+len(b))
+	for len(a) > 0 && len(b) > 0 {
+		switch {
+		case a[0] < b[0]:
+			merged, a = append(merged, a[0]), a[1:]
+		case a[0] > b[0]:
+			merged, b = append(merged, b[0]), b[1:]
+		default:
+			merged, a, b = append(merged, a[0]), a[1:], b[1:]
+		}
+	}
+	merged = append(merged, a...)
+	return append(merged, b...)
+}
+
+// intersectPositions returns the log positions present in both ascending lists.
+func intersectPositions(a, b []uint32) []uint32 {
+	var shared []uint32
+	for len(a) > 0 && len(b) > 0 {
+		switch {
+		case a[0] < b[0]:
+			a = a[1:]
+		case a[0] > b[0]:
+			b = b[1:]
+		default:
+			shared, a, b = append(shared, a[0]), a[1:], b[1:]
+		}
+	}
+	return shared
+}
@@ -0,0 +1,226 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/triedb"
+)
+
+const testLogIndexSectionSize = 8
+
+// logIndexBackend extends the test backend with a log index.
+type logIndexBackend struct {
+	*testBackend
+	indexer   *core.ChainIndexer
+	postings  ethdb.AncientStore
+	headFeed  event.Feed
+	positions atomic.Int32 // Number of log index lookups
+}
+
+func (b *logIndexBackend) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
+	return b.headFeed.Subscribe(ch)
+}
+
+func (b *logIndexBackend) LogIndexStatus() (uint64, uint64) {
+	sections, _, _ := b.indexer.Sections()
+	return testLogIndexSectionSize, sections
+}
+
+func (b *logIndexBackend) LogIndexPositions(section uint64, address *common.Address, topic common.Hash, position int) ([]uint32, error) {
+	b.positions.Add(1)
+	return core.ReadLogPositions(b.db, b.postings, address, topic, position, section, b.indexer.SectionHead(section))
+}
+
+func TestLogIndexFilters(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &logIndexBackend{testBackend: &testBackend{db: db}}
+		sys     = NewFilterSystem(backend, Config{})
+		api     = NewFilterAPI(sys)
+
+		addr1  = common.Address{0x01}
+		addr2  = common.Address{0x02}
+		topic1 = common.Hash{0x01}
+		topic2 = common.Hash{0x02}
+
+		gspec = &core.Genesis{
+			Config:  params.TestChainConfig,
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+	)
+	// Emit logs within both indexed sections and the unindexed tail
+	emitted := map[int][]*types.Log{
+		3:  {{Address: addr1, Topics: []common.Hash{topic1}}},
+		5:  {{Address: addr2, Topics: []common.Hash{topic1, topic2}}},
+		12: {{Address: addr1}, {Address: addr2, Topics: []common.Hash{topic2}}, {Address: addr1, Topics: []common.Hash{topic2}}},
+		18: {{Address: addr1, Topics: []common.Hash{topic1}}},
+	}
+	_, chain, receipts := core.GenerateChainWithGenesis(gspec, ethash.NewFaker(), 20, func(i int, gen *core.BlockGen) {
+		if logs, ok := emitted[i+1]; ok {
+			receipt := types.NewReceipt(nil, false, 0)
+			receipt.Logs = logs
+			gen.AddUncheckedReceipt(receipt)
+			gen.AddUncheckedTx(types.NewTransaction(999, common.HexToAddress("0x999"), big.NewInt(999), 999, gen.BaseFee(), nil))
+		}
+	})
+	gspec.MustCommit(db, triedb.NewDatabase(db, triedb.HashDefaults))
+	for i, block := range chain {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	// Index the two full sections of the chain
+	backend.postings, _ = rawdb.NewLogIndexFreezer("", false)
+	backend.indexer = core.NewLogIndexer(db, backend.postings, testLogIndexSectionSize, 0)
+	backend.indexer.Start(backend)
+	defer backend.indexer.Close()
+
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		if _, sections := backend.LogIndexStatus(); sections == 2 {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("log index not generated")
+		}
+	}
+	type position struct {
+		number uint64
+		index  uint
+	}
+	for i, tt := range []struct {
+		crit FilterCriteria
+		want []position
+	}{
+		{FilterCriteria{Addresses: []common.Address{addr1}}, []position{{3, 0}, {12, 0}, {12, 2}, {18, 0}}},
+		{FilterCriteria{Addresses: []common.Address{addr1, addr2}, Topics: [][]common.Hash{{topic2}}}, []position{{12, 1}, {12, 2}}},
+		{FilterCriteria{Topics: [][]common.Hash{{topic1}, {topic2}}}, []position{{5, 0}}},
+		{FilterCriteria{Topics: [][]common.Hash{nil, {topic2}}}, []position{{5, 0}}},
+		{FilterCriteria{Addresses: []common.Address{addr2}, Topics: [][]common.Hash{{topic1}}, FromBlock: big.NewInt(6)}, nil},
+		{FilterCriteria{Addresses: []common.Address{addr1}, FromBlock: big.NewInt(4), ToBlock: big.NewInt(12)}, []position{{12, 0}, {12, 2}}},
+	} {
+		if tt.crit.FromBlock == nil {
+			tt.crit.FromBlock = big.NewInt(0)
+		}
+		backend.positions.Store(0)
+		logs, err := api.GetLogs(context.Background(), tt.crit)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if backend.positions.Load() == 0 {
+			t.Errorf("test %d: log index not used", i)
+		}
+		if len(logs) != len(tt.want) {
+			t.Fatalf("test %d: log count mismatch: have %d, want %d", i, len(logs), len(tt.want))
+		}
+		for j, log := range logs {
+			if log.BlockNumber != tt.want[j].number || log.Index != tt.want[j].index {
+				t.Errorf("test %d: log %d mismatch: have #%d/%d, want #%d/%d", i, j, log.BlockNumber, log.Index, tt.want[j].number, tt.want[j].index)
+			}
+		}
+	}
+	// Unconstrained filters are served by the bloom bits and raw iteration
+	backend.positions.Store(0)
+	logs, err := api.GetLogs(context.Background(), FilterCriteria{FromBlock: big.NewInt(0), ToBlock: big.NewInt(rpc.LatestBlockNumber.Int64())})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 6 || backend.positions.Load() != 0 {
+		t.Errorf("unconstrained filter: have %d logs and %d index lookups, want 6 logs and none", len(logs), backend.positions.Load())
+	}
+	// Indexed blocks missing from the chain are reported
+	rawdb.DeleteCanonicalHash(db, 3)
+	if _, err := api.GetLogs(context.Background(), FilterCriteria{FromBlock: big.NewInt(0), Addresses: []common.Address{addr1}}); err == nil {
+		t.Error("missing indexed block not reported")
+	}
+}
+
+func TestLogsPage(t *testing.T) {
+	var (
+		db           = rawdb.NewMemoryDatabase()
+		_, sys       = newTestFilterSystem(t, db, Config{})
+		api          = NewFilterAPI(sys)
+		addr         = common.Address{0x01}
+		gspec        = &core.Genesis{Config: params.TestChainConfig, BaseFee: big.NewInt(params.InitialBaseFee)}
+		crit         = FilterCriteria{Addresses: []common.Address{addr}, FromBlock: big.NewInt(0), ToBlock: big.NewInt(rpc.LatestBlockNumber.Int64())}
+		_, chain, rs = core.GenerateChainWithGenesis(gspec, ethash.NewFaker(), 5, func(i int, gen *core.BlockGen) {
+			receipt := types.NewReceipt(nil, false, 0)
+			receipt.Logs = []*types.Log{{Address: addr}, {Address: addr}}
+			gen.AddUncheckedReceipt(receipt)
+			gen.AddUncheckedTx(types.NewTransaction(999, common.HexToAddress("0x999"), big.NewInt(999), 999, gen.BaseFee(), nil))
+		})
+	)
+	gspec.MustCommit(db, triedb.NewDatabase(db, triedb.HashDefaults))
+	for i, block := range chain {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), rs[i])
+	}
+	// Page through the ten logs three at a time
+	var (
+		cursor *LogCursor
+		pages  int
+		count  int
+	)
+	for {
+		page, err := api.GetLogsPage(context.Background(), crit, cursor, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, log := range page.Logs {
+			if want := uint64(count/2 + 1); log.BlockNumber != want || log.Index != uint(count%2) {
+				t.Fatalf("log %d mismatch: have #%d/%d, want #%d/%d", count, log.BlockNumber, log.Index, want, count%2)
+			}
+			count++
+		}
+		pages++
+		if cursor = page.Next; cursor == nil {
+			break
+		}
+	}
+	if pages != 4 || count != 10 {
+		t.Fatalf("pagination mismatch: have %d pages of %d logs, want 4 pages of 10 logs", pages, count)
+	}
+	// Block filters are paginated as well
+	hash := chain[1].Hash()
+	page, err := api.GetLogsPage(context.Background(), FilterCriteria{BlockHash: &hash}, &LogCursor{BlockNumber: 2, LogIndex: 1}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Logs) != 1 || page.Logs[0].Index != 1 || page.Next != nil {
+		t.Fatalf("block page mismatch: have %d logs, next %v", len(page.Logs), page.Next)
+	}
+	if _, err := api.GetLogsPage(context.Background(), crit, nil, 0); err != errInvalidPageSize {
+		t.Fatalf("empty page: have %v, want %v", err, errInvalidPageSize)
+	}
+}
@@ -483,6 +483,11 @@ func (h *handler) BroadcastTransactions(txs types.Transactions) {
 		hash   = make([]byte, 32)
 	)
 	for _, tx := range txs {
+		// CHANGE(taiko): conditional transactions are kept private, as peers
+		// would include them regardless of their conditions.
+		if tx.Conditional() != nil {
+			continue
+		}
 		var maybeDirect bool
 		switch {
 		case tx.Type() == types.BlobTxType:
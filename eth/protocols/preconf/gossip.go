@@ -0,0 +1,280 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package preconf
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"golang.org/x/time/rate"
+)
+
+// maxSeenBlocks is the number of recently seen preconfirmation block hashes to
+// remember for deduplication.
+const maxSeenBlocks = 4096
+
+var (
+	errPeerAlreadyRegistered = errors.New("peer already registered")
+	errUnknownParent         = errors.New("unknown parent block")
+	errAlreadyConfirmed      = errors.New("block height already confirmed on L1")
+	errStaleBlock            = errors.New("block neither extends nor replaces the chain head")
+)
+
+// Config contains the settings of the preconfirmation block gossip.
+type Config struct {
+	Enabled   bool             // Whether to run the `preconf` protocol
+	Whitelist []common.Address // Sequencers allowed to sign preconfirmation blocks
+	RateLimit float64          // Maximum preconfirmation blocks accepted per second from a single peer
+	RateBurst int              // Maximum burst of preconfirmation blocks accepted from a single peer
+}
+
+// DefaultConfig contains the default settings of the preconfirmation gossip.
+var DefaultConfig = Config{
+	RateLimit: 10,
+	RateBurst: 32,
+}
+
+// PeerInfo represents a short summary of the `preconf` sub-protocol metadata known
+// about a connected peer.
+type PeerInfo struct {
+	Version uint `json:"version"` // Preconf protocol version negotiated
+}
+
+// Gossip implements the `preconf` protocol backend. It validates the signed
+// preconfirmation blocks received from the network against the whitelist of
+// sequencers, inserts them into the local chain as preconfirmed blocks and
+// relays them to the rest of the connected peers.
+type Gossip struct {
+	chain     *core.BlockChain
+	db        ethdb.Database
+	config    Config
+	whitelist map[common.Address]struct{}
+
+	seen  *lru.Cache[common.Hash, struct{}] // Recently seen blocks for deduplication
+	peers map[string]*Peer                  // Currently connected `preconf` peers
+	lock  sync.RWMutex                      // Protects the peer set
+
+	insertLock sync.Mutex // Serializes the insertion of preconfirmation blocks
+}
+
+// NewGossip creates a preconfirmation gossip backend on top of the given chain.
+func NewGossip(chain *core.BlockChain, db ethdb.Database, config Config) *Gossip {
+	whitelist := make(map[common.Address]struct{}, len(config.Whitelist))
+	for _, addr := range config.Whitelist {
+		whitelist[addr] = struct{}{}
+	}
+	if len(whitelist) == 0 {
+		log.Warn("Preconfirmation gossip enabled without whitelisted sequencers")
+	}
+	return &Gossip{
+		chain:     chain,
+		db:        db,
+		config:    config,
+		whitelist: whitelist,
+		seen:      lru.NewCache[common.Hash, struct{}](maxSeenBlocks),
+		peers:     make(map[string]*Peer),
+	}
+}
+
+// Protocols returns the P2P protocol definitions served by the gossip backend.
+func (g *Gossip) Protocols() []p2p.Protocol {
+	return MakeProtocols(g)
+}
+
+// Chain retrieves the blockchain object to serve data.
+func (g *Gossip) Chain() *core.BlockChain { return g.chain }
+
+// RunPeer is invoked when a peer joins on the `preconf` protocol.
+func (g *Gossip) RunPeer(peer *Peer, handler Handler) error {
+	peer.limiter = rate.NewLimiter(rate.Limit(g.config.RateLimit), g.config.RateBurst)
+	defer peer.Close()
+
+	g.lock.Lock()
+	if _, ok := g.peers[peer.id]; ok {
+		g.lock.Unlock()
+		return errPeerAlreadyRegistered
+	}
+	g.peers[peer.id] = peer
+	g.lock.Unlock()
+
+	defer func() {
+		g.lock.Lock()
+		delete(g.peers, peer.id)
+		g.lock.Unlock()
+	}()
+	return handler(peer)
+}
+
+// PeerInfo retrieves all known `preconf` information about a peer.
+func (g *Gossip) PeerInfo(id enode.ID) interface{} {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	if p, ok := g.peers[id.String()]; ok {
+		return &PeerInfo{Version: p.Version()}
+	}
+	return nil
+}
+
+// Handle is invoked from a peer's message handler when it receives a new remote
+// message that the handler couldn't consume and serve itself.
+func (g *Gossip) Handle(peer *Peer, packet Packet) error {
+	switch packet := packet.(type) {
+	case *NewPreconfBlockPacket:
+		return g.handlePreconfBlock(peer, packet)
+
+	default:
+		return fmt.Errorf("unexpected preconf packet type: %T", packet)
+	}
+}
+
+// handlePreconfBlock validates, imports and relays a preconfirmation block
+// received from a remote peer. Only a malformed signature is considered peer
+// misbehaviour, everything else (including blocks signed by sequencers which
+// are not whitelisted locally) is silently dropped. Blocks are only marked as
+// seen once imported, so that blocks arriving ahead of their parents can be
+// retried on a later delivery.
+func (g *Gossip) handlePreconfBlock(peer *Peer, packet *NewPreconfBlockPacket) error {
+	if !peer.limiter.Allow() {
+		rateLimitedMeter.Mark(1)
+		peer.Log().Trace("Dropping rate limited preconf block", "number", packet.Block.NumberU64(), "hash", packet.Block.Hash())
+		return nil
+	}
+	hash := packet.Block.Hash()
+	if g.seen.Contains(hash) {
+		duplicateMeter.Mark(1)
+		return nil
+	}
+	if err := g.verify(packet); err != nil {
+		unauthorizedMeter.Mark(1)
+		if errors.Is(err, errUnauthorizedSigner) {
+			peer.Log().Debug("Dropping unauthorized preconf block", "number", packet.Block.NumberU64(), "hash", hash, "err", err)
+			return nil
+		}
+		return err
+	}
+	if err := g.insert(packet.Block); err != nil {
+		insertFailureMeter.Mark(1)
+		peer.Log().Debug("Failed to import preconf block", "number", packet.Block.NumberU64(), "hash", hash, "err", err)
+		return nil
+	}
+	g.seen.Add(hash, struct{}{})
+	acceptedMeter.Mark(1)
+	log.Debug("Imported gossiped preconf block", "number", packet.Block.NumberU64(), "hash", hash, "peer", peer.ID())
+
+	g.broadcast(packet)
+	return nil
+}
+
+// BroadcastBlock propagates a locally known preconfirmation block, signed by a
+// whitelisted sequencer, to all connected peers.
+func (g *Gossip) BroadcastBlock(block *types.Block, signature []byte) error {
+	packet := &NewPreconfBlockPacket{Block: block, Signature: signature}
+	if err := g.verify(packet); err != nil {
+		return err
+	}
+	g.seen.Add(block.Hash(), struct{}{})
+	g.broadcast(packet)
+	return nil
+}
+
+// verify checks that the packet is signed by one of the whitelisted sequencers.
+func (g *Gossip) verify(packet *NewPreconfBlockPacket) error {
+	signer, err := packet.Signer(g.chain.Config().ChainID)
+	if err != nil {
+		return err
+	}
+	if _, ok := g.whitelist[signer]; !ok {
+		return fmt.Errorf("%w: %v", errUnauthorizedSigner, signer)
+	}
+	return nil
+}
+
+// insert imports the block into the local chain, sets it as the new head and
+// records a preconfirmation L1Origin for it. Only blocks extending the current
+// head, or replacing the preconfirmation block at the head height are accepted,
+// so late deliveries can never rewind the chain. Block heights already confirmed
+// by an L1 proposal are never overridden.
+func (g *Gossip) insert(block *types.Block) error {
+	g.insertLock.Lock()
+	defer g.insertLock.Unlock()
+
+	hash := block.Hash()
+	origin, err := rawdb.ReadL1Origin(g.db, block.Number())
+	if err != nil {
+		return err
+	}
+	if origin != nil && !origin.IsPreconfBlock() {
+		if origin.L2BlockHash == hash {
+			return nil
+		}
+		return errAlreadyConfirmed
+	}
+	head := g.chain.CurrentBlock()
+	switch {
+	case head.Hash() == hash:
+		return nil
+	case block.ParentHash() == head.Hash():
+		// Block extends the current head
+	case block.NumberU64() == head.Number.Uint64() && origin != nil:
+		// Block replaces the preconfirmation block at the head height
+	default:
+		return fmt.Errorf("%w: number %d, head %d", errStaleBlock, block.NumberU64(), head.Number)
+	}
+	if !g.chain.HasBlock(hash, block.NumberU64()) {
+		if !g.chain.HasBlockAndState(block.ParentHash(), block.NumberU64()-1) {
+			return errUnknownParent
+		}
+		if _, err := g.chain.InsertBlockWithoutSetHead(block, false); err != nil {
+			return err
+		}
+	}
+	if _, err := g.chain.SetCanonical(block); err != nil {
+		return err
+	}
+	// The L1 block height is deliberately left nil, it marks the origin as a
+	// preconfirmation (see L1Origin.IsPreconfBlock) until the block is proposed
+	// on L1 and the origin is overwritten by the driver.
+	rawdb.WriteL1Origin(g.db, block.Number(), &rawdb.L1Origin{
+		BlockID:     block.Number(),
+		L2BlockHash: hash,
+	})
+	return nil
+}
+
+// broadcast relays the packet to all connected peers not yet knowing about it.
+func (g *Gossip) broadcast(packet *NewPreconfBlockPacket) {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	hash := packet.Block.Hash()
+	for _, peer := range g.peers {
+		if !peer.KnownBlock(hash) {
+			peer.AsyncSendPreconfBlock(packet)
+		}
+	}
+}
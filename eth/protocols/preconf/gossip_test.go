@@ -0,0 +1,363 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package preconf
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/beacon"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	// sequencerKey is the whitelisted key signing the preconfirmation blocks.
+	sequencerKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+
+	// rogueKey is a non-whitelisted key trying to forge preconfirmation blocks.
+	rogueKey, _ = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+)
+
+// testGossip is a preconfirmation gossip backend with its own blockchain.
+type testGossip struct {
+	*Gossip
+	db ethdb.Database
+}
+
+// newTestGenesis returns the genesis shared by all the test nodes.
+func newTestGenesis() *core.Genesis {
+	return &core.Genesis{
+		Config:  params.MergedTestChainConfig,
+		BaseFee: big.NewInt(params.InitialBaseFee),
+	}
+}
+
+// newTestGossip creates a gossip backend on top of an empty test chain.
+func newTestGossip(t *testing.T, config Config) *testGossip {
+	t.Helper()
+
+	db := rawdb.NewMemoryDatabase()
+	chain, err := core.NewBlockChain(db, nil, newTestGenesis(), nil, beacon.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create test chain: %v", err)
+	}
+	t.Cleanup(chain.Stop)
+
+	if config.Whitelist == nil {
+		config.Whitelist = append(config.Whitelist, crypto.PubkeyToAddress(sequencerKey.PublicKey))
+	}
+	if config.RateLimit == 0 {
+		config.RateLimit, config.RateBurst = DefaultConfig.RateLimit, DefaultConfig.RateBurst
+	}
+	return &testGossip{Gossip: NewGossip(chain, db, config), db: db}
+}
+
+// newTestBlocks generates a chain of blocks on top of the test genesis.
+func newTestBlocks(n int) []*types.Block {
+	_, blocks, _ := core.GenerateChainWithGenesis(newTestGenesis(), beacon.NewFaker(), n, nil)
+	return blocks
+}
+
+// signBlock signs the block for preconfirmation gossip with the given key.
+func signBlock(t *testing.T, key *ecdsa.PrivateKey, block *types.Block) []byte {
+	t.Helper()
+
+	sig, err := crypto.Sign(SigningHash(params.MergedTestChainConfig.ChainID, block.Hash()).Bytes(), key)
+	if err != nil {
+		t.Fatalf("failed to sign block: %v", err)
+	}
+	return sig
+}
+
+// connect links two gossip backends with an in-memory message pipe.
+func connect(a, b *testGossip) {
+	pa, pb := p2p.MsgPipe()
+
+	peerA := NewPeer(PRECONF1, p2p.NewPeerPipe(enode.ID{0x0a}, "a", nil, pb), pb)
+	peerB := NewPeer(PRECONF1, p2p.NewPeerPipe(enode.ID{0x0b}, "b", nil, pa), pa)
+
+	go a.RunPeer(peerB, func(peer *Peer) error { return Handle(a, peer) })
+	go b.RunPeer(peerA, func(peer *Peer) error { return Handle(b, peer) })
+}
+
+// testPeer is a raw remote endpoint attached to a gossip backend.
+type testPeer struct {
+	rw   *p2p.MsgPipeRW
+	done chan error
+}
+
+// newTestPeer attaches a raw remote endpoint to the gossip backend.
+func newTestPeer(g *testGossip) *testPeer {
+	local, remote := p2p.MsgPipe()
+	peer := NewPeer(PRECONF1, p2p.NewPeerPipe(enode.ID{0xff}, "remote", nil, local), local)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- g.RunPeer(peer, func(peer *Peer) error { return Handle(g, peer) })
+	}()
+	return &testPeer{rw: remote, done: done}
+}
+
+// send delivers a preconfirmation block packet to the gossip backend.
+func (p *testPeer) send(t *testing.T, block *types.Block, sig []byte) {
+	t.Helper()
+
+	if err := p2p.Send(p.rw, NewPreconfBlockMsg, &NewPreconfBlockPacket{Block: block, Signature: sig}); err != nil {
+		t.Fatalf("failed to send preconf block: %v", err)
+	}
+}
+
+// waitHead waits until the chain head of the backend reaches the given block.
+func waitHead(t *testing.T, g *testGossip, block *types.Block) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if g.chain.CurrentBlock().Hash() == block.Hash() {
+			return
+		}
+	}
+	t.Fatalf("head mismatch: have %d, want %d", g.chain.CurrentBlock().Number, block.Number())
+}
+
+// checkPreconfOrigin ensures a preconfirmation L1Origin is recorded for the block.
+func checkPreconfOrigin(t *testing.T, g *testGossip, block *types.Block) {
+	t.Helper()
+
+	origin, err := rawdb.ReadL1Origin(g.db, block.Number())
+	if err != nil {
+		t.Fatalf("failed to read L1Origin: %v", err)
+	}
+	if origin == nil {
+		t.Fatalf("missing L1Origin for block %d", block.NumberU64())
+	}
+	if !origin.IsPreconfBlock() {
+		t.Fatalf("L1Origin of block %d is not a preconfirmation", block.NumberU64())
+	}
+	if origin.L2BlockHash != block.Hash() {
+		t.Fatalf("L1Origin hash mismatch: have %x, want %x", origin.L2BlockHash, block.Hash())
+	}
+}
+
+// Tests that signed preconfirmation blocks are imported and relayed across
+// multiple hops of the network.
+func TestGossipPropagation(t *testing.T) {
+	var (
+		source   = newTestGossip(t, Config{})
+		relay    = newTestGossip(t, Config{})
+		follower = newTestGossip(t, Config{})
+		blocks   = newTestBlocks(3)
+	)
+	connect(source, relay)
+	connect(relay, follower)
+
+	// Wait for the peers to be registered before broadcasting
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		relay.lock.RLock()
+		n := len(relay.peers)
+		relay.lock.RUnlock()
+		if n == 2 {
+			break
+		}
+	}
+	for _, block := range blocks {
+		if err := source.BroadcastBlock(block, signBlock(t, sequencerKey, block)); err != nil {
+			t.Fatalf("failed to broadcast block %d: %v", block.NumberU64(), err)
+		}
+		waitHead(t, relay, block)
+		waitHead(t, follower, block)
+	}
+	for _, block := range blocks {
+		checkPreconfOrigin(t, relay, block)
+		checkPreconfOrigin(t, follower, block)
+	}
+}
+
+// Tests that blocks signed by a non-whitelisted key are dropped without
+// disconnecting the delivering peer, while malformed signatures do disconnect.
+func TestGossipUnauthorizedSigner(t *testing.T) {
+	var (
+		g      = newTestGossip(t, Config{})
+		peer   = newTestPeer(g)
+		blocks = newTestBlocks(1)
+	)
+	if err := g.BroadcastBlock(blocks[0], signBlock(t, rogueKey, blocks[0])); !errors.Is(err, errUnauthorizedSigner) {
+		t.Fatalf("local broadcast error mismatch: have %v, want %v", err, errUnauthorizedSigner)
+	}
+	peer.send(t, blocks[0], signBlock(t, rogueKey, blocks[0]))
+
+	select {
+	case err := <-peer.done:
+		t.Fatalf("peer disconnected for unauthorized signer: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if head := g.chain.CurrentBlock(); head.Number.Sign() != 0 {
+		t.Fatalf("unauthorized block imported: head %d", head.Number)
+	}
+	peer.send(t, blocks[0], []byte{0x01})
+
+	select {
+	case err := <-peer.done:
+		if !errors.Is(err, errInvalidSignature) {
+			t.Fatalf("disconnect reason mismatch: have %v, want %v", err, errInvalidSignature)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("peer not disconnected")
+	}
+}
+
+// Tests that blocks delivered ahead of their parent are not marked as seen, so
+// that they are imported when redelivered after the parent.
+func TestGossipUnknownParentRetry(t *testing.T) {
+	var (
+		g      = newTestGossip(t, Config{})
+		peer   = newTestPeer(g)
+		blocks = newTestBlocks(2)
+	)
+	peer.send(t, blocks[1], signBlock(t, sequencerKey, blocks[1]))
+	peer.send(t, blocks[0], signBlock(t, sequencerKey, blocks[0]))
+	waitHead(t, g, blocks[0])
+
+	peer.send(t, blocks[1], signBlock(t, sequencerKey, blocks[1]))
+	waitHead(t, g, blocks[1])
+}
+
+// Tests that duplicate deliveries are dropped and that peers exceeding their
+// allowance are rate limited without being disconnected.
+func TestGossipDedupAndRateLimit(t *testing.T) {
+	var (
+		g      = newTestGossip(t, Config{RateLimit: 0.0001, RateBurst: 2})
+		peer   = newTestPeer(g)
+		blocks = newTestBlocks(2)
+	)
+	peer.send(t, blocks[0], signBlock(t, sequencerKey, blocks[0]))
+	waitHead(t, g, blocks[0])
+
+	// Deliver the same block again, it should be deduplicated, but still
+	// count against the rate limit of the peer
+	peer.send(t, blocks[0], signBlock(t, sequencerKey, blocks[0]))
+
+	// The burst is exhausted, the next block must be dropped
+	peer.send(t, blocks[1], signBlock(t, sequencerKey, blocks[1]))
+
+	// Send a final message to ensure all previous ones were processed
+	peer.send(t, blocks[1], signBlock(t, sequencerKey, blocks[1]))
+
+	if have := g.chain.CurrentBlock().Hash(); have != blocks[0].Hash() {
+		t.Fatalf("rate limited block imported: head %x", have)
+	}
+	select {
+	case err := <-peer.done:
+		t.Fatalf("rate limited peer disconnected: %v", err)
+	default:
+	}
+}
+
+// Tests that gossip never overrides a block height which is already confirmed
+// by an L1 proposal.
+func TestGossipConfirmedHeight(t *testing.T) {
+	var (
+		g      = newTestGossip(t, Config{})
+		peer   = newTestPeer(g)
+		blocks = newTestBlocks(1)
+	)
+	rawdb.WriteL1Origin(g.db, blocks[0].Number(), &rawdb.L1Origin{
+		BlockID:       blocks[0].Number(),
+		L1BlockHeight: big.NewInt(1),
+	})
+	peer.send(t, blocks[0], signBlock(t, sequencerKey, blocks[0]))
+	peer.send(t, blocks[0], signBlock(t, sequencerKey, blocks[0]))
+
+	if head := g.chain.CurrentBlock(); head.Number.Sign() != 0 {
+		t.Fatalf("confirmed height overridden: head %d", head.Number)
+	}
+	origin, _ := rawdb.ReadL1Origin(g.db, blocks[0].Number())
+	if origin.IsPreconfBlock() {
+		t.Fatal("confirmed L1Origin overridden by preconfirmation")
+	}
+}
+
+// Tests that gossiped blocks only ever extend the chain head or replace the
+// preconfirmation block at the head height, never rewinding the chain.
+func TestGossipStaleBlock(t *testing.T) {
+	var (
+		g       = newTestGossip(t, Config{})
+		blocks  = newTestBlocks(2)
+		genesis = newTestGenesis()
+	)
+	_, forks, _ := core.GenerateChainWithGenesis(genesis, beacon.NewFaker(), 1, func(i int, gen *core.BlockGen) {
+		gen.SetCoinbase(common.Address{0x01})
+	})
+	for _, block := range blocks {
+		if err := g.insert(block); err != nil {
+			t.Fatalf("failed to insert block %d: %v", block.NumberU64(), err)
+		}
+	}
+	if err := g.insert(forks[0]); !errors.Is(err, errStaleBlock) {
+		t.Fatalf("stale block error mismatch: have %v, want %v", err, errStaleBlock)
+	}
+	if have := g.chain.CurrentBlock().Hash(); have != blocks[1].Hash() {
+		t.Fatalf("head rewound by stale block: have %x, want %x", have, blocks[1].Hash())
+	}
+	// Replacing the preconfirmation block at the head height is allowed
+	_, forks, _ = core.GenerateChainWithGenesis(genesis, beacon.NewFaker(), 2, func(i int, gen *core.BlockGen) {
+		if i == 1 {
+			gen.SetCoinbase(common.Address{0x01})
+		}
+	})
+	if forks[0].Hash() != blocks[0].Hash() {
+		t.Fatal("fork does not share the first block")
+	}
+	if err := g.insert(forks[1]); err != nil {
+		t.Fatalf("failed to replace head block: %v", err)
+	}
+	if have := g.chain.CurrentBlock().Hash(); have != forks[1].Hash() {
+		t.Fatalf("head block not replaced: have %x, want %x", have, forks[1].Hash())
+	}
+}
+
+// Tests that the node info reports the advertised version and the local head.
+func TestNodeInfo(t *testing.T) {
+	var (
+		g      = newTestGossip(t, Config{})
+		blocks = newTestBlocks(2)
+	)
+	for _, block := range blocks {
+		if err := g.insert(block); err != nil {
+			t.Fatalf("failed to insert block %d: %v", block.NumberU64(), err)
+		}
+	}
+	info, ok := g.Protocols()[0].NodeInfo().(*NodeInfo)
+	if !ok {
+		t.Fatalf("node info type mismatch: have %T", g.Protocols()[0].NodeInfo())
+	}
+	want := &NodeInfo{Version: PRECONF1, Head: blocks[1].Hash(), Number: 2}
+	if *info != *want {
+		t.Fatalf("node info mismatch: have %+v, want %+v", info, want)
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package preconf
+
+import (
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var (
+	// acceptedMeter counts the preconfirmation blocks received from the network
+	// which passed validation and were inserted into the local chain.
+	acceptedMeter = metrics.NewRegisteredMeter("eth/protocols/preconf/accepted", nil)
+
+	// duplicateMeter counts the preconfirmation blocks dropped because they were
+	// already seen, either from another peer or from the local driver.
+	duplicateMeter = metrics.NewRegisteredMeter("eth/protocols/preconf/duplicate", nil)
+
+	// rateLimitedMeter counts the preconfirmation blocks dropped because the
+	// sending peer exceeded its allowed message rate.
+	rateLimitedMeter = metrics.NewRegisteredMeter("eth/protocols/preconf/ratelimited", nil)
+
+	// unauthorizedMeter counts the preconfirmation blocks rejected because of a
+	// missing, malformed or non-whitelisted sequencer signature.
+	unauthorizedMeter = metrics.NewRegisteredMeter("eth/protocols/preconf/unauthorized", nil)
+
+	// insertFailureMeter counts the preconfirmation blocks which were correctly
+	// signed, but could not be inserted into the local chain.
+	insertFailureMeter = metrics.NewRegisteredMeter("eth/protocols/preconf/insert/failure", nil)
+)
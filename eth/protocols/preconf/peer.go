@@ -0,0 +1,146 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package preconf
+
+import (
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// maxKnownBlocks is the maximum block hashes to keep in the known list
+	// before starting to randomly evict them.
+	maxKnownBlocks = 1024
+
+	// maxQueuedBlocks is the maximum number of preconfirmation blocks to queue
+	// up before dropping broadcasts. Preconfirmation blocks are produced in
+	// quick succession, so a slow peer would rather miss a few than stall.
+	maxQueuedBlocks = 16
+)
+
+// Peer is a collection of relevant information we have about a `preconf` peer.
+type Peer struct {
+	id string // Unique ID for the peer, cached
+
+	*p2p.Peer                   // The embedded P2P package peer
+	rw        p2p.MsgReadWriter // Input/output streams for preconf
+	version   uint              // Protocol version negotiated
+
+	knownBlocks  mapset.Set[common.Hash]     // Set of block hashes known to be known by this peer
+	queuedBlocks chan *NewPreconfBlockPacket // Queue of blocks to broadcast to the peer
+	limiter      *rate.Limiter               // Rate limiter for inbound preconfirmation blocks
+
+	logger log.Logger    // Contextual logger with the peer id injected
+	term   chan struct{} // Termination channel to stop the broadcaster
+}
+
+// NewPeer creates a wrapper for a network connection and negotiated  protocol
+// version.
+func NewPeer(version uint, p *p2p.Peer, rw p2p.MsgReadWriter) *Peer {
+	id := p.ID().String()
+	peer := &Peer{
+		id:           id,
+		Peer:         p,
+		rw:           rw,
+		version:      version,
+		knownBlocks:  mapset.NewSet[common.Hash](),
+		queuedBlocks: make(chan *NewPreconfBlockPacket, maxQueuedBlocks),
+		logger:       log.New("peer", id[:8]),
+		term:         make(chan struct{}),
+	}
+	// Start up the broadcaster goroutine
+	go peer.broadcastBlocks()
+
+	return peer
+}
+
+// Close signals the broadcast goroutine to terminate. Only ever call this if
+// you created the peer yourself via NewPeer. Otherwise let whoever created it
+// clean it up!
+func (p *Peer) Close() {
+	close(p.term)
+}
+
+// ID retrieves the peer's unique identifier.
+func (p *Peer) ID() string {
+	return p.id
+}
+
+// Version retrieves the peer's negotiated `preconf` protocol version.
+func (p *Peer) Version() uint {
+	return p.version
+}
+
+// Log overrides the P2P logger with the higher level one containing only the id.
+func (p *Peer) Log() log.Logger {
+	return p.logger
+}
+
+// KnownBlock returns whether peer is known to already have a block.
+func (p *Peer) KnownBlock(hash common.Hash) bool {
+	return p.knownBlocks.Contains(hash)
+}
+
+// markBlock marks a block as known for the peer, ensuring that the block will
+// never be propagated to this particular peer.
+func (p *Peer) markBlock(hash common.Hash) {
+	for p.knownBlocks.Cardinality() >= maxKnownBlocks {
+		p.knownBlocks.Pop()
+	}
+	p.knownBlocks.Add(hash)
+}
+
+// SendPreconfBlock propagates an entire signed preconfirmation block to a remote
+// peer, marking it as known.
+func (p *Peer) SendPreconfBlock(packet *NewPreconfBlockPacket) error {
+	p.markBlock(packet.Block.Hash())
+	return p2p.Send(p.rw, NewPreconfBlockMsg, packet)
+}
+
+// AsyncSendPreconfBlock queues an entire signed preconfirmation block for
+// propagation to a remote peer. If the peer's broadcast queue is full, the
+// event is silently dropped.
+func (p *Peer) AsyncSendPreconfBlock(packet *NewPreconfBlockPacket) {
+	select {
+	case p.queuedBlocks <- packet:
+		// Mark the block hash as known, but ensure we don't overflow our limits
+		p.markBlock(packet.Block.Hash())
+	default:
+		p.Log().Debug("Dropping preconf block propagation", "number", packet.Block.NumberU64(), "hash", packet.Block.Hash())
+	}
+}
+
+// broadcastBlocks is a write loop that multiplexes blocks to the remote peer.
+// The goal is to have an async writer that does not lock up node internals and
+// at the same time rate limits queued data.
+func (p *Peer) broadcastBlocks() {
+	for {
+		select {
+		case packet := <-p.queuedBlocks:
+			if err := p.SendPreconfBlock(packet); err != nil {
+				return
+			}
+			p.Log().Trace("Propagated preconf block", "number", packet.Block.Number(), "hash", packet.Block.Hash())
+
+		case <-p.term:
+			return
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package preconf
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Constants to match up protocol versions and messages
+const (
+	PRECONF1 = 1
+)
+
+// ProtocolName is the official short name of the `preconf` protocol used during
+// devp2p capability negotiation.
+const ProtocolName = "preconf"
+
+// ProtocolVersions are the supported versions of the `preconf` protocol (first
+// is primary).
+var ProtocolVersions = []uint{PRECONF1}
+
+// protocolLengths are the number of implemented message corresponding to
+// different protocol versions.
+var protocolLengths = map[uint]uint64{PRECONF1: 1}
+
+// maxMessageSize is the maximum cap on the size of a protocol message.
+const maxMessageSize = 10 * 1024 * 1024
+
+const (
+	NewPreconfBlockMsg = 0x00
+)
+
+var (
+	errMsgTooLarge        = errors.New("message too long")
+	errDecode             = errors.New("invalid message")
+	errInvalidMsgCode     = errors.New("invalid message code")
+	errInvalidSignature   = errors.New("invalid preconfirmation signature")
+	errUnauthorizedSigner = errors.New("unauthorized preconfirmation signer")
+)
+
+// Packet represents a p2p message in the `preconf` protocol.
+type Packet interface {
+	Name() string // Name returns a string corresponding to the message type.
+	Kind() byte   // Kind returns the message type.
+}
+
+// NewPreconfBlockPacket is the network packet for propagating a preconfirmation
+// block, signed by one of the whitelisted preconfirmation sequencers.
+type NewPreconfBlockPacket struct {
+	Block     *types.Block // Preconfirmation block to propagate
+	Signature []byte       // Sequencer signature over the block's signing hash
+}
+
+// Signer recovers the address of the sequencer that signed the packet.
+func (p *NewPreconfBlockPacket) Signer(chainID *big.Int) (common.Address, error) {
+	if len(p.Signature) != crypto.SignatureLength {
+		return common.Address{}, errInvalidSignature
+	}
+	pubkey, err := crypto.SigToPub(SigningHash(chainID, p.Block.Hash()).Bytes(), p.Signature)
+	if err != nil {
+		return common.Address{}, errInvalidSignature
+	}
+	return crypto.PubkeyToAddress(*pubkey), nil
+}
+
+// SigningHash returns the digest a sequencer signs to authorize the gossiping of
+// a preconfirmation block. The chain ID is mixed in to prevent cross-chain replay.
+func SigningHash(chainID *big.Int, blockHash common.Hash) common.Hash {
+	return crypto.Keccak256Hash([]byte(ProtocolName), common.BigToHash(chainID).Bytes(), blockHash.Bytes())
+}
+
+func (*NewPreconfBlockPacket) Name() string { return "NewPreconfBlock" }
+func (*NewPreconfBlockPacket) Kind() byte   { return NewPreconfBlockMsg }
@@ -120,7 +120,7 @@ func (eth *Ethereum) hashState(ctx context.Context, block *types.Block, reexec u
 		if err != nil {
 			switch err.(type) {
 			case *trie.MissingNodeError:
-				return nil, nil, fmt.Errorf("required historical state unavailable (reexec=%d)", reexec)
+				return nil, nil, fmt.Errorf("required %w (reexec=%d)", tracers.ErrStateUnavailable, reexec) // CHANGE(taiko): typed error
 			default:
 				return nil, nil, err
 			}
@@ -182,10 +182,13 @@ func (eth *Ethereum) pathState(block *types.Block) (*state.StateDB, func(), erro
 	if err == nil {
 		return statedb, noopReleaser, nil
 	}
-	// TODO historic state is not supported in path-based scheme.
-	// Fully archive node in pbss will be implemented by relying
-	// on state history, but needs more work on top.
-	return nil, nil, errors.New("historical state not available in path scheme yet")
+	// CHANGE(taiko): serve the historical state from the indexed state
+	// histories if available.
+	statedb, err = eth.blockchain.HistoricState(block.Root())
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w at block #%d: %w", tracers.ErrStateUnavailable, block.NumberU64(), err)
+	}
+	return statedb, noopReleaser, nil
 }
 
 // stateAtBlock retrieves the state database associated with a certain block.
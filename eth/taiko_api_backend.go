@@ -1,10 +1,12 @@
 package eth
 
 import (
+	"errors"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/log"
@@ -87,6 +89,19 @@ func (a *TaikoAuthAPIBackend) UpdateL1Origin(l1Origin *rawdb.L1Origin) *rawdb.L1
 	return l1Origin
 }
 
+// GossipPreconfBlock propagates an already imported preconfirmation block, signed
+// by a whitelisted sequencer, to the peers connected on the `preconf` protocol.
+func (a *TaikoAuthAPIBackend) GossipPreconfBlock(hash common.Hash, signature hexutil.Bytes) error {
+	if a.eth.preconf == nil {
+		return errors.New("preconfirmation gossip is disabled")
+	}
+	block := a.eth.BlockChain().GetBlockByHash(hash)
+	if block == nil {
+		return ethereum.NotFound
+	}
+	return a.eth.preconf.BroadcastBlock(block, signature)
+}
+
 // TxPoolContent retrieves the transaction pool content with the given upper limits.
 func (a *TaikoAuthAPIBackend) TxPoolContent(
 	beneficiary common.Address,
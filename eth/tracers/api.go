@@ -70,6 +70,10 @@ const (
 
 var errTxNotFound = errors.New("transaction not found")
 
+// CHANGE(taiko): ErrStateUnavailable is returned by the backends if the state of
+// a block is neither retained nor regenerable, e.g. pruned.
+var ErrStateUnavailable = errors.New("historical state unavailable")
+
 // StateReleaseFunc is used to deallocate resources held by constructing a
 // historical state for tracing purposes.
 type StateReleaseFunc func()
@@ -0,0 +1,597 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package live
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	// Force-load the native tracers, to trigger registration
+	_ "github.com/ethereum/go-ethereum/eth/tracers/native"
+)
+
+func init() {
+	tracers.LiveDirectory.Register("sink", newSink)
+}
+
+const (
+	// sinkIndexFile is the name of the index of the block records in the segments.
+	sinkIndexFile = "index.bin"
+
+	// sinkIndexEntrySize is the size of an index entry: block number, block hash,
+	// segment and offset of the block record.
+	sinkIndexEntrySize = 8 + common.HashLength + 8 + 8
+
+	// sinkMaxRead is the maximum number of records returned by a single read.
+	sinkMaxRead = 1024
+)
+
+var (
+	errSinkDisabled = errors.New("live trace sink not enabled")
+	errCursorPruned = errors.New("cursor points to a pruned segment")
+)
+
+// activeSink is the running sink served over RPC, nil if the sink is not in use.
+var activeSink atomic.Pointer[sink]
+
+// sinkTracerConfig is the configuration of the sink live tracer.
+type sinkTracerConfig struct {
+	Path         string          `json:"path"`         // Path to the directory where the segments and the index are stored
+	Tracer       string          `json:"tracer"`       // Name of the tracer run on every transaction, defaults to callTracer
+	TracerConfig json.RawMessage `json:"tracerConfig"` // Config of the tracer run on every transaction
+	MaxSize      int             `json:"maxSize"`      // Maximum size in megabytes of a segment before it gets rotated, defaults to 100 megabytes
+	MaxSegments  int             `json:"maxSegments"`  // Maximum number of segments retained, zero to retain all
+}
+
+// sinkRecord is a single line of a segment: either the traces of an imported
+// block, or the revert of a previously written block dropped by a reorg.
+type sinkRecord struct {
+	Type       string          `json:"type"` // "block" or "revert"
+	Number     hexutil.Uint64  `json:"number"`
+	Hash       common.Hash     `json:"hash"`
+	ParentHash *common.Hash    `json:"parentHash,omitempty"`
+	Traces     []*sinkTxResult `json:"traces,omitempty"`
+}
+
+// sinkTxResult is the trace of a single transaction of a block record.
+type sinkTxResult struct {
+	TxHash common.Hash     `json:"txHash"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// sinkIndexEntry locates the record of a block in the segments.
+type sinkIndexEntry struct {
+	number  uint64
+	hash    common.Hash
+	segment uint64
+	offset  uint64
+}
+
+// Cursor is a position in the stream of records written by the sink.
+type Cursor struct {
+	Segment hexutil.Uint64 `json:"segment"`
+	Offset  hexutil.Uint64 `json:"offset"`
+}
+
+// sink is a live tracer running a native tracer on every transaction of the
+// imported blocks, appending the traces of each block as a JSON line to
+// rotating segment files. Blocks dropped by reorgs are reported by revert
+// records, so consumers following the stream end up with the canonical chain.
+type sink struct {
+	path         string
+	tracer       string
+	tracerConfig json.RawMessage
+	maxSize      uint64
+	maxSegments  uint64
+
+	// Block being traced, only accessed by the importing goroutine
+	block   *types.Block
+	txIndex int
+	txHash  common.Hash
+	current *tracers.Tracer
+	traces  []*sinkTxResult
+
+	lock    sync.Mutex       // Protects the fields below, shared with the RPC
+	index   []sinkIndexEntry // Canonical block records, ordered by number
+	first   uint64           // First retained segment
+	segment uint64           // Segment currently written
+	offset  uint64           // Size of the current segment
+	file    *os.File         // Current segment
+	indexer *os.File         // Index of block records
+}
+
+func newSink(cfg json.RawMessage) (*tracing.Hooks, error) {
+	var config sinkTracerConfig
+	if cfg != nil {
+		if err := json.Unmarshal(cfg, &config); err != nil {
+			return nil, fmt.Errorf("failed to parse config: %v", err)
+		}
+	}
+	if config.Path == "" {
+		return nil, errors.New("sink tracer output path is required")
+	}
+	if config.Tracer == "" {
+		config.Tracer = "callTracer"
+	}
+	if config.MaxSize <= 0 {
+		config.MaxSize = 100
+	}
+	// Instantiate the tracer once to validate it and to find the hooks it needs
+	probe, err := tracers.DefaultDirectory.New(config.Tracer, new(tracers.Context), config.TracerConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tracer %s: %v", config.Tracer, err)
+	}
+	s := &sink{
+		path:         config.Path,
+		tracer:       config.Tracer,
+		tracerConfig: config.TracerConfig,
+		maxSize:      uint64(config.MaxSize) * 1024 * 1024,
+		maxSegments:  uint64(max(config.MaxSegments, 0)),
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	activeSink.Store(s)
+
+	hooks := &tracing.Hooks{
+		OnBlockStart: s.OnBlockStart,
+		OnBlockEnd:   s.OnBlockEnd,
+		OnTxStart:    s.OnTxStart,
+		OnTxEnd:      s.OnTxEnd,
+		OnClose:      s.OnClose,
+	}
+	// Forward the execution hooks the tracer makes use of
+	if probe.OnEnter != nil {
+		hooks.OnEnter = func(depth int, typ byte, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+			if s.current != nil {
+				s.current.OnEnter(depth, typ, from, to, input, gas, value)
+			}
+		}
+	}
+	if probe.OnExit != nil {
+		hooks.OnExit = func(depth int, output []byte, gasUsed uint64, err error, reverted bool) {
+			if s.current != nil {
+				s.current.OnExit(depth, output, gasUsed, err, reverted)
+			}
+		}
+	}
+	if probe.OnOpcode != nil {
+		hooks.OnOpcode = func(pc uint64, op byte, gas, cost uint64, scope tracing.OpContext, rData []byte, depth int, err error) {
+			if s.current != nil {
+				s.current.OnOpcode(pc, op, gas, cost, scope, rData, depth, err)
+			}
+		}
+	}
+	if probe.OnFault != nil {
+		hooks.OnFault = func(pc uint64, op byte, gas, cost uint64, scope tracing.OpContext, depth int, err error) {
+			if s.current != nil {
+				s.current.OnFault(pc, op, gas, cost, scope, depth, err)
+			}
+		}
+	}
+	if probe.OnGasChange != nil {
+		hooks.OnGasChange = func(prev, post uint64, reason tracing.GasChangeReason) {
+			if s.current != nil {
+				s.current.OnGasChange(prev, post, reason)
+			}
+		}
+	}
+	if probe.OnBalanceChange != nil {
+		hooks.OnBalanceChange = func(addr common.Address, prev, post *big.Int, reason tracing.BalanceChangeReason) {
+			if s.current != nil {
+				s.current.OnBalanceChange(addr, prev, post, reason)
+			}
+		}
+	}
+	if probe.OnNonceChange != nil {
+		hooks.OnNonceChange = func(addr common.Address, prev, post uint64) {
+			if s.current != nil {
+				s.current.OnNonceChange(addr, prev, post)
+			}
+		}
+	}
+	if probe.OnCodeChange != nil {
+		hooks.OnCodeChange = func(addr common.Address, prevCodeHash common.Hash, prevCode []byte, codeHash common.Hash, code []byte) {
+			if s.current != nil {
+				s.current.OnCodeChange(addr, prevCodeHash, prevCode, codeHash, code)
+			}
+		}
+	}
+	if probe.OnStorageChange != nil {
+		hooks.OnStorageChange = func(addr common.Address, slot common.Hash, prev, post common.Hash) {
+			if s.current != nil {
+				s.current.OnStorageChange(addr, slot, prev, post)
+			}
+		}
+	}
+	if probe.OnLog != nil {
+		hooks.OnLog = func(l *types.Log) {
+			if s.current != nil {
+				s.current.OnLog(l)
+			}
+		}
+	}
+	return hooks, nil
+}
+
+// segmentPath returns the path of the segment with the given sequence number.
+func (s *sink) segmentPath(segment uint64) string {
+	return filepath.Join(s.path, fmt.Sprintf("traces-%06d.jsonl", segment))
+}
+
+// open loads the index and opens the last segment for appending, dropping any
+// index entries pointing to pruned or incompletely written records.
+func (s *sink) open() error {
+	if err := os.MkdirAll(s.path, 0755); err != nil {
+		return err
+	}
+	segments, err := filepath.Glob(filepath.Join(s.path, "traces-*.jsonl"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(segments)
+	if len(segments) > 0 {
+		if _, err := fmt.Sscanf(filepath.Base(segments[0]), "traces-%06d.jsonl", &s.first); err != nil {
+			return fmt.Errorf("invalid segment %s: %v", segments[0], err)
+		}
+		if _, err := fmt.Sscanf(filepath.Base(segments[len(segments)-1]), "traces-%06d.jsonl", &s.segment); err != nil {
+			return fmt.Errorf("invalid segment %s: %v", segments[len(segments)-1], err)
+		}
+	}
+	s.file, err = os.OpenFile(s.segmentPath(s.segment), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	stat, err := s.file.Stat()
+	if err != nil {
+		return err
+	}
+	s.offset = uint64(stat.Size())
+
+	blob, err := os.ReadFile(filepath.Join(s.path, sinkIndexFile))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for len(blob) >= sinkIndexEntrySize {
+		entry := sinkIndexEntry{
+			number:  binary.BigEndian.Uint64(blob),
+			hash:    common.BytesToHash(blob[8 : 8+common.HashLength]),
+			segment: binary.BigEndian.Uint64(blob[8+common.HashLength:]),
+			offset:  binary.BigEndian.Uint64(blob[16+common.HashLength:]),
+		}
+		blob = blob[sinkIndexEntrySize:]
+
+		if entry.segment < s.first || entry.segment > s.segment || (entry.segment == s.segment && entry.offset >= s.offset) {
+			continue
+		}
+		s.truncateIndex(entry.number)
+		s.index = append(s.index, entry)
+	}
+	// Rewrite the index to drop the stale entries
+	return s.writeIndex()
+}
+
+// truncateIndex drops the index entries of blocks at or above the number.
+func (s *sink) truncateIndex(number uint64) []sinkIndexEntry {
+	n := sort.Search(len(s.index), func(i int) bool { return s.index[i].number >= number })
+	dropped := s.index[n:]
+	s.index = s.index[:n]
+	return dropped
+}
+
+// writeIndex rewrites the index file from the in-memory index.
+func (s *sink) writeIndex() error {
+	if s.indexer != nil {
+		s.indexer.Close()
+	}
+	blob := make([]byte, 0, len(s.index)*sinkIndexEntrySize)
+	for _, entry := range s.index {
+		blob = appendIndexEntry(blob, entry)
+	}
+	path := filepath.Join(s.path, sinkIndexFile)
+	if err := os.WriteFile(path+".new", blob, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".new", path); err != nil {
+		return err
+	}
+	var err error
+	s.indexer, err = os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	return err
+}
+
+func appendIndexEntry(blob []byte, entry sinkIndexEntry) []byte {
+	blob = binary.BigEndian.AppendUint64(blob, entry.number)
+	blob = append(blob, entry.hash.Bytes()...)
+	blob = binary.BigEndian.AppendUint64(blob, entry.segment)
+	return binary.BigEndian.AppendUint64(blob, entry.offset)
+}
+
+func (s *sink) OnBlockStart(ev tracing.BlockEvent) {
+	s.block = ev.Block
+	s.txIndex = 0
+	s.traces = make([]*sinkTxResult, 0, len(ev.Block.Transactions()))
+}
+
+func (s *sink) OnTxStart(env *tracing.VMContext, tx *types.Transaction, from common.Address) {
+	if s.block == nil {
+		return
+	}
+	ctx := &tracers.Context{
+		BlockHash:   s.block.Hash(),
+		BlockNumber: s.block.Number(),
+		TxIndex:     s.txIndex,
+		TxHash:      tx.Hash(),
+	}
+	s.txIndex++
+
+	tracer, err := tracers.DefaultDirectory.New(s.tracer, ctx, s.tracerConfig)
+	if err != nil {
+		s.traces = append(s.traces, &sinkTxResult{TxHash: tx.Hash(), Error: err.Error()})
+		return
+	}
+	s.txHash = tx.Hash()
+	s.current = tracer
+	if tracer.OnTxStart != nil {
+		tracer.OnTxStart(env, tx, from)
+	}
+}
+
+func (s *sink) OnTxEnd(receipt *types.Receipt, err error) {
+	if s.current == nil {
+		return
+	}
+	tracer := s.current
+	s.current = nil
+
+	if tracer.OnTxEnd != nil {
+		tracer.OnTxEnd(receipt, err)
+	}
+	res := &sinkTxResult{TxHash: s.txHash}
+	if result, err := tracer.GetResult(); err != nil {
+		res.Error = err.Error()
+	} else {
+		res.Result = result
+	}
+	s.traces = append(s.traces, res)
+}
+
+// OnBlockEnd writes the traces of a successfully imported block, preceded by
+// the revert records of the blocks it replaces.
+func (s *sink) OnBlockEnd(err error) {
+	block := s.block
+	s.block, s.current = nil, nil
+	if block == nil || err != nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	reverted := slices.Clone(s.truncateIndex(block.NumberU64()))
+	slices.Reverse(reverted)
+	for _, entry := range reverted {
+		if _, _, err := s.write(&sinkRecord{Type: "revert", Number: hexutil.Uint64(entry.number), Hash: entry.hash}); err != nil {
+			log.Warn("Failed to write trace sink record", "err", err)
+			return
+		}
+	}
+	parent := block.ParentHash()
+	record := &sinkRecord{
+		Type:       "block",
+		Number:     hexutil.Uint64(block.NumberU64()),
+		Hash:       block.Hash(),
+		ParentHash: &parent,
+		Traces:     s.traces,
+	}
+	segment, offset, err := s.write(record)
+	if err != nil {
+		log.Warn("Failed to write trace sink record", "number", block.NumberU64(), "hash", block.Hash(), "err", err)
+		return
+	}
+	entry := sinkIndexEntry{number: block.NumberU64(), hash: block.Hash(), segment: segment, offset: offset}
+	if _, err := s.indexer.Write(appendIndexEntry(nil, entry)); err != nil {
+		log.Warn("Failed to write trace sink index", "number", block.NumberU64(), "hash", block.Hash(), "err", err)
+	}
+	s.index = append(s.index, entry)
+}
+
+// write appends a record to the current segment, rotating it first if full.
+// The segment and offset the record was written at are returned.
+func (s *sink) write(record *sinkRecord) (uint64, uint64, error) {
+	if s.offset >= s.maxSize {
+		if err := s.rotate(); err != nil {
+			return 0, 0, err
+		}
+	}
+	blob, err := json.Marshal(record)
+	if err != nil {
+		return 0, 0, err
+	}
+	segment, offset := s.segment, s.offset
+	n, err := s.file.Write(append(blob, '\n'))
+	s.offset += uint64(n)
+	return segment, offset, err
+}
+
+// rotate starts a new segment, pruning the oldest ones beyond the retention.
+func (s *sink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(s.segmentPath(s.segment+1), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	s.file, s.segment, s.offset = file, s.segment+1, 0
+
+	if s.maxSegments == 0 || s.segment-s.first < s.maxSegments {
+		return nil
+	}
+	for ; s.segment-s.first >= s.maxSegments; s.first++ {
+		if err := os.Remove(s.segmentPath(s.first)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	n := sort.Search(len(s.index), func(i int) bool { return s.index[i].segment >= s.first })
+	s.index = append([]sinkIndexEntry(nil), s.index[n:]...)
+	return s.writeIndex()
+}
+
+func (s *sink) OnClose() {
+	activeSink.CompareAndSwap(s, nil)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.file.Close(); err != nil {
+		log.Warn("Failed to close trace sink segment", "err", err)
+	}
+	if err := s.indexer.Close(); err != nil {
+		log.Warn("Failed to close trace sink index", "err", err)
+	}
+}
+
+// cursor returns the position of the record of the canonical block with the
+// given number, or the end of the stream if the block was not written yet.
+func (s *sink) cursor(number uint64) (*Cursor, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	n := sort.Search(len(s.index), func(i int) bool { return s.index[i].number >= number })
+	if n == len(s.index) {
+		return &Cursor{Segment: hexutil.Uint64(s.segment), Offset: hexutil.Uint64(s.offset)}, nil
+	}
+	if n == 0 && s.index[0].number > number && s.first > 0 {
+		return nil, errCursorPruned
+	}
+	return &Cursor{Segment: hexutil.Uint64(s.index[n].segment), Offset: hexutil.Uint64(s.index[n].offset)}, nil
+}
+
+// read returns up to limit records from the cursor on, along with the cursor
+// of the record following them.
+func (s *sink) read(cursor Cursor, limit int) ([]json.RawMessage, *Cursor, error) {
+	s.lock.Lock()
+	first, segment, offset := s.first, s.segment, s.offset
+	s.lock.Unlock()
+
+	next := cursor
+	if uint64(next.Segment) < first {
+		return nil, nil, errCursorPruned
+	}
+	var records []json.RawMessage
+	for len(records) < limit && uint64(next.Segment) <= segment {
+		// Only read the records completely written at the time of the call
+		end := uint64(1<<63 - 1)
+		if uint64(next.Segment) == segment {
+			end = offset
+		}
+		if uint64(next.Offset) >= end {
+			break
+		}
+		file, err := os.Open(s.segmentPath(uint64(next.Segment)))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, nil, errCursorPruned
+			}
+			return nil, nil, err
+		}
+		reader := bufio.NewReader(io.NewSectionReader(file, int64(next.Offset), int64(end-uint64(next.Offset))))
+		for len(records) < limit {
+			line, err := reader.ReadBytes('\n')
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				file.Close()
+				return nil, nil, err
+			}
+			next.Offset += hexutil.Uint64(len(line))
+			records = append(records, json.RawMessage(line[:len(line)-1]))
+		}
+		file.Close()
+
+		if len(records) < limit && uint64(next.Segment) < segment {
+			next.Segment, next.Offset = next.Segment+1, 0
+			continue
+		}
+		break
+	}
+	return records, &next, nil
+}
+
+// SinkAPI exposes the records written by the sink live tracer, allowing the
+// consumers to resume following the stream from a block.
+type SinkAPI struct{}
+
+// SinkReadResult is a batch of records along with the cursor of the next one.
+type SinkReadResult struct {
+	Records []json.RawMessage `json:"records"`
+	Next    *Cursor           `json:"next"`
+}
+
+// Cursor returns the position of the record of the canonical block with the
+// given number, or the end of the stream if the block was not traced yet.
+func (api *SinkAPI) Cursor(number hexutil.Uint64) (*Cursor, error) {
+	s := activeSink.Load()
+	if s == nil {
+		return nil, errSinkDisabled
+	}
+	return s.cursor(uint64(number))
+}
+
+// Read returns up to limit records starting at the cursor.
+func (api *SinkAPI) Read(cursor Cursor, limit hexutil.Uint64) (*SinkReadResult, error) {
+	s := activeSink.Load()
+	if s == nil {
+		return nil, errSinkDisabled
+	}
+	records, next, err := s.read(cursor, int(min(uint64(limit), sinkMaxRead)))
+	if err != nil {
+		return nil, err
+	}
+	return &SinkReadResult{Records: records, Next: next}, nil
+}
+
+// APIs returns the RPC services of the live tracers.
+func APIs() []rpc.API {
+	return []rpc.API{
+		{
+			Namespace: "livetrace",
+			Service:   new(SinkAPI),
+		},
+	}
+}
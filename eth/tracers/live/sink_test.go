@@ -0,0 +1,138 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package live
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/stretchr/testify/require"
+)
+
+// importBlock feeds a block with a single value transfer through the hooks.
+func importBlock(hooks *tracing.Hooks, number int64, parent common.Hash, extra byte) *types.Block {
+	var (
+		from = common.HexToAddress("0x01")
+		to   = common.HexToAddress("0x02")
+		tx   = types.NewTransaction(uint64(number), to, big.NewInt(1), params.TxGas, big.NewInt(1), nil)
+	)
+	block := types.NewBlock(&types.Header{Number: big.NewInt(number), ParentHash: parent, Extra: []byte{extra}}, &types.Body{Transactions: []*types.Transaction{tx}}, nil, trie.NewStackTrie(nil))
+
+	hooks.OnBlockStart(tracing.BlockEvent{Block: block})
+	hooks.OnTxStart(&tracing.VMContext{ChainConfig: params.MainnetChainConfig}, tx, from)
+	hooks.OnEnter(0, byte(vm.CALL), from, to, nil, params.TxGas, big.NewInt(1))
+	hooks.OnExit(0, nil, params.TxGas, nil, false)
+	hooks.OnTxEnd(&types.Receipt{GasUsed: params.TxGas}, nil)
+	hooks.OnBlockEnd(nil)
+	return block
+}
+
+// readAll reads all records from the cursor on.
+func readAll(t *testing.T, api *SinkAPI, cursor *Cursor) []map[string]any {
+	var records []map[string]any
+	for {
+		res, err := api.Read(*cursor, 2)
+		require.NoError(t, err)
+		for _, blob := range res.Records {
+			var record map[string]any
+			require.NoError(t, json.Unmarshal(blob, &record))
+			records = append(records, record)
+		}
+		if len(res.Records) == 0 {
+			return records
+		}
+		cursor = res.Next
+	}
+}
+
+func TestSink(t *testing.T) {
+	dir := t.TempDir()
+	hooks, err := newSink(json.RawMessage(fmt.Sprintf(`{"path":%q,"tracer":"callTracer"}`, dir)))
+	require.NoError(t, err)
+
+	// Write a few blocks, rotating the segment after every record
+	activeSink.Load().maxSize = 1
+
+	b1 := importBlock(hooks, 1, common.Hash{}, 0)
+	b2 := importBlock(hooks, 2, b1.Hash(), 0)
+	b3 := importBlock(hooks, 3, b2.Hash(), 0)
+
+	// Reorg the last two blocks away
+	r2 := importBlock(hooks, 2, b1.Hash(), 1)
+
+	api := new(SinkAPI)
+	cursor, err := api.Cursor(1)
+	require.NoError(t, err)
+
+	records := readAll(t, api, cursor)
+	require.Len(t, records, 6)
+	for i, want := range []struct {
+		typ  string
+		hash common.Hash
+	}{
+		{"block", b1.Hash()}, {"block", b2.Hash()}, {"block", b3.Hash()},
+		{"revert", b3.Hash()}, {"revert", b2.Hash()}, {"block", r2.Hash()},
+	} {
+		require.Equal(t, want.typ, records[i]["type"], "record %d", i)
+		require.Equal(t, want.hash.Hex(), records[i]["hash"], "record %d", i)
+	}
+	traces := records[0]["traces"].([]any)
+	require.Len(t, traces, 1)
+	require.Equal(t, "CALL", traces[0].(map[string]any)["result"].(map[string]any)["type"])
+
+	// Resuming from a reorged block starts at its canonical replacement
+	cursor, err = api.Cursor(2)
+	require.NoError(t, err)
+	records = readAll(t, api, cursor)
+	require.Len(t, records, 1)
+	require.Equal(t, r2.Hash().Hex(), records[0]["hash"])
+
+	// Reopening the sink restores the canonical index
+	hooks.OnClose()
+	_, err = api.Cursor(1)
+	require.ErrorIs(t, err, errSinkDisabled)
+
+	hooks, err = newSink(json.RawMessage(fmt.Sprintf(`{"path":%q,"maxSegments":3}`, dir)))
+	require.NoError(t, err)
+	defer hooks.OnClose()
+
+	s := activeSink.Load()
+	require.Len(t, s.index, 2)
+	require.Equal(t, r2.Hash(), s.index[1].hash)
+
+	// Pruning old segments invalidates the cursors pointing to them
+	s.maxSize = 1
+	importBlock(hooks, 3, r2.Hash(), 1)
+	importBlock(hooks, 4, common.Hash{}, 1)
+
+	_, err = api.Read(Cursor{}, 1)
+	require.ErrorIs(t, err, errCursorPruned)
+	_, err = api.Cursor(1)
+	require.ErrorIs(t, err, errCursorPruned)
+
+	cursor, err = api.Cursor(3)
+	require.NoError(t, err)
+	require.Len(t, readAll(t, api, cursor), 2)
+}
@@ -0,0 +1,302 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package native
+
+import (
+	"encoding/json"
+	"math/big"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+)
+
+func init() {
+	tracers.DefaultDirectory.Register("transferTracer", newTransferTracer, false)
+}
+
+var (
+	// transferEventTopic is the topic of the ERC-20 and ERC-721 Transfer event.
+	transferEventTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+	// transferSingleEventTopic is the topic of the ERC-1155 TransferSingle event.
+	transferSingleEventTopic = crypto.Keccak256Hash([]byte("TransferSingle(address,address,address,uint256,uint256)"))
+
+	// transferBatchEventTopic is the topic of the ERC-1155 TransferBatch event.
+	transferBatchEventTopic = crypto.Keccak256Hash([]byte("TransferBatch(address,address,address,uint256[],uint256[])"))
+)
+
+// Kinds of value movements reported by the transfer tracer.
+const (
+	transferNative  = "native"  // Ether moved by a call, creation or self-destruct
+	transferFee     = "fee"     // Transaction fee credited to the coinbase or treasury
+	transferERC20   = "erc20"   // ERC-20 Transfer event
+	transferERC721  = "erc721"  // ERC-721 Transfer event
+	transferERC1155 = "erc1155" // ERC-1155 TransferSingle or TransferBatch event
+)
+
+// transfer is a single value movement of a transaction.
+type transfer struct {
+	Type     string          `json:"type"`
+	Token    *common.Address `json:"token,omitempty"`
+	Operator *common.Address `json:"operator,omitempty"`
+	From     common.Address  `json:"from"`
+	To       common.Address  `json:"to"`
+	Value    *hexutil.Big    `json:"value,omitempty"`
+	TokenID  *hexutil.Big    `json:"tokenId,omitempty"`
+	Reason   string          `json:"reason,omitempty"`
+	Depth    int             `json:"depth"`
+	Success  bool            `json:"success"`
+}
+
+// transferTracer reports the value movements of a transaction in a normalized
+// list: native Ether transfers of all calls, creations and self-destructs, the
+// fee credits to the coinbase and treasury, and the decoded ERC-20, ERC-721 and
+// ERC-1155 transfer events. Movements within reverted calls are reported as
+// unsuccessful.
+//
+// Example:
+//
+//	> debug.traceTransaction("0x...", {tracer: "transferTracer"})
+//	[
+//	  {type: "native", from: "0x...", to: "0x...", value: "0xde0b6b3a7640000", depth: 0, success: true},
+//	  {type: "erc20", token: "0x...", from: "0x...", to: "0x...", value: "0x64", depth: 1, success: true},
+//	  {type: "fee", from: "0x...", to: "0x...", value: "0x5208", reason: "tip", depth: 0, success: true}
+//	]
+type transferTracer struct {
+	transfers []*transfer
+	frames    []int          // Index of the first transfer of each active call frame
+	sender    common.Address // Sender of the transaction, paying the fees
+	interrupt atomic.Bool    // Atomic flag to signal execution interruption
+	reason    error          // Textual reason for the interruption
+}
+
+// newTransferTracer returns a native go tracer which reports the value
+// movements of a transaction.
+func newTransferTracer(ctx *tracers.Context, _ json.RawMessage) (*tracers.Tracer, error) {
+	t := &transferTracer{transfers: []*transfer{}}
+	return &tracers.Tracer{
+		Hooks: &tracing.Hooks{
+			OnTxStart:       t.OnTxStart,
+			OnEnter:         t.OnEnter,
+			OnExit:          t.OnExit,
+			OnLog:           t.OnLog,
+			OnBalanceChange: t.OnBalanceChange,
+		},
+		GetResult: t.GetResult,
+		Stop:      t.Stop,
+	}, nil
+}
+
+func (t *transferTracer) OnTxStart(env *tracing.VMContext, tx *types.Transaction, from common.Address) {
+	t.sender = from
+}
+
+// OnEnter records the Ether moved into a new scope. Delegate and static calls
+// move no value, while call-code transfers to the calling contract itself.
+func (t *transferTracer) OnEnter(depth int, typ byte, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	if t.interrupt.Load() {
+		return
+	}
+	t.frames = append(t.frames, len(t.transfers))
+
+	switch vm.OpCode(typ) {
+	case vm.CALL, vm.CREATE, vm.CREATE2, vm.SELFDESTRUCT:
+		if value != nil && value.Sign() > 0 {
+			t.transfers = append(t.transfers, &transfer{
+				Type:    transferNative,
+				From:    from,
+				To:      to,
+				Value:   (*hexutil.Big)(new(big.Int).Set(value)),
+				Depth:   depth,
+				Success: true,
+			})
+		}
+	}
+}
+
+// OnExit marks all movements of a reverted scope, including its nested scopes,
+// as unsuccessful.
+func (t *transferTracer) OnExit(depth int, output []byte, gasUsed uint64, err error, reverted bool) {
+	if t.interrupt.Load() || len(t.frames) == 0 {
+		return
+	}
+	start := t.frames[len(t.frames)-1]
+	t.frames = t.frames[:len(t.frames)-1]
+
+	if reverted {
+		for _, tr := range t.transfers[start:] {
+			tr.Success = false
+		}
+	}
+}
+
+// OnLog decodes the token transfer events.
+func (t *transferTracer) OnLog(log *types.Log) {
+	if t.interrupt.Load() || len(log.Topics) == 0 {
+		return
+	}
+	depth := max(len(t.frames)-1, 0)
+	token := log.Address
+
+	switch log.Topics[0] {
+	case transferEventTopic:
+		// ERC-20 indexes the parties only, ERC-721 the token id as well
+		switch {
+		case len(log.Topics) == 3 && len(log.Data) == 32:
+			t.transfers = append(t.transfers, &transfer{
+				Type:    transferERC20,
+				Token:   &token,
+				From:    common.BytesToAddress(log.Topics[1].Bytes()),
+				To:      common.BytesToAddress(log.Topics[2].Bytes()),
+				Value:   (*hexutil.Big)(new(big.Int).SetBytes(log.Data)),
+				Depth:   depth,
+				Success: true,
+			})
+		case len(log.Topics) == 4 && len(log.Data) == 0:
+			t.transfers = append(t.transfers, &transfer{
+				Type:    transferERC721,
+				Token:   &token,
+				From:    common.BytesToAddress(log.Topics[1].Bytes()),
+				To:      common.BytesToAddress(log.Topics[2].Bytes()),
+				TokenID: (*hexutil.Big)(log.Topics[3].Big()),
+				Depth:   depth,
+				Success: true,
+			})
+		}
+
+	case transferSingleEventTopic:
+		if len(log.Topics) != 4 || len(log.Data) != 64 {
+			return
+		}
+		t.appendERC1155(log, token, depth, [][2]*big.Int{{
+			new(big.Int).SetBytes(log.Data[:32]),
+			new(big.Int).SetBytes(log.Data[32:]),
+		}})
+
+	case transferBatchEventTopic:
+		if len(log.Topics) != 4 {
+			return
+		}
+		ids, ok := decodeUint256Array(log.Data, 0)
+		if !ok {
+			return
+		}
+		values, ok := decodeUint256Array(log.Data, 32)
+		if !ok || len(ids) != len(values) {
+			return
+		}
+		pairs := make([][2]*big.Int, len(ids))
+		for i := range ids {
+			pairs[i] = [2]*big.Int{ids[i], values[i]}
+		}
+		t.appendERC1155(log, token, depth, pairs)
+	}
+}
+
+// appendERC1155 records the (id, value) pairs of an ERC-1155 transfer event.
+func (t *transferTracer) appendERC1155(log *types.Log, token common.Address, depth int, pairs [][2]*big.Int) {
+	operator := common.BytesToAddress(log.Topics[1].Bytes())
+	for _, pair := range pairs {
+		t.transfers = append(t.transfers, &transfer{
+			Type:     transferERC1155,
+			Token:    &token,
+			Operator: &operator,
+			From:     common.BytesToAddress(log.Topics[2].Bytes()),
+			To:       common.BytesToAddress(log.Topics[3].Bytes()),
+			TokenID:  (*hexutil.Big)(pair[0]),
+			Value:    (*hexutil.Big)(pair[1]),
+			Depth:    depth,
+			Success:  true,
+		})
+	}
+}
+
+// OnBalanceChange records the transaction fees credited to the coinbase and,
+// with Taiko, the base fee credited to the treasury.
+func (t *transferTracer) OnBalanceChange(addr common.Address, prev, post *big.Int, reason tracing.BalanceChangeReason) {
+	if t.interrupt.Load() {
+		return
+	}
+	var kind string
+	switch reason {
+	case tracing.BalanceIncreaseRewardTransactionFee:
+		kind = "tip"
+	case tracing.BalanceIncreaseTreasury:
+		kind = "treasury"
+	case tracing.BalanceIncreaseBaseFeeSharing:
+		kind = "baseFeeSharing"
+	default:
+		return
+	}
+	value := new(big.Int).Sub(post, prev)
+	if value.Sign() <= 0 {
+		return
+	}
+	t.transfers = append(t.transfers, &transfer{
+		Type:    transferFee,
+		From:    t.sender,
+		To:      addr,
+		Value:   (*hexutil.Big)(value),
+		Reason:  kind,
+		Success: true,
+	})
+}
+
+// GetResult returns the json-encoded list of value movements, and any error
+// arising from the encoding or forceful termination (via `Stop`).
+func (t *transferTracer) GetResult() (json.RawMessage, error) {
+	res, err := json.Marshal(t.transfers)
+	if err != nil {
+		return nil, err
+	}
+	return res, t.reason
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (t *transferTracer) Stop(err error) {
+	t.reason = err
+	t.interrupt.Store(true)
+}
+
+// decodeUint256Array decodes an ABI encoded uint256[] whose offset is stored
+// in the head slot at the given position of the data.
+func decodeUint256Array(data []byte, head int) ([]*big.Int, bool) {
+	if len(data) < head+32 {
+		return nil, false
+	}
+	offset := new(big.Int).SetBytes(data[head : head+32])
+	if !offset.IsUint64() || offset.Uint64() > uint64(len(data))-32 {
+		return nil, false
+	}
+	start := int(offset.Uint64())
+	length := new(big.Int).SetBytes(data[start : start+32])
+	if !length.IsUint64() || length.Uint64() > uint64(len(data)-start-32)/32 {
+		return nil, false
+	}
+	items := make([]*big.Int, length.Uint64())
+	for i := range items {
+		pos := start + 32 + 32*i
+		items[i] = new(big.Int).SetBytes(data[pos : pos+32])
+	}
+	return items, true
+}
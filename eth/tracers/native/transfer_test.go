@@ -0,0 +1,99 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package native_test
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferTracer(t *testing.T) {
+	tracer, err := tracers.DefaultDirectory.New("transferTracer", &tracers.Context{}, nil)
+	require.NoError(t, err)
+
+	var (
+		sender   = common.HexToAddress("0x01")
+		contract = common.HexToAddress("0x02")
+		token    = common.HexToAddress("0x03")
+		nested   = common.HexToAddress("0x04")
+		coinbase = common.HexToAddress("0x05")
+		treasury = common.HexToAddress("0x06")
+	)
+	topic := func(sig string) common.Hash { return crypto.Keccak256Hash([]byte(sig)) }
+	word := func(n int64) []byte { return common.BigToHash(big.NewInt(n)).Bytes() }
+
+	tx := types.NewTransaction(0, contract, big.NewInt(100), 0, big.NewInt(0), nil)
+	tracer.OnTxStart(&tracing.VMContext{ChainConfig: params.MainnetChainConfig}, tx, sender)
+
+	// Top-level call sending Ether, emitting an ERC-20 transfer
+	tracer.OnEnter(0, byte(vm.CALL), sender, contract, nil, 0, big.NewInt(100))
+	tracer.OnLog(&types.Log{
+		Address: token,
+		Topics:  []common.Hash{topic("Transfer(address,address,uint256)"), common.BytesToHash(contract.Bytes()), common.BytesToHash(sender.Bytes())},
+		Data:    word(7),
+	})
+	// Reverted nested call sending Ether and emitting an ERC-721 transfer
+	tracer.OnEnter(1, byte(vm.CALL), contract, nested, nil, 0, big.NewInt(10))
+	tracer.OnLog(&types.Log{
+		Address: token,
+		Topics:  []common.Hash{topic("Transfer(address,address,uint256)"), common.BytesToHash(nested.Bytes()), common.BytesToHash(sender.Bytes()), common.BigToHash(big.NewInt(42))},
+	})
+	tracer.OnExit(1, nil, 0, vm.ErrExecutionReverted, true)
+
+	// Delegate calls move no value, ERC-1155 batches are split per token id
+	tracer.OnEnter(1, byte(vm.DELEGATECALL), contract, nested, nil, 0, big.NewInt(100))
+	tracer.OnLog(&types.Log{
+		Address: token,
+		Topics:  []common.Hash{topic("TransferBatch(address,address,address,uint256[],uint256[])"), common.BytesToHash(contract.Bytes()), common.BytesToHash(contract.Bytes()), common.BytesToHash(sender.Bytes())},
+		Data:    append(append(append(append(append(append(word(64), word(160)...), word(2)...), append(word(1), word(2)...)...), word(2)...), word(5)...), word(6)...),
+	})
+	tracer.OnExit(1, nil, 0, nil, false)
+	tracer.OnExit(0, nil, 0, nil, false)
+
+	// Fee credits after execution
+	tracer.OnBalanceChange(coinbase, big.NewInt(0), big.NewInt(3), tracing.BalanceIncreaseRewardTransactionFee)
+	tracer.OnBalanceChange(treasury, big.NewInt(1), big.NewInt(5), tracing.BalanceIncreaseTreasury)
+	tracer.OnBalanceChange(sender, big.NewInt(5), big.NewInt(9), tracing.BalanceIncreaseGasReturn)
+
+	res, err := tracer.GetResult()
+	require.NoError(t, err)
+
+	var have []map[string]any
+	require.NoError(t, json.Unmarshal(res, &have))
+
+	want := []map[string]any{
+		{"type": "native", "from": sender.Hex(), "to": contract.Hex(), "value": "0x64", "depth": 0.0, "success": true},
+		{"type": "erc20", "token": token.Hex(), "from": contract.Hex(), "to": sender.Hex(), "value": "0x7", "depth": 0.0, "success": true},
+		{"type": "native", "from": contract.Hex(), "to": nested.Hex(), "value": "0xa", "depth": 1.0, "success": false},
+		{"type": "erc721", "token": token.Hex(), "from": nested.Hex(), "to": sender.Hex(), "tokenId": "0x2a", "depth": 1.0, "success": false},
+		{"type": "erc1155", "token": token.Hex(), "operator": contract.Hex(), "from": contract.Hex(), "to": sender.Hex(), "tokenId": "0x1", "value": "0x5", "depth": 1.0, "success": true},
+		{"type": "erc1155", "token": token.Hex(), "operator": contract.Hex(), "from": contract.Hex(), "to": sender.Hex(), "tokenId": "0x2", "value": "0x6", "depth": 1.0, "success": true},
+		{"type": "fee", "from": sender.Hex(), "to": coinbase.Hex(), "value": "0x3", "reason": "tip", "depth": 0.0, "success": true},
+		{"type": "fee", "from": sender.Hex(), "to": treasury.Hex(), "value": "0x4", "reason": "treasury", "depth": 0.0, "success": true},
+	}
+	require.Equal(t, want, have)
+}
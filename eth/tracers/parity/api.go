@@ -0,0 +1,464 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package parity implements the Parity/OpenEthereum compatible trace namespace
+// on top of the native flat call tracer.
+package parity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	// Force-load the native tracers to register the flat call and mux tracers.
+	_ "github.com/ethereum/go-ethereum/eth/tracers/native"
+)
+
+const (
+	// maxUnindexedBlocks is the maximum number of blocks trace_filter traces on
+	// the fly if they are not covered by the trace index.
+	maxUnindexedBlocks = 1024
+)
+
+var (
+	errVMTraceUnsupported = errors.New("vmTrace is not supported")
+	errTooManyBlocks      = fmt.Errorf("too many unindexed blocks to filter, max %d", maxUnindexedBlocks)
+)
+
+var (
+	flatCallTracer = "flatCallTracer"
+	muxTracer      = "muxTracer"
+
+	flatCallConfig = json.RawMessage(`{"convertParityErrors":true}`)
+	replayConfig   = json.RawMessage(`{"flatCallTracer":{"convertParityErrors":true},"stateDiffTracer":{}}`)
+)
+
+// Trace is a single call frame of a transaction in the Parity flat trace format.
+type Trace struct {
+	Action              json.RawMessage `json:"action"`
+	BlockHash           *common.Hash    `json:"blockHash"`
+	BlockNumber         uint64          `json:"blockNumber"`
+	Error               string          `json:"error,omitempty"`
+	Result              json.RawMessage `json:"result,omitempty"`
+	Subtraces           int             `json:"subtraces"`
+	TraceAddress        []int           `json:"traceAddress"`
+	TransactionHash     *common.Hash    `json:"transactionHash"`
+	TransactionPosition uint64          `json:"transactionPosition"`
+	Type                string          `json:"type"`
+}
+
+// addresses returns the sender and the recipient of the call frame. Contract
+// creations are addressed to the created contract and self-destructs to the
+// beneficiary of the remaining balance.
+func (t *Trace) addresses() (from *common.Address, to *common.Address) {
+	var action struct {
+		From          *common.Address `json:"from"`
+		To            *common.Address `json:"to"`
+		Address       *common.Address `json:"address"`
+		RefundAddress *common.Address `json:"refundAddress"`
+	}
+	if err := json.Unmarshal(t.Action, &action); err != nil {
+		return nil, nil
+	}
+	var result struct {
+		Address *common.Address `json:"address"`
+	}
+	if len(t.Result) > 0 {
+		json.Unmarshal(t.Result, &result)
+	}
+	from, to = action.From, action.To
+	if from == nil {
+		from = action.Address
+	}
+	if to == nil {
+		to = result.Address
+	}
+	if to == nil {
+		to = action.RefundAddress
+	}
+	return from, to
+}
+
+// TraceResults is the result of replaying a transaction with trace_replay*.
+type TraceResults struct {
+	Output          hexutil.Bytes   `json:"output"`
+	StateDiff       json.RawMessage `json:"stateDiff"`
+	Trace           []*Trace        `json:"trace"`
+	VmTrace         any             `json:"vmTrace"`
+	TransactionHash *common.Hash    `json:"transactionHash,omitempty"`
+}
+
+// FilterArgs are the criteria of trace_filter. Traces are matched if their
+// sender is in FromAddress and their recipient is in ToAddress, an empty list
+// matching any address.
+type FilterArgs struct {
+	FromBlock   *rpc.BlockNumber `json:"fromBlock"`
+	ToBlock     *rpc.BlockNumber `json:"toBlock"`
+	FromAddress []common.Address `json:"fromAddress"`
+	ToAddress   []common.Address `json:"toAddress"`
+	After       *uint64          `json:"after"`
+	Count       *uint64          `json:"count"`
+}
+
+// matches reports whether the trace satisfies the address criteria.
+func (args *FilterArgs) matches(t *Trace) bool {
+	from, to := t.addresses()
+	if len(args.FromAddress) > 0 && (from == nil || !slices.Contains(args.FromAddress, *from)) {
+		return false
+	}
+	if len(args.ToAddress) > 0 && (to == nil || !slices.Contains(args.ToAddress, *to)) {
+		return false
+	}
+	return true
+}
+
+// API is the collection of Parity compatible tracing APIs exposed over the
+// trace namespace.
+type API struct {
+	backend tracers.Backend
+	tracer  *tracers.API
+	indexer *Indexer // Optional trace index, nil if disabled
+}
+
+// NewAPI creates a new API definition for the trace namespace. The indexer may
+// be nil, in which case every trace is produced by re-executing its block.
+func NewAPI(backend tracers.Backend, indexer *Indexer) *API {
+	return &API{backend: backend, tracer: tracers.NewAPI(backend), indexer: indexer}
+}
+
+// APIs return the collection of RPC services the parity tracer package offers.
+func APIs(backend tracers.Backend, indexer *Indexer) []rpc.API {
+	return []rpc.API{
+		{
+			Namespace: "trace",
+			Service:   NewAPI(backend, indexer),
+		},
+	}
+}
+
+// Block returns the traces of all transactions in the given block.
+func (api *API) Block(ctx context.Context, number rpc.BlockNumber) ([]*Trace, error) {
+	header, err := api.backend.HeaderByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return nil, fmt.Errorf("block #%d not found", number)
+	}
+	return api.blockTraces(ctx, header.Number.Uint64(), header.Hash())
+}
+
+// Transaction returns the traces of the given transaction.
+func (api *API) Transaction(ctx context.Context, hash common.Hash) ([]*Trace, error) {
+	found, _, blockHash, blockNumber, index, err := api.backend.GetTransaction(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, nil
+	}
+	if api.indexer != nil {
+		if traces, ok := api.indexer.blockTraces(blockNumber, blockHash); ok {
+			return slices.DeleteFunc(traces, func(t *Trace) bool {
+				return t.TransactionPosition != index
+			}), nil
+		}
+	}
+	res, err := api.tracer.TraceTransaction(ctx, hash, &tracers.TraceConfig{Tracer: &flatCallTracer, TracerConfig: flatCallConfig})
+	if err != nil {
+		return nil, err
+	}
+	return decodeTraces(res)
+}
+
+// Get returns the trace of the given transaction at the given trace address.
+func (api *API) Get(ctx context.Context, hash common.Hash, indices []hexutil.Uint64) (*Trace, error) {
+	traces, err := api.Transaction(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	for _, trace := range traces {
+		if len(trace.TraceAddress) != len(indices) {
+			continue
+		}
+		match := true
+		for i, index := range indices {
+			if uint64(trace.TraceAddress[i]) != uint64(index) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return trace, nil
+		}
+	}
+	return nil, nil
+}
+
+// Filter returns the traces of the given block range matching the filter
+// criteria. Blocks covered by the trace index are looked up by address, any
+// other block in the range is traced on the fly.
+func (api *API) Filter(ctx context.Context, args FilterArgs) ([]*Trace, error) {
+	latest, err := api.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	head := latest.Number.Uint64()
+	resolve := func(number *rpc.BlockNumber, fallback uint64) uint64 {
+		if number == nil || *number < 0 {
+			return fallback
+		}
+		return min(uint64(*number), head)
+	}
+	from, to := resolve(args.FromBlock, 0), resolve(args.ToBlock, head)
+	if from > to {
+		return nil, fmt.Errorf("invalid block range %d..%d", from, to)
+	}
+	// Collect the blocks to search, narrowing the indexed ones down by address
+	var (
+		numbers   []uint64
+		unindexed uint64
+	)
+	var (
+		tail, last uint64
+		ok         bool
+	)
+	if api.indexer != nil {
+		tail, last, ok = rawdb.ReadTraceIndexRange(api.backend.ChainDb())
+	}
+	if !ok || to < tail || from > last {
+		tail, last = to+1, to
+	}
+	tail, last = max(tail, from), min(last, to)
+
+	for n := from; n <= to && n < tail; n++ {
+		numbers, unindexed = append(numbers, n), unindexed+1
+	}
+	if tail <= last {
+		if len(args.FromAddress)+len(args.ToAddress) == 0 {
+			for n := tail; n <= last; n++ {
+				numbers = append(numbers, n)
+			}
+		} else {
+			for _, addr := range append(slices.Clone(args.FromAddress), args.ToAddress...) {
+				numbers = append(numbers, rawdb.ReadTraceAddressIndex(api.backend.ChainDb(), addr, tail, last)...)
+			}
+		}
+	}
+	for n := max(last+1, tail); n <= to; n++ {
+		numbers, unindexed = append(numbers, n), unindexed+1
+	}
+	if unindexed > maxUnindexedBlocks {
+		return nil, errTooManyBlocks
+	}
+	slices.Sort(numbers)
+	numbers = slices.Compact(numbers)
+
+	// Trace the blocks in order, skipping and limiting the matches as requested
+	var (
+		results []*Trace
+		skip    uint64
+	)
+	if args.After != nil {
+		skip = *args.After
+	}
+	for _, number := range numbers {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		traces, err := api.Block(ctx, rpc.BlockNumber(number))
+		if err != nil {
+			return nil, err
+		}
+		for _, trace := range traces {
+			if !args.matches(trace) {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			results = append(results, trace)
+			if args.Count != nil && uint64(len(results)) >= *args.Count {
+				return results, nil
+			}
+		}
+	}
+	return results, nil
+}
+
+// ReplayBlockTransactions replays all transactions of the given block, returning
+// the requested trace types ("trace" and/or "stateDiff") of each.
+func (api *API) ReplayBlockTransactions(ctx context.Context, number rpc.BlockNumber, traceTypes []string) ([]*TraceResults, error) {
+	trace, stateDiff, err := parseTraceTypes(traceTypes)
+	if err != nil {
+		return nil, err
+	}
+	header, err := api.backend.HeaderByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return nil, fmt.Errorf("block #%d not found", number)
+	}
+	if header.Number.Sign() == 0 {
+		return []*TraceResults{}, nil
+	}
+	results, err := api.tracer.TraceBlockByHash(ctx, header.Hash(), &tracers.TraceConfig{Tracer: &muxTracer, TracerConfig: replayConfig})
+	if err != nil {
+		return nil, err
+	}
+	replays := make([]*TraceResults, 0, len(results))
+	for _, res := range results {
+		if res.Error != "" {
+			return nil, errors.New(res.Error)
+		}
+		replay, err := newTraceResults(res.Result, trace, stateDiff)
+		if err != nil {
+			return nil, err
+		}
+		replay.TransactionHash = &res.TxHash
+		replays = append(replays, replay)
+	}
+	return replays, nil
+}
+
+// ReplayTransaction replays the given transaction, returning the requested trace
+// types ("trace" and/or "stateDiff").
+func (api *API) ReplayTransaction(ctx context.Context, hash common.Hash, traceTypes []string) (*TraceResults, error) {
+	trace, stateDiff, err := parseTraceTypes(traceTypes)
+	if err != nil {
+		return nil, err
+	}
+	res, err := api.tracer.TraceTransaction(ctx, hash, &tracers.TraceConfig{Tracer: &muxTracer, TracerConfig: replayConfig})
+	if err != nil {
+		return nil, err
+	}
+	return newTraceResults(res, trace, stateDiff)
+}
+
+// blockTraces returns the traces of a block, reading them from the trace index
+// if available or tracing the block otherwise.
+func (api *API) blockTraces(ctx context.Context, number uint64, hash common.Hash) ([]*Trace, error) {
+	if api.indexer != nil {
+		if traces, ok := api.indexer.blockTraces(number, hash); ok {
+			return traces, nil
+		}
+	}
+	return traceBlock(ctx, api.tracer, number, hash)
+}
+
+// traceBlock traces all transactions of a block with the flat call tracer.
+func traceBlock(ctx context.Context, tracer *tracers.API, number uint64, hash common.Hash) ([]*Trace, error) {
+	// The genesis block has no transactions and cannot be traced
+	if number == 0 {
+		return []*Trace{}, nil
+	}
+	results, err := tracer.TraceBlockByHash(ctx, hash, &tracers.TraceConfig{Tracer: &flatCallTracer, TracerConfig: flatCallConfig})
+	if err != nil {
+		return nil, err
+	}
+	traces := []*Trace{}
+	for _, res := range results {
+		if res.Error != "" {
+			return nil, errors.New(res.Error)
+		}
+		txTraces, err := decodeTraces(res.Result)
+		if err != nil {
+			return nil, err
+		}
+		traces = append(traces, txTraces...)
+	}
+	return traces, nil
+}
+
+// decodeTraces decodes the result of the flat call tracer.
+func decodeTraces(result interface{}) ([]*Trace, error) {
+	blob, ok := result.(json.RawMessage)
+	if !ok {
+		return nil, fmt.Errorf("unexpected trace result type %T", result)
+	}
+	var traces []*Trace
+	if err := json.Unmarshal(blob, &traces); err != nil {
+		return nil, err
+	}
+	return traces, nil
+}
+
+// newTraceResults assembles the replay results of a transaction from the output
+// of the mux tracer, keeping only the requested trace types.
+func newTraceResults(result interface{}, trace, stateDiff bool) (*TraceResults, error) {
+	blob, ok := result.(json.RawMessage)
+	if !ok {
+		return nil, fmt.Errorf("unexpected trace result type %T", result)
+	}
+	var mux struct {
+		Traces    []*Trace        `json:"flatCallTracer"`
+		StateDiff json.RawMessage `json:"stateDiffTracer"`
+	}
+	if err := json.Unmarshal(blob, &mux); err != nil {
+		return nil, err
+	}
+	res := &TraceResults{Trace: []*Trace{}}
+	if len(mux.Traces) > 0 && len(mux.Traces[0].Result) > 0 {
+		var output struct {
+			Output hexutil.Bytes `json:"output"`
+			Code   hexutil.Bytes `json:"code"`
+		}
+		if err := json.Unmarshal(mux.Traces[0].Result, &output); err != nil {
+			return nil, err
+		}
+		res.Output = output.Output
+		if res.Output == nil {
+			res.Output = output.Code
+		}
+	}
+	if res.Output == nil {
+		res.Output = hexutil.Bytes{}
+	}
+	if trace {
+		res.Trace = mux.Traces
+	}
+	if stateDiff {
+		res.StateDiff = mux.StateDiff
+	}
+	return res, nil
+}
+
+// parseTraceTypes validates the requested trace types of a replay.
+func parseTraceTypes(traceTypes []string) (trace bool, stateDiff bool, err error) {
+	for _, kind := range traceTypes {
+		switch kind {
+		case "trace":
+			trace = true
+		case "stateDiff":
+			stateDiff = true
+		case "vmTrace":
+			return false, false, errVMTraceUnsupported
+		default:
+			return false, false, fmt.Errorf("unknown trace type %q", kind)
+		}
+	}
+	return trace, stateDiff, nil
+}
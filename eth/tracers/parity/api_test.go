@@ -0,0 +1,389 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package parity
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var errStateNotFound = errors.New("state not found")
+
+type testBackend struct {
+	chainConfig *params.ChainConfig
+	engine      consensus.Engine
+	chaindb     ethdb.Database
+	chain       *core.BlockChain
+
+	stateErr error // Error injected into the state retrievals, if set
+}
+
+func newTestBackend(t *testing.T, n int, gspec *core.Genesis, generator func(i int, b *core.BlockGen)) *testBackend {
+	backend := &testBackend{
+		chainConfig: gspec.Config,
+		engine:      ethash.NewFaker(),
+		chaindb:     rawdb.NewMemoryDatabase(),
+	}
+	_, blocks, _ := core.GenerateChainWithGenesis(gspec, backend.engine, n, generator)
+
+	cacheConfig := &core.CacheConfig{
+		TrieCleanLimit:    256,
+		TrieDirtyLimit:    256,
+		TrieTimeLimit:     5 * time.Minute,
+		SnapshotLimit:     0,
+		TrieDirtyDisabled: true, // Archive mode
+	}
+	chain, err := core.NewBlockChain(backend.chaindb, cacheConfig, gspec, nil, backend.engine, vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	backend.chain = chain
+	t.Cleanup(chain.Stop)
+	return backend
+}
+
+func (b *testBackend) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	return b.chain.GetHeaderByHash(hash), nil
+}
+
+func (b *testBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	if number == rpc.PendingBlockNumber || number == rpc.LatestBlockNumber {
+		return b.chain.CurrentHeader(), nil
+	}
+	return b.chain.GetHeaderByNumber(uint64(number)), nil
+}
+
+func (b *testBackend) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	return b.chain.GetBlockByHash(hash), nil
+}
+
+func (b *testBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	if number == rpc.PendingBlockNumber || number == rpc.LatestBlockNumber {
+		return b.chain.GetBlockByNumber(b.chain.CurrentBlock().Number.Uint64()), nil
+	}
+	return b.chain.GetBlockByNumber(uint64(number)), nil
+}
+
+func (b *testBackend) GetTransaction(ctx context.Context, txHash common.Hash) (bool, *types.Transaction, common.Hash, uint64, uint64, error) {
+	tx, hash, blockNumber, index := rawdb.ReadTransaction(b.chaindb, txHash)
+	return tx != nil, tx, hash, blockNumber, index, nil
+}
+
+func (b *testBackend) RPCGasCap() uint64                { return 25000000 }
+func (b *testBackend) ChainConfig() *params.ChainConfig { return b.chainConfig }
+func (b *testBackend) Engine() consensus.Engine         { return b.engine }
+func (b *testBackend) ChainDb() ethdb.Database          { return b.chaindb }
+
+func (b *testBackend) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
+	return b.chain.SubscribeChainHeadEvent(ch)
+}
+
+func (b *testBackend) StateAtBlock(ctx context.Context, block *types.Block, reexec uint64, base *state.StateDB, readOnly bool, preferDisk bool) (*state.StateDB, tracers.StateReleaseFunc, error) {
+	if b.stateErr != nil {
+		return nil, nil, b.stateErr
+	}
+	statedb, err := b.chain.StateAt(block.Root())
+	if err != nil {
+		return nil, nil, errStateNotFound
+	}
+	return statedb, func() {}, nil
+}
+
+func (b *testBackend) StateAtTransaction(ctx context.Context, block *types.Block, txIndex int, reexec uint64) (*types.Transaction, vm.BlockContext, *state.StateDB, tracers.StateReleaseFunc, error) {
+	parent := b.chain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	statedb, release, err := b.StateAtBlock(ctx, parent, reexec, nil, true, false)
+	if err != nil {
+		return nil, vm.BlockContext{}, nil, nil, err
+	}
+	signer := types.MakeSigner(b.chainConfig, block.Number(), block.Time())
+	for idx, tx := range block.Transactions() {
+		msg, _ := core.TransactionToMessage(tx, signer, block.BaseFee())
+		context := core.NewEVMBlockContext(block.Header(), b.chain, nil)
+		if idx == txIndex {
+			return tx, context, statedb, release, nil
+		}
+		vmenv := vm.NewEVM(context, core.NewEVMTxContext(msg), statedb, b.chainConfig, vm.Config{})
+		if _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(tx.Gas())); err != nil {
+			return nil, vm.BlockContext{}, nil, nil, fmt.Errorf("transaction %#x failed: %v", tx.Hash(), err)
+		}
+		statedb.Finalise(vmenv.ChainConfig().IsEIP158(block.Number()))
+	}
+	return nil, vm.BlockContext{}, nil, nil, fmt.Errorf("transaction index %d out of range for block %#x", txIndex, block.Hash())
+}
+
+// newTestChain creates a chain of three blocks: a transfer from the first
+// account to the second, a contract creation by the first account and a
+// transfer from the second account to the third.
+func newTestChain(t *testing.T) (*testBackend, []common.Address, []common.Hash) {
+	var (
+		keys  = make([]*ecdsa.PrivateKey, 3)
+		addrs = make([]common.Address, 3)
+		txs   []common.Hash
+	)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		addrs[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+	}
+	genesis := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc: types.GenesisAlloc{
+			addrs[0]: {Balance: big.NewInt(params.Ether)},
+			addrs[1]: {Balance: big.NewInt(params.Ether)},
+		},
+	}
+	signer := types.HomesteadSigner{}
+	backend := newTestBackend(t, 3, genesis, func(i int, b *core.BlockGen) {
+		var tx *types.Transaction
+		switch i {
+		case 0:
+			tx = types.NewTransaction(b.TxNonce(addrs[0]), addrs[1], big.NewInt(1000), params.TxGas, b.BaseFee(), nil)
+			tx, _ = types.SignTx(tx, signer, keys[0])
+		case 1:
+			// Deploy a contract storing 1 into slot 0 and returning empty code
+			code := common.FromHex("0x6001600055")
+			tx = types.NewContractCreation(b.TxNonce(addrs[0]), big.NewInt(0), 100000, b.BaseFee(), code)
+			tx, _ = types.SignTx(tx, signer, keys[0])
+		case 2:
+			tx = types.NewTransaction(b.TxNonce(addrs[1]), addrs[2], big.NewInt(2000), params.TxGas, b.BaseFee(), nil)
+			tx, _ = types.SignTx(tx, signer, keys[1])
+		}
+		b.AddTx(tx)
+		txs = append(txs, tx.Hash())
+	})
+	return backend, addrs, txs
+}
+
+// newTestIndexer creates a trace indexer and runs it to completion.
+func newTestIndexer(t *testing.T, backend *testBackend) *Indexer {
+	indexer := NewIndexer(backend)
+	for {
+		more, err := indexer.step()
+		if err != nil {
+			t.Fatalf("failed to index traces: %v", err)
+		}
+		if !more {
+			return indexer
+		}
+	}
+}
+
+func TestTraceBlockAndTransaction(t *testing.T) {
+	t.Parallel()
+
+	backend, addrs, txs := newTestChain(t)
+	for _, indexed := range []bool{false, true} {
+		var indexer *Indexer
+		if indexed {
+			indexer = newTestIndexer(t, backend)
+		}
+		api := NewAPI(backend, indexer)
+
+		traces, err := api.Block(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("indexed %v: failed to trace block: %v", indexed, err)
+		}
+		if len(traces) != 1 || traces[0].Type != "call" || *traces[0].TransactionHash != txs[0] {
+			t.Fatalf("indexed %v: unexpected block traces: %v", indexed, traces)
+		}
+		if from, to := traces[0].addresses(); *from != addrs[0] || *to != addrs[1] {
+			t.Errorf("indexed %v: addresses mismatch: have %v->%v, want %v->%v", indexed, from, to, addrs[0], addrs[1])
+		}
+		// The contract creation is addressed to the created contract
+		traces, err = api.Transaction(context.Background(), txs[1])
+		if err != nil {
+			t.Fatalf("indexed %v: failed to trace transaction: %v", indexed, err)
+		}
+		if len(traces) != 1 || traces[0].Type != "create" {
+			t.Fatalf("indexed %v: unexpected transaction traces: %v", indexed, traces)
+		}
+		if _, to := traces[0].addresses(); to == nil || *to != crypto.CreateAddress(addrs[0], 1) {
+			t.Errorf("indexed %v: created address mismatch: have %v", indexed, to)
+		}
+		trace, err := api.Get(context.Background(), txs[1], nil)
+		if err != nil {
+			t.Fatalf("indexed %v: failed to get trace: %v", indexed, err)
+		}
+		if !reflect.DeepEqual(trace, traces[0]) {
+			t.Errorf("indexed %v: trace mismatch: have %v, want %v", indexed, trace, traces[0])
+		}
+	}
+}
+
+func TestTraceFilter(t *testing.T) {
+	t.Parallel()
+
+	backend, addrs, txs := newTestChain(t)
+	indexer := newTestIndexer(t, backend)
+	if tail, head, ok := rawdb.ReadTraceIndexRange(backend.chaindb); !ok || tail != 1 || head != 3 {
+		t.Fatalf("index range mismatch: have %d..%d (%v), want 1..3", tail, head, ok)
+	}
+	one := uint64(1)
+	tests := []struct {
+		args FilterArgs
+		want []common.Hash
+	}{
+		{FilterArgs{}, txs},
+		{FilterArgs{FromAddress: []common.Address{addrs[0]}}, txs[:2]},
+		{FilterArgs{ToAddress: []common.Address{addrs[1], addrs[2]}}, []common.Hash{txs[0], txs[2]}},
+		{FilterArgs{FromAddress: []common.Address{addrs[1]}, ToAddress: []common.Address{addrs[1]}}, nil},
+		{FilterArgs{FromAddress: []common.Address{addrs[0]}, After: &one}, txs[1:2]},
+		{FilterArgs{Count: &one}, txs[:1]},
+	}
+	for i, tt := range tests {
+		for _, idx := range []*Indexer{nil, indexer} {
+			traces, err := NewAPI(backend, idx).Filter(context.Background(), tt.args)
+			if err != nil {
+				t.Fatalf("test %d, indexed %v: failed to filter traces: %v", i, idx != nil, err)
+			}
+			var have []common.Hash
+			for _, trace := range traces {
+				have = append(have, *trace.TransactionHash)
+			}
+			if !reflect.DeepEqual(have, tt.want) {
+				t.Errorf("test %d, indexed %v: filtered traces mismatch: have %v, want %v", i, idx != nil, have, tt.want)
+			}
+		}
+	}
+}
+
+func TestIndexerReorg(t *testing.T) {
+	t.Parallel()
+
+	backend, addrs, _ := newTestChain(t)
+	indexer := newTestIndexer(t, backend)
+
+	// Replace the indexed head with a different canonical block, the stale
+	// traces and the address index entries derived from them must be dropped
+	db := backend.chaindb
+	hash := rawdb.ReadCanonicalHash(db, 3)
+	rawdb.WriteCanonicalHash(db, common.Hash{0x01}, 3)
+	if _, err := indexer.step(); err != nil {
+		t.Fatalf("failed to unindex stale traces: %v", err)
+	}
+	if tail, head, ok := rawdb.ReadTraceIndexRange(db); !ok || tail != 1 || head != 2 {
+		t.Fatalf("index range mismatch: have %d..%d (%v), want 1..2", tail, head, ok)
+	}
+	if hashes := rawdb.ReadBlockTracesHashes(db, 3); len(hashes) != 0 {
+		t.Fatalf("stale traces left: %v", hashes)
+	}
+	if numbers := rawdb.ReadTraceAddressIndex(db, addrs[2], 0, 3); len(numbers) != 0 {
+		t.Fatalf("stale address index entries left: %v", numbers)
+	}
+	if numbers := rawdb.ReadTraceAddressIndex(db, addrs[1], 0, 3); !reflect.DeepEqual(numbers, []uint64{1}) {
+		t.Fatalf("address index mismatch: have %v, want [1]", numbers)
+	}
+	// Restore the canonical block and ensure it is indexed again
+	rawdb.WriteCanonicalHash(db, hash, 3)
+	newTestIndexer(t, backend)
+	if tail, head, ok := rawdb.ReadTraceIndexRange(db); !ok || tail != 1 || head != 3 {
+		t.Fatalf("index range mismatch: have %d..%d (%v), want 1..3", tail, head, ok)
+	}
+	if numbers := rawdb.ReadTraceAddressIndex(db, addrs[2], 0, 3); !reflect.DeepEqual(numbers, []uint64{3}) {
+		t.Fatalf("address index mismatch: have %v, want [3]", numbers)
+	}
+}
+
+func TestIndexerBackfill(t *testing.T) {
+	t.Parallel()
+
+	backend, _, _ := newTestChain(t)
+	indexer := NewIndexer(backend)
+	if _, err := indexer.step(); err != nil {
+		t.Fatalf("failed to index head: %v", err)
+	}
+	// Transient failures are retried later on
+	backend.stateErr = errors.New("transient failure")
+	if _, err := indexer.step(); err == nil || !indexer.backfill {
+		t.Fatalf("transient failure: have error %v, backfill %v", err, indexer.backfill)
+	}
+	// Missing historical state stops the backfilling
+	backend.stateErr = fmt.Errorf("%w: pruned", tracers.ErrStateUnavailable)
+	if more, err := indexer.step(); err != nil || more || indexer.backfill {
+		t.Fatalf("missing state: have error %v, more %v, backfill %v", err, more, indexer.backfill)
+	}
+	if tail, head, ok := rawdb.ReadTraceIndexRange(backend.chaindb); !ok || tail != 3 || head != 3 {
+		t.Fatalf("index range mismatch: have %d..%d (%v), want 3..3", tail, head, ok)
+	}
+}
+
+func TestReplayTransaction(t *testing.T) {
+	t.Parallel()
+
+	backend, addrs, txs := newTestChain(t)
+	api := NewAPI(backend, nil)
+
+	if _, err := api.ReplayTransaction(context.Background(), txs[0], []string{"vmTrace"}); err != errVMTraceUnsupported {
+		t.Fatalf("vmTrace error mismatch: have %v, want %v", err, errVMTraceUnsupported)
+	}
+	res, err := api.ReplayTransaction(context.Background(), txs[2], []string{"stateDiff"})
+	if err != nil {
+		t.Fatalf("failed to replay transaction: %v", err)
+	}
+	if len(res.Trace) != 0 {
+		t.Errorf("unrequested traces returned: %v", res.Trace)
+	}
+	var diff map[common.Address]map[string]json.RawMessage
+	if err := json.Unmarshal(res.StateDiff, &diff); err != nil {
+		t.Fatalf("failed to decode state diff: %v", err)
+	}
+	if nonce := string(diff[addrs[1]]["nonce"]); nonce != `{"*":{"from":"0x0","to":"0x1"}}` {
+		t.Errorf("sender nonce diff mismatch: have %s", nonce)
+	}
+	if balance := string(diff[addrs[2]]["balance"]); balance != `{"+":"0x7d0"}` {
+		t.Errorf("recipient balance diff mismatch: have %s", balance)
+	}
+	// The contract creation reports the new contract along with its storage
+	replays, err := api.ReplayBlockTransactions(context.Background(), 2, []string{"trace", "stateDiff"})
+	if err != nil {
+		t.Fatalf("failed to replay block: %v", err)
+	}
+	if len(replays) != 1 || *replays[0].TransactionHash != txs[1] || len(replays[0].Trace) != 1 {
+		t.Fatalf("unexpected block replay: %v", replays)
+	}
+	if err := json.Unmarshal(replays[0].StateDiff, &diff); err != nil {
+		t.Fatalf("failed to decode state diff: %v", err)
+	}
+	contract := crypto.CreateAddress(addrs[0], 1)
+	if storage := string(diff[contract]["storage"]); storage != `{"0x0000000000000000000000000000000000000000000000000000000000000000":{"+":"0x0000000000000000000000000000000000000000000000000000000000000001"}}` {
+		t.Errorf("contract storage diff mismatch: have %s", storage)
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package parity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// indexerRetry is the interval after which a failed indexing attempt is retried
+// if no new chain head arrives in the meantime.
+const indexerRetry = time.Minute
+
+// Backend is the interface required by the trace indexer.
+type Backend interface {
+	tracers.Backend
+	SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription
+}
+
+// Indexer maintains a persistent index of the flat call traces of the canonical
+// chain. It stores the traces of every indexed block along with the addresses
+// involved in them, serving trace_filter queries without re-executing blocks.
+//
+// Indexing starts at the chain head when first enabled, follows the chain as it
+// progresses and backfills history for as long as historical state is available.
+type Indexer struct {
+	db      ethdb.Database
+	backend Backend
+	tracer  *tracers.API
+
+	backfill bool // Whether blocks below the tail are still indexable
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewIndexer creates a trace indexer for the chain of the given backend.
+func NewIndexer(backend Backend) *Indexer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Indexer{
+		db:       backend.ChainDb(),
+		backend:  backend,
+		tracer:   tracers.NewAPI(backend),
+		backfill: true,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Start implements node.Lifecycle, starting the background indexing.
+func (idx *Indexer) Start() error {
+	idx.wg.Add(1)
+	go idx.loop()
+	return nil
+}
+
+// Stop implements node.Lifecycle, terminating the background indexing.
+func (idx *Indexer) Stop() error {
+	idx.cancel()
+	idx.wg.Wait()
+	return nil
+}
+
+// loop indexes blocks until the indexer is stopped, waiting for new chain heads
+// once the index is complete.
+func (idx *Indexer) loop() {
+	defer idx.wg.Done()
+
+	heads := make(chan core.ChainHeadEvent, 16)
+	sub := idx.backend.SubscribeChainHeadEvent(heads)
+	defer sub.Unsubscribe()
+
+	for {
+		more, err := idx.step()
+		if err != nil {
+			log.Warn("Failed to index block traces", "err", err)
+		}
+		if more && err == nil {
+			select {
+			case <-idx.ctx.Done():
+				return
+			default:
+				continue
+			}
+		}
+		select {
+		case <-heads:
+		case <-time.After(indexerRetry):
+		case <-sub.Err():
+			return
+		case <-idx.ctx.Done():
+			return
+		}
+	}
+}
+
+// step indexes a single block, extending the index towards the chain head first
+// and backfilling history afterwards. It returns whether more blocks are left.
+func (idx *Indexer) step() (bool, error) {
+	latest, err := idx.backend.HeaderByNumber(idx.ctx, rpc.LatestBlockNumber)
+	if err != nil {
+		return false, err
+	}
+	head := latest.Number.Uint64()
+
+	tail, last, ok := rawdb.ReadTraceIndexRange(idx.db)
+	switch {
+	case !ok:
+		// Start indexing at the chain head, the genesis block has no traces
+		if head == 0 {
+			return false, nil
+		}
+		return true, idx.index(head, head, head)
+
+	case last > head || rawdb.ReadBlockTraces(idx.db, last, rawdb.ReadCanonicalHash(idx.db, last)) == nil:
+		// The chain was rewound or reorged, drop the stale head of the index
+		// along with its traces and address index entries.
+		return true, idx.unindex(last, tail)
+
+	case last < head:
+		return true, idx.index(last+1, tail, last+1)
+
+	case tail > 1 && idx.backfill:
+		if err := idx.index(tail-1, tail-1, last); err != nil {
+			// Stop backfilling once the historical state is gone, other
+			// failures are retried on the next head
+			if errors.Is(err, tracers.ErrStateUnavailable) {
+				log.Info("Stopped backfilling trace index", "tail", tail, "err", err)
+				idx.backfill = false
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// index traces the canonical block with the given number, storing its traces
+// and address index entries along with the new index range atomically.
+func (idx *Indexer) index(number uint64, tail, head uint64) error {
+	hash := rawdb.ReadCanonicalHash(idx.db, number)
+	traces, err := traceBlock(idx.ctx, idx.tracer, number, hash)
+	if err != nil {
+		return err
+	}
+	blob, err := json.Marshal(traces)
+	if err != nil {
+		return err
+	}
+	batch := idx.db.NewBatch()
+	rawdb.WriteBlockTraces(batch, number, hash, blob)
+	for addr := range traceAddresses(traces) {
+		rawdb.WriteTraceAddressIndex(batch, addr, number)
+	}
+	rawdb.WriteTraceIndexRange(batch, tail, head)
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Debug("Indexed block traces", "number", number, "hash", hash, "traces", len(traces))
+	return nil
+}
+
+// unindex drops the head of the index with the given number, deleting the traces
+// of every block indexed at that height and the address index entries derived
+// from them along with the shrunk index range atomically.
+func (idx *Indexer) unindex(number uint64, tail uint64) error {
+	batch := idx.db.NewBatch()
+	for _, hash := range rawdb.ReadBlockTracesHashes(idx.db, number) {
+		if traces, ok := idx.blockTraces(number, hash); ok {
+			for addr := range traceAddresses(traces) {
+				rawdb.DeleteTraceAddressIndex(batch, addr, number)
+			}
+		}
+		rawdb.DeleteBlockTraces(batch, number, hash)
+	}
+	if number == tail {
+		rawdb.DeleteTraceIndexRange(batch)
+	} else {
+		rawdb.WriteTraceIndexRange(batch, tail, number-1)
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Debug("Unindexed stale block traces", "number", number)
+	return nil
+}
+
+// traceAddresses returns the set of addresses calls are made from or to in the
+// given traces.
+func traceAddresses(traces []*Trace) map[common.Address]struct{} {
+	addrs := make(map[common.Address]struct{})
+	for _, trace := range traces {
+		from, to := trace.addresses()
+		if from != nil {
+			addrs[*from] = struct{}{}
+		}
+		if to != nil {
+			addrs[*to] = struct{}{}
+		}
+	}
+	return addrs
+}
+
+// blockTraces returns the indexed traces of the given block, if available.
+func (idx *Indexer) blockTraces(number uint64, hash common.Hash) ([]*Trace, bool) {
+	blob := rawdb.ReadBlockTraces(idx.db, number, hash)
+	if blob == nil {
+		return nil, false
+	}
+	var traces []*Trace
+	if err := json.Unmarshal(blob, &traces); err != nil {
+		log.Error("Invalid block traces in index", "number", number, "hash", hash, "err", err)
+		return nil, false
+	}
+	return traces, true
+}
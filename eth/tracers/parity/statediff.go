@@ -0,0 +1,233 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package parity
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+)
+
+func init() {
+	tracers.DefaultDirectory.Register("stateDiffTracer", newStateDiffTracer, false)
+}
+
+// Diff is the change of a single value in the Parity state diff format.
+type Diff struct {
+	Kind     string // "=" if unchanged, "+" if born, "-" if died or "*" if changed
+	From, To any
+}
+
+// MarshalJSON encodes the diff as "=", {"+": to}, {"-": from} or
+// {"*": {"from": from, "to": to}}.
+func (d Diff) MarshalJSON() ([]byte, error) {
+	switch d.Kind {
+	case "+":
+		return json.Marshal(map[string]any{"+": d.To})
+	case "-":
+		return json.Marshal(map[string]any{"-": d.From})
+	case "*":
+		return json.Marshal(map[string]any{"*": map[string]any{"from": d.From, "to": d.To}})
+	default:
+		return []byte(`"="`), nil
+	}
+}
+
+// AccountDiff is the change of a single account in the Parity state diff format.
+type AccountDiff struct {
+	Balance Diff                 `json:"balance"`
+	Nonce   Diff                 `json:"nonce"`
+	Code    Diff                 `json:"code"`
+	Storage map[common.Hash]Diff `json:"storage"`
+}
+
+// StateDiff is the set of accounts changed by a transaction.
+type StateDiff map[common.Address]*AccountDiff
+
+// accountState is the state of an account before the transaction changed it.
+type accountState struct {
+	balance *big.Int
+	nonce   uint64
+	code    []byte
+	storage map[common.Hash]common.Hash // Original values of the changed slots
+}
+
+// exists reports whether the account is non-empty as defined by EIP-161.
+func (a *accountState) exists() bool {
+	return a.balance.Sign() != 0 || a.nonce != 0 || len(a.code) != 0
+}
+
+// stateDiffTracer collects the state changes of a transaction, reporting them in
+// the Parity state diff format.
+type stateDiffTracer struct {
+	env      *tracing.VMContext
+	accounts map[common.Address]*accountState
+	diff     StateDiff
+}
+
+func newStateDiffTracer(ctx *tracers.Context, cfg json.RawMessage) (*tracers.Tracer, error) {
+	t := &stateDiffTracer{
+		accounts: make(map[common.Address]*accountState),
+		diff:     make(StateDiff),
+	}
+	return &tracers.Tracer{
+		Hooks: &tracing.Hooks{
+			OnTxStart:       t.OnTxStart,
+			OnTxEnd:         t.OnTxEnd,
+			OnBalanceChange: t.OnBalanceChange,
+			OnNonceChange:   t.OnNonceChange,
+			OnCodeChange:    t.OnCodeChange,
+			OnStorageChange: t.OnStorageChange,
+		},
+		GetResult: t.GetResult,
+		Stop:      func(error) {},
+	}, nil
+}
+
+func (t *stateDiffTracer) OnTxStart(env *tracing.VMContext, tx *types.Transaction, from common.Address) {
+	t.env = env
+}
+
+// touch returns the original state of an account, reading it from the state on
+// the first change. The changed field is then overridden with its prior value.
+func (t *stateDiffTracer) touch(addr common.Address) (*accountState, bool) {
+	if acc, ok := t.accounts[addr]; ok {
+		return acc, false
+	}
+	acc := &accountState{
+		balance: t.env.StateDB.GetBalance(addr).ToBig(),
+		nonce:   t.env.StateDB.GetNonce(addr),
+		code:    t.env.StateDB.GetCode(addr),
+		storage: make(map[common.Hash]common.Hash),
+	}
+	t.accounts[addr] = acc
+	return acc, true
+}
+
+func (t *stateDiffTracer) OnBalanceChange(addr common.Address, prev, _ *big.Int, reason tracing.BalanceChangeReason) {
+	if acc, fresh := t.touch(addr); fresh {
+		acc.balance = new(big.Int).Set(prev)
+	}
+}
+
+func (t *stateDiffTracer) OnNonceChange(addr common.Address, prev, _ uint64) {
+	if acc, fresh := t.touch(addr); fresh {
+		acc.nonce = prev
+	}
+}
+
+func (t *stateDiffTracer) OnCodeChange(addr common.Address, prevCodeHash common.Hash, prevCode []byte, codeHash common.Hash, code []byte) {
+	if acc, fresh := t.touch(addr); fresh {
+		acc.code = prevCode
+	}
+}
+
+func (t *stateDiffTracer) OnStorageChange(addr common.Address, slot common.Hash, prev, _ common.Hash) {
+	acc, _ := t.touch(addr)
+	if _, ok := acc.storage[slot]; !ok {
+		acc.storage[slot] = prev
+	}
+}
+
+// OnTxEnd compares the original state of the changed accounts with their state
+// after the transaction.
+func (t *stateDiffTracer) OnTxEnd(receipt *types.Receipt, err error) {
+	if err != nil {
+		return
+	}
+	for addr, pre := range t.accounts {
+		post := &accountState{
+			balance: t.env.StateDB.GetBalance(addr).ToBig(),
+			nonce:   t.env.StateDB.GetNonce(addr),
+			code:    t.env.StateDB.GetCode(addr),
+			storage: make(map[common.Hash]common.Hash),
+		}
+		for slot := range pre.storage {
+			post.storage[slot] = t.env.StateDB.GetState(addr, slot)
+		}
+		if diff := diffAccount(pre, post); diff != nil {
+			t.diff[addr] = diff
+		}
+	}
+}
+
+func (t *stateDiffTracer) GetResult() (json.RawMessage, error) {
+	return json.Marshal(t.diff)
+}
+
+// diffAccount returns the Parity state diff of an account, or nil if the account
+// was not changed.
+func diffAccount(pre, post *accountState) *AccountDiff {
+	diff := &AccountDiff{Storage: make(map[common.Hash]Diff)}
+	switch {
+	case !pre.exists() && !post.exists():
+		return nil
+
+	case !pre.exists():
+		diff.Balance = Diff{Kind: "+", To: (*hexutil.Big)(post.balance)}
+		diff.Nonce = Diff{Kind: "+", To: hexutil.Uint64(post.nonce)}
+		diff.Code = Diff{Kind: "+", To: hexutil.Bytes(post.code)}
+		for slot, value := range post.storage {
+			if value != (common.Hash{}) {
+				diff.Storage[slot] = Diff{Kind: "+", To: value}
+			}
+		}
+		return diff
+
+	case !post.exists():
+		diff.Balance = Diff{Kind: "-", From: (*hexutil.Big)(pre.balance)}
+		diff.Nonce = Diff{Kind: "-", From: hexutil.Uint64(pre.nonce)}
+		diff.Code = Diff{Kind: "-", From: hexutil.Bytes(pre.code)}
+		for slot, value := range pre.storage {
+			if value != (common.Hash{}) {
+				diff.Storage[slot] = Diff{Kind: "-", From: value}
+			}
+		}
+		return diff
+	}
+	changed := false
+	if pre.balance.Cmp(post.balance) != 0 {
+		diff.Balance, changed = Diff{Kind: "*", From: (*hexutil.Big)(pre.balance), To: (*hexutil.Big)(post.balance)}, true
+	} else {
+		diff.Balance = Diff{Kind: "="}
+	}
+	if pre.nonce != post.nonce {
+		diff.Nonce, changed = Diff{Kind: "*", From: hexutil.Uint64(pre.nonce), To: hexutil.Uint64(post.nonce)}, true
+	} else {
+		diff.Nonce = Diff{Kind: "="}
+	}
+	if !bytes.Equal(pre.code, post.code) {
+		diff.Code, changed = Diff{Kind: "*", From: hexutil.Bytes(pre.code), To: hexutil.Bytes(post.code)}, true
+	} else {
+		diff.Code = Diff{Kind: "="}
+	}
+	for slot, value := range pre.storage {
+		if value != post.storage[slot] {
+			diff.Storage[slot], changed = Diff{Kind: "*", From: value, To: post.storage[slot]}, true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return diff
+}
@@ -0,0 +1,154 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// maxProfileBlocks is the maximum number of blocks profiled in a single call.
+const maxProfileBlocks = 1024
+
+// ProfileConfig holds extra parameters to profile functions.
+type ProfileConfig struct {
+	Reexec *uint64
+}
+
+// ProfileResult is the accumulated execution cost of a range of blocks.
+type ProfileResult struct {
+	Opcodes     map[string]*vm.ProfileStats         `json:"opcodes"`
+	Precompiles map[common.Address]*vm.ProfileStats `json:"precompiles"`
+	Contracts   map[common.Hash]*vm.ProfileStats    `json:"contracts"`
+	Pprof       hexutil.Bytes                       `json:"pprof"` // Gzipped pprof profile
+}
+
+// ProfileBlock re-executes the blocks from start to end (inclusive, defaults to
+// start) and returns the wall time, gas and number of executions accumulated
+// per opcode, per precompile and per contract code hash, along with the same
+// data as a pprof profile.
+func (api *API) ProfileBlock(ctx context.Context, start rpc.BlockNumber, end *rpc.BlockNumber, config *ProfileConfig) (*ProfileResult, error) {
+	from, err := api.blockByNumber(ctx, start)
+	if err != nil {
+		return nil, err
+	}
+	to := from
+	if end != nil {
+		if to, err = api.blockByNumber(ctx, *end); err != nil {
+			return nil, err
+		}
+	}
+	if from.NumberU64() == 0 {
+		return nil, errors.New("genesis is not traceable")
+	}
+	if from.NumberU64() > to.NumberU64() {
+		return nil, fmt.Errorf("end block (#%d) needs to come after start block (#%d)", to.NumberU64(), from.NumberU64())
+	}
+	if to.NumberU64()-from.NumberU64() >= maxProfileBlocks {
+		return nil, fmt.Errorf("too many blocks to profile: have %d, max %d", to.NumberU64()-from.NumberU64()+1, maxProfileBlocks)
+	}
+	reexec := defaultTraceReexec
+	if config != nil && config.Reexec != nil {
+		reexec = *config.Reexec
+	}
+	profiler := vm.NewProfiler()
+	for number := from.NumberU64(); number <= to.NumberU64(); number++ {
+		block := to
+		if number != to.NumberU64() {
+			if block, err = api.blockByNumber(ctx, rpc.BlockNumber(number)); err != nil {
+				return nil, err
+			}
+		}
+		if err := api.profileBlock(ctx, block, reexec, profiler); err != nil {
+			return nil, err
+		}
+	}
+	result := &ProfileResult{
+		Opcodes:     make(map[string]*vm.ProfileStats),
+		Precompiles: profiler.Precompiles(),
+		Contracts:   profiler.Contracts(),
+	}
+	for op, stats := range profiler.Opcodes() {
+		result.Opcodes[op.String()] = stats
+	}
+	var buf bytes.Buffer
+	if err := profiler.Profile().Write(&buf); err != nil {
+		return nil, err
+	}
+	result.Pprof = buf.Bytes()
+	return result, nil
+}
+
+// profileBlock re-executes all transactions of a block with the given profiler.
+func (api *API) profileBlock(ctx context.Context, block *types.Block, reexec uint64, profiler *vm.Profiler) error {
+	parent, err := api.blockByNumberAndHash(ctx, rpc.BlockNumber(block.NumberU64()-1), block.ParentHash())
+	if err != nil {
+		return err
+	}
+	statedb, release, err := api.backend.StateAtBlock(ctx, parent, reexec, nil, true, false)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	var (
+		signer      = types.MakeSigner(api.backend.ChainConfig(), block.Number(), block.Time())
+		chainConfig = api.backend.ChainConfig()
+		vmctx       = core.NewEVMBlockContext(block.Header(), api.chainContext(ctx), nil)
+		vmenv       = vm.NewEVM(vmctx, vm.TxContext{}, statedb, chainConfig, vm.Config{Profiler: profiler})
+	)
+	if beaconRoot := block.BeaconRoot(); beaconRoot != nil {
+		core.ProcessBeaconBlockRoot(*beaconRoot, vmenv, statedb)
+	}
+	if chainConfig.IsPrague(block.Number(), block.Time()) {
+		core.ProcessParentBlockHash(block.ParentHash(), vmenv, statedb)
+	}
+	for i, tx := range block.Transactions() {
+		if i == 0 && chainConfig.Taiko {
+			if err := tx.MarkAsAnchor(); err != nil {
+				return err
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		msg, err := core.TransactionToMessage(tx, signer, block.BaseFee())
+		if err != nil {
+			return fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
+		}
+		// Taiko ontake blocks carry the basefee sharing percentage in the extradata.
+		if chainConfig.IsOntake(block.Number()) {
+			msg.BasefeeSharingPctg = core.DecodeOntakeExtraData(block.Header().Extra)
+		}
+		statedb.SetTxContext(tx.Hash(), i)
+		vmenv.Reset(core.NewEVMTxContext(msg), statedb)
+		if _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.GasLimit)); err != nil {
+			return fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
+		}
+		statedb.Finalise(vmenv.ChainConfig().IsEIP158(block.Number()))
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/google/pprof/profile"
+)
+
+func TestProfileBlock(t *testing.T) {
+	t.Parallel()
+
+	var (
+		accounts = newAccounts(1)
+		contract = common.Address{0xc0}
+		code     = []byte{byte(vm.PUSH1), 0x01, byte(vm.PUSH1), 0x02, byte(vm.ADD), byte(vm.POP), byte(vm.STOP)}
+		genesis  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: types.GenesisAlloc{
+				accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+				contract:         {Code: code},
+			},
+		}
+	)
+	backend := newTestBackend(t, 3, genesis, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTx(&types.LegacyTx{
+			Nonce:    uint64(i),
+			To:       &contract,
+			Gas:      100_000,
+			GasPrice: b.BaseFee(),
+		}), types.HomesteadSigner{}, accounts[0].key)
+		b.AddTx(tx)
+	})
+	defer backend.chain.Stop()
+	api := NewAPI(backend)
+
+	end := rpc.BlockNumber(3)
+	result, err := api.ProfileBlock(context.Background(), 1, &end, nil)
+	if err != nil {
+		t.Fatalf("failed to profile blocks: %v", err)
+	}
+	if have := result.Opcodes["ADD"]; have == nil || have.Count != 3 {
+		t.Fatalf("ADD stats mismatch: have %+v, want 3 executions", have)
+	}
+	if have := result.Contracts[crypto.Keccak256Hash(code)]; have == nil || have.Count != 3 || have.Gas != 3*11 {
+		t.Fatalf("contract stats mismatch: have %+v, want 3 calls using 33 gas", have)
+	}
+	prof, err := profile.Parse(bytes.NewReader(result.Pprof))
+	if err != nil {
+		t.Fatalf("failed to parse pprof profile: %v", err)
+	}
+	if len(prof.Sample) != len(result.Opcodes) {
+		t.Fatalf("sample count mismatch: have %d, want %d", len(prof.Sample), len(result.Opcodes))
+	}
+	// Profiling a single block, and invalid ranges.
+	if result, err = api.ProfileBlock(context.Background(), 2, nil, nil); err != nil || result.Opcodes["ADD"].Count != 1 {
+		t.Fatalf("failed to profile single block: %v", err)
+	}
+	end = 1
+	if _, err := api.ProfileBlock(context.Background(), 2, &end, nil); err == nil {
+		t.Fatal("profiled reversed block range")
+	}
+}
@@ -0,0 +1,683 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package btreedb implements an embedded key-value store on top of a copy-on-write
+// B+tree kept in a single memory mapped file.
+//
+// Reads are served straight from the read-only mapping without any locking
+// against the writer, which makes the engine a good fit for read-heavy (e.g.
+// RPC serving) workloads. Writes are serialized and committed atomically: the
+// modified nodes are written to unused pages, synced, and only then published
+// by writing one of two alternating checksummed meta pages. A crash at any
+// point leaves at least one intact meta page referencing a consistent tree.
+package btreedb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// FileName is the name of the database file within the data directory.
+const FileName = "btree.db"
+
+var (
+	// errClosed is returned if a database was already closed at the invocation
+	// of a data access operation.
+	errClosed = errors.New("database closed")
+
+	// errNotFound is returned if a key is requested that is not found in the
+	// database.
+	errNotFound = errors.New("not found")
+
+	// errReadOnly is returned if a write is attempted on a database opened in
+	// read-only mode.
+	errReadOnly = errors.New("read-only database")
+)
+
+// Database is a persistent key-value store based on a copy-on-write B+tree.
+// Apart from basic data storage functionality it also supports batch writes
+// and iterating over the keyspace in binary-alphabetical order.
+type Database struct {
+	path      string
+	file      *os.File
+	readonly  bool
+	ephemeral bool
+
+	lock   sync.RWMutex // Protects the mapping against remaps and closure
+	data   []byte       // Read-only mapping of the file, nil if unsupported
+	closed bool
+
+	readLock sync.Mutex        // Protects the committed meta and the reader set
+	meta     meta              // Meta of the last committed transaction
+	readers  map[uint64]int    // Number of active readers per transaction id
+	pending  map[uint64][]pgid // Pages freed by a transaction, awaiting its readers
+
+	writeLock     sync.Mutex      // Serializes write transactions
+	queueLock     sync.Mutex      // Protects the queue of direct writes
+	queue         []*writeRequest // Direct writes awaiting a group commit
+	free          []pgid          // Sorted list of pages available for reuse
+	freelistPages int             // Number of pages taken by the persisted freelist
+
+	log log.Logger
+
+	// failCommit is a testing hook to abort commits right before publishing
+	// the new meta page, emulating a crash.
+	failCommit bool
+}
+
+// Exists reports whether a database file is present in the given directory.
+func Exists(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, FileName))
+	return err == nil
+}
+
+// New opens (or creates, unless read-only) the database in the given directory.
+// Ephemeral databases skip all filesystem sync operations, trading crash safety
+// for speed.
+func New(dir string, readonly bool, ephemeral bool) (*Database, error) {
+	var (
+		path = filepath.Join(dir, FileName)
+		file *os.File
+		err  error
+	)
+	if readonly {
+		file, err = os.Open(path)
+	} else {
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+		file, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := flock(file, readonly); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to lock database file %s: %w", path, err)
+	}
+	db := &Database{
+		path:      path,
+		file:      file,
+		readonly:  readonly,
+		ephemeral: ephemeral,
+		readers:   make(map[uint64]int),
+		pending:   make(map[uint64][]pgid),
+		log:       log.New("database", dir),
+	}
+	if err := db.init(); err != nil {
+		funlock(file)
+		file.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// init loads the latest consistent state of the database file, creating a
+// fresh one if the file is empty.
+func (db *Database) init() error {
+	info, err := db.file.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+	if size == 0 {
+		if db.readonly {
+			return errCorruptMeta
+		}
+		// Fresh database, write two empty meta pages
+		buf := make([]byte, 2*pageSize)
+		for i := 0; i < 2; i++ {
+			m := meta{pages: 2, txid: uint64(i)}
+			copy(buf[i*pageSize:], m.encode())
+		}
+		if _, err := db.file.WriteAt(buf, 0); err != nil {
+			return err
+		}
+		if err := db.sync(); err != nil {
+			return err
+		}
+		size = int64(len(buf))
+	}
+	// Pick the most recent valid meta page. A meta which fails its checksum or
+	// references data past the end of the file belongs to a commit torn apart
+	// by a crash, in which case the previous one is still intact.
+	var metas [2]*meta
+	for i := range metas {
+		buf := make([]byte, metaSize)
+		if _, err := db.file.ReadAt(buf, int64(i)*pageSize); err != nil {
+			continue
+		}
+		m, err := decodeMeta(buf)
+		if err != nil || m.pages < 2 || int64(m.pages)*pageSize > size || m.root >= m.pages || m.freelist >= m.pages {
+			continue
+		}
+		metas[i] = m
+	}
+	m := metas[0]
+	if m == nil || (metas[1] != nil && metas[1].txid > m.txid) {
+		m = metas[1]
+	}
+	if m == nil {
+		return fmt.Errorf("no valid meta page in %s", db.path)
+	}
+	if other := metas[1-m.txid%2]; other == nil {
+		db.log.Warn("Recovered database from torn commit", "txid", m.txid)
+	}
+	db.meta = *m
+
+	if err := db.remap(int(m.pages) * pageSize); err != nil {
+		return err
+	}
+	if m.freelist != 0 {
+		p, err := db.page(m.freelist)
+		if err != nil {
+			return err
+		}
+		if db.free, err = decodeFreelist(p); err != nil {
+			return err
+		}
+		db.freelistPages = 1 + pageOverflow(p)
+	}
+	return nil
+}
+
+// remap ensures the mapping covers at least the given number of bytes. It
+// must be called with the lock held for writing, or during initialization.
+func (db *Database) remap(minSize int) error {
+	size := 1 << 24
+	for size < minSize && size < 1<<30 {
+		size <<= 1
+	}
+	if size < minSize {
+		size = (minSize + 1<<30 - 1) / (1 << 30) * (1 << 30)
+	}
+	if db.data != nil && len(db.data) >= size {
+		return nil
+	}
+	if err := munmap(db.data); err != nil {
+		return err
+	}
+	db.data = nil
+	data, err := mmap(db.file, size)
+	if err != nil {
+		return fmt.Errorf("failed to mmap database: %w", err)
+	}
+	db.data = data
+	return nil
+}
+
+// page returns the raw content of the node or freelist starting at the given
+// page, including any overflow pages. It must be called with the lock held.
+func (db *Database) page(id pgid) ([]byte, error) {
+	if id < 2 {
+		return nil, errCorruptPage
+	}
+	off := int(id) * pageSize
+	if db.data == nil {
+		buf := make([]byte, pageSize)
+		if _, err := db.file.ReadAt(buf, int64(off)); err != nil {
+			return nil, err
+		}
+		if overflow := pageOverflow(buf); overflow > 0 {
+			buf = append(buf, make([]byte, overflow*pageSize)...)
+			if _, err := db.file.ReadAt(buf[pageSize:], int64(off+pageSize)); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	}
+	if off+pageSize > len(db.data) {
+		return nil, errCorruptPage
+	}
+	end := off + (1+pageOverflow(db.data[off:]))*pageSize
+	if end > len(db.data) {
+		return nil, errCorruptPage
+	}
+	return db.data[off:end], nil
+}
+
+// sync flushes the file content to disk, unless the database is ephemeral.
+func (db *Database) sync() error {
+	if db.ephemeral {
+		return nil
+	}
+	return db.file.Sync()
+}
+
+// acquire pins the latest committed tree, preventing its pages from being
+// reused until released.
+func (db *Database) acquire() (pgid, uint64) {
+	db.readLock.Lock()
+	defer db.readLock.Unlock()
+
+	db.readers[db.meta.txid]++
+	return db.meta.root, db.meta.txid
+}
+
+// release unpins a tree previously pinned by acquire.
+func (db *Database) release(txid uint64) {
+	db.readLock.Lock()
+	defer db.readLock.Unlock()
+
+	if db.readers[txid]--; db.readers[txid] == 0 {
+		delete(db.readers, txid)
+	}
+}
+
+// Close unmaps and closes the database file, ensuring any consecutive data
+// access op fails with an error.
+func (db *Database) Close() error {
+	db.writeLock.Lock()
+	defer db.writeLock.Unlock()
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if db.closed {
+		return nil
+	}
+	db.closed = true
+
+	var errs []error
+	if err := munmap(db.data); err != nil {
+		errs = append(errs, err)
+	}
+	db.data = nil
+	if err := funlock(db.file); err != nil {
+		errs = append(errs, err)
+	}
+	if err := db.file.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// lookup retrieves the value stored under key in the tree with the given root.
+// It must be called with the lock held.
+func (db *Database) lookup(root pgid, key []byte) ([]byte, bool, error) {
+	for id := root; id != 0; {
+		p, err := db.page(id)
+		if err != nil {
+			return nil, false, err
+		}
+		switch pageFlags(p) {
+		case branchPageFlag:
+			_, id = branchElem(p, searchBranch(p, key))
+		case leafPageFlag:
+			i := searchLeaf(p, key)
+			if i < pageCount(p) {
+				if k, v := leafElem(p, i); bytes.Equal(k, key) {
+					return v, true, nil
+				}
+			}
+			return nil, false, nil
+		default:
+			return nil, false, errCorruptPage
+		}
+	}
+	return nil, false, nil
+}
+
+// Has retrieves if a key is present in the key-value store.
+func (db *Database) Has(key []byte) (bool, error) {
+	root, txid := db.acquire()
+	defer db.release(txid)
+
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	if db.closed {
+		return false, errClosed
+	}
+	_, ok, err := db.lookup(root, key)
+	return ok, err
+}
+
+// Get retrieves the given key if it's present in the key-value store.
+func (db *Database) Get(key []byte) ([]byte, error) {
+	root, txid := db.acquire()
+	defer db.release(txid)
+
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	if db.closed {
+		return nil, errClosed
+	}
+	val, ok, err := db.lookup(root, key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errNotFound
+	}
+	return common.CopyBytes(val), nil
+}
+
+// Put inserts the given value into the key-value store. The concurrent direct
+// writes are committed together, but each of them still waits for a sync of the
+// file, so batches should be preferred for bulk writes.
+func (db *Database) Put(key []byte, value []byte) error {
+	return db.write(keyvalue{key: key, value: value})
+}
+
+// Delete removes the key from the key-value store. The concurrent direct writes
+// are committed together, like for Put.
+func (db *Database) Delete(key []byte) error {
+	return db.write(keyvalue{key: key, delete: true})
+}
+
+// NewBatch creates a write-only key-value store that buffers changes to its host
+// database until a final write is called.
+func (db *Database) NewBatch() ethdb.Batch {
+	return &batch{db: db}
+}
+
+// NewBatchWithSize creates a write-only database batch with pre-allocated buffer.
+func (db *Database) NewBatchWithSize(size int) ethdb.Batch {
+	return &batch{db: db, writes: make([]keyvalue, 0, size)}
+}
+
+// Stat returns the page usage statistics of the database.
+func (db *Database) Stat() (string, error) {
+	db.writeLock.Lock()
+	defer db.writeLock.Unlock()
+
+	if db.closed {
+		return "", errClosed
+	}
+	db.readLock.Lock()
+	defer db.readLock.Unlock()
+
+	var pending int
+	for _, ids := range db.pending {
+		pending += len(ids)
+	}
+	return fmt.Sprintf("Pages: %d\nFree pages: %d\nPending pages: %d\nFreelist pages: %d\nPage size: %d\nTransaction: %d\nActive readers: %d\n",
+		db.meta.pages, len(db.free), pending, db.freelistPages, pageSize, db.meta.txid, len(db.readers)), nil
+}
+
+// Compact rewrites the nodes holding the keys of the given range into the lowest
+// free pages of the file, merging the underfull ones, and truncates the free
+// pages off the end of the file afterwards. A nil start is treated as a key
+// before all keys in the database, and a nil limit as a key after all of them.
+//
+// The pages pinned by active readers are not reused until they are released, so
+// the file is only shrunk as far as they allow.
+func (db *Database) Compact(start []byte, limit []byte) error {
+	for next := start; ; {
+		var err error
+		if next, err = db.compactRange(next, limit); err != nil {
+			return err
+		}
+		if next == nil {
+			break
+		}
+	}
+	return db.shrink()
+}
+
+// Path returns the path to the database file.
+func (db *Database) Path() string {
+	return db.path
+}
+
+// NewIterator creates a binary-alphabetical iterator over a subset
+// of database content with a particular key prefix, starting at a particular
+// initial key (or after, if it does not exist).
+func (db *Database) NewIterator(prefix []byte, start []byte) ethdb.Iterator {
+	root, txid := db.acquire()
+	return &iterator{
+		db:     db,
+		root:   root,
+		txid:   txid,
+		prefix: common.CopyBytes(prefix),
+		seek:   append(common.CopyBytes(prefix), start...),
+	}
+}
+
+// keyvalue is a key-value tuple tagged with a deletion field to allow creating
+// database write batches.
+type keyvalue struct {
+	key    []byte
+	value  []byte
+	delete bool
+}
+
+// batch is a write-only key-value store that buffers changes to its host
+// database until a final write is called.
+type batch struct {
+	db     *Database
+	writes []keyvalue
+	size   int
+}
+
+// Put inserts the given value into the batch for later committing.
+func (b *batch) Put(key, value []byte) error {
+	b.writes = append(b.writes, keyvalue{common.CopyBytes(key), common.CopyBytes(value), false})
+	b.size += len(key) + len(value)
+	return nil
+}
+
+// Delete inserts the key removal into the batch for later committing.
+func (b *batch) Delete(key []byte) error {
+	b.writes = append(b.writes, keyvalue{common.CopyBytes(key), nil, true})
+	b.size += len(key)
+	return nil
+}
+
+// ValueSize retrieves the amount of data queued up for writing.
+func (b *batch) ValueSize() int {
+	return b.size
+}
+
+// Write flushes any accumulated data to disk in a single transaction.
+func (b *batch) Write() error {
+	return b.db.commit(b.writes)
+}
+
+// Reset resets the batch for reuse.
+func (b *batch) Reset() {
+	b.writes = b.writes[:0]
+	b.size = 0
+}
+
+// Replay replays the batch contents.
+func (b *batch) Replay(w ethdb.KeyValueWriter) error {
+	for _, keyvalue := range b.writes {
+		if keyvalue.delete {
+			if err := w.Delete(keyvalue.key); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := w.Put(keyvalue.key, keyvalue.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// frame is a position within a node page on the iterator's path.
+type frame struct {
+	id  pgid
+	idx int
+}
+
+// iterator walks a pinned snapshot of the tree. It keeps the path to the current
+// leaf element as page ids rather than memory references, so that the mapping
+// is only locked for the duration of a single step and may be remapped between
+// two of them.
+type iterator struct {
+	db     *Database
+	root   pgid
+	txid   uint64
+	prefix []byte
+	seek   []byte
+
+	stack    []frame
+	started  bool
+	done     bool
+	released bool
+	key, val []byte
+	err      error
+}
+
+// Next moves the iterator to the next key/value pair. It returns whether the
+// iterator is exhausted.
+func (it *iterator) Next() bool {
+	if it.done || it.err != nil || it.released {
+		return false
+	}
+	it.db.lock.RLock()
+	defer it.db.lock.RUnlock()
+
+	if it.db.closed {
+		it.err = errClosed
+		return false
+	}
+	if !it.started {
+		it.started = true
+		if it.root == 0 {
+			return it.finish()
+		}
+		if it.err = it.descend(); it.err != nil {
+			return false
+		}
+	} else {
+		it.stack[len(it.stack)-1].idx++
+	}
+	ok, err := it.settle()
+	if err != nil {
+		it.err = err
+		return false
+	}
+	if !ok {
+		return it.finish()
+	}
+	p, err := it.db.page(it.stack[len(it.stack)-1].id)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	key, val := leafElem(p, it.stack[len(it.stack)-1].idx)
+	if !bytes.HasPrefix(key, it.prefix) {
+		return it.finish()
+	}
+	it.key = append(it.key[:0], key...)
+	it.val = append(it.val[:0], val...)
+	return true
+}
+
+// descend positions the iterator at the first element not smaller than the
+// seek key, possibly one past the end of a leaf.
+func (it *iterator) descend() error {
+	for id := it.root; ; {
+		p, err := it.db.page(id)
+		if err != nil {
+			return err
+		}
+		switch pageFlags(p) {
+		case branchPageFlag:
+			i := searchBranch(p, it.seek)
+			it.stack = append(it.stack, frame{id, i})
+			_, id = branchElem(p, i)
+		case leafPageFlag:
+			it.stack = append(it.stack, frame{id, searchLeaf(p, it.seek)})
+			return nil
+		default:
+			return errCorruptPage
+		}
+	}
+}
+
+// settle moves the iterator forward until it points at a valid leaf element,
+// returning false if the tree is exhausted.
+func (it *iterator) settle() (bool, error) {
+	for len(it.stack) > 0 {
+		top := it.stack[len(it.stack)-1]
+		p, err := it.db.page(top.id)
+		if err != nil {
+			return false, err
+		}
+		if top.idx >= pageCount(p) {
+			it.stack = it.stack[:len(it.stack)-1]
+			if len(it.stack) > 0 {
+				it.stack[len(it.stack)-1].idx++
+			}
+			continue
+		}
+		switch pageFlags(p) {
+		case leafPageFlag:
+			return true, nil
+		case branchPageFlag:
+			_, child := branchElem(p, top.idx)
+			it.stack = append(it.stack, frame{child, 0})
+		default:
+			return false, errCorruptPage
+		}
+	}
+	return false, nil
+}
+
+// finish marks the iterator exhausted.
+func (it *iterator) finish() bool {
+	it.done = true
+	it.key, it.val = nil, nil
+	return false
+}
+
+// Error returns any accumulated error. Exhausting all the key/value pairs
+// is not considered to be an error.
+func (it *iterator) Error() error {
+	return it.err
+}
+
+// Key returns the key of the current key/value pair, or nil if done. The caller
+// should not modify the contents of the returned slice, and its contents may
+// change on the next call to Next.
+func (it *iterator) Key() []byte {
+	if it.done || it.err != nil || it.released {
+		return nil
+	}
+	return it.key
+}
+
+// Value returns the value of the current key/value pair, or nil if done. The
+// caller should not modify the contents of the returned slice, and its contents
+// may change on the next call to Next.
+func (it *iterator) Value() []byte {
+	if it.done || it.err != nil || it.released {
+		return nil
+	}
+	return it.val
+}
+
+// Release releases associated resources. Release should always succeed and can
+// be called multiple times without causing error.
+func (it *iterator) Release() {
+	if !it.released {
+		it.released = true
+		it.db.release(it.txid)
+	}
+}
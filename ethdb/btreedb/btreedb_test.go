@@ -0,0 +1,519 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package btreedb
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/dbtest"
+)
+
+func TestBTreeDB(t *testing.T) {
+	t.Run("DatabaseSuite", func(t *testing.T) {
+		dbtest.TestDatabaseSuite(t, func() ethdb.KeyValueStore {
+			db, err := New(t.TempDir(), false, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return db
+		})
+	})
+}
+
+func BenchmarkBTreeDB(b *testing.B) {
+	dbtest.BenchDatabaseSuite(b, func() ethdb.KeyValueStore {
+		db, err := New(b.TempDir(), false, true)
+		if err != nil {
+			b.Fatal(err)
+		}
+		return db
+	})
+}
+
+// checkIntegrity walks the whole tree verifying the key order and that no page
+// is referenced twice or is both reachable and free.
+func checkIntegrity(t *testing.T, db *Database) {
+	t.Helper()
+
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	used := make(map[pgid]string)
+	mark := func(id pgid, overflow int, what string) {
+		for i := 0; i <= overflow; i++ {
+			if prev, ok := used[id+pgid(i)]; ok {
+				t.Fatalf("page %d used as %s and %s", id+pgid(i), prev, what)
+			}
+			if id+pgid(i) >= db.meta.pages {
+				t.Fatalf("page %d beyond high water mark %d", id+pgid(i), db.meta.pages)
+			}
+			used[id+pgid(i)] = what
+		}
+	}
+	var (
+		last []byte
+		walk func(id pgid, lower []byte)
+	)
+	walk = func(id pgid, lower []byte) {
+		p, err := db.page(id)
+		if err != nil {
+			t.Fatalf("failed to read page %d: %v", id, err)
+		}
+		mark(id, pageOverflow(p), "node")
+		if pageCount(p) == 0 {
+			t.Fatalf("empty node at page %d", id)
+		}
+		for i := 0; i < pageCount(p); i++ {
+			if pageFlags(p) == leafPageFlag {
+				key, _ := leafElem(p, i)
+				if last != nil && bytes.Compare(last, key) >= 0 {
+					t.Fatalf("key order violated: %x >= %x", last, key)
+				}
+				if lower != nil && bytes.Compare(key, lower) < 0 {
+					t.Fatalf("key %x below separator %x", key, lower)
+				}
+				last = bytes.Clone(key)
+				continue
+			}
+			key, child := branchElem(p, i)
+			if i == 0 {
+				key = lower
+			}
+			walk(child, key)
+		}
+	}
+	if db.meta.root != 0 {
+		walk(db.meta.root, nil)
+	}
+	if db.meta.freelist != 0 {
+		mark(db.meta.freelist, db.freelistPages-1, "freelist")
+	}
+	for _, id := range db.free {
+		mark(id, 0, "free")
+	}
+	for _, ids := range db.pending {
+		for _, id := range ids {
+			mark(id, 0, "pending")
+		}
+	}
+	if pgid(len(used)+2) != db.meta.pages {
+		t.Fatalf("leaked pages: %d accounted, %d allocated", len(used)+2, db.meta.pages)
+	}
+}
+
+// checkContent verifies that the database holds exactly the given content.
+func checkContent(t *testing.T, db *Database, want map[string][]byte) {
+	t.Helper()
+
+	it := db.NewIterator(nil, nil)
+	defer it.Release()
+
+	var count int
+	for it.Next() {
+		val, ok := want[string(it.Key())]
+		if !ok {
+			t.Fatalf("unexpected key %x", it.Key())
+		}
+		if !bytes.Equal(val, it.Value()) {
+			t.Fatalf("value mismatch for key %x", it.Key())
+		}
+		count++
+	}
+	if err := it.Error(); err != nil {
+		t.Fatal(err)
+	}
+	if count != len(want) {
+		t.Fatalf("item count mismatch: have %d, want %d", count, len(want))
+	}
+	for key, val := range want {
+		have, err := db.Get([]byte(key))
+		if err != nil {
+			t.Fatalf("failed to get key %x: %v", key, err)
+		}
+		if !bytes.Equal(have, val) {
+			t.Fatalf("value mismatch for key %x", key)
+		}
+	}
+}
+
+// randomBatch applies a random mix of inserts, updates and deletions of various
+// sizes to both the database and the reference content.
+func randomBatch(t *testing.T, rng *rand.Rand, db *Database, content map[string][]byte, ops int) {
+	t.Helper()
+
+	batch := db.NewBatch()
+	for i := 0; i < ops; i++ {
+		key := []byte(fmt.Sprintf("key-%05d", rng.Intn(4000)))
+		if rng.Intn(3) == 0 {
+			batch.Delete(key)
+			delete(content, string(key))
+			continue
+		}
+		size := rng.Intn(64)
+		if rng.Intn(50) == 0 {
+			size = rng.Intn(5 * pageSize) // exercise overflow pages
+		}
+		val := make([]byte, size)
+		rng.Read(val)
+		batch.Put(key, val)
+		content[string(key)] = val
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRandomOperations(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		rng     = rand.New(rand.NewSource(1))
+		content = make(map[string][]byte)
+	)
+	db, err := New(dir, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for round := 0; round < 50; round++ {
+		randomBatch(t, rng, db, content, 1+rng.Intn(500))
+		checkIntegrity(t, db)
+		checkContent(t, db, content)
+
+		if round%10 == 9 {
+			db.Close()
+			if db, err = New(dir, false, true); err != nil {
+				t.Fatal(err)
+			}
+			checkContent(t, db, content)
+		}
+	}
+	// Delete everything and ensure the tree collapses
+	batch := db.NewBatch()
+	for key := range content {
+		batch.Delete([]byte(key))
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatal(err)
+	}
+	if db.meta.root != 0 {
+		t.Fatalf("root not reset after deleting all keys: %d", db.meta.root)
+	}
+	checkIntegrity(t, db)
+	db.Close()
+}
+
+func TestIteratorSnapshot(t *testing.T) {
+	db, err := New(t.TempDir(), false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for i := 0; i < 1000; i++ {
+		db.Put([]byte(fmt.Sprintf("%04d", i)), []byte("old"))
+	}
+	it := db.NewIterator(nil, nil)
+	defer it.Release()
+
+	// Overwrite the entire content repeatedly while iterating, forcing the
+	// writer to churn through pages. The iterator must keep seeing its own
+	// snapshot of the tree.
+	var count int
+	for it.Next() {
+		if want := fmt.Sprintf("%04d", count); string(it.Key()) != want || string(it.Value()) != "old" {
+			t.Fatalf("item %d: have %s=%s, want %s=old", count, it.Key(), it.Value(), want)
+		}
+		if count%100 == 0 {
+			batch := db.NewBatch()
+			for i := 0; i < 1000; i++ {
+				batch.Put([]byte(fmt.Sprintf("%04d", i)), []byte(fmt.Sprintf("new-%d", count)))
+			}
+			if err := batch.Write(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		count++
+	}
+	if count != 1000 {
+		t.Fatalf("iterated %d items, want 1000", count)
+	}
+	it.Release()
+	checkIntegrity(t, db)
+}
+
+func TestReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := New(dir, true, false); err == nil {
+		t.Fatal("opened non-existent database in read-only mode")
+	}
+	db, err := New(dir, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Put([]byte("key"), []byte("value"))
+	db.Close()
+
+	if db, err = New(dir, true, false); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Put([]byte("key"), []byte("other")); err != errReadOnly {
+		t.Fatalf("unexpected error on read-only write: %v", err)
+	}
+	checkContent(t, db, map[string][]byte{"key": []byte("value")})
+}
+
+// TestRecoverAbortedCommit checks that a crash after the tree pages have been
+// written, but before the meta page has been, leaves the previous state intact.
+func TestRecoverAbortedCommit(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		rng     = rand.New(rand.NewSource(2))
+		content = make(map[string][]byte)
+	)
+	db, err := New(dir, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		randomBatch(t, rng, db, content, 200)
+	}
+	db.failCommit = true
+	for i := 0; i < 5; i++ {
+		batch := db.NewBatch()
+		for j := 0; j < 200; j++ {
+			batch.Put([]byte(fmt.Sprintf("key-%05d", rng.Intn(4000))), []byte("lost"))
+		}
+		if err := batch.Write(); err != errCommitAborted {
+			t.Fatalf("unexpected commit result: %v", err)
+		}
+	}
+	db.Close()
+
+	if db, err = New(dir, false, false); err != nil {
+		t.Fatal(err)
+	}
+	checkContent(t, db, content)
+	checkIntegrity(t, db)
+
+	// The database must remain writable after the recovery
+	randomBatch(t, rng, db, content, 200)
+	checkContent(t, db, content)
+	checkIntegrity(t, db)
+	db.Close()
+}
+
+// TestRecoverTornMeta checks that a corrupted latest meta page, e.g. due to a
+// crash in the middle of writing it, rolls the database back to the previous
+// commit.
+func TestRecoverTornMeta(t *testing.T) {
+	var (
+		dir = t.TempDir()
+		rng = rand.New(rand.NewSource(3))
+	)
+	db, err := New(dir, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := make(map[string][]byte)
+	for i := 0; i < 10; i++ {
+		randomBatch(t, rng, db, content, 200)
+	}
+	previous := make(map[string][]byte)
+	for key, val := range content {
+		previous[key] = val
+	}
+	randomBatch(t, rng, db, content, 200)
+	txid := db.meta.txid
+	db.Close()
+
+	// Tear the latest meta page apart
+	f, err := os.OpenFile(filepath.Join(dir, FileName), os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt([]byte{0xde, 0xad, 0xbe, 0xef}, int64(txid%2)*pageSize+20); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if db, err = New(dir, false, false); err != nil {
+		t.Fatal(err)
+	}
+	if db.meta.txid != txid-1 {
+		t.Fatalf("recovered wrong transaction: have %d, want %d", db.meta.txid, txid-1)
+	}
+	checkContent(t, db, previous)
+	checkIntegrity(t, db)
+
+	randomBatch(t, rng, db, previous, 200)
+	db.Close()
+
+	if db, err = New(dir, false, false); err != nil {
+		t.Fatal(err)
+	}
+	checkContent(t, db, previous)
+	checkIntegrity(t, db)
+	db.Close()
+}
+
+// TestRecoverTruncatedFile checks that a meta page referencing pages lost from
+// the end of the file is rejected in favour of the previous one.
+func TestRecoverTruncatedFile(t *testing.T) {
+	dir := t.TempDir()
+	db, err := New(dir, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := map[string][]byte{"a": []byte("1")}
+	db.Put([]byte("a"), []byte("1"))
+	size := int64(db.meta.pages) * pageSize
+
+	// Append a value spanning a lot of fresh pages
+	db.Put([]byte("b"), make([]byte, 16*pageSize))
+	db.Close()
+
+	if err := os.Truncate(filepath.Join(dir, FileName), size); err != nil {
+		t.Fatal(err)
+	}
+	if db, err = New(dir, false, false); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	checkContent(t, db, content)
+	checkIntegrity(t, db)
+}
+
+func TestFileLock(t *testing.T) {
+	dir := t.TempDir()
+	db, err := New(dir, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if other, err := New(dir, false, false); err == nil {
+		other.Close()
+		t.Fatal("opened locked database twice")
+	}
+}
+
+func TestGroupCommit(t *testing.T) {
+	db, err := New(t.TempDir(), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Hold the write lock until all the writers are queued up, which should
+	// then be committed in a single transaction.
+	var (
+		wg      sync.WaitGroup
+		content = make(map[string][]byte)
+		txid    = db.meta.txid
+	)
+	db.writeLock.Lock()
+	for i := 0; i < 16; i++ {
+		key, val := fmt.Sprintf("key-%02d", i), []byte(fmt.Sprintf("val-%02d", i))
+		content[key] = val
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := db.Put([]byte(key), val); err != nil {
+				t.Errorf("failed to put: %v", err)
+			}
+		}()
+	}
+	for {
+		db.queueLock.Lock()
+		queued := len(db.queue)
+		db.queueLock.Unlock()
+		if queued == len(content) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	db.writeLock.Unlock()
+	wg.Wait()
+
+	if db.meta.txid != txid+1 {
+		t.Fatalf("writes not grouped: %d transactions", db.meta.txid-txid)
+	}
+	checkContent(t, db, content)
+	checkIntegrity(t, db)
+}
+
+func TestCompact(t *testing.T) {
+	dir := t.TempDir()
+	db, err := New(dir, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		rng     = rand.New(rand.NewSource(1))
+		content = make(map[string][]byte)
+	)
+	for round := 0; round < 20; round++ {
+		randomBatch(t, rng, db, content, 500)
+	}
+	// Delete most of the keys, leaving the survivors scattered across the file.
+	batch := db.NewBatch()
+	for key := range content {
+		if rng.Intn(10) != 0 {
+			batch.Delete([]byte(key))
+			delete(content, key)
+		}
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatal(err)
+	}
+	pages := db.meta.pages
+
+	if err := db.Compact(nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if db.meta.pages >= pages/2 {
+		t.Fatalf("database not compacted: %d pages before, %d after", pages, db.meta.pages)
+	}
+	info, err := os.Stat(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != int64(db.meta.pages)*pageSize {
+		t.Fatalf("file not truncated: size %d, pages %d", info.Size(), db.meta.pages)
+	}
+	checkIntegrity(t, db)
+	checkContent(t, db, content)
+
+	// The compacted database should survive a restart.
+	db.Close()
+	if db, err = New(dir, false, true); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	checkContent(t, db, content)
+	checkIntegrity(t, db)
+}
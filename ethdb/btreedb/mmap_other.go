@@ -0,0 +1,41 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package btreedb
+
+import "os"
+
+// mmap is not supported on this platform, pages are read from the file instead.
+func mmap(f *os.File, size int) ([]byte, error) {
+	return nil, nil
+}
+
+// munmap is a no-op on this platform.
+func munmap(data []byte) error {
+	return nil
+}
+
+// flock is a no-op on this platform.
+func flock(f *os.File, readonly bool) error {
+	return nil
+}
+
+// funlock is a no-op on this platform.
+func funlock(f *os.File) error {
+	return nil
+}
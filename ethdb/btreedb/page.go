@@ -0,0 +1,336 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package btreedb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"sort"
+)
+
+// The database file is an array of fixed size pages. The first two pages hold
+// the double-buffered meta records, every other page belongs to a tree node or
+// to the freelist. Nodes larger than a single page span a run of contiguous
+// overflow pages following their first one.
+//
+// Node page layout:
+//
+//	flags (1) | unused (3) | count (4) | overflow (4) | unused (4) | offsets (4 * count) | elements
+//
+// Leaf element:   klen (4) | vlen (4) | key | value
+// Branch element: klen (4) | child (8) | key
+//
+// Freelist page layout:
+//
+//	flags (1) | unused (3) | count (4) | overflow (4) | unused (4) | page ids (8 * count)
+const (
+	pageSize       = 4096
+	pageHeaderSize = 16
+
+	branchPageFlag   = 0x01
+	leafPageFlag     = 0x02
+	freelistPageFlag = 0x04
+
+	leafElemHeader   = 8
+	branchElemHeader = 12
+
+	metaMagic   = 0x6765746862747265 // "gethbtre"
+	metaVersion = 1
+	metaSize    = 52
+)
+
+var (
+	errCorruptMeta = errors.New("corrupted meta page")
+	errCorruptPage = errors.New("corrupted page")
+
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// pgid is the index of a page within the database file.
+type pgid uint64
+
+// meta is the root record of a committed transaction.
+type meta struct {
+	root     pgid   // root node of the tree, 0 if the tree is empty
+	freelist pgid   // first page of the persisted freelist, 0 if none
+	pages    pgid   // high water mark, number of pages in use by the file
+	txid     uint64 // sequence number of the transaction that wrote the meta
+}
+
+// encode serializes the meta record, appending its checksum.
+func (m *meta) encode() []byte {
+	buf := make([]byte, metaSize)
+	binary.LittleEndian.PutUint64(buf[0:], metaMagic)
+	binary.LittleEndian.PutUint32(buf[8:], metaVersion)
+	binary.LittleEndian.PutUint32(buf[12:], pageSize)
+	binary.LittleEndian.PutUint64(buf[16:], uint64(m.root))
+	binary.LittleEndian.PutUint64(buf[24:], uint64(m.freelist))
+	binary.LittleEndian.PutUint64(buf[32:], uint64(m.pages))
+	binary.LittleEndian.PutUint64(buf[40:], m.txid)
+	binary.LittleEndian.PutUint32(buf[48:], crc32.Checksum(buf[:48], crcTable))
+	return buf
+}
+
+// decodeMeta parses and validates a meta record.
+func decodeMeta(buf []byte) (*meta, error) {
+	if len(buf) < metaSize {
+		return nil, errCorruptMeta
+	}
+	if binary.LittleEndian.Uint64(buf[0:]) != metaMagic {
+		return nil, errCorruptMeta
+	}
+	if crc32.Checksum(buf[:48], crcTable) != binary.LittleEndian.Uint32(buf[48:]) {
+		return nil, errCorruptMeta
+	}
+	if binary.LittleEndian.Uint32(buf[8:]) != metaVersion || binary.LittleEndian.Uint32(buf[12:]) != pageSize {
+		return nil, errCorruptMeta
+	}
+	return &meta{
+		root:     pgid(binary.LittleEndian.Uint64(buf[16:])),
+		freelist: pgid(binary.LittleEndian.Uint64(buf[24:])),
+		pages:    pgid(binary.LittleEndian.Uint64(buf[32:])),
+		txid:     binary.LittleEndian.Uint64(buf[40:]),
+	}, nil
+}
+
+// pagesFor returns the number of pages needed to hold size bytes.
+func pagesFor(size int) int {
+	return (size + pageSize - 1) / pageSize
+}
+
+func pageFlags(p []byte) byte   { return p[0] }
+func pageCount(p []byte) int    { return int(binary.LittleEndian.Uint32(p[4:])) }
+func pageOverflow(p []byte) int { return int(binary.LittleEndian.Uint32(p[8:])) }
+func elemOffset(p []byte, i int) int {
+	return int(binary.LittleEndian.Uint32(p[pageHeaderSize+4*i:]))
+}
+
+// leafElem returns the key and value of the i-th element of a leaf page.
+func leafElem(p []byte, i int) ([]byte, []byte) {
+	off := elemOffset(p, i)
+	klen := int(binary.LittleEndian.Uint32(p[off:]))
+	vlen := int(binary.LittleEndian.Uint32(p[off+4:]))
+	key := p[off+leafElemHeader : off+leafElemHeader+klen]
+	return key, p[off+leafElemHeader+klen : off+leafElemHeader+klen+vlen]
+}
+
+// branchElem returns the separator key and child page of the i-th element of
+// a branch page.
+func branchElem(p []byte, i int) ([]byte, pgid) {
+	off := elemOffset(p, i)
+	klen := int(binary.LittleEndian.Uint32(p[off:]))
+	child := pgid(binary.LittleEndian.Uint64(p[off+4:]))
+	return p[off+branchElemHeader : off+branchElemHeader+klen], child
+}
+
+// searchLeaf returns the index of the first element of a leaf page whose key
+// is greater than or equal to the given one.
+func searchLeaf(p []byte, key []byte) int {
+	return sort.Search(pageCount(p), func(i int) bool {
+		k, _ := leafElem(p, i)
+		return bytes.Compare(k, key) >= 0
+	})
+}
+
+// searchBranch returns the index of the child of a branch page which may hold
+// the given key.
+func searchBranch(p []byte, key []byte) int {
+	i := sort.Search(pageCount(p), func(i int) bool {
+		k, _ := branchElem(p, i)
+		return bytes.Compare(k, key) > 0
+	})
+	if i > 0 {
+		i--
+	}
+	return i
+}
+
+// node is the in-memory, mutable form of a tree node used by the writer.
+type node struct {
+	id       pgid // first page the node was loaded from, 0 if not yet stored
+	overflow int  // number of overflow pages of the stored node
+	leaf     bool
+	dirty    bool
+
+	keys [][]byte
+	vals [][]byte // leaf only
+	ids  []pgid   // branch only, child pages
+	kids []*node  // branch only, materialized children (lazily allocated)
+}
+
+// decodeNode materializes a node page. The page is copied out in one piece, so
+// the node stays valid regardless of what happens to the page afterwards.
+func decodeNode(id pgid, p []byte) (*node, error) {
+	flags := pageFlags(p)
+	if flags != leafPageFlag && flags != branchPageFlag {
+		return nil, errCorruptPage
+	}
+	p = bytes.Clone(p)
+	n := &node{
+		id:       id,
+		overflow: pageOverflow(p),
+		leaf:     flags == leafPageFlag,
+	}
+	count := pageCount(p)
+	n.keys = make([][]byte, count)
+	if n.leaf {
+		n.vals = make([][]byte, count)
+		for i := 0; i < count; i++ {
+			n.keys[i], n.vals[i] = leafElem(p, i)
+		}
+	} else {
+		n.ids = make([]pgid, count)
+		for i := 0; i < count; i++ {
+			n.keys[i], n.ids[i] = branchElem(p, i)
+		}
+	}
+	return n, nil
+}
+
+// elemSize returns the encoded size of the i-th element, including its offset.
+func (n *node) elemSize(i int) int {
+	if n.leaf {
+		return 4 + leafElemHeader + len(n.keys[i]) + len(n.vals[i])
+	}
+	return 4 + branchElemHeader + len(n.keys[i])
+}
+
+// size returns the encoded size of the node.
+func (n *node) size() int {
+	size := pageHeaderSize
+	for i := range n.keys {
+		size += n.elemSize(i)
+	}
+	return size
+}
+
+// encode serializes the node into a buffer padded to whole pages.
+func (n *node) encode() []byte {
+	pages := pagesFor(n.size())
+	buf := make([]byte, pages*pageSize)
+	if n.leaf {
+		buf[0] = leafPageFlag
+	} else {
+		buf[0] = branchPageFlag
+	}
+	binary.LittleEndian.PutUint32(buf[4:], uint32(len(n.keys)))
+	binary.LittleEndian.PutUint32(buf[8:], uint32(pages-1))
+
+	off := pageHeaderSize + 4*len(n.keys)
+	for i, key := range n.keys {
+		binary.LittleEndian.PutUint32(buf[pageHeaderSize+4*i:], uint32(off))
+		binary.LittleEndian.PutUint32(buf[off:], uint32(len(key)))
+		if n.leaf {
+			binary.LittleEndian.PutUint32(buf[off+4:], uint32(len(n.vals[i])))
+			off += leafElemHeader
+			off += copy(buf[off:], key)
+			off += copy(buf[off:], n.vals[i])
+		} else {
+			binary.LittleEndian.PutUint64(buf[off+4:], uint64(n.ids[i]))
+			off += branchElemHeader
+			off += copy(buf[off:], key)
+		}
+	}
+	return buf
+}
+
+// search returns the index of the first key greater than or equal to the given
+// one, and whether it matches exactly.
+func (n *node) search(key []byte) (int, bool) {
+	i := sort.Search(len(n.keys), func(i int) bool {
+		return bytes.Compare(n.keys[i], key) >= 0
+	})
+	return i, i < len(n.keys) && bytes.Equal(n.keys[i], key)
+}
+
+// childIndex returns the index of the child of a branch node which may hold
+// the given key.
+func (n *node) childIndex(key []byte) int {
+	i := sort.Search(len(n.keys), func(i int) bool {
+		return bytes.Compare(n.keys[i], key) > 0
+	})
+	if i > 0 {
+		i--
+	}
+	return i
+}
+
+// split breaks up an oversized node into evenly filled pieces that fit into a
+// single page each, unless a single element is larger than that on its own.
+func (n *node) split() []*node {
+	size := n.size()
+	if size <= pageSize || len(n.keys) == 1 {
+		return []*node{n}
+	}
+	var (
+		target = size / pagesFor(size)
+		pieces []*node
+		start  int
+		fill   = pageHeaderSize
+	)
+	for i := range n.keys {
+		elem := n.elemSize(i)
+		if i > start && (fill+elem > pageSize || fill >= target) {
+			pieces = append(pieces, n.slice(start, i))
+			start, fill = i, pageHeaderSize
+		}
+		fill += elem
+	}
+	return append(pieces, n.slice(start, len(n.keys)))
+}
+
+// slice returns a new node holding the elements in the given range.
+func (n *node) slice(start, end int) *node {
+	piece := &node{leaf: n.leaf, dirty: true, keys: n.keys[start:end]}
+	if n.leaf {
+		piece.vals = n.vals[start:end]
+	} else {
+		piece.ids = n.ids[start:end]
+	}
+	return piece
+}
+
+// encodeFreelist serializes a list of free pages into a buffer of the given
+// number of pages.
+func encodeFreelist(ids []pgid, pages int) []byte {
+	buf := make([]byte, pages*pageSize)
+	buf[0] = freelistPageFlag
+	binary.LittleEndian.PutUint32(buf[4:], uint32(len(ids)))
+	binary.LittleEndian.PutUint32(buf[8:], uint32(pages-1))
+	for i, id := range ids {
+		binary.LittleEndian.PutUint64(buf[pageHeaderSize+8*i:], uint64(id))
+	}
+	return buf
+}
+
+// decodeFreelist parses a freelist page.
+func decodeFreelist(p []byte) ([]pgid, error) {
+	if pageFlags(p) != freelistPageFlag {
+		return nil, errCorruptPage
+	}
+	count := pageCount(p)
+	if pageHeaderSize+8*count > len(p) {
+		return nil, errCorruptPage
+	}
+	ids := make([]pgid, count)
+	for i := range ids {
+		ids[i] = pgid(binary.LittleEndian.Uint64(p[pageHeaderSize+8*i:]))
+	}
+	return ids, nil
+}
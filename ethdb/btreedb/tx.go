@@ -0,0 +1,614 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package btreedb
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// mergeThreshold is the encoded size below which a modified node is merged
+	// into one of its siblings.
+	mergeThreshold = pageSize / 4
+
+	// compactLeaves is the maximum number of leaves visited by a compaction
+	// transaction, bounding the nodes materialized in memory at once.
+	compactLeaves = 1024
+)
+
+// errCommitAborted is returned by commits interrupted by the failCommit hook.
+var errCommitAborted = errors.New("commit aborted")
+
+// entry is a reference to a stored node from its parent.
+type entry struct {
+	key []byte
+	id  pgid
+}
+
+// tx is a write transaction. Nodes on the paths touched by the transaction are
+// materialized in memory, modified, and written back to freshly allocated pages
+// on commit. The pages of the replaced nodes are only released for reuse once
+// no reader can access them anymore.
+type tx struct {
+	db    *Database
+	meta  meta
+	root  *node
+	freed []pgid
+}
+
+// writeRequest is a direct write waiting to be committed along with the other
+// ones queued meanwhile.
+type writeRequest struct {
+	op   keyvalue
+	done chan error
+}
+
+// write applies a single operation to the database. The operations issued while
+// another transaction is being committed are queued, and committed together by
+// whichever writer acquires the write lock next, sharing the syncs of the file.
+func (db *Database) write(op keyvalue) error {
+	req := &writeRequest{op: op, done: make(chan error, 1)}
+	db.queueLock.Lock()
+	db.queue = append(db.queue, req)
+	db.queueLock.Unlock()
+
+	db.writeLock.Lock()
+	db.queueLock.Lock()
+	reqs := db.queue
+	db.queue = nil
+	db.queueLock.Unlock()
+
+	// The queue is empty if the request was committed by another writer.
+	if len(reqs) > 0 {
+		ops := make([]keyvalue, len(reqs))
+		for i, req := range reqs {
+			ops[i] = req.op
+		}
+		err := db.commitLocked(ops)
+		if err != nil && len(reqs) > 1 {
+			// Retry the writes one by one, to not fail all of them on the
+			// behalf of a single bad one.
+			for _, req := range reqs {
+				req.done <- db.commitLocked([]keyvalue{req.op})
+			}
+		} else {
+			for _, req := range reqs {
+				req.done <- err
+			}
+		}
+	}
+	db.writeLock.Unlock()
+	return <-req.done
+}
+
+// commit applies the given operations to the database atomically.
+func (db *Database) commit(ops []keyvalue) error {
+	db.writeLock.Lock()
+	defer db.writeLock.Unlock()
+
+	return db.commitLocked(ops)
+}
+
+// commitLocked applies the given operations to the database atomically. It must
+// be called with the write lock held.
+func (db *Database) commitLocked(ops []keyvalue) error {
+	if db.closed {
+		return errClosed
+	}
+	if db.readonly {
+		return errReadOnly
+	}
+	db.reclaim()
+
+	t := &tx{db: db, meta: db.meta}
+	if t.meta.root != 0 {
+		root, err := t.load(t.meta.root)
+		if err != nil {
+			return err
+		}
+		t.root = root
+	}
+	for _, op := range ops {
+		var err error
+		if op.delete {
+			if t.root != nil {
+				_, err = t.delete(t.root, op.key)
+			}
+		} else {
+			if t.root == nil {
+				t.root = &node{leaf: true}
+			}
+			_, err = t.put(t.root, op.key, op.value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return t.commit()
+}
+
+// commit writes out the modified nodes of the transaction and publishes the new
+// tree.
+func (t *tx) commit() error {
+	if t.root == nil || !t.root.dirty {
+		return nil
+	}
+	if err := t.rebalance(t.root); err != nil {
+		return err
+	}
+	// Shrink the tree if the root became redundant
+	for !t.root.leaf && len(t.root.ids) == 1 {
+		child, err := t.child(t.root, 0)
+		if err != nil {
+			return err
+		}
+		t.free(t.root)
+		t.root = child
+	}
+	// Write out all modified nodes, growing the tree if the root got split
+	entries, changed, err := t.spill(t.root)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		t.meta.root = t.root.id
+	} else {
+		for len(entries) > 1 {
+			root := &node{dirty: true}
+			for _, e := range entries {
+				root.keys = append(root.keys, e.key)
+				root.ids = append(root.ids, e.id)
+			}
+			if entries, _, err = t.spill(root); err != nil {
+				return err
+			}
+		}
+		t.meta.root = 0
+		if len(entries) == 1 {
+			t.meta.root = entries[0].id
+		}
+	}
+	return t.publish()
+}
+
+// compactRange rewrites the nodes holding the keys from start until limit which
+// can be moved to lower pages or are underfull, see Compact. At most
+// compactLeaves leaves are visited, the key to continue from is returned if the
+// range is not finished yet.
+func (db *Database) compactRange(start, limit []byte) ([]byte, error) {
+	db.writeLock.Lock()
+	defer db.writeLock.Unlock()
+
+	if db.closed {
+		return nil, errClosed
+	}
+	if db.readonly {
+		return nil, errReadOnly
+	}
+	db.reclaim()
+
+	t := &tx{db: db, meta: db.meta}
+	if t.meta.root == 0 {
+		return nil, nil
+	}
+	root, err := t.load(t.meta.root)
+	if err != nil {
+		return nil, err
+	}
+	t.root = root
+
+	budget := compactLeaves
+	next, err := t.touch(t.root, start, limit, &budget)
+	if err != nil {
+		return nil, err
+	}
+	return next, t.commit()
+}
+
+// touch marks the nodes of the subtree of n within the key range dirty if they
+// are worth rewriting, either as a free page is available below them, or they
+// are small enough to be merged into a sibling. It returns the key to continue
+// from once the leaf budget is exhausted.
+func (t *tx) touch(n *node, start, limit []byte, budget *int) ([]byte, error) {
+	if n.leaf {
+		*budget--
+	} else {
+		first := 0
+		if start != nil {
+			first = n.childIndex(start)
+		}
+		for i := first; i < len(n.ids); i++ {
+			if limit != nil && i > first && bytes.Compare(n.keys[i], limit) >= 0 {
+				break
+			}
+			if *budget <= 0 {
+				return n.keys[i], nil
+			}
+			child, err := t.child(n, i)
+			if err != nil {
+				return nil, err
+			}
+			next, err := t.touch(child, start, limit, budget)
+			if err != nil {
+				return nil, err
+			}
+			if child.dirty {
+				n.dirty = true
+			}
+			if next != nil {
+				return next, nil
+			}
+		}
+	}
+	if (len(t.db.free) > 0 && t.db.free[0] < n.id) || n.size() < mergeThreshold {
+		n.dirty = true
+	}
+	return nil, nil
+}
+
+// shrink truncates the free pages off the end of the file.
+func (db *Database) shrink() error {
+	db.writeLock.Lock()
+	defer db.writeLock.Unlock()
+
+	if db.closed {
+		return errClosed
+	}
+	if db.readonly {
+		return errReadOnly
+	}
+	db.reclaim()
+
+	t := &tx{db: db, meta: db.meta}
+	free := db.free
+	for len(free) > 0 && free[len(free)-1] == t.meta.pages-1 {
+		free = free[:len(free)-1]
+		t.meta.pages--
+	}
+	if len(free) == len(db.free) {
+		return nil
+	}
+	db.free = free
+	if err := t.publish(); err != nil {
+		return err
+	}
+	// The pages past the end are referenced neither by the published tree nor
+	// by any pinned one, as they were free.
+	size := int64(db.meta.pages) * pageSize
+	if err := db.file.Truncate(size); err != nil {
+		return err
+	}
+	db.log.Info("Shrunk database file", "size", common.StorageSize(size))
+	return nil
+}
+
+// reclaim moves the pages freed by past transactions into the freelist, once
+// all readers that could access them have finished.
+func (db *Database) reclaim() {
+	db.readLock.Lock()
+	defer db.readLock.Unlock()
+
+	oldest := db.meta.txid + 1
+	for txid := range db.readers {
+		oldest = min(oldest, txid)
+	}
+	// Pages freed by transaction N were last reachable from snapshot N-1
+	var reclaimed bool
+	for txid, ids := range db.pending {
+		if txid <= oldest {
+			db.free = append(db.free, ids...)
+			delete(db.pending, txid)
+			reclaimed = true
+		}
+	}
+	if reclaimed {
+		slices.Sort(db.free)
+	}
+}
+
+// load materializes the node stored at the given page.
+func (t *tx) load(id pgid) (*node, error) {
+	t.db.lock.RLock()
+	defer t.db.lock.RUnlock()
+
+	p, err := t.db.page(id)
+	if err != nil {
+		return nil, err
+	}
+	return decodeNode(id, p)
+}
+
+// child returns the i-th child of a branch node, materializing it if needed.
+func (t *tx) child(n *node, i int) (*node, error) {
+	if n.kids == nil {
+		n.kids = make([]*node, len(n.ids))
+	}
+	if n.kids[i] == nil {
+		child, err := t.load(n.ids[i])
+		if err != nil {
+			return nil, err
+		}
+		n.kids[i] = child
+	}
+	return n.kids[i], nil
+}
+
+// put inserts or updates the key in the subtree of n, returning whether the
+// subtree was modified.
+func (t *tx) put(n *node, key, value []byte) (bool, error) {
+	if n.leaf {
+		i, found := n.search(key)
+		if found {
+			if string(n.vals[i]) == string(value) {
+				return false, nil
+			}
+			n.vals[i] = value
+		} else {
+			n.keys = slices.Insert(n.keys, i, key)
+			n.vals = slices.Insert(n.vals, i, value)
+		}
+		n.dirty = true
+		return true, nil
+	}
+	child, err := t.child(n, n.childIndex(key))
+	if err != nil {
+		return false, err
+	}
+	changed, err := t.put(child, key, value)
+	if changed {
+		n.dirty = true
+	}
+	return changed, err
+}
+
+// delete removes the key from the subtree of n, returning whether the subtree
+// was modified.
+func (t *tx) delete(n *node, key []byte) (bool, error) {
+	if n.leaf {
+		i, found := n.search(key)
+		if !found {
+			return false, nil
+		}
+		n.keys = slices.Delete(n.keys, i, i+1)
+		n.vals = slices.Delete(n.vals, i, i+1)
+		n.dirty = true
+		return true, nil
+	}
+	child, err := t.child(n, n.childIndex(key))
+	if err != nil {
+		return false, err
+	}
+	changed, err := t.delete(child, key)
+	if changed {
+		n.dirty = true
+	}
+	return changed, err
+}
+
+// rebalance merges the modified, underfull children of a branch node into
+// their siblings and drops the empty ones.
+func (t *tx) rebalance(n *node) error {
+	if n.leaf {
+		return nil
+	}
+	for _, child := range n.kids {
+		if child != nil && child.dirty {
+			if err := t.rebalance(child); err != nil {
+				return err
+			}
+		}
+	}
+	for i := 0; i < len(n.ids); {
+		var child *node
+		if n.kids != nil {
+			child = n.kids[i]
+		}
+		if child == nil || !child.dirty || child.size() >= mergeThreshold || len(n.ids) == 1 {
+			i++
+			continue
+		}
+		if len(child.keys) == 0 {
+			t.free(child)
+			n.remove(i)
+			continue
+		}
+		left := i
+		if i == len(n.ids)-1 {
+			left = i - 1
+		}
+		if err := t.merge(n, left); err != nil {
+			return err
+		}
+		i = left
+	}
+	return nil
+}
+
+// merge folds the child right of the given index into the one at the index.
+func (t *tx) merge(n *node, left int) error {
+	l, err := t.child(n, left)
+	if err != nil {
+		return err
+	}
+	r, err := t.child(n, left+1)
+	if err != nil {
+		return err
+	}
+	l.keys = append(l.keys, r.keys...)
+	if l.leaf {
+		l.vals = append(l.vals, r.vals...)
+	} else {
+		if l.kids == nil {
+			l.kids = make([]*node, len(l.ids))
+		}
+		if r.kids == nil {
+			r.kids = make([]*node, len(r.ids))
+		}
+		l.ids = append(l.ids, r.ids...)
+		l.kids = append(l.kids, r.kids...)
+	}
+	l.dirty = true
+	t.free(r)
+	n.remove(left + 1)
+	return nil
+}
+
+// remove drops the i-th child of a branch node.
+func (n *node) remove(i int) {
+	n.keys = slices.Delete(n.keys, i, i+1)
+	n.ids = slices.Delete(n.ids, i, i+1)
+	if n.kids != nil {
+		n.kids = slices.Delete(n.kids, i, i+1)
+	}
+	n.dirty = true
+}
+
+// free schedules the pages of a stored node for release.
+func (t *tx) free(n *node) {
+	if n.id == 0 {
+		return
+	}
+	for i := 0; i <= n.overflow; i++ {
+		t.freed = append(t.freed, n.id+pgid(i))
+	}
+	n.id, n.overflow = 0, 0
+}
+
+// spill writes a modified subtree to freshly allocated pages. It returns the
+// references to the node pieces replacing n in its parent, and whether n was
+// modified at all.
+func (t *tx) spill(n *node) ([]entry, bool, error) {
+	if !n.dirty {
+		return nil, false, nil
+	}
+	if !n.leaf {
+		var (
+			keys = make([][]byte, 0, len(n.keys))
+			ids  = make([]pgid, 0, len(n.ids))
+		)
+		for i := range n.ids {
+			if n.kids != nil && n.kids[i] != nil {
+				entries, changed, err := t.spill(n.kids[i])
+				if err != nil {
+					return nil, false, err
+				}
+				if changed {
+					for _, e := range entries {
+						keys = append(keys, e.key)
+						ids = append(ids, e.id)
+					}
+					continue
+				}
+			}
+			keys = append(keys, n.keys[i])
+			ids = append(ids, n.ids[i])
+		}
+		n.keys, n.ids, n.kids = keys, ids, nil
+	}
+	t.free(n)
+	if len(n.keys) == 0 {
+		return nil, true, nil
+	}
+	var entries []entry
+	for _, piece := range n.split() {
+		buf := piece.encode()
+		id := t.allocate(len(buf) / pageSize)
+		if _, err := t.db.file.WriteAt(buf, int64(id)*pageSize); err != nil {
+			return nil, false, err
+		}
+		entries = append(entries, entry{key: piece.keys[0], id: id})
+	}
+	return entries, true, nil
+}
+
+// allocate reserves a run of contiguous pages, reusing free ones if possible
+// and growing the file otherwise.
+func (t *tx) allocate(count int) pgid {
+	free := t.db.free
+	for i := 0; i+count <= len(free); i++ {
+		if free[i+count-1] == free[i]+pgid(count-1) {
+			id := free[i]
+			t.db.free = slices.Delete(free, i, i+count)
+			return id
+		}
+	}
+	id := t.meta.pages
+	t.meta.pages += pgid(count)
+	return id
+}
+
+// publish persists the freelist and the new meta page, making the transaction
+// visible to readers and durable.
+func (t *tx) publish() error {
+	db := t.db
+	if t.meta.freelist != 0 {
+		for i := 0; i < db.freelistPages; i++ {
+			t.freed = append(t.freed, t.meta.freelist+pgid(i))
+		}
+	}
+	// Pages awaiting readers are free for good after a restart, so persist
+	// them along with the actually free ones.
+	db.readLock.Lock()
+	var pending []pgid
+	for _, ids := range db.pending {
+		pending = append(pending, ids...)
+	}
+	db.readLock.Unlock()
+	pending = append(pending, t.freed...)
+
+	pages := pagesFor(pageHeaderSize + 8*(len(db.free)+len(pending)))
+	t.meta.freelist = t.allocate(pages)
+
+	ids := append(slices.Clone(db.free), pending...)
+	slices.Sort(ids)
+	if _, err := db.file.WriteAt(encodeFreelist(ids, pages), int64(t.meta.freelist)*pageSize); err != nil {
+		return err
+	}
+	if err := db.sync(); err != nil {
+		return err
+	}
+	if db.failCommit {
+		return errCommitAborted
+	}
+	t.meta.txid++
+	if _, err := db.file.WriteAt(t.meta.encode(), int64(t.meta.txid%2)*pageSize); err != nil {
+		return err
+	}
+	if err := db.sync(); err != nil {
+		return err
+	}
+	db.freelistPages = pages
+
+	// Grow the mapping before publishing the new tree to readers
+	db.lock.Lock()
+	err := db.remap(int(t.meta.pages) * pageSize)
+	db.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	db.readLock.Lock()
+	db.meta = t.meta
+	if len(t.freed) > 0 {
+		db.pending[t.meta.txid] = t.freed
+	}
+	db.readLock.Unlock()
+	return nil
+}
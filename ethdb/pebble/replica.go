@@ -0,0 +1,538 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pebble
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/bloom"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Replica is a read-only key-value store following a pebble database which is
+// opened by another process, the primary.
+//
+// Pebble has no notion of secondary instances. The replica instead opens the
+// primary's files in read-only mode, which gives a point-in-time view on the
+// flushed tables and the write-ahead log. The writes appended to the log since
+// are followed incrementally by CatchUp, tailing the log into an in-memory
+// overlay on top of the view. The database is only reopened when the primary
+// rotates its log or updates its manifest, i.e. after flushes and compactions.
+//
+// The primary deletes the obsolete files after compactions, the reads against
+// a stale view might fail until the next catch-up.
+type Replica struct {
+	fn      string
+	cache   *pebble.Cache
+	handles int
+
+	view   atomic.Pointer[replicaView] // Current view on the primary, nil if closed
+	lock   sync.Mutex                  // Lock serializing the catch-ups and closing
+	closed bool
+	wg     sync.WaitGroup // Tracks the releasing stale views
+	log    log.Logger
+}
+
+// replicaView is a view on the primary database, consisting of the tables and
+// the log replayed at the time of opening, and the overlay of the writes tailed
+// from the log since.
+type replicaView struct {
+	db      *Database
+	overlay *replicaOverlay
+	tail    *walTail // Tail of the newest log of the primary, nil if there is none
+
+	manifest     string // Manifest of the primary the view was opened from
+	manifestSize int64  // Size of the manifest the view was opened from
+	stale        bool   // Flag whether the view has to be reopened on the next catch-up
+
+	lock   sync.RWMutex // Held for reading by the ongoing accesses
+	closed bool
+}
+
+// release waits until all the accesses to the view are finished and closes it.
+func (v *replicaView) release() error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	v.closed = true
+	return v.db.Close()
+}
+
+// NewReplica opens the pebble database at the given path as a replica.
+func NewReplica(file string, cache int, handles int) (*Replica, error) {
+	if cache < minCache {
+		cache = minCache
+	}
+	if handles < minHandles {
+		handles = minHandles
+	}
+	logger := log.New("database", file)
+	logger.Info("Allocated cache and file handles", "cache", common.StorageSize(cache*1024*1024), "handles", handles, "replica", true)
+
+	r := &Replica{
+		fn:      file,
+		cache:   pebble.NewCache(int64(cache * 1024 * 1024)),
+		handles: handles,
+		log:     logger,
+	}
+	view, err := r.open()
+	if err != nil {
+		r.cache.Unref()
+		return nil, err
+	}
+	r.view.Store(view)
+	return r, nil
+}
+
+// open opens a new read-only view on the primary database, and positions the
+// tail of the newest log right after the writes replayed into the view.
+func (r *Replica) open() (*replicaView, error) {
+	manifest, manifestSize, err := currentManifest(r.fn)
+	if err != nil {
+		return nil, err
+	}
+	fs := newReplicaFS(vfs.Default)
+	opt := &pebble.Options{
+		Cache:        r.cache,
+		MaxOpenFiles: r.handles,
+		Levels: []pebble.LevelOptions{
+			{FilterPolicy: bloom.FilterPolicy(10)},
+		},
+		ReadOnly: true,
+		FS:       fs,
+		Logger:   panicLogger{},
+	}
+	db, err := pebble.Open(r.fn, opt)
+	if err != nil {
+		return nil, err
+	}
+	view := &replicaView{
+		db:           &Database{fn: r.fn, db: db, log: r.log, writeOptions: pebble.Sync},
+		overlay:      newReplicaOverlay(),
+		manifest:     manifest,
+		manifestSize: manifestSize,
+	}
+	num, path, err := newestLog(r.fn)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if path != "" {
+		view.tail = &walTail{num: num, path: path}
+		replayed, ok := fs.replayed(path)
+		switch {
+		case ok:
+			// Skip the writes already replayed into the view.
+			if err := view.tail.next(replayed, nil); err != nil {
+				db.Close()
+				return nil, err
+			}
+		case fs.replays() > 0:
+			// The log was created after the older ones were replayed, whose
+			// remaining writes are lost for the view. Reopen on the next
+			// catch-up, it is most likely a flush in progress anyway.
+			view.stale = true
+		}
+	}
+	return view, nil
+}
+
+// changed reports whether the view needs to be reopened to follow the primary,
+// i.e. the primary updated its manifest or rotated its log.
+func (v *replicaView) changed(dir string) (bool, error) {
+	if v.stale {
+		return true, nil
+	}
+	manifest, size, err := currentManifest(dir)
+	if err != nil {
+		return false, err
+	}
+	if manifest != v.manifest || size != v.manifestSize {
+		return true, nil
+	}
+	num, _, err := newestLog(dir)
+	if err != nil {
+		return false, err
+	}
+	if v.tail == nil {
+		return num != 0, nil
+	}
+	return num != v.tail.num, nil
+}
+
+// CatchUp follows the writes of the primary since the last catch-up. The writes
+// appended to the log are applied to the overlay of the current view, which is
+// only replaced by reopening the database if the primary flushed or compacted
+// its tables meanwhile. The stale view is released in the background once its
+// accesses, e.g. the iterators, are finished.
+func (r *Replica) CatchUp() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.closed {
+		return pebble.ErrClosed
+	}
+	view := r.view.Load()
+	changed, err := view.changed(r.fn)
+	if err != nil {
+		return err
+	}
+	if !changed && view.tail != nil {
+		err := view.tail.next(-1, view.overlay.apply)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, errUnsupportedRecord) {
+			return err
+		}
+		// The overlay can't represent the write, fall back to reopening.
+		view.stale = true
+	}
+	if !changed && !view.stale {
+		return nil
+	}
+	fresh, err := r.open()
+	if err != nil {
+		return err
+	}
+	stale := r.view.Swap(fresh)
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		if err := stale.release(); err != nil {
+			r.log.Warn("Failed to release replica view", "err", err)
+		}
+	}()
+	return nil
+}
+
+// acquire retrieves the current view, holding it open until released.
+func (r *Replica) acquire() (*replicaView, error) {
+	for {
+		view := r.view.Load()
+		if view == nil {
+			return nil, pebble.ErrClosed
+		}
+		view.lock.RLock()
+		if !view.closed {
+			return view, nil
+		}
+		// The view is swapped out and released meanwhile, retry with the
+		// current one.
+		view.lock.RUnlock()
+	}
+}
+
+// Close releases the view on the primary database.
+func (r *Replica) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+
+	err := r.view.Swap(nil).release()
+	r.wg.Wait()
+	r.cache.Unref()
+	return err
+}
+
+// Has retrieves if a key is present in the key-value store.
+func (r *Replica) Has(key []byte) (bool, error) {
+	view, err := r.acquire()
+	if err != nil {
+		return false, err
+	}
+	defer view.lock.RUnlock()
+
+	if value, ok := view.overlay.get(key); ok {
+		return value != nil, nil
+	}
+	return view.db.Has(key)
+}
+
+// Get retrieves the given key if it's present in the key-value store.
+func (r *Replica) Get(key []byte) ([]byte, error) {
+	view, err := r.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer view.lock.RUnlock()
+
+	if value, ok := view.overlay.get(key); ok {
+		if value == nil {
+			return nil, pebble.ErrNotFound
+		}
+		return common.CopyBytes(value), nil
+	}
+	return view.db.Get(key)
+}
+
+// Put is not supported by the replica.
+func (r *Replica) Put(key []byte, value []byte) error {
+	return pebble.ErrReadOnly
+}
+
+// Delete is not supported by the replica.
+func (r *Replica) Delete(key []byte) error {
+	return pebble.ErrReadOnly
+}
+
+// NewBatch creates a batch which can't be written into the replica.
+func (r *Replica) NewBatch() ethdb.Batch {
+	return new(replicaBatch)
+}
+
+// NewBatchWithSize creates a batch which can't be written into the replica.
+func (r *Replica) NewBatchWithSize(size int) ethdb.Batch {
+	return new(replicaBatch)
+}
+
+// NewIterator creates a binary-alphabetical iterator over a subset of database
+// content with a particular key prefix, starting at a particular initial key.
+// The iterator holds the current view open until released, and doesn't see the
+// writes applied to the overlay after its creation.
+func (r *Replica) NewIterator(prefix []byte, start []byte) ethdb.Iterator {
+	view, err := r.acquire()
+	if err != nil {
+		return &replicaIterator{err: err}
+	}
+	keys, values := view.overlay.snapshot(prefix, start)
+	return &replicaIterator{
+		disk:    view.db.NewIterator(prefix, start),
+		pending: true,
+		keys:    keys,
+		values:  values,
+		view:    view,
+	}
+}
+
+// Stat returns the internal metrics of the current view.
+func (r *Replica) Stat() (string, error) {
+	view, err := r.acquire()
+	if err != nil {
+		return "", err
+	}
+	defer view.lock.RUnlock()
+	return view.db.Stat()
+}
+
+// Compact is not supported by the replica.
+func (r *Replica) Compact(start []byte, limit []byte) error {
+	return pebble.ErrReadOnly
+}
+
+// Path returns the path to the database directory.
+func (r *Replica) Path() string {
+	return r.fn
+}
+
+// replicaIterator is an iterator over a replica view, merging the tables of the
+// view with a snapshot of its overlay, and releasing the view along with the
+// iterator.
+type replicaIterator struct {
+	disk    ethdb.Iterator // Iterator over the tables of the view
+	diskOk  bool           // Flag whether the disk iterator is positioned on an item
+	pending bool           // Flag whether the disk iterator needs to be advanced
+
+	keys   []string // Sorted keys of the overlay snapshot
+	values [][]byte // Values of the overlay snapshot, nil for deletions
+	pos    int      // Position of the next overlay item
+
+	key, value []byte
+	view       *replicaView
+	err        error
+}
+
+func (it *replicaIterator) Next() bool {
+	if it.disk == nil {
+		return false
+	}
+	for {
+		if it.pending {
+			it.diskOk, it.pending = it.disk.Next(), false
+		}
+		overlayOk := it.pos < len(it.keys)
+		if !it.diskOk && !overlayOk {
+			it.key, it.value = nil, nil
+			return false
+		}
+		var cmp int
+		switch {
+		case !overlayOk:
+			cmp = -1
+		case !it.diskOk:
+			cmp = 1
+		default:
+			cmp = bytes.Compare(it.disk.Key(), []byte(it.keys[it.pos]))
+		}
+		if cmp < 0 {
+			it.key, it.value, it.pending = it.disk.Key(), it.disk.Value(), true
+			return true
+		}
+		// The overlay shadows the item of the tables with the same key.
+		if cmp == 0 {
+			it.pending = true
+		}
+		key, value := it.keys[it.pos], it.values[it.pos]
+		it.pos++
+		if value == nil {
+			continue
+		}
+		it.key, it.value = []byte(key), value
+		return true
+	}
+}
+
+func (it *replicaIterator) Error() error {
+	if it.disk == nil {
+		return it.err
+	}
+	return it.disk.Error()
+}
+
+func (it *replicaIterator) Key() []byte {
+	return it.key
+}
+
+func (it *replicaIterator) Value() []byte {
+	return it.value
+}
+
+func (it *replicaIterator) Release() {
+	if it.disk == nil {
+		return
+	}
+	it.disk.Release()
+	it.disk, it.key, it.value = nil, nil, nil
+	it.view.lock.RUnlock()
+}
+
+// replicaBatch is a batch which fails to be written into the replica.
+type replicaBatch struct {
+	size int
+}
+
+func (b *replicaBatch) Put(key, value []byte) error {
+	b.size += len(key) + len(value)
+	return nil
+}
+
+func (b *replicaBatch) Delete(key []byte) error {
+	b.size += len(key)
+	return nil
+}
+
+func (b *replicaBatch) ValueSize() int {
+	return b.size
+}
+
+func (b *replicaBatch) Write() error {
+	return pebble.ErrReadOnly
+}
+
+func (b *replicaBatch) Reset() {
+	b.size = 0
+}
+
+func (b *replicaBatch) Replay(w ethdb.KeyValueWriter) error {
+	return nil
+}
+
+// replicaFS is the file system of the replica. Pebble acquires the directory
+// lock even in read-only mode, which is held by the primary, so the replica
+// opens the database without it. The logs are capped at their size at the time
+// they are opened, so that the position the log is replayed up to is known and
+// the tail can continue from there.
+type replicaFS struct {
+	vfs.FS
+
+	logs map[string]int64 // Replayed logs and their sizes at the time of opening
+	lock sync.Mutex
+}
+
+func newReplicaFS(fs vfs.FS) *replicaFS {
+	return &replicaFS{FS: fs, logs: make(map[string]int64)}
+}
+
+// Lock implements vfs.FS, returning a no-op lock.
+func (*replicaFS) Lock(name string) (io.Closer, error) {
+	return io.NopCloser(nil), nil
+}
+
+// Open implements vfs.FS, capping the logs at their current size.
+func (fs *replicaFS) Open(name string, opts ...vfs.OpenOption) (vfs.File, error) {
+	f, err := fs.FS.Open(name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := parseLogName(fs.PathBase(name)); !ok {
+		return f, nil
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	fs.lock.Lock()
+	fs.logs[name] = info.Size()
+	fs.lock.Unlock()
+
+	return &cappedFile{File: f, r: io.NewSectionReader(f, 0, info.Size())}, nil
+}
+
+// replayed returns the size the given log was capped at when it was replayed.
+func (fs *replicaFS) replayed(name string) (int64, bool) {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+
+	size, ok := fs.logs[name]
+	return size, ok
+}
+
+// replays returns the number of logs replayed.
+func (fs *replicaFS) replays() int {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+
+	return len(fs.logs)
+}
+
+// cappedFile is a file only readable up to a fixed size.
+type cappedFile struct {
+	vfs.File
+	r *io.SectionReader
+}
+
+func (f *cappedFile) Read(p []byte) (int, error) {
+	return f.r.Read(p)
+}
+
+func (f *cappedFile) ReadAt(p []byte, off int64) (int, error) {
+	return f.r.ReadAt(p, off)
+}
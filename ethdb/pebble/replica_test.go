@@ -0,0 +1,139 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pebble
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReplicaCatchUp(t *testing.T) {
+	dir := t.TempDir()
+	primary, err := New(dir, 16, 16, "", false, false)
+	if err != nil {
+		t.Fatalf("Failed to open primary: %v", err)
+	}
+	defer primary.Close()
+
+	if err := primary.Put([]byte("a"), []byte("1")); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	replica, err := NewReplica(dir, 16, 16)
+	if err != nil {
+		t.Fatalf("Failed to open replica: %v", err)
+	}
+	defer replica.Close()
+
+	if val, err := replica.Get([]byte("a")); err != nil || !bytes.Equal(val, []byte("1")) {
+		t.Fatalf("Unexpected value: %x, %v", val, err)
+	}
+	if err := replica.Put([]byte("b"), []byte("2")); err == nil {
+		t.Fatal("Write into replica is not rejected")
+	}
+	if err := replica.NewBatch().Write(); err == nil {
+		t.Fatal("Batch write into replica is not rejected")
+	}
+	// The writes of the primary are only visible after catching up, while the
+	// iterator keeps the stale view.
+	if err := primary.Put([]byte("b"), []byte("2")); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	if ok, _ := replica.Has([]byte("b")); ok {
+		t.Fatal("Unexpected key before catching up")
+	}
+	it := replica.NewIterator(nil, nil)
+	if err := replica.CatchUp(); err != nil {
+		t.Fatalf("Failed to catch up: %v", err)
+	}
+	if val, err := replica.Get([]byte("b")); err != nil || !bytes.Equal(val, []byte("2")) {
+		t.Fatalf("Unexpected value: %x, %v", val, err)
+	}
+	var keys int
+	for it.Next() {
+		keys++
+	}
+	it.Release()
+	if keys != 1 {
+		t.Fatalf("Unexpected keys in the stale view: %d", keys)
+	}
+}
+
+func TestReplicaTailing(t *testing.T) {
+	dir := t.TempDir()
+	primary, err := New(dir, 16, 16, "", false, false)
+	if err != nil {
+		t.Fatalf("Failed to open primary: %v", err)
+	}
+	defer primary.Close()
+
+	for _, key := range []string{"a", "c", "e"} {
+		if err := primary.Put([]byte(key), []byte(key)); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+	}
+	replica, err := NewReplica(dir, 16, 16)
+	if err != nil {
+		t.Fatalf("Failed to open replica: %v", err)
+	}
+	defer replica.Close()
+
+	// The writes appended to the log are followed without reopening the view.
+	view := replica.view.Load()
+	primary.Put([]byte("b"), []byte("b"))
+	primary.Put([]byte("c"), []byte("C"))
+	primary.Delete([]byte("e"))
+	if err := replica.CatchUp(); err != nil {
+		t.Fatalf("Failed to catch up: %v", err)
+	}
+	if replica.view.Load() != view {
+		t.Fatal("View reopened without flushes")
+	}
+	if val, err := replica.Get([]byte("c")); err != nil || !bytes.Equal(val, []byte("C")) {
+		t.Fatalf("Unexpected value: %x, %v", val, err)
+	}
+	if ok, _ := replica.Has([]byte("e")); ok {
+		t.Fatal("Deleted key still present")
+	}
+	var (
+		it   = replica.NewIterator(nil, nil)
+		have []string
+	)
+	for it.Next() {
+		have = append(have, string(it.Key())+"="+string(it.Value()))
+	}
+	it.Release()
+	if want := []string{"a=a", "b=b", "c=C"}; strings.Join(have, ",") != strings.Join(want, ",") {
+		t.Fatalf("Unexpected iteration: have %v, want %v", have, want)
+	}
+	// Flushing the primary updates its manifest, reopening the view.
+	if err := primary.db.Flush(); err != nil {
+		t.Fatalf("Failed to flush: %v", err)
+	}
+	primary.Put([]byte("d"), []byte("d"))
+	if err := replica.CatchUp(); err != nil {
+		t.Fatalf("Failed to catch up: %v", err)
+	}
+	if replica.view.Load() == view {
+		t.Fatal("View not reopened after flush")
+	}
+	for key, want := range map[string]string{"a": "a", "b": "b", "c": "C", "d": "d"} {
+		if val, err := replica.Get([]byte(key)); err != nil || string(val) != want {
+			t.Fatalf("Unexpected value of %s: %s, %v", key, val, err)
+		}
+	}
+}
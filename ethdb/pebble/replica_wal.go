@@ -0,0 +1,239 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pebble
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/record"
+	"github.com/cockroachdb/pebble/vfs"
+)
+
+const (
+	// walBlockSize is the size of the blocks the records of the log are
+	// chunked into.
+	walBlockSize = 32 * 1024
+
+	// walBatchHeaderLen is the length of the header of the batches stored as
+	// the records of the log: the sequence number and the number of writes.
+	walBatchHeaderLen = 12
+)
+
+// errUnsupportedRecord is returned if a write tailed from the log can't be
+// applied to the overlay of a replica view, e.g. a range deletion.
+var errUnsupportedRecord = errors.New("unsupported log record")
+
+// walTail tracks the position of a replica within a log of the primary.
+type walTail struct {
+	num    uint64 // Number of the log file
+	path   string // Path of the log file
+	offset int64  // Offset after the last record processed
+	seq    uint64 // Highest sequence number processed
+}
+
+// next reads the records appended to the log since the last call, up to the
+// given limit if it's not negative, and passes them to the callback. A record
+// being written by the primary terminates the reading, it is retried on the next
+// call. The records are only skipped if no callback is given.
+func (t *walTail) next(limit int64, fn func([]byte) error) error {
+	f, err := vfs.Default.Open(t.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// The reader is restarted from the beginning of the block containing the
+	// current position, the records processed already are skipped by their
+	// sequence numbers.
+	start := t.offset - t.offset%walBlockSize
+	size := int64(math.MaxInt64 - start)
+	if limit >= 0 {
+		size = limit - start
+	}
+	reader := record.NewReader(io.NewSectionReader(f, start, size), pebble.FileNum(t.num))
+	for {
+		rec, err := reader.Next()
+		if err != nil {
+			if err == io.EOF || record.IsInvalidRecord(err) {
+				return nil
+			}
+			return err
+		}
+		data, err := io.ReadAll(rec)
+		if err != nil {
+			if record.IsInvalidRecord(err) {
+				return nil
+			}
+			return err
+		}
+		if len(data) < walBatchHeaderLen {
+			return fmt.Errorf("invalid batch in log %d: length %d", t.num, len(data))
+		}
+		seq := binary.LittleEndian.Uint64(data)
+		count := uint64(binary.LittleEndian.Uint32(data[8:]))
+		if seq > t.seq && count > 0 {
+			if fn != nil {
+				if err := fn(data); err != nil {
+					return err
+				}
+			}
+			t.seq = seq + count - 1
+		}
+		t.offset = start + reader.Offset()
+	}
+}
+
+// replicaOverlay is an in-memory set of the writes tailed from the log of the
+// primary since its view was opened.
+type replicaOverlay struct {
+	items map[string][]byte // Written items, nil values for deletions
+	lock  sync.RWMutex
+}
+
+func newReplicaOverlay() *replicaOverlay {
+	return &replicaOverlay{items: make(map[string][]byte)}
+}
+
+// get retrieves the written value of the key, nil if it's deleted. The returned
+// slice is never modified.
+func (o *replicaOverlay) get(key []byte) ([]byte, bool) {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	value, ok := o.items[string(key)]
+	return value, ok
+}
+
+// apply applies the writes of a batch read from the log atomically.
+func (o *replicaOverlay) apply(batch []byte) error {
+	type write struct {
+		key   string
+		value []byte
+	}
+	var (
+		writes    []write
+		reader, _ = pebble.ReadBatch(batch)
+	)
+	for {
+		kind, key, value, ok, err := reader.Next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		switch kind {
+		case pebble.InternalKeyKindSet:
+			writes = append(writes, write{string(key), append([]byte{}, value...)})
+		case pebble.InternalKeyKindDelete, pebble.InternalKeyKindSingleDelete:
+			writes = append(writes, write{string(key), nil})
+		case pebble.InternalKeyKindLogData:
+		default:
+			return fmt.Errorf("%w: kind %v", errUnsupportedRecord, kind)
+		}
+	}
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	for _, w := range writes {
+		o.items[w.key] = w.value
+	}
+	return nil
+}
+
+// snapshot returns the sorted items of the overlay with the given prefix, at or
+// after the given start.
+func (o *replicaOverlay) snapshot(prefix []byte, start []byte) ([]string, [][]byte) {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	var (
+		pr   = string(prefix)
+		st   = string(append(prefix, start...))
+		keys []string
+	)
+	for key := range o.items {
+		if strings.HasPrefix(key, pr) && key >= st {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		values[i] = o.items[key]
+	}
+	return keys, values
+}
+
+// currentManifest returns the path and the size of the current manifest of the
+// pebble database in the given directory.
+func currentManifest(dir string) (string, int64, error) {
+	desc, err := pebble.Peek(dir, vfs.Default)
+	if err != nil {
+		return "", 0, err
+	}
+	if !desc.Exists {
+		return "", 0, fmt.Errorf("no pebble database in %s", dir)
+	}
+	info, err := vfs.Default.Stat(desc.ManifestFilename)
+	if err != nil {
+		return "", 0, err
+	}
+	return desc.ManifestFilename, info.Size(), nil
+}
+
+// newestLog returns the number and the path of the newest log of the pebble
+// database in the given directory, zero if there is none.
+func newestLog(dir string) (uint64, string, error) {
+	names, err := vfs.Default.List(dir)
+	if err != nil {
+		return 0, "", err
+	}
+	var (
+		newest uint64
+		path   string
+	)
+	for _, name := range names {
+		if num, ok := parseLogName(name); ok && num > newest {
+			newest, path = num, vfs.Default.PathJoin(dir, name)
+		}
+	}
+	return newest, path, nil
+}
+
+// parseLogName parses the number of a pebble log from its file name.
+func parseLogName(name string) (uint64, bool) {
+	num, ok := strings.CutSuffix(name, ".log")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(num, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,172 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package cachejournal persists the hottest entries of the clean caches of the
+// state databases across restarts, so that they are served from memory right
+// after startup.
+package cachejournal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/VictoriaMetrics/fastcache"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// rootFile is the name of the file holding the state root of the cached
+	// data, stored next to the entries.
+	rootFile = "root"
+
+	// entriesFile is the name of the file holding the persisted cache entries.
+	entriesFile = "entries"
+)
+
+// Save persists the hottest entries of the cache recorded by the tracker into
+// the directory at the given path, along with the root of the state the cached
+// entries belong to. The root is empty if the entries are valid for any state,
+// e.g. the content-addressed trie nodes.
+//
+// The entries are written in the descending order of their access counts, until
+// the size budget of the tracker is exhausted.
+func Save(cache *fastcache.Cache, tracker *Tracker, path string, root common.Hash) error {
+	start := time.Now()
+	if err := os.RemoveAll(path); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(filepath.Join(path, entriesFile))
+	if err != nil {
+		return err
+	}
+	var (
+		w       = bufio.NewWriter(file)
+		buf     []byte
+		entries int
+		size    int
+	)
+	for _, key := range tracker.hottest() {
+		value, ok := cache.HasGet(buf[:0], key)
+		if !ok {
+			continue // evicted meanwhile
+		}
+		buf = value
+		if size+len(key)+len(value) > tracker.budget {
+			break
+		}
+		w.Write(binary.AppendUvarint(nil, uint64(len(key))))
+		w.Write(key)
+		w.Write(binary.AppendUvarint(nil, uint64(len(value))))
+		if _, err := w.Write(value); err != nil {
+			file.Close()
+			return err
+		}
+		entries++
+		size += len(key) + len(value)
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	// The root is written last, an interrupted save leaves an unusable journal.
+	if err := os.WriteFile(filepath.Join(path, rootFile), root.Bytes(), 0644); err != nil {
+		return err
+	}
+	log.Info("Persisted clean cache", "path", path, "entries", entries,
+		"size", common.StorageSize(size), "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// Load loads the cache persisted at the given path if it belongs to the state of
+// the given root, nil is returned otherwise. The journal is deleted once loaded,
+// as the cached entries might become stale after the state moves on.
+func Load(path string, maxBytes int, root common.Hash) *fastcache.Cache {
+	blob, err := os.ReadFile(filepath.Join(path, rootFile))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warn("Failed to read clean cache journal", "path", path, "err", err)
+		}
+		os.RemoveAll(path)
+		return nil
+	}
+	defer os.RemoveAll(path)
+
+	if saved := common.BytesToHash(blob); len(blob) != common.HashLength || saved != root {
+		log.Info("Discarded stale clean cache journal", "path", path, "saved", saved, "root", root)
+		return nil
+	}
+	start := time.Now()
+	file, err := os.Open(filepath.Join(path, entriesFile))
+	if err != nil {
+		log.Warn("Failed to open clean cache journal", "path", path, "err", err)
+		return nil
+	}
+	defer file.Close()
+
+	var (
+		r       = bufio.NewReader(file)
+		cache   = fastcache.New(maxBytes)
+		entries int
+		size    int
+	)
+	for {
+		key, err := readBlob(r)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var value []byte
+		if err == nil {
+			value, err = readBlob(r)
+		}
+		if err != nil {
+			log.Warn("Failed to read clean cache journal", "path", path, "err", err)
+			cache.Reset()
+			return nil
+		}
+		cache.Set(key, value)
+		entries++
+		size += len(key) + len(value)
+	}
+	log.Info("Loaded clean cache journal", "path", path, "entries", entries,
+		"size", common.StorageSize(size), "elapsed", common.PrettyDuration(time.Since(start)))
+	return cache
+}
+
+// readBlob reads a length prefixed blob from the journal. The io.EOF is only
+// returned if the journal ends before the blob.
+func readBlob(r *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	blob := make([]byte, n)
+	if _, err := io.ReadFull(r, blob); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return blob, nil
+}
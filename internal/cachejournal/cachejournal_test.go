@@ -0,0 +1,119 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package cachejournal
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/VictoriaMetrics/fastcache"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestSaveLoad(t *testing.T) {
+	var (
+		path    = filepath.Join(t.TempDir(), "cache")
+		root    = common.Hash{0x01}
+		cache   = fastcache.New(1024 * 1024)
+		tracker = NewTracker(1024 * 1024)
+	)
+	cache.Set([]byte("key"), []byte("value"))
+	tracker.Touch([]byte("key"))
+
+	// The journal of another state is discarded.
+	if err := Save(cache, tracker, path, root); err != nil {
+		t.Fatalf("Failed to save cache: %v", err)
+	}
+	if loaded := Load(path, 1024*1024, common.Hash{0x02}); loaded != nil {
+		t.Fatal("Stale cache is loaded")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("Stale journal is not deleted")
+	}
+	// The journal of the same state is loaded once.
+	if err := Save(cache, tracker, path, root); err != nil {
+		t.Fatalf("Failed to save cache: %v", err)
+	}
+	loaded := Load(path, 1024*1024, root)
+	if loaded == nil {
+		t.Fatal("Cache is not loaded")
+	}
+	if value := loaded.Get(nil, []byte("key")); !bytes.Equal(value, []byte("value")) {
+		t.Fatalf("Unexpected cached value %q", value)
+	}
+	if loaded := Load(path, 1024*1024, root); loaded != nil {
+		t.Fatal("Cache is loaded twice")
+	}
+	// The interrupted save without the root is unusable.
+	if err := cache.SaveToFile(path); err != nil {
+		t.Fatalf("Failed to save cache: %v", err)
+	}
+	if loaded := Load(path, 1024*1024, root); loaded != nil {
+		t.Fatal("Incomplete journal is loaded")
+	}
+}
+
+func TestSaveHottest(t *testing.T) {
+	var (
+		path    = filepath.Join(t.TempDir(), "cache")
+		cache   = fastcache.New(1024 * 1024)
+		tracker = NewTracker(4 * budgetRatio * 1024) // 4 entries of 1KB fit the budget
+		value   = make([]byte, 1024-4)
+	)
+	// Access the entries with an increasing frequency, leaving a tracked entry
+	// evicted from the cache and a cached entry never accessed.
+	for i := 0; i < 8; i++ {
+		key := []byte(fmt.Sprintf("k%03d", i))
+		if i != 7 {
+			cache.Set(key, value)
+		}
+		for j := 0; j <= i; j++ {
+			tracker.Touch(key)
+		}
+	}
+	cache.Set([]byte("cold"), value)
+
+	if err := Save(cache, tracker, path, common.Hash{}); err != nil {
+		t.Fatalf("Failed to save cache: %v", err)
+	}
+	loaded := Load(path, 1024*1024, common.Hash{})
+	if loaded == nil {
+		t.Fatal("Cache is not loaded")
+	}
+	for i := 0; i < 8; i++ {
+		key := []byte(fmt.Sprintf("k%03d", i))
+		if want := i >= 3 && i != 7; loaded.Has(key) != want {
+			t.Fatalf("Entry %d persisted mismatch, want %v", i, want)
+		}
+	}
+	if loaded.Has([]byte("cold")) {
+		t.Fatal("Cold entry is persisted")
+	}
+}
+
+func TestTrackerDecay(t *testing.T) {
+	tracker := NewTracker(budgetRatio * averageEntrySize * trackerShards) // one key per shard
+	for i := 0; i < 1000; i++ {
+		tracker.Touch([]byte(fmt.Sprintf("key%d", i)))
+	}
+	if n := len(tracker.hottest()); n > trackerShards {
+		t.Fatalf("Too many tracked keys, have %d, limit %d", n, trackerShards)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package cachejournal
+
+import (
+	"cmp"
+	"hash/maphash"
+	"math"
+	"slices"
+	"sync"
+)
+
+const (
+	// budgetRatio is the share of the cache size the journal is allowed to take.
+	budgetRatio = 4
+
+	// averageEntrySize is the estimated size of a cache entry, bounding the number
+	// of the tracked keys to what the budget is expected to hold.
+	averageEntrySize = 256
+
+	// trackerShards is the number of independently locked shards of the tracker,
+	// to not serialize the concurrent cache accesses.
+	trackerShards = 16
+)
+
+// Tracker counts the accesses of the cached entries, so that only the hottest
+// ones are persisted within the size budget of the journal. The number of the
+// tracked keys is bounded, once the limit is reached the counters are halved and
+// the keys which cooled down are dropped, keeping the recent accesses dominant.
+type Tracker struct {
+	budget int          // size limit of the persisted entries
+	limit  int          // maximum number of the keys tracked by a shard
+	seed   maphash.Seed // seed for distributing the keys among the shards
+	shards [trackerShards]trackerShard
+}
+
+// trackerShard is a subset of the tracked keys with their access counts.
+type trackerShard struct {
+	lock   sync.Mutex
+	counts map[string]uint32
+}
+
+// NewTracker creates the access tracker of a cache with the given size.
+func NewTracker(cacheSize int) *Tracker {
+	budget := cacheSize / budgetRatio
+	t := &Tracker{
+		budget: budget,
+		limit:  max(budget/averageEntrySize/trackerShards, 1),
+		seed:   maphash.MakeSeed(),
+	}
+	for i := range t.shards {
+		t.shards[i].counts = make(map[string]uint32)
+	}
+	return t
+}
+
+// Touch records an access of the cached entry with the given key. It's a noop on
+// a nil tracker, to be called unconditionally if the journal is disabled.
+func (t *Tracker) Touch(key []byte) {
+	if t == nil {
+		return
+	}
+	s := &t.shards[maphash.Bytes(t.seed, key)%trackerShards]
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if count, ok := s.counts[string(key)]; ok {
+		if count < math.MaxUint32 {
+			s.counts[string(key)] = count + 1
+		}
+		return
+	}
+	for len(s.counts) >= t.limit {
+		s.decay()
+	}
+	s.counts[string(key)] = 1
+}
+
+// decay halves the access counts, dropping the keys accessed only once since the
+// last decay.
+func (s *trackerShard) decay() {
+	for key, count := range s.counts {
+		if count >>= 1; count == 0 {
+			delete(s.counts, key)
+		} else {
+			s.counts[key] = count
+		}
+	}
+}
+
+// hottest returns the tracked keys in the descending order of the access counts.
+func (t *Tracker) hottest() [][]byte {
+	if t == nil {
+		return nil
+	}
+	type entry struct {
+		key   string
+		count uint32
+	}
+	var entries []entry
+	for i := range t.shards {
+		s := &t.shards[i]
+		s.lock.Lock()
+		for key, count := range s.counts {
+			entries = append(entries, entry{key, count})
+		}
+		s.lock.Unlock()
+	}
+	slices.SortFunc(entries, func(a, b entry) int {
+		return cmp.Compare(b.count, a.count)
+	})
+	keys := make([][]byte, len(entries))
+	for i, entry := range entries {
+		keys[i] = []byte(entry.key)
+	}
+	return keys
+}
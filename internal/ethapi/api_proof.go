@@ -0,0 +1,251 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/trie/trienode"
+)
+
+const (
+	// maxMultiProofKeys is the maximum number of accounts and storage slots
+	// proven by a single multiproof request.
+	maxMultiProofKeys = 1024
+
+	// maxRangeProofItems is the maximum number of trie entries returned by a
+	// single range proof request.
+	maxRangeProofItems = 1024
+)
+
+// MultiProofArgs is the set of storage slots of an account to be proven.
+type MultiProofArgs struct {
+	Address     common.Address `json:"address"`
+	StorageKeys []string       `json:"storageKeys"`
+}
+
+// MultiProofResult is the multiproof of a set of accounts and storage slots,
+// sharing the trie nodes among all the proven keys. Each key can be verified
+// by trie.VerifyProof against the state root or the storage root, using the
+// nodes keyed by their hashes as the proof database.
+type MultiProofResult struct {
+	StateRoot common.Hash         `json:"stateRoot"`
+	Accounts  []MultiProofAccount `json:"accounts"`
+	Nodes     []hexutil.Bytes     `json:"nodes"`
+}
+
+// MultiProofAccount is the proven account along with its proven storage slots.
+type MultiProofAccount struct {
+	Address     common.Address   `json:"address"`
+	Balance     *hexutil.Big     `json:"balance"`
+	CodeHash    common.Hash      `json:"codeHash"`
+	Nonce       hexutil.Uint64   `json:"nonce"`
+	StorageHash common.Hash      `json:"storageHash"`
+	Storage     []MultiProofSlot `json:"storage"`
+}
+
+// MultiProofSlot is the proven storage slot.
+type MultiProofSlot struct {
+	Key   string       `json:"key"`
+	Value *hexutil.Big `json:"value"`
+}
+
+// RangeProofResult is the proof of a contiguous range of entries in the account
+// trie or a storage trie, compatible with trie.VerifyRangeProof. The keys are
+// the hashed trie keys, the values are the raw trie values.
+type RangeProofResult struct {
+	Root   common.Hash     `json:"root"`
+	Keys   []common.Hash   `json:"keys"`
+	Values []hexutil.Bytes `json:"values"`
+	Proof  []hexutil.Bytes `json:"proof"`
+}
+
+// GetMultiProof returns the deduplicated Merkle proof of the given accounts and
+// storage slots.
+func (api *BlockChainAPI) GetMultiProof(ctx context.Context, args []MultiProofArgs, blockNrOrHash rpc.BlockNumberOrHash) (*MultiProofResult, error) {
+	var (
+		keys       = make([][]common.Hash, len(args))
+		keyLengths = make([][]int, len(args))
+		total      = len(args)
+	)
+	// Deserialize all keys. This prevents state access on invalid input.
+	for i, arg := range args {
+		total += len(arg.StorageKeys)
+		if total > maxMultiProofKeys {
+			return nil, fmt.Errorf("too many keys, want at most %d", maxMultiProofKeys)
+		}
+		keys[i] = make([]common.Hash, len(arg.StorageKeys))
+		keyLengths[i] = make([]int, len(arg.StorageKeys))
+		for j, hexKey := range arg.StorageKeys {
+			var err error
+			keys[i][j], keyLengths[i][j], err = decodeHash(hexKey)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	statedb, header, err := api.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	tr, err := trie.NewStateTrie(trie.StateTrieID(header.Root), statedb.Database().TrieDB())
+	if err != nil {
+		return nil, err
+	}
+	var (
+		nodes    = trienode.NewProofSet()
+		accounts = make([]MultiProofAccount, len(args))
+	)
+	for i, arg := range args {
+		address := arg.Address
+		if err := tr.Prove(crypto.Keccak256(address.Bytes()), nodes); err != nil {
+			return nil, err
+		}
+		storageRoot := statedb.GetStorageRoot(address)
+		storage := make([]MultiProofSlot, len(keys[i]))
+
+		var storageTrie *trie.StateTrie
+		if len(keys[i]) > 0 && storageRoot != types.EmptyRootHash && storageRoot != (common.Hash{}) {
+			id := trie.StorageTrieID(header.Root, crypto.Keccak256Hash(address.Bytes()), storageRoot)
+			storageTrie, err = trie.NewStateTrie(id, statedb.Database().TrieDB())
+			if err != nil {
+				return nil, err
+			}
+		}
+		for j, key := range keys[i] {
+			// Output key encoding follows eth_getProof, see GetProof.
+			var outputKey string
+			if keyLengths[i][j] != 32 {
+				outputKey = hexutil.EncodeBig(key.Big())
+			} else {
+				outputKey = hexutil.Encode(key[:])
+			}
+			if storageTrie == nil {
+				storage[j] = MultiProofSlot{outputKey, &hexutil.Big{}}
+				continue
+			}
+			if err := storageTrie.Prove(crypto.Keccak256(key.Bytes()), nodes); err != nil {
+				return nil, err
+			}
+			storage[j] = MultiProofSlot{outputKey, (*hexutil.Big)(statedb.GetState(address, key).Big())}
+		}
+		accounts[i] = MultiProofAccount{
+			Address:     address,
+			Balance:     (*hexutil.Big)(statedb.GetBalance(address).ToBig()),
+			CodeHash:    statedb.GetCodeHash(address),
+			Nonce:       hexutil.Uint64(statedb.GetNonce(address)),
+			StorageHash: storageRoot,
+			Storage:     storage,
+		}
+	}
+	list := nodes.List()
+	result := &MultiProofResult{
+		StateRoot: header.Root,
+		Accounts:  accounts,
+		Nodes:     make([]hexutil.Bytes, len(list)),
+	}
+	for i, node := range list {
+		result.Nodes[i] = node
+	}
+	return result, statedb.Error()
+}
+
+// GetRangeProof returns the entries of the account trie, or the storage trie of
+// the given account, starting at the given hashed key along with the proof of
+// the range boundaries. At most maxRangeProofItems entries are returned. The
+// proof is omitted if the entire trie is returned.
+func (api *BlockChainAPI) GetRangeProof(ctx context.Context, address *common.Address, start common.Hash, limit hexutil.Uint64, blockNrOrHash rpc.BlockNumberOrHash) (*RangeProofResult, error) {
+	if limit == 0 || limit > maxRangeProofItems {
+		limit = maxRangeProofItems
+	}
+	statedb, header, err := api.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	id := trie.StateTrieID(header.Root)
+	if address != nil {
+		root := statedb.GetStorageRoot(*address)
+		if err := statedb.Error(); err != nil {
+			return nil, err
+		}
+		if root == (common.Hash{}) {
+			root = types.EmptyRootHash
+		}
+		id = trie.StorageTrieID(header.Root, crypto.Keccak256Hash(address.Bytes()), root)
+	}
+	result := &RangeProofResult{
+		Root:   id.Root,
+		Keys:   []common.Hash{},
+		Values: []hexutil.Bytes{},
+		Proof:  []hexutil.Bytes{},
+	}
+	if id.Root == types.EmptyRootHash {
+		return result, nil
+	}
+	tr, err := trie.New(id, statedb.Database().TrieDB())
+	if err != nil {
+		return nil, err
+	}
+	nodeIt, err := tr.NodeIterator(start[:])
+	if err != nil {
+		return nil, err
+	}
+	var (
+		it        = trie.NewIterator(nodeIt)
+		exhausted bool
+	)
+	for {
+		if uint64(len(result.Keys)) == uint64(limit) {
+			break
+		}
+		if !it.Next() {
+			exhausted = true
+			break
+		}
+		result.Keys = append(result.Keys, common.BytesToHash(it.Key))
+		result.Values = append(result.Values, common.CopyBytes(it.Value))
+	}
+	if it.Err != nil {
+		return nil, it.Err
+	}
+	// The entire trie is returned, it's verifiable without the proof just like
+	// the storage ranges delivered in the snap protocol.
+	if start == (common.Hash{}) && exhausted {
+		return result, nil
+	}
+	// Generate the Merkle proofs for the first and last entries.
+	proof := trienode.NewProofSet()
+	if err := tr.Prove(start[:], proof); err != nil {
+		return nil, err
+	}
+	if len(result.Keys) > 0 {
+		if err := tr.Prove(result.Keys[len(result.Keys)-1][:], proof); err != nil {
+			return nil, err
+		}
+	}
+	for _, node := range proof.List() {
+		result.Proof = append(result.Proof, node)
+	}
+	return result, nil
+}
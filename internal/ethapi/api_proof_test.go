@@ -0,0 +1,209 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/beacon"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+func newProofTestAPI(t *testing.T) (*BlockChainAPI, []account, common.Address) {
+	var (
+		accounts = newAccounts(8)
+		contract = common.HexToAddress("0xc0de")
+		storage  = make(map[common.Hash]common.Hash)
+		genesis  = &core.Genesis{
+			Config: params.MergedTestChainConfig,
+			Alloc: types.GenesisAlloc{
+				contract: {Balance: big.NewInt(1), Code: []byte{0x00}, Storage: storage},
+			},
+		}
+	)
+	for _, acc := range accounts {
+		genesis.Alloc[acc.addr] = types.Account{Balance: big.NewInt(params.Ether)}
+	}
+	for i := 1; i <= 32; i++ {
+		storage[common.BigToHash(big.NewInt(int64(i)))] = common.BigToHash(big.NewInt(int64(i * 10)))
+	}
+	backend := newTestBackend(t, 1, genesis, beacon.New(ethash.NewFaker()), func(i int, b *core.BlockGen) {
+		b.SetPoS()
+	})
+	return NewBlockChainAPI(backend), accounts, contract
+}
+
+func TestGetMultiProof(t *testing.T) {
+	t.Parallel()
+
+	api, accounts, contract := newProofTestAPI(t)
+	latest := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+
+	args := []MultiProofArgs{
+		{Address: contract, StorageKeys: []string{"0x1", "0x2", "0x3", "0x21"}},
+		{Address: accounts[0].addr},
+		{Address: accounts[1].addr, StorageKeys: []string{"0x1"}},
+	}
+	result, err := api.GetMultiProof(context.Background(), args, latest)
+	if err != nil {
+		t.Fatalf("Failed to get multiproof: %v", err)
+	}
+	proofDb := rawdb.NewMemoryDatabase()
+	for _, node := range result.Nodes {
+		proofDb.Put(crypto.Keccak256(node), node)
+	}
+	// Every requested key must be verifiable against the shared node set.
+	var single int
+	for i, arg := range args {
+		acc := result.Accounts[i]
+		blob, err := trie.VerifyProof(result.StateRoot, crypto.Keccak256(arg.Address.Bytes()), proofDb)
+		if err != nil || blob == nil {
+			t.Fatalf("Failed to verify account %x: %v", arg.Address, err)
+		}
+		var stored types.StateAccount
+		if err := rlp.DecodeBytes(blob, &stored); err != nil {
+			t.Fatalf("Failed to decode account: %v", err)
+		}
+		if stored.Root != acc.StorageHash || stored.Balance.ToBig().Cmp(acc.Balance.ToInt()) != 0 {
+			t.Fatalf("Mismatched account %x", arg.Address)
+		}
+		for j, slot := range acc.Storage {
+			key, _, _ := decodeHash(arg.StorageKeys[j])
+			blob, err := trie.VerifyProof(acc.StorageHash, crypto.Keccak256(key.Bytes()), proofDb)
+			if acc.StorageHash == types.EmptyRootHash {
+				continue
+			}
+			if err != nil {
+				t.Fatalf("Failed to verify slot %x of %x: %v", key, arg.Address, err)
+			}
+			var value []byte
+			if len(blob) > 0 {
+				if _, content, _, err := rlp.Split(blob); err == nil {
+					value = content
+				}
+			}
+			if new(big.Int).SetBytes(value).Cmp(slot.Value.ToInt()) != 0 {
+				t.Fatalf("Mismatched slot %x of %x", key, arg.Address)
+			}
+		}
+		// The independent proofs contain the shared nodes repeatedly.
+		res, err := api.GetProof(context.Background(), arg.Address, arg.StorageKeys, latest)
+		if err != nil {
+			t.Fatalf("Failed to get proof: %v", err)
+		}
+		single += len(res.AccountProof)
+		for _, slot := range res.StorageProof {
+			single += len(slot.Proof)
+		}
+	}
+	if len(result.Nodes) >= single {
+		t.Fatalf("Multiproof is not deduplicated, %d nodes vs %d", len(result.Nodes), single)
+	}
+	if got := result.Accounts[0].Storage[3].Value.ToInt(); got.Sign() != 0 {
+		t.Fatalf("Unexpected value of the absent slot %v", got)
+	}
+	// Oversized requests are rejected.
+	keys := make([]string, maxMultiProofKeys)
+	for i := range keys {
+		keys[i] = hexutil.EncodeUint64(uint64(i))
+	}
+	if _, err := api.GetMultiProof(context.Background(), []MultiProofArgs{{Address: contract, StorageKeys: keys}}, latest); err == nil {
+		t.Fatal("Oversized multiproof request is not rejected")
+	}
+}
+
+func TestGetRangeProof(t *testing.T) {
+	t.Parallel()
+
+	api, _, contract := newProofTestAPI(t)
+	latest := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+
+	verify := func(result *RangeProofResult, start common.Hash) bool {
+		t.Helper()
+
+		var proofDb ethdb.KeyValueStore
+		if len(result.Proof) > 0 {
+			proofDb = rawdb.NewMemoryDatabase()
+			for _, node := range result.Proof {
+				proofDb.Put(crypto.Keccak256(node), node)
+			}
+		}
+		keys := make([][]byte, len(result.Keys))
+		values := make([][]byte, len(result.Values))
+		for i := range result.Keys {
+			keys[i], values[i] = result.Keys[i].Bytes(), result.Values[i]
+		}
+		more, err := trie.VerifyRangeProof(result.Root, start[:], keys, values, proofDb)
+		if err != nil {
+			t.Fatalf("Failed to verify range proof: %v", err)
+		}
+		return more
+	}
+	// Iterate the account trie in small chunks until the end.
+	var (
+		start common.Hash
+		total int
+	)
+	for {
+		result, err := api.GetRangeProof(context.Background(), nil, start, 3, latest)
+		if err != nil {
+			t.Fatalf("Failed to get account range: %v", err)
+		}
+		total += len(result.Keys)
+		if !verify(result, start) {
+			break
+		}
+		last := result.Keys[len(result.Keys)-1].Big()
+		start = common.BigToHash(last.Add(last, common.Big1))
+	}
+	if total < 10 {
+		t.Fatalf("Unexpected account count %d", total)
+	}
+	// The whole storage trie is returned within a single range.
+	result, err := api.GetRangeProof(context.Background(), &contract, common.Hash{}, 0, latest)
+	if err != nil {
+		t.Fatalf("Failed to get storage range: %v", err)
+	}
+	if len(result.Keys) != 32 {
+		t.Fatalf("Unexpected slot count %d", len(result.Keys))
+	}
+	if len(result.Proof) != 0 || verify(result, common.Hash{}) {
+		t.Fatal("Unexpected proof or more slots")
+	}
+	// The storage range of the account without storage is empty.
+	empty := common.HexToAddress("0xdead")
+	result, err = api.GetRangeProof(context.Background(), &empty, common.Hash{}, 0, latest)
+	if err != nil {
+		t.Fatalf("Failed to get storage range: %v", err)
+	}
+	if result.Root != types.EmptyRootHash || len(result.Keys) != 0 || verify(result, common.Hash{}) {
+		t.Fatalf("Unexpected empty storage range %v", result)
+	}
+}
@@ -0,0 +1,45 @@
+package ethapi
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// JSON error codes of eth_sendRawTransactionConditional.
+const (
+	errCodeConditionalRejected      = -32003
+	errCodeConditionalLimitExceeded = -32005
+)
+
+// SendRawTransactionConditional will add the signed transaction to the transaction
+// pool, to be included only in blocks satisfying the given conditions. The
+// conditions are checked against the latest state before submission.
+func (api *TransactionAPI) SendRawTransactionConditional(ctx context.Context, input hexutil.Bytes, cond types.TransactionConditional) (common.Hash, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(input); err != nil {
+		return common.Hash{}, err
+	}
+	if cost := cond.Cost(); cost > types.MaxTransactionConditionalCost {
+		return common.Hash{}, &invalidTxError{Message: fmt.Sprintf("conditional cost %d exceeds limit %d", cost, types.MaxTransactionConditionalCost), Code: errCodeConditionalLimitExceeded}
+	}
+	if err := cond.Validate(); err != nil {
+		return common.Hash{}, &invalidTxError{Message: err.Error(), Code: errCodeInvalidParams}
+	}
+	state, header, err := api.b.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if state == nil || err != nil {
+		return common.Hash{}, err
+	}
+	if cond.Expired(header) {
+		return common.Hash{}, &invalidTxError{Message: fmt.Sprintf("%v: block range passed", types.ErrConditionalFailed), Code: errCodeConditionalRejected}
+	}
+	if err := state.CheckKnownAccounts(cond.KnownAccounts); err != nil {
+		return common.Hash{}, &invalidTxError{Message: err.Error(), Code: errCodeConditionalRejected}
+	}
+	tx.SetConditional(&cond)
+	return SubmitTransaction(ctx, api.b, tx)
+}
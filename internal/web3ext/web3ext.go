@@ -31,6 +31,9 @@ var Modules = map[string]string{
 	"les":      LESJs,
 	"vflux":    VfluxJs,
 	"dev":      DevJs,
+	"trace":    TraceJs, // CHANGE(taiko): Parity compatible trace namespace.
+
+	"livetrace": LivetraceJs, // CHANGE(taiko): records of the live trace sink.
 }
 
 const CliqueJs = `
@@ -406,6 +409,12 @@ web3._extend({
 			params: 2,
 			inputFormatter: [null, null]
 		}),
+		new web3._extend.Method({
+			name: 'profileBlock',
+			call: 'debug_profileBlock',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, web3._extend.formatters.inputBlockNumberFormatter, null]
+		}),
 		new web3._extend.Method({
 			name: 'standardTraceBlockToFile',
 			call: 'debug_standardTraceBlockToFile',
@@ -545,6 +554,11 @@ web3._extend({
 			params: 1,
 			inputFormatter: [web3._extend.formatters.inputTransactionFormatter]
 		}),
+		new web3._extend.Method({
+			name: 'sendRawTransactionConditional',
+			call: 'eth_sendRawTransactionConditional',
+			params: 2
+		}),
 		new web3._extend.Method({
 			name: 'fillTransaction',
 			call: 'eth_fillTransaction',
@@ -593,6 +607,18 @@ web3._extend({
 			params: 3,
 			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
+		new web3._extend.Method({
+			name: 'getMultiProof',
+			call: 'eth_getMultiProof',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getRangeProof',
+			call: 'eth_getRangeProof',
+			params: 4,
+			inputFormatter: [null, null, null, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
 		new web3._extend.Method({
 			name: 'createAccessList',
 			call: 'eth_createAccessList',
@@ -893,3 +919,65 @@ web3._extend({
 	],
 });
 `
+
+// CHANGE(taiko): Parity compatible trace namespace.
+const TraceJs = `
+web3._extend({
+	property: 'trace',
+	methods:
+	[
+		new web3._extend.Method({
+			name: 'block',
+			call: 'trace_block',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'transaction',
+			call: 'trace_transaction',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'get',
+			call: 'trace_get',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'filter',
+			call: 'trace_filter',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'replayBlockTransactions',
+			call: 'trace_replayBlockTransactions',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'replayTransaction',
+			call: 'trace_replayTransaction',
+			params: 2
+		}),
+	],
+});
+`
+
+// CHANGE(taiko): records of the live trace sink.
+const LivetraceJs = `
+web3._extend({
+	property: 'livetrace',
+	methods:
+	[
+		new web3._extend.Method({
+			name: 'cursor',
+			call: 'livetrace_cursor',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'read',
+			call: 'livetrace_read',
+			params: 2
+		}),
+	],
+});
+`
@@ -18,8 +18,10 @@ package miner
 
 import (
 	"crypto/ecdsa"
+	"maps"
 	"math/big"
 	"math/rand"
+	"slices"
 	"testing"
 	"time"
 
@@ -102,7 +104,7 @@ func testTransactionPriceNonceSort(t *testing.T, baseFee *big.Int) {
 		expectedCount += count
 	}
 	// Sort the transactions and cross check the nonce ordering
-	txset := newTransactionsByPriceAndNonce(signer, groups, baseFee)
+	txset := newTransactionsByPriceAndNonce(signer, groups, baseFee, nil)
 
 	txs := types.Transactions{}
 	for tx, _ := txset.Peek(); tx != nil; tx, _ = txset.Peek() {
@@ -168,7 +170,7 @@ func TestTransactionTimeSort(t *testing.T) {
 		})
 	}
 	// Sort the transactions and cross check the nonce ordering
-	txset := newTransactionsByPriceAndNonce(signer, groups, nil)
+	txset := newTransactionsByPriceAndNonce(signer, groups, nil, nil)
 
 	txs := types.Transactions{}
 	for tx, _ := txset.Peek(); tx != nil; tx, _ = txset.Peek() {
@@ -194,3 +196,55 @@ func TestTransactionTimeSort(t *testing.T) {
 		}
 	}
 }
+
+// Tests that the transactions are ordered by the policy of the pool, if given.
+func TestTransactionPolicyOrdering(t *testing.T) {
+	t.Parallel()
+
+	var (
+		signer = types.HomesteadSigner{}
+		groups = map[common.Address][]*txpool.LazyTransaction{}
+		prices = []int64{1, 3, 2}
+		sizes  = []uint64{100, 1000, 50}
+	)
+	// Cheaper transactions are seen earlier, smaller ones are worth more per byte.
+	for i, price := range prices {
+		key, _ := crypto.GenerateKey()
+		tx, _ := types.SignTx(types.NewTransaction(0, common.Address{}, big.NewInt(100), 100, big.NewInt(price), nil), signer, key)
+		tx.SetTime(time.Unix(price, 0))
+
+		groups[crypto.PubkeyToAddress(key.PublicKey)] = []*txpool.LazyTransaction{{
+			Hash:      tx.Hash(),
+			Tx:        tx,
+			Time:      tx.Time(),
+			GasFeeCap: uint256.MustFromBig(tx.GasFeeCap()),
+			GasTipCap: uint256.MustFromBig(tx.GasTipCap()),
+			Gas:       tx.Gas(),
+			Size:      sizes[i],
+		}}
+	}
+	for _, test := range []struct {
+		ordering string
+		want     []int64
+	}{
+		{txpool.OrderingPrice, []int64{3, 2, 1}},
+		{txpool.OrderingFCFS, []int64{1, 2, 3}},
+		{txpool.OrderingFeePerByte, []int64{2, 1, 3}},
+	} {
+		policy, err := txpool.NewPolicy(txpool.PolicyConfig{Ordering: test.ordering})
+		if err != nil {
+			t.Fatalf("failed to create policy: %v", err)
+		}
+		var (
+			txset = newTransactionsByPriceAndNonce(signer, maps.Clone(groups), nil, policy)
+			have  []int64
+		)
+		for tx, _ := txset.Peek(); tx != nil; tx, _ = txset.Peek() {
+			have = append(have, tx.Tx.GasPrice().Int64())
+			txset.Shift()
+		}
+		if !slices.Equal(have, test.want) {
+			t.Errorf("ordering %s: have prices %v, want %v", test.ordering, have, test.want)
+		}
+	}
+}
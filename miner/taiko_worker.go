@@ -81,8 +81,8 @@ func (w *Miner) buildTransactionsLists(
 		lastTransaction := w.commitL2Transactions(
 			env,
 			firstTransaction,
-			newTransactionsByPriceAndNonce(signer, maps.Clone(localTxs), baseFee),
-			newTransactionsByPriceAndNonce(signer, maps.Clone(remoteTxs), baseFee),
+			newTransactionsByPriceAndNonce(signer, maps.Clone(localTxs), baseFee, w.txpool.Policy()),
+			newTransactionsByPriceAndNonce(signer, maps.Clone(remoteTxs), baseFee, w.txpool.Policy()),
 			maxBytesPerTxList,
 			minTip,
 		)
@@ -292,6 +292,12 @@ loop:
 			txs.Pop()
 			continue
 		}
+		if err := checkConditional(env, tx); err != nil {
+			log.Trace("Ignoring transaction with failed conditional", "hash", tx.Hash(), "err", err)
+
+			txs.Pop()
+			continue
+		}
 		// Start executing the transaction
 		env.state.SetTxContext(tx.Hash(), env.tcount)
 
@@ -338,6 +344,19 @@ loop:
 	return lastTransaction
 }
 
+// checkConditional returns an error if the transaction was submitted with
+// conditions which do not hold for the block being built.
+func checkConditional(env *environment, tx *types.Transaction) error {
+	cond := tx.Conditional()
+	if cond == nil {
+		return nil
+	}
+	if err := cond.CheckBlock(env.header.Number, env.header.Time); err != nil {
+		return err
+	}
+	return env.state.CheckKnownAccounts(cond.KnownAccounts)
+}
+
 // encodeAndCompressTxList encodes and compresses the given transactions list.
 func encodeAndCompressTxList(txs types.Transactions) ([]byte, error) {
 	b, err := rlp.EncodeToBytes(txs)
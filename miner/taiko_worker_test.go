@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/consensus/clique"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/txpool"
@@ -69,3 +70,20 @@ func TestBuildTransactionsLists(t *testing.T) {
 	assert.LessOrEqual(t, 1, len(txList))
 	assert.LessOrEqual(t, txList[0].BytesLength, uint64(maxBytesPerTxList))
 }
+
+func TestBuildTransactionsListsConditional(t *testing.T) {
+	w := testGenerateWorker(t, 0)
+
+	// Add a plain transaction, followed by one only includable in a future block
+	plain := newRandomTx(w.txpool, false)
+	assert.NoError(t, w.txpool.Add([]*types.Transaction{plain}, true, true)[0])
+
+	future := newRandomTx(w.txpool, false)
+	future.SetConditional(&types.TransactionConditional{BlockNumberMin: (*hexutil.Big)(big.NewInt(100))})
+	assert.NoError(t, w.txpool.Add([]*types.Transaction{future}, true, true)[0])
+
+	txList, err := w.BuildTransactionsLists(testBankAddress, nil, 240_000_000, 100_000, nil, 1)
+	assert.NoError(t, err)
+	assert.Len(t, txList, 1)
+	assert.Equal(t, types.Transactions{plain}, types.Transactions(txList[0].TxList))
+}
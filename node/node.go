@@ -772,6 +772,37 @@ func (n *Node) OpenDatabaseWithFreezer(name string, cache, handles int, ancient
 	return db, err
 }
 
+// CHANGE(taiko): OpenReplicaDatabase opens the chain database of a primary node
+// in read-only mode, following the writes of the primary. The directory is the
+// path of the primary's chain database, the ancient store defaults to the one
+// inside of it if not specified.
+func (n *Node) OpenReplicaDatabase(directory string, cache, handles int, ancient string, namespace string) (ethdb.Database, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if n.state == closedState {
+		return nil, ErrNodeStopped
+	}
+	switch {
+	case ancient == "":
+		ancient = filepath.Join(directory, "ancient")
+	case !filepath.IsAbs(ancient):
+		ancient = n.ResolvePath(ancient)
+	}
+	db, err := rawdb.Open(rawdb.OpenOptions{
+		Directory:         directory,
+		AncientsDirectory: ancient,
+		Namespace:         namespace,
+		Cache:             cache,
+		Handles:           handles,
+		ReadOnly:          true,
+		Replica:           true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return n.wrapDatabase(db), nil
+}
+
 // ResolvePath returns the absolute path of a resource in the instance directory.
 func (n *Node) ResolvePath(x string) string {
 	return n.config.ResolvePath(x)
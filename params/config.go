@@ -360,6 +360,19 @@ type ChainConfig struct {
 	Taiko       bool     `json:"taiko"`
 	OntakeBlock *big.Int `json:"ontakeBlock,omitempty"` // Ontake switch block (nil = no fork, 0 = already activated)
 	PacayaBlock *big.Int `json:"pacayaBlock,omitempty"` // Ontake switch block (nil = no fork, 0 = already activated)
+
+	// CHANGE(taiko): EIP-7702 activation independent of Prague.
+	EIP7702Block *big.Int `json:"eip7702Block,omitempty"` // EIP-7702 switch block (nil = no fork, 0 = already activated)
+
+	// CHANGE(taiko): RIP-7212 secp256r1 verification precompile activation.
+	RIP7212Block *big.Int `json:"rip7212Block,omitempty"` // RIP-7212 switch block (nil = no fork, 0 = already activated)
+
+	// CHANGE(taiko): EVM Object Format activation, for prototyping on devnets.
+	EOFBlock *big.Int `json:"eofBlock,omitempty"` // EOF switch block (nil = no fork, 0 = already activated)
+
+	// CHANGE(taiko): custom precompiles registered with the EVM, activated at
+	// the configured addresses and blocks.
+	Precompiles map[common.Address]*PrecompileConfig `json:"precompiles,omitempty"`
 }
 
 // EthashConfig is the consensus engine configs for proof-of-work based sealing.
@@ -473,6 +486,24 @@ func (c *ChainConfig) Description() string {
 	if c.VerkleTime != nil {
 		banner += fmt.Sprintf(" - Verkle:                      @%-10v\n", *c.VerkleTime)
 	}
+	// CHANGE(taiko): show the standalone EIP-7702, RIP-7212 and EOF activations.
+	if c.EIP7702Block != nil || c.RIP7212Block != nil || c.EOFBlock != nil || len(c.Precompiles) > 0 {
+		banner += "\n"
+		banner += "Standalone EIPs (block based):\n"
+		if c.EIP7702Block != nil {
+			banner += fmt.Sprintf(" - EIP-7702 (set code):         #%-8v\n", c.EIP7702Block)
+		}
+		if c.RIP7212Block != nil {
+			banner += fmt.Sprintf(" - RIP-7212 (P256VERIFY):       #%-8v\n", c.RIP7212Block)
+		}
+		if c.EOFBlock != nil {
+			banner += fmt.Sprintf(" - EOF (EVM Object Format):     #%-8v\n", c.EOFBlock)
+		}
+		for _, addr := range c.precompileAddresses() {
+			p := c.Precompiles[addr]
+			banner += fmt.Sprintf(" - Precompile %-17s #%-8v (%v)\n", p.Name+":", p.Block, addr)
+		}
+	}
 	return banner
 }
 
@@ -581,6 +612,24 @@ func (c *ChainConfig) IsOntake(num *big.Int) bool {
 	return isBlockForked(c.OntakeBlock, num)
 }
 
+// CHANGE(taiko): IsEIP7702 returns whether EIP-7702 set code transactions are
+// enabled, either as part of Prague or through the standalone EIP7702Block.
+func (c *ChainConfig) IsEIP7702(num *big.Int, time uint64) bool {
+	return c.IsPrague(num, time) || isBlockForked(c.EIP7702Block, num)
+}
+
+// CHANGE(taiko): IsRIP7212 returns whether num is either equal to the RIP-7212
+// fork block or greater, enabling the secp256r1 verification precompile.
+func (c *ChainConfig) IsRIP7212(num *big.Int) bool {
+	return isBlockForked(c.RIP7212Block, num)
+}
+
+// CHANGE(taiko): IsEOF returns whether num is either equal to the EOF fork
+// block or greater, enabling EVM Object Format contracts.
+func (c *ChainConfig) IsEOF(num *big.Int) bool {
+	return isBlockForked(c.EOFBlock, num)
+}
+
 // CHANGE(taiko): IsPacaya returns whether num is either equal to the pacaya fork block or greater.
 func (c *ChainConfig) IsPacaya(num *big.Int) bool {
 	return isBlockForked(c.PacayaBlock, num)
@@ -681,6 +730,10 @@ func (c *ChainConfig) CheckConfigForkOrder() error {
 			lastFork = cur
 		}
 	}
+	// CHANGE(taiko): EOF is built on top of the Shanghai instruction set.
+	if c.EOFBlock != nil && c.ShanghaiTime == nil {
+		return fmt.Errorf("unsupported fork ordering: shanghaiTime not enabled, but eofBlock enabled at block %v", c.EOFBlock)
+	}
 	return nil
 }
 
@@ -752,6 +805,22 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, headNumber *big.Int,
 	if isForkTimestampIncompatible(c.VerkleTime, newcfg.VerkleTime, headTimestamp) {
 		return newTimestampCompatError("Verkle fork timestamp", c.VerkleTime, newcfg.VerkleTime)
 	}
+	// CHANGE(taiko): standalone EIP-7702 activation.
+	if isForkBlockIncompatible(c.EIP7702Block, newcfg.EIP7702Block, headNumber) {
+		return newBlockCompatError("EIP-7702 fork block", c.EIP7702Block, newcfg.EIP7702Block)
+	}
+	// CHANGE(taiko): RIP-7212 activation.
+	if isForkBlockIncompatible(c.RIP7212Block, newcfg.RIP7212Block, headNumber) {
+		return newBlockCompatError("RIP-7212 fork block", c.RIP7212Block, newcfg.RIP7212Block)
+	}
+	// CHANGE(taiko): EOF activation.
+	if isForkBlockIncompatible(c.EOFBlock, newcfg.EOFBlock, headNumber) {
+		return newBlockCompatError("EOF fork block", c.EOFBlock, newcfg.EOFBlock)
+	}
+	// CHANGE(taiko): custom precompile activations.
+	if err := c.checkPrecompilesCompatible(newcfg, headNumber); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -924,6 +993,19 @@ type Rules struct {
 	IsBerlin, IsLondon                                      bool
 	IsMerge, IsShanghai, IsCancun, IsPrague                 bool
 	IsVerkle                                                bool
+
+	// CHANGE(taiko): EIP-7702 activation independent of Prague.
+	IsEIP7702 bool
+
+	// CHANGE(taiko): RIP-7212 secp256r1 verification precompile.
+	IsRIP7212 bool
+
+	// CHANGE(taiko): EVM Object Format, layered on top of Shanghai.
+	IsEOF bool
+
+	// CHANGE(taiko): names of the active custom precompiles, by address. The
+	// map is shared between the rules of the same fork set and is read-only.
+	Precompiles map[common.Address]string
 }
 
 // Rules ensures c's ChainID is not nil.
@@ -954,5 +1036,13 @@ func (c *ChainConfig) Rules(num *big.Int, isMerge bool, timestamp uint64) Rules
 		IsPrague:         isMerge && c.IsPrague(num, timestamp),
 		IsVerkle:         isVerkle,
 		IsEIP4762:        isVerkle,
+		// CHANGE(taiko): EIP-7702 activation independent of Prague.
+		IsEIP7702: isMerge && c.IsEIP7702(num, timestamp),
+		// CHANGE(taiko): RIP-7212 secp256r1 verification precompile.
+		IsRIP7212: c.IsRIP7212(num),
+		// CHANGE(taiko): EVM Object Format.
+		IsEOF: isMerge && c.IsShanghai(num, timestamp) && c.IsEOF(num),
+		// CHANGE(taiko): custom precompiles.
+		Precompiles: c.activePrecompiles(num),
 	}
 }
@@ -1,10 +1,17 @@
 package params
 
 import (
+	"bytes"
 	"encoding/binary"
+	"fmt"
 	"math/big"
+	"reflect"
+	"slices"
+	"sort"
+	"unsafe"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
 )
 
 // To make taiko-geth compatible with the op-service library, we need to define the following constants and functions.
@@ -35,6 +42,112 @@ func (v ProtocolVersionV0) Encode() (out ProtocolVersion) {
 
 func u64(val uint64) *uint64 { return &val }
 
+// PrecompileConfig activates a custom precompile, registered with the EVM
+// under the given name, from the given block onwards.
+type PrecompileConfig struct {
+	Name  string   `json:"name"`
+	Block *big.Int `json:"block"`
+}
+
+// precompileSchedule is the precomputed sets of the custom precompiles active
+// across the activation blocks of a chain config.
+type precompileSchedule struct {
+	source unsafe.Pointer              // Precompiles map the schedule was derived from
+	blocks []*big.Int                  // Ascending distinct activation blocks
+	active []map[common.Address]string // Active precompiles once the first i blocks are reached
+}
+
+// precompileSchedules caches the precompile schedule of the recently used chain
+// configs, so that the rules don't rebuild the set of active precompiles for
+// every message. Configs copied per call, e.g. by tracing overrides, are evicted.
+var precompileSchedules = lru.NewCache[*ChainConfig, *precompileSchedule](16)
+
+// activePrecompiles returns the names of the custom precompiles active at the
+// given block, keyed by their address. The returned map is shared between all
+// the callers and must not be modified.
+func (c *ChainConfig) activePrecompiles(num *big.Int) map[common.Address]string {
+	if len(c.Precompiles) == 0 {
+		return nil
+	}
+	sched := c.precompileSchedule()
+	n := sort.Search(len(sched.blocks), func(i int) bool { return !isBlockForked(sched.blocks[i], num) })
+	return sched.active[n]
+}
+
+// precompileSchedule returns the cached precompile schedule of the config,
+// deriving it again if the configured precompiles were replaced.
+func (c *ChainConfig) precompileSchedule() *precompileSchedule {
+	source := reflect.ValueOf(c.Precompiles).UnsafePointer()
+	if cached, ok := precompileSchedules.Get(c); ok && cached.source == source {
+		return cached
+	}
+	sched := &precompileSchedule{source: source}
+	for _, p := range c.Precompiles {
+		if p == nil || p.Block == nil {
+			continue
+		}
+		if !slices.ContainsFunc(sched.blocks, func(b *big.Int) bool { return b.Cmp(p.Block) == 0 }) {
+			sched.blocks = append(sched.blocks, p.Block)
+		}
+	}
+	slices.SortFunc(sched.blocks, func(a, b *big.Int) int { return a.Cmp(b) })
+
+	sched.active = make([]map[common.Address]string, len(sched.blocks)+1)
+	for i, block := range sched.blocks {
+		active := make(map[common.Address]string)
+		for addr, p := range c.Precompiles {
+			if p != nil && isBlockForked(p.Block, block) {
+				active[addr] = p.Name
+			}
+		}
+		sched.active[i+1] = active
+	}
+	precompileSchedules.Add(c, sched)
+	return sched
+}
+
+// precompileAddresses returns the addresses of the configured custom
+// precompiles in ascending order.
+func (c *ChainConfig) precompileAddresses() []common.Address {
+	addrs := make([]common.Address, 0, len(c.Precompiles))
+	for addr := range c.Precompiles {
+		addrs = append(addrs, addr)
+	}
+	slices.SortFunc(addrs, func(a, b common.Address) int { return bytes.Compare(a[:], b[:]) })
+	return addrs
+}
+
+// checkPrecompilesCompatible checks whether the custom precompiles of newcfg
+// can be applied to a chain configured with c at the given head.
+func (c *ChainConfig) checkPrecompilesCompatible(newcfg *ChainConfig, headNumber *big.Int) *ConfigCompatError {
+	addrs := c.precompileAddresses()
+	for _, addr := range newcfg.precompileAddresses() {
+		if _, ok := c.Precompiles[addr]; !ok {
+			addrs = append(addrs, addr)
+		}
+	}
+	for _, addr := range addrs {
+		var (
+			what             = fmt.Sprintf("precompile %v activation block", addr)
+			stored, newblock *big.Int
+			oldName, newName string
+		)
+		if p := c.Precompiles[addr]; p != nil {
+			stored, oldName = p.Block, p.Name
+		}
+		if p := newcfg.Precompiles[addr]; p != nil {
+			newblock, newName = p.Block, p.Name
+		}
+		if isForkBlockIncompatible(stored, newblock, headNumber) {
+			return newBlockCompatError(what, stored, newblock)
+		}
+		if oldName != newName && isBlockForked(stored, headNumber) {
+			return newBlockCompatError(fmt.Sprintf("precompile %v name", addr), stored, newblock)
+		}
+	}
+	return nil
+}
+
 // Network IDs
 var (
 	TaikoMainnetNetworkID     = big.NewInt(167000)
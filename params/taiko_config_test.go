@@ -2,7 +2,10 @@ package params
 
 import (
 	"math/big"
+	"reflect"
 	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
 )
 
 func TestNetworkIDToChainConfigOrDefault(t *testing.T) {
@@ -91,3 +94,88 @@ func TestNetworkIDToChainConfigOrDefault(t *testing.T) {
 		})
 	}
 }
+
+func TestPrecompilesCompatible(t *testing.T) {
+	var (
+		addr   = common.Address{0x10, 0x00}
+		stored = &ChainConfig{Precompiles: map[common.Address]*PrecompileConfig{
+			addr: {Name: "signal", Block: big.NewInt(10)},
+		}}
+	)
+	for i, test := range []struct {
+		newcfg  map[common.Address]*PrecompileConfig
+		head    uint64
+		wantErr bool
+	}{
+		{newcfg: map[common.Address]*PrecompileConfig{addr: {Name: "signal", Block: big.NewInt(10)}}, head: 20},
+		{newcfg: map[common.Address]*PrecompileConfig{addr: {Name: "signal", Block: big.NewInt(15)}}, head: 5},
+		{newcfg: map[common.Address]*PrecompileConfig{addr: {Name: "signal", Block: big.NewInt(15)}}, head: 12, wantErr: true},
+		{newcfg: map[common.Address]*PrecompileConfig{addr: {Name: "l1sload", Block: big.NewInt(10)}}, head: 5},
+		{newcfg: map[common.Address]*PrecompileConfig{addr: {Name: "l1sload", Block: big.NewInt(10)}}, head: 10, wantErr: true},
+		{newcfg: nil, head: 12, wantErr: true},
+		{newcfg: map[common.Address]*PrecompileConfig{addr: {Name: "signal", Block: big.NewInt(10)}, {0x11}: {Name: "l1sload", Block: big.NewInt(5)}}, head: 12, wantErr: true},
+	} {
+		err := stored.checkPrecompilesCompatible(&ChainConfig{Precompiles: test.newcfg}, new(big.Int).SetUint64(test.head))
+		if (err != nil) != test.wantErr {
+			t.Errorf("test %d: error mismatch: have %v, want error %t", i, err, test.wantErr)
+		}
+	}
+	rules := stored.Rules(big.NewInt(9), false, 0)
+	if len(rules.Precompiles) != 0 {
+		t.Errorf("precompile active before its activation block: %v", rules.Precompiles)
+	}
+	rules = stored.Rules(big.NewInt(10), false, 0)
+	if rules.Precompiles[addr] != "signal" {
+		t.Errorf("precompile not active at its activation block: %v", rules.Precompiles)
+	}
+}
+
+func TestActivePrecompiles(t *testing.T) {
+	config := &ChainConfig{Precompiles: map[common.Address]*PrecompileConfig{
+		{0x10}: {Name: "signal", Block: big.NewInt(10)},
+		{0x11}: {Name: "l1sload", Block: big.NewInt(20)},
+		{0x12}: {Name: "inactive"},
+	}}
+	for _, test := range []struct {
+		head uint64
+		want map[common.Address]string
+	}{
+		{9, nil},
+		{10, map[common.Address]string{{0x10}: "signal"}},
+		{19, map[common.Address]string{{0x10}: "signal"}},
+		{20, map[common.Address]string{{0x10}: "signal", {0x11}: "l1sload"}},
+	} {
+		if have := config.activePrecompiles(new(big.Int).SetUint64(test.head)); !reflect.DeepEqual(have, test.want) {
+			t.Errorf("head %d: active precompiles mismatch: have %v, want %v", test.head, have, test.want)
+		}
+	}
+	// The active set is shared within a fork set
+	a, b := config.activePrecompiles(big.NewInt(10)), config.activePrecompiles(big.NewInt(15))
+	if reflect.ValueOf(a).UnsafePointer() != reflect.ValueOf(b).UnsafePointer() {
+		t.Error("active precompiles rebuilt within the same fork set")
+	}
+	// Replacing the configured precompiles is picked up
+	config.Precompiles = map[common.Address]*PrecompileConfig{{0x13}: {Name: "other", Block: big.NewInt(0)}}
+	if have := config.activePrecompiles(big.NewInt(10)); !reflect.DeepEqual(have, map[common.Address]string{{0x13}: "other"}) {
+		t.Errorf("replaced precompiles mismatch: have %v", have)
+	}
+}
+
+func TestEOFActivation(t *testing.T) {
+	config := *TaikoChainConfig
+	config.EOFBlock = big.NewInt(10)
+
+	if err := config.CheckConfigForkOrder(); err != nil {
+		t.Fatalf("unexpected fork order error: %v", err)
+	}
+	if config.Rules(big.NewInt(9), true, 0).IsEOF {
+		t.Error("EOF active before its activation block")
+	}
+	if !config.Rules(big.NewInt(10), true, 0).IsEOF {
+		t.Error("EOF not active at its activation block without Cancun")
+	}
+	config.ShanghaiTime = nil
+	if err := config.CheckConfigForkOrder(); err == nil {
+		t.Error("EOF without Shanghai not rejected")
+	}
+}
@@ -28,6 +28,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/internal/cachejournal"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/rlp"
@@ -64,6 +65,9 @@ var (
 // Config contains the settings for database.
 type Config struct {
 	CleanCacheSize int // Maximum memory allowance (in bytes) for caching clean nodes
+
+	// CHANGE(taiko): persist the clean cache across restarts.
+	CleanCacheJournal string // Directory to persist the clean cache on close, disabled if empty
 }
 
 // Defaults is the default setting for database if it's not specified.
@@ -81,6 +85,8 @@ var Defaults = &Config{
 type Database struct {
 	diskdb  ethdb.Database              // Persistent storage for matured trie nodes
 	cleans  *fastcache.Cache            // GC friendly memory cache of clean node RLPs
+	journal string                      // CHANGE(taiko): directory to persist the clean cache
+	tracker *cachejournal.Tracker       // CHANGE(taiko): access tracker of the clean cache, nil if it's not persisted
 	dirties map[common.Hash]*cachedNode // Data and references relationships of dirty trie nodes
 	oldest  common.Hash                 // Oldest tracked node, flush-list head
 	newest  common.Hash                 // Newest tracked node, flush-list tail
@@ -129,13 +135,26 @@ func New(diskdb ethdb.Database, config *Config) *Database {
 	if config == nil {
 		config = Defaults
 	}
-	var cleans *fastcache.Cache
+	var (
+		cleans  *fastcache.Cache
+		tracker *cachejournal.Tracker
+	)
 	if config.CleanCacheSize > 0 {
-		cleans = fastcache.New(config.CleanCacheSize)
+		// CHANGE(taiko): warm up the clean cache with the persisted one. The
+		// nodes are content-addressed, valid regardless of the persistent state.
+		if config.CleanCacheJournal != "" {
+			cleans = cachejournal.Load(config.CleanCacheJournal, config.CleanCacheSize, common.Hash{})
+			tracker = cachejournal.NewTracker(config.CleanCacheSize)
+		}
+		if cleans == nil {
+			cleans = fastcache.New(config.CleanCacheSize)
+		}
 	}
 	return &Database{
 		diskdb:  diskdb,
 		cleans:  cleans,
+		journal: config.CleanCacheJournal,
+		tracker: tracker,
 		dirties: make(map[common.Hash]*cachedNode),
 	}
 }
@@ -181,6 +200,7 @@ func (db *Database) node(hash common.Hash) ([]byte, error) {
 	// Retrieve the node from the clean cache if available
 	if db.cleans != nil {
 		if enc := db.cleans.Get(nil, hash[:]); enc != nil {
+			db.tracker.Touch(hash[:]) // CHANGE(taiko)
 			memcacheCleanHitMeter.Mark(1)
 			memcacheCleanReadMeter.Mark(int64(len(enc)))
 			return enc, nil
@@ -206,6 +226,7 @@ func (db *Database) node(hash common.Hash) ([]byte, error) {
 	if len(enc) != 0 {
 		if db.cleans != nil {
 			db.cleans.Set(hash[:], enc)
+			db.tracker.Touch(hash[:]) // CHANGE(taiko)
 			memcacheCleanMissMeter.Mark(1)
 			memcacheCleanWriteMeter.Mark(int64(len(enc)))
 		}
@@ -611,6 +632,12 @@ func (db *Database) Size() (common.StorageSize, common.StorageSize) {
 // Close closes the trie database and releases all held resources.
 func (db *Database) Close() error {
 	if db.cleans != nil {
+		// CHANGE(taiko): persist the clean cache for the next startup.
+		if db.journal != "" {
+			if err := cachejournal.Save(db.cleans, db.tracker, db.journal, common.Hash{}); err != nil {
+				log.Warn("Failed to persist clean cache", "err", err)
+			}
+		}
 		db.cleans.Reset()
 	}
 	return nil
@@ -70,3 +70,26 @@ func (db *Database) HistoryRange() (uint64, uint64, error) {
 	}
 	return pdb.HistoryRange()
 }
+
+// HistoricReader constructs a reader for accessing the requested historic state.
+//
+// This function is only supported by path mode database.
+func (db *Database) HistoricReader(root common.Hash) (*pathdb.HistoricalStateReader, error) {
+	pdb, ok := db.backend.(*pathdb.Database)
+	if !ok {
+		return nil, errors.New("not supported")
+	}
+	return pdb.HistoricReader(root)
+}
+
+// PruneHistory removes all the state histories except the most recent ones
+// specified by keep, returning the number of pruned histories.
+//
+// This function is only supported by path mode database.
+func (db *Database) PruneHistory(keep uint64) (int, error) {
+	pdb, ok := db.backend.(*pathdb.Database)
+	if !ok {
+		return 0, errors.New("not supported")
+	}
+	return pdb.PruneHistory(keep)
+}
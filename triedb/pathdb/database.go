@@ -28,6 +28,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/internal/cachejournal"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/trie/trienode"
@@ -50,6 +51,10 @@ const (
 	// Do not increase the buffer size arbitrarily, otherwise the system
 	// pause time will increase when the database writes happen.
 	DefaultBufferSize = 64 * 1024 * 1024
+
+	// historyPruneBatch is the number of state histories to prune at once,
+	// bounding the memory used for loading the metadata of pruned histories.
+	historyPruneBatch = 10000
 )
 
 var (
@@ -90,10 +95,14 @@ type layer interface {
 
 // Config contains the settings for database.
 type Config struct {
-	StateHistory   uint64 // Number of recent blocks to maintain state history for
-	CleanCacheSize int    // Maximum memory allowance (in bytes) for caching clean nodes
-	DirtyCacheSize int    // Maximum memory allowance (in bytes) for caching dirty nodes
-	ReadOnly       bool   // Flag whether the database is opened in read only mode.
+	StateHistory        uint64 // Number of recent blocks to maintain state history for
+	EnableStateIndexing bool   // Whether to index the state histories for serving historical state
+	CleanCacheSize      int    // Maximum memory allowance (in bytes) for caching clean nodes
+	DirtyCacheSize      int    // Maximum memory allowance (in bytes) for caching dirty nodes
+	ReadOnly            bool   // Flag whether the database is opened in read only mode.
+
+	// CHANGE(taiko): persist the clean cache across restarts.
+	CleanCacheJournal string // Directory to persist the clean cache on close, disabled if empty
 }
 
 // sanitize checks the provided user configurations and changes anything that's
@@ -140,6 +149,8 @@ type Database struct {
 	diskdb     ethdb.Database               // Persistent storage for matured trie nodes
 	tree       *layerTree                   // The group for all known layers
 	freezer    ethdb.ResettableAncientStore // Freezer for storing trie histories, nil possible in tests
+	indexer    *historyIndexer              // Indexer of state histories, nil if historical state is not served
+	tracker    *cachejournal.Tracker        // CHANGE(taiko): access tracker of the clean cache, nil if it's not persisted
 	lock       sync.RWMutex                 // Lock to prevent mutations from happening at the same time
 }
 
@@ -167,6 +178,11 @@ func New(diskdb ethdb.Database, config *Config, isVerkle bool) *Database {
 		config:     config,
 		diskdb:     diskdb,
 	}
+	// CHANGE(taiko): track the accesses of the clean cache to persist the
+	// hottest entries on shutdown.
+	if config.CleanCacheJournal != "" && config.CleanCacheSize > 0 {
+		db.tracker = cachejournal.NewTracker(config.CleanCacheSize)
+	}
 	// Construct the layer tree by resolving the in-disk singleton state
 	// and in-memory layer journal.
 	db.tree = newLayerTree(db.loadLayers())
@@ -176,6 +192,10 @@ func New(diskdb ethdb.Database, config *Config, isVerkle bool) *Database {
 	if err := db.repairHistory(); err != nil {
 		log.Crit("Failed to repair pathdb", "err", err)
 	}
+	// Start indexing the state histories not yet covered by the index.
+	if db.indexer != nil {
+		db.indexer.extend()
+	}
 	// Disable database in case node is still in the initial state sync stage.
 	if rawdb.ReadSnapSyncStatusFlag(diskdb) == rawdb.StateSyncRunning && !db.readOnly {
 		if err := db.Disable(); err != nil {
@@ -204,6 +224,19 @@ func (db *Database) repairHistory() error {
 	}
 	db.freezer = freezer
 
+	// Set up the state history indexer if historical state is served. The
+	// leftover index is invalidated otherwise, since it won't be maintained
+	// anymore and has to be rebuilt once the indexing is enabled again.
+	if db.config.EnableStateIndexing && !db.readOnly {
+		if db.isVerkle {
+			log.Warn("State history indexing is not supported in verkle")
+		} else {
+			db.indexer = newHistoryIndexer(db.diskdb, db.freezer)
+		}
+	} else if !db.readOnly && rawdb.ReadStateHistoryIndexHead(db.diskdb) != nil {
+		rawdb.DeleteStateHistoryIndexHead(db.diskdb)
+		log.Info("Invalidated state history index")
+	}
 	// Reset the entire state histories if the trie database is not initialized
 	// yet. This action is necessary because these state histories are not
 	// expected to exist without an initialized trie database.
@@ -214,23 +247,33 @@ func (db *Database) repairHistory() error {
 			log.Crit("Failed to retrieve head of state history", "err", err)
 		}
 		if frozen != 0 {
-			err := db.freezer.Reset()
+			err := db.resetHistory()
 			if err != nil {
 				log.Crit("Failed to reset state histories", "err", err)
 			}
 			log.Info("Truncated extraneous state history")
+		} else if !db.readOnly {
+			rawdb.DeleteStateHistoryPruneTarget(db.diskdb)
 		}
 		return nil
 	}
 	// Truncate the extra state histories above in freezer in case it's not
 	// aligned with the disk layer. It might happen after a unclean shutdown.
-	pruned, err := truncateFromHead(db.diskdb, db.freezer, id)
+	pruned, err := db.truncateHistoryHead(id)
 	if err != nil {
 		log.Crit("Failed to truncate extra state histories", "err", err)
 	}
 	if pruned != 0 {
 		log.Warn("Truncated extra state histories", "number", pruned)
 	}
+	// Resume the interrupted state history pruning if there is any, otherwise
+	// the histories are left pruned partially.
+	if target := rawdb.ReadStateHistoryPruneTarget(db.diskdb); target != nil && !db.readOnly {
+		log.Info("Resuming state history pruning", "target", *target)
+		if _, err := db.pruneHistory(min(*target, id)); err != nil {
+			log.Crit("Failed to resume state history pruning", "err", err)
+		}
+	}
 	return nil
 }
 
@@ -335,7 +378,7 @@ func (db *Database) Enable(root common.Hash) error {
 	// mappings can be huge and might take a while to clear
 	// them, just leave them in disk and wait for overwriting.
 	if db.freezer != nil {
-		if err := db.freezer.Reset(); err != nil {
+		if err := db.resetHistory(); err != nil {
 			return err
 		}
 	}
@@ -389,7 +432,7 @@ func (db *Database) Recover(root common.Hash) error {
 		db.tree.reset(dl)
 	}
 	rawdb.DeleteTrieJournal(db.diskdb)
-	_, err := truncateFromHead(db.diskdb, db.freezer, dl.stateID())
+	_, err := db.truncateHistoryHead(dl.stateID())
 	if err != nil {
 		return err
 	}
@@ -435,6 +478,11 @@ func (db *Database) Close() error {
 	db.lock.Lock()
 	defer db.lock.Unlock()
 
+	// CHANGE(taiko): persist the clean cache for the next startup, unless
+	// the database is opened in read only mode or the state is being synced.
+	if !db.config.ReadOnly && !db.waitSync {
+		db.saveCleanCache()
+	}
 	// Set the database to read-only mode to prevent all
 	// following mutations.
 	db.readOnly = true
@@ -442,6 +490,10 @@ func (db *Database) Close() error {
 	// Release the memory held by clean cache.
 	db.tree.bottom().resetCache()
 
+	// Terminate the state history indexing.
+	if db.indexer != nil {
+		db.indexer.close()
+	}
 	// Close the attached state history freezer.
 	if db.freezer == nil {
 		return nil
@@ -449,6 +501,105 @@ func (db *Database) Close() error {
 	return db.freezer.Close()
 }
 
+// truncateHistoryHead removes the state histories above nhead from the freezer,
+// along with their index entries if the state histories are indexed.
+func (db *Database) truncateHistoryHead(nhead uint64) (int, error) {
+	if db.indexer == nil {
+		return truncateFromHead(db.diskdb, db.freezer, nhead)
+	}
+	var pruned int
+	err := db.indexer.truncateHead(nhead, func() (err error) {
+		pruned, err = truncateFromHead(db.diskdb, db.freezer, nhead)
+		return err
+	})
+	return pruned, err
+}
+
+// truncateHistoryTail removes the state histories up to ntail from the freezer,
+// along with their index entries if the state histories are indexed.
+func (db *Database) truncateHistoryTail(ntail uint64) (int, error) {
+	if db.indexer == nil {
+		return truncateFromTail(db.diskdb, db.freezer, ntail)
+	}
+	var pruned int
+	err := db.indexer.pruneTail(ntail, func() (err error) {
+		pruned, err = truncateFromTail(db.diskdb, db.freezer, ntail)
+		return err
+	})
+	return pruned, err
+}
+
+// resetHistory wipes all the state histories from the freezer, along with the
+// entire index if the state histories are indexed.
+func (db *Database) resetHistory() error {
+	// The pending pruning is meaningless for the wiped state histories.
+	rawdb.DeleteStateHistoryPruneTarget(db.diskdb)
+
+	if db.indexer == nil {
+		return db.freezer.Reset()
+	}
+	return db.indexer.reset(db.freezer.Reset)
+}
+
+// PruneHistory removes all the state histories except the most recent ones
+// specified by keep, along with their state lookups and index entries. The
+// pruning target is persisted beforehand, so that it can be resumed in the
+// next start if the pruning is interrupted.
+func (db *Database) PruneHistory(keep uint64) (int, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if err := db.modifyAllowed(); err != nil {
+		return 0, err
+	}
+	if db.freezer == nil {
+		return 0, errors.New("state history is not supported")
+	}
+	head, err := db.freezer.Ancients()
+	if err != nil {
+		return 0, err
+	}
+	tail, err := db.freezer.Tail()
+	if err != nil {
+		return 0, err
+	}
+	if head <= tail+keep {
+		return 0, nil
+	}
+	rawdb.WriteStateHistoryPruneTarget(db.diskdb, head-keep)
+	return db.pruneHistory(head - keep)
+}
+
+// pruneHistory removes the state histories up to ntail in batches, and clears
+// the persisted pruning target once it's finished.
+func (db *Database) pruneHistory(ntail uint64) (int, error) {
+	tail, err := db.freezer.Tail()
+	if err != nil {
+		return 0, err
+	}
+	var (
+		start  = time.Now()
+		logged = time.Now()
+		pruned int
+	)
+	for tail < ntail {
+		next := min(tail+historyPruneBatch, ntail)
+		n, err := db.truncateHistoryTail(next)
+		if err != nil {
+			return pruned, err
+		}
+		pruned, tail = pruned+n, next
+
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Pruning state histories", "pruned", pruned, "tail", tail, "target", ntail, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	rawdb.DeleteStateHistoryPruneTarget(db.diskdb)
+	log.Info("Pruned state histories", "pruned", pruned, "tail", tail, "elapsed", common.PrettyDuration(time.Since(start)))
+	return pruned, nil
+}
+
 // Size returns the current storage size of the memory cache in front of the
 // persistent database layer.
 func (db *Database) Size() (diffs common.StorageSize, nodes common.StorageSize) {
@@ -23,10 +23,12 @@ import (
 	"math/rand"
 	"testing"
 
+	"github.com/VictoriaMetrics/fastcache"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/cachejournal"
 	"github.com/ethereum/go-ethereum/internal/testrand"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/trie"
@@ -104,13 +106,14 @@ type tester struct {
 	snapStorages map[common.Hash]map[common.Hash]map[common.Hash][]byte
 }
 
-func newTester(t *testing.T, historyLimit uint64) *tester {
+func newTester(t *testing.T, historyLimit uint64, enableIndex bool) *tester {
 	var (
 		disk, _ = rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), t.TempDir(), "", false)
 		db      = New(disk, &Config{
-			StateHistory:   historyLimit,
-			CleanCacheSize: 16 * 1024,
-			DirtyCacheSize: 16 * 1024,
+			StateHistory:        historyLimit,
+			EnableStateIndexing: enableIndex,
+			CleanCacheSize:      16 * 1024,
+			DirtyCacheSize:      16 * 1024,
 		}, false)
 		obj = &tester{
 			db:           db,
@@ -405,7 +408,7 @@ func TestDatabaseRollback(t *testing.T) {
 	}()
 
 	// Verify state histories
-	tester := newTester(t, 0)
+	tester := newTester(t, 0, false)
 	defer tester.release()
 
 	if err := tester.verifyHistory(); err != nil {
@@ -439,7 +442,7 @@ func TestDatabaseRecoverable(t *testing.T) {
 	}()
 
 	var (
-		tester = newTester(t, 0)
+		tester = newTester(t, 0, false)
 		index  = tester.bottomIndex()
 	)
 	defer tester.release()
@@ -483,7 +486,7 @@ func TestDisable(t *testing.T) {
 		maxDiffLayers = 128
 	}()
 
-	tester := newTester(t, 0)
+	tester := newTester(t, 0, false)
 	defer tester.release()
 
 	stored := crypto.Keccak256Hash(rawdb.ReadAccountTrieNode(tester.db.diskdb, nil))
@@ -525,7 +528,7 @@ func TestCommit(t *testing.T) {
 		maxDiffLayers = 128
 	}()
 
-	tester := newTester(t, 0)
+	tester := newTester(t, 0, false)
 	defer tester.release()
 
 	if err := tester.db.Commit(tester.lastHash(), false); err != nil {
@@ -555,7 +558,7 @@ func TestJournal(t *testing.T) {
 		maxDiffLayers = 128
 	}()
 
-	tester := newTester(t, 0)
+	tester := newTester(t, 0, false)
 	defer tester.release()
 
 	if err := tester.db.Journal(tester.lastHash()); err != nil {
@@ -578,6 +581,53 @@ func TestJournal(t *testing.T) {
 	}
 }
 
+func TestCleanCacheJournal(t *testing.T) {
+	// Redefine the diff layer depth allowance for faster testing.
+	maxDiffLayers = 4
+	defer func() {
+		maxDiffLayers = 128
+	}()
+
+	tester := newTester(t, 0, false)
+	defer tester.release()
+
+	// Warm up the clean cache with the persistent state, tracking the accesses.
+	config := &Config{CleanCacheSize: 16 * 1024, CleanCacheJournal: t.TempDir()}
+	tester.db.config.CleanCacheJournal = config.CleanCacheJournal
+	tester.db.tracker = cachejournal.NewTracker(tester.db.config.CleanCacheSize)
+
+	if err := tester.verifyState(tester.roots[tester.bottomIndex()]); err != nil {
+		t.Fatalf("Invalid state, err: %v", err)
+	}
+	if err := tester.db.Journal(tester.lastHash()); err != nil {
+		t.Errorf("Failed to journal, err: %v", err)
+	}
+	tester.db.Close()
+
+	// The persisted clean cache is loaded for the same persistent state.
+	tester.db = New(tester.db.diskdb, config, false)
+	var stats fastcache.Stats
+	tester.db.tree.bottom().cleans.UpdateStats(&stats)
+	if stats.EntriesCount == 0 {
+		t.Fatal("Clean cache is not loaded")
+	}
+	for i := tester.bottomIndex(); i < len(tester.roots); i++ {
+		if err := tester.verifyState(tester.roots[i]); err != nil {
+			t.Fatalf("Invalid state, err: %v", err)
+		}
+	}
+	// The persisted clean cache is discarded if the persistent state changes.
+	tester.db.Close()
+	rawdb.WriteAccountTrieNode(tester.db.diskdb, nil, []byte{0x01})
+	tester.db = New(tester.db.diskdb, config, false)
+
+	stats = fastcache.Stats{}
+	tester.db.tree.bottom().cleans.UpdateStats(&stats)
+	if stats.EntriesCount != 0 {
+		t.Fatalf("Stale clean cache is loaded, entries: %d", stats.EntriesCount)
+	}
+}
+
 func TestCorruptedJournal(t *testing.T) {
 	// Redefine the diff layer depth allowance for faster testing.
 	maxDiffLayers = 4
@@ -585,7 +635,7 @@ func TestCorruptedJournal(t *testing.T) {
 		maxDiffLayers = 128
 	}()
 
-	tester := newTester(t, 0)
+	tester := newTester(t, 0, false)
 	defer tester.release()
 
 	if err := tester.db.Journal(tester.lastHash()); err != nil {
@@ -633,7 +683,7 @@ func TestTailTruncateHistory(t *testing.T) {
 		maxDiffLayers = 128
 	}()
 
-	tester := newTester(t, 10)
+	tester := newTester(t, 10, false)
 	defer tester.release()
 
 	tester.db.Close()
@@ -649,6 +699,65 @@ func TestTailTruncateHistory(t *testing.T) {
 	}
 }
 
+func TestPruneHistory(t *testing.T) {
+	// Redefine the diff layer depth allowance for faster testing.
+	maxDiffLayers = 4
+	defer func() {
+		maxDiffLayers = 128
+	}()
+
+	tester := newTester(t, 0, false)
+	defer tester.release()
+
+	head, err := tester.db.freezer.Ancients()
+	if err != nil {
+		t.Fatalf("Failed to obtain freezer head: %v", err)
+	}
+	pruned, err := tester.db.PruneHistory(head - 4)
+	if err != nil {
+		t.Fatalf("Failed to prune state histories: %v", err)
+	}
+	if pruned != 4 {
+		t.Fatalf("Unexpected pruned histories, want %d, got %d", 4, pruned)
+	}
+	tail, err := tester.db.freezer.Tail()
+	if err != nil {
+		t.Fatalf("Failed to obtain freezer tail: %v", err)
+	}
+	if tail != 4 {
+		t.Fatalf("Unexpected freezer tail, want %d, got %d", 4, tail)
+	}
+	// The state lookups of the pruned histories should be removed
+	for i := 0; i < int(tail); i++ {
+		if rawdb.ReadStateID(tester.db.diskdb, tester.roots[i]) != nil {
+			t.Fatalf("Unexpected state lookup of pruned history %d", i+1)
+		}
+	}
+	if rawdb.ReadStateHistoryPruneTarget(tester.db.diskdb) != nil {
+		t.Fatal("Unexpected leftover pruning target")
+	}
+	// Flush all the layers into disk, the resumption is capped by the persistent
+	// state id otherwise, which depends on when the dirty cache was flushed
+	if err := tester.db.Commit(tester.lastHash(), false); err != nil {
+		t.Fatalf("Failed to commit state: %v", err)
+	}
+	// Simulate an interrupted pruning, it should be resumed in the next start
+	rawdb.WriteStateHistoryPruneTarget(tester.db.diskdb, 6)
+	tester.db.Close()
+	tester.db = New(tester.db.diskdb, nil, false)
+
+	tail, err = tester.db.freezer.Tail()
+	if err != nil {
+		t.Fatalf("Failed to obtain freezer tail: %v", err)
+	}
+	if tail != 6 {
+		t.Fatalf("Unexpected freezer tail after resumption, want %d, got %d", 6, tail)
+	}
+	if rawdb.ReadStateHistoryPruneTarget(tester.db.diskdb) != nil {
+		t.Fatal("Unexpected leftover pruning target after resumption")
+	}
+}
+
 // copyAccounts returns a deep-copied account set of the provided one.
 func copyAccounts(set map[common.Hash][]byte) map[common.Hash][]byte {
 	copied := make(map[common.Hash][]byte, len(set))
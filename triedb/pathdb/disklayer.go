@@ -122,6 +122,7 @@ func (dl *diskLayer) node(owner common.Hash, path []byte, depth int) ([]byte, co
 	key := cacheKey(owner, path)
 	if dl.cleans != nil {
 		if blob := dl.cleans.Get(nil, key); len(blob) > 0 {
+			dl.db.tracker.Touch(key) // CHANGE(taiko)
 			cleanHitMeter.Mark(1)
 			cleanReadMeter.Mark(int64(len(blob)))
 			return blob, h.hash(blob), &nodeLoc{loc: locCleanCache, depth: depth}, nil
@@ -137,6 +138,7 @@ func (dl *diskLayer) node(owner common.Hash, path []byte, depth int) ([]byte, co
 	}
 	if dl.cleans != nil && len(blob) > 0 {
 		dl.cleans.Set(key, blob)
+		dl.db.tracker.Touch(key) // CHANGE(taiko)
 		cleanWriteMeter.Mark(int64(len(blob)))
 	}
 	return blob, h.hash(blob), &nodeLoc{loc: locDiskLayer, depth: depth}, nil
@@ -208,12 +210,16 @@ func (dl *diskLayer) commit(bottom *diffLayer, force bool) (*diskLayer, error) {
 	// To remove outdated history objects from the end, we set the 'tail' parameter
 	// to 'oldest-1' due to the offset between the freezer index and the history ID.
 	if overflow {
-		pruned, err := truncateFromTail(ndl.db.diskdb, ndl.db.freezer, oldest-1)
+		pruned, err := ndl.db.truncateHistoryTail(oldest - 1)
 		if err != nil {
 			return nil, err
 		}
 		log.Debug("Pruned state history", "items", pruned, "tailid", oldest)
 	}
+	// Notify the indexer about the newly written state history.
+	if ndl.db.indexer != nil {
+		ndl.db.indexer.extend()
+	}
 	return ndl, nil
 }
 
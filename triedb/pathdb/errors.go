@@ -39,4 +39,16 @@ var (
 	// errStateUnrecoverable is returned if state is required to be reverted to
 	// a destination without associated state history available.
 	errStateUnrecoverable = errors.New("state is unrecoverable")
+
+	// errStateIndexDisabled is returned if historical state is requested while
+	// the state history indexing is not enabled.
+	errStateIndexDisabled = errors.New("state history indexing is disabled")
+
+	// errStateIndexNotReady is returned if historical state is requested while
+	// the state history index is still far behind the persistent state.
+	errStateIndexNotReady = errors.New("state history index is not ready")
+
+	// errStateHistoryPruned is returned if historical state is requested which
+	// is older than the retained state histories.
+	errStateHistoryPruned = errors.New("state history is pruned")
 )
@@ -0,0 +1,330 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>
+
+package pathdb
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// State history index
+//
+// The state histories only record the original values of the states mutated in
+// each state transition, which is sufficient for rolling back the state, but
+// too slow to serve historical state reads: finding the value of a state at a
+// given point would require scanning all the subsequent histories.
+//
+// The index maps each account and storage slot to the ids of the state histories
+// in which it was modified, as a set of empty key-value entries sorted by id:
+//
+//   StateHistoryAccountIndexPrefix + address + id                -> nil
+//   StateHistoryStorageIndexPrefix + address + slot hash + id    -> nil
+//
+// The value of a state at state n is then the original value recorded in the
+// first history after n in which the state was modified, or the value in the
+// disk layer if it was never modified afterwards.
+//
+// The index is constructed by a background indexer, which tracks the progress
+// by the id of the latest indexed history, and is kept aligned with the state
+// histories in the freezer as they are pruned or truncated. Entries referring
+// to histories which no longer exist might be leftover after an unclean shutdown,
+// these are harmless as every lookup is verified against the history itself.
+
+// indexBatch is the number of state histories to index before committing the
+// progress to disk and yielding to the other index mutations.
+const indexBatch = 1000
+
+// historyIndexer maintains the index of state histories in the background.
+type historyIndexer struct {
+	disk    ethdb.KeyValueStore
+	freezer ethdb.AncientReader
+	head    atomic.Uint64 // Id of the latest indexed state history
+	lock    sync.Mutex    // Lock serializing the index mutations
+
+	notify chan struct{}  // Channel to trigger the indexing of new histories
+	closed chan struct{}  // Channel to signal the indexer termination
+	wg     sync.WaitGroup // Tracks the background indexing goroutine
+}
+
+// newHistoryIndexer creates the state history indexer. The histories not yet
+// covered by the index are indexed in the background once notified.
+func newHistoryIndexer(disk ethdb.KeyValueStore, freezer ethdb.AncientReader) *historyIndexer {
+	indexer := &historyIndexer{
+		disk:    disk,
+		freezer: freezer,
+		notify:  make(chan struct{}, 1),
+		closed:  make(chan struct{}),
+	}
+	if head := rawdb.ReadStateHistoryIndexHead(disk); head != nil {
+		indexer.head.Store(*head)
+	}
+	indexer.wg.Add(1)
+	go indexer.loop()
+
+	return indexer
+}
+
+// loop indexes the newly written state histories whenever notified.
+func (i *historyIndexer) loop() {
+	defer i.wg.Done()
+
+	for {
+		select {
+		case <-i.notify:
+			if err := i.index(); err != nil {
+				log.Error("Failed to index state histories", "err", err)
+			}
+		case <-i.closed:
+			return
+		}
+	}
+}
+
+// extend notifies the indexer that new state histories are available.
+func (i *historyIndexer) extend() {
+	select {
+	case i.notify <- struct{}{}:
+	default:
+	}
+}
+
+// indexed returns the id of the latest indexed state history.
+func (i *historyIndexer) indexed() uint64 {
+	return i.head.Load()
+}
+
+// index indexes all the state histories in the freezer not yet covered by the
+// index, in batches, until caught up or terminated.
+func (i *historyIndexer) index() error {
+	var (
+		start   = time.Now()
+		logged  = time.Now()
+		indexed int
+	)
+	for {
+		select {
+		case <-i.closed:
+			return nil
+		default:
+		}
+		done, n, err := i.indexBatch()
+		if err != nil {
+			return err
+		}
+		indexed += n
+		if done {
+			break
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Indexing state histories", "indexed", indexed, "head", i.indexed(), "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if indexed > 0 {
+		log.Debug("Indexed state histories", "count", indexed, "head", i.indexed(), "elapsed", common.PrettyDuration(time.Since(start)))
+	}
+	return nil
+}
+
+// indexBatch indexes the next batch of state histories. It returns whether the
+// index caught up with the freezer along with the number of indexed histories.
+func (i *historyIndexer) indexBatch() (bool, int, error) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	head, err := i.freezer.Ancients()
+	if err != nil {
+		return false, 0, err
+	}
+	tail, err := i.freezer.Tail()
+	if err != nil {
+		return false, 0, err
+	}
+	// The index head can only be ahead of the freezer if the process crashed
+	// in between the freezer truncation and the index update, rewind it.
+	if i.indexed() > head {
+		rawdb.WriteStateHistoryIndexHead(i.disk, head)
+		i.head.Store(head)
+	}
+	// The histories below the tail are already pruned, skip them.
+	next := i.indexed() + 1
+	if next <= tail {
+		next = tail + 1
+	}
+	if next > head {
+		return true, 0, nil
+	}
+	last := min(head, next+indexBatch-1)
+
+	batch := i.disk.NewBatch()
+	for id := next; id <= last; id++ {
+		h, err := readHistory(i.freezer, id)
+		if err != nil {
+			return false, 0, err
+		}
+		indexHistory(batch, h, id)
+	}
+	rawdb.WriteStateHistoryIndexHead(batch, last)
+	if err := batch.Write(); err != nil {
+		return false, 0, err
+	}
+	i.head.Store(last)
+	historyIndexedMeter.Mark(int64(last - next + 1))
+
+	return last == head, int(last - next + 1), nil
+}
+
+// pruneTail removes the index entries of the state histories in range
+// [otail+1, ntail] as they are pruned from the freezer. The histories are
+// loaded before and the entries removed only after the freezer truncation,
+// so that any concurrent reader finding an entry missing is guaranteed to
+// observe the new freezer tail as well.
+func (i *historyIndexer) pruneTail(ntail uint64, truncate func() error) error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	otail, err := i.freezer.Tail()
+	if err != nil {
+		return err
+	}
+	var histories []*history
+	for id := otail + 1; id <= min(ntail, i.indexed()); id++ {
+		h, err := readHistory(i.freezer, id)
+		if err != nil {
+			return err
+		}
+		histories = append(histories, h)
+	}
+	if err := truncate(); err != nil {
+		return err
+	}
+	batch := i.disk.NewBatch()
+	for n, h := range histories {
+		unindexHistory(batch, h, otail+1+uint64(n))
+	}
+	return batch.Write()
+}
+
+// truncateHead removes the index entries of the state histories above nhead
+// as they are truncated from the freezer, rewinding the index head if needed.
+func (i *historyIndexer) truncateHead(nhead uint64, truncate func() error) error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	ohead, err := i.freezer.Ancients()
+	if err != nil {
+		return err
+	}
+	var histories []*history
+	for id := nhead + 1; id <= min(ohead, i.indexed()); id++ {
+		h, err := readHistory(i.freezer, id)
+		if err != nil {
+			return err
+		}
+		histories = append(histories, h)
+	}
+	if err := truncate(); err != nil {
+		return err
+	}
+	batch := i.disk.NewBatch()
+	for n, h := range histories {
+		unindexHistory(batch, h, nhead+1+uint64(n))
+	}
+	if i.indexed() > nhead {
+		rawdb.WriteStateHistoryIndexHead(batch, nhead)
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	if i.indexed() > nhead {
+		i.head.Store(nhead)
+	}
+	return nil
+}
+
+// reset drops the entire state history index, as the freezer is wiped.
+func (i *historyIndexer) reset(reset func() error) error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if err := reset(); err != nil {
+		return err
+	}
+	batch := i.disk.NewBatch()
+	for _, prefix := range [][]byte{rawdb.StateHistoryAccountIndexPrefix, rawdb.StateHistoryStorageIndexPrefix} {
+		it := i.disk.NewIterator(prefix, nil)
+		for it.Next() {
+			batch.Delete(it.Key())
+			if batch.ValueSize() >= ethdb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					it.Release()
+					return err
+				}
+				batch.Reset()
+			}
+		}
+		it.Release()
+	}
+	rawdb.DeleteStateHistoryIndexHead(batch)
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	i.head.Store(0)
+	return nil
+}
+
+// close terminates the background indexing.
+func (i *historyIndexer) close() {
+	select {
+	case <-i.closed:
+	default:
+		close(i.closed)
+	}
+	i.wg.Wait()
+}
+
+// indexHistory writes the index entries of all the states modified in the
+// given state history.
+func indexHistory(db ethdb.KeyValueWriter, h *history, id uint64) {
+	for _, addr := range h.accountList {
+		rawdb.WriteAccountHistoryIndex(db, addr, id)
+	}
+	for addr, slots := range h.storageList {
+		for _, slot := range slots {
+			rawdb.WriteStorageHistoryIndex(db, addr, slot, id)
+		}
+	}
+}
+
+// unindexHistory removes the index entries of all the states modified in the
+// given state history.
+func unindexHistory(db ethdb.KeyValueWriter, h *history, id uint64) {
+	for _, addr := range h.accountList {
+		rawdb.DeleteAccountHistoryIndex(db, addr, id)
+	}
+	for addr, slots := range h.storageList {
+		for _, slot := range slots {
+			rawdb.DeleteStorageHistoryIndex(db, addr, slot, id)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>
+
+package pathdb
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/triedb/database"
+)
+
+// maxUnindexedHistories is the maximum number of state histories not yet covered
+// by the index which are allowed to be scanned for serving a historical state
+// read. Beyond that, the read is rejected until the indexer catches up.
+const maxUnindexedHistories = 1024
+
+// layerDatabase implements the database.Database interface, binding all the trie
+// node reads to a specific state layer.
+type layerDatabase struct {
+	layer layer
+}
+
+// Reader implements database.Database, returning the reader of the bound layer.
+func (db *layerDatabase) Reader(root common.Hash) (database.Reader, error) {
+	if root != db.layer.rootHash() {
+		return nil, fmt.Errorf("state %#x is not available", root)
+	}
+	return &reader{layer: db.layer}, nil
+}
+
+// HistoricalStateReader provides the access to the account and storage state
+// at a historical point within the retained state history window. It's backed
+// by the persistent state of the disk layer, along with the state histories
+// which are applied in reverse to find the historical values.
+type HistoricalStateReader struct {
+	db *Database
+	id uint64 // State id of the requested historical state
+}
+
+// HistoricReader constructs a reader for accessing the requested historic state.
+// Only the states not newer than the disk layer and with all the subsequent state
+// histories retained are supported.
+func (db *Database) HistoricReader(root common.Hash) (*HistoricalStateReader, error) {
+	if db.indexer == nil {
+		return nil, errStateIndexDisabled
+	}
+	root = types.TrieRootHash(root)
+	id := rawdb.ReadStateID(db.diskdb, root)
+	if id == nil {
+		return nil, fmt.Errorf("state %#x is not available", root)
+	}
+	if *id > db.tree.bottom().stateID() {
+		return nil, fmt.Errorf("state %#x is not yet persisted", root)
+	}
+	r := &HistoricalStateReader{db: db, id: *id}
+	if err := r.available(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// available checks that the state histories after the requested state are still
+// retained in the freezer.
+func (r *HistoricalStateReader) available() error {
+	tail, err := r.db.freezer.Tail()
+	if err != nil {
+		return err
+	}
+	if r.id < tail {
+		return fmt.Errorf("%w: state id %d, oldest %d", errStateHistoryPruned, r.id, tail)
+	}
+	return nil
+}
+
+// checked returns the resolved value if the state histories it was derived
+// from were not pruned in the meantime.
+func (r *HistoricalStateReader) checked(blob []byte) ([]byte, error) {
+	if err := r.available(); err != nil {
+		return nil, err
+	}
+	return blob, nil
+}
+
+// Account returns the account data in the slim-RLP format at the historical
+// state. Nil is returned if the account was not existent.
+func (r *HistoricalStateReader) Account(address common.Address) ([]byte, error) {
+	defer func(start time.Time) { historicAccountReadTimer.UpdateSince(start) }(time.Now())
+
+	lookup := func(start uint64) (uint64, bool) {
+		return rawdb.ReadAccountHistoryIndexFrom(r.db.diskdb, address, start)
+	}
+	extract := func(h *history) ([]byte, bool) {
+		blob, ok := h.accounts[address]
+		return blob, ok
+	}
+	resolve := func(dl *diskLayer) ([]byte, error) {
+		account, err := readAccount(dl, crypto.Keccak256Hash(address.Bytes()))
+		if err != nil || account == nil {
+			return nil, err
+		}
+		return types.SlimAccountRLP(*account), nil
+	}
+	return r.read(lookup, extract, resolve)
+}
+
+// Storage returns the storage slot in the prefix-zero-trimmed RLP format at the
+// historical state. Nil is returned if the slot was not existent.
+//
+// Note, slot refers to the hash of the raw slot key.
+func (r *HistoricalStateReader) Storage(address common.Address, slot common.Hash) ([]byte, error) {
+	defer func(start time.Time) { historicStorageReadTimer.UpdateSince(start) }(time.Now())
+
+	lookup := func(start uint64) (uint64, bool) {
+		return rawdb.ReadStorageHistoryIndexFrom(r.db.diskdb, address, slot, start)
+	}
+	extract := func(h *history) ([]byte, bool) {
+		blob, ok := h.storages[address][slot]
+		return blob, ok
+	}
+	resolve := func(dl *diskLayer) ([]byte, error) {
+		addrHash := crypto.Keccak256Hash(address.Bytes())
+		account, err := readAccount(dl, addrHash)
+		if err != nil || account == nil {
+			return nil, err
+		}
+		tr, err := trie.New(trie.StorageTrieID(dl.root, addrHash, account.Root), &layerDatabase{layer: dl})
+		if err != nil {
+			return nil, err
+		}
+		return tr.Get(slot.Bytes())
+	}
+	return r.read(lookup, extract, resolve)
+}
+
+// read resolves the value of a state at the historical point, which is the
+// original value recorded in the first subsequent state history modifying
+// it, or the value in the disk layer if it was never modified afterwards.
+func (r *HistoricalStateReader) read(lookup func(uint64) (uint64, bool), extract func(*history) ([]byte, bool), resolve func(*diskLayer) ([]byte, error)) ([]byte, error) {
+	for {
+		dl := r.db.tree.bottom()
+		if r.id > dl.stateID() {
+			return nil, fmt.Errorf("state id %d is above the disk layer %d", r.id, dl.stateID())
+		}
+		var (
+			last    = dl.stateID()
+			indexed = min(r.db.indexer.indexed(), last)
+			next    = r.id + 1
+		)
+		if last-max(indexed, r.id) > maxUnindexedHistories {
+			return nil, fmt.Errorf("%w: indexed %d, head %d", errStateIndexNotReady, indexed, last)
+		}
+		// Locate the modification with the state index first. The entry might
+		// be a leftover of a truncated state history, verify it against the
+		// history itself.
+		for next <= indexed {
+			id, ok := lookup(next)
+			if !ok || id > indexed {
+				next = indexed + 1
+				break
+			}
+			h, err := readHistory(r.db.freezer, id)
+			if err != nil {
+				return nil, err
+			}
+			if blob, ok := extract(h); ok {
+				return r.checked(blob)
+			}
+			next = id + 1
+		}
+		// Scan the remaining state histories not covered by the index yet.
+		for ; next <= last; next++ {
+			h, err := readHistory(r.db.freezer, next)
+			if err != nil {
+				return nil, err
+			}
+			if blob, ok := extract(h); ok {
+				return r.checked(blob)
+			}
+		}
+		// The state was not modified afterwards, resolve it from the disk layer.
+		// Retry if the disk layer was changed in the meantime.
+		blob, err := resolve(dl)
+		if errors.Is(err, errSnapshotStale) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return r.checked(blob)
+	}
+}
+
+// readAccount resolves the account from the account trie of the disk layer.
+func readAccount(dl *diskLayer, addrHash common.Hash) (*types.StateAccount, error) {
+	tr, err := trie.New(trie.StateTrieID(dl.root), &layerDatabase{layer: dl})
+	if err != nil {
+		return nil, err
+	}
+	blob, err := tr.Get(addrHash.Bytes())
+	if err != nil || len(blob) == 0 {
+		return nil, err
+	}
+	account := new(types.StateAccount)
+	if err := rlp.DecodeBytes(blob, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
@@ -0,0 +1,192 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>
+
+package pathdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+)
+
+// waitIndexed waits until the state histories are indexed up to the disk layer.
+func waitIndexed(t *testing.T, db *Database) {
+	t.Helper()
+
+	target := db.tree.bottom().stateID()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if db.indexer.indexed() == target {
+			return
+		}
+	}
+	t.Fatalf("state histories not indexed, have %d, want %d", db.indexer.indexed(), target)
+}
+
+// checkHistoricState verifies all the accounts and storage slots ever existed
+// against the historical state of the specified root.
+func checkHistoricState(tester *tester, root common.Hash) error {
+	r, err := tester.db.HistoricReader(root)
+	if err != nil {
+		return err
+	}
+	for addrHash, addr := range tester.preimages {
+		want := tester.snapAccounts[root][addrHash]
+		blob, err := r.Account(addr)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(blob, want) {
+			return fmt.Errorf("account %x mismatch, want %x, got %x", addr, want, blob)
+		}
+		for slot, value := range tester.snapStorages[root][addrHash] {
+			blob, err := r.Storage(addr, slot)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(blob, value) {
+				return fmt.Errorf("slot %x of account %x mismatch, want %x, got %x", slot, addr, value, blob)
+			}
+		}
+	}
+	return nil
+}
+
+// indexedIDs returns the ids of all the state histories referenced by the index.
+func indexedIDs(db *Database) []uint64 {
+	var ids []uint64
+	for _, prefix := range [][]byte{rawdb.StateHistoryAccountIndexPrefix, rawdb.StateHistoryStorageIndexPrefix} {
+		it := db.diskdb.NewIterator(prefix, nil)
+		for it.Next() {
+			key := it.Key()
+			ids = append(ids, binary.BigEndian.Uint64(key[len(key)-8:]))
+		}
+		it.Release()
+	}
+	return ids
+}
+
+func TestHistoricalStateReader(t *testing.T) {
+	// Redefine the diff layer depth allowance for faster testing.
+	maxDiffLayers = 4
+	defer func() {
+		maxDiffLayers = 128
+	}()
+
+	tester := newTester(t, 0, true)
+	defer tester.release()
+
+	waitIndexed(t, tester.db)
+	for i := 0; i <= tester.bottomIndex(); i++ {
+		if err := checkHistoricState(tester, tester.roots[i]); err != nil {
+			t.Fatalf("Unexpected historical state %d: %v", i, err)
+		}
+	}
+	// The states in the diff layers are not historical yet
+	if _, err := tester.db.HistoricReader(tester.lastHash()); err == nil {
+		t.Fatal("Unexpected historical reader above the disk layer")
+	}
+}
+
+func TestHistoricalStateReaderUnindexed(t *testing.T) {
+	// Redefine the diff layer depth allowance for faster testing.
+	maxDiffLayers = 4
+	defer func() {
+		maxDiffLayers = 128
+	}()
+
+	tester := newTester(t, 0, true)
+	defer tester.release()
+
+	// Stop the indexer in whatever progress it is, the histories not yet
+	// indexed should be scanned instead.
+	tester.db.indexer.close()
+
+	for i := 0; i <= tester.bottomIndex(); i++ {
+		if err := checkHistoricState(tester, tester.roots[i]); err != nil {
+			t.Fatalf("Unexpected historical state %d: %v", i, err)
+		}
+	}
+}
+
+func TestHistoricalStateReaderDisabled(t *testing.T) {
+	// Redefine the diff layer depth allowance for faster testing.
+	maxDiffLayers = 4
+	defer func() {
+		maxDiffLayers = 128
+	}()
+
+	tester := newTester(t, 0, false)
+	defer tester.release()
+
+	if _, err := tester.db.HistoricReader(tester.roots[0]); !errors.Is(err, errStateIndexDisabled) {
+		t.Fatalf("Unexpected error, want %v, got %v", errStateIndexDisabled, err)
+	}
+}
+
+func TestHistoryIndexTruncation(t *testing.T) {
+	// Redefine the diff layer depth allowance for faster testing.
+	maxDiffLayers = 4
+	defer func() {
+		maxDiffLayers = 128
+	}()
+
+	tester := newTester(t, 5, true)
+	defer tester.release()
+	waitIndexed(t, tester.db)
+
+	// The index entries of the pruned histories should be removed as well
+	tail, err := tester.db.freezer.Tail()
+	if err != nil {
+		t.Fatalf("Failed to obtain freezer tail: %v", err)
+	}
+	if tail == 0 {
+		t.Fatal("State histories are not pruned")
+	}
+	for _, id := range indexedIDs(tester.db) {
+		if id <= tail {
+			t.Fatalf("Unexpected index entry of pruned history %d, tail %d", id, tail)
+		}
+	}
+	if _, err := tester.db.HistoricReader(tester.roots[tail-1]); err == nil {
+		t.Fatal("Unexpected historical reader below the tail")
+	}
+	if err := checkHistoricState(tester, tester.roots[tail]); err != nil {
+		t.Fatalf("Unexpected historical state at tail: %v", err)
+	}
+	// Rewind the state, the index entries above should be removed
+	target := tester.roots[tester.bottomIndex()-2]
+	if err := tester.db.Recover(target); err != nil {
+		t.Fatalf("Failed to recover state: %v", err)
+	}
+	head := tester.db.tree.bottom().stateID()
+	if indexed := tester.db.indexer.indexed(); indexed > head {
+		t.Fatalf("Index head is not rewound, head %d, indexed %d", head, indexed)
+	}
+	for _, id := range indexedIDs(tester.db) {
+		if id > head {
+			t.Fatalf("Unexpected index entry of truncated history %d, head %d", id, head)
+		}
+	}
+	if err := checkHistoricState(tester, tester.roots[tail]); err != nil {
+		t.Fatalf("Unexpected historical state after rewinding: %v", err)
+	}
+}
@@ -23,10 +23,12 @@ import (
 	"io"
 	"time"
 
+	"github.com/VictoriaMetrics/fastcache"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/cachejournal"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/trie/trienode"
@@ -76,7 +78,7 @@ type journalStorage struct {
 }
 
 // loadJournal tries to parse the layer journal from the disk.
-func (db *Database) loadJournal(diskRoot common.Hash) (layer, error) {
+func (db *Database) loadJournal(diskRoot common.Hash, cleans *fastcache.Cache) (layer, error) {
 	journal := rawdb.ReadTrieJournal(db.diskdb)
 	if len(journal) == 0 {
 		return nil, errMissJournal
@@ -104,7 +106,7 @@ func (db *Database) loadJournal(diskRoot common.Hash) (layer, error) {
 		return nil, fmt.Errorf("%w want %x got %x", errUnmatchedJournal, root, diskRoot)
 	}
 	// Load the disk layer from the journal
-	base, err := db.loadDiskLayer(r)
+	base, err := db.loadDiskLayer(r, cleans)
 	if err != nil {
 		return nil, err
 	}
@@ -124,8 +126,12 @@ func (db *Database) loadLayers() layer {
 	if blob := rawdb.ReadAccountTrieNode(db.diskdb, nil); len(blob) > 0 {
 		root = crypto.Keccak256Hash(blob)
 	}
+	// CHANGE(taiko): warm up the clean cache with the persisted one if it's
+	// saved for the same persistent state.
+	cleans := db.loadCleanCache(root)
+
 	// Load the layers by resolving the journal
-	head, err := db.loadJournal(root)
+	head, err := db.loadJournal(root, cleans)
 	if err == nil {
 		return head
 	}
@@ -136,12 +142,38 @@ func (db *Database) loadLayers() layer {
 		log.Info("Failed to load journal, discard it", "err", err)
 	}
 	// Return single layer with persistent state.
-	return newDiskLayer(root, rawdb.ReadPersistentStateID(db.diskdb), db, nil, newNodeBuffer(db.bufferSize, nil, 0))
+	return newDiskLayer(root, rawdb.ReadPersistentStateID(db.diskdb), db, cleans, newNodeBuffer(db.bufferSize, nil, 0))
+}
+
+// CHANGE(taiko): loadCleanCache loads the clean cache persisted for the given
+// persistent state, nil is returned if it's not available.
+func (db *Database) loadCleanCache(root common.Hash) *fastcache.Cache {
+	if db.config.CleanCacheJournal == "" || db.config.CleanCacheSize == 0 {
+		return nil
+	}
+	return cachejournal.Load(db.config.CleanCacheJournal, db.config.CleanCacheSize, root)
+}
+
+// CHANGE(taiko): saveCleanCache persists the clean cache of the disk layer. The
+// cached nodes are all in the persistent state, as the nodes in the node buffer
+// are only cached once flushed.
+func (db *Database) saveCleanCache() {
+	dl := db.tree.bottom()
+	if db.config.CleanCacheJournal == "" || dl.cleans == nil {
+		return
+	}
+	root := types.EmptyRootHash
+	if blob := rawdb.ReadAccountTrieNode(db.diskdb, nil); len(blob) > 0 {
+		root = crypto.Keccak256Hash(blob)
+	}
+	if err := cachejournal.Save(dl.cleans, db.tracker, db.config.CleanCacheJournal, root); err != nil {
+		log.Warn("Failed to persist clean cache", "err", err)
+	}
 }
 
 // loadDiskLayer reads the binary blob from the layer journal, reconstructing
 // a new disk layer on it.
-func (db *Database) loadDiskLayer(r *rlp.Stream) (layer, error) {
+func (db *Database) loadDiskLayer(r *rlp.Stream, cleans *fastcache.Cache) (layer, error) {
 	// Resolve disk layer root
 	var root common.Hash
 	if err := r.Decode(&root); err != nil {
@@ -176,7 +208,7 @@ func (db *Database) loadDiskLayer(r *rlp.Stream) (layer, error) {
 		nodes[entry.Owner] = subset
 	}
 	// Calculate the internal state transitions by id difference.
-	base := newDiskLayer(root, id, db, nil, newNodeBuffer(db.bufferSize, nodes, id-stored))
+	base := newDiskLayer(root, id, db, cleans, newNodeBuffer(db.bufferSize, nodes, id-stored))
 	return base, nil
 }
 
@@ -48,4 +48,8 @@ var (
 	historyBuildTimeMeter  = metrics.NewRegisteredTimer("pathdb/history/time", nil)
 	historyDataBytesMeter  = metrics.NewRegisteredMeter("pathdb/history/bytes/data", nil)
 	historyIndexBytesMeter = metrics.NewRegisteredMeter("pathdb/history/bytes/index", nil)
+	historyIndexedMeter    = metrics.NewRegisteredMeter("pathdb/history/indexed", nil)
+
+	historicAccountReadTimer = metrics.NewRegisteredTimer("pathdb/historic/account/time", nil)
+	historicStorageReadTimer = metrics.NewRegisteredTimer("pathdb/historic/storage/time", nil)
 )